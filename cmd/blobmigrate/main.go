@@ -0,0 +1,26 @@
+// Command blobmigrate moves binary data contents that are still stored in
+// the database to the configured blob store.
+package main
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
+	"github.com/elina-chertova/auth-keeper.git/internal/config"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/database"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"log"
+)
+
+func main() {
+	dbConf, appConf := config.LoadEnv()
+	db := database.InitDB(&dbConf)
+
+	blobs, err := blobstore.New(appConf.Blobs)
+	if err != nil {
+		log.Fatalf("Error opening blob store: %v", err)
+	}
+	moved, err := repository.MigrateBlobs(db, blobs)
+	if err != nil {
+		log.Fatalf("Error migrating blobs after %d moved: %v", moved, err)
+	}
+	log.Printf("Moved %d blobs out of the database", moved)
+}
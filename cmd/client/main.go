@@ -14,12 +14,18 @@ func main() {
 
 	baseURLAuth := fmt.Sprintf("%s%s", baseURL, "/api/user/")
 	baseURLData := fmt.Sprintf("%s%s", baseURL, "/api/data/")
+	baseURLSend := fmt.Sprintf("%s%s", baseURL, "/api/send")
 	app := &cli.App{
 		Name:  name,
 		Usage: "Password Keeper CLI",
-		Commands: []*cli.Command{
+		// Field values such as "a,b" must not be split into several flags.
+		DisableSliceFlagSeparator: true,
+		Commands: cliApp.WithNotices(baseURLData, []*cli.Command{
 			cliApp.RegisterCommand(baseURLAuth),
 			cliApp.LoginCommand(baseURLAuth),
+			cliApp.ChangePasswordCommand(baseURLAuth),
+			cliApp.UsageCommand(baseURLAuth),
+			cliApp.KeyPairCommand(baseURLAuth),
 
 			cliApp.AddCardCommand(baseURLData),
 			cliApp.GetCardCommand(baseURLData),
@@ -32,7 +38,40 @@ func main() {
 
 			cliApp.AddLoginPasswordCommand(baseURLData),
 			cliApp.GetLoginPasswordCommand(baseURLData),
-		},
+
+			cliApp.AddOTPCommand(baseURLData),
+			cliApp.GetOTPCommand(baseURLData),
+			cliApp.OTPCommand(baseURLData),
+
+			cliApp.SSHKeyCommand(baseURLData),
+			cliApp.SSHAgentCommand(baseURLData),
+
+			cliApp.ItemCommand(baseURLData),
+
+			cliApp.FolderCommand(baseURLData),
+			cliApp.TagCommand(baseURLData),
+			cliApp.OrganizeCommand(baseURLData),
+			cliApp.HistoryCommand(baseURLData),
+			cliApp.RestoreVersionCommand(baseURLData),
+			cliApp.DeleteCommand(baseURLData),
+			cliApp.TrashCommand(baseURLData),
+
+			cliApp.ShareCommand(baseURLData),
+			cliApp.SharedWithMeCommand(baseURLData),
+			cliApp.OrgCommand(baseURLData),
+			cliApp.EmergencyCommand(baseURLData, baseURLAuth),
+			cliApp.SendCommand(baseURLSend),
+			cliApp.ImportCommand(baseURLData),
+			cliApp.ExportCommand(baseURLData),
+			cliApp.RestoreCommand(baseURLData),
+
+			cliApp.SearchCommand(baseURLData),
+			cliApp.ReindexCommand(baseURLData),
+
+			cliApp.GenerateCommand(),
+			cliApp.AuditCommand(baseURLData),
+			cliApp.BreachFilterCommand(),
+		}),
 	}
 
 	if err := app.Run(os.Args); err != nil {
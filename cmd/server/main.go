@@ -1,13 +1,27 @@
 package main
 
 import (
+	"context"
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
 	"github.com/elina-chertova/auth-keeper.git/internal/config"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/database"
 	"github.com/elina-chertova/auth-keeper.git/internal/handlers"
+	"github.com/elina-chertova/auth-keeper.git/internal/janitor"
 	"github.com/elina-chertova/auth-keeper.git/internal/middleware"
 	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+const (
+	// janitorInterval is how often the server purges expired records.
+	janitorInterval = time.Hour
+	// defaultMaxBodyBytes limits request bodies unless MAX_BODY_BYTES is set;
+	// it leaves room for the largest binary data chunk.
+	defaultMaxBodyBytes = 64 << 20
 )
 
 func main() {
@@ -19,48 +33,198 @@ func main() {
 func run() error {
 	router := gin.Default()
 	dbConf, appConf := config.LoadEnv()
+	maxBody := appConf.MaxBodyBytes
+	if maxBody == 0 {
+		maxBody = defaultMaxBodyBytes
+	}
+	router.Use(middleware.LimitBody(maxBody))
 
 	db := database.InitDB(&dbConf)
-	authRoutes(router, db)
-	dataRoutes(router, db)
+	blobs, err := blobstore.New(appConf.Blobs)
+	if err != nil {
+		return err
+	}
+	if repository.InlineBlobsLeft(db) {
+		log.Println("Binary data contents are still stored in the database; run cmd/blobmigrate")
+	}
+
+	var breach security.BreachChecker
+	if appConf.BreachDBPath != "" {
+		checker, err := security.OpenBreachChecker(appConf.BreachDBPath)
+		if err != nil {
+			return err
+		}
+		breach = checker
+	}
+
+	limits := handlers.NewLimits(
+		handlers.Quota{
+			MaxBytes:     appConf.QuotaMaxBytes,
+			MaxItemBytes: appConf.QuotaMaxItemBytes,
+			MaxItems:     appConf.QuotaMaxItems,
+		},
+		repository.NewUsageRepo(db),
+	)
+	authRoutes(router, db, breach, limits)
+	sendRoutes(router, db)
+	retention := repository.Retention{
+		MaxVersions: appConf.HistoryMaxVersions,
+		MaxAge:      time.Duration(appConf.HistoryMaxDays) * 24 * time.Hour,
+	}
+	dataRoutes(router, db, blobs, retention, limits)
+	go janitor.Run(context.Background(), janitorInterval, janitorTasks(db, blobs, appConf)...)
 
-	err := router.Run(appConf.Address)
+	err = router.Run(appConf.Address)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func authRoutes(r *gin.Engine, db *gorm.DB) {
+func authRoutes(
+	r *gin.Engine,
+	db *gorm.DB,
+	breach security.BreachChecker,
+	limits *handlers.Limits,
+) {
 	u := repository.NewUserRepo(db)
-	h := handlers.NewUserHandler(u)
+	h := handlers.NewUserHandler(u, breach)
 	r.POST("/api/user/register", h.Register())
 	r.POST("/api/user/login", h.Signup())
+	r.POST(
+		"/api/user/change-password",
+		middleware.JWTAuth(),
+		middleware.ExtractUserID(u),
+		h.ChangePassword(),
+	)
+	r.PUT(
+		"/api/user/public-key",
+		middleware.JWTAuth(),
+		middleware.ExtractUserID(u),
+		h.SetPublicKey(),
+	)
+	r.GET(
+		"/api/user/usage",
+		middleware.JWTAuth(),
+		middleware.ExtractUserID(u),
+		limits.UsageHandler(),
+	)
+	r.POST(
+		"/api/user/emergency-takeover/:id",
+		middleware.JWTAuth(),
+		middleware.ExtractUserID(u),
+		h.EmergencyTakeover(repository.NewEmergencyRepo(db)),
+	)
 }
 
-func dataRoutes(r *gin.Engine, db *gorm.DB) {
-	lp := repository.NewLPRepo(db)
-	bd := repository.NewBDRepo(db)
-	cc := repository.NewCCRepo(db)
-	td := repository.NewTDRepo(db)
+// sendRoutes adds the send routes; receiving needs no account.
+func sendRoutes(r *gin.Engine, db *gorm.DB) {
+	sh := handlers.NewSendHandler(repository.NewSendRepo(db))
+	users := repository.NewUserRepo(db)
+	r.POST("/api/send", middleware.JWTAuth(), middleware.ExtractUserID(users), sh.CreateSendHandler())
+	r.GET("/api/send/:id", sh.ReceiveSendHandler())
+	r.DELETE("/api/send/:id", middleware.JWTAuth(), middleware.ExtractUserID(users), sh.DeleteSendHandler())
+}
 
-	h := handlers.NewDataHandler(lp, bd, cc, td)
-	r.Use(middleware.JWTAuth())
-	r.Use(middleware.ExtractUserID())
+func janitorTasks(db *gorm.DB, blobs blobstore.Store, appConf config.AppConf) []janitor.Task {
+	tasks := []janitor.Task{
+		{
+			Name: "orphan blobs",
+			Run: func(time.Time) (int64, error) {
+				return repository.SweepBlobs(db, blobs)
+			},
+		},
+		{
+			Name: "expired sends",
+			Run: func(now time.Time) (int64, error) {
+				return repository.PurgeSends(db, now)
+			},
+		},
+		{
+			Name: "emergency access",
+			Run: func(now time.Time) (int64, error) {
+				return repository.ApproveEmergencyAccess(db, now)
+			},
+		},
+	}
+	if appConf.TrashRetentionDays > 0 {
+		retention := time.Duration(appConf.TrashRetentionDays) * 24 * time.Hour
+		tasks = append(
+			tasks, janitor.Task{
+				Name: "trash",
+				Run: func(now time.Time) (int64, error) {
+					return repository.PurgeTrash(db, now.Add(-retention))
+				},
+			},
+		)
+	}
+	return tasks
+}
+
+// retained sets the version retention of repo.
+func retained[T any](repo *repository.Repo[T], ret repository.Retention) *repository.Repo[T] {
+	repo.SetRetention(ret)
+	return repo
+}
+
+func dataRoutes(
+	r *gin.Engine,
+	db *gorm.DB,
+	blobs blobstore.Store,
+	retention repository.Retention,
+	limits *handlers.Limits,
+) {
 	userRepo := repository.NewUserRepo(db)
+	r.Use(middleware.JWTAuth())
+	r.Use(middleware.ExtractUserID(userRepo))
 
 	r.Use(middleware.LoadPersonalKey(userRepo))
 
-	r.POST("/api/data/add-card", h.AddCreditCardHandler())
-	r.GET("/api/data/get-card", h.GetCreditCardHandler())
+	data := r.Group("/api/data")
+	binaries := repository.NewBinaryRepo(db, blobs)
+	binaries.SetRetention(retention)
+	stores := handlers.DataStores{
+		LoginPasswords: retained(repository.NewLPRepo(db), retention),
+		TextData:       retained(repository.NewTDRepo(db), retention),
+		BinaryData:     binaries,
+		CreditCards:    retained(repository.NewCCRepo(db), retention),
+		OTPSecrets:     retained(repository.NewOTPRepo(db), retention),
+		SSHKeys:        retained(repository.NewSSHRepo(db), retention),
+		Limits:         limits,
+	}
+	handlers.RegisterDataRoutes(data, stores)
+	handlers.RegisterSearchRoutes(data, stores)
+	bh := handlers.NewBinaryHandler(binaries)
+	bh.SetLimits(limits)
+	handlers.RegisterBinaryRoutes(data, bh)
+
+	items := retained(repository.NewItemRepo(db), retention)
+	ih := handlers.NewItemHandler(items, repository.NewTemplateRepo(db))
+	ih.SetLimits(limits)
+	handlers.RegisterItemRoutes(data, ih)
 
-	r.POST("/api/data/add-text-data", h.AddTextDataHandler())
-	r.GET("/api/data/get-text-data", h.GetTextDataHandler())
+	handlers.RegisterTrashRoutes(data, handlers.NewTrashHandler(stores, items))
 
-	r.POST("/api/data/add-binary-data", h.AddBinaryDataHandler())
-	r.GET("/api/data/get-binary-data", h.GetBinaryDataHandler())
+	grants := repository.NewEmergencyRepo(db)
+	shares := repository.NewShareRepo(db)
+	data.GET("/get-notices", handlers.NewNoticeHandler(grants, shares).GetNoticeHandler())
 
-	r.POST("/api/data/add-login-password", h.AddLoginPasswordHandler())
-	r.GET("/api/data/get-login-password", h.GetLoginPasswordHandler())
+	handlers.RegisterShareRoutes(
+		data,
+		handlers.NewShareHandler(shares, userRepo, stores, items),
+	)
+	handlers.RegisterOrgRoutes(data, handlers.NewOrgHandler(repository.NewOrgRepo(db), userRepo))
+	handlers.RegisterEmergencyRoutes(
+		data,
+		handlers.NewEmergencyHandler(grants, userRepo, stores, items),
+	)
 
+	oh := handlers.NewOrganizeHandler(repository.NewFolderRepo(db), repository.NewTagRepo(db))
+	data.POST("/add-folder", oh.AddFolderHandler())
+	data.GET("/get-folder", oh.GetFolderHandler())
+	data.PUT("/update-folder/:id", oh.UpdateFolderHandler())
+	data.DELETE("/delete-folder/:id", oh.DeleteFolderHandler())
+	data.GET("/get-tag", oh.GetTagHandler())
+	data.PUT("/update-tag", oh.UpdateTagHandler())
+	data.DELETE("/delete-tag/:name", oh.DeleteTagHandler())
 }
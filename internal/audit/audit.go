@@ -0,0 +1,239 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/elina-chertova/auth-keeper.git/internal/validation"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	ItemLoginPassword = "login_password"
+	ItemCreditCard    = "credit_card"
+)
+
+const (
+	IssueCompromised   = "compromised"
+	IssueReused        = "reused"
+	IssueWeak          = "weak"
+	IssueOld           = "old"
+	IssueExpired       = "expired"
+	IssueExpiringSoon  = "expiring_soon"
+	IssueInvalidExpiry = "invalid_expiry"
+	IssueFailsLuhn     = "fails_luhn"
+)
+
+type Finding struct {
+	ItemType string `json:"item_type"`
+	ItemID   uint   `json:"item_id"`
+	Label    string `json:"label"`
+	Issue    string `json:"issue"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type Report struct {
+	CheckedLoginPasswords int `json:"checked_login_passwords"`
+	// HashedLoginPasswords counts the entries whose password is a bcrypt
+	// hash, as the server stores them; only their age is checked.
+	HashedLoginPasswords int       `json:"hashed_login_passwords"`
+	CheckedCreditCards   int       `json:"checked_credit_cards"`
+	Findings             []Finding `json:"findings"`
+}
+
+type Options struct {
+	// MaxPasswordAge flags passwords not updated for longer; zero disables.
+	MaxPasswordAge time.Duration
+	// ExpiringWithin flags cards that expire within this window.
+	ExpiringWithin time.Duration
+	// MinScore is the lowest acceptable strength score.
+	MinScore int
+	// Breach is optional.
+	Breach security.BreachChecker
+	Now    time.Time
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxPasswordAge: 365 * 24 * time.Hour,
+		ExpiringWithin: 30 * 24 * time.Hour,
+		MinScore:       security.MinAccountPasswordScore,
+		Now:            time.Now(),
+	}
+}
+
+// Run checks the decrypted vault and returns every finding.
+func Run(
+	lps []*models.LoginPassword,
+	cards []*models.CreditCard,
+	opts Options,
+) (*Report, error) {
+	report := &Report{
+		CheckedLoginPasswords: len(lps),
+		CheckedCreditCards:    len(cards),
+		Findings:              []Finding{},
+	}
+
+	for _, lp := range lps {
+		if security.IsPasswordHash(lp.Password) {
+			report.HashedLoginPasswords++
+		}
+	}
+	findings, err := checkLoginPasswords(lps, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.Findings = append(report.Findings, findings...)
+	report.Findings = append(report.Findings, checkCreditCards(cards, opts)...)
+	return report, nil
+}
+
+func checkLoginPasswords(lps []*models.LoginPassword, opts Options) ([]Finding, error) {
+	var findings []Finding
+
+	usage := make(map[string]int)
+	for _, lp := range lps {
+		usage[lp.Password]++
+	}
+	checkAge := func(lp *models.LoginPassword, add func(issue, detail string)) {
+		if opts.MaxPasswordAge > 0 && !lp.UpdatedAt.IsZero() {
+			if age := opts.Now.Sub(lp.UpdatedAt); age > opts.MaxPasswordAge {
+				add(IssueOld, fmt.Sprintf("last changed %d days ago", int(age.Hours()/24)))
+			}
+		}
+	}
+
+	for _, lp := range lps {
+		add := func(issue, detail string) {
+			findings = append(
+				findings, Finding{
+					ItemType: ItemLoginPassword,
+					ItemID:   lp.ID,
+					Label:    lp.Login,
+					Issue:    issue,
+					Detail:   detail,
+				},
+			)
+		}
+
+		// A hash tells nothing about the password.
+		if security.IsPasswordHash(lp.Password) {
+			checkAge(lp, add)
+			continue
+		}
+
+		if opts.Breach != nil {
+			breached, err := opts.Breach.IsBreached(lp.Password)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check password %d: %w", lp.ID, err)
+			}
+			if breached {
+				add(IssueCompromised, "password appears in a known breach")
+			}
+		}
+
+		if n := usage[lp.Password]; n > 1 {
+			add(IssueReused, fmt.Sprintf("password is used by %d entries", n))
+		}
+
+		if strength := security.EstimateStrength(lp.Password, lp.Login); strength.Score < opts.MinScore {
+			detail := fmt.Sprintf("strength %d/4", strength.Score)
+			if strength.Warning != "" {
+				detail += ": " + strength.Warning
+			}
+			add(IssueWeak, detail)
+		}
+
+		checkAge(lp, add)
+	}
+	return findings, nil
+}
+
+func checkCreditCards(cards []*models.CreditCard, opts Options) []Finding {
+	var findings []Finding
+	for _, cc := range cards {
+		add := func(issue, detail string) {
+			findings = append(
+				findings, Finding{
+					ItemType: ItemCreditCard,
+					ItemID:   cc.ID,
+					Label:    validation.DetectBrand(cc.CardNumber) + " " + maskCardNumber(cc.CardNumber),
+					Issue:    issue,
+					Detail:   detail,
+				},
+			)
+		}
+
+		if !validation.Luhn(cc.CardNumber) {
+			add(IssueFailsLuhn, "card number fails the Luhn check")
+		}
+
+		expiresAt, err := validation.ParseExpiry(cc.ExpiryDate)
+		switch {
+		case err != nil:
+			add(IssueInvalidExpiry, err.Error())
+		case !opts.Now.Before(expiresAt):
+			add(IssueExpired, "expired "+cc.ExpiryDate)
+		case expiresAt.Sub(opts.Now) <= opts.ExpiringWithin:
+			add(IssueExpiringSoon, "expires "+cc.ExpiryDate)
+		}
+	}
+	return findings
+}
+
+func maskCardNumber(number string) string {
+	digits := validation.DigitsOnly(number)
+	if len(digits) <= 4 {
+		return digits
+	}
+	return "**** " + digits[len(digits)-4:]
+}
+
+func (r *Report) HasFindings() bool {
+	return len(r.Findings) > 0
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *Report) WriteTable(w io.Writer) error {
+	findings := make([]Finding, len(r.Findings))
+	copy(findings, r.Findings)
+	sort.SliceStable(
+		findings, func(i, j int) bool {
+			if findings[i].ItemType != findings[j].ItemType {
+				return findings[i].ItemType > findings[j].ItemType
+			}
+			return findings[i].ItemID < findings[j].ItemID
+		},
+	)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tID\tLABEL\tISSUE\tDETAIL")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", f.ItemType, f.ItemID, f.Label, f.Issue, f.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(
+		w,
+		"\nChecked %d login-password entries and %d credit cards: %d issues found\n",
+		r.CheckedLoginPasswords,
+		r.CheckedCreditCards,
+		len(r.Findings),
+	)
+	if err == nil && r.HashedLoginPasswords > 0 {
+		_, err = fmt.Fprintf(
+			w, "%d passwords are stored as hashes; only their age was checked\n", r.HashedLoginPasswords,
+		)
+	}
+	return err
+}
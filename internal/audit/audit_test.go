@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBreachChecker map[string]bool
+
+func (m mockBreachChecker) IsBreached(password string) (bool, error) {
+	return m[password], nil
+}
+
+func issuesFor(findings []Finding, itemType string, id uint) []string {
+	var issues []string
+	for _, f := range findings {
+		if f.ItemType == itemType && f.ItemID == id {
+			issues = append(issues, f.Issue)
+		}
+	}
+	return issues
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-24 * time.Hour)
+
+	lps := []*models.LoginPassword{
+		{
+			Model:    gorm.Model{ID: 1, UpdatedAt: recent},
+			Login:    "alice",
+			Password: "violet-anvil-harbor-quiver",
+		},
+		{
+			Model:    gorm.Model{ID: 2, UpdatedAt: recent},
+			Login:    "bob",
+			Password: "tundra-orchid-zigzag-lantern",
+		},
+		{
+			Model:    gorm.Model{ID: 3, UpdatedAt: recent},
+			Login:    "carol",
+			Password: "tundra-orchid-zigzag-lantern",
+		},
+		{
+			Model:    gorm.Model{ID: 4, UpdatedAt: recent},
+			Login:    "dave",
+			Password: "qwerty",
+		},
+		{
+			Model:    gorm.Model{ID: 5, UpdatedAt: now.Add(-400 * 24 * time.Hour)},
+			Login:    "eve",
+			Password: "marble-yogurt-walrus-cactus",
+		},
+		{
+			Model:    gorm.Model{ID: 6, UpdatedAt: recent},
+			Login:    "frank",
+			Password: "pepper-nomad-tulip-gecko",
+		},
+		{
+			Model:    gorm.Model{ID: 7, UpdatedAt: now.Add(-400 * 24 * time.Hour)},
+			Login:    "grace",
+			Password: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		},
+	}
+	cards := []*models.CreditCard{
+		{Model: gorm.Model{ID: 1}, CardNumber: "4111 1111 1111 1111", ExpiryDate: "12/2030"},
+		{Model: gorm.Model{ID: 2}, CardNumber: "4111111111111111", ExpiryDate: "09/26"},
+		{Model: gorm.Model{ID: 3}, CardNumber: "4111111111111111", ExpiryDate: "10/26"},
+		{Model: gorm.Model{ID: 4}, CardNumber: "1234-5678-9876-5432", ExpiryDate: "01/30"},
+		{Model: gorm.Model{ID: 5}, CardNumber: "5555555555554444", ExpiryDate: "2030-01"},
+	}
+
+	opts := DefaultOptions()
+	opts.Now = now
+	opts.Breach = mockBreachChecker{"pepper-nomad-tulip-gecko": true}
+
+	report, err := Run(lps, cards, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, report.CheckedLoginPasswords)
+	assert.Equal(t, 1, report.HashedLoginPasswords)
+	assert.Equal(t, 5, report.CheckedCreditCards)
+	assert.True(t, report.HasFindings())
+
+	tests := []struct {
+		name     string
+		itemType string
+		id       uint
+		want     []string
+	}{
+		{name: "Healthy password", itemType: ItemLoginPassword, id: 1, want: nil},
+		{name: "Reused password", itemType: ItemLoginPassword, id: 2, want: []string{IssueReused}},
+		{name: "Reused password twin", itemType: ItemLoginPassword, id: 3, want: []string{IssueReused}},
+		{name: "Weak password", itemType: ItemLoginPassword, id: 4, want: []string{IssueWeak}},
+		{name: "Old password", itemType: ItemLoginPassword, id: 5, want: []string{IssueOld}},
+		{name: "Compromised password", itemType: ItemLoginPassword, id: 6, want: []string{IssueCompromised}},
+		{name: "Hashed password", itemType: ItemLoginPassword, id: 7, want: []string{IssueOld}},
+		{name: "Healthy card", itemType: ItemCreditCard, id: 1, want: nil},
+		{name: "Expired card", itemType: ItemCreditCard, id: 2, want: []string{IssueExpired}},
+		{name: "Expiring card", itemType: ItemCreditCard, id: 3, want: []string{IssueExpiringSoon}},
+		{name: "Luhn failure", itemType: ItemCreditCard, id: 4, want: []string{IssueFailsLuhn}},
+		{name: "Invalid expiry", itemType: ItemCreditCard, id: 5, want: []string{IssueInvalidExpiry}},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.want, issuesFor(report.Findings, tt.itemType, tt.id))
+			},
+		)
+	}
+}
+
+func TestReportOutput(t *testing.T) {
+	report := &Report{
+		CheckedLoginPasswords: 1,
+		CheckedCreditCards:    1,
+		Findings: []Finding{
+			{ItemType: ItemCreditCard, ItemID: 7, Label: "**** 1111", Issue: IssueExpired},
+			{ItemType: ItemLoginPassword, ItemID: 3, Label: "alice", Issue: IssueWeak},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteJSON(&buf))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, *report, decoded)
+
+	buf.Reset()
+	assert.NoError(t, report.WriteTable(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "TYPE")
+	assert.Less(t, bytes.Index(buf.Bytes(), []byte("alice")), bytes.Index(buf.Bytes(), []byte("**** 1111")))
+	assert.Contains(t, out, "2 issues found")
+}
+
+func TestRunEmptyVault(t *testing.T) {
+	report, err := Run(nil, nil, DefaultOptions())
+	assert.NoError(t, err)
+	assert.False(t, report.HasFindings())
+	assert.NotNil(t, report.Findings)
+}
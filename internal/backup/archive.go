@@ -0,0 +1,355 @@
+// Package backup writes and reads passphrase-encrypted backups of a whole
+// vault and plans restoring them.
+//
+// A backup starts with a header: the magic "AKBACKUP", the format version,
+// the Argon2id parameters and salt the key is derived from the passphrase
+// with, and the header of a package stream. The rest is a gzipped tar
+// sealed in chunks with package stream under that key: vault.json holds
+// the items, content/<id> the file of every binary data. Changing any
+// header byte changes the key or is rejected, and chunks cannot be dropped,
+// reordered or cut off at the end without reading the backup failing.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
+	"golang.org/x/crypto/argon2"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Version is the version of the backup format written.
+	Version = 1
+
+	magic      = "AKBACKUP"
+	saltSize   = 16
+	keySize    = 32
+	headerSize = len(magic) + 1 + 4 + 4 + 1 + saltSize + stream.HeaderSize
+	chunkSize  = stream.DefaultChunkSize
+
+	vaultFile  = "vault.json"
+	contentDir = "content/"
+
+	// maxMemory bounds the Argon2id memory, in KiB, a backup may ask for.
+	maxMemory = 4 << 20
+	maxTime   = 64
+)
+
+var (
+	ErrNotBackup       = errors.New("not a vault backup")
+	ErrVersion         = errors.New("unsupported backup version")
+	ErrPassphrase      = errors.New("wrong passphrase or damaged backup")
+	ErrDamaged         = errors.New("damaged backup")
+	ErrEmptyPassphrase = errors.New("passphrase is empty")
+)
+
+// Params are the Argon2id parameters of a backup key; Memory is in KiB.
+type Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// DefaultParams follow the second recommendation of RFC 9106 with a
+// higher number of passes.
+var DefaultParams = Params{Time: 3, Memory: 64 << 10, Threads: 4}
+
+func (p Params) valid() bool {
+	return p.Time > 0 && p.Time <= maxTime && p.Memory >= 8*uint32(p.Threads) &&
+		p.Memory <= maxMemory && p.Threads > 0
+}
+
+func deriveKey(passphrase string, salt []byte, p Params) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, keySize)
+}
+
+// Writer writes an encrypted backup of a vault. The content of every
+// binary data of the vault must be written with WriteContent before Close.
+type Writer struct {
+	seal    *sealWriter
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	pending map[uint]int64
+}
+
+// NewWriter writes the header and the items of v to w and returns the
+// Writer of the contents of its binary data.
+func NewWriter(w io.Writer, passphrase string, params Params, v *Vault) (*Writer, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	if !params.valid() {
+		return nil, fmt.Errorf("invalid key derivation parameters %+v", params)
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	streamHeader, err := stream.NewHeader()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, Version)
+	header = binary.BigEndian.AppendUint32(header, params.Time)
+	header = binary.BigEndian.AppendUint32(header, params.Memory)
+	header = append(header, params.Threads)
+	header = append(header, salt...)
+	header = append(header, streamHeader...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	c, err := stream.New(deriveKey(passphrase, salt, params), streamHeader)
+	if err != nil {
+		return nil, err
+	}
+	seal := &sealWriter{w: w, c: c}
+	gz := gzip.NewWriter(seal)
+	bw := &Writer{seal: seal, gz: gz, tw: tar.NewWriter(gz), pending: make(map[uint]int64)}
+
+	v.Version = Version
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vault: %w", err)
+	}
+	if err := bw.writeFile(vaultFile, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	for _, bd := range v.BinaryData {
+		bw.pending[bd.ID] = bd.Size
+	}
+	return bw, nil
+}
+
+// WriteContent writes the content of binary data id, which must be as
+// long as its Size.
+func (w *Writer) WriteContent(id uint, r io.Reader) error {
+	size, ok := w.pending[id]
+	if !ok {
+		return fmt.Errorf("binary data %d is not in the vault or already written", id)
+	}
+	if err := w.writeFile(contentDir+strconv.FormatUint(uint64(id), 10), size, r); err != nil {
+		return fmt.Errorf("failed to write binary data %d: %w", id, err)
+	}
+	delete(w.pending, id)
+	return nil
+}
+
+func (w *Writer) writeFile(name string, size int64, r io.Reader) error {
+	err := w.tw.WriteHeader(
+		&tar.Header{Name: name, Mode: 0600, Size: size, Typeflag: tar.TypeReg, ModTime: time.Unix(0, 0)},
+	)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(w.tw, io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("got %d bytes, expected %d", n, size)
+	}
+	return nil
+}
+
+// Close seals the last chunk. It fails if the content of a binary data
+// has not been written; the backup is then incomplete.
+func (w *Writer) Close() error {
+	if len(w.pending) > 0 {
+		return fmt.Errorf("content of %d binary data not written", len(w.pending))
+	}
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if err := w.gz.Close(); err != nil {
+		return err
+	}
+	return w.seal.Close()
+}
+
+// sealWriter seals what is written to it in chunks of chunkSize bytes.
+type sealWriter struct {
+	w      io.Writer
+	c      *stream.Cipher
+	buf    []byte
+	number int
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for len(s.buf) > chunkSize {
+		if _, err := s.w.Write(s.c.Seal(s.number, s.buf[:chunkSize], false)); err != nil {
+			return 0, err
+		}
+		s.number++
+		s.buf = append(s.buf[:0], s.buf[chunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (s *sealWriter) Close() error {
+	_, err := s.w.Write(s.c.Seal(s.number, s.buf, true))
+	s.buf = nil
+	return err
+}
+
+// Reader reads an encrypted backup: the vault first, then the contents of
+// its binary data with Next.
+type Reader struct {
+	tr    *tar.Reader
+	vault *Vault
+}
+
+// NewReader reads the header and the items of a backup. ErrPassphrase
+// means the passphrase is wrong or the start of the backup is damaged.
+func NewReader(r io.Reader, passphrase string) (*Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrNotBackup
+	}
+	if !strings.HasPrefix(string(header), magic) {
+		return nil, ErrNotBackup
+	}
+	rest := header[len(magic):]
+	if rest[0] != Version {
+		return nil, fmt.Errorf("%w %d", ErrVersion, rest[0])
+	}
+	params := Params{
+		Time:    binary.BigEndian.Uint32(rest[1:5]),
+		Memory:  binary.BigEndian.Uint32(rest[5:9]),
+		Threads: rest[9],
+	}
+	if !params.valid() {
+		return nil, fmt.Errorf("%w: invalid key derivation parameters", ErrNotBackup)
+	}
+	salt := rest[10 : 10+saltSize]
+	c, err := stream.New(deriveKey(passphrase, salt, params), rest[10+saltSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(&openReader{r: bufio.NewReader(r), c: c})
+	if err != nil {
+		return nil, damaged(err)
+	}
+	br := &Reader{tr: tar.NewReader(gz)}
+	hdr, err := br.tr.Next()
+	if err != nil || hdr.Name != vaultFile {
+		return nil, damaged(err)
+	}
+	if err := json.NewDecoder(br.tr).Decode(&br.vault); err != nil {
+		return nil, damaged(err)
+	}
+	return br, nil
+}
+
+// Vault returns the items of the backup.
+func (r *Reader) Vault() *Vault {
+	return r.vault
+}
+
+// Next returns the ID of the next binary data in the backup and a reader
+// of its content, valid until the next call. It returns io.EOF after the
+// last one.
+func (r *Reader) Next() (uint, io.Reader, error) {
+	hdr, err := r.tr.Next()
+	if errors.Is(err, io.EOF) {
+		return 0, nil, io.EOF
+	}
+	if err != nil {
+		return 0, nil, damaged(err)
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(hdr.Name, contentDir), 10, 64)
+	if !strings.HasPrefix(hdr.Name, contentDir) || err != nil {
+		return 0, nil, fmt.Errorf("%w: unexpected file %s", ErrDamaged, hdr.Name)
+	}
+	return uint(id), &contentReader{r: r.tr}, nil
+}
+
+// contentReader reports damage found while reading a content.
+type contentReader struct {
+	r io.Reader
+}
+
+func (c *contentReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = damaged(err)
+	}
+	return n, err
+}
+
+func damaged(err error) error {
+	if err == nil || errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if errors.Is(err, ErrPassphrase) || errors.Is(err, ErrDamaged) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrDamaged, err)
+}
+
+// openReader opens the chunks sealed by sealWriter.
+type openReader struct {
+	r      *bufio.Reader
+	c      *stream.Cipher
+	plain  []byte
+	number int
+	done   bool
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.plain) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		if err := o.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+func (o *openReader) next() error {
+	sealed := make([]byte, chunkSize+stream.Overhead)
+	n, err := io.ReadFull(o.r, sealed)
+	switch {
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("%w: truncated", ErrDamaged)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		o.done = true
+	case err != nil:
+		return err
+	default:
+		_, err := o.r.Peek(1)
+		o.done = errors.Is(err, io.EOF)
+	}
+	plain, err := o.c.Open(o.number, sealed[:n], o.done)
+	if err != nil {
+		if o.number == 0 {
+			return ErrPassphrase
+		}
+		return fmt.Errorf("%w: %v", ErrDamaged, err)
+	}
+	o.plain = plain
+	o.number++
+	return nil
+}
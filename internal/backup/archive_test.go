@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// testParams keep key derivation fast in tests.
+var testParams = Params{Time: 1, Memory: 64, Threads: 1}
+
+func testVault(contents map[uint][]byte) *Vault {
+	folder := uint(7)
+	v := &Vault{
+		Folders: []*models.Folder{{Model: gorm.Model{ID: folder}, Name: "Work"}},
+		LoginPasswords: []*models.LoginPassword{
+			{
+				Model:    gorm.Model{ID: 1},
+				ItemMeta: models.ItemMeta{DisplayName: "Mail", FolderID: &folder, Tags: []string{"email"}},
+				Login:    "jane",
+				Password: "s3cret",
+				URL:      "https://mail.example.com",
+			},
+		},
+		TextData: []*models.TextData{{Model: gorm.Model{ID: 2}, Content: "remember"}},
+	}
+	for id, content := range contents {
+		v.BinaryData = append(
+			v.BinaryData, &models.BinaryData{Model: gorm.Model{ID: id}, FileName: "f", Size: int64(len(content))},
+		)
+	}
+	return v
+}
+
+func writeBackup(t *testing.T, v *Vault, contents map[uint][]byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "correct horse", testParams, v)
+	require.NoError(t, err)
+	for id, content := range contents {
+		require.NoError(t, w.WriteContent(id, bytes.NewReader(content)))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestBackup_RoundTrip(t *testing.T) {
+	large := make([]byte, 2*chunkSize+123)
+	_, err := rand.Read(large)
+	require.NoError(t, err)
+	contents := map[uint][]byte{3: []byte("small file"), 4: large, 5: {}}
+	data := writeBackup(t, testVault(contents), contents)
+
+	r, err := NewReader(bytes.NewReader(data), "correct horse")
+	require.NoError(t, err)
+	v := r.Vault()
+	assert.Equal(t, Version, v.Version)
+	assert.False(t, v.CreatedAt.IsZero())
+	require.Len(t, v.LoginPasswords, 1)
+	assert.Equal(t, "s3cret", v.LoginPasswords[0].Password)
+	assert.Equal(t, uint(7), *v.LoginPasswords[0].FolderID)
+	assert.Equal(t, "remember", v.TextData[0].Content)
+	assert.Len(t, v.BinaryData, 3)
+
+	read := make(map[uint][]byte)
+	for {
+		id, content, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		read[id], err = io.ReadAll(content)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, contents, read)
+}
+
+func TestNewReader_Errors(t *testing.T) {
+	// Random content does not compress, so the backup has three chunks.
+	content := make([]byte, 2*chunkSize)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+	contents := map[uint][]byte{3: content}
+	data := writeBackup(t, testVault(contents), contents)
+
+	_, err = NewReader(bytes.NewReader(data), "wrong horse")
+	assert.ErrorIs(t, err, ErrPassphrase)
+
+	_, err = NewReader(bytes.NewReader([]byte("PK\x03\x04 not a backup at all, just some bytes")), "correct horse")
+	assert.ErrorIs(t, err, ErrNotBackup)
+
+	newer := append([]byte(nil), data...)
+	newer[len(magic)] = Version + 1
+	_, err = NewReader(bytes.NewReader(newer), "correct horse")
+	assert.ErrorIs(t, err, ErrVersion)
+
+	// Other Argon2id parameters derive another key.
+	params := append([]byte(nil), data...)
+	params[len(magic)+4]++
+	_, err = NewReader(bytes.NewReader(params), "correct horse")
+	assert.ErrorIs(t, err, ErrPassphrase)
+
+	readAll := func(data []byte) error {
+		r, err := NewReader(bytes.NewReader(data), "correct horse")
+		if err != nil {
+			return err
+		}
+		for {
+			_, content, err := r.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(io.Discard, content); err != nil {
+				return err
+			}
+		}
+	}
+	require.NoError(t, readAll(data))
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	assert.ErrorIs(t, readAll(tampered), ErrDamaged)
+
+	// Cut off after the second chunk, which is then taken as the last one.
+	truncated := data[:headerSize+2*(chunkSize+stream.Overhead)]
+	assert.ErrorIs(t, readAll(truncated), ErrDamaged)
+}
+
+func TestWriter_Errors(t *testing.T) {
+	contents := map[uint][]byte{3: []byte("content")}
+
+	_, err := NewWriter(io.Discard, "", testParams, testVault(contents))
+	assert.ErrorIs(t, err, ErrEmptyPassphrase)
+	_, err = NewWriter(io.Discard, "pass", Params{}, testVault(contents))
+	assert.Error(t, err)
+
+	w, err := NewWriter(io.Discard, "pass", testParams, testVault(contents))
+	require.NoError(t, err)
+	assert.Error(t, w.WriteContent(9, bytes.NewReader(nil)), "not in the vault")
+	assert.Error(t, w.WriteContent(3, bytes.NewReader([]byte("short"))))
+
+	w, err = NewWriter(io.Discard, "pass", testParams, testVault(contents))
+	require.NoError(t, err)
+	assert.Error(t, w.Close(), "content missing")
+}
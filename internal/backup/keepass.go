@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/item"
+	"github.com/elina-chertova/auth-keeper.git/internal/keepass"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// keePassName is the name of the root group and the database.
+const keePassName = "auth-keeper"
+
+// WriteKeePass writes the items of v to a KeePass KDBX 4 database encrypted
+// with password. Folders become groups and the contents of binary data,
+// which must be set inline, become attachments of an entry of their own.
+// OTP secrets linked to a login are written into its entry as KeePassXC
+// does; cards, SSH keys and custom items become entries with their fields
+// as strings.
+func WriteKeePass(w io.Writer, v *Vault, password string, params keepass.Params) error {
+	k := &keePassWriter{
+		db: &keepass.Database{
+			File: &keepass.File{Meta: keepass.Meta{Generator: keePassName, DatabaseName: keePassName}},
+		},
+		groups:   make(map[uint]*keepass.Group),
+		binaries: make(map[[sha256.Size]byte]int),
+	}
+	root := &keepass.Group{UUID: keepass.NewUUID(), Name: keePassName}
+	k.groups[0] = root
+	for _, f := range v.Folders {
+		k.groups[f.ID] = &keepass.Group{UUID: keepass.NewUUID(), Name: f.Name}
+	}
+
+	logins := make(map[uint]bool, len(v.LoginPasswords))
+	for _, lp := range v.LoginPasswords {
+		logins[lp.ID] = true
+	}
+	otps := make(map[uint][]*models.OTPSecret)
+	for _, s := range v.OTPSecrets {
+		if s.LoginPasswordID != nil && logins[*s.LoginPasswordID] {
+			otps[*s.LoginPasswordID] = append(otps[*s.LoginPasswordID], s)
+		}
+	}
+
+	for _, lp := range v.LoginPasswords {
+		e := k.entry(lp.ItemMeta, lp.DisplayName, lp.Login, lp.Password, lp.URL, lp.Metadata)
+		for i, s := range otps[lp.ID] {
+			key := keepass.FieldOTP
+			if i > 0 {
+				key += " " + strconv.Itoa(i+1)
+			}
+			e.Set(key, otpURI(s), true)
+		}
+	}
+	for _, cc := range v.CreditCards {
+		e := k.entry(cc.ItemMeta, firstNonEmpty(cc.DisplayName, "Card"), "", "", "", cc.Metadata)
+		e.Set(keepass.FieldCardNumber, cc.CardNumber, true)
+		e.Set(keepass.FieldExpiryDate, cc.ExpiryDate, false)
+		e.Set(keepass.FieldCVV, cc.CVV, true)
+		e.Set(keepass.FieldCardHolder, cc.CardHolder, false)
+		if cc.Brand != "" {
+			e.Set(keepass.FieldBrand, cc.Brand, false)
+		}
+	}
+	for _, td := range v.TextData {
+		e := k.entry(td.ItemMeta, td.DisplayName, "", "", "", td.Content)
+		if td.Metadata != "" {
+			e.Set("Metadata", td.Metadata, false)
+		}
+	}
+	for _, bd := range v.BinaryData {
+		e := k.entry(bd.ItemMeta, firstNonEmpty(bd.DisplayName, bd.FileName), "", "", "", bd.Metadata)
+		k.attach(e, firstNonEmpty(bd.FileName, bd.DisplayName, "file"), bd.Content)
+	}
+	for _, s := range v.OTPSecrets {
+		if s.LoginPasswordID != nil && logins[*s.LoginPasswordID] {
+			continue
+		}
+		e := k.entry(s.ItemMeta, firstNonEmpty(s.DisplayName, s.Issuer, s.Account), s.Account, "", "", s.Metadata)
+		e.Set(keepass.FieldOTP, otpURI(s), true)
+	}
+	for _, key := range v.SSHKeys {
+		e := k.entry(key.ItemMeta, firstNonEmpty(key.DisplayName, key.Comment, key.Fingerprint), "", "", "", key.Metadata)
+		name := "id_" + strings.TrimPrefix(key.KeyType, "ssh-")
+		if strings.HasPrefix(key.KeyType, "ecdsa-") {
+			name = "id_ecdsa"
+		}
+		k.attach(e, name, []byte(key.PrivateKey))
+		k.attach(e, name+".pub", []byte(key.PublicKey))
+	}
+	for _, it := range v.Items {
+		e := k.entry(it.ItemMeta, firstNonEmpty(it.DisplayName, it.Name), "", "", "", it.Metadata)
+		for _, f := range it.Fields {
+			if !keePassStandard[f.Name] {
+				e.Set(f.Name, f.Value, f.Type == item.FieldConcealed)
+			}
+		}
+	}
+
+	seen := make(map[uint]bool)
+	k.tree(root, 0, v.Folders, seen)
+	// Folders in a cycle are not below the root group; they go to it.
+	for _, f := range v.Folders {
+		if !seen[f.ID] {
+			k.tree(k.groups[f.ID], f.ID, v.Folders, seen)
+			root.Groups = append(root.Groups, *k.groups[f.ID])
+		}
+	}
+	k.db.File.Root.Groups = []keepass.Group{*root}
+	return keepass.Write(w, password, k.db, params)
+}
+
+// keePassStandard are the strings KeePass keeps for every entry.
+var keePassStandard = map[string]bool{
+	keepass.FieldTitle: true, keepass.FieldUserName: true, keepass.FieldPassword: true, keepass.FieldURL: true,
+	keepass.FieldNotes: true,
+}
+
+type keePassWriter struct {
+	db *keepass.Database
+	// groups are the groups by folder ID, the root group by 0.
+	groups map[uint]*keepass.Group
+	// binaries are the indexes of the attachments by their SHA-256, so that
+	// equal files are written once.
+	binaries map[[sha256.Size]byte]int
+}
+
+// entry adds an entry to the group of meta's folder.
+func (k *keePassWriter) entry(meta models.ItemMeta, title, username, password, url, notes string) *keepass.Entry {
+	group := k.groups[0]
+	if meta.FolderID != nil && k.groups[*meta.FolderID] != nil {
+		group = k.groups[*meta.FolderID]
+	}
+	e := keepass.Entry{UUID: keepass.NewUUID(), Tags: strings.Join(meta.Tags, ";")}
+	e.Set(keepass.FieldTitle, title, false)
+	e.Set(keepass.FieldUserName, username, false)
+	e.Set(keepass.FieldPassword, password, true)
+	e.Set(keepass.FieldURL, url, false)
+	e.Set(keepass.FieldNotes, notes, false)
+	group.Entries = append(group.Entries, e)
+	return &group.Entries[len(group.Entries)-1]
+}
+
+func (k *keePassWriter) attach(e *keepass.Entry, name string, content []byte) {
+	sum := sha256.Sum256(content)
+	i, ok := k.binaries[sum]
+	if !ok {
+		i = len(k.db.Binaries)
+		k.binaries[sum] = i
+		k.db.Binaries = append(k.db.Binaries, content)
+	}
+	ref := keepass.BinaryRef{Key: name}
+	ref.Value.Ref = strconv.Itoa(i)
+	e.Binaries = append(e.Binaries, ref)
+}
+
+// tree adds the groups of the subfolders of folder id to group.
+func (k *keePassWriter) tree(group *keepass.Group, id uint, folders []*models.Folder, seen map[uint]bool) {
+	seen[id] = true
+	for _, f := range folders {
+		parent := uint(0)
+		if f.ParentID != nil && k.groups[*f.ParentID] != nil {
+			parent = *f.ParentID
+		}
+		if parent != id || seen[f.ID] {
+			continue
+		}
+		sub := k.groups[f.ID]
+		k.tree(sub, f.ID, folders, seen)
+		group.Groups = append(group.Groups, *sub)
+	}
+}
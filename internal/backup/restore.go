@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/jinzhu/gorm"
+	"reflect"
+	"strings"
+)
+
+// What restoring does with an item of a backup that has the name of an
+// existing item of the same type but other contents.
+const (
+	// ConflictSkip keeps the existing item only.
+	ConflictSkip = "skip"
+	// ConflictKeep adds the item of the backup next to the existing one.
+	ConflictKeep = "keep"
+	// ConflictReplace replaces the existing item, whose contents stay in
+	// its history.
+	ConflictReplace = "replace"
+)
+
+// Conflicts lists the conflict modes of Plan.
+var Conflicts = []string{ConflictSkip, ConflictKeep, ConflictReplace}
+
+// Restore is what restoring a backup into a vault does.
+type Restore struct {
+	// Add holds the items to add with the IDs they have in the backup,
+	// the folders of the backup and the item templates the vault lacks.
+	Add *Vault
+	// Replace holds the items replacing existing items, with the IDs of
+	// the items they replace.
+	Replace *Vault
+	// Same counts the items left out because the vault has them already,
+	// Conflicts the items named like an existing item.
+	Same      int
+	Conflicts int
+	// LoginIDs maps the IDs of backup logins that are not added to the
+	// IDs of the existing logins they match or replace, to link OTP
+	// secrets to.
+	LoginIDs map[uint]uint
+	// BinarySources maps the IDs of the binary data in Replace to the IDs
+	// their contents have in the backup.
+	BinarySources map[uint]uint
+}
+
+// Plan compares the backup v with the existing vault and decides what to
+// add and replace. Items are the same when they differ only in ID, owner,
+// folder and timestamps; conflicts are resolved by mode.
+func Plan(v, existing *Vault, mode string) (*Restore, error) {
+	switch mode {
+	case ConflictSkip, ConflictKeep, ConflictReplace:
+	default:
+		return nil, fmt.Errorf("unknown conflict mode %q, expected one of %s", mode, strings.Join(Conflicts, ", "))
+	}
+	r := &Restore{
+		Add:     &Vault{Version: v.Version, CreatedAt: v.CreatedAt, Folders: v.Folders},
+		Replace: new(Vault),
+	}
+
+	r.Add.LoginPasswords, r.Replace.LoginPasswords, r.LoginIDs, _ = plan(
+		r, mode, v.LoginPasswords, existing.LoginPasswords, nil,
+		func(lp *models.LoginPassword) string { return lp.DisplayName },
+	)
+	r.Add.CreditCards, r.Replace.CreditCards, _, _ = plan(
+		r, mode, v.CreditCards, existing.CreditCards, nil,
+		func(cc *models.CreditCard) string { return cc.DisplayName },
+	)
+	r.Add.TextData, r.Replace.TextData, _, _ = plan(
+		r, mode, v.TextData, existing.TextData, nil,
+		func(td *models.TextData) string { return td.DisplayName },
+	)
+	r.Add.BinaryData, r.Replace.BinaryData, _, r.BinarySources = plan(
+		r, mode, v.BinaryData, existing.BinaryData, CleanBinaryData,
+		func(bd *models.BinaryData) string { return firstNonEmpty(bd.DisplayName, bd.FileName) },
+	)
+	r.Add.OTPSecrets, r.Replace.OTPSecrets, _, _ = plan(
+		r, mode, v.OTPSecrets, existing.OTPSecrets,
+		func(s *models.OTPSecret) { s.LoginPasswordID = nil },
+		func(s *models.OTPSecret) string { return s.DisplayName },
+	)
+	r.Add.SSHKeys, r.Replace.SSHKeys, _, _ = plan(
+		r, mode, v.SSHKeys, existing.SSHKeys, nil,
+		func(k *models.SSHKey) string { return k.DisplayName },
+	)
+	r.Add.Items, r.Replace.Items, _, _ = plan(
+		r, mode, v.Items, existing.Items, nil,
+		func(it *models.Item) string { return firstNonEmpty(it.DisplayName, it.Name) },
+	)
+
+	templates := make(map[string]bool, len(existing.ItemTemplates))
+	for _, t := range existing.ItemTemplates {
+		templates[t.Name] = true
+	}
+	for _, t := range v.ItemTemplates {
+		if !templates[t.Name] {
+			r.Add.ItemTemplates = append(r.Add.ItemTemplates, t)
+		}
+	}
+	return r, nil
+}
+
+// plan splits items into those to add and those replacing an existing item.
+func plan[T any, PT interface {
+	*T
+	Meta() *models.ItemMeta
+}](
+	r *Restore,
+	mode string,
+	items, existing []*T,
+	clean func(*T),
+	name func(*T) string,
+) (add, replace []*T, matched, sources map[uint]uint) {
+	matched, sources = make(map[uint]uint), make(map[uint]uint)
+	same := make(map[string]uint, len(existing))
+	named := make(map[string]*T, len(existing))
+	for _, item := range existing {
+		same[sameKey[T, PT](item, clean)] = model(item).ID
+		if n := strings.ToLower(name(item)); n != "" {
+			if _, ok := named[n]; !ok {
+				named[n] = item
+			}
+		}
+	}
+
+	for _, item := range items {
+		id := model(item).ID
+		if existingID, ok := same[sameKey[T, PT](item, clean)]; ok {
+			r.Same++
+			matched[id] = existingID
+			continue
+		}
+		n := strings.ToLower(name(item))
+		other, ok := named[n]
+		if n == "" || !ok {
+			add = append(add, item)
+			continue
+		}
+
+		r.Conflicts++
+		switch mode {
+		case ConflictKeep:
+			add = append(add, item)
+		case ConflictSkip:
+			matched[id] = model(other).ID
+		case ConflictReplace:
+			replacing := *item
+			model(&replacing).ID = model(other).ID
+			replace = append(replace, &replacing)
+			matched[id] = model(other).ID
+			sources[model(other).ID] = id
+			// An existing item is replaced once at most.
+			delete(named, n)
+		}
+	}
+	return add, replace, matched, sources
+}
+
+// sameKey is the JSON of item without what differs between vaults.
+func sameKey[T any, PT interface {
+	*T
+	Meta() *models.ItemMeta
+}](item *T, clean func(*T)) string {
+	c := *item
+	if clean != nil {
+		clean(&c)
+	}
+	*model(&c) = gorm.Model{}
+	reflect.ValueOf(&c).Elem().FieldByName("UserID").SetString("")
+	meta := PT(&c).Meta()
+	meta.FolderID = nil
+	meta.SearchTokens = nil
+	data, _ := json.Marshal(&c)
+	return string(data)
+}
+
+// ClearModel resets the gorm.Model of an item of a backup, so that the
+// server assigns it a new ID, and returns the ID it had.
+func ClearModel[T any](item *T) uint {
+	m := model(item)
+	id := m.ID
+	*m = gorm.Model{}
+	return id
+}
+
+// model returns the gorm.Model embedded in an item.
+func model[T any](item *T) *gorm.Model {
+	return reflect.ValueOf(item).Elem().FieldByName("Model").Addr().Interface().(*gorm.Model)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func restoreVaults() (backup, existing *Vault) {
+	oldFolder, newFolder := uint(1), uint(50)
+	backup = &Vault{
+		LoginPasswords: []*models.LoginPassword{
+			{
+				Model:    gorm.Model{ID: 1},
+				ItemMeta: models.ItemMeta{DisplayName: "Mail", FolderID: &oldFolder},
+				UserID:   "jane",
+				Login:    "jane",
+				Password: "pw",
+			},
+			{Model: gorm.Model{ID: 2}, ItemMeta: models.ItemMeta{DisplayName: "Bank"}, Login: "jane", Password: "old"},
+			{Model: gorm.Model{ID: 3}, ItemMeta: models.ItemMeta{DisplayName: "Shop"}, Login: "jane", Password: "pw"},
+		},
+		BinaryData: []*models.BinaryData{
+			{Model: gorm.Model{ID: 4}, FileName: "a.txt", SHA256: "aa"},
+			{Model: gorm.Model{ID: 6}, FileName: "b.txt", SHA256: "bb"},
+		},
+		OTPSecrets: []*models.OTPSecret{
+			{Model: gorm.Model{ID: 5}, Account: "jane", Secret: "S", LoginPasswordID: &[]uint{2}[0]},
+		},
+		ItemTemplates: []*models.ItemTemplate{{Name: "wifi"}, {Name: "router"}},
+	}
+	existing = &Vault{
+		LoginPasswords: []*models.LoginPassword{
+			{
+				Model:    gorm.Model{ID: 10},
+				ItemMeta: models.ItemMeta{DisplayName: "Mail", FolderID: &newFolder},
+				UserID:   "bob",
+				Login:    "jane",
+				Password: "pw",
+			},
+			{Model: gorm.Model{ID: 11}, ItemMeta: models.ItemMeta{DisplayName: "bank"}, Login: "jane", Password: "new"},
+		},
+		BinaryData: []*models.BinaryData{
+			{
+				Model:      gorm.Model{ID: 12},
+				FileName:   "a.txt",
+				SHA256:     "aa",
+				Chunked:    true,
+				ContentKey: "key",
+				Complete:   true,
+			},
+			{Model: gorm.Model{ID: 14}, FileName: "B.txt", SHA256: "cc"},
+		},
+		OTPSecrets: []*models.OTPSecret{
+			{Model: gorm.Model{ID: 13}, Account: "jane", Secret: "S", LoginPasswordID: &[]uint{11}[0]},
+		},
+		ItemTemplates: []*models.ItemTemplate{{Name: "wifi"}},
+	}
+	return backup, existing
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		mode        string
+		added       []string
+		replaced    []uint
+		wantLoginID map[uint]uint
+		addedFiles  int
+		sources     map[uint]uint
+	}{
+		{mode: ConflictSkip, added: []string{"Shop"}, wantLoginID: map[uint]uint{1: 10, 2: 11}},
+		{mode: ConflictKeep, added: []string{"Bank", "Shop"}, wantLoginID: map[uint]uint{1: 10}, addedFiles: 1},
+		{
+			mode:        ConflictReplace,
+			added:       []string{"Shop"},
+			replaced:    []uint{11},
+			wantLoginID: map[uint]uint{1: 10, 2: 11},
+			sources:     map[uint]uint{14: 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.mode, func(t *testing.T) {
+				backup, existing := restoreVaults()
+				r, err := Plan(backup, existing, tt.mode)
+				require.NoError(t, err)
+
+				var added []string
+				for _, lp := range r.Add.LoginPasswords {
+					added = append(added, lp.DisplayName)
+				}
+				assert.Equal(t, tt.added, added)
+				var replaced []uint
+				for _, lp := range r.Replace.LoginPasswords {
+					replaced = append(replaced, lp.ID)
+					assert.Equal(t, "old", lp.Password)
+				}
+				assert.Equal(t, tt.replaced, replaced)
+				assert.Equal(t, tt.wantLoginID, r.LoginIDs)
+				assert.Len(t, r.Add.BinaryData, tt.addedFiles)
+				if tt.sources != nil {
+					assert.Equal(t, tt.sources, r.BinarySources)
+				} else {
+					assert.Empty(t, r.BinarySources)
+				}
+
+				// Equal apart from how the server stores them and what they
+				// link to.
+				assert.Empty(t, r.Add.OTPSecrets)
+				assert.Equal(t, 3, r.Same)
+				assert.Equal(t, 2, r.Conflicts)
+				require.Len(t, r.Add.ItemTemplates, 1)
+				assert.Equal(t, "router", r.Add.ItemTemplates[0].Name)
+				// The backup keeps its own IDs.
+				assert.Equal(t, uint(2), backup.LoginPasswords[1].ID)
+			},
+		)
+	}
+
+	_, err := Plan(&Vault{}, &Vault{}, "merge")
+	assert.Error(t, err)
+}
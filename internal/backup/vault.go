@@ -0,0 +1,232 @@
+package backup
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vault is everything a backup holds. Items keep the IDs they had in the
+// backed up vault; FolderID and LoginPasswordID refer to those IDs.
+// BinaryData are listed without their content, which follows in the
+// backup or, in a plaintext JSON export, is set inline.
+type Vault struct {
+	Version        int                     `json:"version"`
+	CreatedAt      time.Time               `json:"created_at"`
+	Folders        []*models.Folder        `json:"folders"`
+	LoginPasswords []*models.LoginPassword `json:"login_passwords"`
+	CreditCards    []*models.CreditCard    `json:"credit_cards"`
+	TextData       []*models.TextData      `json:"text_data"`
+	BinaryData     []*models.BinaryData    `json:"binary_data"`
+	OTPSecrets     []*models.OTPSecret     `json:"otp_secrets"`
+	SSHKeys        []*models.SSHKey        `json:"ssh_keys"`
+	Items          []*models.Item          `json:"items"`
+	ItemTemplates  []*models.ItemTemplate  `json:"item_templates"`
+}
+
+// Len returns the number of items in v, not counting folders and item
+// templates.
+func (v *Vault) Len() int {
+	return len(v.LoginPasswords) + len(v.CreditCards) + len(v.TextData) + len(v.BinaryData) +
+		len(v.OTPSecrets) + len(v.SSHKeys) + len(v.Items)
+}
+
+// CleanBinaryData drops what describes how the server stores a binary
+// data, so that it can be added again as a new file.
+func CleanBinaryData(bd *models.BinaryData) {
+	*bd = models.BinaryData{
+		Model:    bd.Model,
+		ItemMeta: bd.ItemMeta,
+		UserID:   bd.UserID,
+		Content:  bd.Content,
+		Metadata: bd.Metadata,
+		FileName: bd.FileName,
+		MIMEType: bd.MIMEType,
+		Size:     bd.Size,
+		SHA256:   bd.SHA256,
+	}
+}
+
+// WriteJSON writes v as indented plaintext JSON.
+func WriteJSON(w io.Writer, v *Vault) error {
+	v.Version = Version
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now().UTC()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// csvHeader are the columns of a plaintext CSV export.
+var csvHeader = []string{
+	"type", "folder", "name", "favorite", "tags", "username", "password", "url",
+	"card_number", "expiry_date", "cvv", "card_holder", "content", "notes",
+}
+
+// WriteCSV writes the items of v as plaintext CSV, one row per item. The
+// files of binary data are not written, only their names; the content of
+// an OTP secret is its otpauth URI, that of a custom item its fields as
+// "name: value" lines.
+func WriteCSV(w io.Writer, v *Vault) error {
+	paths := FolderPaths(v.Folders)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	row := func(kind string, meta models.ItemMeta, values map[string]string) error {
+		record := make([]string, len(csvHeader))
+		for i, column := range csvHeader {
+			record[i] = values[column]
+		}
+		record[0] = kind
+		if meta.FolderID != nil {
+			record[1] = paths[*meta.FolderID]
+		}
+		if record[2] == "" {
+			record[2] = meta.DisplayName
+		}
+		record[3] = strconv.FormatBool(meta.Favorite)
+		record[4] = strings.Join(meta.Tags, ",")
+		return cw.Write(record)
+	}
+
+	for _, lp := range v.LoginPasswords {
+		err := row(
+			"login-password", lp.ItemMeta, map[string]string{
+				"username": lp.Login, "password": lp.Password, "url": lp.URL, "notes": lp.Metadata,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, cc := range v.CreditCards {
+		err := row(
+			"card", cc.ItemMeta, map[string]string{
+				"card_number": cc.CardNumber, "expiry_date": cc.ExpiryDate, "cvv": cc.CVV,
+				"card_holder": cc.CardHolder, "notes": cc.Metadata,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, td := range v.TextData {
+		if err := row("text-data", td.ItemMeta, map[string]string{"content": td.Content, "notes": td.Metadata}); err != nil {
+			return err
+		}
+	}
+	for _, bd := range v.BinaryData {
+		values := map[string]string{"name": firstNonEmpty(bd.DisplayName, bd.FileName), "content": bd.FileName, "notes": bd.Metadata}
+		if err := row("binary-data", bd.ItemMeta, values); err != nil {
+			return err
+		}
+	}
+	for _, s := range v.OTPSecrets {
+		err := row(
+			"otp", s.ItemMeta, map[string]string{
+				"username": s.Account, "content": otpURI(s), "notes": s.Metadata,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, k := range v.SSHKeys {
+		err := row(
+			"ssh-key", k.ItemMeta, map[string]string{
+				"content": k.PrivateKey, "notes": joinNonEmpty(k.Comment, k.Metadata),
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, it := range v.Items {
+		lines := make([]string, 0, len(it.Fields))
+		for _, f := range it.Fields {
+			lines = append(lines, f.Name+": "+f.Value)
+		}
+		name := it.DisplayName
+		if name == "" {
+			name = it.Name
+		}
+		err := row(
+			"item:"+it.Template, it.ItemMeta, map[string]string{
+				"name": name, "content": strings.Join(lines, "\n"), "notes": it.Metadata,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// otpURI returns the otpauth URI of an OTP secret.
+func otpURI(s *models.OTPSecret) string {
+	label := s.Account
+	if s.Issuer != "" {
+		label = s.Issuer + ":" + s.Account
+	}
+	query := url.Values{}
+	query.Set("secret", s.Secret)
+	if s.Issuer != "" {
+		query.Set("issuer", s.Issuer)
+	}
+	if s.Algorithm != "" {
+		query.Set("algorithm", s.Algorithm)
+	}
+	if s.Digits != 0 {
+		query.Set("digits", strconv.Itoa(s.Digits))
+	}
+	if s.Period != 0 {
+		query.Set("period", strconv.Itoa(s.Period))
+	}
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func joinNonEmpty(values ...string) string {
+	var kept []string
+	for _, v := range values {
+		if v != "" {
+			kept = append(kept, v)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// FolderPaths maps folder IDs to their slash-separated paths.
+func FolderPaths(folders []*models.Folder) map[uint]string {
+	byID := make(map[uint]*models.Folder, len(folders))
+	for _, f := range folders {
+		byID[f.ID] = f
+	}
+	paths := make(map[uint]string, len(folders))
+	var path func(f *models.Folder, depth int) string
+	path = func(f *models.Folder, depth int) string {
+		if p, ok := paths[f.ID]; ok {
+			return p
+		}
+		p := f.Name
+		if f.ParentID != nil && depth < len(folders) {
+			if parent, ok := byID[*f.ParentID]; ok {
+				p = path(parent, depth+1) + "/" + f.Name
+			}
+		}
+		paths[f.ID] = p
+		return p
+	}
+	for _, f := range folders {
+		path(f, 0)
+	}
+	return paths
+}
@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/otp"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	v := testVault(nil)
+	v.BinaryData = []*models.BinaryData{{FileName: "a.txt", Content: []byte("inline")}}
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, v))
+
+	var read Vault
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &read))
+	assert.Equal(t, Version, read.Version)
+	assert.Equal(t, "s3cret", read.LoginPasswords[0].Password)
+	assert.Equal(t, []byte("inline"), read.BinaryData[0].Content)
+}
+
+func TestWriteCSV(t *testing.T) {
+	parent, child := uint(1), uint(2)
+	v := &Vault{
+		Folders: []*models.Folder{
+			{Model: gorm.Model{ID: child}, Name: "Mail", ParentID: &parent},
+			{Model: gorm.Model{ID: parent}, Name: "Work"},
+		},
+		LoginPasswords: []*models.LoginPassword{
+			{
+				ItemMeta: models.ItemMeta{DisplayName: "Mail", FolderID: &child, Favorite: true, Tags: []string{"a", "b"}},
+				Login:    "jane",
+				Password: "pw",
+				URL:      "https://mail.example.com",
+			},
+		},
+		CreditCards: []*models.CreditCard{{CardNumber: "4111111111111111", ExpiryDate: "12/99", CVV: "123"}},
+		OTPSecrets: []*models.OTPSecret{
+			{Issuer: "Example", Account: "jane", Secret: "JBSWY3DPEHPK3PXP", Algorithm: "SHA1", Digits: 6, Period: 30},
+		},
+		Items: []*models.Item{
+			{Template: "wifi", Name: "Home", Fields: []models.ItemField{{Name: "ssid", Value: "home"}, {Name: "psk", Value: "pw"}}},
+		},
+		BinaryData: []*models.BinaryData{{FileName: "scan.pdf"}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, v))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+	assert.Equal(t, csvHeader, records[0])
+	row := func(i int) map[string]string {
+		m := make(map[string]string)
+		for j, column := range csvHeader {
+			m[column] = records[i][j]
+		}
+		return m
+	}
+
+	login := row(1)
+	assert.Equal(t, "login-password", login["type"])
+	assert.Equal(t, "Work/Mail", login["folder"])
+	assert.Equal(t, "true", login["favorite"])
+	assert.Equal(t, "a,b", login["tags"])
+	assert.Equal(t, "pw", login["password"])
+	assert.Equal(t, "4111111111111111", row(2)["card_number"])
+	assert.Equal(t, "scan.pdf", row(3)["content"])
+
+	secret, err := otp.ParseURI(row(4)["content"])
+	require.NoError(t, err)
+	assert.Equal(t, "Example", secret.Issuer)
+	assert.Equal(t, "jane", secret.Account)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", secret.Secret)
+
+	item := row(5)
+	assert.Equal(t, "item:wifi", item["type"])
+	assert.Equal(t, "Home", item["name"])
+	assert.Equal(t, "ssid: home\npsk: pw", item["content"])
+}
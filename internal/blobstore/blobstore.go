@@ -0,0 +1,89 @@
+// Package blobstore keeps large contents, such as binary data, outside the
+// database. Blobs are addressed by opaque keys from NewKey and are written
+// once; changing a content means storing a new blob and deleting the old.
+package blobstore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	BackendFS     = "fs"
+	BackendS3     = "s3"
+	BackendMemory = "memory"
+
+	// DefaultDir is the directory of the filesystem backend when none is
+	// configured.
+	DefaultDir = "blobs"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store is a blob storage backend.
+type Store interface {
+	// Put stores data under key, replacing any blob stored there.
+	Put(key string, data []byte) error
+	// Get returns the blob stored under key or ErrNotFound.
+	Get(key string) ([]byte, error)
+	// Delete removes the blob stored under key; deleting a missing blob is
+	// not an error.
+	Delete(key string) error
+}
+
+// Config selects and configures a backend.
+type Config struct {
+	// Backend is BackendFS, BackendS3 or BackendMemory; empty means
+	// BackendFS.
+	Backend string
+	// Dir is the root directory of the filesystem backend.
+	Dir string
+	S3  S3Config
+}
+
+// New opens the backend described by conf.
+func New(conf Config) (Store, error) {
+	switch conf.Backend {
+	case "", BackendFS:
+		dir := conf.Dir
+		if dir == "" {
+			dir = DefaultDir
+		}
+		return NewFS(dir)
+	case BackendS3:
+		return NewS3(conf.S3)
+	case BackendMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store backend %q", conf.Backend)
+	}
+}
+
+// NewKey returns a random key for a new blob.
+func NewKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// validKey reports whether key is safe as a file name and URL segment.
+func validKey(key string) bool {
+	if len(key) < 3 {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
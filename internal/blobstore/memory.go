@@ -0,0 +1,50 @@
+package blobstore
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Memory keeps blobs in memory. It is meant for tests.
+type Memory struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{blobs: map[string][]byte{}}
+}
+
+func (m *Memory) Put(key string, data []byte) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = bytes.Clone(data)
+	return nil
+}
+
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(data), nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+// Len returns the number of stored blobs.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.blobs)
+}
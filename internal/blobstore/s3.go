@@ -0,0 +1,179 @@
+package blobstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat = "20060102T150405Z"
+	signAlgorithm = "AWS4-HMAC-SHA256"
+)
+
+// S3Config describes a bucket of an S3-compatible service such as AWS S3
+// or MinIO. Objects are addressed path-style, as Endpoint/Bucket/key.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3 keeps blobs as objects of an S3-compatible bucket. Requests are
+// signed with AWS Signature Version 4.
+type S3 struct {
+	conf     S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3(conf S3Config) (*S3, error) {
+	if conf.Endpoint == "" || conf.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	endpoint, err := url.Parse(conf.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if conf.Region == "" {
+		conf.Region = "us-east-1"
+	}
+	return &S3{
+		conf:     conf,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3) Put(key string, data []byte) error {
+	_, err := s.do(http.MethodPut, key, data)
+	return err
+}
+
+func (s *S3) Get(key string) ([]byte, error) {
+	return s.do(http.MethodGet, key, nil)
+}
+
+func (s *S3) Delete(key string) error {
+	_, err := s.do(http.MethodDelete, key, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *S3) do(method, key string, body []byte) ([]byte, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.conf.Bucket + "/" + key
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return data, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf(
+			"s3 %s %s failed, status code: %d, response: %s",
+			method, key, resp.StatusCode, data,
+		)
+	}
+}
+
+// sign adds the AWS Signature Version 4 headers to req.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join(
+		[]string{
+			req.Method,
+			req.URL.EscapedPath(),
+			canonicalQuery(req.URL.Query()),
+			canonicalHeaders.String(),
+			signedHeaders,
+			payloadHash,
+		}, "\n",
+	)
+	scope := date + "/" + s.conf.Region + "/s3/aws4_request"
+	stringToSign := strings.Join(
+		[]string{signAlgorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n",
+	)
+
+	key := hmacSHA256([]byte("AWS4"+s.conf.SecretKey), date)
+	key = hmacSHA256(key, s.conf.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set(
+		"Authorization", fmt.Sprintf(
+			"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+			signAlgorithm, s.conf.AccessKey, scope, signedHeaders, signature,
+		),
+	)
+}
+
+func canonicalQuery(query url.Values) string {
+	// url.Values.Encode sorts by key; S3 wants spaces as %20.
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
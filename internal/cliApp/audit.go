@@ -0,0 +1,155 @@
+package cliApp
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/audit"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+	"time"
+)
+
+func getAuditFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "breach-db",
+			Aliases:  []string{"b"},
+			Usage:    "Sorted HIBP SHA-1 file or prebuilt bloom filter",
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:  "max-age-days",
+			Usage: "Flag passwords not changed for more than N days (0 disables)",
+			Value: 365,
+		},
+		&cli.IntFlag{
+			Name:  "expiring-days",
+			Usage: "Flag cards that expire within N days",
+			Value: 30,
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: table or json",
+			Value:   "table",
+		},
+		&cli.StringFlag{
+			Name:     "token",
+			Aliases:  []string{"t"},
+			Usage:    "Token for Authorization",
+			Required: true,
+		},
+	}
+}
+
+func Audit(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		opts := audit.DefaultOptions()
+		opts.MaxPasswordAge = time.Duration(c.Int("max-age-days")) * 24 * time.Hour
+		opts.ExpiringWithin = time.Duration(c.Int("expiring-days")) * 24 * time.Hour
+
+		if path := c.String("breach-db"); path != "" {
+			checker, err := security.OpenBreachChecker(path)
+			if err != nil {
+				log.Fatalf("Error opening breach database: %v", err)
+			}
+			opts.Breach = checker
+		}
+
+		token := c.String("token")
+		var lpData []*models.LoginPassword
+		if err := fetchList(baseURL, "get-login-password", token, &lpData); err != nil {
+			log.Fatalf("Error getting login-password data: %v", err)
+		}
+		var cards []*models.CreditCard
+		if err := fetchList(baseURL, "get-card", token, &cards); err != nil {
+			log.Fatalf("Error getting credit cards: %v", err)
+		}
+
+		report, err := audit.Run(lpData, cards, opts)
+		if err != nil {
+			log.Fatalf("Error auditing vault: %v", err)
+		}
+
+		switch c.String("format") {
+		case "json":
+			err = report.WriteJSON(os.Stdout)
+		case "table":
+			err = report.WriteTable(os.Stdout)
+		default:
+			log.Fatalf("Unknown output format: %s", c.String("format"))
+		}
+		if err != nil {
+			log.Fatalf("Error writing report: %v", err)
+		}
+
+		if report.HasFindings() {
+			return cli.Exit("", 1)
+		}
+		return nil
+	}
+}
+
+func AuditCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:   "audit",
+		Usage:  "Report compromised, reused, weak and old passwords and expiring cards",
+		Flags:  getAuditFlags(),
+		Action: Audit(baseURL),
+	}
+}
+
+func getBreachFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "in",
+			Aliases:  []string{"i"},
+			Usage:    "Sorted HIBP SHA-1 file",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "out",
+			Aliases:  []string{"o"},
+			Usage:    "Output bloom filter file",
+			Required: true,
+		},
+		&cli.Float64Flag{
+			Name:  "fp-rate",
+			Usage: "False positive rate, between 0 and 1",
+			Value: 0.001,
+		},
+	}
+}
+
+func BuildBreachFilter() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		bloom, err := security.BuildBloomFilter(c.String("in"), c.Float64("fp-rate"))
+		if err != nil {
+			log.Fatalf("Error building bloom filter: %v", err)
+		}
+
+		f, err := os.Create(c.String("out"))
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer f.Close()
+
+		n, err := bloom.WriteTo(f)
+		if err != nil {
+			log.Fatalf("Error writing bloom filter: %v", err)
+		}
+		fmt.Printf("Bloom filter written to %s (%d bytes)\n", c.String("out"), n)
+		return nil
+	}
+}
+
+func BreachFilterCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "breach-filter",
+		Usage:  "Build a bloom filter from a HIBP SHA-1 file",
+		Flags:  getBreachFilterFlags(),
+		Action: BuildBreachFilter(),
+	}
+}
@@ -0,0 +1,545 @@
+package cliApp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/backup"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/keepass"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formats of export.
+const (
+	exportEncrypted = "encrypted"
+	exportJSON      = "json"
+	exportCSV       = "csv"
+	exportKDBX      = "kdbx"
+)
+
+// ExportVault writes every item of the vault, with the files of binary
+// data, to --out. The default format is a passphrase-encrypted backup;
+// a KeePass KDBX 4 database is encrypted with the passphrase as well, and
+// plaintext JSON and CSV need --yes.
+func ExportVault(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		format, out, passphrase := c.String("format"), c.String("out"), c.String("passphrase")
+		switch format {
+		case exportEncrypted, exportKDBX:
+			if passphrase == "" {
+				log.Fatalf("An encrypted export needs --passphrase")
+			}
+			strength := security.EstimateStrength(passphrase)
+			if strength.Score < security.MinAccountPasswordScore {
+				log.Fatalf("Passphrase is too weak: %s", strength)
+			}
+		case exportJSON, exportCSV:
+			if !c.Bool("yes") {
+				log.Fatalf(
+					"A %s export holds every secret unencrypted, anyone who reads the file reads them; "+
+						"confirm with --yes or leave out --format for an encrypted backup", format,
+				)
+			}
+		default:
+			log.Fatalf(
+				"Unknown format %s, expected %s, %s, %s or %s", format, exportEncrypted, exportKDBX, exportJSON,
+				exportCSV,
+			)
+		}
+		params := keepass.DefaultParams
+		params.Cipher = c.String("cipher")
+		if format == exportKDBX && params.Cipher != keepass.CipherChaCha20 && params.Cipher != keepass.CipherAES {
+			log.Fatalf("Unknown cipher %s, expected %s or %s", params.Cipher, keepass.CipherChaCha20, keepass.CipherAES)
+		}
+		token := c.String("token")
+
+		v, err := fetchBackup(baseURL, token)
+		if err != nil {
+			log.Fatalf("Error getting vault: %v", err)
+		}
+		dir, err := os.MkdirTemp("", "auth-keeper-export")
+		if err != nil {
+			log.Fatalf("Error creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		part := out + ".part"
+		file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			log.Fatalf("Error creating export: %v", err)
+		}
+		defer os.Remove(part)
+		defer file.Close()
+
+		switch format {
+		case exportEncrypted:
+			err = writeBackup(baseURL, token, file, passphrase, v, dir)
+		case exportJSON, exportKDBX:
+			for _, bd := range v.BinaryData {
+				if bd.Content, err = downloadContent(baseURL, token, bd, dir); err != nil {
+					break
+				}
+			}
+			if err == nil && format == exportJSON {
+				err = backup.WriteJSON(file, v)
+			} else if err == nil {
+				err = backup.WriteKeePass(file, v, passphrase, params)
+			}
+		case exportCSV:
+			err = backup.WriteCSV(file, v)
+		}
+		if err == nil {
+			err = file.Close()
+		}
+		if err == nil {
+			err = os.Rename(part, out)
+		}
+		if err != nil {
+			log.Fatalf("Error writing export: %v", err)
+		}
+		fmt.Printf("Exported %d items and %d folders to %s\n", v.Len(), len(v.Folders), out)
+		if format == exportCSV && len(v.BinaryData) > 0 {
+			fmt.Printf("The files of %d binary data are not in a CSV export\n", len(v.BinaryData))
+		}
+		return nil
+	}
+}
+
+// fetchBackup gets the vault, folders and item templates without binary contents.
+func fetchBackup(baseURL, token string) (*backup.Vault, error) {
+	v := new(backup.Vault)
+	lists := []struct {
+		endpoint string
+		out      interface{}
+	}{
+		{"get-folder", &v.Folders},
+		{"get-login-password", &v.LoginPasswords},
+		{"get-card", &v.CreditCards},
+		{"get-text-data", &v.TextData},
+		{"get-binary-data", &v.BinaryData},
+		{"get-otp", &v.OTPSecrets},
+		{"get-ssh-key", &v.SSHKeys},
+		{"get-item", &v.Items},
+		{"get-item-template", &v.ItemTemplates},
+	}
+	for _, list := range lists {
+		if err := fetchList(baseURL, list.endpoint, token, list.out); err != nil {
+			return nil, err
+		}
+	}
+
+	complete := v.BinaryData[:0]
+	for _, bd := range v.BinaryData {
+		if !bd.Chunked || bd.Complete {
+			backup.CleanBinaryData(bd)
+			complete = append(complete, bd)
+		}
+	}
+	v.BinaryData = complete
+	// Built-in templates have no ID and exist on every server.
+	own := v.ItemTemplates[:0]
+	for _, t := range v.ItemTemplates {
+		if t.ID != 0 {
+			own = append(own, t)
+		}
+	}
+	v.ItemTemplates = own
+	return v, nil
+}
+
+// writeBackup writes v as an encrypted backup to w.
+func writeBackup(baseURL, token string, w io.Writer, passphrase string, v *backup.Vault, dir string) error {
+	bw, err := backup.NewWriter(w, passphrase, backup.DefaultParams, v)
+	if err != nil {
+		return err
+	}
+	for _, bd := range v.BinaryData {
+		path := filepath.Join(dir, strconv.FormatUint(uint64(bd.ID), 10))
+		if _, err := downloadBinaryData(baseURL, token, bd.ID, path); err != nil {
+			return fmt.Errorf("failed to download binary data %d: %w", bd.ID, err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = bw.WriteContent(bd.ID, file)
+		file.Close()
+		os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Close()
+}
+
+// downloadContent downloads the file of a binary data through dir.
+func downloadContent(baseURL, token string, bd *models.BinaryData, dir string) ([]byte, error) {
+	path := filepath.Join(dir, strconv.FormatUint(uint64(bd.ID), 10))
+	if _, err := downloadBinaryData(baseURL, token, bd.ID, path); err != nil {
+		return nil, fmt.Errorf("failed to download binary data %d: %w", bd.ID, err)
+	}
+	defer os.Remove(path)
+	return os.ReadFile(path)
+}
+
+// RestoreVault adds the items of an encrypted backup to the vault. Items
+// the vault has already are skipped; --on-conflict decides what happens to
+// items named like an existing item of the same type. Folders are matched
+// by path and created as needed, and OTP secrets are linked to the restored
+// logins.
+func RestoreVault(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		path := c.Args().Get(0)
+		if path == "" {
+			log.Fatalf("Usage: restore --passphrase <passphrase> <file>")
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Error reading backup: %v", err)
+		}
+		defer file.Close()
+		r, err := backup.NewReader(file, c.String("passphrase"))
+		if err != nil {
+			log.Fatalf("Error reading backup: %v", err)
+		}
+		v := r.Vault()
+		token := c.String("token")
+
+		existing, err := fetchBackup(baseURL, token)
+		if err != nil {
+			log.Fatalf("Error getting vault: %v", err)
+		}
+		plan, err := backup.Plan(v, existing, c.String("on-conflict"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf(
+			"Backup of %s: %d items, %d already in the vault, %d named like an existing item (%s)\n",
+			v.CreatedAt.Local().Format("2006-01-02 15:04"), v.Len(), plan.Same, plan.Conflicts,
+			c.String("on-conflict"),
+		)
+		fmt.Printf("To add: %d items, to replace: %d items\n", plan.Add.Len(), plan.Replace.Len())
+		if c.Bool("dry-run") || plan.Add.Len()+plan.Replace.Len()+len(plan.Add.ItemTemplates) == 0 {
+			return nil
+		}
+
+		dir, err := os.MkdirTemp("", "auth-keeper-restore")
+		if err != nil {
+			log.Fatalf("Error creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		if err := extractContents(r, dir); err != nil {
+			log.Fatalf("Error reading backup: %v", err)
+		}
+		if err := restorePlan(baseURL, token, plan, dir); err != nil {
+			log.Fatalf("Error restoring: %v\nItems already restored are skipped when run again", err)
+		}
+		fmt.Printf("Restored %d items, replaced %d items\n", plan.Add.Len(), plan.Replace.Len())
+		if plan.Replace.Len() > 0 {
+			syncSharesAfterChange(baseURL, token, nil)
+		}
+		return nil
+	}
+}
+
+// extractContents writes the binary data files of a backup to dir.
+func extractContents(r *backup.Reader, dir string) error {
+	for {
+		id, content, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(
+			filepath.Join(dir, strconv.FormatUint(uint64(id), 10)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func restorePlan(baseURL, token string, plan *backup.Restore, dir string) error {
+	if err := restoreFolders(baseURL, token, plan); err != nil {
+		return fmt.Errorf("failed to create folders: %w", err)
+	}
+	for _, t := range plan.Add.ItemTemplates {
+		backup.ClearModel(t)
+		if _, err := postEncrypted(baseURL, "add-item-template", token, t); err != nil {
+			return err
+		}
+	}
+
+	loginIDs, err := restoreItems(
+		baseURL, token, "login-password", plan.Add.LoginPasswords, plan.Replace.LoginPasswords,
+	)
+	if err != nil {
+		return err
+	}
+	for old, id := range plan.LoginIDs {
+		loginIDs[old] = id
+	}
+	for _, s := range append(plan.Add.OTPSecrets, plan.Replace.OTPSecrets...) {
+		if s.LoginPasswordID == nil {
+			continue
+		}
+		if id, ok := loginIDs[*s.LoginPasswordID]; ok {
+			s.LoginPasswordID = &id
+		} else {
+			s.LoginPasswordID = nil
+		}
+	}
+
+	if _, err := restoreItems(baseURL, token, "card", plan.Add.CreditCards, plan.Replace.CreditCards); err != nil {
+		return err
+	}
+	if _, err := restoreItems(baseURL, token, "text-data", plan.Add.TextData, plan.Replace.TextData); err != nil {
+		return err
+	}
+	if _, err := restoreItems(baseURL, token, "otp", plan.Add.OTPSecrets, plan.Replace.OTPSecrets); err != nil {
+		return err
+	}
+	if _, err := restoreItems(baseURL, token, "ssh-key", plan.Add.SSHKeys, plan.Replace.SSHKeys); err != nil {
+		return err
+	}
+	for _, it := range plan.Add.Items {
+		backup.ClearModel(it)
+		indexItem(it)
+		if _, err := postEncrypted(baseURL, "add-item", token, it); err != nil {
+			return err
+		}
+	}
+	for _, it := range plan.Replace.Items {
+		if err := replaceItem(baseURL, token, "item", it.ID, it); err != nil {
+			return err
+		}
+	}
+	return restoreBinaryData(baseURL, token, plan, dir)
+}
+
+// restoreFolders creates the folders of the items to restore.
+func restoreFolders(baseURL, token string, plan *backup.Restore) error {
+	metas := restoredMetas(plan.Add)
+	metas = append(metas, restoredMetas(plan.Replace)...)
+	paths := backup.FolderPaths(plan.Add.Folders)
+	var needed []string
+	for _, meta := range metas {
+		if meta.FolderID != nil && paths[*meta.FolderID] != "" {
+			needed = append(needed, paths[*meta.FolderID])
+		}
+	}
+	var ids map[string]uint
+	if len(needed) > 0 {
+		var err error
+		if ids, err = ensureFolders(baseURL, token, needed); err != nil {
+			return err
+		}
+	}
+	for _, meta := range metas {
+		if meta.FolderID == nil {
+			continue
+		}
+		if id, ok := ids[strings.ToLower(paths[*meta.FolderID])]; ok {
+			meta.FolderID = &id
+		} else {
+			meta.FolderID = nil
+		}
+	}
+	return nil
+}
+
+func restoredMetas(v *backup.Vault) []*models.ItemMeta {
+	var metas []*models.ItemMeta
+	for _, lp := range v.LoginPasswords {
+		metas = append(metas, lp.Meta())
+	}
+	for _, cc := range v.CreditCards {
+		metas = append(metas, cc.Meta())
+	}
+	for _, td := range v.TextData {
+		metas = append(metas, td.Meta())
+	}
+	for _, bd := range v.BinaryData {
+		metas = append(metas, bd.Meta())
+	}
+	for _, s := range v.OTPSecrets {
+		metas = append(metas, s.Meta())
+	}
+	for _, k := range v.SSHKeys {
+		metas = append(metas, k.Meta())
+	}
+	for _, it := range v.Items {
+		metas = append(metas, it.Meta())
+	}
+	return metas
+}
+
+// restoreItems adds and replaces the items of one type.
+func restoreItems[T any, PT interface {
+	*T
+	Meta() *models.ItemMeta
+}](baseURL, token, route string, add, replace []*T) (map[uint]uint, error) {
+	old := make([]uint, len(add))
+	for i, item := range add {
+		old[i] = backup.ClearModel(item)
+	}
+	ids, err := importBatches[T, PT](baseURL, token, route, add, nil)
+	if err != nil {
+		return nil, err
+	}
+	added := make(map[uint]uint, len(ids))
+	for i, id := range ids {
+		added[old[i]] = id
+	}
+
+	for _, item := range replace {
+		id := backup.ClearModel(item)
+		indexItem(PT(item))
+		if err := replaceItem(baseURL, token, route, id, item); err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+func replaceItem(baseURL, token, route string, id uint, item interface{}) error {
+	_, err := sendEncrypted(
+		baseURL, "PUT", fmt.Sprintf("update-%s/%d", route, id), token, item, http.StatusOK,
+	)
+	return err
+}
+
+// restoreBinaryData uploads the files of the binary data to restore.
+func restoreBinaryData(baseURL, token string, plan *backup.Restore, dir string) error {
+	if len(plan.Add.BinaryData)+len(plan.Replace.BinaryData) == 0 {
+		return nil
+	}
+	personalKey, err := os.ReadFile(personalKeyFile)
+	if err != nil {
+		return fmt.Errorf("error reading personal key: %w", err)
+	}
+	for _, bd := range plan.Add.BinaryData {
+		if err := restoreFile(baseURL, token, personalKey, bd, dir, backup.ClearModel(bd)); err != nil {
+			return err
+		}
+	}
+	for _, bd := range plan.Replace.BinaryData {
+		replaced := backup.ClearModel(bd)
+		if err := restoreFile(baseURL, token, personalKey, bd, dir, plan.BinarySources[replaced]); err != nil {
+			return err
+		}
+		_, err := sendRequest(
+			baseURL, "DELETE", fmt.Sprintf("delete-binary-data/%d", replaced), token, nil, http.StatusOK,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreFile uploads the extracted content of binary data id.
+func restoreFile(baseURL, token string, personalKey []byte, bd *models.BinaryData, dir string, id uint) error {
+	file, err := os.Open(filepath.Join(dir, strconv.FormatUint(uint64(id), 10)))
+	if err != nil {
+		return fmt.Errorf("content of binary data %s not in the backup: %w", bd.FileName, err)
+	}
+	defer file.Close()
+	described, err := describeFile(file, personalKey)
+	if err != nil {
+		return err
+	}
+	if bd.SHA256 != "" && described.SHA256 != bd.SHA256 {
+		return fmt.Errorf("content of binary data %s does not match its sha256", bd.FileName)
+	}
+	described.ItemMeta, described.Metadata = bd.ItemMeta, bd.Metadata
+	described.FileName, described.MIMEType = bd.FileName, bd.MIMEType
+	indexItem(described)
+
+	status := uploadStatus{}
+	if status.Item, err = startUpload(baseURL, token, described); err != nil {
+		return err
+	}
+	if status.Item.Complete {
+		return nil
+	}
+	return uploadChunks(baseURL, token, personalKey, file, &status)
+}
+
+func ExportCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:  "export",
+		Usage: "Write every item of the vault to a passphrase-encrypted backup",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "out",
+				Aliases:  []string{"o"},
+				Usage:    "File to write the export to",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "passphrase",
+				Usage: "Passphrase to encrypt the backup or KeePass database with, not the account password",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Value: exportEncrypted,
+				Usage: "encrypted, kdbx for a KeePass database, or json or csv for a plaintext export",
+			},
+			&cli.StringFlag{
+				Name:  "cipher",
+				Value: keepass.CipherChaCha20,
+				Usage: "Cipher of a kdbx export: " + keepass.CipherChaCha20 + " or " + keepass.CipherAES,
+			},
+			&cli.BoolFlag{
+				Name:  "yes",
+				Usage: "Confirm writing a plaintext export that anyone who reads the file can read",
+			},
+			getTokenFlag(),
+		},
+		Action: ExportVault(baseURL),
+	}
+}
+
+func RestoreCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "restore",
+		Usage:     "Add the items of an encrypted backup to the vault",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "passphrase",
+				Usage:    "Passphrase the backup was encrypted with",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "on-conflict",
+				Value: backup.ConflictSkip,
+				Usage: "What to do with items named like an existing item: " + strings.Join(backup.Conflicts, ", "),
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print what would be restored without restoring it",
+			},
+			getTokenFlag(),
+		},
+		Action: RestoreVault(baseURL),
+	}
+}
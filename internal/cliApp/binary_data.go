@@ -1,16 +1,23 @@
 package cliApp
 
 import (
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
-	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/elina-chertova/auth-keeper.git/internal/sender"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
 	"github.com/urfave/cli/v2"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 )
 
 func getAddBinaryDataFlags() []cli.Flag {
@@ -33,68 +40,212 @@ func getAddBinaryDataFlags() []cli.Flag {
 			Usage:    "Token for Authorization",
 			Required: true,
 		},
+		&cli.UintFlag{
+			Name:  "resume",
+			Usage: "ID of an interrupted upload of the same file to continue",
+		},
 	}
 }
 
+// AddBinaryData streams a file to the server in chunks packed and sealed
+// with package stream, along with its name, MIME type, SHA-256 and content
+// key; --resume continues an interrupted upload. A file already stored is
+// not sent again.
 func AddBinaryData(baseURL string) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		filePath := c.String("file_path")
-		content, err := os.ReadFile(filePath)
+		file, err := os.Open(c.String("file_path"))
 		if err != nil {
 			log.Fatalf("Error reading file: %v", err)
 		}
-		binaryData := models.BinaryData{
-			Content:  content,
-			Metadata: c.String("metadata"),
-		}
-		token := c.String("token")
-		client := sender.NewClient(baseURL)
-
-		jsonData, err := json.Marshal(binaryData)
-		if err != nil {
-			log.Fatalf("Error marshalling data: %v", err)
-		}
-
-		personalKey, err := os.ReadFile("pkey.txt")
+		defer file.Close()
+		personalKey, err := os.ReadFile(personalKeyFile)
 		if err != nil {
 			log.Fatalf("Error reading personal key: %v", err)
 		}
-
-		encryptedData, err := security.EncryptData(jsonData, personalKey)
+		described, err := describeFile(file, personalKey)
 		if err != nil {
-			log.Fatalf("Error encrypting data: %v", err)
+			log.Fatalf("Error reading file: %v", err)
 		}
+		token := c.String("token")
 
-		encodedData := base64.StdEncoding.EncodeToString(encryptedData)
-		fmt.Printf("Encrypted Data: %s\n", encodedData)
-
-		resp, err := client.SendRequest(
-			"POST",
-			"add-binary-data",
-			map[string]string{"data": encodedData},
-			token,
-		)
-		if err != nil {
-			log.Fatalf("Error adding binary data: %v", err)
+		var status uploadStatus
+		if id := c.Uint("resume"); id != 0 {
+			err := fetchList(baseURL, fmt.Sprintf("upload-binary-data/%d", id), token, &status)
+			if err != nil {
+				log.Fatalf("Error getting upload status: %v", err)
+			}
+			if status.Item.Size != described.Size || status.Item.SHA256 != described.SHA256 {
+				log.Fatalf("File does not match upload %d", id)
+			}
+		} else {
+			described.Metadata = c.String("metadata")
+			described.ItemMeta = metaFromFlags(c, baseURL)
+			status.Item, err = startUpload(baseURL, token, described)
+			if err != nil {
+				log.Fatalf("Error starting upload: %v", err)
+			}
+			if status.Item.Complete {
+				fmt.Printf("Binary Data already stored, added without upload, id: %d\n", status.Item.ID)
+				return nil
+			}
 		}
 
-		if resp.StatusCode != http.StatusCreated {
+		if err := uploadChunks(baseURL, token, personalKey, file, &status); err != nil {
 			log.Fatalf(
-				"Failed to add binary data, status code: %d, response: %s",
-				resp.StatusCode,
-				resp.String(),
+				"Error uploading binary data: %v\nResume with --resume %d",
+				err,
+				status.Item.ID,
 			)
 		}
-		fmt.Printf("Binary Data added successfully: %s\n", resp.String())
+		fmt.Printf("Binary Data added successfully, id: %d\n", status.Item.ID)
 		return nil
 	}
 }
 
+// uploadStatus is the answer of GET upload-binary-data/:id.
+type uploadStatus struct {
+	Item     *models.BinaryData `json:"item"`
+	Received []int              `json:"received"`
+}
+
+// describeFile reads file once for its size, hashes and MIME type.
+func describeFile(file *os.File, personalKey []byte) (*models.BinaryData, error) {
+	contentKey, err := stream.NewContentKey(personalKey)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	hash := sha256.New()
+	w := io.MultiWriter(hash, contentKey)
+	w.Write(head[:n])
+	rest, err := io.Copy(w, file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &models.BinaryData{
+		FileName:   filepath.Base(file.Name()),
+		MIMEType:   http.DetectContentType(head[:n]),
+		Size:       int64(n) + rest,
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		ContentKey: hex.EncodeToString(contentKey.Sum(nil)),
+	}, nil
+}
+
+// startUpload posts the manifest of a new chunked upload.
+func startUpload(baseURL, token string, bd *models.BinaryData) (*models.BinaryData, error) {
+	header, err := stream.NewHeader()
+	if err != nil {
+		return nil, err
+	}
+	bd.Chunked = true
+	bd.ChunkSize = stream.DefaultChunkSize
+	bd.Chunks = stream.Chunks(bd.Size, stream.DefaultChunkSize)
+	bd.Header = header
+	bd.Compression = stream.CompressionZstd
+	indexItem(bd)
+
+	body, err := postEncrypted(baseURL, "upload-binary-data", token, bd)
+	if err != nil {
+		return nil, err
+	}
+	var created struct {
+		ID       uint `json:"id"`
+		Complete bool `json:"complete"`
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+	bd.ID, bd.Complete = created.ID, created.Complete
+	return bd, nil
+}
+
+// uploadChunks sends the chunks the server lacks and completes the upload.
+func uploadChunks(
+	baseURL, token string,
+	personalKey []byte,
+	file io.Reader,
+	status *uploadStatus,
+) error {
+	bd := status.Item
+	c, err := stream.New(personalKey, bd.Header)
+	if err != nil {
+		return err
+	}
+	received := make(map[int]bool, len(status.Received))
+	for _, n := range status.Received {
+		received[n] = true
+	}
+
+	client := sender.NewClient(baseURL)
+	hash := sha256.New()
+	chunk := make([]byte, bd.ChunkSize)
+	bar := newProgress("Uploading", bd.Size)
+	defer bar.Finish()
+	for number := 0; number < bd.Chunks; number++ {
+		n, err := io.ReadFull(file, chunk)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return err
+		}
+		sealed := c.Seal(number, packChunk(bd, chunk[:n]), number == bd.Chunks-1)
+		hash.Write(sealed)
+
+		if !received[number] {
+			endpoint := fmt.Sprintf("upload-binary-data/%d/%d", bd.ID, number)
+			resp, err := client.SendBytes("PUT", endpoint, sealed, token)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf(
+					"failed to upload chunk %d, status code: %d, response: %s",
+					number,
+					resp.StatusCode,
+					resp.String(),
+				)
+			}
+		}
+		bar.Add(int64(n))
+	}
+
+	_, err = sendRequest(
+		baseURL,
+		"POST",
+		fmt.Sprintf("upload-binary-data/%d/complete", bd.ID),
+		token,
+		map[string]string{"sha256": hex.EncodeToString(hash.Sum(nil))},
+		http.StatusOK,
+	)
+	return err
+}
+
+// packChunk packs a chunk of bd for sealing if bd is compressed.
+func packChunk(bd *models.BinaryData, chunk []byte) []byte {
+	if bd.Compression == "" {
+		return chunk
+	}
+	return stream.Pack(chunk)
+}
+
+// unpackChunk undoes packChunk on an opened chunk.
+func unpackChunk(bd *models.BinaryData, opened []byte) ([]byte, error) {
+	if bd.Compression == "" {
+		return opened, nil
+	}
+	return stream.Unpack(opened, int64(bd.ChunkSize))
+}
+
 func AddBinaryDataCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "add-binary-data",
 		Usage:  "Add a binary data",
-		Flags:  getAddBinaryDataFlags(),
+		Flags:  append(getAddBinaryDataFlags(), getMetaFlags()...),
 		Action: AddBinaryData(baseURL),
 	}
 }
@@ -107,63 +258,194 @@ func getBinaryDataFlags() []cli.Flag {
 			Usage:    "Token for Authorization",
 			Required: true,
 		},
+		&cli.UintFlag{
+			Name:  "id",
+			Usage: "ID of the binary data to download",
+		},
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "File to download the binary data to, the original file name by default",
+		},
 	}
 }
 
+// GetBinaryData lists the file metadata of the binary data or, with --id,
+// downloads one item to --out.
 func GetBinaryData(baseURL string) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
 		token := c.String("token")
-		client := sender.NewClient(baseURL)
-		resp, err := client.SendRequest("GET", "get-binary-data", nil, token)
-		if err != nil {
+		if id := c.Uint("id"); id != 0 {
+			out, err := downloadBinaryData(baseURL, token, id, c.String("out"))
+			if err != nil {
+				log.Fatalf("Error downloading binary data: %v", err)
+			}
+			fmt.Printf("Binary data saved to %s\n", out)
+			return nil
+		}
+
+		var items []*models.BinaryData
+		endpoint := withQuery("get-binary-data", filterQuery(c, baseURL, url.Values{}))
+		if err := fetchList(baseURL, endpoint, token, &items); err != nil {
 			log.Fatalf("Error getting binary data: %v", err)
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			log.Fatalf(
-				"Failed to get binary data, status code: %d, response: %s",
-				resp.StatusCode,
-				resp.String(),
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tFILE\tTYPE\tSIZE\tTAGS")
+		for _, it := range items {
+			fmt.Fprintf(
+				tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				it.ID, it.DisplayName, it.FileName, it.MIMEType, byteSize(it.Size),
+				strings.Join(it.Tags, ","),
 			)
 		}
+		return tw.Flush()
+	}
+}
 
-		var responseData struct {
-			Body    string `json:"body"`
-			Message string `json:"message"`
+// downloadBinaryData writes the original bytes of an item and returns the path written.
+func downloadBinaryData(baseURL, token string, id uint, out string) (string, error) {
+	var bd models.BinaryData
+	if err := fetchList(baseURL, fmt.Sprintf("download-binary-data/%d", id), token, &bd); err != nil {
+		return "", err
+	}
+	if out == "" {
+		out = filepath.Base(bd.FileName)
+		if out == "." || out == ".." || out == string(filepath.Separator) {
+			out = fmt.Sprintf("binary-data-%d", id)
 		}
+	}
+	var err error
+	if bd.Chunked {
+		err = downloadChunks(baseURL, token, &bd, out)
+	} else {
+		err = writeContent(&bd, out)
+	}
+	return out, err
+}
 
-		if err := json.Unmarshal(resp.Bytes(), &responseData); err != nil {
-			log.Fatalf("Error unmarshalling response data: %v", err)
-		}
+// writeContent checks the content of an inline item and writes it to out.
+func writeContent(bd *models.BinaryData, out string) error {
+	if int64(len(bd.Content)) != bd.Size {
+		return fmt.Errorf("received %d bytes, expected %d", len(bd.Content), bd.Size)
+	}
+	sum := sha256.Sum256(bd.Content)
+	if err := checkSHA256(bd, sum[:]); err != nil {
+		return err
+	}
+	part := out + ".part"
+	if err := os.WriteFile(part, bd.Content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(part, out)
+}
 
-		encodedData := responseData.Body
-		fmt.Printf("Encoded Data: %s\n", encodedData)
+// checkSHA256 compares content with the SHA-256 recorded on upload, if any.
+func checkSHA256(bd *models.BinaryData, sum []byte) error {
+	if bd.SHA256 != "" && hex.EncodeToString(sum) != bd.SHA256 {
+		return errors.New("sha256 of the decrypted content does not match")
+	}
+	return nil
+}
 
-		personalKey, err := os.ReadFile("pkey.txt")
-		if err != nil {
-			log.Fatalf("Error reading personal key: %v", err)
+// downloadChunks writes a chunked item to out, resuming from out.part.
+func downloadChunks(baseURL, token string, bd *models.BinaryData, out string) error {
+	personalKey, err := os.ReadFile(personalKeyFile)
+	if err != nil {
+		return fmt.Errorf("error reading personal key: %w", err)
+	}
+	c, err := stream.New(personalKey, bd.Header)
+	if err != nil {
+		return err
+	}
+
+	part := out + ".part"
+	file, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	done := min(int(info.Size()/int64(bd.ChunkSize)), bd.Chunks-1)
+	if err := file.Truncate(int64(done) * int64(bd.ChunkSize)); err != nil {
+		return err
+	}
+
+	// Chunks already on disk are sealed again to check the hash of the
+	// whole content.
+	hash, plainHash := sha256.New(), sha256.New()
+	chunk := make([]byte, bd.ChunkSize)
+	for number := 0; number < done; number++ {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return err
 		}
+		hash.Write(c.Seal(number, packChunk(bd, chunk), false))
+		plainHash.Write(chunk)
+	}
 
-		decodedData, err := base64.StdEncoding.DecodeString(encodedData)
+	client := sender.NewClient(baseURL)
+	bar := newProgress("Downloading", bd.Size)
+	bar.Add(int64(done) * int64(bd.ChunkSize))
+	defer bar.Finish()
+	for number := done; number < bd.Chunks; number++ {
+		resp, err := client.SendRequest(
+			"GET",
+			fmt.Sprintf("download-binary-data/%d/%d", bd.ID, number),
+			nil,
+			token,
+		)
 		if err != nil {
-			log.Fatalf("Error decoding base64 data: %v", err)
+			return err
 		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf(
+				"failed to download chunk %d, status code: %d, response: %s",
+				number,
+				resp.StatusCode,
+				resp.String(),
+			)
+		}
+		sealed := resp.Bytes()
+		opened, err := c.Open(number, sealed, number == bd.Chunks-1)
+		if err != nil {
+			return err
+		}
+		plain, err := unpackChunk(bd, opened)
 		if err != nil {
-			log.Fatalf("Error decrypting data: %v", err)
+			return err
 		}
+		hash.Write(sealed)
+		plainHash.Write(plain)
+		if _, err := file.Write(plain); err != nil {
+			return err
+		}
+		bar.Add(int64(len(plain)))
+	}
 
-		fmt.Printf("Decrypted Data: %s\n", string(decryptedData))
-		return nil
+	if hex.EncodeToString(hash.Sum(nil)) != bd.CipherHash {
+		return errors.New("content hash does not match")
+	}
+	if err := checkSHA256(bd, plainHash.Sum(nil)); err != nil {
+		return err
+	}
+	if info, err := file.Stat(); err != nil {
+		return err
+	} else if info.Size() != bd.Size {
+		return fmt.Errorf("downloaded %d bytes, expected %d", info.Size(), bd.Size)
+	}
+	if err := file.Close(); err != nil {
+		return err
 	}
+	return os.Rename(part, out)
 }
 
 func GetBinaryDataCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "get-binary-data",
 		Usage:  "Get binary data",
-		Flags:  getBinaryDataFlags(),
+		Flags:  append(getBinaryDataFlags(), getFilterFlags()...),
 		Action: GetBinaryData(baseURL),
 	}
 }
@@ -0,0 +1,90 @@
+package cliApp
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/sender"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+)
+
+func getChangePasswordFlags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:     "old-password",
+			Aliases:  []string{"op"},
+			Usage:    "Current password",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "new-password",
+			Aliases:  []string{"np"},
+			Usage:    "New password",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:    "generate",
+			Aliases: []string{"g"},
+			Usage:   "Generate the new password",
+		},
+		&cli.StringFlag{
+			Name:     "token",
+			Aliases:  []string{"t"},
+			Usage:    "Token for Authorization",
+			Required: true,
+		},
+	}
+	return append(flags, getGenerateFlags()...)
+}
+
+func changePassword(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		newPassword := c.String("new-password")
+		if c.Bool("generate") {
+			generated, err := generatePassword(c)
+			if err != nil {
+				log.Fatalf("Error generating password: %v", err)
+			}
+			newPassword = generated
+			fmt.Printf("Generated password: %s\n", newPassword)
+		}
+		if newPassword == "" {
+			log.Fatalf("Either --new-password or --generate must be set")
+		}
+		printPasswordStrength(newPassword)
+
+		client := sender.NewClient(baseURL)
+		resp, err := client.SendRequest(
+			"POST",
+			"change-password",
+			map[string]string{
+				"old_password": c.String("old-password"),
+				"new_password": newPassword,
+			},
+			c.String("token"),
+		)
+		if err != nil {
+			log.Fatalf("Error changing password: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf(
+				"Failed to change password, status code: %d, response: %s",
+				resp.StatusCode,
+				resp.String(),
+			)
+		}
+
+		fmt.Printf("Password changed successfully: %s\n", resp.String())
+		return nil
+	}
+}
+
+func ChangePasswordCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:   "change-password",
+		Usage:  "Change the account password",
+		Flags:  getChangePasswordFlags(),
+		Action: changePassword(baseURL),
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/search"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/elina-chertova/auth-keeper.git/internal/sender"
+	"github.com/elina-chertova/auth-keeper.git/internal/validation"
 	"github.com/urfave/cli/v2"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 )
 
@@ -24,7 +27,7 @@ func getAddCardFlags() []cli.Flag {
 		&cli.StringFlag{
 			Name:     "expiry_date",
 			Aliases:  []string{"ed"},
-			Usage:    "Expiry Date",
+			Usage:    "Expiry Date (MM/YY or MM/YYYY)",
 			Required: true,
 		},
 		&cli.StringFlag{
@@ -62,7 +65,15 @@ func AddCard(baseURL string) func(c *cli.Context) error {
 			CVV:        c.String("cvv"),
 			CardHolder: c.String("card_holder"),
 			Metadata:   c.String("metadata"),
+			ItemMeta:   metaFromFlags(c, baseURL),
 		}
+		validation.NormalizeCreditCard(&creditCard)
+		if err := validation.ValidateCreditCard(&creditCard); err != nil {
+			log.Fatalf("Invalid credit card: %v", err)
+		}
+		fmt.Printf("Card brand: %s\n", creditCard.Brand)
+		indexItem(&creditCard)
+
 		token := c.String("token")
 		client := sender.NewClient(baseURL)
 
@@ -70,6 +81,9 @@ func AddCard(baseURL string) func(c *cli.Context) error {
 		if err != nil {
 			log.Fatalf("Error marshalling data: %v", err)
 		}
+		if jsonData, err = search.SealMeta(jsonData, metaKey); err != nil {
+			log.Fatalf("Error sealing display name and tags: %v", err)
+		}
 
 		personalKey, err := os.ReadFile("pkey.txt")
 		if err != nil {
@@ -93,9 +107,6 @@ func AddCard(baseURL string) func(c *cli.Context) error {
 		if err != nil {
 			log.Fatalf("Error adding credit card: %v", err)
 		}
-		if err != nil {
-			log.Fatalf("Error adding credit card: %v", err)
-		}
 
 		if resp.StatusCode != http.StatusCreated {
 			log.Fatalf(
@@ -114,7 +125,7 @@ func AddCardCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "add-card",
 		Usage:  "Add a credit card",
-		Flags:  getAddCardFlags(),
+		Flags:  append(getAddCardFlags(), getMetaFlags()...),
 		Action: AddCard(baseURL),
 	}
 }
@@ -132,49 +143,12 @@ func getCardFlags() []cli.Flag {
 
 func GetCard(baseURL string) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		token := c.String("token")
-		client := sender.NewClient(baseURL)
-		resp, err := client.SendRequest("GET", "get-card", nil, token)
+		endpoint := withQuery("get-card", filterQuery(c, baseURL, url.Values{}))
+		data, err := fetchDecrypted(baseURL, endpoint, c.String("token"))
 		if err != nil {
 			log.Fatalf("Error getting credit card: %v", err)
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			log.Fatalf(
-				"Failed to get credit card, status code: %d, response: %s",
-				resp.StatusCode,
-				resp.String(),
-			)
-		}
-
-		var responseData struct {
-			Body    string `json:"body"`
-			Message string `json:"message"`
-		}
-
-		if err := json.Unmarshal(resp.Bytes(), &responseData); err != nil {
-			log.Fatalf("Error unmarshalling response data: %v", err)
-		}
-
-		encodedData := responseData.Body
-		fmt.Printf("Encoded Data: %s\n", encodedData)
-
-		personalKey, err := os.ReadFile("pkey.txt")
-		if err != nil {
-			log.Fatalf("Error reading personal key: %v", err)
-		}
-
-		decodedData, err := base64.StdEncoding.DecodeString(encodedData)
-		if err != nil {
-			log.Fatalf("Error decoding base64 data: %v", err)
-		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey)
-		if err != nil {
-			log.Fatalf("Error decrypting data: %v", err)
-		}
-
-		fmt.Printf("Decrypted Data: %s\n", string(decryptedData))
+		fmt.Printf("Decrypted Data: %s\n", string(data))
 		return nil
 	}
 }
@@ -183,7 +157,7 @@ func GetCardCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "get-card",
 		Usage:  "Get credit cards",
-		Flags:  getCardFlags(),
+		Flags:  append(getCardFlags(), getFilterFlags()...),
 		Action: GetCard(baseURL),
 	}
 }
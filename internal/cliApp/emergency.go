@@ -0,0 +1,349 @@
+package cliApp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/search"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// GrantEmergency gives a user emergency access to the vault. The personal
+// key and the client key are sealed to the user's public key, so the server
+// cannot open them.
+func GrantEmergency(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		grantee := c.Args().Get(0)
+		if grantee == "" {
+			log.Fatalf("Usage: emergency grant [--type view|takeover] [--wait-days <days>] <user>")
+		}
+		token := c.String("token")
+
+		resp, err := sendRequest(
+			baseURL, "GET", "get-public-key/"+url.PathEscape(grantee), token, nil, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error getting public key of %s: %v", grantee, err)
+		}
+		var granteeKey struct {
+			PublicKey []byte `json:"public_key"`
+		}
+		if err := json.Unmarshal([]byte(resp), &granteeKey); err != nil {
+			log.Fatalf("Error unmarshalling public key: %v", err)
+		}
+		personalKey, err := os.ReadFile(personalKeyFile)
+		if err != nil {
+			log.Fatalf("Error reading personal key: %v", err)
+		}
+		clientKey, err := readClientKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		sealedKey, err := security.SealToPublicKey(append(personalKey, clientKey...), granteeKey.PublicKey)
+		if err != nil {
+			log.Fatalf("Error sealing personal key: %v", err)
+		}
+
+		resp, err = postEncrypted(
+			baseURL, "add-emergency-access", token, &models.EmergencyAccess{
+				GranteeID: grantee,
+				Type:      c.String("type"),
+				WaitDays:  c.Int("wait-days"),
+				Key:       sealedKey,
+			},
+		)
+		if err != nil {
+			log.Fatalf("Error granting emergency access: %v", err)
+		}
+		fmt.Printf("Emergency access granted to %s: %s\n", grantee, resp)
+		return nil
+	}
+}
+
+// ListEmergency lists the emergency access the user gave and warns about
+// pending requests, which are granted unless rejected in time.
+func ListEmergency(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var grants []*models.EmergencyAccess
+		if err := fetchList(baseURL, "get-emergency-access", c.String("token"), &grants); err != nil {
+			log.Fatalf("Error getting emergency access: %v", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tGRANTEE\tTYPE\tWAIT DAYS\tSTATUS\tRECOVERY AT")
+		for _, g := range grants {
+			fmt.Fprintf(
+				tw, "%d\t%s\t%s\t%d\t%s\t%s\n",
+				g.ID, g.GranteeID, g.Type, g.WaitDays, g.Status, recoveryAt(g),
+			)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		for _, g := range grants {
+			if g.Status == models.EmergencyRequested {
+				fmt.Printf(
+					"%s requested access %d; it is granted at %s unless you run emergency reject %d\n",
+					g.GranteeID, g.ID, recoveryAt(g), g.ID,
+				)
+			}
+		}
+		return nil
+	}
+}
+
+// ListEmergencyGranted lists the emergency access other users gave the
+// user.
+func ListEmergencyGranted(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var grants []*models.EmergencyAccess
+		if err := fetchList(baseURL, "get-emergency-access-granted", c.String("token"), &grants); err != nil {
+			log.Fatalf("Error getting emergency access: %v", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tGRANTOR\tTYPE\tWAIT DAYS\tSTATUS\tRECOVERY AT")
+		for _, g := range grants {
+			fmt.Fprintf(
+				tw, "%d\t%s\t%s\t%d\t%s\t%s\n",
+				g.ID, g.GrantorID, g.Type, g.WaitDays, g.Status, recoveryAt(g),
+			)
+		}
+		return tw.Flush()
+	}
+}
+
+func recoveryAt(g *models.EmergencyAccess) string {
+	if g.RecoveryAt == nil {
+		return "-"
+	}
+	return g.RecoveryAt.Local().Format(time.RFC3339)
+}
+
+// ChangeEmergency posts to the endpoint of an emergency access, such as
+// request-emergency-access, and prints what was done.
+func ChangeEmergency(baseURL, method, endpoint, done string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		id := c.Args().Get(0)
+		if id == "" {
+			log.Fatalf("Usage: emergency %s <id>", c.Command.Name)
+		}
+		resp, err := sendRequest(
+			baseURL, method, endpoint+"/"+url.PathEscape(id), c.String("token"), nil, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error changing emergency access: %v", err)
+		}
+		fmt.Printf("Emergency access %s: %s\n", done, resp)
+		return nil
+	}
+}
+
+// openEmergencyKey opens the keys of a grantor who approved emergency access.
+func openEmergencyKey(baseURL, id, token string) (string, []byte, []byte, error) {
+	var access models.EmergencyAccess
+	if err := fetchList(baseURL, "get-emergency-access-key/"+url.PathEscape(id), token, &access); err != nil {
+		return "", nil, nil, fmt.Errorf("error getting emergency access: %w", err)
+	}
+	privateKey, err := readPrivateKey()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	keys, err := security.OpenWithPrivateKey(access.Key, privateKey)
+	if err == nil && len(keys) != 2*security.KeySize {
+		err = security.ErrSealedData
+	}
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("error opening personal key of %s: %w", access.GrantorID, err)
+	}
+	return access.GrantorID, keys[:security.KeySize], keys[security.KeySize:], nil
+}
+
+// ViewEmergency prints the vault of a grantor once emergency access is
+// approved.
+func ViewEmergency(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		id := c.Args().Get(0)
+		if id == "" {
+			log.Fatalf("Usage: emergency view <id>")
+		}
+		token := c.String("token")
+		grantor, personalKey, clientKey, err := openEmergencyKey(baseURL, id, token)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := sendRequest(
+			baseURL, "GET", "get-emergency-vault/"+url.PathEscape(id), token, nil, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error getting vault of %s: %v", grantor, err)
+		}
+		var responseData struct {
+			Body string `json:"body"`
+		}
+		if err := json.Unmarshal([]byte(resp), &responseData); err != nil {
+			log.Fatalf("Error unmarshalling response data: %v", err)
+		}
+		encrypted, err := base64.StdEncoding.DecodeString(responseData.Body)
+		if err != nil {
+			log.Fatalf("Error decoding base64 data: %v", err)
+		}
+		vault, err := security.DecryptData(encrypted, personalKey)
+		if err != nil {
+			log.Fatalf("Error decrypting vault: %v", err)
+		}
+		vault, err = search.OpenMeta(
+			vault, func() ([]byte, error) {
+				return search.DeriveMetaKey(clientKey)
+			},
+		)
+		if err != nil {
+			log.Fatalf("Error opening display names and tags: %v", err)
+		}
+		fmt.Printf("Vault of %s:\n%s\n", grantor, indentJSON(vault))
+		return nil
+	}
+}
+
+// TakeoverEmergency sets a new password for the account of a grantor who
+// gave the user takeover access and saves the grantor's personal key, with
+// which the user can log in and work as the grantor.
+func TakeoverEmergency(baseURL, baseURLAuth string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		id := c.Args().Get(0)
+		password := c.String("new-password")
+		if id == "" || password == "" {
+			log.Fatalf("Usage: emergency takeover --new-password <password> [--key-out <file>] <id>")
+		}
+		token := c.String("token")
+		grantor, personalKey, clientKey, err := openEmergencyKey(baseURL, id, token)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := sendRequest(
+			baseURLAuth, "POST", "emergency-takeover/"+url.PathEscape(id), token,
+			map[string]string{"new_password": password}, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error taking over account of %s: %v", grantor, err)
+		}
+		keyOut := c.String("key-out")
+		if keyOut == "" {
+			keyOut = "pkey-" + grantor + ".txt"
+		}
+		if err := os.WriteFile(keyOut, personalKey, 0600); err != nil {
+			log.Fatalf("Error saving personal key of %s: %v", grantor, err)
+		}
+		fmt.Printf(
+			"Account of %s taken over, use %s as %s to work as them: %s\n",
+			grantor, keyOut, personalKeyFile, resp,
+		)
+		clientKeyOut := "ckey-" + grantor + ".txt"
+		if err := os.WriteFile(clientKeyOut, clientKey, 0600); err != nil {
+			log.Fatalf("Error saving client key of %s: %v", grantor, err)
+		}
+		fmt.Printf("Use %s as %s to read their display names, tags and search index\n", clientKeyOut, clientKeyFile)
+		return nil
+	}
+}
+
+func EmergencyCommand(baseURL, baseURLAuth string) *cli.Command {
+	return &cli.Command{
+		Name:  "emergency",
+		Usage: "Give trusted users emergency access to your vault and use access given to you",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "grant",
+				Usage:     "Give a user emergency access, granted after the wait period unless you reject it",
+				ArgsUsage: "<user>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "type",
+						Value: models.EmergencyView,
+						Usage: "Access of the user: view to read the vault, takeover to change your password",
+					},
+					&cli.IntFlag{
+						Name:  "wait-days",
+						Value: 7,
+						Usage: "Days you have to reject a request",
+					},
+					getTokenFlag(),
+				},
+				Action: GrantEmergency(baseURL),
+			},
+			{
+				Name:   "list",
+				Usage:  "List the emergency access you gave and pending requests",
+				Flags:  []cli.Flag{getTokenFlag()},
+				Action: ListEmergency(baseURL),
+			},
+			{
+				Name:   "granted",
+				Usage:  "List the emergency access given to you",
+				Flags:  []cli.Flag{getTokenFlag()},
+				Action: ListEmergencyGranted(baseURL),
+			},
+			{
+				Name:      "request",
+				Usage:     "Request emergency access given to you",
+				ArgsUsage: "<id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    ChangeEmergency(baseURL, "POST", "request-emergency-access", "requested"),
+			},
+			{
+				Name:      "reject",
+				Usage:     "Reject a request for emergency access or end approved access",
+				ArgsUsage: "<id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    ChangeEmergency(baseURL, "POST", "reject-emergency-access", "rejected"),
+			},
+			{
+				Name:      "approve",
+				Usage:     "Approve a request for emergency access without waiting",
+				ArgsUsage: "<id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    ChangeEmergency(baseURL, "POST", "approve-emergency-access", "approved"),
+			},
+			{
+				Name:      "revoke",
+				Usage:     "Take back emergency access you gave",
+				ArgsUsage: "<id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    ChangeEmergency(baseURL, "DELETE", "delete-emergency-access", "revoked"),
+			},
+			{
+				Name:      "view",
+				Usage:     "Print the vault of a user whose emergency access is approved",
+				ArgsUsage: "<id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    ViewEmergency(baseURL),
+			},
+			{
+				Name:      "takeover",
+				Usage:     "Set a new password for the account of a user whose takeover access is approved",
+				ArgsUsage: "<id>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "new-password",
+						Usage:    "New password of the account",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "key-out",
+						Usage: "File to save the user's personal key to, pkey-<user>.txt by default",
+					},
+					getTokenFlag(),
+				},
+				Action: TakeoverEmergency(baseURL, baseURLAuth),
+			},
+		},
+	}
+}
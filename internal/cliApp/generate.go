@@ -0,0 +1,106 @@
+package cliApp
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/urfave/cli/v2"
+	"log"
+)
+
+func getGenerateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:    "length",
+			Aliases: []string{"l"},
+			Usage:   "Password length",
+			Value:   security.DefaultPasswordLength,
+		},
+		&cli.BoolFlag{
+			Name:  "no-lower",
+			Usage: "Do not use lowercase letters",
+		},
+		&cli.BoolFlag{
+			Name:  "no-upper",
+			Usage: "Do not use uppercase letters",
+		},
+		&cli.BoolFlag{
+			Name:  "no-digits",
+			Usage: "Do not use digits",
+		},
+		&cli.BoolFlag{
+			Name:  "no-symbols",
+			Usage: "Do not use symbols",
+		},
+		&cli.BoolFlag{
+			Name:    "exclude-ambiguous",
+			Aliases: []string{"ea"},
+			Usage:   "Exclude look-alike characters such as l, 1, O and 0",
+		},
+		&cli.BoolFlag{
+			Name:    "passphrase",
+			Aliases: []string{"pp"},
+			Usage:   "Generate a passphrase of random words instead of a password",
+		},
+		&cli.IntFlag{
+			Name:    "words",
+			Aliases: []string{"w"},
+			Usage:   "Number of words in the passphrase",
+			Value:   security.DefaultPassphraseWords,
+		},
+		&cli.StringFlag{
+			Name:    "separator",
+			Aliases: []string{"s"},
+			Usage:   "Passphrase word separator",
+			Value:   security.DefaultPassphraseSep,
+		},
+	}
+}
+
+func generatePassword(c *cli.Context) (string, error) {
+	if c.Bool("passphrase") {
+		return security.GeneratePassphrase(c.Int("words"), c.String("separator"))
+	}
+	return security.GeneratePassword(
+		security.PasswordOptions{
+			Length:           c.Int("length"),
+			Lower:            !c.Bool("no-lower"),
+			Upper:            !c.Bool("no-upper"),
+			Digits:           !c.Bool("no-digits"),
+			Symbols:          !c.Bool("no-symbols"),
+			ExcludeAmbiguous: c.Bool("exclude-ambiguous"),
+		},
+	)
+}
+
+func printPasswordStrength(password string, userInputs ...string) {
+	strength := security.EstimateStrength(password, userInputs...)
+	fmt.Printf("Password strength: %s\n", strength)
+	for _, s := range strength.Suggestions {
+		fmt.Printf("  - %s\n", s)
+	}
+}
+
+func Generate() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		password, err := generatePassword(c)
+		if err != nil {
+			log.Fatalf("Error generating password: %v", err)
+		}
+
+		fmt.Println(password)
+		if c.Bool("passphrase") {
+			fmt.Printf("Entropy: %.0f bits\n", security.PassphraseBits(c.Int("words")))
+		}
+		printPasswordStrength(password)
+		return nil
+	}
+}
+
+func GenerateCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "generate",
+		Usage:  "Generate a password or passphrase",
+		Flags:  getGenerateFlags(),
+		Action: Generate(),
+	}
+}
@@ -0,0 +1,112 @@
+package cliApp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const itemTypesUsage = "<card|text|binary|login-password|otp|ssh-key|item> <id>"
+
+// itemVersion is one entry of the decrypted body of GET history-<route>/:id.
+type itemVersion struct {
+	Version   uint            `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Item      json.RawMessage `json:"item"`
+}
+
+func itemArgs(c *cli.Context, command string) (route, id string) {
+	route, ok := itemTypes[c.Args().Get(0)]
+	id = c.Args().Get(1)
+	if !ok || id == "" {
+		log.Fatalf("Usage: %s %s", command, itemTypesUsage)
+	}
+	return route, id
+}
+
+// History prints the kept versions of an item, newest first.
+func History(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		route, id := itemArgs(c, "history")
+
+		var versions []itemVersion
+		endpoint := "history-" + route + "/" + url.PathEscape(id)
+		if err := fetchList(baseURL, endpoint, c.String("token"), &versions); err != nil {
+			log.Fatalf("Error getting item history: %v", err)
+		}
+		if len(versions) == 0 {
+			fmt.Println("No earlier versions")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSAVED AT\tITEM")
+		for _, v := range versions {
+			fmt.Fprintf(
+				tw, "%d\t%s\t%s\n", v.Version, v.CreatedAt.Local().Format(time.DateTime), v.Item,
+			)
+		}
+		return tw.Flush()
+	}
+}
+
+// RestoreVersion makes a version listed by "history" the current contents
+// of the item and brings its search index up to date.
+func RestoreVersion(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		route, id := itemArgs(c, "restore-version")
+		token := c.String("token")
+
+		resp, err := sendRequest(
+			baseURL,
+			"POST",
+			fmt.Sprintf("restore-%s/%s/%d", route, url.PathEscape(id), c.Uint("version")),
+			token,
+			nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error restoring item version: %v", err)
+		}
+		fmt.Printf("Item version restored successfully: %s\n", resp)
+		changed := func(r string, itemID uint) bool {
+			return r == route && fmt.Sprint(itemID) == id
+		}
+		reindexAfterChange(baseURL, token, changed)
+		syncSharesAfterChange(baseURL, token, changed)
+		return nil
+	}
+}
+
+func HistoryCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "history",
+		Usage:     "List the earlier versions of an item",
+		ArgsUsage: itemTypesUsage,
+		Flags:     []cli.Flag{getTokenFlag()},
+		Action:    History(baseURL),
+	}
+}
+
+func RestoreVersionCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "restore-version",
+		Usage:     "Restore an earlier version of an item",
+		ArgsUsage: itemTypesUsage,
+		Flags: []cli.Flag{
+			&cli.UintFlag{
+				Name:     "version",
+				Usage:    "Version number shown by history",
+				Required: true,
+			},
+			getTokenFlag(),
+		},
+		Action: RestoreVersion(baseURL),
+	}
+}
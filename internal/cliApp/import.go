@@ -0,0 +1,289 @@
+package cliApp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/backup"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/importer"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
+	"github.com/urfave/cli/v2"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// importBatchSize is the most items sent in one batch, the server's
+	// limit.
+	importBatchSize = 100
+	// importBatchBytes bounds the attachment contents sent in one batch.
+	importBatchBytes = 8 << 20
+)
+
+// ImportVault reads the export of another password manager and adds its
+// items to the vault in batches. Items already in the vault or repeated in
+// the export are skipped unless --keep-duplicates is set; --dry-run only
+// prints what would be imported.
+func ImportVault(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		path := c.Args().Get(0)
+		if path == "" {
+			log.Fatalf("Usage: import [--format <format>] [--passphrase <password>] [--dry-run] <file>")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Error reading export: %v", err)
+		}
+		format := c.String("format")
+		if format == "" {
+			if format, err = importer.Detect(path, data); err != nil {
+				log.Fatal(err)
+			}
+		}
+		var vault *importer.Vault
+		if format == importer.FormatKDBX {
+			vault, err = importer.ParseKDBX(data, c.String("passphrase"))
+		} else {
+			vault, err = importer.Parse(format, data)
+		}
+		if err != nil {
+			log.Fatalf("Error reading %s export: %v", format, err)
+		}
+		token := c.String("token")
+
+		duplicates := 0
+		if !c.Bool("keep-duplicates") {
+			existing, err := fetchVault(baseURL, token)
+			if err != nil {
+				log.Fatalf("Error getting vault: %v", err)
+			}
+			duplicates = vault.Dedupe(existing)
+		}
+
+		for _, warning := range vault.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+		if c.Bool("dry-run") {
+			if err := printImport(vault); err != nil {
+				return err
+			}
+		}
+		fmt.Printf(
+			"%s export: %d login-password, %d card, %d text-data, %d attachments; %d duplicates skipped\n",
+			format, len(vault.LoginPasswords), len(vault.CreditCards), len(vault.TextData),
+			len(vault.Attachments), duplicates,
+		)
+		if c.Bool("dry-run") || vault.Len() == 0 {
+			return nil
+		}
+
+		if err := importFolders(baseURL, token, vault); err != nil {
+			log.Fatalf("Error creating folders: %v", err)
+		}
+		if err := importAttachments(vault.Attachments); err != nil {
+			log.Fatalf("Error reading attachments: %v", err)
+		}
+		_, err = importBatches(baseURL, token, "login-password", vault.LoginPasswords, nil)
+		if err == nil {
+			_, err = importBatches(baseURL, token, "card", vault.CreditCards, nil)
+		}
+		if err == nil {
+			_, err = importBatches(baseURL, token, "text-data", vault.TextData, nil)
+		}
+		if err == nil {
+			_, err = importBatches(
+				baseURL, token, "binary-data", vault.Attachments,
+				func(bd *models.BinaryData) int { return len(bd.Content) },
+			)
+		}
+		if err != nil {
+			log.Fatalf("Error importing: %v\nBatches already imported are skipped as duplicates when run again", err)
+		}
+		fmt.Printf("Imported %d items\n", vault.Len())
+		return nil
+	}
+}
+
+// fetchVault gets the items an import is checked against for duplicates.
+func fetchVault(baseURL, token string) (*importer.Vault, error) {
+	v := new(importer.Vault)
+	if err := fetchList(baseURL, "get-login-password", token, &v.LoginPasswords); err != nil {
+		return nil, err
+	}
+	if err := fetchList(baseURL, "get-card", token, &v.CreditCards); err != nil {
+		return nil, err
+	}
+	if err := fetchList(baseURL, "get-text-data", token, &v.TextData); err != nil {
+		return nil, err
+	}
+	if err := fetchList(baseURL, "get-binary-data", token, &v.Attachments); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// printImport lists the items of an import.
+func printImport(vault *importer.Vault) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tNAME\tUSERNAME\tFOLDER")
+	for _, lp := range vault.LoginPasswords {
+		fmt.Fprintf(tw, "login-password\t%s\t%s\t%s\n", lp.DisplayName, lp.Login, vault.Folders[&lp.ItemMeta])
+	}
+	for _, cc := range vault.CreditCards {
+		fmt.Fprintf(tw, "card\t%s\t%s\t%s\n", cc.DisplayName, cc.CardHolder, vault.Folders[&cc.ItemMeta])
+	}
+	for _, td := range vault.TextData {
+		fmt.Fprintf(tw, "text-data\t%s\t\t%s\n", td.DisplayName, vault.Folders[&td.ItemMeta])
+	}
+	for _, bd := range vault.Attachments {
+		fmt.Fprintf(
+			tw, "binary-data\t%s (%s)\t\t%s\n", bd.FileName, byteSize(bd.Size), vault.Folders[&bd.ItemMeta],
+		)
+	}
+	return tw.Flush()
+}
+
+// importFolders creates the folders of an import and sets its folder IDs.
+func importFolders(baseURL, token string, vault *importer.Vault) error {
+	paths := vault.FolderPaths()
+	if len(paths) == 0 {
+		return nil
+	}
+	ids, err := ensureFolders(baseURL, token, paths)
+	if err != nil {
+		return err
+	}
+	for meta, path := range vault.Folders {
+		id := ids[strings.ToLower(path)]
+		meta.FolderID = &id
+	}
+	return nil
+}
+
+// ensureFolders creates missing folders and returns all IDs by lower-cased path.
+func ensureFolders(baseURL, token string, paths []string) (map[string]uint, error) {
+	var folders []*models.Folder
+	if err := fetchList(baseURL, "get-folder", token, &folders); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint)
+	for id, path := range backup.FolderPaths(folders) {
+		ids[strings.ToLower(path)] = id
+	}
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		names := strings.Split(path, "/")
+		for i := range names {
+			prefix := strings.ToLower(strings.Join(names[:i+1], "/"))
+			if _, ok := ids[prefix]; ok {
+				continue
+			}
+			folder := models.Folder{Name: names[i]}
+			if i > 0 {
+				parent := ids[strings.ToLower(strings.Join(names[:i], "/"))]
+				folder.ParentID = &parent
+			}
+			resp, err := postEncrypted(baseURL, "add-folder", token, folder)
+			if err != nil {
+				return nil, err
+			}
+			var created struct {
+				ID uint `json:"id"`
+			}
+			if err := json.Unmarshal([]byte(resp), &created); err != nil {
+				return nil, fmt.Errorf("error unmarshalling response: %w", err)
+			}
+			ids[prefix] = created.ID
+			fmt.Printf("Folder %s created\n", strings.Join(names[:i+1], "/"))
+		}
+	}
+	return ids, nil
+}
+
+// importAttachments sets the content keys of attachments.
+func importAttachments(attachments []*models.BinaryData) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	personalKey, err := os.ReadFile(personalKeyFile)
+	if err != nil {
+		return fmt.Errorf("error reading personal key: %w", err)
+	}
+	for _, bd := range attachments {
+		contentKey, err := stream.NewContentKey(personalKey)
+		if err != nil {
+			return err
+		}
+		contentKey.Write(bd.Content)
+		bd.ContentKey = hex.EncodeToString(contentKey.Sum(nil))
+	}
+	return nil
+}
+
+// importBatches posts items to add-<route>-batch and returns their IDs in order.
+func importBatches[T any, PT interface {
+	*T
+	Meta() *models.ItemMeta
+}](baseURL, token, route string, items []*T, size func(*T) int) ([]uint, error) {
+	ids := make([]uint, 0, len(items))
+	for start := 0; start < len(items); {
+		end, bytes := start, 0
+		for end < len(items) && end-start < importBatchSize {
+			if size != nil && end > start && bytes+size(items[end]) > importBatchBytes {
+				break
+			}
+			if size != nil {
+				bytes += size(items[end])
+			}
+			indexItem(PT(items[end]))
+			end++
+		}
+		resp, err := postEncrypted(baseURL, "add-"+route+"-batch", token, items[start:end])
+		if err != nil {
+			return ids, err
+		}
+		var added struct {
+			IDs []uint `json:"ids"`
+		}
+		if err := json.Unmarshal([]byte(resp), &added); err != nil {
+			return ids, fmt.Errorf("error unmarshalling response: %w", err)
+		}
+		ids = append(ids, added.IDs...)
+		fmt.Printf("Imported %d/%d %s\n", end, len(items), route)
+		start = end
+	}
+	return ids, nil
+}
+
+func ImportCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "Import the export of another password manager",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Format of the export, guessed from the file if not set: " + strings.Join(importer.Formats, ", "),
+			},
+			&cli.StringFlag{
+				Name:  "passphrase",
+				Usage: "Password of a KeePass KDBX database",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print what would be imported without importing it",
+			},
+			&cli.BoolFlag{
+				Name:  "keep-duplicates",
+				Usage: "Import items that are already in the vault or repeated in the export",
+			},
+			getTokenFlag(),
+		},
+		Action: ImportVault(baseURL),
+	}
+}
@@ -0,0 +1,304 @@
+package cliApp
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/item"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// parseItemField parses "name=value" or "name:type=value".
+func parseItemField(spec string) (models.ItemField, error) {
+	name, value, ok := strings.Cut(spec, "=")
+	if !ok || name == "" {
+		return models.ItemField{}, fmt.Errorf("field %q must look like name=value", spec)
+	}
+	field := models.ItemField{Name: name, Value: value}
+	if n, t, typed := strings.Cut(name, ":"); typed {
+		field.Name, field.Type = n, t
+	}
+	return field, nil
+}
+
+// parseTemplateField parses "name[:type[:required]]".
+func parseTemplateField(spec string) (models.ItemField, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return models.ItemField{}, fmt.Errorf("field %q must look like name[:type[:required]]", spec)
+	}
+	field := models.ItemField{Name: parts[0]}
+	if len(parts) > 1 {
+		field.Type = parts[1]
+	}
+	if len(parts) > 2 {
+		if parts[2] != "required" {
+			return models.ItemField{}, fmt.Errorf("field %q: unknown option %q", spec, parts[2])
+		}
+		field.Required = true
+	}
+	return field, nil
+}
+
+func itemFromFlags(c *cli.Context) *models.Item {
+	it := &models.Item{
+		Template: c.String("template"),
+		Name:     c.String("name"),
+		Metadata: c.String("metadata"),
+	}
+	for _, spec := range c.StringSlice("field") {
+		field, err := parseItemField(spec)
+		if err != nil {
+			log.Fatalf("Error parsing field: %v", err)
+		}
+		it.Fields = append(it.Fields, field)
+	}
+	return it
+}
+
+func getItemFlags(nameRequired bool) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "template",
+			Aliases: []string{"T"},
+			Usage:   "Template name, e.g. api-key, database, software-license",
+		},
+		&cli.StringFlag{
+			Name:     "name",
+			Aliases:  []string{"n"},
+			Usage:    "Item name",
+			Required: nameRequired,
+		},
+		&cli.StringSliceFlag{
+			Name:    "field",
+			Aliases: []string{"f"},
+			Usage:   "Field as name=value or name:type=value, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:    "metadata",
+			Aliases: []string{"m"},
+			Usage:   "Metadata",
+		},
+		getTokenFlag(),
+	}
+}
+
+func AddItem(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		it := itemFromFlags(c)
+		it.ItemMeta = metaFromFlags(c, baseURL)
+		resp, err := postEncrypted(baseURL, "add-item", c.String("token"), it)
+		if err != nil {
+			log.Fatalf("Error adding item: %v", err)
+		}
+		fmt.Printf("Item added successfully: %s\n", resp)
+		return nil
+	}
+}
+
+func UpdateItem(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		resp, err := sendEncrypted(
+			baseURL,
+			"PUT",
+			fmt.Sprintf("update-item/%d", c.Uint("id")),
+			c.String("token"),
+			itemFromFlags(c),
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error updating item: %v", err)
+		}
+		fmt.Printf("Item updated successfully: %s\n", resp)
+		syncSharesAfterChange(
+			baseURL, c.String("token"), func(route string, id uint) bool {
+				return route == "item" && id == c.Uint("id")
+			},
+		)
+		return nil
+	}
+}
+
+func DeleteItem(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		resp, err := sendRequest(
+			baseURL,
+			"DELETE",
+			fmt.Sprintf("delete-item/%d", c.Uint("id")),
+			c.String("token"),
+			nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error deleting item: %v", err)
+		}
+		fmt.Printf("Item moved to trash: %s\n", resp)
+		return nil
+	}
+}
+
+func formatItemFields(fields []models.ItemField, reveal bool) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		value := f.Value
+		if f.Type == item.FieldConcealed && !reveal {
+			value = "********"
+		}
+		parts = append(parts, f.Name+"="+value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func ListItems(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		query := url.Values{}
+		if template := c.String("template"); template != "" {
+			query.Set("template", template)
+		}
+		endpoint := withQuery("get-item", filterQuery(c, baseURL, query))
+
+		var items []*models.Item
+		if err := fetchList(baseURL, endpoint, c.String("token"), &items); err != nil {
+			log.Fatalf("Error getting items: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTEMPLATE\tNAME\tFIELDS")
+		for _, it := range items {
+			fmt.Fprintf(
+				tw, "%d\t%s\t%s\t%s\n",
+				it.ID, it.Template, it.Name, formatItemFields(it.Fields, c.Bool("reveal")),
+			)
+		}
+		return tw.Flush()
+	}
+}
+
+func AddItemTemplate(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		t := &models.ItemTemplate{Name: c.String("name")}
+		for _, spec := range c.StringSlice("field") {
+			field, err := parseTemplateField(spec)
+			if err != nil {
+				log.Fatalf("Error parsing field: %v", err)
+			}
+			t.Fields = append(t.Fields, field)
+		}
+
+		resp, err := postEncrypted(baseURL, "add-item-template", c.String("token"), t)
+		if err != nil {
+			log.Fatalf("Error adding item template: %v", err)
+		}
+		fmt.Printf("Item template added successfully: %s\n", resp)
+		return nil
+	}
+}
+
+func ListItemTemplates(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var templates []*models.ItemTemplate
+		if err := fetchList(baseURL, "get-item-template", c.String("token"), &templates); err != nil {
+			log.Fatalf("Error getting item templates: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TEMPLATE\tFIELDS")
+		for _, t := range templates {
+			fields := make([]string, 0, len(t.Fields))
+			for _, f := range t.Fields {
+				spec := f.Name + ":" + f.Type
+				if f.Required {
+					spec += ":required"
+				}
+				fields = append(fields, spec)
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", t.Name, strings.Join(fields, ", "))
+		}
+		return tw.Flush()
+	}
+}
+
+func ItemCommand(baseURL string) *cli.Command {
+	idFlag := &cli.UintFlag{
+		Name:     "id",
+		Usage:    "Item ID",
+		Required: true,
+	}
+	return &cli.Command{
+		Name:  "item",
+		Usage: "Manage custom items such as API keys, database credentials or licenses",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "add",
+				Usage:  "Add an item",
+				Flags:  append(getItemFlags(true), getMetaFlags()...),
+				Action: AddItem(baseURL),
+			},
+			{
+				Name:  "list",
+				Usage: "List items",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:    "template",
+						Aliases: []string{"T"},
+						Usage:   "Only items of this template",
+					},
+					&cli.BoolFlag{
+						Name:  "reveal",
+						Usage: "Show concealed field values",
+					},
+					getTokenFlag(),
+				}, getFilterFlags()...),
+				Action: ListItems(baseURL),
+			},
+			{
+				Name:   "update",
+				Usage:  "Replace an item's name and fields",
+				Flags:  append(getItemFlags(true), idFlag),
+				Action: UpdateItem(baseURL),
+			},
+			{
+				Name:   "delete",
+				Usage:  "Move an item to the trash",
+				Flags:  []cli.Flag{idFlag, getTokenFlag()},
+				Action: DeleteItem(baseURL),
+			},
+			{
+				Name:  "template",
+				Usage: "Manage item templates",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "Add a template",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Aliases:  []string{"n"},
+								Usage:    "Template name",
+								Required: true,
+							},
+							&cli.StringSliceFlag{
+								Name:    "field",
+								Aliases: []string{"f"},
+								Usage:   "Field as name[:type[:required]], can be repeated",
+							},
+							getTokenFlag(),
+						},
+						Action: AddItemTemplate(baseURL),
+					},
+					{
+						Name:   "list",
+						Usage:  "List built-in and own templates",
+						Flags:  []cli.Flag{getTokenFlag()},
+						Action: ListItemTemplates(baseURL),
+					},
+				},
+			},
+		},
+	}
+}
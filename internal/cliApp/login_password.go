@@ -5,16 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/search"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/elina-chertova/auth-keeper.git/internal/sender"
 	"github.com/urfave/cli/v2"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 )
 
 func getAddLoginPasswordFlags() []cli.Flag {
-	return []cli.Flag{
+	flags := []cli.Flag{
 		&cli.StringFlag{
 			Name:     "username",
 			Aliases:  []string{"u"},
@@ -25,7 +27,16 @@ func getAddLoginPasswordFlags() []cli.Flag {
 			Name:     "password",
 			Aliases:  []string{"p"},
 			Usage:    "Password",
-			Required: true,
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:  "url",
+			Usage: "Website URL",
+		},
+		&cli.BoolFlag{
+			Name:    "generate",
+			Aliases: []string{"g"},
+			Usage:   "Generate a password instead of passing --password",
 		},
 		&cli.StringFlag{
 			Name:     "metadata",
@@ -40,15 +51,33 @@ func getAddLoginPasswordFlags() []cli.Flag {
 			Required: true,
 		},
 	}
+	return append(flags, getGenerateFlags()...)
 }
 
 func AddLoginPassword(baseURL string) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
+		password := c.String("password")
+		if c.Bool("generate") {
+			generated, err := generatePassword(c)
+			if err != nil {
+				log.Fatalf("Error generating password: %v", err)
+			}
+			password = generated
+			fmt.Printf("Generated password: %s\n", password)
+		}
+		if password == "" {
+			log.Fatalf("Either --password or --generate must be set")
+		}
+		printPasswordStrength(password, c.String("username"))
+
 		lpData := models.LoginPassword{
 			Login:    c.String("username"),
-			Password: c.String("password"),
+			Password: password,
+			URL:      c.String("url"),
 			Metadata: c.String("metadata"),
+			ItemMeta: metaFromFlags(c, baseURL),
 		}
+		indexItem(&lpData)
 		token := c.String("token")
 		client := sender.NewClient(baseURL)
 
@@ -56,6 +85,9 @@ func AddLoginPassword(baseURL string) func(c *cli.Context) error {
 		if err != nil {
 			log.Fatalf("Error marshalling data: %v", err)
 		}
+		if jsonData, err = search.SealMeta(jsonData, metaKey); err != nil {
+			log.Fatalf("Error sealing display name and tags: %v", err)
+		}
 
 		personalKey, err := os.ReadFile("pkey.txt")
 		if err != nil {
@@ -96,7 +128,7 @@ func AddLoginPasswordCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "add-login-password",
 		Usage:  "Add a login-password data",
-		Flags:  getAddLoginPasswordFlags(),
+		Flags:  append(getAddLoginPasswordFlags(), getMetaFlags()...),
 		Action: AddLoginPassword(baseURL),
 	}
 }
@@ -114,49 +146,12 @@ func getLoginPasswordFlags() []cli.Flag {
 
 func GetLoginPassword(baseURL string) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		token := c.String("token")
-		client := sender.NewClient(baseURL)
-		resp, err := client.SendRequest("GET", "get-login-password", nil, token)
+		endpoint := withQuery("get-login-password", filterQuery(c, baseURL, url.Values{}))
+		data, err := fetchDecrypted(baseURL, endpoint, c.String("token"))
 		if err != nil {
 			log.Fatalf("Error getting login-password data: %v", err)
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			log.Fatalf(
-				"Failed to get login-password data, status code: %d, response: %s",
-				resp.StatusCode,
-				resp.String(),
-			)
-		}
-
-		var responseData struct {
-			Body    string `json:"body"`
-			Message string `json:"message"`
-		}
-
-		if err := json.Unmarshal(resp.Bytes(), &responseData); err != nil {
-			log.Fatalf("Error unmarshalling response data: %v", err)
-		}
-
-		encodedData := responseData.Body
-		fmt.Printf("Encoded Data: %s\n", encodedData)
-
-		personalKey, err := os.ReadFile("pkey.txt")
-		if err != nil {
-			log.Fatalf("Error reading personal key: %v", err)
-		}
-
-		decodedData, err := base64.StdEncoding.DecodeString(encodedData)
-		if err != nil {
-			log.Fatalf("Error decoding base64 data: %v", err)
-		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey)
-		if err != nil {
-			log.Fatalf("Error decrypting data: %v", err)
-		}
-
-		fmt.Printf("Decrypted Data: %s\n", string(decryptedData))
+		fmt.Printf("Decrypted Data: %s\n", string(data))
 		return nil
 	}
 }
@@ -165,7 +160,7 @@ func GetLoginPasswordCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "get-login-password",
 		Usage:  "Get login-password data",
-		Flags:  getLoginPasswordFlags(),
+		Flags:  append(getLoginPasswordFlags(), getFilterFlags()...),
 		Action: GetLoginPassword(baseURL),
 	}
 }
@@ -0,0 +1,67 @@
+package cliApp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/urfave/cli/v2"
+	"net/http"
+	"os"
+)
+
+// WithNotices makes every command among commands that takes a token first
+// ask the server what the user has to attend to, once per run.
+func WithNotices(baseURL string, commands []*cli.Command) []*cli.Command {
+	for _, cmd := range commands {
+		WithNotices(baseURL, cmd.Subcommands)
+		for _, flag := range cmd.Flags {
+			if flag.Names()[0] == "token" {
+				cmd.Before = func(c *cli.Context) error {
+					printNotices(baseURL, c.String("token"))
+					return nil
+				}
+				break
+			}
+		}
+	}
+	return commands
+}
+
+// printNotices warns on stderr of emergency requests and edited shares.
+func printNotices(baseURL, token string) {
+	resp, err := sendRequest(baseURL, "GET", "get-notices", token, nil, http.StatusOK)
+	if err != nil {
+		return
+	}
+	var body struct {
+		Notices models.Notices `json:"notices"`
+	}
+	if err := json.Unmarshal([]byte(resp), &body); err != nil {
+		return
+	}
+
+	if n := body.Notices.EmergencyRequests; n > 0 {
+		var grants []*models.EmergencyAccess
+		if err := fetchList(baseURL, "get-emergency-access", token, &grants); err != nil {
+			fmt.Fprintf(
+				os.Stderr, "Warning: %d request(s) for emergency access to your vault, "+
+					"see emergency list; reject them before the wait period is over\n", n,
+			)
+		}
+		for _, g := range grants {
+			if g.Status == models.EmergencyRequested {
+				fmt.Fprintf(
+					os.Stderr, "Warning: %s requested emergency access %d to your vault; "+
+						"it is granted at %s unless you run emergency reject %d\n",
+					g.GranteeID, g.ID, recoveryAt(g), g.ID,
+				)
+			}
+		}
+	}
+	if n := body.Notices.SharesEdited; n > 0 {
+		fmt.Fprintf(
+			os.Stderr, "Note: %d shared item(s) edited by recipients, "+
+				"run share sync to merge the edits into your items\n", n,
+		)
+	}
+}
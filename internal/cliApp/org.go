@@ -0,0 +1,652 @@
+package cliApp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// CreateOrg creates an organization owned by the user.
+func CreateOrg(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		name := c.Args().Get(0)
+		if name == "" {
+			log.Fatalf("Usage: org create <name>")
+		}
+		resp, err := postEncrypted(baseURL, "add-org", c.String("token"), &models.Organization{Name: name})
+		if err != nil {
+			log.Fatalf("Error creating organization: %v", err)
+		}
+		fmt.Printf("Organization created: %s\n", resp)
+		return nil
+	}
+}
+
+// ListOrgs lists the user's organizations and pending invitations.
+func ListOrgs(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var memberships []*models.OrgMember
+		if err := fetchList(baseURL, "get-org", c.String("token"), &memberships); err != nil {
+			log.Fatalf("Error getting organizations: %v", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tROLE\tSTATUS")
+		for _, m := range memberships {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", m.OrgID, m.OrgName, m.Role, memberStatus(m))
+		}
+		return tw.Flush()
+	}
+}
+
+func memberStatus(m *models.OrgMember) string {
+	if m.Accepted {
+		return "member"
+	}
+	return "invited"
+}
+
+func AcceptOrg(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		orgID := c.Args().Get(0)
+		if orgID == "" {
+			log.Fatalf("Usage: org accept <org-id>")
+		}
+		resp, err := sendRequest(
+			baseURL, "POST", "accept-org/"+url.PathEscape(orgID), c.String("token"), nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error accepting invitation: %v", err)
+		}
+		fmt.Printf("Invitation accepted: %s\n", resp)
+		return nil
+	}
+}
+
+func fetchMembers(baseURL, orgID, token string) ([]*models.OrgMember, error) {
+	var members []*models.OrgMember
+	if err := fetchList(baseURL, "get-org-member/"+url.PathEscape(orgID), token, &members); err != nil {
+		return nil, fmt.Errorf("error getting members: %w", err)
+	}
+	return members, nil
+}
+
+func fetchCollections(baseURL, orgID, token string) ([]*models.Collection, error) {
+	var collections []*models.Collection
+	if err := fetchList(baseURL, "get-collection/"+url.PathEscape(orgID), token, &collections); err != nil {
+		return nil, fmt.Errorf("error getting collections: %w", err)
+	}
+	return collections, nil
+}
+
+// findCollection looks a collection up in the user's organizations.
+func findCollection(baseURL, collectionID, token string) (*models.Collection, error) {
+	var memberships []*models.OrgMember
+	if err := fetchList(baseURL, "get-org", token, &memberships); err != nil {
+		return nil, fmt.Errorf("error getting organizations: %w", err)
+	}
+	for _, m := range memberships {
+		if !m.Accepted {
+			continue
+		}
+		collections, err := fetchCollections(baseURL, strconv.FormatUint(uint64(m.OrgID), 10), token)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range collections {
+			if strconv.FormatUint(uint64(col.ID), 10) == collectionID {
+				return col, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("collection %s not found", collectionID)
+}
+
+// sealCollectionKey seals a collection key to every member.
+func sealCollectionKey(key []byte, members []*models.OrgMember) ([]*models.CollectionKey, error) {
+	keys := make([]*models.CollectionKey, 0, len(members))
+	for _, m := range members {
+		if len(m.PublicKey) == 0 {
+			return nil, fmt.Errorf("%s has no public key", m.UserID)
+		}
+		sealed, err := security.SealToPublicKey(key, m.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &models.CollectionKey{UserID: m.UserID, Key: sealed})
+	}
+	return keys, nil
+}
+
+// InviteMember seals the key of every collection of an organization to a
+// user's public key and invites the user.
+func InviteMember(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		orgID, username := c.Args().Get(0), c.Args().Get(1)
+		if orgID == "" || username == "" {
+			log.Fatalf("Usage: org invite [--role admin|member|read-only] <org-id> <user>")
+		}
+		token := c.String("token")
+
+		privateKey, err := readPrivateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		resp, err := sendRequest(
+			baseURL, "GET", "get-public-key/"+url.PathEscape(username), token, nil, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error getting public key of %s: %v", username, err)
+		}
+		var invitee struct {
+			PublicKey []byte `json:"public_key"`
+		}
+		if err := json.Unmarshal([]byte(resp), &invitee); err != nil {
+			log.Fatalf("Error unmarshalling public key: %v", err)
+		}
+		collections, err := fetchCollections(baseURL, orgID, token)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		invite := &models.OrgInvite{UserID: username, Role: c.String("role")}
+		for _, col := range collections {
+			key, err := security.OpenWithPrivateKey(col.Key, privateKey)
+			if err != nil {
+				log.Fatalf("Error opening key of collection %s: %v", col.Name, err)
+			}
+			sealed, err := security.SealToPublicKey(key, invitee.PublicKey)
+			if err != nil {
+				log.Fatalf("Error sealing key of collection %s: %v", col.Name, err)
+			}
+			invite.Keys = append(invite.Keys, &models.CollectionKey{CollectionID: col.ID, Key: sealed})
+		}
+
+		resp, err = postEncrypted(baseURL, "invite-org-member/"+url.PathEscape(orgID), token, invite)
+		if err != nil {
+			log.Fatalf("Error inviting %s: %v", username, err)
+		}
+		fmt.Printf("%s invited: %s\n", username, resp)
+		return nil
+	}
+}
+
+func ListMembers(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		orgID := c.Args().Get(0)
+		if orgID == "" {
+			log.Fatalf("Usage: org members <org-id>")
+		}
+		members, err := fetchMembers(baseURL, orgID, c.String("token"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "USER\tROLE\tSTATUS")
+		for _, m := range members {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", m.UserID, m.Role, memberStatus(m))
+		}
+		return tw.Flush()
+	}
+}
+
+// RemoveMember removes a user from an organization. Every collection gets a
+// new key, sealed to the remaining members, and its items are sealed with
+// it, so that the removed user's keys open nothing stored from now on.
+func RemoveMember(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		orgID, username := c.Args().Get(0), c.Args().Get(1)
+		if orgID == "" || username == "" {
+			log.Fatalf("Usage: org remove <org-id> <user>")
+		}
+		token := c.String("token")
+
+		privateKey, err := readPrivateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		members, err := fetchMembers(baseURL, orgID, token)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var remaining []*models.OrgMember
+		for _, m := range members {
+			if m.UserID != username {
+				remaining = append(remaining, m)
+			}
+		}
+		if len(remaining) == len(members) {
+			log.Fatalf("%s is not a member of organization %s", username, orgID)
+		}
+		collections, err := fetchCollections(baseURL, orgID, token)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		rotations := make([]*models.CollectionRotation, 0, len(collections))
+		for _, col := range collections {
+			rotation, err := rotateCollection(baseURL, token, col, privateKey, remaining)
+			if err != nil {
+				log.Fatalf("Error rotating key of collection %s: %v", col.Name, err)
+			}
+			rotations = append(rotations, rotation)
+		}
+
+		resp, err := sendEncrypted(
+			baseURL, "DELETE",
+			"remove-org-member/"+url.PathEscape(orgID)+"/"+url.PathEscape(username),
+			token, rotations, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error removing %s: %v", username, err)
+		}
+		fmt.Printf("%s removed, collection keys rotated: %s\n", username, resp)
+		return nil
+	}
+}
+
+// rotateCollection seals the items of a collection with a new key.
+func rotateCollection(
+	baseURL, token string,
+	col *models.Collection,
+	privateKey []byte,
+	members []*models.OrgMember,
+) (*models.CollectionRotation, error) {
+	oldKey, err := security.OpenWithPrivateKey(col.Key, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	var items []*models.CollectionItem
+	endpoint := "get-collection-item/" + strconv.FormatUint(uint64(col.ID), 10)
+	if err := fetchList(baseURL, endpoint, token, &items); err != nil {
+		return nil, err
+	}
+	newKey, err := security.NewItemKey()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := sealCollectionKey(newKey, members)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := &models.CollectionRotation{CollectionID: col.ID, KeyVersion: col.KeyVersion, Keys: keys}
+	for _, item := range items {
+		data, err := security.OpenData(item.Data, oldKey)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", item.ID, err)
+		}
+		if item.Data, err = security.SealData(data, newKey); err != nil {
+			return nil, err
+		}
+		rotation.Items = append(rotation.Items, item)
+	}
+	return rotation, nil
+}
+
+func ListCollections(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		orgID := c.Args().Get(0)
+		if orgID == "" {
+			log.Fatalf("Usage: org collections <org-id>")
+		}
+		collections, err := fetchCollections(baseURL, orgID, c.String("token"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tKEY VERSION")
+		for _, col := range collections {
+			fmt.Fprintf(tw, "%d\t%s\t%d\n", col.ID, col.Name, col.KeyVersion)
+		}
+		return tw.Flush()
+	}
+}
+
+// CreateCollection creates a collection with a new key sealed to every
+// member of the organization, invited users included.
+func CreateCollection(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		orgID, name := c.Args().Get(0), c.Args().Get(1)
+		if orgID == "" || name == "" {
+			log.Fatalf("Usage: org collection create <org-id> <name>")
+		}
+		token := c.String("token")
+
+		members, err := fetchMembers(baseURL, orgID, token)
+		if err != nil {
+			log.Fatal(err)
+		}
+		key, err := security.NewItemKey()
+		if err != nil {
+			log.Fatalf("Error creating collection key: %v", err)
+		}
+		keys, err := sealCollectionKey(key, members)
+		if err != nil {
+			log.Fatalf("Error sealing collection key: %v", err)
+		}
+
+		resp, err := postEncrypted(
+			baseURL, "add-collection/"+url.PathEscape(orgID), token,
+			&models.NewCollection{Name: name, Keys: keys},
+		)
+		if err != nil {
+			log.Fatalf("Error creating collection: %v", err)
+		}
+		fmt.Printf("Collection created: %s\n", resp)
+		return nil
+	}
+}
+
+// openCollection returns the key of a collection and its items.
+func openCollection(baseURL, collectionID, token string) ([]byte, *models.Collection, []*models.CollectionItem) {
+	col, err := findCollection(baseURL, collectionID, token)
+	if err != nil {
+		log.Fatal(err)
+	}
+	privateKey, err := readPrivateKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	key, err := security.OpenWithPrivateKey(col.Key, privateKey)
+	if err != nil {
+		log.Fatalf("Error opening collection key: %v", err)
+	}
+	var items []*models.CollectionItem
+	if err := fetchList(baseURL, "get-collection-item/"+url.PathEscape(collectionID), token, &items); err != nil {
+		log.Fatalf("Error getting collection items: %v", err)
+	}
+	return key, col, items
+}
+
+// ListCollectionItems lists the items of a collection; --reveal also
+// prints them. Items that cannot be opened are named "?".
+func ListCollectionItems(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		collectionID := c.Args().Get(0)
+		if collectionID == "" {
+			log.Fatalf("Usage: org collection items [--reveal] <collection-id>")
+		}
+		key, _, items := openCollection(baseURL, collectionID, c.String("token"))
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTYPE\tNAME\tUPDATED BY")
+		var revealed []string
+		for _, item := range items {
+			name := "?"
+			if data, err := security.OpenData(item.Data, key); err == nil {
+				name = displayName(data)
+				if name == "" {
+					name = item.ItemType
+				}
+				if c.Bool("reveal") {
+					revealed = append(revealed, fmt.Sprintf("#%d %s", item.ID, indentJSON(data)))
+				}
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", item.ID, item.ItemType, name, item.UpdatedBy)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		for _, item := range revealed {
+			fmt.Println(item)
+		}
+		return nil
+	}
+}
+
+// AddToCollection copies one of the user's items into a collection.
+func AddToCollection(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		collectionID := c.Args().Get(0)
+		route, ok := itemTypes[c.Args().Get(1)]
+		id := c.Args().Get(2)
+		if collectionID == "" || !ok || id == "" {
+			log.Fatalf("Usage: org collection add <collection-id> %s", itemTypesUsage)
+		}
+		if route == "binary-data" {
+			log.Fatalf("Binary data cannot be added to a collection")
+		}
+		token := c.String("token")
+
+		item, err := findItem(baseURL, route, id, token)
+		if err != nil {
+			log.Fatalf("Error getting item: %v", err)
+		}
+		col, err := findCollection(baseURL, collectionID, token)
+		if err != nil {
+			log.Fatal(err)
+		}
+		privateKey, err := readPrivateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, err := func() ([]byte, error) {
+			key, err := security.OpenWithPrivateKey(col.Key, privateKey)
+			if err != nil {
+				return nil, err
+			}
+			return security.SealData(item, key)
+		}()
+		if err != nil {
+			log.Fatalf("Error sealing item: %v", err)
+		}
+
+		resp, err := postEncrypted(
+			baseURL, "add-collection-item/"+url.PathEscape(collectionID), token,
+			&models.CollectionItem{ItemType: route, Data: data, KeyVersion: col.KeyVersion},
+		)
+		if err != nil {
+			log.Fatalf("Error adding item to collection: %v", err)
+		}
+		fmt.Printf("Item added to collection %s: %s\n", col.Name, resp)
+		return nil
+	}
+}
+
+// UpdateCollectionItem changes fields of a collection item and seals it
+// again with the collection key.
+func UpdateCollectionItem(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		collectionID, id := c.Args().Get(0), c.Args().Get(1)
+		sets := c.StringSlice("set")
+		if collectionID == "" || id == "" || len(sets) == 0 {
+			log.Fatalf("Usage: org collection update --set field=value <collection-id> <item-id>")
+		}
+		token := c.String("token")
+
+		key, col, items := openCollection(baseURL, collectionID, token)
+		var item *models.CollectionItem
+		for _, it := range items {
+			if strconv.FormatUint(uint64(it.ID), 10) == id {
+				item = it
+			}
+		}
+		if item == nil {
+			log.Fatalf("Item %s not found in collection %s", id, collectionID)
+		}
+
+		data, err := func() ([]byte, error) {
+			data, err := security.OpenData(item.Data, key)
+			if err != nil {
+				return nil, err
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return nil, err
+			}
+			for _, set := range sets {
+				name, value, ok := strings.Cut(set, "=")
+				if !ok || name == "" {
+					return nil, errors.New("--set must be field=value")
+				}
+				fields[name] = value
+			}
+			if data, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+			return security.SealData(data, key)
+		}()
+		if err != nil {
+			log.Fatalf("Error updating collection item: %v", err)
+		}
+
+		resp, err := sendEncrypted(
+			baseURL, "PUT",
+			"update-collection-item/"+url.PathEscape(collectionID)+"/"+url.PathEscape(id), token,
+			&models.CollectionItem{ItemType: item.ItemType, Data: data, KeyVersion: col.KeyVersion},
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error updating collection item: %v", err)
+		}
+		fmt.Printf("Collection item updated: %s\n", resp)
+		return nil
+	}
+}
+
+func DeleteCollectionItem(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		collectionID, id := c.Args().Get(0), c.Args().Get(1)
+		if collectionID == "" || id == "" {
+			log.Fatalf("Usage: org collection delete <collection-id> <item-id>")
+		}
+		resp, err := sendRequest(
+			baseURL, "DELETE",
+			"delete-collection-item/"+url.PathEscape(collectionID)+"/"+url.PathEscape(id),
+			c.String("token"), nil, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error deleting collection item: %v", err)
+		}
+		fmt.Printf("Collection item deleted: %s\n", resp)
+		return nil
+	}
+}
+
+func OrgCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:  "org",
+		Usage: "Manage organizations, their members and shared collections",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "create",
+				Usage:     "Create an organization you own",
+				ArgsUsage: "<name>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    CreateOrg(baseURL),
+			},
+			{
+				Name:   "list",
+				Usage:  "List your organizations and invitations",
+				Flags:  []cli.Flag{getTokenFlag()},
+				Action: ListOrgs(baseURL),
+			},
+			{
+				Name:      "invite",
+				Usage:     "Invite a user, giving them the keys of all collections",
+				ArgsUsage: "<org-id> <user>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "role",
+						Value: models.RoleMember,
+						Usage: "Role of the user: admin, member or read-only",
+					},
+					getTokenFlag(),
+				},
+				Action: InviteMember(baseURL),
+			},
+			{
+				Name:      "accept",
+				Usage:     "Accept an invitation to an organization",
+				ArgsUsage: "<org-id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    AcceptOrg(baseURL),
+			},
+			{
+				Name:      "members",
+				Usage:     "List the members of an organization",
+				ArgsUsage: "<org-id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    ListMembers(baseURL),
+			},
+			{
+				Name:      "remove",
+				Usage:     "Remove a member and rotate the keys of all collections",
+				ArgsUsage: "<org-id> <user>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    RemoveMember(baseURL),
+			},
+			{
+				Name:      "collections",
+				Usage:     "List the collections of an organization",
+				ArgsUsage: "<org-id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    ListCollections(baseURL),
+			},
+			{
+				Name:  "collection",
+				Usage: "Create collections and manage their items",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "create",
+						Usage:     "Create a collection shared by all members",
+						ArgsUsage: "<org-id> <name>",
+						Flags:     []cli.Flag{getTokenFlag()},
+						Action:    CreateCollection(baseURL),
+					},
+					{
+						Name:      "items",
+						Usage:     "List the items of a collection",
+						ArgsUsage: "<collection-id>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "reveal",
+								Usage: "Show the items",
+							},
+							getTokenFlag(),
+						},
+						Action: ListCollectionItems(baseURL),
+					},
+					{
+						Name:      "add",
+						Usage:     "Copy one of your items into a collection",
+						ArgsUsage: "<collection-id> " + itemTypesUsage,
+						Flags:     []cli.Flag{getTokenFlag()},
+						Action:    AddToCollection(baseURL),
+					},
+					{
+						Name:      "update",
+						Usage:     "Change fields of a collection item",
+						ArgsUsage: "<collection-id> <item-id>",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "set",
+								Usage: "Field as name=value, can be repeated",
+							},
+							getTokenFlag(),
+						},
+						Action: UpdateCollectionItem(baseURL),
+					},
+					{
+						Name:      "delete",
+						Usage:     "Delete an item from a collection",
+						ArgsUsage: "<collection-id> <item-id>",
+						Flags:     []cli.Flag{getTokenFlag()},
+						Action:    DeleteCollectionItem(baseURL),
+					},
+				},
+			},
+		},
+	}
+}
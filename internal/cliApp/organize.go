@@ -0,0 +1,509 @@
+package cliApp
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/backup"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/search"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// itemTypes maps item type arguments to routes.
+var itemTypes = map[string]string{
+	"card":           "card",
+	"text":           "text-data",
+	"binary":         "binary-data",
+	"login-password": "login-password",
+	"otp":            "otp",
+	"ssh-key":        "ssh-key",
+	"item":           "item",
+}
+
+// getMetaFlags are the organization flags of every add command.
+func getMetaFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "display-name",
+			Usage: "Name shown in listings",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tag",
+			Usage: "Tag, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "folder",
+			Usage: "Folder path, e.g. Work/Servers",
+		},
+		&cli.BoolFlag{
+			Name:  "favorite",
+			Usage: "Mark as favorite",
+		},
+	}
+}
+
+// getFilterFlags are the filter and sort flags of every list command.
+func getFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "tag",
+			Usage: "Only items with this tag, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "folder",
+			Usage: "Only items in this folder path and its subfolders",
+		},
+		&cli.BoolFlag{
+			Name:  "favorite",
+			Usage: "Only favorites (--favorite=false for the rest)",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "Sort by created, updated or name",
+		},
+		&cli.BoolFlag{
+			Name:  "desc",
+			Usage: "Sort in descending order",
+		},
+		&cli.StringFlag{
+			Name:  "created-after",
+			Usage: "Only items created after a date or RFC 3339 time",
+		},
+		&cli.StringFlag{
+			Name:  "created-before",
+			Usage: "Only items created before a date or RFC 3339 time",
+		},
+		&cli.StringFlag{
+			Name:  "updated-after",
+			Usage: "Only items updated after a date or RFC 3339 time",
+		},
+		&cli.StringFlag{
+			Name:  "updated-before",
+			Usage: "Only items updated before a date or RFC 3339 time",
+		},
+	}
+}
+
+// metaFromFlags builds the ItemMeta of a new item from getMetaFlags.
+func metaFromFlags(c *cli.Context, baseURL string) models.ItemMeta {
+	meta := models.ItemMeta{
+		DisplayName: c.String("display-name"),
+		Favorite:    c.Bool("favorite"),
+		Tags:        c.StringSlice("tag"),
+	}
+	if path := c.String("folder"); path != "" {
+		id, err := resolveFolder(baseURL, c.String("token"), path)
+		if err != nil {
+			log.Fatalf("Error resolving folder: %v", err)
+		}
+		meta.FolderID = &id
+	}
+	return meta
+}
+
+// sealTag seals a tag given on the command line as the server stores it.
+func sealTag(tag string) string {
+	key, err := metaKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sealed, err := search.SealTag(key, tag)
+	if err != nil {
+		log.Fatalf("Error sealing tag: %v", err)
+	}
+	return sealed
+}
+
+// filterQuery adds the filters and sort order set by getFilterFlags to query.
+func filterQuery(c *cli.Context, baseURL string, query url.Values) url.Values {
+	for _, tag := range c.StringSlice("tag") {
+		query.Add("tag", sealTag(tag))
+	}
+	if path := c.String("folder"); path != "" {
+		id, err := resolveFolder(baseURL, c.String("token"), path)
+		if err != nil {
+			log.Fatalf("Error resolving folder: %v", err)
+		}
+		query.Set("folder_id", fmt.Sprint(id))
+	}
+	if c.IsSet("favorite") {
+		query.Set("favorite", fmt.Sprint(c.Bool("favorite")))
+	}
+	if sort := c.String("sort"); sort != "" {
+		query.Set("sort", sort)
+	}
+	if c.Bool("desc") {
+		query.Set("order", "desc")
+	}
+	for _, name := range []string{"created-after", "created-before", "updated-after", "updated-before"} {
+		if v := c.String(name); v != "" {
+			query.Set(strings.ReplaceAll(name, "-", "_"), v)
+		}
+	}
+	return query
+}
+
+// withQuery appends a non-empty query string to endpoint.
+func withQuery(endpoint string, query url.Values) string {
+	if len(query) == 0 {
+		return endpoint
+	}
+	return endpoint + "?" + query.Encode()
+}
+
+// resolveFolder finds the ID of a folder by its path.
+func resolveFolder(baseURL, token, path string) (uint, error) {
+	var folders []*models.Folder
+	if err := fetchList(baseURL, "get-folder", token, &folders); err != nil {
+		return 0, err
+	}
+	path = strings.Trim(path, "/")
+	for id, p := range backup.FolderPaths(folders) {
+		if strings.EqualFold(p, path) {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("folder %q not found", path)
+}
+
+func AddFolder(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		token := c.String("token")
+		folder := models.Folder{Name: c.String("name")}
+		if parent := c.String("parent"); parent != "" {
+			id, err := resolveFolder(baseURL, token, parent)
+			if err != nil {
+				log.Fatalf("Error resolving folder: %v", err)
+			}
+			folder.ParentID = &id
+		}
+		resp, err := postEncrypted(baseURL, "add-folder", token, folder)
+		if err != nil {
+			log.Fatalf("Error adding folder: %v", err)
+		}
+		fmt.Printf("Folder added successfully: %s\n", resp)
+		return nil
+	}
+}
+
+func ListFolders(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var folders []*models.Folder
+		if err := fetchList(baseURL, "get-folder", c.String("token"), &folders); err != nil {
+			log.Fatalf("Error getting folders: %v", err)
+		}
+		paths := backup.FolderPaths(folders)
+		sort.Slice(folders, func(i, j int) bool { return paths[folders[i].ID] < paths[folders[j].ID] })
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tPATH")
+		for _, f := range folders {
+			fmt.Fprintf(tw, "%d\t%s\n", f.ID, paths[f.ID])
+		}
+		return tw.Flush()
+	}
+}
+
+// updateFolder sends change for the folder given by --folder.
+func updateFolder(baseURL string, c *cli.Context, change models.FolderChange) {
+	token := c.String("token")
+	id, err := resolveFolder(baseURL, token, c.String("folder"))
+	if err != nil {
+		log.Fatalf("Error resolving folder: %v", err)
+	}
+	resp, err := sendEncrypted(
+		baseURL, "PUT", fmt.Sprintf("update-folder/%d", id), token, change, http.StatusOK,
+	)
+	if err != nil {
+		log.Fatalf("Error updating folder: %v", err)
+	}
+	fmt.Printf("Folder updated successfully: %s\n", resp)
+}
+
+func RenameFolder(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		name := c.String("name")
+		updateFolder(baseURL, c, models.FolderChange{Name: &name})
+		return nil
+	}
+}
+
+// MoveFolder moves a folder below --parent, or to the top level without it.
+func MoveFolder(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var parentID uint
+		if parent := c.String("parent"); parent != "" {
+			var err error
+			parentID, err = resolveFolder(baseURL, c.String("token"), parent)
+			if err != nil {
+				log.Fatalf("Error resolving folder: %v", err)
+			}
+		}
+		updateFolder(baseURL, c, models.FolderChange{ParentID: &parentID})
+		return nil
+	}
+}
+
+func DeleteFolder(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		token := c.String("token")
+		id, err := resolveFolder(baseURL, token, c.String("folder"))
+		if err != nil {
+			log.Fatalf("Error resolving folder: %v", err)
+		}
+		resp, err := sendRequest(
+			baseURL, "DELETE", fmt.Sprintf("delete-folder/%d", id), token, nil, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error deleting folder: %v", err)
+		}
+		fmt.Printf("Folder deleted successfully: %s\n", resp)
+		return nil
+	}
+}
+
+func FolderCommand(baseURL string) *cli.Command {
+	folderFlag := &cli.StringFlag{
+		Name:     "folder",
+		Usage:    "Folder path, e.g. Work/Servers",
+		Required: true,
+	}
+	parentFlag := &cli.StringFlag{
+		Name:  "parent",
+		Usage: "Parent folder path",
+	}
+	return &cli.Command{
+		Name:  "folder",
+		Usage: "Manage folders",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "add",
+				Usage: "Add a folder",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Aliases:  []string{"n"},
+						Usage:    "Folder name",
+						Required: true,
+					},
+					parentFlag,
+					getTokenFlag(),
+				},
+				Action: AddFolder(baseURL),
+			},
+			{
+				Name:   "list",
+				Usage:  "List folders",
+				Flags:  []cli.Flag{getTokenFlag()},
+				Action: ListFolders(baseURL),
+			},
+			{
+				Name:  "rename",
+				Usage: "Rename a folder",
+				Flags: []cli.Flag{
+					folderFlag,
+					&cli.StringFlag{
+						Name:     "name",
+						Aliases:  []string{"n"},
+						Usage:    "New name",
+						Required: true,
+					},
+					getTokenFlag(),
+				},
+				Action: RenameFolder(baseURL),
+			},
+			{
+				Name:   "move",
+				Usage:  "Move a folder below --parent, or to the top level without it",
+				Flags:  []cli.Flag{folderFlag, parentFlag, getTokenFlag()},
+				Action: MoveFolder(baseURL),
+			},
+			{
+				Name:   "delete",
+				Usage:  "Delete a folder; its contents move to its parent",
+				Flags:  []cli.Flag{folderFlag, getTokenFlag()},
+				Action: DeleteFolder(baseURL),
+			},
+		},
+	}
+}
+
+func ListTags(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var tags []*models.Tag
+		if err := fetchList(baseURL, "get-tag", c.String("token"), &tags); err != nil {
+			log.Fatalf("Error getting tags: %v", err)
+		}
+		key, err := metaKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		names := make([]string, len(tags))
+		for i, tag := range tags {
+			if names[i], err = search.OpenValue(key, tag.Name); err != nil {
+				names[i] = search.Unreadable
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+}
+
+func RenameTag(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		rename := map[string]string{
+			"name":     sealTag(c.String("name")),
+			"new_name": sealTag(c.String("new-name")),
+		}
+		resp, err := sendEncrypted(
+			baseURL, "PUT", "update-tag", c.String("token"), rename, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error renaming tag: %v", err)
+		}
+		fmt.Printf("Tag renamed successfully: %s\n", resp)
+		reindexAfterChange(baseURL, c.String("token"), nil)
+		return nil
+	}
+}
+
+func DeleteTag(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		resp, err := sendRequest(
+			baseURL,
+			"DELETE",
+			"delete-tag/"+url.PathEscape(sealTag(c.String("name"))),
+			c.String("token"),
+			nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error deleting tag: %v", err)
+		}
+		fmt.Printf("Tag deleted successfully: %s\n", resp)
+		reindexAfterChange(baseURL, c.String("token"), nil)
+		return nil
+	}
+}
+
+func TagCommand(baseURL string) *cli.Command {
+	nameFlag := &cli.StringFlag{
+		Name:     "name",
+		Aliases:  []string{"n"},
+		Usage:    "Tag name",
+		Required: true,
+	}
+	return &cli.Command{
+		Name:  "tag",
+		Usage: "Manage tags",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List tags",
+				Flags:  []cli.Flag{getTokenFlag()},
+				Action: ListTags(baseURL),
+			},
+			{
+				Name:  "rename",
+				Usage: "Rename a tag on all items",
+				Flags: []cli.Flag{
+					nameFlag,
+					&cli.StringFlag{
+						Name:     "new-name",
+						Usage:    "New tag name",
+						Required: true,
+					},
+					getTokenFlag(),
+				},
+				Action: RenameTag(baseURL),
+			},
+			{
+				Name:   "delete",
+				Usage:  "Remove a tag from all items",
+				Flags:  []cli.Flag{nameFlag, getTokenFlag()},
+				Action: DeleteTag(baseURL),
+			},
+		},
+	}
+}
+
+// Organize changes only the metadata given by flags; --folder "" moves the
+// item out of any folder and --tag "" clears its tags.
+func Organize(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		route, id := itemArgs(c, "organize")
+		token := c.String("token")
+
+		var change models.MetaChange
+		if c.IsSet("display-name") {
+			name := c.String("display-name")
+			change.DisplayName = &name
+		}
+		if c.IsSet("favorite") {
+			favorite := c.Bool("favorite")
+			change.Favorite = &favorite
+		}
+		if c.IsSet("tag") {
+			var tags []string
+			for _, tag := range c.StringSlice("tag") {
+				if tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			change.Tags = &tags
+		}
+		if c.IsSet("folder") {
+			var folderID uint
+			if path := c.String("folder"); path != "" {
+				var err error
+				folderID, err = resolveFolder(baseURL, token, path)
+				if err != nil {
+					log.Fatalf("Error resolving folder: %v", err)
+				}
+			}
+			change.FolderID = &folderID
+		}
+
+		resp, err := sendEncrypted(
+			baseURL, "PUT", "organize-"+route+"/"+url.PathEscape(id), token, change, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error organizing item: %v", err)
+		}
+		fmt.Printf("Item organized successfully: %s\n", resp)
+		changed := func(r string, itemID uint) bool {
+			return r == route && fmt.Sprint(itemID) == id
+		}
+		if change.DisplayName != nil || change.Tags != nil {
+			reindexAfterChange(baseURL, token, changed)
+		}
+		syncSharesAfterChange(baseURL, token, changed)
+		return nil
+	}
+}
+
+// reindexAfterChange updates the search index, only warning on failure.
+func reindexAfterChange(baseURL, token string, only func(route string, id uint) bool) {
+	if _, err := reindex(baseURL, token, only); err != nil {
+		log.Printf("Warning: search index not updated, run reindex: %v", err)
+	}
+}
+
+func OrganizeCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "organize",
+		Usage:     "Change the display name, folder, favorite flag or tags of an item",
+		ArgsUsage: itemTypesUsage,
+		Flags:     append(getMetaFlags(), getTokenFlag()),
+		Action:    Organize(baseURL),
+	}
+}
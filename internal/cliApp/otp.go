@@ -0,0 +1,195 @@
+package cliApp
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/otp"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/url"
+	"time"
+)
+
+func getAddOTPFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "uri",
+			Usage: "otpauth:// URI, e.g. decoded from a QR code",
+		},
+		&cli.StringFlag{
+			Name:    "issuer",
+			Aliases: []string{"i"},
+			Usage:   "Issuer, e.g. GitHub",
+		},
+		&cli.StringFlag{
+			Name:    "account",
+			Aliases: []string{"a"},
+			Usage:   "Account name",
+		},
+		&cli.StringFlag{
+			Name:    "secret",
+			Aliases: []string{"s"},
+			Usage:   "Base32 secret",
+		},
+		&cli.StringFlag{
+			Name:  "algorithm",
+			Usage: "SHA1, SHA256 or SHA512",
+			Value: otp.AlgorithmSHA1,
+		},
+		&cli.IntFlag{
+			Name:  "digits",
+			Usage: "Code length",
+			Value: otp.DefaultDigits,
+		},
+		&cli.IntFlag{
+			Name:  "period",
+			Usage: "Code period in seconds",
+			Value: otp.DefaultPeriod,
+		},
+		&cli.UintFlag{
+			Name:    "login-password-id",
+			Aliases: []string{"lp"},
+			Usage:   "ID of the login-password entry to link",
+		},
+		&cli.StringFlag{
+			Name:     "metadata",
+			Aliases:  []string{"m"},
+			Usage:    "Metadata",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "token",
+			Aliases:  []string{"t"},
+			Usage:    "Token for Authorization",
+			Required: true,
+		},
+	}
+}
+
+func AddOTP(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var secret *models.OTPSecret
+		if uri := c.String("uri"); uri != "" {
+			parsed, err := otp.ParseURI(uri)
+			if err != nil {
+				log.Fatalf("Error parsing URI: %v", err)
+			}
+			secret = parsed
+		} else {
+			secret = &models.OTPSecret{
+				Issuer:    c.String("issuer"),
+				Account:   c.String("account"),
+				Secret:    c.String("secret"),
+				Algorithm: c.String("algorithm"),
+				Digits:    c.Int("digits"),
+				Period:    c.Int("period"),
+			}
+			otp.Normalize(secret)
+			if err := otp.Validate(secret); err != nil {
+				log.Fatalf("Invalid OTP secret: %v", err)
+			}
+		}
+		if id := c.Uint("login-password-id"); id != 0 {
+			secret.LoginPasswordID = &id
+		}
+		secret.Metadata = c.String("metadata")
+		secret.ItemMeta = metaFromFlags(c, baseURL)
+
+		resp, err := postEncrypted(baseURL, "add-otp", c.String("token"), secret)
+		if err != nil {
+			log.Fatalf("Error adding OTP secret: %v", err)
+		}
+		fmt.Printf("OTP secret added successfully: %s\n", resp)
+		return nil
+	}
+}
+
+func AddOTPCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:   "add-otp",
+		Usage:  "Add a TOTP authenticator secret",
+		Flags:  append(getAddOTPFlags(), getMetaFlags()...),
+		Action: AddOTP(baseURL),
+	}
+}
+
+func getOTPFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "token",
+			Aliases:  []string{"t"},
+			Usage:    "Token for Authorization",
+			Required: true,
+		},
+	}
+}
+
+func GetOTP(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		data, err := fetchDecrypted(
+			baseURL, withQuery("get-otp", filterQuery(c, baseURL, url.Values{})), c.String("token"),
+		)
+		if err != nil {
+			log.Fatalf("Error getting OTP secrets: %v", err)
+		}
+		fmt.Printf("Decrypted Data: %s\n", string(data))
+		return nil
+	}
+}
+
+func GetOTPCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:   "get-otp",
+		Usage:  "Get TOTP authenticator secrets",
+		Flags:  append(getOTPFlags(), getFilterFlags()...),
+		Action: GetOTP(baseURL),
+	}
+}
+
+func OTP(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		name := c.Args().First()
+		if name == "" {
+			log.Fatalf("Usage: otp <name>")
+		}
+
+		var secrets []*models.OTPSecret
+		if err := fetchList(baseURL, "get-otp", c.String("token"), &secrets); err != nil {
+			log.Fatalf("Error getting OTP secrets: %v", err)
+		}
+
+		now := time.Now()
+		found := false
+		for _, s := range secrets {
+			if !otp.Matches(s, name) {
+				continue
+			}
+			code, err := otp.GenerateCode(s, now)
+			if err != nil {
+				log.Fatalf("Error generating code for %s:%s: %v", s.Issuer, s.Account, err)
+			}
+			fmt.Printf(
+				"%s:%s  %s  (%ds remaining)\n",
+				s.Issuer,
+				s.Account,
+				code,
+				int(otp.Remaining(s, now).Seconds()),
+			)
+			found = true
+		}
+		if !found {
+			return cli.Exit(fmt.Sprintf("No OTP secret matches %q", name), 1)
+		}
+		return nil
+	}
+}
+
+func OTPCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "otp",
+		Usage:     "Print the current TOTP code",
+		ArgsUsage: "<issuer|account|issuer:account>",
+		Flags:     getOTPFlags(),
+		Action:    OTP(baseURL),
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cliApp
+
+import "os"
+
+// ownedByUser cannot tell the owner of a file here, so it trusts every one.
+func ownedByUser(info os.FileInfo) bool {
+	return true
+}
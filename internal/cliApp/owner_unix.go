@@ -0,0 +1,14 @@
+//go:build unix
+
+package cliApp
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByUser reports whether info belongs to the current user.
+func ownedByUser(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
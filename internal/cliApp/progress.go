@@ -0,0 +1,67 @@
+package cliApp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const progressWidth = 30
+
+// progress draws a one-line progress bar of a transfer of total bytes.
+type progress struct {
+	w     io.Writer
+	label string
+	total int64
+	done  int64
+}
+
+func newProgress(label string, total int64) *progress {
+	p := &progress{w: os.Stderr, label: label, total: total}
+	p.draw()
+	return p
+}
+
+// Add records n more bytes as transferred.
+func (p *progress) Add(n int64) {
+	p.done += n
+	p.draw()
+}
+
+// Finish ends the progress line.
+func (p *progress) Finish() {
+	fmt.Fprintln(p.w)
+}
+
+func (p *progress) draw() {
+	ratio := 1.0
+	if p.total > 0 {
+		ratio = float64(p.done) / float64(p.total)
+	}
+	filled := int(ratio * progressWidth)
+	fmt.Fprintf(
+		p.w,
+		"\r%s [%s%s] %3.0f%% %s/%s",
+		p.label,
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", progressWidth-filled),
+		ratio*100,
+		byteSize(p.done),
+		byteSize(p.total),
+	)
+}
+
+// byteSize formats n bytes with a binary unit.
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
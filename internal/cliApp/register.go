@@ -1,11 +1,13 @@
 package cliApp
 
 import (
+	"errors"
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/elina-chertova/auth-keeper.git/internal/sender"
 	"github.com/urfave/cli/v2"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -50,6 +52,14 @@ func registerUser(baseURL string) func(c *cli.Context) error {
 			log.Fatalf("Error saving personal key to file: %v", err)
 		}
 
+		publicKey, err := writeKeyPair()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeClientKey(); err != nil && !errors.Is(err, fs.ErrExist) {
+			log.Fatal(err)
+		}
+
 		encryptedPersonalKey, err := security.EncryptPersonalKey(personalKey)
 		if err != nil {
 			log.Fatalf("Error encrypting personal key: %v", err)
@@ -60,6 +70,7 @@ func registerUser(baseURL string) func(c *cli.Context) error {
 			Password:    password,
 			Email:       email,
 			PersonalKey: encryptedPersonalKey,
+			PublicKey:   publicKey,
 		}
 
 		client := sender.NewClient(baseURL)
@@ -0,0 +1,387 @@
+package cliApp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/search"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// searchResults is the decrypted body of GET search.
+type searchResults struct {
+	LoginPasswords []*models.LoginPassword `json:"login-password"`
+	CreditCards    []*models.CreditCard    `json:"card"`
+	TextData       []*models.TextData      `json:"text-data"`
+	BinaryData     []*models.BinaryData    `json:"binary-data"`
+}
+
+type searchIndexEntry struct {
+	Type   string   `json:"type"`
+	ID     uint     `json:"id"`
+	Tokens []string `json:"tokens"`
+}
+
+// clientKeyFile holds the client key, which never leaves the device.
+const clientKeyFile = "ckey.txt"
+
+// writeClientKey creates the client key if there is none.
+func writeClientKey() error {
+	key, err := security.NewItemKey()
+	if err != nil {
+		return fmt.Errorf("error generating client key: %w", err)
+	}
+	file, err := os.OpenFile(clientKeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("error saving client key: %w", err)
+	}
+	_, err = file.Write(key)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error saving client key: %w", err)
+	}
+	return nil
+}
+
+func readClientKey() ([]byte, error) {
+	key, err := os.ReadFile(clientKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error reading client key, copy %s from the device you registered on or run reindex to create it: %w",
+			clientKeyFile, err,
+		)
+	}
+	return key, nil
+}
+
+func searchKey() ([]byte, error) {
+	clientKey, err := readClientKey()
+	if err != nil {
+		return nil, err
+	}
+	return search.DeriveKey(clientKey)
+}
+
+// metaKey is the key of the display names and tags of the user's items.
+func metaKey() ([]byte, error) {
+	clientKey, err := readClientKey()
+	if err != nil {
+		return nil, err
+	}
+	return search.DeriveMetaKey(clientKey)
+}
+
+// indexItem fills the blind index of a new item from its searchable fields.
+func indexItem(item interface{ Meta() *models.ItemMeta }) {
+	key, err := searchKey()
+	if err != nil {
+		log.Fatalf("Error deriving search key: %v", err)
+	}
+	item.Meta().SearchTokens = search.IndexTokens(key, search.Fields(item)...)
+}
+
+// reindex uploads the blind index of the items for which only returns true.
+func reindex(baseURL, token string, only func(route string, id uint) bool) (int, error) {
+	key, err := searchKey()
+	if err != nil {
+		return 0, err
+	}
+
+	var entries []searchIndexEntry
+	unreadable := 0
+	add := func(route string, id uint, item interface{}) {
+		if only != nil && !only(route, id) {
+			return
+		}
+		fields := search.Fields(item)
+		for _, field := range fields {
+			if field == search.Unreadable {
+				unreadable++
+				return
+			}
+		}
+		entries = append(
+			entries, searchIndexEntry{Type: route, ID: id, Tokens: search.IndexTokens(key, fields...)},
+		)
+	}
+
+	var logins []*models.LoginPassword
+	if err := fetchList(baseURL, "get-login-password", token, &logins); err != nil {
+		return 0, err
+	}
+	for _, it := range logins {
+		add("login-password", it.ID, it)
+	}
+	var cards []*models.CreditCard
+	if err := fetchList(baseURL, "get-card", token, &cards); err != nil {
+		return 0, err
+	}
+	for _, it := range cards {
+		add("card", it.ID, it)
+	}
+	var texts []*models.TextData
+	if err := fetchList(baseURL, "get-text-data", token, &texts); err != nil {
+		return 0, err
+	}
+	for _, it := range texts {
+		add("text-data", it.ID, it)
+	}
+	var binaries []*models.BinaryData
+	if err := fetchList(baseURL, "get-binary-data", token, &binaries); err != nil {
+		return 0, err
+	}
+	for _, it := range binaries {
+		add("binary-data", it.ID, it)
+	}
+
+	// Such items were sealed with another client key, so this one is
+	// likely the wrong one to build the index with.
+	if unreadable > 0 {
+		return 0, fmt.Errorf("%d items cannot be opened with %s", unreadable, clientKeyFile)
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	if _, err := sendEncrypted(baseURL, "PUT", "search-index", token, entries, http.StatusOK); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// sealStoredMeta seals display names and tags stored before sealing existed.
+func sealStoredMeta(baseURL, token string) (int, error) {
+	key, err := metaKey()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, route := range itemTypes {
+		data, err := fetchJoined(baseURL, "get-"+route, token)
+		if err != nil {
+			return n, err
+		}
+		var items []struct {
+			ID uint `json:"ID"`
+			models.ItemMeta
+		}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return n, fmt.Errorf("error unmarshalling decrypted data: %w", err)
+		}
+		for _, it := range items {
+			stored := it.DisplayName != "" && !search.IsSealed(it.DisplayName)
+			for _, tag := range it.Tags {
+				stored = stored || !search.IsSealed(tag)
+			}
+			if !stored {
+				continue
+			}
+			// Sealed ones are opened for sendEncrypted to seal them all.
+			name, err := search.OpenValue(key, it.DisplayName)
+			tags := make([]string, len(it.Tags))
+			for i, tag := range it.Tags {
+				if err == nil {
+					tags[i], err = search.OpenValue(key, tag)
+				}
+			}
+			if err != nil {
+				log.Printf("Warning: %s %d skipped: %v", route, it.ID, err)
+				continue
+			}
+			_, err = sendEncrypted(
+				baseURL, "PUT", fmt.Sprintf("organize-%s/%d", route, it.ID), token,
+				models.MetaChange{DisplayName: &name, Tags: &tags}, http.StatusOK,
+			)
+			if err != nil {
+				return n, fmt.Errorf("error sealing %s %d: %w", route, it.ID, err)
+			}
+			n++
+		}
+	}
+
+	data, err := fetchJoined(baseURL, "get-tag", token)
+	if err != nil {
+		return n, err
+	}
+	var tags []*models.Tag
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return n, fmt.Errorf("error unmarshalling decrypted data: %w", err)
+	}
+	for _, tag := range tags {
+		if search.IsSealed(tag.Name) {
+			continue
+		}
+		_, err := sendRequest(
+			baseURL, "DELETE", "delete-tag/"+url.PathEscape(tag.Name), token, nil, http.StatusOK,
+		)
+		if err != nil {
+			return n, fmt.Errorf("error deleting unsealed tag: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// Reindex rebuilds the search index. It creates the client key of a user
+// who has none yet and seals the display names and tags stored before they
+// were sealed.
+func Reindex(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		token := c.String("token")
+		if _, err := os.Stat(clientKeyFile); errors.Is(err, fs.ErrNotExist) {
+			if err := writeClientKey(); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Client key created and saved to %s; copy it to your other devices\n", clientKeyFile)
+		}
+		sealed, err := sealStoredMeta(baseURL, token)
+		if err != nil {
+			log.Fatalf("Error sealing display names and tags: %v", err)
+		}
+		if sealed > 0 {
+			fmt.Printf("Display names and tags sealed for %d items\n", sealed)
+		}
+		n, err := reindex(baseURL, token, nil)
+		if err != nil {
+			log.Fatalf("Error rebuilding search index: %v", err)
+		}
+		fmt.Printf("Search index rebuilt for %d items\n", n)
+		return nil
+	}
+}
+
+func ReindexCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:   "reindex",
+		Usage:  "Rebuild the search index of all logins, cards, text and binary data and seal display names and tags",
+		Flags:  []cli.Flag{getTokenFlag()},
+		Action: Reindex(baseURL),
+	}
+}
+
+func mask(secret string, reveal bool) string {
+	if reveal {
+		return secret
+	}
+	return "********"
+}
+
+func cardNumber(number string, reveal bool) string {
+	if reveal || len(number) <= 4 {
+		return number
+	}
+	return "**** " + number[len(number)-4:]
+}
+
+// Search sends the blind index tokens of the query and prints the
+// decrypted matches. Matches are checked again locally because prefixes
+// longer than search.MaxPrefix are cut in the index.
+func Search(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		q := strings.Join(c.Args().Slice(), " ")
+		if q == "" {
+			log.Fatalf("Usage: search <query>")
+		}
+		prefix := !c.Bool("exact")
+		reveal := c.Bool("reveal")
+
+		key, err := searchKey()
+		if err != nil {
+			log.Fatalf("Error deriving search key: %v", err)
+		}
+		tokens, err := search.QueryTokens(key, q, prefix)
+		if err != nil {
+			log.Fatalf("Error building query: %v", err)
+		}
+		query := url.Values{"t": tokens}
+
+		data, err := fetchDecrypted(baseURL, withQuery("search", query), c.String("token"))
+		if err != nil {
+			log.Fatalf("Error searching: %v", err)
+		}
+		var results searchResults
+		if err := json.Unmarshal(data, &results); err != nil {
+			log.Fatalf("Error unmarshalling decrypted data: %v", err)
+		}
+
+		matches := func(item interface{}) bool {
+			return search.Matches(q, prefix, search.Fields(item)...)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		found := 0
+		for _, it := range results.LoginPasswords {
+			if matches(it) {
+				found++
+				fmt.Fprintf(
+					tw, "login-password\t%d\t%s\t%s\t%s\t%s\t%s\n",
+					it.ID, it.DisplayName, it.Login, mask(it.Password, reveal), it.URL,
+					strings.Join(it.Tags, ","),
+				)
+			}
+		}
+		for _, it := range results.CreditCards {
+			if matches(it) {
+				found++
+				fmt.Fprintf(
+					tw, "card\t%d\t%s\t%s\t%s\t%s\t%s\n",
+					it.ID, it.DisplayName, it.CardHolder, cardNumber(it.CardNumber, reveal), it.Brand,
+					strings.Join(it.Tags, ","),
+				)
+			}
+		}
+		for _, it := range results.TextData {
+			if matches(it) {
+				found++
+				fmt.Fprintf(
+					tw, "text\t%d\t%s\t%s\t\t\t%s\n",
+					it.ID, it.DisplayName, mask(it.Content, reveal), strings.Join(it.Tags, ","),
+				)
+			}
+		}
+		for _, it := range results.BinaryData {
+			if matches(it) {
+				found++
+				fmt.Fprintf(
+					tw, "binary\t%d\t%s\t%s\t%s\t\t%s\n",
+					it.ID, it.DisplayName, it.FileName, byteSize(it.Size), strings.Join(it.Tags, ","),
+				)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if found == 0 {
+			fmt.Println("No matches")
+		}
+		return nil
+	}
+}
+
+func SearchCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "search",
+		Usage:     "Search logins, cards, text and binary data by name, tags, URL and login",
+		ArgsUsage: "<query>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "exact",
+				Usage: "Match whole words only instead of word prefixes",
+			},
+			&cli.BoolFlag{
+				Name:  "reveal",
+				Usage: "Show passwords, card numbers and text content",
+			},
+			getTokenFlag(),
+		},
+		Action: Search(baseURL),
+	}
+}
@@ -0,0 +1,255 @@
+package cliApp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/elina-chertova/auth-keeper.git/internal/sender"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// sendContent is the text or file a send seals.
+type sendContent struct {
+	Name string `json:"name,omitempty"`
+	Data []byte `json:"data"`
+}
+
+// CreateSend seals a text or a file with a new key and prints a link to
+// it. The key is only in the fragment of the link, so the server cannot
+// open the send.
+func CreateSend(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		text, file := c.String("text"), c.String("file")
+		if (text == "") == (file == "") {
+			log.Fatalf("Usage: send create --text <text> | --file <path> --token <token>")
+		}
+		content := sendContent{Data: []byte(text)}
+		if file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				log.Fatalf("Error reading file: %v", err)
+			}
+			content = sendContent{Name: filepath.Base(file), Data: data}
+		}
+
+		key, err := security.NewItemKey()
+		if err != nil {
+			log.Fatalf("Error creating send key: %v", err)
+		}
+		data, err := func() ([]byte, error) {
+			plain, err := json.Marshal(content)
+			if err != nil {
+				return nil, err
+			}
+			return security.SealData(plain, key)
+		}()
+		if err != nil {
+			log.Fatalf("Error sealing send: %v", err)
+		}
+
+		req := models.NewSend{
+			Data:      data,
+			MaxViews:  c.Int("max-views"),
+			ExpiresAt: time.Now().Add(c.Duration("expires")),
+			Password:  c.String("password"),
+		}
+		resp, err := sendRequest(baseURL, "POST", "", c.String("token"), req, http.StatusCreated)
+		if err != nil {
+			log.Fatalf("Error creating send: %v", err)
+		}
+		var created struct {
+			ID        string    `json:"id"`
+			MaxViews  int       `json:"max_views"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if err := json.Unmarshal([]byte(resp), &created); err != nil {
+			log.Fatalf("Error unmarshalling response: %v", err)
+		}
+		fmt.Printf(
+			"%s/%s#%s\nCan be opened %d time(s) until %s\n",
+			baseURL, created.ID, base64.RawURLEncoding.EncodeToString(key),
+			created.MaxViews, created.ExpiresAt.Local().Format(time.DateTime),
+		)
+		return nil
+	}
+}
+
+// ReceiveSend gets a send by its link and opens it with the key in the
+// fragment. Texts are printed, files saved; --out writes either to a file.
+// Receiving counts as a view, the last allowed one deletes the send.
+func ReceiveSend() func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		link := c.Args().Get(0)
+		if link == "" {
+			log.Fatalf("Usage: send receive [--password <password>] [--out <path>] <url>")
+		}
+		u, err := url.Parse(link)
+		if err != nil || u.Fragment == "" {
+			log.Fatalf("Invalid send link, the key after # is missing")
+		}
+		key, err := base64.RawURLEncoding.DecodeString(u.Fragment)
+		if err != nil {
+			log.Fatalf("Invalid send key: %v", err)
+		}
+		u.Fragment = ""
+
+		headers := map[string]string{}
+		if password := c.String("password"); password != "" {
+			headers[models.SendPasswordHeader] = password
+		}
+		resp, err := sender.NewClient(u.String()).SendWithHeaders("GET", "", nil, headers, "")
+		if err != nil {
+			log.Fatalf("Error receiving send: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("Error receiving send, status code: %d, response: %s", resp.StatusCode, resp.String())
+		}
+		var received struct {
+			Data      []byte `json:"data"`
+			ViewsLeft int    `json:"views_left"`
+		}
+		if err := json.Unmarshal(resp.Bytes(), &received); err != nil {
+			log.Fatalf("Error unmarshalling send: %v", err)
+		}
+
+		var content sendContent
+		err = func() error {
+			plain, err := security.OpenData(received.Data, key)
+			if err != nil {
+				return errors.New("the key in the link does not open it")
+			}
+			return json.Unmarshal(plain, &content)
+		}()
+		if err != nil {
+			log.Fatalf("Error opening send: %v", err)
+		}
+
+		// The name comes from whoever created the link; only its base is
+		// used, so that it cannot point outside the working directory.
+		out := c.String("out")
+		if out == "" && content.Name != "" {
+			out = filepath.Base(content.Name)
+			if out == "." || out == ".." || out == string(filepath.Separator) {
+				log.Fatalf("The send names its file %q, save it with --out", content.Name)
+			}
+		}
+		if out == "" {
+			fmt.Println(string(content.Data))
+		} else {
+			if err := saveSend(out, content.Data); err != nil {
+				log.Fatalf("Error saving send: %v", err)
+			}
+			fmt.Printf("Saved to %s\n", out)
+		}
+		fmt.Fprintf(os.Stderr, "Views left: %d\n", received.ViewsLeft)
+		return nil
+	}
+}
+
+// DeleteSend deletes a send of the user, given its link or access ID.
+func DeleteSend(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		link := c.Args().Get(0)
+		if link == "" {
+			log.Fatalf("Usage: send delete --token <token> <url | id>")
+		}
+		id := link
+		if u, err := url.Parse(link); err == nil {
+			id = path.Base(u.Path)
+		}
+		if _, err := sendRequest(baseURL, "DELETE", "/"+id, c.String("token"), nil, http.StatusOK); err != nil {
+			log.Fatalf("Error deleting send: %v", err)
+		}
+		fmt.Println("Send has been deleted")
+		return nil
+	}
+}
+
+// saveSend writes data to out, which must not exist.
+func saveSend(out string, data []byte) error {
+	file, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, choose another file with --out", out)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func SendCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:  "send",
+		Usage: "Hand a text or a file to anyone through a one-time link",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "create",
+				Usage: "Create a link to a text or a file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "text",
+						Usage: "Text to send",
+					},
+					&cli.StringFlag{
+						Name:  "file",
+						Usage: "File to send",
+					},
+					&cli.IntFlag{
+						Name:  "max-views",
+						Value: 1,
+						Usage: "Number of times the link can be opened",
+					},
+					&cli.DurationFlag{
+						Name:  "expires",
+						Value: 24 * time.Hour,
+						Usage: "Time after which the link expires, at most 720h",
+					},
+					&cli.StringFlag{
+						Name:  "password",
+						Usage: "Password needed to open the link",
+					},
+					getTokenFlag(),
+				},
+				Action: CreateSend(baseURL),
+			},
+			{
+				Name:      "receive",
+				Usage:     "Open a link, without an account",
+				ArgsUsage: "<url>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "password",
+						Usage: "Password of the link",
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "New file to save the content to, by default the name of the sent file",
+					},
+				},
+				Action: ReceiveSend(),
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete a link you created",
+				ArgsUsage: "<url | id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    DeleteSend(baseURL),
+			},
+		},
+	}
+}
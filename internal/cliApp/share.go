@@ -0,0 +1,616 @@
+package cliApp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// privateKeyFile holds the X25519 private key for opening shares.
+const privateKeyFile = "xkey.txt"
+
+// writeKeyPair saves a new private key and returns its public key.
+func writeKeyPair() ([]byte, error) {
+	publicKey, privateKey, err := security.GenerateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("error generating key pair: %w", err)
+	}
+	if err := os.WriteFile(privateKeyFile, privateKey, 0600); err != nil {
+		return nil, fmt.Errorf("error saving private key: %w", err)
+	}
+	return publicKey, nil
+}
+
+func readPrivateKey() ([]byte, error) {
+	privateKey, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading private key, run keypair first: %w", err)
+	}
+	return privateKey, nil
+}
+
+// KeyPair creates a key pair and gives its public key to the server.
+func KeyPair(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if _, err := os.Stat(privateKeyFile); err == nil && !c.Bool("force") {
+			log.Fatalf(
+				"%s already exists; pass --force to replace it, items shared with you will be lost",
+				privateKeyFile,
+			)
+		}
+		publicKey, err := writeKeyPair()
+		if err != nil {
+			log.Fatal(err)
+		}
+		resp, err := sendRequest(
+			baseURL, "PUT", "public-key", c.String("token"),
+			map[string][]byte{"public_key": publicKey}, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error setting public key: %v", err)
+		}
+		fmt.Printf("Key pair created, private key saved to %s: %s\n", privateKeyFile, resp)
+		return nil
+	}
+}
+
+// findItem returns the JSON of one of the user's items of a route.
+func findItem(baseURL, route, id, token string) (json.RawMessage, error) {
+	var items []json.RawMessage
+	if err := fetchList(baseURL, "get-"+route, token, &items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		var head struct {
+			ID uint `json:"ID"`
+		}
+		if err := json.Unmarshal(item, &head); err != nil {
+			return nil, err
+		}
+		if strconv.FormatUint(uint64(head.ID), 10) == id {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s not found", route, id)
+}
+
+// ShareItem seals an item with a new item key and shares it with a user.
+// The item key is sealed to the public keys of the recipient and of the
+// owner, so both can open the share but the server cannot.
+func ShareItem(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		route, id := itemArgs(c, "share")
+		recipient := c.String("with")
+		if recipient == "" || c.String("token") == "" {
+			log.Fatalf("Usage: share %s --with <user> --token <token>", itemTypesUsage)
+		}
+		if route == "binary-data" {
+			log.Fatalf("Binary data cannot be shared")
+		}
+		token := c.String("token")
+
+		privateKey, err := readPrivateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		ownerKey, err := security.PublicKey(privateKey)
+		if err != nil {
+			log.Fatalf("Error reading private key: %v", err)
+		}
+		resp, err := sendRequest(
+			baseURL, "GET", "get-public-key/"+url.PathEscape(recipient), token, nil, http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error getting public key of %s: %v", recipient, err)
+		}
+		var recipientKey struct {
+			PublicKey []byte `json:"public_key"`
+		}
+		if err := json.Unmarshal([]byte(resp), &recipientKey); err != nil {
+			log.Fatalf("Error unmarshalling public key: %v", err)
+		}
+		item, err := findItem(baseURL, route, id, token)
+		if err != nil {
+			log.Fatalf("Error getting item: %v", err)
+		}
+
+		itemID, _ := strconv.ParseUint(id, 10, 64)
+		share := &models.Share{
+			RecipientID: recipient,
+			ItemType:    route,
+			ItemID:      uint(itemID),
+			Permission:  models.PermissionRead,
+		}
+		if c.Bool("editable") {
+			share.Permission = models.PermissionEdit
+		}
+		err = func() error {
+			itemKey, err := security.NewItemKey()
+			if err != nil {
+				return err
+			}
+			if share.Data, err = security.SealData(item, itemKey); err != nil {
+				return err
+			}
+			if share.OwnerKey, err = security.SealToPublicKey(itemKey, ownerKey); err != nil {
+				return err
+			}
+			share.RecipientKey, err = security.SealToPublicKey(itemKey, recipientKey.PublicKey)
+			return err
+		}()
+		if err != nil {
+			log.Fatalf("Error sealing item: %v", err)
+		}
+
+		resp, err = postEncrypted(baseURL, "add-share", token, share)
+		if err != nil {
+			log.Fatalf("Error sharing item: %v", err)
+		}
+		fmt.Printf("Item shared with %s: %s\n", recipient, resp)
+		return nil
+	}
+}
+
+// openShare returns the item key and item JSON of a share.
+func openShare(share *models.Share, privateKey []byte, owner bool) ([]byte, []byte, error) {
+	sealedKey := share.RecipientKey
+	if owner {
+		sealedKey = share.OwnerKey
+	}
+	itemKey, err := security.OpenWithPrivateKey(sealedKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	item, err := security.OpenData(share.Data, itemKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return itemKey, item, nil
+}
+
+// displayName is the display name or name of an item's JSON, if any.
+func displayName(item []byte) string {
+	var meta struct {
+		DisplayName string `json:"display_name"`
+		Name        string `json:"name"`
+	}
+	_ = json.Unmarshal(item, &meta)
+	if meta.DisplayName != "" {
+		return meta.DisplayName
+	}
+	return meta.Name
+}
+
+// shareName is the display name of a shared item, or its type and ID.
+func shareName(share *models.Share, item []byte) string {
+	if name := displayName(item); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s %d", share.ItemType, share.ItemID)
+}
+
+// ListShares lists the items the user shares; --reveal also prints them as
+// last sealed, by the user or an editing recipient.
+func ListShares(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		return printShares(
+			c, baseURL, "get-share", true,
+			"ID\tTYPE\tITEM\tNAME\tRECIPIENT\tPERMISSION\tUPDATED BY",
+			func(s *models.Share, name string) []interface{} {
+				return []interface{}{s.ID, s.ItemType, s.ItemID, name, s.RecipientID, s.Permission, s.UpdatedBy}
+			},
+		)
+	}
+}
+
+func RevokeShare(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		id := c.Args().Get(0)
+		if id == "" {
+			log.Fatalf("Usage: share revoke <share-id>")
+		}
+		resp, err := sendRequest(
+			baseURL, "DELETE", "delete-share/"+url.PathEscape(id), c.String("token"), nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error revoking share: %v", err)
+		}
+		fmt.Printf("Share revoked: %s\n", resp)
+		return nil
+	}
+}
+
+// SharedWithMe lists the items other users share with the user.
+func SharedWithMe(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.String("token") == "" {
+			log.Fatalf("Usage: shared-with-me --token <token>")
+		}
+		return printShares(
+			c, baseURL, "get-shared-with-me", false,
+			"ID\tOWNER\tTYPE\tNAME\tPERMISSION",
+			func(s *models.Share, name string) []interface{} {
+				return []interface{}{s.ID, s.OwnerID, s.ItemType, name, s.Permission}
+			},
+		)
+	}
+}
+
+// printShares prints the shares listed at endpoint.
+func printShares(
+	c *cli.Context,
+	baseURL, endpoint string,
+	owner bool,
+	header string,
+	row func(s *models.Share, name string) []interface{},
+) error {
+	var shares []*models.Share
+	if err := fetchList(baseURL, endpoint, c.String("token"), &shares); err != nil {
+		log.Fatalf("Error getting shares: %v", err)
+	}
+	privateKey, err := readPrivateKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, header)
+	var revealed []string
+	for _, s := range shares {
+		name := "?"
+		if _, item, err := openShare(s, privateKey, owner); err == nil {
+			name = shareName(s, item)
+			if c.Bool("reveal") {
+				revealed = append(revealed, fmt.Sprintf("#%d %s", s.ID, indentJSON(item)))
+			}
+		}
+		cells := row(s, name)
+		fmt.Fprintf(tw, strings.Repeat("%v\t", len(cells)-1)+"%v\n", cells...)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, item := range revealed {
+		fmt.Println(item)
+	}
+	return nil
+}
+
+func indentJSON(data []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	indented, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(data)
+	}
+	return string(indented)
+}
+
+// UpdateShared changes fields of an editable item shared with the user and
+// seals it again with the same item key.
+func UpdateShared(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		id := c.Args().Get(0)
+		sets := c.StringSlice("set")
+		if id == "" || len(sets) == 0 {
+			log.Fatalf("Usage: shared-with-me update <share-id> --set field=value")
+		}
+		token := c.String("token")
+
+		var shares []*models.Share
+		if err := fetchList(baseURL, "get-shared-with-me", token, &shares); err != nil {
+			log.Fatalf("Error getting shared items: %v", err)
+		}
+		var share *models.Share
+		for _, s := range shares {
+			if strconv.FormatUint(uint64(s.ID), 10) == id {
+				share = s
+			}
+		}
+		if share == nil {
+			log.Fatalf("Share %s not found", id)
+		}
+		if share.Permission != models.PermissionEdit {
+			log.Fatalf("Share %s is read-only", id)
+		}
+		privateKey, err := readPrivateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		data, err := func() ([]byte, error) {
+			itemKey, item, err := openShare(share, privateKey, false)
+			if err != nil {
+				return nil, err
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(item, &fields); err != nil {
+				return nil, err
+			}
+			for _, set := range sets {
+				name, value, ok := strings.Cut(set, "=")
+				if !ok || name == "" {
+					return nil, errors.New("--set must be field=value")
+				}
+				fields[name] = value
+			}
+			if item, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+			return security.SealData(item, itemKey)
+		}()
+		if err != nil {
+			log.Fatalf("Error updating shared item: %v", err)
+		}
+
+		resp, err := sendEncrypted(
+			baseURL, "PUT", "update-share/"+url.PathEscape(id), token, &models.Share{Data: data},
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error updating share: %v", err)
+		}
+		fmt.Printf("Shared item updated: %s\n", resp)
+		return nil
+	}
+}
+
+// syncShares merges recipients' edits and reseals shares of changed items.
+func syncShares(baseURL, token string, only func(route string, id uint) bool) (int, int, error) {
+	var shares []*models.Share
+	if err := fetchList(baseURL, "get-share", token, &shares); err != nil {
+		return 0, 0, err
+	}
+	var picked []*models.Share
+	for _, s := range shares {
+		if only == nil || only(s.ItemType, s.ItemID) {
+			picked = append(picked, s)
+		}
+	}
+	if len(picked) == 0 {
+		return 0, 0, nil
+	}
+	privateKey, err := readPrivateKey()
+	if err != nil {
+		return 0, 0, err
+	}
+	items := func() (map[string]map[uint]json.RawMessage, error) {
+		byRoute := map[string]map[uint]json.RawMessage{}
+		for _, s := range picked {
+			if _, ok := byRoute[s.ItemType]; ok {
+				continue
+			}
+			var list []json.RawMessage
+			if err := fetchList(baseURL, "get-"+s.ItemType, token, &list); err != nil {
+				return nil, err
+			}
+			byRoute[s.ItemType] = map[uint]json.RawMessage{}
+			for _, item := range list {
+				var head struct {
+					ID uint `json:"ID"`
+				}
+				if err := json.Unmarshal(item, &head); err != nil {
+					return nil, err
+				}
+				byRoute[s.ItemType][head.ID] = item
+			}
+		}
+		return byRoute, nil
+	}
+
+	current, err := items()
+	if err != nil {
+		return 0, 0, err
+	}
+	mergedIDs := map[string]bool{}
+	for _, s := range picked {
+		item, ok := current[s.ItemType][s.ItemID]
+		if !ok || s.UpdatedBy == s.OwnerID {
+			continue
+		}
+		_, shared, err := openShare(s, privateKey, true)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error opening share %d: %w", s.ID, err)
+		}
+		if item, err = mergeShared(item, shared); err != nil {
+			return 0, 0, fmt.Errorf("error merging share %d: %w", s.ID, err)
+		}
+		_, err = sendEncrypted(
+			baseURL, "PUT", fmt.Sprintf("update-%s/%d", s.ItemType, s.ItemID), token, item,
+			http.StatusOK,
+		)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error merging share %d: %w", s.ID, err)
+		}
+		current[s.ItemType][s.ItemID] = item
+		mergedIDs[fmt.Sprintf("%s/%d", s.ItemType, s.ItemID)] = true
+	}
+	if len(mergedIDs) > 0 {
+		if current, err = items(); err != nil {
+			return 0, 0, err
+		}
+		reindexAfterChange(
+			baseURL, token, func(route string, id uint) bool {
+				return mergedIDs[fmt.Sprintf("%s/%d", route, id)]
+			},
+		)
+	}
+
+	resealed := 0
+	for _, s := range picked {
+		item, ok := current[s.ItemType][s.ItemID]
+		if !ok {
+			continue
+		}
+		itemKey, shared, err := openShare(s, privateKey, true)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error opening share %d: %w", s.ID, err)
+		}
+		if s.UpdatedBy == s.OwnerID && bytes.Equal(shared, item) {
+			continue
+		}
+		data, err := security.SealData(item, itemKey)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error sealing share %d: %w", s.ID, err)
+		}
+		_, err = sendEncrypted(
+			baseURL, "PUT", fmt.Sprintf("update-share/%d", s.ID), token, &models.Share{Data: data},
+			http.StatusOK,
+		)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error updating share %d: %w", s.ID, err)
+		}
+		resealed++
+	}
+	return len(mergedIDs), resealed, nil
+}
+
+// mergeShared applies a recipient's edits to an item's JSON.
+func mergeShared(item, shared []byte) (json.RawMessage, error) {
+	var fields, edited map[string]json.RawMessage
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(shared, &edited); err != nil {
+		return nil, err
+	}
+	for name, value := range edited {
+		fields[name] = value
+	}
+	return json.Marshal(fields)
+}
+
+// syncSharesAfterChange syncs shares of changed items, only warning on failure.
+func syncSharesAfterChange(baseURL, token string, only func(route string, id uint) bool) {
+	if _, _, err := syncShares(baseURL, token, only); err != nil {
+		log.Printf("Warning: shares not updated, run share sync: %v", err)
+	}
+}
+
+// SyncShares merges the recipients' edits of shared items into the items
+// and seals the shares of changed items again.
+func SyncShares(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		merged, resealed, err := syncShares(baseURL, c.String("token"), nil)
+		if err != nil {
+			log.Fatalf("Error syncing shares: %v", err)
+		}
+		fmt.Printf("%d edited items merged, %d shares updated\n", merged, resealed)
+		return nil
+	}
+}
+
+// getParentTokenFlag is the token flag of a command with subcommands.
+func getParentTokenFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "token",
+		Aliases: []string{"t"},
+		Usage:   "Token for Authorization",
+	}
+}
+
+func KeyPairCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:  "keypair",
+		Usage: "Create the key pair used to share items and give its public key to the server",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Replace an existing private key",
+			},
+			getTokenFlag(),
+		},
+		Action: KeyPair(baseURL),
+	}
+}
+
+func ShareCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "share",
+		Usage:     "Share an item with another user, list and revoke shares",
+		ArgsUsage: itemTypesUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "with",
+				Usage: "User to share the item with",
+			},
+			&cli.BoolFlag{
+				Name:  "editable",
+				Usage: "Let the user change the shared item",
+			},
+			getParentTokenFlag(),
+		},
+		Action: ShareItem(baseURL),
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the items you share",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "reveal",
+						Usage: "Show the shared items as last sealed",
+					},
+					getTokenFlag(),
+				},
+				Action: ListShares(baseURL),
+			},
+			{
+				Name:   "sync",
+				Usage:  "Merge the recipients' edits into your items and update the shares of changed items",
+				Flags:  []cli.Flag{getTokenFlag()},
+				Action: SyncShares(baseURL),
+			},
+			{
+				Name:      "revoke",
+				Usage:     "Stop sharing an item with a user",
+				ArgsUsage: "<share-id>",
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    RevokeShare(baseURL),
+			},
+		},
+	}
+}
+
+func SharedWithMeCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:  "shared-with-me",
+		Usage: "List the items other users share with you",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "reveal",
+				Usage: "Show the shared items",
+			},
+			getParentTokenFlag(),
+		},
+		Action: SharedWithMe(baseURL),
+		Subcommands: []*cli.Command{
+			{
+				Name:      "update",
+				Usage:     "Change fields of an editable shared item",
+				ArgsUsage: "<share-id>",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "set",
+						Usage: "Field as name=value, can be repeated",
+					},
+					getTokenFlag(),
+				},
+				Action: UpdateShared(baseURL),
+			},
+		},
+	}
+}
@@ -1,12 +1,15 @@
 package cliApp
 
 import (
+	"errors"
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
 	"github.com/elina-chertova/auth-keeper.git/internal/sender"
 	"github.com/urfave/cli/v2"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
 )
 
 func getLoginFlags() []cli.Flag {
@@ -60,6 +63,13 @@ func loginUser(baseURL string) func(c *cli.Context) error {
 		}
 
 		fmt.Printf("Login successful: %s\n", resp.String())
+		if _, err := os.Stat(clientKeyFile); errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf(
+				"No client key in %s: copy it from a device you use this account on to read display names, "+
+					"tags and search, or run reindex to create one if the account has none yet\n",
+				clientKeyFile,
+			)
+		}
 		return nil
 	}
 
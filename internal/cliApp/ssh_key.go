@@ -0,0 +1,295 @@
+package cliApp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/sshkey"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"io/fs"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+)
+
+func getTokenFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:     "token",
+		Aliases:  []string{"t"},
+		Usage:    "Token for Authorization",
+		Required: true,
+	}
+}
+
+func getAddSSHKeyFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "file",
+			Aliases:  []string{"f"},
+			Usage:    "Path to the private key, e.g. ~/.ssh/id_ed25519",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "passphrase",
+			Aliases: []string{"p"},
+			Usage:   "Passphrase of an encrypted private key",
+		},
+		&cli.StringFlag{
+			Name:    "comment",
+			Aliases: []string{"c"},
+			Usage:   "Key comment, defaults to the one in the .pub file",
+		},
+		&cli.StringFlag{
+			Name:    "metadata",
+			Aliases: []string{"m"},
+			Usage:   "Metadata",
+		},
+		getTokenFlag(),
+	}
+}
+
+func AddSSHKey(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		path := c.String("file")
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Error reading private key: %v", err)
+		}
+
+		comment := c.String("comment")
+		if comment == "" {
+			if pub, err := os.ReadFile(path + ".pub"); err == nil {
+				if _, pubComment, _, _, err := ssh.ParseAuthorizedKey(pub); err == nil {
+					comment = pubComment
+				}
+			}
+		}
+
+		key, err := sshkey.FromPrivateKey(pemBytes, []byte(c.String("passphrase")), comment)
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				log.Fatalf("The private key is encrypted, pass --passphrase")
+			}
+			log.Fatalf("Error parsing private key: %v", err)
+		}
+		key.Metadata = c.String("metadata")
+		key.ItemMeta = metaFromFlags(c, baseURL)
+
+		resp, err := postEncrypted(baseURL, "add-ssh-key", c.String("token"), key)
+		if err != nil {
+			log.Fatalf("Error adding ssh key: %v", err)
+		}
+		fmt.Printf("SSH key %s added successfully: %s\n", key.Fingerprint, resp)
+		return nil
+	}
+}
+
+func getGenerateSSHKeyFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "Key type: ed25519, rsa or ecdsa",
+			Value: sshkey.TypeEd25519,
+		},
+		&cli.IntFlag{
+			Name:    "bits",
+			Aliases: []string{"b"},
+			Usage:   "Key size for rsa and ecdsa keys",
+		},
+		&cli.StringFlag{
+			Name:    "comment",
+			Aliases: []string{"c"},
+			Usage:   "Key comment",
+		},
+		&cli.StringFlag{
+			Name:    "metadata",
+			Aliases: []string{"m"},
+			Usage:   "Metadata",
+		},
+		getTokenFlag(),
+	}
+}
+
+func GenerateSSHKey(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		key, err := sshkey.Generate(c.String("type"), c.Int("bits"), c.String("comment"))
+		if err != nil {
+			log.Fatalf("Error generating ssh key: %v", err)
+		}
+		key.Metadata = c.String("metadata")
+		key.ItemMeta = metaFromFlags(c, baseURL)
+
+		if _, err := postEncrypted(baseURL, "add-ssh-key", c.String("token"), key); err != nil {
+			log.Fatalf("Error adding ssh key: %v", err)
+		}
+		fmt.Printf("Generated %s key %s\n", key.KeyType, key.Fingerprint)
+		fmt.Println(key.PublicKey)
+		return nil
+	}
+}
+
+func ListSSHKeys(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var keys []*models.SSHKey
+		endpoint := withQuery("get-ssh-key", filterQuery(c, baseURL, url.Values{}))
+		if err := fetchList(baseURL, endpoint, c.String("token"), &keys); err != nil {
+			log.Fatalf("Error getting ssh keys: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTYPE\tFINGERPRINT\tCOMMENT")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", k.ID, k.KeyType, k.Fingerprint, k.Comment)
+		}
+		return tw.Flush()
+	}
+}
+
+func SSHKeyCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:  "ssh-key",
+		Usage: "Manage SSH keys stored in the vault",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "add",
+				Usage:  "Import an existing private key",
+				Flags:  append(getAddSSHKeyFlags(), getMetaFlags()...),
+				Action: AddSSHKey(baseURL),
+			},
+			{
+				Name:   "generate",
+				Usage:  "Generate a new key pair and store it",
+				Flags:  append(getGenerateSSHKeyFlags(), getMetaFlags()...),
+				Action: GenerateSSHKey(baseURL),
+			},
+			{
+				Name:   "list",
+				Usage:  "List stored keys",
+				Flags:  append(getFilterFlags(), getTokenFlag()),
+				Action: ListSSHKeys(baseURL),
+			},
+		},
+	}
+}
+
+func getSSHAgentFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "socket",
+			Aliases: []string{"s"},
+			Usage:   "Unix socket path to listen on, by default in a new private directory",
+		},
+		&cli.BoolFlag{
+			Name:  "confirm",
+			Usage: "Ask for confirmation before every use of a key",
+		},
+		getTokenFlag(),
+	}
+}
+
+// terminalConfirm asks on stdin, one prompt at a time.
+func terminalConfirm() sshkey.ConfirmFunc {
+	var mu sync.Mutex
+	reader := bufio.NewReader(os.Stdin)
+	return func(key *agent.Key) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fmt.Printf("Allow use of key %s (%s)? [y/N] ", key.Comment, key.Format)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
+
+func SSHAgent(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var keys []*models.SSHKey
+		if err := fetchList(baseURL, "get-ssh-key", c.String("token"), &keys); err != nil {
+			log.Fatalf("Error getting ssh keys: %v", err)
+		}
+
+		var confirm sshkey.ConfirmFunc
+		if c.Bool("confirm") {
+			confirm = terminalConfirm()
+		}
+		a, err := sshkey.NewAgent(keys, confirm)
+		if err != nil {
+			log.Fatalf("Error loading ssh keys: %v", err)
+		}
+
+		socket := c.String("socket")
+		if socket == "" {
+			dir, err := os.MkdirTemp("", "auth-keeper-agent-")
+			if err != nil {
+				log.Fatalf("Error creating socket directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			socket = filepath.Join(dir, "agent.sock")
+		} else if err := removeStaleSocket(socket); err != nil {
+			log.Fatalf("Error reusing %s: %v", socket, err)
+		}
+		l, err := net.Listen("unix", socket)
+		if err != nil {
+			log.Fatalf("Error listening on %s: %v", socket, err)
+		}
+		if err := os.Chmod(socket, 0600); err != nil {
+			l.Close()
+			log.Fatalf("Error restricting socket permissions: %v", err)
+		}
+
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-stop
+			l.Close()
+		}()
+
+		fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", socket)
+		fmt.Printf("Serving %d keys, press Ctrl+C to stop\n", len(keys))
+		err = a.Serve(l)
+		_ = os.Remove(socket)
+		return err
+	}
+}
+
+// removeStaleSocket removes a socket left at path by an earlier agent.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return errors.New("not a socket")
+	}
+	if !ownedByUser(info) {
+		return errors.New("socket is owned by another user")
+	}
+	return os.Remove(path)
+}
+
+func SSHAgentCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:   "ssh-agent",
+		Usage:  "Serve vault SSH keys over the OpenSSH agent protocol",
+		Flags:  getSSHAgentFlags(),
+		Action: SSHAgent(baseURL),
+	}
+}
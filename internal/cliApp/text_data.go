@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/search"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/elina-chertova/auth-keeper.git/internal/sender"
 	"github.com/urfave/cli/v2"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 )
 
@@ -41,7 +43,9 @@ func AddTextData(baseURL string) func(c *cli.Context) error {
 		textData := models.TextData{
 			Content:  c.String("content"),
 			Metadata: c.String("metadata"),
+			ItemMeta: metaFromFlags(c, baseURL),
 		}
+		indexItem(&textData)
 		token := c.String("token")
 		client := sender.NewClient(baseURL)
 
@@ -49,6 +53,9 @@ func AddTextData(baseURL string) func(c *cli.Context) error {
 		if err != nil {
 			log.Fatalf("Error marshalling data: %v", err)
 		}
+		if jsonData, err = search.SealMeta(jsonData, metaKey); err != nil {
+			log.Fatalf("Error sealing display name and tags: %v", err)
+		}
 
 		personalKey, err := os.ReadFile("pkey.txt")
 		if err != nil {
@@ -90,7 +97,7 @@ func AddTextDataCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "add-text-data",
 		Usage:  "Add a text data",
-		Flags:  getAddTextDataFlags(),
+		Flags:  append(getAddTextDataFlags(), getMetaFlags()...),
 		Action: AddTextData(baseURL),
 	}
 }
@@ -108,45 +115,12 @@ func getTextDataFlags() []cli.Flag {
 
 func GetTextData(baseURL string) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		token := c.String("token")
-		client := sender.NewClient(baseURL)
-		resp, err := client.SendRequest("GET", "get-text-data", nil, token)
+		endpoint := withQuery("get-text-data", filterQuery(c, baseURL, url.Values{}))
+		data, err := fetchDecrypted(baseURL, endpoint, c.String("token"))
 		if err != nil {
 			log.Fatalf("Error getting text data: %v", err)
 		}
-		if resp.StatusCode != http.StatusOK {
-			log.Fatalf(
-				"Failed to get text data, status code: %d, response: %s",
-				resp.StatusCode,
-				resp.String(),
-			)
-		}
-		var responseData struct {
-			Body    string `json:"body"`
-			Message string `json:"message"`
-		}
-		if err := json.Unmarshal(resp.Bytes(), &responseData); err != nil {
-			log.Fatalf("Error unmarshalling response data: %v", err)
-		}
-
-		encodedData := responseData.Body
-		fmt.Printf("Encoded Data: %s\n", encodedData)
-
-		personalKey, err := os.ReadFile("pkey.txt")
-		if err != nil {
-			log.Fatalf("Error reading personal key: %v", err)
-		}
-
-		decodedData, err := base64.StdEncoding.DecodeString(encodedData)
-		if err != nil {
-			log.Fatalf("Error decoding base64 data: %v", err)
-		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey)
-		if err != nil {
-			log.Fatalf("Error decrypting data: %v", err)
-		}
-		fmt.Printf("Decrypted Data: %s\n", string(decryptedData))
+		fmt.Printf("Decrypted Data: %s\n", string(data))
 		return nil
 	}
 }
@@ -155,7 +129,7 @@ func GetTextDataCommand(baseURL string) *cli.Command {
 	return &cli.Command{
 		Name:   "get-text-data",
 		Usage:  "Get text data",
-		Flags:  getTextDataFlags(),
+		Flags:  append(getTextDataFlags(), getFilterFlags()...),
 		Action: GetTextData(baseURL),
 	}
 }
@@ -0,0 +1,176 @@
+package cliApp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// trashedItem is a trashed item of any type.
+type trashedItem struct {
+	ID          uint       `json:"ID"`
+	DeletedAt   *time.Time `json:"DeletedAt"`
+	DisplayName string     `json:"display_name"`
+}
+
+// routeTypes maps routes back to the item type arguments of itemTypes.
+func routeTypes() map[string]string {
+	types := make(map[string]string, len(itemTypes))
+	for name, route := range itemTypes {
+		types[route] = name
+	}
+	return types
+}
+
+// Delete moves an item to the trash.
+func Delete(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		route, id := itemArgs(c, "delete")
+		resp, err := sendRequest(
+			baseURL, "DELETE", "delete-"+route+"/"+url.PathEscape(id), c.String("token"), nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error deleting item: %v", err)
+		}
+		fmt.Printf("Item moved to trash: %s\n", resp)
+		return nil
+	}
+}
+
+func ListTrash(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		var trash map[string][]trashedItem
+		if err := fetchList(baseURL, "get-trash", c.String("token"), &trash); err != nil {
+			log.Fatalf("Error getting trash: %v", err)
+		}
+
+		types := routeTypes()
+		routes := make([]string, 0, len(trash))
+		for route := range trash {
+			routes = append(routes, route)
+		}
+		sort.Strings(routes)
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TYPE\tID\tNAME\tDELETED AT")
+		for _, route := range routes {
+			for _, it := range trash[route] {
+				deleted := ""
+				if it.DeletedAt != nil {
+					deleted = it.DeletedAt.Local().Format(time.DateTime)
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", types[route], it.ID, it.DisplayName, deleted)
+			}
+		}
+		return tw.Flush()
+	}
+}
+
+func RestoreFromTrash(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		route, id := itemArgs(c, "trash restore")
+		resp, err := sendRequest(
+			baseURL, "PUT", "restore-trash/"+route+"/"+url.PathEscape(id), c.String("token"), nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error restoring item: %v", err)
+		}
+		fmt.Printf("Item restored successfully: %s\n", resp)
+		return nil
+	}
+}
+
+func PurgeFromTrash(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		route, id := itemArgs(c, "trash purge")
+		resp, err := sendRequest(
+			baseURL, "DELETE", "purge-trash/"+route+"/"+url.PathEscape(id), c.String("token"), nil,
+			http.StatusOK,
+		)
+		if err != nil {
+			log.Fatalf("Error purging item: %v", err)
+		}
+		fmt.Printf("Item permanently deleted: %s\n", resp)
+		return nil
+	}
+}
+
+func EmptyTrash(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if !c.Bool("yes") {
+			log.Fatalf("Emptying the trash cannot be undone, pass --yes to confirm")
+		}
+		resp, err := sendRequest(baseURL, "DELETE", "empty-trash", c.String("token"), nil, http.StatusOK)
+		if err != nil {
+			log.Fatalf("Error emptying trash: %v", err)
+		}
+		var result struct {
+			Purged int64 `json:"purged"`
+		}
+		if err := json.Unmarshal([]byte(resp), &result); err != nil {
+			log.Fatalf("Error unmarshalling response: %v", err)
+		}
+		fmt.Printf("Trash emptied, %d items permanently deleted\n", result.Purged)
+		return nil
+	}
+}
+
+func DeleteCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:      "delete",
+		Usage:     "Move an item to the trash",
+		ArgsUsage: itemTypesUsage,
+		Flags:     []cli.Flag{getTokenFlag()},
+		Action:    Delete(baseURL),
+	}
+}
+
+func TrashCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:  "trash",
+		Usage: "List, restore and permanently delete trashed items",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List trashed items",
+				Flags:  []cli.Flag{getTokenFlag()},
+				Action: ListTrash(baseURL),
+			},
+			{
+				Name:      "restore",
+				Usage:     "Move an item out of the trash",
+				ArgsUsage: itemTypesUsage,
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    RestoreFromTrash(baseURL),
+			},
+			{
+				Name:      "purge",
+				Usage:     "Permanently delete a trashed item",
+				ArgsUsage: itemTypesUsage,
+				Flags:     []cli.Flag{getTokenFlag()},
+				Action:    PurgeFromTrash(baseURL),
+			},
+			{
+				Name:  "empty",
+				Usage: "Permanently delete all trashed items",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Confirm that the items cannot be recovered",
+					},
+					getTokenFlag(),
+				},
+				Action: EmptyTrash(baseURL),
+			},
+		},
+	}
+}
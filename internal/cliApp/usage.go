@@ -0,0 +1,75 @@
+package cliApp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// usageResponse is the answer of the usage route.
+type usageResponse struct {
+	Usage struct {
+		Bytes int64            `json:"bytes"`
+		Items map[string]int64 `json:"items"`
+	} `json:"usage"`
+	Quota struct {
+		MaxBytes     int64 `json:"max_bytes"`
+		MaxItemBytes int64 `json:"max_item_bytes"`
+		MaxItems     int64 `json:"max_items"`
+	} `json:"quota"`
+}
+
+// limitOf prints a quota limit, zero meaning none.
+func limitOf(limit int64, format func(int64) string) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return format(limit)
+}
+
+func Usage(baseURL string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		body, err := sendRequest(baseURL, "GET", "usage", c.String("token"), nil, http.StatusOK)
+		if err != nil {
+			log.Fatalf("Error getting usage: %v", err)
+		}
+		var res usageResponse
+		if err := json.Unmarshal([]byte(body), &res); err != nil {
+			log.Fatalf("Error decoding usage: %v", err)
+		}
+
+		fmt.Printf(
+			"Storage: %s of %s\n", byteSize(res.Usage.Bytes), limitOf(res.Quota.MaxBytes, byteSize),
+		)
+		fmt.Printf("Max item size: %s\n", limitOf(res.Quota.MaxItemBytes, byteSize))
+
+		tables := make([]string, 0, len(res.Usage.Items))
+		for table := range res.Usage.Items {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		maxItems := limitOf(
+			res.Quota.MaxItems, func(n int64) string { return fmt.Sprint(n) },
+		)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TYPE\tITEMS\tLIMIT")
+		for _, table := range tables {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", table, res.Usage.Items[table], maxItems)
+		}
+		return tw.Flush()
+	}
+}
+
+func UsageCommand(baseURL string) *cli.Command {
+	return &cli.Command{
+		Name:   "usage",
+		Usage:  "Show the storage used and the quota",
+		Flags:  []cli.Flag{getTokenFlag()},
+		Action: Usage(baseURL),
+	}
+}
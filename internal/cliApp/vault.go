@@ -0,0 +1,245 @@
+package cliApp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/search"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/elina-chertova/auth-keeper.git/internal/sender"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const personalKeyFile = "pkey.txt"
+
+// listPageSize is the number of items requested per page of a list.
+const listPageSize = 100
+
+// fetchDecrypted fetches every page of an encrypted list and decrypts it.
+func fetchDecrypted(baseURL, endpoint, token string) ([]byte, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %w", endpoint, err)
+	}
+	query := u.Query()
+	byName, desc := query.Get("sort") == "name", query.Get("order") == "desc"
+	if byName {
+		query.Del("sort")
+		query.Del("order")
+		u.RawQuery = query.Encode()
+	}
+
+	data, err := fetchJoined(baseURL, u.String(), token)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = search.OpenMeta(data, metaKey); err != nil {
+		return nil, fmt.Errorf("error opening display names and tags: %w", err)
+	}
+	if bytes.Contains(data, []byte(search.Unreadable)) {
+		log.Printf(
+			"Warning: some display names or tags were sealed with another client key than %s and are shown as %s",
+			clientKeyFile, search.Unreadable,
+		)
+	}
+	if byName {
+		return sortByName(data, desc), nil
+	}
+	return data, nil
+}
+
+// sortByName sorts a JSON array of items by name.
+func sortByName(data []byte, desc bool) []byte {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return data
+	}
+	names := make([]string, len(items))
+	order := make([]int, len(items))
+	for i, item := range items {
+		var it struct {
+			Name        *string `json:"name"`
+			DisplayName string  `json:"display_name"`
+		}
+		_ = json.Unmarshal(item, &it)
+		names[i] = it.DisplayName
+		if it.Name != nil {
+			names[i] = *it.Name
+		}
+		order[i] = i
+	}
+	sort.SliceStable(
+		order, func(a, b int) bool {
+			if desc {
+				return names[order[a]] > names[order[b]]
+			}
+			return names[order[a]] < names[order[b]]
+		},
+	)
+	list := make([]json.RawMessage, len(items))
+	for i, j := range order {
+		list[i] = items[j]
+	}
+	sorted, err := json.Marshal(list)
+	if err != nil {
+		return data
+	}
+	return sorted
+}
+
+// fetchJoined is fetchDecrypted leaving names and tags sealed.
+func fetchJoined(baseURL, endpoint, token string) ([]byte, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %w", endpoint, err)
+	}
+	query := u.Query()
+	if query.Get("limit") == "" {
+		query.Set("limit", strconv.Itoa(listPageSize))
+	}
+
+	var items []json.RawMessage
+	for {
+		u.RawQuery = query.Encode()
+		data, next, err := fetchPage(baseURL, u.String(), token)
+		if err != nil {
+			return nil, err
+		}
+		if next == "" && items == nil {
+			return data, nil
+		}
+
+		var page []json.RawMessage
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("error unmarshalling page: %w", err)
+		}
+		items = append(items, page...)
+		if next == "" {
+			return json.Marshal(items)
+		}
+		query.Set("cursor", next)
+	}
+}
+
+// fetchPage returns one decrypted page and the next cursor.
+func fetchPage(baseURL, endpoint, token string) ([]byte, string, error) {
+	client := sender.NewClient(baseURL)
+	resp, err := client.SendRequest("GET", endpoint, nil, token)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf(
+			"failed to get %s, status code: %d, response: %s",
+			endpoint,
+			resp.StatusCode,
+			resp.String(),
+		)
+	}
+
+	var responseData struct {
+		Body    string `json:"body"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(resp.Bytes(), &responseData); err != nil {
+		return nil, "", fmt.Errorf("error unmarshalling response data: %w", err)
+	}
+
+	personalKey, err := os.ReadFile(personalKeyFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading personal key: %w", err)
+	}
+
+	decodedData, err := base64.StdEncoding.DecodeString(responseData.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error decoding base64 data: %w", err)
+	}
+
+	data, err := security.DecryptData(decodedData, personalKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("X-Next-Cursor"), nil
+}
+
+// fetchList requests an encrypted list and unmarshals it into out.
+func fetchList(baseURL, endpoint, token string, out interface{}) error {
+	data, err := fetchDecrypted(baseURL, endpoint, token)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error unmarshalling decrypted data: %w", err)
+	}
+	return nil
+}
+
+// postEncrypted posts v sealed with the personal key, expecting 201.
+func postEncrypted(baseURL, endpoint, token string, v interface{}) (string, error) {
+	return sendEncrypted(baseURL, "POST", endpoint, token, v, http.StatusCreated)
+}
+
+// sendEncrypted sends v sealed with the personal key, expecting status.
+func sendEncrypted(
+	baseURL, method, endpoint, token string,
+	v interface{},
+	expected int,
+) (string, error) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling data: %w", err)
+	}
+
+	personalKey, err := os.ReadFile(personalKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading personal key: %w", err)
+	}
+
+	if jsonData, err = search.SealMeta(jsonData, metaKey); err != nil {
+		return "", fmt.Errorf("error sealing display names and tags: %w", err)
+	}
+
+	encryptedData, err := security.EncryptData(jsonData, personalKey)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting data: %w", err)
+	}
+
+	return sendRequest(
+		baseURL,
+		method,
+		endpoint,
+		token,
+		map[string]string{"data": base64.StdEncoding.EncodeToString(encryptedData)},
+		expected,
+	)
+}
+
+// sendRequest sends data as is and checks the response status code.
+func sendRequest(
+	baseURL, method, endpoint, token string,
+	data interface{},
+	expected int,
+) (string, error) {
+	client := sender.NewClient(baseURL)
+	resp, err := client.SendRequest(method, endpoint, data, token)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != expected {
+		return "", fmt.Errorf(
+			"failed to %s %s, status code: %d, response: %s",
+			strings.ToLower(method),
+			endpoint,
+			resp.StatusCode,
+			resp.String(),
+		)
+	}
+	return resp.String(), nil
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/database"
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -9,7 +10,26 @@ import (
 )
 
 type AppConf struct {
-	Address string
+	Address      string
+	BreachDBPath string
+	// HistoryMaxVersions and HistoryMaxDays limit the kept versions of each
+	// item; zero keeps all.
+	HistoryMaxVersions int
+	HistoryMaxDays     int
+	// TrashRetentionDays is how long deleted items stay in the trash before
+	// the janitor purges them; zero keeps them until purged by hand.
+	TrashRetentionDays int
+	// Blobs selects where binary contents are stored.
+	Blobs blobstore.Config
+	// QuotaMaxBytes, QuotaMaxItemBytes and QuotaMaxItems limit the binary
+	// data bytes, the bytes of one binary data item and the items of each
+	// type a user can store; zero is unlimited.
+	QuotaMaxBytes     int64
+	QuotaMaxItemBytes int64
+	QuotaMaxItems     int64
+	// MaxBodyBytes limits the size of any request body; zero keeps the
+	// default.
+	MaxBodyBytes int64
 }
 
 var SecretKey string
@@ -29,7 +49,29 @@ func LoadEnv() (database.DBConfig, AppConf) {
 		Password: viper.GetString("DB_PASSWORD"),
 	}
 	appConf := AppConf{
-		Address: viper.GetString("APP_ADDRESS"),
+		Address:      viper.GetString("APP_ADDRESS"),
+		BreachDBPath: viper.GetString("BREACH_DB_PATH"),
+
+		HistoryMaxVersions: viper.GetInt("HISTORY_MAX_VERSIONS"),
+		HistoryMaxDays:     viper.GetInt("HISTORY_MAX_DAYS"),
+		TrashRetentionDays: viper.GetInt("TRASH_RETENTION_DAYS"),
+
+		QuotaMaxBytes:     viper.GetInt64("QUOTA_MAX_BYTES"),
+		QuotaMaxItemBytes: viper.GetInt64("QUOTA_MAX_ITEM_BYTES"),
+		QuotaMaxItems:     viper.GetInt64("QUOTA_MAX_ITEMS"),
+		MaxBodyBytes:      viper.GetInt64("MAX_BODY_BYTES"),
+
+		Blobs: blobstore.Config{
+			Backend: viper.GetString("BLOB_BACKEND"),
+			Dir:     viper.GetString("BLOB_DIR"),
+			S3: blobstore.S3Config{
+				Endpoint:  viper.GetString("S3_ENDPOINT"),
+				Region:    viper.GetString("S3_REGION"),
+				Bucket:    viper.GetString("S3_BUCKET"),
+				AccessKey: viper.GetString("S3_ACCESS_KEY"),
+				SecretKey: viper.GetString("S3_SECRET_KEY"),
+			},
+		},
 	}
 	SecretKey = os.Getenv("SECRET_KEY")
 	return dbConf, appConf
@@ -32,6 +32,26 @@ func InitDB(conf *DBConfig) *gorm.DB {
 		&models.BinaryData{},
 		&models.TextData{},
 		&models.LoginPassword{},
+		&models.OTPSecret{},
+		&models.SSHKey{},
+		&models.Item{},
+		&models.ItemTemplate{},
+		&models.Folder{},
+		&models.Tag{},
+		&models.ItemTag{},
+		&models.SearchToken{},
+		&models.ItemVersion{},
+		&models.BinaryChunk{},
+		&models.OrphanBlob{},
+		&models.BlobRef{},
+		&models.Share{},
+		&models.Organization{},
+		&models.OrgMember{},
+		&models.Collection{},
+		&models.CollectionKey{},
+		&models.CollectionItem{},
+		&models.Send{},
+		&models.EmergencyAccess{},
 	)
 	if err != nil {
 		log.Fatalf("Error during migration: %v", err)
@@ -25,6 +25,26 @@ func MockInitDB(conf *DBConfig) *gorm.DB {
 		&models.BinaryData{},
 		&models.TextData{},
 		&models.LoginPassword{},
+		&models.OTPSecret{},
+		&models.SSHKey{},
+		&models.Item{},
+		&models.ItemTemplate{},
+		&models.Folder{},
+		&models.Tag{},
+		&models.ItemTag{},
+		&models.SearchToken{},
+		&models.ItemVersion{},
+		&models.BinaryChunk{},
+		&models.OrphanBlob{},
+		&models.BlobRef{},
+		&models.Share{},
+		&models.Organization{},
+		&models.OrgMember{},
+		&models.Collection{},
+		&models.CollectionKey{},
+		&models.CollectionItem{},
+		&models.Send{},
+		&models.EmergencyAccess{},
 	)
 	if err != nil {
 		log.Fatalf("Error during test migration: %v", err)
@@ -83,6 +103,18 @@ func Test_InitDB(t *testing.T) {
 					"binary_data",
 					"text_data",
 					"login_passwords",
+					"otp_secrets",
+					"ssh_keys",
+					"items",
+					"item_templates",
+					"folders",
+					"tags",
+					"item_tags",
+					"search_tokens",
+					"item_versions",
+					"binary_chunks", "orphan_blobs", "blob_refs", "shares",
+					"organizations", "org_members", "collections", "collection_keys", "collection_items",
+					"sends", "emergency_accesses",
 				}
 				for _, table := range expectedTables {
 					if !got.Migrator().HasTable(table) {
@@ -2,6 +2,7 @@ package models
 
 import (
 	"github.com/jinzhu/gorm"
+	"time"
 )
 
 type User struct {
@@ -10,36 +11,415 @@ type User struct {
 	Password    string `json:"password" gorm:"not null"`
 	Email       string `json:"email" gorm:"unique;not null"`
 	PersonalKey []byte `json:"personal_key" gorm:"not null"`
+	// PublicKey is the user's X25519 public key, which others seal shared
+	// keys to. The private key is kept by the user's client.
+	PublicKey []byte `json:"public_key"`
+	// TokenVersion is raised when the password changes, which revokes the
+	// tokens issued before.
+	TokenVersion uint `json:"-" gorm:"not null;default:0"`
+}
+
+// ItemMeta organizes a vault item of any type. The client seals DisplayName
+// and Tags, so the server only sees opaque strings. Tags are stored in the
+// item_tags join table and filled in by the repositories. SearchTokens are
+// the item's blind index sent by the client; they are stored in
+// search_tokens and never listed.
+type ItemMeta struct {
+	DisplayName  string   `json:"display_name"`
+	FolderID     *uint    `json:"folder_id" gorm:"index"`
+	Favorite     bool     `json:"favorite" gorm:"not null;default:false"`
+	Tags         []string `json:"tags" gorm:"-"`
+	SearchTokens []string `json:"search_tokens,omitempty" gorm:"-"`
+}
+
+func (m *ItemMeta) Meta() *ItemMeta { return m }
+
+type Folder struct {
+	gorm.Model
+	UserID   string `json:"user_id" gorm:"not null;index"`
+	Name     string `json:"name" gorm:"not null"`
+	ParentID *uint  `json:"parent_id" gorm:"index"`
+}
+
+// Tag is a tag of a user's items; Name is sealed by the client.
+type Tag struct {
+	gorm.Model
+	UserID string `json:"user_id" gorm:"not null;uniqueIndex:idx_tags_user_name"`
+	Name   string `json:"name" gorm:"not null;uniqueIndex:idx_tags_user_name"`
+}
+
+// ItemTag links a tag to an item of any type; ItemType is the item's table.
+type ItemTag struct {
+	ItemType string `gorm:"primaryKey"`
+	ItemID   uint   `gorm:"primaryKey"`
+	TagID    uint   `gorm:"primaryKey;index"`
+}
+
+// SearchToken is one blind index entry of an item of any type.
+type SearchToken struct {
+	ItemType string `gorm:"primaryKey"`
+	ItemID   uint   `gorm:"primaryKey"`
+	Token    string `gorm:"primaryKey;index:idx_search_tokens_user_token,priority:2"`
+	UserID   string `gorm:"not null;index:idx_search_tokens_user_token,priority:1"`
+}
+
+// ItemVersion is an earlier state of an item of any type, kept when the
+// item is updated. Data is the item's JSON encrypted with the owner's
+// personal key; ItemType is the item's table.
+type ItemVersion struct {
+	gorm.Model
+	UserID   string `json:"user_id" gorm:"not null;index"`
+	ItemType string `json:"item_type" gorm:"not null;index:idx_item_versions_item,priority:1"`
+	ItemID   uint   `json:"item_id" gorm:"not null;index:idx_item_versions_item,priority:2"`
+	Data     []byte `json:"-" gorm:"not null"`
 }
 
 type LoginPassword struct {
 	gorm.Model
+	ItemMeta
 	UserID   string `json:"user_id" gorm:"not null"`
 	Login    string `json:"username" gorm:"not null"`
 	Password string `json:"password" gorm:"not null"`
+	URL      string `json:"url"`
 	Metadata string `json:"metadata" gorm:"type:text"`
 }
 
 type TextData struct {
 	gorm.Model
+	ItemMeta
 	UserID   string `json:"user_id" gorm:"not null"`
 	Content  string `json:"content" gorm:"not null"`
 	Metadata string `json:"metadata" gorm:"type:text"`
 }
 
+// BinaryData holds a file either inline in Content or, when Chunked, as
+// BinaryChunks sealed by the client with package stream. FileName, MIMEType,
+// Size and SHA256 describe the original file. The bytes live in a blob
+// store: rows keep only ContentRef and ContentHash, the hex SHA-256 of the
+// stored blob. Chunked content is split into Chunks chunks of ChunkSize
+// plaintext bytes and can be downloaded once Complete; CipherHash is the
+// hex SHA-256 of the sealed chunks in order. Compression tells whether the
+// stored content or chunks are packed with stream.Pack. ContentKey is the
+// owner's keyed hash of the file; items with equal keys share their blobs.
 type BinaryData struct {
 	gorm.Model
+	ItemMeta
 	UserID   string `json:"user_id" gorm:"not null"`
-	Content  []byte `json:"content" gorm:"not null"`
+	Content  []byte `json:"content,omitempty" gorm:"-"`
 	Metadata string `json:"metadata" gorm:"type:text"`
+
+	FileName string `json:"file_name"`
+	MIMEType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+
+	ContentRef  string `json:"-" gorm:"index"`
+	ContentHash string `json:"content_hash,omitempty"`
+	ContentKey  string `json:"content_key,omitempty" gorm:"index"`
+	Compression string `json:"compression"`
+
+	Chunked    bool   `json:"chunked" gorm:"not null;default:false"`
+	ChunkSize  int    `json:"chunk_size"`
+	Chunks     int    `json:"chunks"`
+	Header     []byte `json:"header,omitempty"`
+	Complete   bool   `json:"complete" gorm:"not null;default:false"`
+	CipherHash string `json:"cipher_hash,omitempty"`
+}
+
+// BinaryChunk is one sealed chunk of a chunked BinaryData, Size bytes long
+// and kept in the blob store under Ref.
+type BinaryChunk struct {
+	BinaryDataID uint   `gorm:"primaryKey"`
+	Number       int    `gorm:"primaryKey"`
+	Ref          string `gorm:"index"`
+	Size         int
+}
+
+// BlobRef counts the rows referencing a blob shared by deduplicated binary
+// data. Blobs without a BlobRef have a single reference.
+type BlobRef struct {
+	Ref  string `gorm:"primaryKey"`
+	Refs int    `gorm:"not null"`
+}
+
+// OrphanBlob is a blob no longer referenced by any row, waiting for the
+// janitor to delete it from the blob store.
+type OrphanBlob struct {
+	ID        uint `gorm:"primary_key"`
+	Ref       string
+	CreatedAt time.Time
+}
+
+// Share permissions: the recipient of an editable share may replace its
+// data, the recipient of a read-only share may only read it.
+const (
+	PermissionRead = "read"
+	PermissionEdit = "edit"
+)
+
+// Share gives another user access to one item. Data is the item's JSON
+// sealed by the owner's client with a random item key, which is sealed to
+// the public keys of the owner (OwnerKey) and of the recipient
+// (RecipientKey), so the server cannot read the shared item. ItemType is
+// the item's route, e.g. "login-password". UpdatedBy is the user who last
+// replaced Data; the owner's client merges the edits of recipients into the
+// item and seals the shares of changed items again. Purging the item
+// deletes its shares.
+type Share struct {
+	gorm.Model
+	OwnerID      string `json:"owner_id" gorm:"not null;index"`
+	RecipientID  string `json:"recipient_id" gorm:"not null;index"`
+	ItemType     string `json:"item_type" gorm:"not null"`
+	ItemID       uint   `json:"item_id" gorm:"not null"`
+	Permission   string `json:"permission" gorm:"not null"`
+	Data         []byte `json:"data" gorm:"not null"`
+	OwnerKey     []byte `json:"owner_key" gorm:"not null"`
+	RecipientKey []byte `json:"recipient_key" gorm:"not null"`
+	UpdatedBy    string `json:"updated_by"`
+}
+
+// Organization roles, from most to least privileged. Owners and admins
+// manage members and collections, members also change collection items and
+// read-only members only read them.
+const (
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+	RoleMember   = "member"
+	RoleReadOnly = "read-only"
+)
+
+type Organization struct {
+	gorm.Model
+	Name string `json:"name" gorm:"not null"`
+}
+
+// OrgMember is a user's membership of an organization. Invited members
+// hold the collection keys but get access when they accept. OrgName is
+// filled in when listing a user's memberships, PublicKey when listing the
+// members of an organization.
+type OrgMember struct {
+	gorm.Model
+	OrgID     uint   `json:"org_id" gorm:"not null;uniqueIndex:idx_org_members_org_user"`
+	UserID    string `json:"user_id" gorm:"not null;uniqueIndex:idx_org_members_org_user;index"`
+	Role      string `json:"role" gorm:"not null"`
+	Accepted  bool   `json:"accepted" gorm:"not null;default:false"`
+	OrgName   string `json:"org_name,omitempty" gorm:"-"`
+	PublicKey []byte `json:"public_key,omitempty" gorm:"-"`
+}
+
+// Collection is a vault shared by the members of an organization. Its
+// items are sealed with the collection key, which is sealed to the public
+// key of every member in a CollectionKey. KeyVersion grows with every key
+// rotation; Key is the requesting member's sealed key when listed.
+type Collection struct {
+	gorm.Model
+	OrgID      uint   `json:"org_id" gorm:"not null;index"`
+	Name       string `json:"name" gorm:"not null"`
+	KeyVersion int    `json:"key_version" gorm:"not null;default:1"`
+	Key        []byte `json:"key,omitempty" gorm:"-"`
+}
+
+// CollectionKey is the key of a collection sealed to a member's public key.
+type CollectionKey struct {
+	CollectionID uint   `json:"collection_id" gorm:"primaryKey"`
+	UserID       string `json:"user_id" gorm:"primaryKey"`
+	Key          []byte `json:"key" gorm:"not null"`
+}
+
+// CollectionItem is an item of any type kept in a collection. Data is the
+// item's JSON sealed with the collection key of KeyVersion; ItemType is
+// the route of the item's type, e.g. "login-password".
+type CollectionItem struct {
+	gorm.Model
+	CollectionID uint   `json:"collection_id" gorm:"not null;index"`
+	ItemType     string `json:"item_type" gorm:"not null"`
+	Data         []byte `json:"data" gorm:"not null"`
+	KeyVersion   int    `json:"key_version" gorm:"not null"`
+	UpdatedBy    string `json:"updated_by"`
 }
 
 type CreditCard struct {
 	gorm.Model
+	ItemMeta
 	UserID     string `json:"user_id" gorm:"not null"`
 	CardNumber string `json:"card_number" gorm:"not null"`
 	ExpiryDate string `json:"expiry_date" gorm:"not null"`
 	CVV        string `json:"cvv" gorm:"not null"`
 	CardHolder string `json:"card_holder" gorm:"not null"`
+	Brand      string `json:"brand"`
 	Metadata   string `json:"metadata" gorm:"type:text"`
 }
+
+type OTPSecret struct {
+	gorm.Model
+	ItemMeta
+	UserID          string `json:"user_id" gorm:"not null"`
+	Issuer          string `json:"issuer"`
+	Account         string `json:"account" gorm:"not null"`
+	Secret          string `json:"secret" gorm:"not null"`
+	Algorithm       string `json:"algorithm" gorm:"not null"`
+	Digits          int    `json:"digits" gorm:"not null"`
+	Period          int    `json:"period" gorm:"not null"`
+	LoginPasswordID *uint  `json:"login_password_id"`
+	Metadata        string `json:"metadata" gorm:"type:text"`
+}
+
+type SSHKey struct {
+	gorm.Model
+	ItemMeta
+	UserID      string `json:"user_id" gorm:"not null"`
+	KeyType     string `json:"key_type" gorm:"not null"`
+	PrivateKey  string `json:"private_key" gorm:"type:text;not null"`
+	PublicKey   string `json:"public_key" gorm:"type:text;not null"`
+	Fingerprint string `json:"fingerprint" gorm:"not null"`
+	Comment     string `json:"comment"`
+	Metadata    string `json:"metadata" gorm:"type:text"`
+}
+
+type ItemField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Value    string `json:"value,omitempty"`
+	Required bool   `json:"required,omitempty"`
+}
+
+type Item struct {
+	gorm.Model
+	ItemMeta
+	UserID   string      `json:"user_id" gorm:"not null"`
+	Template string      `json:"template" gorm:"not null"`
+	Name     string      `json:"name" gorm:"not null"`
+	Fields   []ItemField `json:"fields" gorm:"serializer:json;type:text"`
+	Metadata string      `json:"metadata" gorm:"type:text"`
+}
+
+type ItemTemplate struct {
+	gorm.Model
+	UserID string      `json:"user_id" gorm:"not null;uniqueIndex:idx_item_templates_user_name"`
+	Name   string      `json:"name" gorm:"not null;uniqueIndex:idx_item_templates_user_name"`
+	Fields []ItemField `json:"fields" gorm:"serializer:json;type:text"`
+}
+
+// SetUserID assigns the owner of a new vault item.
+func (m *LoginPassword) SetUserID(userID string) { m.UserID = userID }
+
+func (m *TextData) SetUserID(userID string) { m.UserID = userID }
+
+func (m *BinaryData) SetUserID(userID string) { m.UserID = userID }
+
+func (m *CreditCard) SetUserID(userID string) { m.UserID = userID }
+
+func (m *OTPSecret) SetUserID(userID string) { m.UserID = userID }
+
+func (m *SSHKey) SetUserID(userID string) { m.UserID = userID }
+
+func (m *Item) SetUserID(userID string) { m.UserID = userID }
+
+// MetaChange is a partial update of an item's ItemMeta; nil fields are left
+// as they are and a FolderID of 0 moves the item out of any folder.
+type MetaChange struct {
+	DisplayName *string   `json:"display_name,omitempty"`
+	FolderID    *uint     `json:"folder_id,omitempty"`
+	Favorite    *bool     `json:"favorite,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+}
+
+// FolderChange renames or moves a folder; a ParentID of 0 moves it to the
+// top level.
+type FolderChange struct {
+	Name     *string `json:"name,omitempty"`
+	ParentID *uint   `json:"parent_id,omitempty"`
+}
+
+// OrgInvite invites a user to an organization with the keys of all of its
+// collections sealed to the user's public key.
+type OrgInvite struct {
+	UserID string           `json:"user_id"`
+	Role   string           `json:"role"`
+	Keys   []*CollectionKey `json:"keys"`
+}
+
+// CollectionRotation replaces the key of a collection of KeyVersion: Keys
+// seal the new key to every remaining member and Items are all of the
+// collection's items sealed with it.
+type CollectionRotation struct {
+	CollectionID uint              `json:"collection_id"`
+	KeyVersion   int               `json:"key_version"`
+	Keys         []*CollectionKey  `json:"keys"`
+	Items        []*CollectionItem `json:"items"`
+}
+
+// NewCollection creates a collection with its key sealed to every member
+// and invited user of the organization.
+type NewCollection struct {
+	Name string           `json:"name"`
+	Keys []*CollectionKey `json:"keys"`
+}
+
+// Send is content handed to anyone holding its link, with or without an
+// account. Data is sealed by the creator's client with a key that is only
+// in the fragment of the link, so the server cannot read it. A send is
+// deleted after MaxViews reads or at ExpiresAt, whichever comes first.
+// AccessID is the random ID in the link; PasswordHash, if set, must match
+// the password given by the reader, and FailedAttempts counts the wrong
+// ones.
+type Send struct {
+	gorm.Model
+	AccessID       string    `json:"access_id" gorm:"not null;uniqueIndex"`
+	UserID         string    `json:"user_id" gorm:"not null;index"`
+	Data           []byte    `json:"data" gorm:"not null"`
+	MaxViews       int       `json:"max_views" gorm:"not null"`
+	Views          int       `json:"views" gorm:"not null;default:0"`
+	ExpiresAt      time.Time `json:"expires_at" gorm:"not null;index"`
+	PasswordHash   string    `json:"-"`
+	FailedAttempts int       `json:"-" gorm:"not null;default:0"`
+}
+
+// SendPasswordHeader carries the password of a password-protected Send.
+const SendPasswordHeader = "X-Send-Password"
+
+// NewSend creates a Send. A zero MaxViews or ExpiresAt takes the server's
+// default.
+type NewSend struct {
+	Data      []byte    `json:"data"`
+	MaxViews  int       `json:"max_views"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Password  string    `json:"password,omitempty"`
+}
+
+// Emergency access types and statuses. View access lets the grantee read
+// the grantor's vault, takeover also lets the grantee set a new password
+// for the grantor's account.
+const (
+	EmergencyView     = "view"
+	EmergencyTakeover = "takeover"
+
+	EmergencyGranted   = "granted"
+	EmergencyRequested = "requested"
+	EmergencyApproved  = "approved"
+)
+
+// Notices counts what a user has to attend to: the requests for emergency
+// access the user has yet to answer and the user's shares a recipient
+// edited that the client has yet to merge into the items.
+type Notices struct {
+	EmergencyRequests int64 `json:"emergency_requests"`
+	SharesEdited      int64 `json:"shares_edited"`
+}
+
+// EmergencyAccess lets a trusted user, the grantee, reach the grantor's
+// vault when the grantor does not reject a request for it within WaitDays.
+// Key is the grantor's personal key sealed to the grantee's public key.
+// RecoveryAt is when a pending request is granted; the janitor approves
+// the requests whose time has come.
+type EmergencyAccess struct {
+	gorm.Model
+	GrantorID   string     `json:"grantor_id" gorm:"not null;uniqueIndex:idx_emergency_accesses_grantor_grantee"`
+	GranteeID   string     `json:"grantee_id" gorm:"not null;uniqueIndex:idx_emergency_accesses_grantor_grantee;index"`
+	Type        string     `json:"type" gorm:"not null"`
+	WaitDays    int        `json:"wait_days" gorm:"not null"`
+	Status      string     `json:"status" gorm:"not null"`
+	Key         []byte     `json:"key" gorm:"not null"`
+	RequestedAt *time.Time `json:"requested_at"`
+	RecoveryAt  *time.Time `json:"recovery_at" gorm:"index"`
+}
@@ -12,17 +12,49 @@ import (
 
 type UserHandler struct {
 	userRep repository.UserRepo
+	breach  security.BreachChecker
 }
 
-func NewUserHandler(ur repository.UserRepo) *UserHandler {
-	return &UserHandler{userRep: ur}
+// NewUserHandler creates a UserHandler. breach may be nil, in which case
+// passwords are not checked against a breach corpus.
+func NewUserHandler(ur repository.UserRepo, breach security.BreachChecker) *UserHandler {
+	return &UserHandler{userRep: ur, breach: breach}
 }
 
 var (
 	errBadRequest     = errors.New("email or password is incorrect")
 	errTokenGenerated = errors.New("token is not generated")
+	errWeakPassword   = errors.New("password is too weak")
+	errPwnedPassword  = errors.New("password has appeared in a data breach")
 )
 
+// checkPassword enforces the account password policy.
+func (h *UserHandler) checkPassword(ctx *gin.Context, password string, userInputs ...string) bool {
+	strength := security.EstimateStrength(password, userInputs...)
+	if strength.Score < security.MinAccountPasswordScore {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": errWeakPassword.Error(), "strength": strength},
+		)
+		return false
+	}
+
+	if h.breach == nil {
+		return true
+	}
+	breached, err := h.breach.IsBreached(password)
+	if err != nil {
+		log.Printf("Error checking password against breach database: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check password"})
+		return false
+	}
+	if breached {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errPwnedPassword.Error()})
+		return false
+	}
+	return true
+}
+
 func (h *UserHandler) Register() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var user models.User
@@ -31,6 +63,17 @@ func (h *UserHandler) Register() gin.HandlerFunc {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+
+		if !h.checkPassword(ctx, user.Password, user.Username, user.Email) {
+			return
+		}
+		if len(user.PublicKey) > 0 {
+			if err := security.ValidatePublicKey(user.PublicKey); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		hashed, err := security.HashPassword(user.Password)
 		if err != nil {
 			log.Printf("Error hashing password: %v", err)
@@ -45,7 +88,7 @@ func (h *UserHandler) Register() gin.HandlerFunc {
 			return
 		}
 
-		token, err := security.GenerateToken(user.Username)
+		token, err := security.GenerateToken(user.Username, user.TokenVersion)
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": errTokenGenerated})
 			return
@@ -88,7 +131,7 @@ func (h *UserHandler) Signup() gin.HandlerFunc {
 				return
 			}
 		}
-		token, err := security.GenerateToken(user.Username)
+		token, err := security.GenerateToken(dbUser.Username, dbUser.TokenVersion)
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": errTokenGenerated})
 			return
@@ -106,3 +149,103 @@ func (h *UserHandler) Signup() gin.HandlerFunc {
 		)
 	}
 }
+
+func (h *UserHandler) ChangePassword() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			OldPassword string `json:"old_password" binding:"required"`
+			NewPassword string `json:"new_password" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		dbUser, err := h.userRep.GetUserByUsername(userID.(string))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": errBadRequest.Error()})
+			return
+		}
+		if !security.CheckPasswordHash(req.OldPassword, dbUser.Password) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": errBadRequest.Error()})
+			return
+		}
+
+		if !h.checkPassword(ctx, req.NewPassword, dbUser.Username, dbUser.Email) {
+			return
+		}
+
+		hashed, err := security.HashPassword(req.NewPassword)
+		if err != nil {
+			log.Printf("Error hashing password: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := h.userRep.UpdatePassword(dbUser.Username, hashed); err != nil {
+			log.Printf("Error updating password: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Tokens issued before, this one included, are revoked.
+		token, err := security.GenerateToken(dbUser.Username, dbUser.TokenVersion+1)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": errTokenGenerated})
+			return
+		}
+		ctx.SetCookie("access_token", token, 3600, "/", "localhost", false, true)
+		ctx.Writer.Header().Set("Authorization", "Bearer "+token)
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Password has been changed",
+				"token":   token,
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// SetPublicKey replaces the user's public key, e.g. when a key pair is
+// created for an account registered without one. Shares sealed to the old
+// key cannot be opened with the new private key.
+func (h *UserHandler) SetPublicKey() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			PublicKey []byte `json:"public_key" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := security.ValidatePublicKey(req.PublicKey); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := h.userRep.SetPublicKey(userID.(string), req.PublicKey); err != nil {
+			log.Printf("Error setting public key: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Public key has been set",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
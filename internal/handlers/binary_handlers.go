@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	errChunkedBinaryData = errors.New("chunked binary data must be sent to upload-binary-data")
+	errContentSHA256     = errors.New("sha256 does not match the content")
+	errInvalidSHA256     = errors.New("sha256 must be 64 hex digits")
+	errInvalidMIMEType   = errors.New("invalid mime type")
+	errInvalidContentKey = errors.New("content key must be 64 hex digits")
+	errCompression       = errors.New("unsupported compression")
+)
+
+// UploadStatus is the state of a chunked upload: its manifest and the
+// numbers of the chunks received so far.
+type UploadStatus struct {
+	Item     *models.BinaryData `json:"item"`
+	Received []int              `json:"received"`
+}
+
+// BinaryHandler serves chunked uploads and downloads of binary data. The
+// client packs and seals every chunk with package stream; the server only
+// checks the chunk lengths and the hash of the sealed chunks.
+type BinaryHandler struct {
+	repo   repository.BinaryRepo
+	limits *Limits
+}
+
+func NewBinaryHandler(repo repository.BinaryRepo) *BinaryHandler {
+	return &BinaryHandler{repo: repo}
+}
+
+// SetLimits makes new uploads subject to limits.
+func (bh *BinaryHandler) SetLimits(limits *Limits) {
+	bh.limits = limits
+}
+
+// RegisterBinaryRoutes adds the chunked upload and download routes to r.
+func RegisterBinaryRoutes(r gin.IRoutes, bh *BinaryHandler) {
+	r.POST("/upload-binary-data", bh.StartUploadHandler())
+	r.GET("/upload-binary-data/:id", bh.UploadStatusHandler())
+	r.PUT("/upload-binary-data/:id/:number", bh.UploadChunkHandler())
+	r.POST("/upload-binary-data/:id/complete", bh.CompleteUploadHandler())
+	r.GET("/download-binary-data/:id", bh.DownloadHandler())
+	r.GET("/download-binary-data/:id/:number", bh.DownloadChunkHandler())
+}
+
+// StartUploadHandler creates a chunked binary data item from its encrypted
+// manifest and answers with its id. An upload of content the user already
+// stored is complete at once, and no chunks have to be sent.
+func (bh *BinaryHandler) StartUploadHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if !bh.limits.allowItem(ctx, userID.(string), &models.BinaryData{}) {
+			return
+		}
+		var bd models.BinaryData
+		if !decryptRequest(ctx, &bd) {
+			return
+		}
+		if err := validateManifest(&bd); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		// Chunks are bounded by the declared size, so it is what counts.
+		if !bh.limits.allowBytes(ctx, userID.(string), bd.Size, true) {
+			return
+		}
+
+		clearModel(&bd)
+		bd.UserID = userID.(string)
+		bd.Chunked, bd.Complete, bd.CipherHash = true, false, ""
+		bd.Content = []byte{}
+		if err := bh.repo.SaveNewUpload(&bd); err != nil {
+			if errors.Is(err, repository.ErrFolderNotFound) {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Folder not found"})
+				return
+			}
+			log.Printf("Error starting upload: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message":  "Upload has been started",
+				"status":   http.StatusCreated,
+				"id":       bd.ID,
+				"complete": bd.Complete,
+			},
+		)
+	}
+}
+
+// validateManifest checks the chunk layout of bd.
+func validateManifest(bd *models.BinaryData) error {
+	bd.SHA256 = strings.ToLower(bd.SHA256)
+	if bd.MIMEType == "" {
+		bd.MIMEType = "application/octet-stream"
+	}
+	switch {
+	case !validSHA256(bd.SHA256):
+		return errInvalidSHA256
+	case bd.Compression != "" && bd.Compression != stream.CompressionZstd:
+		return errCompression
+	case bd.Size < 0:
+		return errors.New("size must not be negative")
+	case bd.ChunkSize < stream.MinChunkSize || bd.ChunkSize > stream.MaxChunkSize:
+		return errors.New("chunk size is out of range")
+	case bd.Chunks != stream.Chunks(bd.Size, bd.ChunkSize):
+		return errors.New("number of chunks does not match the size")
+	case len(bd.Header) != stream.HeaderSize:
+		return stream.ErrInvalidHeader
+	}
+	return describeFile(bd)
+}
+
+// describeContent fills in the file metadata of inline binary data.
+func describeContent(bd *models.BinaryData) error {
+	sum := sha256.Sum256(bd.Content)
+	hash := hex.EncodeToString(sum[:])
+	if bd.SHA256 != "" && !strings.EqualFold(bd.SHA256, hash) {
+		return errContentSHA256
+	}
+	bd.SHA256 = hash
+	bd.Size = int64(len(bd.Content))
+	if bd.MIMEType == "" {
+		bd.MIMEType = http.DetectContentType(bd.Content)
+	}
+	return describeFile(bd)
+}
+
+// describeFile cleans the file name of bd and checks its metadata.
+func describeFile(bd *models.BinaryData) error {
+	bd.ContentKey = strings.ToLower(bd.ContentKey)
+	if bd.ContentKey != "" && !validSHA256(bd.ContentKey) {
+		return errInvalidContentKey
+	}
+	bd.FileName = path.Base(strings.ReplaceAll(bd.FileName, `\`, "/"))
+	switch bd.FileName {
+	case ".", "..", "/":
+		bd.FileName = ""
+	}
+	if _, _, err := mime.ParseMediaType(bd.MIMEType); err != nil {
+		return errInvalidMIMEType
+	}
+	return nil
+}
+
+func validSHA256(hash string) bool {
+	sum, err := hex.DecodeString(hash)
+	return err == nil && len(sum) == sha256.Size
+}
+
+// UploadStatusHandler reports the manifest and received chunks of an
+// upload, so that an interrupted upload can be resumed.
+func (bh *BinaryHandler) UploadStatusHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		bd, err := bh.repo.GetUpload(userID.(string), id)
+		if err != nil {
+			respondUploadError(ctx, err)
+			return
+		}
+		received, err := bh.repo.GetChunkNumbers(userID.(string), id)
+		if err != nil {
+			respondUploadError(ctx, err)
+			return
+		}
+		respondEncrypted(ctx, "Upload status", UploadStatus{Item: bd, Received: received})
+	}
+}
+
+// UploadChunkHandler stores one sealed chunk sent as the raw request body.
+// Chunks may be sent in any order and sent again until the upload is
+// complete.
+func (bh *BinaryHandler) UploadChunkHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+		number, ok := chunkParam(ctx)
+		if !ok {
+			return
+		}
+
+		bd, err := bh.repo.GetUpload(userID.(string), id)
+		if err != nil {
+			respondUploadError(ctx, err)
+			return
+		}
+		expected := stream.SealedSize(bd.Size, bd.ChunkSize, number)
+		if expected < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chunk number"})
+			return
+		}
+		// Packed chunks are at most PackOverhead longer and can be shorter.
+		shortest := expected
+		if bd.Compression != "" {
+			expected += stream.PackOverhead
+			shortest = stream.Overhead + stream.PackOverhead
+		}
+
+		body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, int64(expected))
+		data, err := io.ReadAll(body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk is too large"})
+				return
+			}
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(data) < shortest || len(data) > expected {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chunk length"})
+			return
+		}
+
+		if err := bh.repo.SaveChunk(userID.(string), id, number, data); err != nil {
+			respondUploadError(ctx, err)
+			return
+		}
+		ctx.JSON(
+			http.StatusOK, gin.H{
+				"message": "Chunk has been uploaded",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// CompleteUploadHandler checks the hex SHA-256 of the sealed chunks sent
+// as {"sha256": ...} and makes the upload downloadable.
+func (bh *BinaryHandler) CompleteUploadHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+		var req struct {
+			SHA256 string `json:"sha256" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := bh.repo.CompleteUpload(userID.(string), id, req.SHA256); err != nil {
+			respondUploadError(ctx, err)
+			return
+		}
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Upload has been completed",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// DownloadHandler answers with one encrypted binary data item: inline items
+// with their content, chunked ones with the manifest of the complete upload.
+func (bh *BinaryHandler) DownloadHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		bd, err := bh.repo.Get(userID.(string), id)
+		if err != nil {
+			respondUploadError(ctx, err)
+			return
+		}
+		if bd.Chunked && !bd.Complete {
+			respondUploadError(ctx, repository.ErrUploadIncomplete)
+			return
+		}
+		respondEncrypted(ctx, "Binary data", bd)
+	}
+}
+
+// DownloadChunkHandler answers with one sealed chunk as the raw body.
+func (bh *BinaryHandler) DownloadChunkHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+		number, ok := chunkParam(ctx)
+		if !ok {
+			return
+		}
+
+		data, err := bh.repo.GetChunk(userID.(string), id, number)
+		if err != nil {
+			respondUploadError(ctx, err)
+			return
+		}
+		ctx.Data(http.StatusOK, "application/octet-stream", data)
+	}
+}
+
+func chunkParam(ctx *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(ctx.Param("number"))
+	if err != nil || number < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chunk number"})
+		return 0, false
+	}
+	return number, true
+}
+
+func respondUploadError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Binary data not found"})
+	case errors.Is(err, repository.ErrUploadComplete):
+		ctx.JSON(http.StatusConflict, gin.H{"error": repository.ErrUploadComplete.Error()})
+	case errors.Is(err, repository.ErrUploadIncomplete):
+		ctx.JSON(http.StatusConflict, gin.H{"error": repository.ErrUploadIncomplete.Error()})
+	case errors.Is(err, repository.ErrMissingChunks):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": repository.ErrMissingChunks.Error()})
+	case errors.Is(err, repository.ErrHashMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": repository.ErrHashMismatch.Error()})
+	default:
+		log.Printf("Error handling binary data upload: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type MockBinaryRepo struct {
+	uploads map[uint]*models.BinaryData
+	chunks  map[uint]map[int][]byte
+}
+
+func newMockBinaryRepo() *MockBinaryRepo {
+	return &MockBinaryRepo{
+		uploads: map[uint]*models.BinaryData{},
+		chunks:  map[uint]map[int][]byte{},
+	}
+}
+
+func (m *MockBinaryRepo) SaveNewUpload(bd *models.BinaryData) error {
+	bd.ID = uint(len(m.uploads) + 1)
+	m.uploads[bd.ID] = bd
+	m.chunks[bd.ID] = map[int][]byte{}
+	return nil
+}
+
+func (m *MockBinaryRepo) GetUpload(userID string, id uint) (*models.BinaryData, error) {
+	bd, ok := m.uploads[id]
+	if !ok || bd.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return bd, nil
+}
+
+func (m *MockBinaryRepo) Get(userID string, id uint) (*models.BinaryData, error) {
+	return m.GetUpload(userID, id)
+}
+
+func (m *MockBinaryRepo) SaveChunk(userID string, id uint, number int, data []byte) error {
+	bd, err := m.GetUpload(userID, id)
+	if err != nil {
+		return err
+	}
+	if bd.Complete {
+		return repository.ErrUploadComplete
+	}
+	m.chunks[id][number] = data
+	return nil
+}
+
+func (m *MockBinaryRepo) GetChunkNumbers(userID string, id uint) ([]int, error) {
+	if _, err := m.GetUpload(userID, id); err != nil {
+		return nil, err
+	}
+	var numbers []int
+	for n := 0; n < m.uploads[id].Chunks; n++ {
+		if _, ok := m.chunks[id][n]; ok {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers, nil
+}
+
+func (m *MockBinaryRepo) CompleteUpload(userID string, id uint, hash string) error {
+	bd, err := m.GetUpload(userID, id)
+	if err != nil {
+		return err
+	}
+	if len(m.chunks[id]) != bd.Chunks {
+		return repository.ErrMissingChunks
+	}
+	h := sha256.New()
+	for n := 0; n < bd.Chunks; n++ {
+		h.Write(m.chunks[id][n])
+	}
+	if hex.EncodeToString(h.Sum(nil)) != hash {
+		return repository.ErrHashMismatch
+	}
+	bd.Complete, bd.CipherHash = true, hash
+	return nil
+}
+
+func (m *MockBinaryRepo) GetChunk(userID string, id uint, number int) ([]byte, error) {
+	bd, err := m.GetUpload(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !bd.Complete {
+		return nil, repository.ErrUploadIncomplete
+	}
+	data, ok := m.chunks[id][number]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return data, nil
+}
+
+func TestBinaryHandler_Upload(t *testing.T) {
+	repo := newMockBinaryRepo()
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "test_user")
+		},
+	)
+	RegisterBinaryRoutes(router, NewBinaryHandler(repo))
+	send := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	manifest := func(bd models.BinaryData) []byte {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, bd)})
+		return body
+	}
+
+	header, err := stream.NewHeader()
+	require.NoError(t, err)
+	content := bytes.Repeat([]byte("a"), stream.MinChunkSize+10)
+	sum := sha256.Sum256(content)
+	bd := models.BinaryData{
+		FileName:  "dir/a.txt",
+		SHA256:    hex.EncodeToString(sum[:]),
+		Size:      int64(len(content)),
+		ChunkSize: stream.MinChunkSize,
+		Chunks:    2,
+		Header:    header,
+	}
+	invalid := bd
+	invalid.Chunks = 3
+	w := send("POST", "/upload-binary-data", manifest(invalid))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	invalid = bd
+	invalid.SHA256 = "abc"
+	w = send("POST", "/upload-binary-data", manifest(invalid))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = send("POST", "/upload-binary-data", manifest(bd))
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"id": 1`)
+
+	c, err := stream.New(testPersonalKey, header)
+	require.NoError(t, err)
+	sealed := [][]byte{
+		c.Seal(0, content[:stream.MinChunkSize], false),
+		c.Seal(1, content[stream.MinChunkSize:], true),
+	}
+	h := sha256.New()
+	h.Write(sealed[0])
+	h.Write(sealed[1])
+	hash, _ := json.Marshal(gin.H{"sha256": hex.EncodeToString(h.Sum(nil))})
+
+	assert.Equal(t, http.StatusOK, send("PUT", "/upload-binary-data/1/1", sealed[1]).Code)
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/upload-binary-data/1/0", sealed[1]).Code)
+	tooLarge := append(bytes.Clone(sealed[0]), 0)
+	assert.Equal(
+		t, http.StatusRequestEntityTooLarge, send("PUT", "/upload-binary-data/1/0", tooLarge).Code,
+	)
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/upload-binary-data/1/2", sealed[1]).Code)
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/upload-binary-data/2/0", sealed[0]).Code)
+
+	w = send("GET", "/upload-binary-data/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status UploadStatus
+	decryptedBody(t, w, &status)
+	assert.Equal(t, []int{1}, status.Received)
+	assert.Equal(t, header, status.Item.Header)
+
+	w = send("POST", "/upload-binary-data/1/complete", hash)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, http.StatusConflict, send("GET", "/download-binary-data/1", nil).Code)
+	assert.Equal(t, http.StatusOK, send("PUT", "/upload-binary-data/1/0", sealed[0]).Code)
+	assert.Equal(t, http.StatusOK, send("POST", "/upload-binary-data/1/complete", hash).Code)
+	assert.Equal(t, http.StatusConflict, send("PUT", "/upload-binary-data/1/0", sealed[0]).Code)
+
+	w = send("GET", "/download-binary-data/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var got models.BinaryData
+	decryptedBody(t, w, &got)
+	assert.True(t, got.Complete)
+	assert.Equal(t, bd.Size, got.Size)
+	assert.Equal(t, bd.SHA256, got.SHA256)
+	assert.Equal(t, "a.txt", got.FileName)
+	assert.Equal(t, "application/octet-stream", got.MIMEType)
+
+	var downloaded []byte
+	for n := 0; n < got.Chunks; n++ {
+		w = send("GET", "/download-binary-data/1/"+strconv.Itoa(n), nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		chunk, err := c.Open(n, w.Body.Bytes(), n == got.Chunks-1)
+		require.NoError(t, err)
+		downloaded = append(downloaded, chunk...)
+	}
+	assert.Equal(t, content, downloaded)
+}
+
+func TestBinaryDataSpec_RejectsChunked(t *testing.T) {
+	spec := BinaryDataSpec(newMockStore[models.BinaryData]())
+	assert.Error(t, spec.Prepare("test_user", &models.BinaryData{Chunked: true}))
+	assert.NoError(t, spec.Prepare("test_user", &models.BinaryData{Content: []byte("x")}))
+}
+
+func TestBinaryDataSpec_DescribesContent(t *testing.T) {
+	spec := BinaryDataSpec(newMockStore[models.BinaryData]())
+	bd := &models.BinaryData{FileName: `C:\docs\note.txt`, Content: []byte("hello")}
+	require.NoError(t, spec.Prepare("test_user", bd))
+	assert.Equal(t, "note.txt", bd.FileName)
+	assert.Equal(t, "text/plain; charset=utf-8", bd.MIMEType)
+	assert.Equal(t, int64(5), bd.Size)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", bd.SHA256)
+
+	png := &models.BinaryData{Content: []byte("\x89PNG\r\n\x1a\n")}
+	require.NoError(t, spec.Prepare("test_user", png))
+	assert.Equal(t, "image/png", png.MIMEType)
+
+	var invalid *invalidItemError
+	err := spec.Prepare("test_user", &models.BinaryData{Content: []byte("x"), SHA256: bd.SHA256})
+	assert.ErrorAs(t, err, &invalid)
+	err = spec.Prepare("test_user", &models.BinaryData{Content: []byte("x"), MIMEType: "not a type"})
+	assert.ErrorAs(t, err, &invalid)
+}
+
+func TestBinaryHandler_PackedChunks(t *testing.T) {
+	repo := newMockBinaryRepo()
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "test_user")
+		},
+	)
+	RegisterBinaryRoutes(router, NewBinaryHandler(repo))
+	send := func(method, path string, body []byte) int {
+		req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	header, err := stream.NewHeader()
+	require.NoError(t, err)
+	content := bytes.Repeat([]byte("a"), stream.MinChunkSize)
+	sum := sha256.Sum256(content)
+	bd := models.BinaryData{
+		SHA256:      hex.EncodeToString(sum[:]),
+		Size:        int64(len(content)),
+		ChunkSize:   stream.MinChunkSize,
+		Chunks:      1,
+		Header:      header,
+		Compression: "gzip",
+	}
+	manifest := func() []byte {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, bd)})
+		return body
+	}
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", "/upload-binary-data", manifest()))
+	bd.Compression = stream.CompressionZstd
+	require.Equal(t, http.StatusCreated, send("POST", "/upload-binary-data", manifest()))
+
+	c, err := stream.New(testPersonalKey, header)
+	require.NoError(t, err)
+	packed := c.Seal(0, stream.Pack(content), true)
+	assert.Less(t, len(packed), len(content))
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/upload-binary-data/1/0", packed[:stream.Overhead]))
+	assert.Equal(t, http.StatusOK, send("PUT", "/upload-binary-data/1/0", packed))
+	raw := c.Seal(0, append([]byte{0}, content...), true)
+	assert.Equal(t, http.StatusOK, send("PUT", "/upload-binary-data/1/0", raw))
+	assert.Equal(
+		t, http.StatusRequestEntityTooLarge,
+		send("PUT", "/upload-binary-data/1/0", append(bytes.Clone(raw), 0)),
+	)
+}
@@ -1,40 +1,21 @@
 package handlers
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/otp"
 	"github.com/elina-chertova/auth-keeper.git/internal/repository"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/elina-chertova/auth-keeper.git/internal/sshkey"
+	"github.com/elina-chertova/auth-keeper.git/internal/validation"
 	"github.com/gin-gonic/gin"
-	"log"
-	"net/http"
 )
 
-var errTokenNotFound = errors.New("token not found")
-
-type DataHandler struct {
-	lp repository.LoginPasswordRepo
-	bd repository.BinaryDataRepo
-	cc repository.CreditCardRepo
-	td repository.TextDataRepo
-}
-
-func NewDataHandler(
-	lp repository.LoginPasswordRepo,
-	bd repository.BinaryDataRepo,
-	cc repository.CreditCardRepo,
-	td repository.TextDataRepo,
-) *DataHandler {
-	return &DataHandler{
-		lp: lp,
-		bd: bd,
-		cc: cc,
-		td: td,
-	}
-}
+var (
+	errTokenNotFound       = errors.New("token not found")
+	errLinkedLoginNotFound = errors.New("Login-Password entry to link not found")
+)
 
 func extractUserFromRequest(ctx *gin.Context) (string, error) {
 	token, exists := ctx.Get("token")
@@ -51,452 +32,160 @@ func extractUserFromRequest(ctx *gin.Context) (string, error) {
 	return userID, nil
 }
 
-func (dh *DataHandler) AddCreditCardHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, exists := ctx.Get("userID")
-		if !exists {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			return
-		}
-
-		var encryptedData struct {
-			Data string `json:"data"`
-		}
-		if err := ctx.ShouldBindJSON(&encryptedData); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		decodedData, err := base64.StdEncoding.DecodeString(encryptedData.Data)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode base64 data"})
-			return
-		}
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey.([]byte))
-		if err != nil {
-			log.Printf("Failed to decrypt data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
-			return
-		}
+// DataStores holds the repositories of the built-in secret types and the
+// limits their routes enforce; nil Limits allow everything.
+type DataStores struct {
+	LoginPasswords repository.Store[models.LoginPassword]
+	TextData       repository.Store[models.TextData]
+	BinaryData     repository.Store[models.BinaryData]
+	CreditCards    repository.Store[models.CreditCard]
+	OTPSecrets     repository.Store[models.OTPSecret]
+	SSHKeys        repository.Store[models.SSHKey]
+	Limits         *Limits
+}
 
-		var creditCard models.CreditCard
-		if err := json.Unmarshal(decryptedData, &creditCard); err != nil {
-			log.Printf("Failed to unmarshal decrypted data: %v", err)
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal decrypted data"})
-			return
-		}
+// RegisterDataRoutes registers the add and list routes of every built-in
+// secret type on r.
+func RegisterDataRoutes(r gin.IRoutes, s DataStores) {
+	RegisterItem(r, limited(CreditCardSpec(s.CreditCards), s.Limits))
+	RegisterItem(r, limited(TextDataSpec(s.TextData), s.Limits))
+	RegisterItem(r, limited(BinaryDataSpec(s.BinaryData), s.Limits))
+	RegisterItem(r, limited(LoginPasswordSpec(s.LoginPasswords), s.Limits))
+	RegisterItem(r, limited(OTPSecretSpec(s.OTPSecrets, s.LoginPasswords), s.Limits))
+	RegisterItem(r, limited(SSHKeySpec(s.SSHKeys), s.Limits))
+}
 
-		creditCard.UserID = userID.(string)
-		err = dh.cc.SaveNewCreditCard(&creditCard)
-		if err != nil {
-			log.Printf("Error adding credit card: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		ctx.IndentedJSON(
-			http.StatusCreated, gin.H{
-				"message": "Credit Card has been added",
-				"status":  http.StatusCreated,
-			},
-		)
-	}
+func limited[T any](spec ItemSpec[T], limits *Limits) ItemSpec[T] {
+	spec.Limits = limits
+	return spec
 }
 
-func (dh *DataHandler) GetCreditCardHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, err := extractUserFromRequest(ctx)
-		if err != nil {
-			if errors.Is(err, errTokenNotFound) {
-				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			} else {
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func CreditCardSpec(store repository.Store[models.CreditCard]) ItemSpec[models.CreditCard] {
+	return ItemSpec[models.CreditCard]{
+		Route:          "card",
+		Store:          store,
+		AddedMessage:   "Credit Card has been added",
+		ListMessage:    "Credit card list",
+		InvalidMessage: "Invalid credit card",
+		Prepare: func(_ string, creditCard *models.CreditCard) error {
+			validation.NormalizeCreditCard(creditCard)
+			if err := validation.ValidateCreditCard(creditCard); err != nil {
+				return invalidItem(err)
 			}
-			log.Printf("Error extracting user from token: %v", err)
-			return
-		}
-
-		creditCard, err := dh.cc.GetCreditCardList(userID)
-		if err != nil {
-			log.Printf("Error getting credit card: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		creditCardsJSON, err := json.Marshal(creditCard)
-		if err != nil {
-			ctx.JSON(
-				http.StatusInternalServerError,
-				gin.H{"error": "Failed to marshal credit cards"},
-			)
-			return
-		}
-
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		encryptedData, err := security.EncryptData(creditCardsJSON, personalKey.([]byte))
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt data"})
-			return
-		}
-
-		encodedData := base64.StdEncoding.EncodeToString(encryptedData)
-
-		ctx.IndentedJSON(
-			http.StatusOK, gin.H{
-				"message": "Credit card list",
-				"body":    encodedData,
-			},
-		)
+			return nil
+		},
+		Present: func(creditCards []*models.CreditCard) {
+			for _, cc := range creditCards {
+				if cc.Brand == "" {
+					cc.Brand = validation.DetectBrand(cc.CardNumber)
+				}
+			}
+		},
 	}
 }
 
-func (dh *DataHandler) AddBinaryDataHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, exists := ctx.Get("userID")
-		if !exists {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			return
-		}
-
-		var encryptedData struct {
-			Data string `json:"data"`
-		}
-		if err := ctx.ShouldBindJSON(&encryptedData); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		decodedData, err := base64.StdEncoding.DecodeString(encryptedData.Data)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode base64 data"})
-			return
-		}
-
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey.([]byte))
-		if err != nil {
-			log.Printf("Failed to decrypt data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
-			return
-		}
-
-		var binaryData models.BinaryData
-		if err := json.Unmarshal(decryptedData, &binaryData); err != nil {
-			log.Printf("Failed to unmarshal decrypted data: %v", err)
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal decrypted data"})
-			return
-		}
-
-		binaryData.UserID = userID.(string)
-		err = dh.bd.SaveNewBinaryData(&binaryData)
-		if err != nil {
-			log.Printf("Error adding binary data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		ctx.IndentedJSON(
-			http.StatusCreated, gin.H{
-				"message": "Binary data have been added",
-				"status":  http.StatusCreated,
-			},
-		)
+func TextDataSpec(store repository.Store[models.TextData]) ItemSpec[models.TextData] {
+	return ItemSpec[models.TextData]{
+		Route:        "text-data",
+		Store:        store,
+		AddedMessage: "Text data have been added",
+		ListMessage:  "Text data list",
 	}
 }
 
-func (dh *DataHandler) GetBinaryDataHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, err := extractUserFromRequest(ctx)
-		if err != nil {
-			if errors.Is(err, errTokenNotFound) {
-				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			} else {
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// BinaryDataSpec accepts inline content only; chunked content goes through
+// the upload routes of BinaryHandler. Listing returns the file metadata
+// without the contents, which are fetched one at a time from
+// download-binary-data.
+func BinaryDataSpec(store repository.Store[models.BinaryData]) ItemSpec[models.BinaryData] {
+	return ItemSpec[models.BinaryData]{
+		Route:        "binary-data",
+		Store:        store,
+		AddedMessage: "Binary data have been added",
+		ListMessage:  "Binary data list",
+		Prepare: func(_ string, binaryData *models.BinaryData) error {
+			if binaryData.Chunked {
+				return invalidItem(errChunkedBinaryData)
 			}
-			log.Printf("Error extracting user from token: %v", err)
-			return
-		}
-
-		binaryData, err := dh.bd.GetBinaryData(userID)
-		if err != nil {
-			log.Printf("Error getting binary data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		binaryDataJSON, err := json.Marshal(binaryData)
-		if err != nil {
-			ctx.JSON(
-				http.StatusInternalServerError,
-				gin.H{"error": "Failed to marshal binary data"},
-			)
-			return
-		}
-
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		encryptedData, err := security.EncryptData(binaryDataJSON, personalKey.([]byte))
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt data"})
-			return
-		}
-
-		encodedData := base64.StdEncoding.EncodeToString(encryptedData)
-
-		ctx.IndentedJSON(
-			http.StatusOK, gin.H{
-				"message": "Binary data list",
-				"body":    encodedData,
-			},
-		)
-	}
-}
-
-func (dh *DataHandler) AddTextDataHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, exists := ctx.Get("userID")
-		if !exists {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			return
-		}
-
-		var encryptedData struct {
-			Data string `json:"data"`
-		}
-		if err := ctx.ShouldBindJSON(&encryptedData); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		decodedData, err := base64.StdEncoding.DecodeString(encryptedData.Data)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode base64 data"})
-			return
-		}
-
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey.([]byte))
-		if err != nil {
-			log.Printf("Failed to decrypt data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
-			return
-		}
-
-		var textData models.TextData
-		if err := json.Unmarshal(decryptedData, &textData); err != nil {
-			log.Printf("Failed to unmarshal decrypted data: %v", err)
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal decrypted data"})
-			return
-		}
-
-		textData.UserID = userID.(string)
-		err = dh.td.SaveNewTextData(&textData)
-		if err != nil {
-			log.Printf("Error adding text data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		ctx.IndentedJSON(
-			http.StatusCreated, gin.H{
-				"message": "Text data have been added",
-				"status":  http.StatusCreated,
-			},
-		)
+			if err := describeContent(binaryData); err != nil {
+				return invalidItem(err)
+			}
+			return nil
+		},
+		Size: func(binaryData *models.BinaryData) int64 {
+			return int64(len(binaryData.Content))
+		},
 	}
 }
 
-func (dh *DataHandler) GetTextDataHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, err := extractUserFromRequest(ctx)
-		if err != nil {
-			if errors.Is(err, errTokenNotFound) {
-				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			} else {
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func LoginPasswordSpec(
+	store repository.Store[models.LoginPassword],
+) ItemSpec[models.LoginPassword] {
+	return ItemSpec[models.LoginPassword]{
+		Route:        "login-password",
+		Store:        store,
+		AddedMessage: "Login-Password data have been added",
+		ListMessage:  "Login-Password data list",
+		Prepare: func(userID string, lp *models.LoginPassword) error {
+			// Restored backups and versions hold the hash already.
+			if security.IsPasswordHash(lp.Password) {
+				return nil
 			}
-			log.Printf("Error extracting user from token: %v", err)
-			return
-		}
-
-		textData, err := dh.td.GetTextData(userID)
-		if err != nil {
-			log.Printf("Error getting text data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		textDataJSON, err := json.Marshal(textData)
-		if err != nil {
-			ctx.JSON(
-				http.StatusInternalServerError,
-				gin.H{"error": "Failed to marshal text data"},
-			)
-			return
-		}
-
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		encryptedData, err := security.EncryptData(textDataJSON, personalKey.([]byte))
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt data"})
-			return
-		}
-
-		encodedData := base64.StdEncoding.EncodeToString(encryptedData)
-
-		ctx.IndentedJSON(
-			http.StatusOK, gin.H{
-				"message": "Text data list",
-				"body":    encodedData,
-			},
-		)
+			hashed, err := security.HashPassword(lp.Password)
+			if err != nil {
+				return fmt.Errorf("error hashing password: %w", err)
+			}
+			lp.Password = hashed
+			return nil
+		},
 	}
 }
 
-func (dh *DataHandler) AddLoginPasswordHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, exists := ctx.Get("userID")
-		if !exists {
-			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			return
-		}
-
-		var encryptedData struct {
-			Data string `json:"data"`
-		}
-		if err := ctx.ShouldBindJSON(&encryptedData); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		decodedData, err := base64.StdEncoding.DecodeString(encryptedData.Data)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode base64 data"})
-			return
-		}
-
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		decryptedData, err := security.DecryptData(decodedData, personalKey.([]byte))
-		if err != nil {
-			log.Printf("Failed to decrypt data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
-			return
-		}
-
-		var loginPassword models.LoginPassword
-		if err := json.Unmarshal(decryptedData, &loginPassword); err != nil {
-			log.Printf("Failed to unmarshal decrypted data: %v", err)
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal decrypted data"})
-			return
-		}
-
-		hashed, err := security.HashPassword(loginPassword.Password)
-		if err != nil {
-			log.Printf("Error hashing password: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		loginPassword.Password = hashed
-		loginPassword.UserID = userID.(string)
-		err = dh.lp.SaveNewLoginPassword(&loginPassword)
-		if err != nil {
-			log.Printf("Error adding new login and password: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		ctx.IndentedJSON(
-			http.StatusCreated, gin.H{
-				"message": "Login-Password data have been added",
-				"status":  http.StatusCreated,
-			},
-		)
+// OTPSecretSpec validates the secret and, when it links a login-password
+// entry, that the entry belongs to the same user.
+func OTPSecretSpec(
+	store repository.Store[models.OTPSecret],
+	logins repository.Store[models.LoginPassword],
+) ItemSpec[models.OTPSecret] {
+	return ItemSpec[models.OTPSecret]{
+		Route:        "otp",
+		Store:        store,
+		AddedMessage: "OTP secret has been added",
+		ListMessage:  "OTP secret list",
+		Prepare: func(userID string, otpSecret *models.OTPSecret) error {
+			otp.Normalize(otpSecret)
+			if err := otp.Validate(otpSecret); err != nil {
+				return invalidItem(err)
+			}
+			if otpSecret.LoginPasswordID == nil {
+				return nil
+			}
+			lpData, err := logins.List(userID, repository.ListFilter{})
+			if err != nil {
+				return err
+			}
+			for _, lp := range lpData {
+				if lp.ID == *otpSecret.LoginPasswordID {
+					return nil
+				}
+			}
+			return invalidItem(errLinkedLoginNotFound)
+		},
 	}
 }
 
-func (dh *DataHandler) GetLoginPasswordHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		userID, err := extractUserFromRequest(ctx)
-		if err != nil {
-			if errors.Is(err, errTokenNotFound) {
-				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			} else {
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// SSHKeySpec derives the public key and fingerprint from the private key.
+func SSHKeySpec(store repository.Store[models.SSHKey]) ItemSpec[models.SSHKey] {
+	return ItemSpec[models.SSHKey]{
+		Route:        "ssh-key",
+		Store:        store,
+		AddedMessage: "SSH key has been added",
+		ListMessage:  "SSH key list",
+		Prepare: func(_ string, sshKey *models.SSHKey) error {
+			if err := sshkey.Normalize(sshKey); err != nil {
+				return invalidItem(err)
 			}
-			log.Printf("error extracting user from token: %v", err)
-			return
-		}
-
-		lpData, err := dh.lp.GetLoginPasswordData(userID)
-		if err != nil {
-			log.Printf("error getting login-password data: %v", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		lpDataJSON, err := json.Marshal(lpData)
-		if err != nil {
-			ctx.JSON(
-				http.StatusInternalServerError,
-				gin.H{"error": "Failed to marshal login-password data"},
-			)
-			return
-		}
-
-		personalKey, exists := ctx.Get("personalKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
-			return
-		}
-
-		encryptedData, err := security.EncryptData(lpDataJSON, personalKey.([]byte))
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt data"})
-			return
-		}
-
-		encodedData := base64.StdEncoding.EncodeToString(encryptedData)
-
-		ctx.IndentedJSON(
-			http.StatusOK, gin.H{
-				"message": "Login-Password data list",
-				"body":    encodedData,
-			},
-		)
+			return nil
+		},
 	}
 }
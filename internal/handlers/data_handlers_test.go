@@ -5,292 +5,1052 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/elina-chertova/auth-keeper.git/internal/sshkey"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
-type MockCreditCardRepo struct {
-	SaveNewCreditCardFunc func(card *models.CreditCard) error
-	GetCreditCardListFunc func(userID string) ([]*models.CreditCard, error)
+// mockStoreState is shared by a MockStore and the test driving it, so tests
+// that do not know the item type can still inject errors and inspect saves.
+type mockStoreState struct {
+	saveErr     error
+	listErr     error
+	organizeErr error
+	indexErr    error
+	updateErr   error
+	saved       []interface{}
+	filter      repository.ListFilter
+	page        repository.Page
+	organized   []models.MetaChange
+	indexed     map[uint][]string
+	deleted     []uint
+	restored    []uint
+	purged      []uint
 }
 
-func (m *MockCreditCardRepo) SaveNewCreditCard(card *models.CreditCard) error {
-	if m.SaveNewCreditCardFunc != nil {
-		return m.SaveNewCreditCardFunc(card)
+// MockStore identifies items by their 1-based position in items.
+type MockStore[T any] struct {
+	state    *mockStoreState
+	items    []*T
+	versions []*models.ItemVersion
+}
+
+func (m *MockStore[T]) Save(item *T) error {
+	if m.state.saveErr != nil {
+		return m.state.saveErr
+	}
+	m.state.saved = append(m.state.saved, item)
+	m.items = append(m.items, item)
+	return nil
+}
+
+func (m *MockStore[T]) SaveAll(items []*T) error {
+	if m.state.saveErr != nil {
+		return m.state.saveErr
+	}
+	for _, item := range items {
+		m.state.saved = append(m.state.saved, item)
+		m.items = append(m.items, item)
+		reflect.ValueOf(item).Elem().FieldByName("ID").SetUint(uint64(len(m.items)))
+	}
+	return nil
+}
+
+func (m *MockStore[T]) List(userID string, filter repository.ListFilter) ([]*T, error) {
+	m.state.filter = filter
+	if m.state.listErr != nil {
+		return nil, m.state.listErr
+	}
+	return m.items, nil
+}
+
+func (m *MockStore[T]) Page(
+	userID string,
+	filter repository.ListFilter,
+	page repository.Page,
+) (*repository.PageResult[T], error) {
+	items, err := m.List(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	m.state.page = page
+	res := &repository.PageResult[T]{Items: items, Total: int64(len(items))}
+	if page.Limit > 0 && len(items) > page.Limit {
+		res.Items = items[:page.Limit]
+		res.NextCursor = "next"
 	}
+	return res, nil
+}
+
+func (m *MockStore[T]) Get(userID string, id uint) (*T, error) {
+	if id == 0 || int(id) > len(m.items) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m.items[id-1], nil
+}
+
+func (m *MockStore[T]) Organize(userID string, id uint, change models.MetaChange) error {
+	if m.state.organizeErr != nil {
+		return m.state.organizeErr
+	}
+	m.state.organized = append(m.state.organized, change)
 	return nil
 }
 
-func (m *MockCreditCardRepo) GetCreditCardList(userID string) ([]*models.CreditCard, error) {
-	if m.GetCreditCardListFunc != nil {
-		return m.GetCreditCardListFunc(userID)
+func (m *MockStore[T]) Index(userID string, id uint, tokens []string) error {
+	if m.state.indexErr != nil {
+		return m.state.indexErr
+	}
+	if m.state.indexed == nil {
+		m.state.indexed = make(map[uint][]string)
+	}
+	m.state.indexed[id] = tokens
+	return nil
+}
+
+func (m *MockStore[T]) Update(userID string, id uint, item *T, seal repository.Sealer) error {
+	if m.state.updateErr != nil {
+		return m.state.updateErr
+	}
+	if id == 0 || int(id) > len(m.items) {
+		return gorm.ErrRecordNotFound
+	}
+	data, err := seal(m.items[id-1])
+	if err != nil {
+		return err
+	}
+	v := &models.ItemVersion{UserID: userID, ItemID: id, Data: data}
+	v.ID = uint(len(m.versions) + 1)
+	m.versions = append(m.versions, v)
+	m.items[id-1] = item
+	return nil
+}
+
+func (m *MockStore[T]) Versions(userID string, id uint) ([]*models.ItemVersion, error) {
+	if id == 0 || int(id) > len(m.items) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var versions []*models.ItemVersion
+	for i := len(m.versions) - 1; i >= 0; i-- {
+		if m.versions[i].ItemID == id {
+			versions = append(versions, m.versions[i])
+		}
+	}
+	return versions, nil
+}
+
+func (m *MockStore[T]) Version(userID string, id, version uint) (*models.ItemVersion, error) {
+	for _, v := range m.versions {
+		if v.ItemID == id && v.ID == version {
+			return v, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Delete records the id as trashed; Restore and Purge only accept trashed
+// ids.
+func (m *MockStore[T]) Delete(userID string, id uint) error {
+	if id == 0 || int(id) > len(m.items) {
+		return gorm.ErrRecordNotFound
+	}
+	m.state.deleted = append(m.state.deleted, id)
+	return nil
+}
+
+func (m *MockStore[T]) Trashed(userID string) ([]*T, error) {
+	var items []*T
+	for _, id := range m.state.deleted {
+		items = append(items, m.items[id-1])
+	}
+	return items, nil
+}
+
+func (m *MockStore[T]) trashed(id uint) bool {
+	for _, d := range m.state.deleted {
+		if d == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockStore[T]) Restore(userID string, id uint) error {
+	if !m.trashed(id) {
+		return gorm.ErrRecordNotFound
+	}
+	m.state.restored = append(m.state.restored, id)
+	return nil
+}
+
+func (m *MockStore[T]) Purge(userID string, id uint) error {
+	if !m.trashed(id) {
+		return gorm.ErrRecordNotFound
+	}
+	m.state.purged = append(m.state.purged, id)
+	return nil
+}
+
+func (m *MockStore[T]) EmptyTrash(userID string) (int64, error) {
+	return int64(len(m.state.deleted)), nil
+}
+
+func newMockStore[T any](items ...*T) *MockStore[T] {
+	return &MockStore[T]{state: &mockStoreState{}, items: items}
+}
+
+type dataTypeCase struct {
+	name  string
+	valid interface{}
+	// register adds the type's routes backed by a mock store that lists
+	// the valid item and returns the route and response messages.
+	register func(r gin.IRoutes, state *mockStoreState) (route, added, listed string)
+}
+
+func newDataTypeCase[T any, PT Owned[T]](
+	name string,
+	valid T,
+	spec func(repository.Store[T]) ItemSpec[T],
+) dataTypeCase {
+	return dataTypeCase{
+		name:  name,
+		valid: valid,
+		register: func(r gin.IRoutes, state *mockStoreState) (string, string, string) {
+			listed := valid
+			s := spec(&MockStore[T]{state: state, items: []*T{&listed}})
+			RegisterItem[T, PT](r, s)
+			return s.Route, s.AddedMessage, s.ListMessage
+		},
 	}
-	return nil, nil
 }
 
-func TestDataHandler_AddCreditCardHandler(t *testing.T) {
+func dataTypeCases(t *testing.T) []dataTypeCase {
+	key, err := sshkey.Generate(sshkey.TypeEd25519, 0, "work")
+	require.NoError(t, err)
+
+	return []dataTypeCase{
+		newDataTypeCase(
+			"credit card", models.CreditCard{
+				UserID:     "test_user",
+				CardNumber: "4111-1111-1111-1111",
+				ExpiryDate: "12/24",
+				CVV:        "123",
+				CardHolder: "John Doe",
+				Metadata:   "metadata",
+			}, CreditCardSpec,
+		),
+		newDataTypeCase(
+			"text data", models.TextData{
+				UserID:   "test_user",
+				Content:  "some text",
+				Metadata: "metadata",
+			}, TextDataSpec,
+		),
+		newDataTypeCase(
+			"binary data", models.BinaryData{
+				UserID:   "test_user",
+				Content:  []byte{0, 1, 2, 255},
+				Metadata: "metadata",
+			}, BinaryDataSpec,
+		),
+		newDataTypeCase(
+			"login-password", models.LoginPassword{
+				UserID:   "test_user",
+				Login:    "octocat",
+				Password: "violet-anvil-harbor-quiver",
+				Metadata: "metadata",
+			}, LoginPasswordSpec,
+		),
+		newDataTypeCase(
+			"otp secret", models.OTPSecret{
+				UserID:  "test_user",
+				Issuer:  "GitHub",
+				Account: "octocat",
+				Secret:  "JBSWY3DPEHPK3PXP",
+			}, func(store repository.Store[models.OTPSecret]) ItemSpec[models.OTPSecret] {
+				return OTPSecretSpec(store, newMockStore[models.LoginPassword]())
+			},
+		),
+		newDataTypeCase(
+			"ssh key", models.SSHKey{
+				UserID:     "test_user",
+				PrivateKey: key.PrivateKey,
+				Comment:    "work",
+			}, SSHKeySpec,
+		),
+	}
+}
+
+var testPersonalKey = []byte("1234567890123456")
+
+func encryptForTest(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	encrypted, err := security.EncryptData(data, testPersonalKey)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(encrypted)
+}
+
+func newDataRouter(setupContext func(ctx *gin.Context)) *gin.Engine {
 	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(
+		func(ctx *gin.Context) {
+			setupContext(ctx)
+			ctx.Next()
+		},
+	)
+	return router
+}
 
-	personalKey := []byte("1234567890123456")
+func TestAddItemHandler(t *testing.T) {
+	for _, tc := range dataTypeCases(t) {
+		t.Run(
+			tc.name, func(t *testing.T) {
+				validEncryptedData := encryptForTest(t, tc.valid)
 
-	validCreditCard := models.CreditCard{
-		UserID:     "test_user",
-		CardNumber: "1234-5678-9876-5432",
-		ExpiryDate: "12/24",
+				tests := []struct {
+					name                 string
+					setupContext         func(ctx *gin.Context)
+					requestBody          string
+					saveErr              error
+					expectedStatusCode   int
+					expectedResponseBody string
+				}{
+					{
+						name: "Valid Request",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("userID", "test_user")
+							ctx.Set("personalKey", testPersonalKey)
+						},
+						requestBody:        `{"data":"` + validEncryptedData + `"}`,
+						expectedStatusCode: http.StatusCreated,
+					},
+					{
+						name: "No userID in Context",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("personalKey", testPersonalKey)
+						},
+						requestBody:          `{"data":"` + validEncryptedData + `"}`,
+						expectedStatusCode:   http.StatusUnauthorized,
+						expectedResponseBody: `{"error":"Unauthorized"}`,
+					},
+					{
+						name: "Invalid JSON Body",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("userID", "test_user")
+							ctx.Set("personalKey", testPersonalKey)
+						},
+						requestBody:          `"invalid_json"`,
+						expectedStatusCode:   http.StatusBadRequest,
+						expectedResponseBody: `{"error":"json: cannot unmarshal string into Go value of type struct { Data string \"json:\\\"data\\\"\" }"}`,
+					},
+					{
+						name: "Base64 Decode Failure",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("userID", "test_user")
+							ctx.Set("personalKey", testPersonalKey)
+						},
+						requestBody:          `{"data":"invalid_base64"}`,
+						expectedStatusCode:   http.StatusBadRequest,
+						expectedResponseBody: `{"error":"Failed to decode base64 data"}`,
+					},
+					{
+						name: "No personalKey in Context",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("userID", "test_user")
+						},
+						requestBody:          `{"data":"` + validEncryptedData + `"}`,
+						expectedStatusCode:   http.StatusInternalServerError,
+						expectedResponseBody: `{"error":"Personal key not found"}`,
+					},
+					{
+						name: "Data Decryption Failure",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("userID", "test_user")
+							ctx.Set("personalKey", []byte("wrong_key"))
+						},
+						requestBody:          `{"data":"` + validEncryptedData + `"}`,
+						expectedStatusCode:   http.StatusInternalServerError,
+						expectedResponseBody: `{"error":"Failed to decrypt data"}`,
+					},
+					{
+						name: "Unmarshal Decrypted Data Failure",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("userID", "test_user")
+							ctx.Set("personalKey", testPersonalKey)
+						},
+						requestBody:          `{"data":"` + encryptForTest(t, json.RawMessage(`"invalid_json"`)) + `"}`,
+						expectedStatusCode:   http.StatusBadRequest,
+						expectedResponseBody: `{"error":"Failed to unmarshal decrypted data"}`,
+					},
+					{
+						name: "Save Failure",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("userID", "test_user")
+							ctx.Set("personalKey", testPersonalKey)
+						},
+						requestBody:          `{"data":"` + validEncryptedData + `"}`,
+						saveErr:              errors.New("failed to save"),
+						expectedStatusCode:   http.StatusInternalServerError,
+						expectedResponseBody: `{"error":"failed to save"}`,
+					},
+				}
+
+				for _, tt := range tests {
+					t.Run(
+						tt.name, func(t *testing.T) {
+							state := &mockStoreState{saveErr: tt.saveErr}
+							router := newDataRouter(tt.setupContext)
+							route, added, _ := tc.register(router, state)
+
+							req, _ := http.NewRequest(
+								"POST",
+								"/add-"+route,
+								bytes.NewBufferString(tt.requestBody),
+							)
+							req.Header.Set("Content-Type", "application/json")
+							w := httptest.NewRecorder()
+
+							router.ServeHTTP(w, req)
+
+							assert.Equal(t, tt.expectedStatusCode, w.Code, w.Body.String())
+							expected := tt.expectedResponseBody
+							if tt.expectedStatusCode == http.StatusCreated {
+								expected = `{"message":"` + added + `","status":201}`
+								require.Len(t, state.saved, 1)
+								saved, _ := json.Marshal(state.saved[0])
+								var owner struct {
+									UserID string `json:"user_id"`
+								}
+								require.NoError(t, json.Unmarshal(saved, &owner))
+								assert.Equal(t, "test_user", owner.UserID)
+							} else {
+								assert.Empty(t, state.saved)
+							}
+							assert.JSONEq(t, expected, w.Body.String())
+						},
+					)
+				}
+			},
+		)
+	}
+}
+
+func TestAddBatchHandler(t *testing.T) {
+	store := newMockStore[models.CreditCard]()
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "test_user")
+		},
+	)
+	RegisterItem(router, CreditCardSpec(store))
+	var ids []uint
+	add := func(cards []models.CreditCard) int {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, cards)})
+		req, _ := http.NewRequest("POST", "/add-card-batch", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp struct {
+			IDs []uint `json:"ids"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		ids = resp.IDs
+		return w.Code
+	}
+	valid := models.CreditCard{
+		CardNumber: "4111 1111 1111 1111",
+		ExpiryDate: "12/99",
 		CVV:        "123",
-		CardHolder: "John Doe",
-		Metadata:   "metadata",
+		CardHolder: "Test User",
 	}
-	validCreditCardJSON, _ := json.Marshal(validCreditCard)
-	encryptedData, _ := security.EncryptData(validCreditCardJSON, personalKey)
-	validEncryptedData := base64.StdEncoding.EncodeToString(encryptedData)
+	invalid := valid
+	invalid.CardNumber = "4111111111111112"
+
+	assert.Equal(t, http.StatusCreated, add([]models.CreditCard{valid, valid}))
+	assert.Equal(t, []uint{1, 2}, ids)
+	require.Len(t, store.state.saved, 2)
+	saved := store.state.saved[1].(*models.CreditCard)
+	assert.Equal(t, "test_user", saved.UserID)
+	assert.Equal(t, "4111111111111111", saved.CardNumber)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, add([]models.CreditCard{valid, invalid}))
+	assert.Equal(t, http.StatusBadRequest, add(nil))
+	assert.Equal(t, http.StatusBadRequest, add(make([]models.CreditCard, maxBatchItems+1)))
+	store.state.saveErr = repository.ErrFolderNotFound
+	assert.Equal(t, http.StatusUnprocessableEntity, add([]models.CreditCard{valid}))
+	assert.Len(t, store.state.saved, 2)
+}
+
+func TestAddItemHandler_IgnoresModel(t *testing.T) {
+	store := newMockStore[models.CreditCard]()
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "test_user")
+		},
+	)
+	RegisterItem(router, CreditCardSpec(store))
+	card := gin.H{
+		"ID":          42,
+		"CreatedAt":   "2020-01-01T00:00:00Z",
+		"DeletedAt":   "2020-01-02T00:00:00Z",
+		"card_number": "4111 1111 1111 1111",
+		"expiry_date": "12/99",
+		"cvv":         "123",
+		"card_holder": "Test User",
+	}
+	add := func(endpoint string, v interface{}) {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, v)})
+		req, _ := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, endpoint)
+	}
+	add("/add-card", card)
+	add("/add-card-batch", []gin.H{card})
+
+	require.Len(t, store.state.saved, 2)
+	single := store.state.saved[0].(*models.CreditCard)
+	assert.Zero(t, single.ID)
+	assert.True(t, single.CreatedAt.IsZero())
+	assert.Nil(t, single.DeletedAt)
+	batch := store.state.saved[1].(*models.CreditCard)
+	assert.Equal(t, uint(2), batch.ID)
+	assert.True(t, batch.CreatedAt.IsZero())
+	assert.Nil(t, batch.DeletedAt)
+}
+
+func TestListItemHandler(t *testing.T) {
+	token, _ := security.GenerateToken("test_user", 0)
+
+	for _, tc := range dataTypeCases(t) {
+		t.Run(
+			tc.name, func(t *testing.T) {
+				tests := []struct {
+					name               string
+					setupContext       func(ctx *gin.Context)
+					listErr            error
+					expectedStatusCode int
+				}{
+					{
+						name: "Valid Request",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("personalKey", testPersonalKey)
+							ctx.Set("token", token)
+						},
+						expectedStatusCode: http.StatusOK,
+					},
+					{
+						name: "No token in Context",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("personalKey", testPersonalKey)
+						},
+						expectedStatusCode: http.StatusUnauthorized,
+					},
+					{
+						name: "Failed to get list",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("personalKey", testPersonalKey)
+							ctx.Set("token", token)
+						},
+						listErr:            errors.New("failed to get list"),
+						expectedStatusCode: http.StatusInternalServerError,
+					},
+					{
+						name: "No personalKey in Context",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("token", token)
+						},
+						expectedStatusCode: http.StatusInternalServerError,
+					},
+					{
+						name: "Failed to encrypt data",
+						setupContext: func(ctx *gin.Context) {
+							ctx.Set("personalKey", []byte("wrong_key"))
+							ctx.Set("token", token)
+						},
+						expectedStatusCode: http.StatusInternalServerError,
+					},
+				}
+
+				for _, tt := range tests {
+					t.Run(
+						tt.name, func(t *testing.T) {
+							state := &mockStoreState{listErr: tt.listErr}
+							router := newDataRouter(tt.setupContext)
+							route, _, listed := tc.register(router, state)
+
+							req, _ := http.NewRequest("GET", "/get-"+route, nil)
+							w := httptest.NewRecorder()
+							router.ServeHTTP(w, req)
+
+							assert.Equal(t, tt.expectedStatusCode, w.Code)
+							if tt.expectedStatusCode != http.StatusOK {
+								return
+							}
+
+							var response struct {
+								Message string `json:"message"`
+								Body    string `json:"body"`
+							}
+							require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+							assert.Equal(t, listed, response.Message)
+							decoded, err := base64.StdEncoding.DecodeString(response.Body)
+							require.NoError(t, err)
+							decrypted, err := security.DecryptData(decoded, testPersonalKey)
+							require.NoError(t, err)
+
+							var items []map[string]interface{}
+							require.NoError(t, json.Unmarshal(decrypted, &items))
+							require.Len(t, items, 1)
+							assert.Equal(t, "test_user", items[0]["user_id"])
+						},
+					)
+				}
+			},
+		)
+	}
+}
+
+func TestListItemHandler_Filters(t *testing.T) {
+	token, _ := security.GenerateToken("test_user", 0)
+	withToken := func(ctx *gin.Context) {
+		ctx.Set("personalKey", testPersonalKey)
+		ctx.Set("token", token)
+	}
+	folderID := uint(3)
+	favorite := true
 
 	tests := []struct {
-		name                 string
-		setupContext         func(ctx *gin.Context)
-		requestBody          string
-		mockSaveCreditCard   func(card *models.CreditCard) error
-		expectedStatusCode   int
-		expectedResponseBody string
+		name               string
+		query              string
+		listErr            error
+		expectedStatusCode int
+		expectedFilter     repository.ListFilter
 	}{
 		{
-			name: "Valid Request",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", personalKey)
-			},
-			requestBody: validEncryptedData,
-			mockSaveCreditCard: func(card *models.CreditCard) error {
-				return nil
+			name:               "All filters",
+			query:              "?tag=work&tag=ops&folder_id=3&favorite=true",
+			expectedStatusCode: http.StatusOK,
+			expectedFilter: repository.ListFilter{
+				Tags: []string{"work", "ops"}, FolderID: &folderID, Favorite: &favorite,
 			},
-			expectedStatusCode:   http.StatusCreated,
-			expectedResponseBody: `{"message":"Credit Card has been added","status":201}`,
 		},
 		{
-			name: "No userID in Context",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("personalKey", personalKey)
-			},
-			requestBody:          validEncryptedData,
-			expectedStatusCode:   http.StatusUnauthorized,
-			expectedResponseBody: `{"error":"Unauthorized"}`,
+			name:               "Invalid folder_id",
+			query:              "?folder_id=abc",
+			expectedStatusCode: http.StatusBadRequest,
 		},
 		{
-			name: "Invalid JSON Body",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", personalKey)
-			},
-			requestBody:          "invalid_json",
-			expectedStatusCode:   http.StatusBadRequest,
-			expectedResponseBody: `{"error":"json: cannot unmarshal string into Go value of type struct { Data string \"json:\\\"data\\\"\" }"}`,
+			name:               "Invalid favorite",
+			query:              "?favorite=maybe",
+			expectedStatusCode: http.StatusBadRequest,
 		},
 		{
-			name: "Base64 Decode Failure",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", personalKey)
-			},
-			requestBody:          `{"data":"invalid_base64"}`,
-			expectedStatusCode:   http.StatusBadRequest,
-			expectedResponseBody: `{"error":"Failed to decode base64 data"}`,
+			name:               "Unknown folder",
+			query:              "?folder_id=3",
+			listErr:            repository.ErrFolderNotFound,
+			expectedStatusCode: http.StatusNotFound,
 		},
-		{
-			name: "No personalKey in Context",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				store := newMockStore[models.TextData]()
+				store.state.listErr = tt.listErr
+				router := newDataRouter(withToken)
+				RegisterItem(router, TextDataSpec(store))
+
+				req, _ := http.NewRequest("GET", "/get-text-data"+tt.query, nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, tt.expectedStatusCode, w.Code)
+				if tt.expectedStatusCode == http.StatusOK {
+					assert.Equal(t, tt.expectedFilter, store.state.filter)
+				}
 			},
-			requestBody:          validEncryptedData,
-			expectedStatusCode:   http.StatusInternalServerError,
-			expectedResponseBody: `{"error":"Personal key not found"}`,
-		},
+		)
+	}
+}
+
+func TestListItemHandler_Paging(t *testing.T) {
+	token, _ := security.GenerateToken("test_user", 0)
+	withToken := func(ctx *gin.Context) {
+		ctx.Set("personalKey", testPersonalKey)
+		ctx.Set("token", token)
+	}
+
+	tests := []struct {
+		name               string
+		query              string
+		listErr            error
+		expectedStatusCode int
+	}{
 		{
-			name: "Data Decryption Failure",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", []byte("wrong_key"))
-			},
-			requestBody:          validEncryptedData,
-			expectedStatusCode:   http.StatusInternalServerError,
-			expectedResponseBody: `{"error":"Failed to decrypt data"}`,
+			name:               "Page with sort and range",
+			query:              "?limit=1&cursor=abc&sort=name&order=desc&created_after=2024-01-01",
+			expectedStatusCode: http.StatusOK,
 		},
+		{name: "Invalid limit", query: "?limit=0", expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid order", query: "?order=up", expectedStatusCode: http.StatusBadRequest},
 		{
-			name: "Unmarshal Decrypted Data Failure",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", personalKey)
-			},
-			requestBody:          `{"data":"` + base64.StdEncoding.EncodeToString([]byte("invalid_json")) + `"}`,
-			expectedStatusCode:   http.StatusBadRequest,
-			expectedResponseBody: `{"error":"Failed to unmarshal decrypted data"}`,
+			name:               "Invalid created_after",
+			query:              "?created_after=yesterday",
+			expectedStatusCode: http.StatusBadRequest,
 		},
 		{
-			name: "Save Credit Card Failure",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", personalKey)
-			},
-			requestBody: validEncryptedData,
-			mockSaveCreditCard: func(card *models.CreditCard) error {
-				return errors.New("failed to save credit card")
-			},
-			expectedStatusCode:   http.StatusInternalServerError,
-			expectedResponseBody: `{"error":"failed to save credit card"}`,
+			name:               "Invalid cursor",
+			query:              "?cursor=abc",
+			listErr:            fmt.Errorf("failed: %w", repository.ErrInvalidCursor),
+			expectedStatusCode: http.StatusBadRequest,
 		},
 	}
-
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				mockCCRepo := &MockCreditCardRepo{
-					SaveNewCreditCardFunc: tt.mockSaveCreditCard,
-				}
+				store := newMockStore(
+					&models.TextData{UserID: "test_user", Content: "a"},
+					&models.TextData{UserID: "test_user", Content: "b"},
+				)
+				store.state.listErr = tt.listErr
+				router := newDataRouter(withToken)
+				RegisterItem(router, TextDataSpec(store))
 
-				dh := &DataHandler{
-					cc: mockCCRepo,
-				}
+				req, _ := http.NewRequest("GET", "/get-text-data"+tt.query, nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
 
-				router := gin.New()
-				router.Use(
-					func(ctx *gin.Context) {
-						tt.setupContext(ctx)
-						ctx.Next()
-					},
+				assert.Equal(t, tt.expectedStatusCode, w.Code)
+				if tt.expectedStatusCode != http.StatusOK {
+					return
+				}
+				assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+				assert.Equal(t, "next", w.Header().Get("X-Next-Cursor"))
+				assert.Equal(
+					t, repository.Page{Limit: 1, Cursor: "abc", Sort: "name", Desc: true},
+					store.state.page,
 				)
-				router.POST("/api/data/add-card", dh.AddCreditCardHandler())
+				require.NotNil(t, store.state.filter.CreatedAfter)
+				assert.Equal(t, "2024-01-01", store.state.filter.CreatedAfter.Format("2006-01-02"))
+			},
+		)
+	}
+}
 
-				reqBody := `{"data":"` + tt.requestBody + `"}`
+func TestOrganizeItemHandler(t *testing.T) {
+	withUser := func(ctx *gin.Context) {
+		ctx.Set("personalKey", testPersonalKey)
+		ctx.Set("userID", "test_user")
+	}
+	name := "Home router"
+	tags := []string{"home"}
+	change := models.MetaChange{DisplayName: &name, Tags: &tags}
 
-				req, _ := http.NewRequest(
-					"POST",
-					"/api/data/add-card",
-					bytes.NewBufferString(reqBody),
-				)
-				req.Header.Set("Content-Type", "application/json")
-				w := httptest.NewRecorder()
+	tests := []struct {
+		name               string
+		path               string
+		organizeErr        error
+		expectedStatusCode int
+	}{
+		{name: "Valid Request", path: "/organize-text-data/1", expectedStatusCode: http.StatusOK},
+		{name: "Invalid id", path: "/organize-text-data/x", expectedStatusCode: http.StatusBadRequest},
+		{
+			name:               "Item not found",
+			path:               "/organize-text-data/1",
+			organizeErr:        fmt.Errorf("failed: %w", gorm.ErrRecordNotFound),
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Folder not found",
+			path:               "/organize-text-data/1",
+			organizeErr:        repository.ErrFolderNotFound,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				store := newMockStore[models.TextData]()
+				store.state.organizeErr = tt.organizeErr
+				router := newDataRouter(withUser)
+				RegisterItem(router, TextDataSpec(store))
 
+				body, _ := json.Marshal(gin.H{"data": encryptForTest(t, change)})
+				req, _ := http.NewRequest("PUT", tt.path, bytes.NewBuffer(body))
+				w := httptest.NewRecorder()
 				router.ServeHTTP(w, req)
 
 				assert.Equal(t, tt.expectedStatusCode, w.Code)
+				if tt.expectedStatusCode == http.StatusOK {
+					assert.Equal(t, []models.MetaChange{change}, store.state.organized)
+				}
 			},
 		)
 	}
 }
 
-func TestDataHandler_GetCreditCardHandler(t *testing.T) {
-	gin.SetMode(gin.TestMode)
+func TestUpdateItemHandler_History(t *testing.T) {
+	withUser := func(ctx *gin.Context) {
+		ctx.Set("personalKey", testPersonalKey)
+		ctx.Set("userID", "test_user")
+	}
+	store := newMockStore(&models.LoginPassword{Login: "alice", Password: "old"})
+	router := newDataRouter(withUser)
+	RegisterItem(router, LoginPasswordSpec(store))
+	send := func(method, path string, v interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if v != nil {
+			body, _ = json.Marshal(gin.H{"data": encryptForTest(t, v)})
+		}
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
 
-	personalKey := []byte("1234567890123456")
+	update := models.LoginPassword{Login: "alice", Password: "new"}
+	assert.Equal(t, http.StatusOK, send("PUT", "/update-login-password/1", update).Code)
+	assert.True(t, security.CheckPasswordHash("new", store.items[0].Password))
+	assert.Equal(t, "test_user", store.items[0].UserID)
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/update-login-password/2", update).Code)
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/update-login-password/x", update).Code)
 
-	validCreditCard := models.CreditCard{
-		UserID:     "test_user",
+	w := send("GET", "/history-login-password/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []ItemVersion[models.LoginPassword]
+	decryptedBody(t, w, &history)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, uint(1), history[0].Version)
+		assert.Equal(t, "old", history[0].Item.Password)
+	}
+	assert.Equal(t, http.StatusNotFound, send("GET", "/history-login-password/2", nil).Code)
+
+	assert.Equal(t, http.StatusOK, send("POST", "/restore-login-password/1/1", nil).Code)
+	assert.Equal(t, "old", store.items[0].Password)
+	assert.Len(t, store.versions, 2)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/restore-login-password/1/9", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/restore-login-password/1/x", nil).Code)
+
+	store.state.updateErr = errors.New("db down")
+	assert.Equal(
+		t, http.StatusInternalServerError, send("PUT", "/update-login-password/1", update).Code,
+	)
+}
+
+func TestCreditCardSpec(t *testing.T) {
+	invalidCreditCard := models.CreditCard{
 		CardNumber: "1234-5678-9876-5432",
 		ExpiryDate: "12/24",
-		CVV:        "123",
+		CVV:        "12",
 		CardHolder: "John Doe",
-		Metadata:   "metadata",
 	}
-	validCreditCardList := []*models.CreditCard{&validCreditCard}
-	validCreditCardListJSON, _ := json.Marshal(validCreditCardList)
-	encryptedData, _ := security.EncryptData(validCreditCardListJSON, personalKey)
-	validEncryptedData := base64.StdEncoding.EncodeToString(encryptedData)
+	validCreditCard := invalidCreditCard
+	validCreditCard.CardNumber = "4111-1111-1111-1111"
+	validCreditCard.CVV = "123"
+
+	store := newMockStore[models.CreditCard]()
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("userID", "test_user")
+			ctx.Set("personalKey", testPersonalKey)
+		},
+	)
+	RegisterItem(router, CreditCardSpec(store))
+
+	post := func(card models.CreditCard) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(
+			"POST",
+			"/add-card",
+			bytes.NewBufferString(`{"data":"`+encryptForTest(t, card)+`"}`),
+		)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
 
-	token, _ := security.GenerateToken("test_user")
+	w := post(invalidCreditCard)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(
+		t,
+		`{"error":"Invalid credit card","fields":{"card_number":"fails the Luhn check","cvv":"must be 3 digits for unknown"}}`,
+		w.Body.String(),
+	)
 
+	w = post(validCreditCard)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	if assert.Len(t, store.items, 1) {
+		savedCard := store.items[0]
+		assert.Equal(t, "4111111111111111", savedCard.CardNumber)
+		assert.Equal(t, "JOHN DOE", savedCard.CardHolder)
+		assert.Equal(t, "visa", savedCard.Brand)
+	}
+}
+
+func TestCreditCardSpec_ListFillsBrand(t *testing.T) {
+	store := newMockStore(
+		&models.CreditCard{UserID: "test_user", CardNumber: "5555555555554444"},
+		&models.CreditCard{UserID: "test_user", CardNumber: "378282246310005", Brand: "amex"},
+	)
+	CreditCardSpec(store).Present(store.items)
+	assert.Equal(t, "mastercard", store.items[0].Brand)
+	assert.Equal(t, "amex", store.items[1].Brand)
+}
+
+func TestOTPSecretSpec_Prepare(t *testing.T) {
+	linked := &models.LoginPassword{UserID: "test_user", Login: "octocat"}
+	linked.ID = 1
+	logins := newMockStore(linked)
+	spec := OTPSecretSpec(newMockStore[models.OTPSecret](), logins)
+
+	linkedID, missingID := uint(1), uint(2)
 	tests := []struct {
-		name                  string
-		setupContext          func(ctx *gin.Context)
-		mockGetCreditCardList func(userID string) ([]*models.CreditCard, error)
-		expectedStatusCode    int
-		expectedResponseBody  string
+		name    string
+		secret  models.OTPSecret
+		listErr error
+		wantErr string
+		invalid bool
 	}{
 		{
-			name: "Valid Request",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", personalKey)
-				ctx.Set("token", token)
-			},
-			mockGetCreditCardList: func(userID string) ([]*models.CreditCard, error) {
-				return validCreditCardList, nil
-			},
-			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `{"message":"Credit card list","body":"` + validEncryptedData + `"}`,
+			name:   "Normalizes",
+			secret: models.OTPSecret{Account: "octocat", Secret: "jbsw y3dp ehpk 3pxp"},
 		},
-
 		{
-			name: "Failed to get user",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", personalKey)
-				ctx.Set("token", token)
-			},
-			mockGetCreditCardList: func(userID string) ([]*models.CreditCard, error) {
-				return nil, errors.New("failed to get user")
-			},
-			expectedStatusCode:   http.StatusInternalServerError,
-			expectedResponseBody: `{"error":"failed to get user"}`,
+			name:   "Linked Login-Password",
+			secret: models.OTPSecret{Account: "octocat", Secret: "JBSWY3DPEHPK3PXP", LoginPasswordID: &linkedID},
 		},
 		{
-			name: "No personalKey in Context",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("token", token)
-			},
-			mockGetCreditCardList: func(userID string) ([]*models.CreditCard, error) {
-				return validCreditCardList, nil
-			},
-			expectedStatusCode:   http.StatusInternalServerError,
-			expectedResponseBody: `{"error":"Personal key not found"}`,
+			name:    "Linked Login-Password Not Found",
+			secret:  models.OTPSecret{Account: "octocat", Secret: "JBSWY3DPEHPK3PXP", LoginPasswordID: &missingID},
+			wantErr: "Login-Password entry to link not found",
+			invalid: true,
 		},
 		{
-			name: "Failed to encrypt data",
-			setupContext: func(ctx *gin.Context) {
-				ctx.Set("userID", "test_user")
-				ctx.Set("personalKey", []byte("wrong_key"))
-				ctx.Set("token", token)
-			},
-			mockGetCreditCardList: func(userID string) ([]*models.CreditCard, error) {
-				return validCreditCardList, nil
-			},
-			expectedStatusCode:   http.StatusInternalServerError,
-			expectedResponseBody: `{"error":"Failed to encrypt data"}`,
+			name:    "Login-Password Lookup Failure",
+			secret:  models.OTPSecret{Account: "octocat", Secret: "JBSWY3DPEHPK3PXP", LoginPasswordID: &linkedID},
+			listErr: errors.New("db is down"),
+			wantErr: "db is down",
+		},
+		{
+			name:    "Invalid Secret",
+			secret:  models.OTPSecret{Account: "octocat", Secret: "not base32!"},
+			wantErr: "secret must be a valid base32 string",
+			invalid: true,
 		},
 	}
-
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				mockCCRepo := &MockCreditCardRepo{
-					GetCreditCardListFunc: tt.mockGetCreditCardList,
+				logins.state.listErr = tt.listErr
+				secret := tt.secret
+				err := spec.Prepare("test_user", &secret)
+				if tt.wantErr == "" {
+					require.NoError(t, err)
+					assert.Equal(t, "JBSWY3DPEHPK3PXP", secret.Secret)
+					assert.Equal(t, "SHA1", secret.Algorithm)
+					assert.Equal(t, 6, secret.Digits)
+					assert.Equal(t, 30, secret.Period)
+					return
 				}
+				require.EqualError(t, err, tt.wantErr)
+				var invalid *invalidItemError
+				assert.Equal(t, tt.invalid, errors.As(err, &invalid))
+			},
+		)
+	}
+}
 
-				dh := &DataHandler{
-					cc: mockCCRepo,
-				}
+func TestLoginPasswordSpec_Prepare(t *testing.T) {
+	spec := LoginPasswordSpec(newMockStore[models.LoginPassword]())
 
-				router := gin.New()
-				router.Use(
-					func(ctx *gin.Context) {
-						tt.setupContext(ctx)
-						ctx.Next()
-					},
-				)
-				router.GET("/api/data/get-card", dh.GetCreditCardHandler())
+	lp := models.LoginPassword{Login: "alice", Password: "violet-anvil-harbor-quiver"}
+	require.NoError(t, spec.Prepare("test_user", &lp))
+	assert.True(t, security.CheckPasswordHash("violet-anvil-harbor-quiver", lp.Password))
 
-				req, _ := http.NewRequest("GET", "/api/data/get-card", nil)
-				w := httptest.NewRecorder()
+	hashed := lp.Password
+	require.NoError(t, spec.Prepare("test_user", &lp))
+	assert.Equal(t, hashed, lp.Password)
+}
 
-				router.ServeHTTP(w, req)
+func TestSSHKeySpec_Prepare(t *testing.T) {
+	generated, err := sshkey.Generate(sshkey.TypeEd25519, 0, "work")
+	require.NoError(t, err)
+	other, err := sshkey.Generate(sshkey.TypeEd25519, 0, "other")
+	require.NoError(t, err)
+	spec := SSHKeySpec(newMockStore[models.SSHKey]())
 
-				assert.Equal(t, tt.expectedStatusCode, w.Code)
-			},
-		)
+	key := models.SSHKey{PrivateKey: generated.PrivateKey, Comment: "work"}
+	require.NoError(t, spec.Prepare("test_user", &key))
+	assert.Equal(t, generated.PublicKey, key.PublicKey)
+	assert.Equal(t, generated.Fingerprint, key.Fingerprint)
+	assert.Equal(t, "ssh-ed25519", key.KeyType)
+
+	var invalid *invalidItemError
+	mismatch := models.SSHKey{PrivateKey: generated.PrivateKey, PublicKey: other.PublicKey}
+	assert.True(t, errors.As(spec.Prepare("test_user", &mismatch), &invalid))
+	garbage := models.SSHKey{PrivateKey: "garbage"}
+	assert.True(t, errors.As(spec.Prepare("test_user", &garbage), &invalid))
+}
+
+func TestRegisterDataRoutes(t *testing.T) {
+	router := gin.New()
+	RegisterDataRoutes(
+		router.Group("/api/data"), DataStores{
+			LoginPasswords: newMockStore[models.LoginPassword](),
+			TextData:       newMockStore[models.TextData](),
+			BinaryData:     newMockStore[models.BinaryData](),
+			CreditCards:    newMockStore[models.CreditCard](),
+			OTPSecrets:     newMockStore[models.OTPSecret](),
+			SSHKeys:        newMockStore[models.SSHKey](),
+		},
+	)
+
+	var routes []string
+	for _, r := range router.Routes() {
+		routes = append(routes, r.Method+" "+r.Path)
 	}
+	assert.ElementsMatch(
+		t, []string{
+			"POST /api/data/add-card", "GET /api/data/get-card",
+			"POST /api/data/add-text-data", "GET /api/data/get-text-data",
+			"POST /api/data/add-binary-data", "GET /api/data/get-binary-data",
+			"POST /api/data/add-login-password", "GET /api/data/get-login-password",
+			"POST /api/data/add-otp", "GET /api/data/get-otp",
+			"POST /api/data/add-ssh-key", "GET /api/data/get-ssh-key",
+			"POST /api/data/add-card-batch", "POST /api/data/add-text-data-batch",
+			"POST /api/data/add-binary-data-batch", "POST /api/data/add-login-password-batch",
+			"POST /api/data/add-otp-batch", "POST /api/data/add-ssh-key-batch",
+			"PUT /api/data/organize-card/:id", "PUT /api/data/organize-text-data/:id",
+			"PUT /api/data/organize-binary-data/:id", "PUT /api/data/organize-login-password/:id",
+			"PUT /api/data/organize-otp/:id", "PUT /api/data/organize-ssh-key/:id",
+			"PUT /api/data/update-card/:id", "PUT /api/data/update-text-data/:id",
+			"PUT /api/data/update-binary-data/:id", "PUT /api/data/update-login-password/:id",
+			"PUT /api/data/update-otp/:id", "PUT /api/data/update-ssh-key/:id",
+			"DELETE /api/data/delete-card/:id", "DELETE /api/data/delete-text-data/:id",
+			"DELETE /api/data/delete-binary-data/:id", "DELETE /api/data/delete-login-password/:id",
+			"DELETE /api/data/delete-otp/:id", "DELETE /api/data/delete-ssh-key/:id",
+			"GET /api/data/history-card/:id", "GET /api/data/history-text-data/:id",
+			"GET /api/data/history-binary-data/:id", "GET /api/data/history-login-password/:id",
+			"GET /api/data/history-otp/:id", "GET /api/data/history-ssh-key/:id",
+			"POST /api/data/restore-card/:id/:version",
+			"POST /api/data/restore-text-data/:id/:version",
+			"POST /api/data/restore-binary-data/:id/:version",
+			"POST /api/data/restore-login-password/:id/:version",
+			"POST /api/data/restore-otp/:id/:version",
+			"POST /api/data/restore-ssh-key/:id/:version",
+		}, routes,
+	)
 }
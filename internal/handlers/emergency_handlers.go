@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxEmergencyWaitDays limits the wait period of emergency access.
+const maxEmergencyWaitDays = 90
+
+// vaultList lists the live items of one secret type of a user.
+type vaultList struct {
+	route string
+	list  func(userID string) (interface{}, error)
+}
+
+func listAll[T any](route string, store repository.Store[T]) vaultList {
+	return vaultList{
+		route: route,
+		list: func(userID string) (interface{}, error) {
+			return store.List(userID, repository.ListFilter{})
+		},
+	}
+}
+
+// EmergencyHandler serves emergency access: a grantor names a trusted user
+// who may request access to the grantor's vault, which is granted unless
+// the grantor rejects the request within the wait period. The grantor's
+// client seals the grantor's personal key to the grantee's public key, so
+// the grantee can read the vault, which is sent encrypted with it.
+type EmergencyHandler struct {
+	grants repository.EmergencyRepo
+	users  repository.UserRepo
+	vault  []vaultList
+}
+
+func NewEmergencyHandler(
+	grants repository.EmergencyRepo,
+	users repository.UserRepo,
+	s DataStores,
+	items repository.Store[models.Item],
+) *EmergencyHandler {
+	return &EmergencyHandler{
+		grants: grants,
+		users:  users,
+		vault: []vaultList{
+			listAll("card", s.CreditCards),
+			listAll("text-data", s.TextData),
+			listAll("binary-data", s.BinaryData),
+			listAll("login-password", s.LoginPasswords),
+			listAll("otp", s.OTPSecrets),
+			listAll("ssh-key", s.SSHKeys),
+			listAll("item", items),
+		},
+	}
+}
+
+// RegisterEmergencyRoutes adds POST add-emergency-access, GET
+// get-emergency-access, GET get-emergency-access-granted, POST
+// request-emergency-access/:id, POST reject-emergency-access/:id, POST
+// approve-emergency-access/:id, DELETE delete-emergency-access/:id, GET
+// get-emergency-access-key/:id and GET get-emergency-vault/:id to r.
+func RegisterEmergencyRoutes(r gin.IRoutes, eh *EmergencyHandler) {
+	r.POST("/add-emergency-access", eh.GrantHandler())
+	r.GET("/get-emergency-access", eh.ListHandler(eh.grants.GrantedBy, "Emergency access granted"))
+	r.GET("/get-emergency-access-granted", eh.ListHandler(eh.grants.GrantedTo, "Emergency access granted to me"))
+	r.POST("/request-emergency-access/:id", eh.RequestHandler())
+	r.POST("/reject-emergency-access/:id", eh.GrantorHandler(eh.grants.Reject, "rejected"))
+	r.POST("/approve-emergency-access/:id", eh.GrantorHandler(eh.grants.Approve, "approved"))
+	r.DELETE("/delete-emergency-access/:id", eh.GrantorHandler(eh.grants.Revoke, "revoked"))
+	r.GET("/get-emergency-access-key/:id", eh.KeyHandler())
+	r.GET("/get-emergency-vault/:id", eh.VaultHandler())
+}
+
+// respondEmergencyError maps emergency access errors to responses.
+func respondEmergencyError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Emergency access not found"})
+	case errors.Is(err, repository.ErrEmergencyNotApproved):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": repository.ErrEmergencyNotApproved.Error()})
+	case errors.Is(err, repository.ErrEmergencyState):
+		ctx.JSON(http.StatusConflict, gin.H{"error": repository.ErrEmergencyState.Error()})
+	default:
+		log.Printf("Error managing emergency access: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GrantHandler gives a user with a public key emergency access to the
+// user's vault; the body is an encrypted models.EmergencyAccess. Granting
+// it again to the same user replaces it.
+func (eh *EmergencyHandler) GrantHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		access := new(models.EmergencyAccess)
+		if !decryptRequest(ctx, access) {
+			return
+		}
+		access.ID = 0
+		access.GrantorID = userID.(string)
+		if access.Type == "" {
+			access.Type = models.EmergencyView
+		}
+		if err := validateEmergencyAccess(access); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := publicKeyOf(ctx, eh.users, access.GranteeID); !ok {
+			return
+		}
+
+		if err := eh.grants.Grant(access); err != nil {
+			respondEmergencyError(ctx, err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "Emergency access has been granted",
+				"id":      access.ID,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+func validateEmergencyAccess(access *models.EmergencyAccess) error {
+	switch {
+	case access.Type != models.EmergencyView && access.Type != models.EmergencyTakeover:
+		return fmt.Errorf("invalid emergency access type %q", access.Type)
+	case access.WaitDays < 0 || access.WaitDays > maxEmergencyWaitDays:
+		return fmt.Errorf("wait period must be between 0 and %d days", maxEmergencyWaitDays)
+	case access.GranteeID == access.GrantorID:
+		return errors.New("cannot grant emergency access to yourself")
+	case len(access.Key) == 0:
+		return errors.New("sealed key is required")
+	}
+	return nil
+}
+
+// ListHandler lists the emergency access returned by list for the user.
+func (eh *EmergencyHandler) ListHandler(
+	list func(userID string) ([]*models.EmergencyAccess, error),
+	message string,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		grants, err := list(userID.(string))
+		if err != nil {
+			respondEmergencyError(ctx, err)
+			return
+		}
+		respondEncrypted(ctx, message, grants)
+	}
+}
+
+// RequestHandler asks for emergency access granted to the user. The
+// grantor is told of the request on every response to the grantor, see
+// middleware.NoticeEmergencyRequests, and has until recovery_at to reject
+// it.
+func (eh *EmergencyHandler) RequestHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		access, err := eh.grants.Request(userID.(string), id, time.Now())
+		if err != nil {
+			respondEmergencyError(ctx, err)
+			return
+		}
+		log.Printf(
+			"Emergency access %d to the vault of %s requested by %s, granted at %s unless rejected",
+			access.ID, access.GrantorID, access.GranteeID, access.RecoveryAt.Format(time.RFC3339),
+		)
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message":     "Emergency access has been requested",
+				"recovery_at": access.RecoveryAt,
+				"status":      http.StatusOK,
+			},
+		)
+	}
+}
+
+// GrantorHandler applies change to emergency access the user gave.
+func (eh *EmergencyHandler) GrantorHandler(
+	change func(grantorID string, id uint) error,
+	done string,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := change(userID.(string), id); err != nil {
+			respondEmergencyError(ctx, err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Emergency access has been " + done,
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// KeyHandler answers with emergency access of the user, with the sealed
+// key, once it is approved. Listing the access granted to the user leaves
+// the key out, so that it cannot be opened before the wait period is over.
+func (eh *EmergencyHandler) KeyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		access, err := eh.grants.Access(userID.(string), id, time.Now())
+		if err != nil {
+			respondEmergencyError(ctx, err)
+			return
+		}
+		respondEncrypted(ctx, "Emergency access key", access)
+	}
+}
+
+// VaultHandler answers with the grantor's vault, by secret type, once the
+// user's emergency access is approved. It is encrypted with the grantor's
+// personal key, which the user opens from the sealed key of the access.
+func (eh *EmergencyHandler) VaultHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		access, err := eh.grants.Access(userID.(string), id, time.Now())
+		if err != nil {
+			respondEmergencyError(ctx, err)
+			return
+		}
+		grantor, err := eh.users.GetUserByUsername(access.GrantorID)
+		if err != nil {
+			log.Printf("Error getting grantor: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		personalKey, err := security.DecryptPersonalKey(grantor.PersonalKey)
+		if err != nil {
+			log.Printf("Error decrypting personal key of %s: %v", grantor.Username, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt personal key"})
+			return
+		}
+
+		vault := make(map[string]interface{}, len(eh.vault))
+		for _, v := range eh.vault {
+			items, err := v.list(grantor.Username)
+			if err != nil {
+				log.Printf("Error listing %s of %s: %v", v.route, grantor.Username, err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			vault[v.route] = items
+		}
+		respondEncryptedWith(ctx, "Vault of "+grantor.Username, vault, personalKey)
+	}
+}
+
+// EmergencyTakeover sets a new password for the account of a grantor who
+// gave the user approved takeover access; the body is
+// {"new_password": ...}. The user then logs in as the grantor with the
+// grantor's personal key opened from the access.
+func (h *UserHandler) EmergencyTakeover(grants repository.EmergencyRepo) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			NewPassword string `json:"new_password" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		access, err := grants.Access(userID.(string), id, time.Now())
+		if err != nil {
+			respondEmergencyError(ctx, err)
+			return
+		}
+		if access.Type != models.EmergencyTakeover {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "Emergency access does not allow takeover"})
+			return
+		}
+		grantor, err := h.userRep.GetUserByUsername(access.GrantorID)
+		if err != nil {
+			log.Printf("Error getting grantor: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !h.checkPassword(ctx, req.NewPassword, grantor.Username, grantor.Email) {
+			return
+		}
+
+		hashed, err := security.HashPassword(req.NewPassword)
+		if err != nil {
+			log.Printf("Error hashing password: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := h.userRep.UpdatePassword(grantor.Username, hashed); err != nil {
+			log.Printf("Error updating password: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Account of %s taken over by %s through emergency access %d", grantor.Username, userID, id)
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Password of " + grantor.Username + " has been changed",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
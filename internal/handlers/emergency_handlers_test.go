@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// MockEmergencyRepo records grants and answers Access with access or err.
+type MockEmergencyRepo struct {
+	granted []*models.EmergencyAccess
+	access  *models.EmergencyAccess
+	pending int64
+	err     error
+}
+
+func (m *MockEmergencyRepo) Grant(access *models.EmergencyAccess) error {
+	access.ID = uint(len(m.granted) + 1)
+	m.granted = append(m.granted, access)
+	return nil
+}
+
+func (m *MockEmergencyRepo) GrantedBy(grantorID string) ([]*models.EmergencyAccess, error) {
+	return m.granted, nil
+}
+
+func (m *MockEmergencyRepo) GrantedTo(granteeID string) ([]*models.EmergencyAccess, error) {
+	return m.granted, nil
+}
+
+func (m *MockEmergencyRepo) Pending(grantorID string) (int64, error) {
+	return m.pending, m.err
+}
+
+func (m *MockEmergencyRepo) Request(granteeID string, id uint, now time.Time) (*models.EmergencyAccess, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	recoveryAt := now.Add(24 * time.Hour)
+	return &models.EmergencyAccess{GrantorID: "alice", GranteeID: granteeID, RecoveryAt: &recoveryAt}, nil
+}
+
+func (m *MockEmergencyRepo) Reject(grantorID string, id uint) error {
+	return m.err
+}
+
+func (m *MockEmergencyRepo) Approve(grantorID string, id uint) error {
+	return m.err
+}
+
+func (m *MockEmergencyRepo) Revoke(grantorID string, id uint) error {
+	return m.err
+}
+
+func (m *MockEmergencyRepo) Access(granteeID string, id uint, now time.Time) (*models.EmergencyAccess, error) {
+	return m.access, m.err
+}
+
+func TestEmergencyHandler(t *testing.T) {
+	grantorKey := []byte("6543210987654321")
+	encryptedGrantorKey, err := security.EncryptPersonalKey(grantorKey)
+	require.NoError(t, err)
+	users := new(MockUserRepo)
+	users.On("GetUserByUsername", "bob").Return(&models.User{Username: "bob", PublicKey: []byte("bob key")}, nil)
+	users.On("GetUserByUsername", "carol").
+		Return(&models.User{Username: "carol", PersonalKey: encryptedGrantorKey}, nil)
+	grants := &MockEmergencyRepo{}
+	stores := DataStores{
+		LoginPasswords: newMockStore(&models.LoginPassword{Login: "admin", Password: "secret"}),
+		TextData:       newMockStore[models.TextData](),
+		BinaryData:     newMockStore[models.BinaryData](),
+		CreditCards:    newMockStore[models.CreditCard](),
+		OTPSecrets:     newMockStore[models.OTPSecret](),
+		SSHKeys:        newMockStore[models.SSHKey](),
+	}
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "alice")
+		},
+	)
+	RegisterEmergencyRoutes(router, NewEmergencyHandler(grants, users, stores, newMockStore[models.Item]()))
+	grant := func(access models.EmergencyAccess) int {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, access)})
+		return serve(router, "POST", "/add-emergency-access", bytes.NewBuffer(body)).Code
+	}
+
+	valid := models.EmergencyAccess{GranteeID: "bob", WaitDays: 7, Key: []byte("sealed key")}
+	assert.Equal(t, http.StatusCreated, grant(valid))
+	require.Len(t, grants.granted, 1)
+	assert.Equal(t, "alice", grants.granted[0].GrantorID)
+	assert.Equal(t, models.EmergencyView, grants.granted[0].Type)
+	for name, tc := range map[string]struct {
+		change func(a *models.EmergencyAccess)
+		want   int
+	}{
+		"invalid type":  {func(a *models.EmergencyAccess) { a.Type = "admin" }, http.StatusUnprocessableEntity},
+		"long wait":     {func(a *models.EmergencyAccess) { a.WaitDays = 91 }, http.StatusUnprocessableEntity},
+		"self":          {func(a *models.EmergencyAccess) { a.GranteeID = "alice" }, http.StatusUnprocessableEntity},
+		"no key":        {func(a *models.EmergencyAccess) { a.Key = nil }, http.StatusUnprocessableEntity},
+		"no public key": {func(a *models.EmergencyAccess) { a.GranteeID = "carol" }, http.StatusUnprocessableEntity},
+	} {
+		a := valid
+		tc.change(&a)
+		assert.Equal(t, tc.want, grant(a), name)
+	}
+	assert.Len(t, grants.granted, 1)
+
+	assert.Equal(t, http.StatusOK, serve(router, "POST", "/request-emergency-access/1", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(router, "POST", "/reject-emergency-access/1", nil).Code)
+	grants.err = repository.ErrEmergencyState
+	assert.Equal(t, http.StatusConflict, serve(router, "POST", "/approve-emergency-access/1", nil).Code)
+	grants.err = gorm.ErrRecordNotFound
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/delete-emergency-access/1", nil).Code)
+	grants.err = repository.ErrEmergencyNotApproved
+	assert.Equal(t, http.StatusForbidden, serve(router, "GET", "/get-emergency-vault/1", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(router, "GET", "/get-emergency-access-key/1", nil).Code)
+
+	grants.err = nil
+	grants.access = &models.EmergencyAccess{GrantorID: "carol", GranteeID: "alice", Status: models.EmergencyApproved}
+	grants.access.Key = []byte("sealed key")
+	w := serve(router, "GET", "/get-emergency-access-key/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Body string `json:"body"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	encrypted, err := base64.StdEncoding.DecodeString(response.Body)
+	require.NoError(t, err)
+	decrypted, err := security.DecryptData(encrypted, testPersonalKey)
+	require.NoError(t, err)
+	var keyed models.EmergencyAccess
+	require.NoError(t, json.Unmarshal(decrypted, &keyed))
+	assert.Equal(t, []byte("sealed key"), keyed.Key)
+
+	w = serve(router, "GET", "/get-emergency-vault/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	encrypted, err = base64.StdEncoding.DecodeString(response.Body)
+	require.NoError(t, err)
+	wrong, err := security.DecryptData(append([]byte(nil), encrypted...), testPersonalKey)
+	require.NoError(t, err)
+	assert.False(t, json.Valid(wrong))
+	decrypted, err = security.DecryptData(encrypted, grantorKey)
+	require.NoError(t, err)
+	var vault struct {
+		LoginPasswords []*models.LoginPassword `json:"login-password"`
+		Cards          []*models.CreditCard    `json:"card"`
+	}
+	require.NoError(t, json.Unmarshal(decrypted, &vault))
+	require.Len(t, vault.LoginPasswords, 1)
+	assert.Equal(t, "secret", vault.LoginPasswords[0].Password)
+	users.AssertExpectations(t)
+}
+
+func TestUserHandler_EmergencyTakeover(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := new(MockUserRepo)
+	users.On("GetUserByUsername", "carol").Return(&models.User{Username: "carol", Email: "carol@example.com"}, nil)
+	users.On("UpdatePassword", "carol", mock.Anything).Return(nil).Once()
+	grants := &MockEmergencyRepo{
+		access: &models.EmergencyAccess{GrantorID: "carol", Type: models.EmergencyView},
+	}
+	router := gin.New()
+	router.POST(
+		"/takeover/:id", func(ctx *gin.Context) {
+			ctx.Set("userID", "alice")
+		}, NewUserHandler(users, nil).EmergencyTakeover(grants),
+	)
+	takeover := func(password string) int {
+		body, _ := json.Marshal(gin.H{"new_password": password})
+		return serve(router, "POST", "/takeover/1", bytes.NewBuffer(body)).Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, takeover("correct horse battery staple 42"))
+	grants.access.Type = models.EmergencyTakeover
+	assert.Equal(t, http.StatusBadRequest, takeover("carol"))
+	assert.Equal(t, http.StatusOK, takeover("correct horse battery staple 42"))
+	grants.err = repository.ErrEmergencyNotApproved
+	assert.Equal(t, http.StatusForbidden, takeover("correct horse battery staple 42"))
+	users.AssertExpectations(t)
+}
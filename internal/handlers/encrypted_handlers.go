@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/elina-chertova/auth-keeper.git/internal/validation"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Owned is the pointer type of a vault model that can be assigned to a user.
+type Owned[T any] interface {
+	*T
+	SetUserID(userID string)
+}
+
+// maxBatchItems is the most items added by one batch request.
+const maxBatchItems = 100
+
+// ItemSpec describes one encrypted secret type served under
+// POST add-<Route>, GET get-<Route>, PUT update-<Route>/:id,
+// PUT organize-<Route>/:id, DELETE delete-<Route>/:id,
+// GET history-<Route>/:id and POST restore-<Route>/:id/:version.
+type ItemSpec[T any] struct {
+	Route string
+	Store repository.Store[T]
+
+	// AddedMessage and ListMessage are the "message" of the responses.
+	AddedMessage string
+	ListMessage  string
+	// InvalidMessage is the "error" reported with field validation errors.
+	InvalidMessage string
+
+	// Prepare normalizes and validates a decrypted item before it is added
+	// or updated. Errors wrapped with invalidItem are answered with 422,
+	// others with 500.
+	Prepare func(userID string, item *T) error
+	// Inherit fills in what an update leaves out from the stored item,
+	// before Prepare runs.
+	Inherit func(item, prev *T)
+	// Present adjusts listed items before they are encrypted.
+	Present func(items []*T)
+
+	// Limits enforces the storage quota on added and updated items; nil
+	// allows everything. Size, if set, is the number of bytes of an item
+	// counted against the byte quotas.
+	Limits *Limits
+	Size   func(item *T) int64
+}
+
+type invalidItemError struct {
+	err error
+}
+
+func (e *invalidItemError) Error() string { return e.err.Error() }
+
+func (e *invalidItemError) Unwrap() error { return e.err }
+
+// invalidItem marks err as a client mistake in the item contents.
+func invalidItem(err error) error {
+	return &invalidItemError{err: err}
+}
+
+// RegisterItem adds the add, batch add, list, update, organize, delete and history
+// routes of a secret type to r.
+func RegisterItem[T any, PT Owned[T]](r gin.IRoutes, spec ItemSpec[T]) {
+	r.POST("/add-"+spec.Route, AddItemHandler[T, PT](spec))
+	r.POST("/add-"+spec.Route+"-batch", AddBatchHandler[T, PT](spec))
+	r.GET("/get-"+spec.Route, ListItemHandler(spec))
+	r.PUT("/update-"+spec.Route+"/:id", UpdateItemHandler[T, PT](spec))
+	r.PUT("/organize-"+spec.Route+"/:id", OrganizeItemHandler(spec))
+	r.DELETE("/delete-"+spec.Route+"/:id", DeleteItemHandler(spec))
+	r.GET("/history-"+spec.Route+"/:id", HistoryHandler(spec))
+	r.POST("/restore-"+spec.Route+"/:id/:version", RestoreVersionHandler(spec))
+}
+
+func AddItemHandler[T any, PT Owned[T]](spec ItemSpec[T]) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if !spec.Limits.allowItem(ctx, userID.(string), new(T)) {
+			return
+		}
+		if spec.Size != nil {
+			spec.Limits.limitBody(ctx)
+		}
+
+		item := new(T)
+		if !decryptRequest(ctx, item) {
+			return
+		}
+
+		if !prepareItem(ctx, spec, userID.(string), item) {
+			return
+		}
+		if spec.Size != nil && !spec.Limits.allowBytes(ctx, userID.(string), spec.Size(item), true) {
+			return
+		}
+
+		clearModel(item)
+		PT(item).SetUserID(userID.(string))
+		if err := spec.Store.Save(item); err != nil {
+			if errors.Is(err, repository.ErrFolderNotFound) {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Folder not found"})
+				return
+			}
+			log.Printf("Error adding %s: %v", spec.Route, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": spec.AddedMessage,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+// AddBatchHandler adds up to maxBatchItems items at once; the body is an
+// encrypted JSON array of them. Either all of them are added or, if one is
+// invalid or does not fit in the quota, none is. The response lists the IDs
+// of the added items in order.
+func AddBatchHandler[T any, PT Owned[T]](spec ItemSpec[T]) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if spec.Size != nil {
+			spec.Limits.limitBatchBody(ctx, maxBatchItems)
+		}
+		var items []*T
+		if !decryptRequest(ctx, &items) {
+			return
+		}
+		if len(items) == 0 || len(items) > maxBatchItems {
+			ctx.JSON(
+				http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("A batch holds 1 to %d items", maxBatchItems),
+				},
+			)
+			return
+		}
+		if !spec.Limits.allowItems(ctx, userID.(string), new(T), int64(len(items))) {
+			return
+		}
+
+		var size int64
+		for _, item := range items {
+			if !prepareItem(ctx, spec, userID.(string), item) {
+				return
+			}
+			if spec.Size != nil {
+				if !spec.Limits.allowBytes(ctx, userID.(string), spec.Size(item), false) {
+					return
+				}
+				size += spec.Size(item)
+			}
+			clearModel(item)
+			PT(item).SetUserID(userID.(string))
+		}
+		if !spec.Limits.allowAdded(ctx, userID.(string), size) {
+			return
+		}
+
+		if err := spec.Store.SaveAll(items); err != nil {
+			if errors.Is(err, repository.ErrFolderNotFound) {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Folder not found"})
+				return
+			}
+			log.Printf("Error adding %s: %v", spec.Route, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ids := make([]uint, len(items))
+		for i, item := range items {
+			ids[i] = repository.IDOf(item)
+		}
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": spec.AddedMessage,
+				"count":   len(items),
+				"ids":     ids,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+// clearModel drops the ID and timestamps a client sent with a new item.
+func clearModel[T any](item *T) {
+	m := reflect.ValueOf(item).Elem().FieldByName("Model")
+	m.Set(reflect.Zero(m.Type()))
+}
+
+// prepareItem runs spec.Prepare and writes the error response on failure.
+func prepareItem[T any](ctx *gin.Context, spec ItemSpec[T], userID string, item *T) bool {
+	if spec.Prepare == nil {
+		return true
+	}
+	if err := spec.Prepare(userID, item); err != nil {
+		var invalid *invalidItemError
+		if errors.As(err, &invalid) {
+			respondValidationError(ctx, spec.InvalidMessage, invalid.err)
+			return false
+		}
+		log.Printf("Error preparing %s: %v", spec.Route, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+func ListItemHandler[T any](spec ItemSpec[T]) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, err := extractUserFromRequest(ctx)
+		if err != nil {
+			if errors.Is(err, errTokenNotFound) {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			} else {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			log.Printf("Error extracting user from token: %v", err)
+			return
+		}
+
+		filter, ok := listFilter(ctx)
+		if !ok {
+			return
+		}
+		page, ok := listPage(ctx)
+		if !ok {
+			return
+		}
+		res, err := spec.Store.Page(userID, filter, page)
+		if err != nil {
+			respondListError(ctx, spec.Route, err)
+			return
+		}
+
+		if spec.Present != nil {
+			spec.Present(res.Items)
+		}
+		setPageHeaders(ctx, res.Total, res.NextCursor)
+		respondEncrypted(ctx, spec.ListMessage, res.Items)
+	}
+}
+
+// OrganizeItemHandler changes the display name, folder, favorite flag or
+// tags of one item; the body is an encrypted models.MetaChange.
+func OrganizeItemHandler[T any](spec ItemSpec[T]) gin.HandlerFunc {
+	return organizeHandler(spec.Store.Organize)
+}
+
+func organizeHandler(
+	organize func(userID string, id uint, change models.MetaChange) error,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		var change models.MetaChange
+		if !decryptRequest(ctx, &change) {
+			return
+		}
+
+		if err := organize(userID.(string), id, change); err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			case errors.Is(err, repository.ErrFolderNotFound):
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Folder not found"})
+			default:
+				log.Printf("Error organizing item: %v", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Item has been organized",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// listFilter reads the filter query parameters.
+func listFilter(ctx *gin.Context) (repository.ListFilter, bool) {
+	filter := repository.ListFilter{Tags: ctx.QueryArray("tag"), Template: ctx.Query("template")}
+	for param, dst := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+		"updated_after":  &filter.UpdatedAfter,
+		"updated_before": &filter.UpdatedBefore,
+	} {
+		v := ctx.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := parseTime(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return filter, false
+		}
+		*dst = &t
+	}
+	if v := ctx.Query("folder_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id == 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder_id"})
+			return filter, false
+		}
+		folderID := uint(id)
+		filter.FolderID = &folderID
+	}
+	if v := ctx.Query("favorite"); v != "" {
+		favorite, err := strconv.ParseBool(v)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid favorite"})
+			return filter, false
+		}
+		filter.Favorite = &favorite
+	}
+	return filter, true
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// listPage reads the limit, cursor, sort and order query parameters.
+func listPage(ctx *gin.Context) (repository.Page, bool) {
+	page := repository.Page{Cursor: ctx.Query("cursor"), Sort: ctx.Query("sort")}
+	if v := ctx.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return page, false
+		}
+		page.Limit = min(limit, repository.MaxLimit)
+	}
+	switch ctx.Query("order") {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return page, false
+	}
+	return page, true
+}
+
+// setPageHeaders reports the total count and the cursor of the next page.
+func setPageHeaders(ctx *gin.Context, total int64, next string) {
+	ctx.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if next != "" {
+		ctx.Header("X-Next-Cursor", next)
+	}
+}
+
+// respondListError maps list errors to responses.
+func respondListError(ctx *gin.Context, what string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrFolderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+	case errors.Is(err, repository.ErrInvalidCursor):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": repository.ErrInvalidCursor.Error()})
+	case errors.Is(err, repository.ErrInvalidSort),
+		errors.Is(err, repository.ErrSealedSort),
+		errors.Is(err, repository.ErrTemplateFilter):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Error getting %s: %v", what, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// decryptRequest reads {"data": ...} into out, answering errors itself.
+func decryptRequest(ctx *gin.Context, out interface{}) bool {
+	var encryptedData struct {
+		Data string `json:"data"`
+	}
+	if err := ctx.ShouldBindJSON(&encryptedData); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return false
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	decodedData, err := base64.StdEncoding.DecodeString(encryptedData.Data)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode base64 data"})
+		return false
+	}
+
+	personalKey, exists := ctx.Get("personalKey")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
+		return false
+	}
+
+	decryptedData, err := security.DecryptData(decodedData, personalKey.([]byte))
+	if err != nil {
+		log.Printf("Failed to decrypt data: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+		return false
+	}
+
+	if err := json.Unmarshal(decryptedData, out); err != nil {
+		log.Printf("Failed to unmarshal decrypted data: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal decrypted data"})
+		return false
+	}
+	return true
+}
+
+// respondEncrypted answers 200 with v sealed with the personal key.
+func respondEncrypted(ctx *gin.Context, message string, v interface{}) {
+	personalKey, exists := ctx.Get("personalKey")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
+		return
+	}
+	respondEncryptedWith(ctx, message, v, personalKey.([]byte))
+}
+
+// respondEncryptedWith is respondEncrypted with the personal key given.
+func respondEncryptedWith(ctx *gin.Context, message string, v interface{}, personalKey []byte) {
+	dataJSON, err := json.Marshal(v)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal data"})
+		return
+	}
+
+	encryptedData, err := security.EncryptData(dataJSON, personalKey)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt data"})
+		return
+	}
+
+	ctx.IndentedJSON(
+		http.StatusOK, gin.H{
+			"message": message,
+			"body":    base64.StdEncoding.EncodeToString(encryptedData),
+		},
+	)
+}
+
+func respondValidationError(ctx *gin.Context, message string, err error) {
+	var fieldErrors validation.FieldErrors
+	if errors.As(err, &fieldErrors) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": message, "fields": fieldErrors})
+		return
+	}
+	ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+}
+
+func idParam(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return 0, false
+	}
+	return uint(id), true
+}
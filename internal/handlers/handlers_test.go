@@ -9,9 +9,11 @@ import (
 
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
 	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockUserRepo struct {
@@ -28,6 +30,24 @@ func (m *MockUserRepo) GetUserByUsername(username string) (*models.User, error)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockUserRepo) UpdatePassword(username, hashedPassword string) error {
+	args := m.Called(username, hashedPassword)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) SetPublicKey(username string, publicKey []byte) error {
+	args := m.Called(username, publicKey)
+	return args.Error(0)
+}
+
+type MockBreachChecker struct {
+	breached map[string]bool
+}
+
+func (m *MockBreachChecker) IsBreached(password string) (bool, error) {
+	return m.breached[password], nil
+}
+
 type MockSecurity struct {
 	mock.Mock
 }
@@ -48,12 +68,23 @@ func (m *MockSecurity) GenerateToken(username string) (string, error) {
 }
 
 func setupRouter(userRepo repository.UserRepo, security *MockSecurity) *gin.Engine {
+	return setupRouterWithBreach(userRepo, nil)
+}
+
+func setupRouterWithBreach(userRepo repository.UserRepo, breach security.BreachChecker) *gin.Engine {
 	handler := NewUserHandler(
 		userRepo,
+		breach,
 	)
 	router := gin.New()
 	router.POST("/register", handler.Register())
 	router.POST("/signup", handler.Signup())
+	router.POST(
+		"/change-password", func(ctx *gin.Context) {
+			ctx.Set("userID", "test_user")
+			ctx.Next()
+		}, handler.ChangePassword(),
+	)
 	return router
 }
 
@@ -66,12 +97,12 @@ func TestUserHandler_Register(t *testing.T) {
 		"Successful Registration", func(t *testing.T) {
 			user := &models.User{
 				Username: "test_user",
-				Password: "password",
+				Password: "violet-anvil-harbor-quiver",
 				Email:    "test@example.com",
 			}
 
 			mockRepo.On("CreateUser", mock.Anything).Return(nil).Once()
-			mockSecurity.On("HashPassword", "password").Return("hashed_password", nil).Once()
+			mockSecurity.On("HashPassword", "violet-anvil-harbor-quiver").Return("hashed_password", nil).Once()
 			mockSecurity.On("GenerateToken", "test_user").Return("test_token", nil).Once()
 
 			body, _ := json.Marshal(user)
@@ -96,6 +127,129 @@ func TestUserHandler_Register(t *testing.T) {
 		},
 	)
 
+	t.Run(
+		"Registration with Weak Password", func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			router := setupRouter(mockRepo, mockSecurity)
+			user := &models.User{
+				Username: "test_user",
+				Password: "password",
+				Email:    "test@example.com",
+			}
+
+			body, _ := json.Marshal(user)
+			req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, "password is too weak", response["error"])
+			mockRepo.AssertNotCalled(t, "CreateUser", mock.Anything)
+		},
+	)
+
+	t.Run(
+		"Registration with Breached Password", func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			breach := &MockBreachChecker{
+				breached: map[string]bool{"violet-anvil-harbor-quiver": true},
+			}
+			router := setupRouterWithBreach(mockRepo, breach)
+			user := &models.User{
+				Username: "test_user",
+				Password: "violet-anvil-harbor-quiver",
+				Email:    "test@example.com",
+			}
+
+			body, _ := json.Marshal(user)
+			req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.JSONEq(t, `{"error":"password has appeared in a data breach"}`, w.Body.String())
+			mockRepo.AssertNotCalled(t, "CreateUser", mock.Anything)
+		},
+	)
+}
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	oldHash, _ := security.HashPassword("old-password")
+	breach := &MockBreachChecker{
+		breached: map[string]bool{"breached-tundra-quiver-anvil": true},
+	}
+
+	tests := []struct {
+		name               string
+		body               string
+		mockUpdate         bool
+		expectedStatusCode int
+	}{
+		{
+			name:               "Successful Change",
+			body:               `{"old_password":"old-password","new_password":"violet-anvil-harbor-quiver"}`,
+			mockUpdate:         true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Wrong Old Password",
+			body:               `{"old_password":"wrong","new_password":"violet-anvil-harbor-quiver"}`,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Weak New Password",
+			body:               `{"old_password":"old-password","new_password":"qwerty"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Breached New Password",
+			body:               `{"old_password":"old-password","new_password":"breached-tundra-quiver-anvil"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Missing Fields",
+			body:               `{"old_password":"old-password"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				mockRepo := new(MockUserRepo)
+				mockRepo.On("GetUserByUsername", "test_user").Return(
+					&models.User{Username: "test_user", Password: oldHash, TokenVersion: 2}, nil,
+				)
+				if tt.mockUpdate {
+					mockRepo.On("UpdatePassword", "test_user", mock.Anything).Return(nil).Once()
+				}
+				router := setupRouterWithBreach(mockRepo, breach)
+
+				req, _ := http.NewRequest("POST", "/change-password", bytes.NewBufferString(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, tt.expectedStatusCode, w.Code)
+				if !tt.mockUpdate {
+					mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+					return
+				}
+				var resp struct {
+					Token string `json:"token"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				claims, err := security.ParseToken(resp.Token)
+				require.NoError(t, err)
+				assert.Equal(t, uint(3), claims.TokenVersion)
+			},
+		)
+	}
 }
 
 func TestUserHandler_Signup(t *testing.T) {
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ItemVersion is one entry of the history of an item as listed to its owner.
+type ItemVersion[T any] struct {
+	Version   uint      `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Item      *T        `json:"item"`
+}
+
+// UpdateItemHandler replaces the contents of one item; the previous
+// contents are kept as a version.
+func UpdateItemHandler[T any, PT Owned[T]](spec ItemSpec[T]) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		if spec.Size != nil {
+			spec.Limits.limitBody(ctx)
+		}
+		item := new(T)
+		if !decryptRequest(ctx, item) {
+			return
+		}
+		if spec.Inherit != nil {
+			prev, err := spec.Store.Get(userID.(string), id)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+					return
+				}
+				log.Printf("Error getting %s: %v", spec.Route, err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			spec.Inherit(item, prev)
+		}
+		if !prepareItem(ctx, spec, userID.(string), item) {
+			return
+		}
+		if !allowReplaced(ctx, spec, userID.(string), id, item) {
+			return
+		}
+		seal, ok := sealer(ctx)
+		if !ok {
+			return
+		}
+
+		PT(item).SetUserID(userID.(string))
+		if err := spec.Store.Update(userID.(string), id, item, seal); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+				return
+			}
+			if errors.Is(err, repository.ErrChunkedUpdate) {
+				ctx.JSON(http.StatusConflict, gin.H{"error": repository.ErrChunkedUpdate.Error()})
+				return
+			}
+			log.Printf("Error updating %s: %v", spec.Route, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Item has been updated",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// HistoryHandler lists the kept versions of one item, newest first.
+func HistoryHandler[T any](spec ItemSpec[T]) gin.HandlerFunc {
+	return historyHandler[T](spec.Store.Versions)
+}
+
+// RestoreVersionHandler makes a kept version the current contents of an
+// item; the replaced contents become a version themselves.
+func RestoreVersionHandler[T any](spec ItemSpec[T]) gin.HandlerFunc {
+	return restoreHandler(
+		spec.Store.Version, spec.Store.Update,
+		func(ctx *gin.Context, userID string, id uint, item *T) bool {
+			return allowReplaced(ctx, spec, userID, id, item)
+		},
+	)
+}
+
+// allowReplaced checks the limits for item replacing item id.
+func allowReplaced[T any](ctx *gin.Context, spec ItemSpec[T], userID string, id uint, item *T) bool {
+	if spec.Size == nil || spec.Limits == nil {
+		return true
+	}
+	size := spec.Size(item)
+	if !spec.Limits.allowBytes(ctx, userID, size, false) {
+		return false
+	}
+	prev, err := spec.Store.Get(userID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			return false
+		}
+		log.Printf("Error getting %s: %v", spec.Route, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	grown := size - spec.Size(prev)
+	return grown <= 0 || spec.Limits.allowAdded(ctx, userID, grown)
+}
+
+func historyHandler[T any](
+	versions func(userID string, id uint) ([]*models.ItemVersion, error),
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		list, err := versions(userID.(string), id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+				return
+			}
+			log.Printf("Error getting item history: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		history := make([]ItemVersion[T], 0, len(list))
+		for _, v := range list {
+			item := new(T)
+			if !openVersion(ctx, v, item) {
+				return
+			}
+			history = append(
+				history, ItemVersion[T]{Version: v.ID, CreatedAt: v.CreatedAt, Item: item},
+			)
+		}
+		respondEncrypted(ctx, "Item history", history)
+	}
+}
+
+func restoreHandler[T any](
+	version func(userID string, id, version uint) (*models.ItemVersion, error),
+	update func(userID string, id uint, item *T, seal repository.Sealer) error,
+	allow func(ctx *gin.Context, userID string, id uint, item *T) bool,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+		n, err := strconv.ParseUint(ctx.Param("version"), 10, 64)
+		if err != nil || n == 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+
+		v, err := version(userID.(string), id, uint(n))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+				return
+			}
+			log.Printf("Error getting item version: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		item := new(T)
+		if !openVersion(ctx, v, item) {
+			return
+		}
+		if allow != nil && !allow(ctx, userID.(string), id, item) {
+			return
+		}
+		seal, ok := sealer(ctx)
+		if !ok {
+			return
+		}
+
+		if err := update(userID.(string), id, item, seal); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+				return
+			}
+			log.Printf("Error restoring item version: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Item version has been restored",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// sealer encrypts versions with the request's personal key.
+func sealer(ctx *gin.Context) (repository.Sealer, bool) {
+	personalKey, exists := ctx.Get("personalKey")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
+		return nil, false
+	}
+	return func(v interface{}) ([]byte, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return security.EncryptData(data, personalKey.([]byte))
+	}, true
+}
+
+// openVersion decrypts a version sealed by sealer into out.
+func openVersion(ctx *gin.Context, v *models.ItemVersion, out interface{}) bool {
+	personalKey, exists := ctx.Get("personalKey")
+	if !exists {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Personal key not found"})
+		return false
+	}
+	// DecryptData works in place; keep v.Data intact.
+	data, err := security.DecryptData(bytes.Clone(v.Data), personalKey.([]byte))
+	if err == nil {
+		err = json.Unmarshal(data, out)
+	}
+	if err != nil {
+		log.Printf("Failed to open item version %d: %v", v.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt item version"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/item"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+)
+
+type ItemHandler struct {
+	items     repository.Store[models.Item]
+	templates repository.ItemTemplateRepo
+	limits    *Limits
+}
+
+func NewItemHandler(
+	items repository.Store[models.Item],
+	templates repository.ItemTemplateRepo,
+) *ItemHandler {
+	return &ItemHandler{items: items, templates: templates}
+}
+
+// SetLimits makes new items subject to the item limit of limits.
+func (ih *ItemHandler) SetLimits(limits *Limits) {
+	ih.limits = limits
+}
+
+// RegisterItemRoutes adds the routes of items and of item templates to r.
+func RegisterItemRoutes(r gin.IRoutes, ih *ItemHandler) {
+	RegisterItem(r, limited(ih.spec(), ih.limits))
+	r.POST("/add-item-template", ih.AddItemTemplateHandler())
+	r.GET("/get-item-template", ih.GetItemTemplateHandler())
+}
+
+// spec validates items against their templates.
+func (ih *ItemHandler) spec() ItemSpec[models.Item] {
+	return ItemSpec[models.Item]{
+		Route:          "item",
+		Store:          ih.items,
+		AddedMessage:   "Item has been added",
+		ListMessage:    "Item list",
+		InvalidMessage: "Invalid item",
+		Prepare: func(userID string, it *models.Item) error {
+			t, err := ih.template(userID, it.Template)
+			if errors.Is(err, item.ErrUnknownTemplate) {
+				return invalidItem(err)
+			}
+			if err != nil {
+				return err
+			}
+			if err := item.Apply(it, t); err != nil {
+				return invalidItem(err)
+			}
+			return nil
+		},
+		Inherit: func(it, prev *models.Item) {
+			if it.Template == "" {
+				it.Template = prev.Template
+			}
+		},
+	}
+}
+
+// template resolves a built-in or user-defined template by name.
+func (ih *ItemHandler) template(userID, name string) (*models.ItemTemplate, error) {
+	if name == "" {
+		name = item.TemplateCustom
+	}
+	if t, ok := item.BuiltinTemplate(name); ok {
+		return t, nil
+	}
+	t, err := ih.templates.GetItemTemplate(userID, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, item.ErrUnknownTemplate
+	}
+	return t, err
+}
+func (ih *ItemHandler) AddItemTemplateHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var t models.ItemTemplate
+		if !decryptRequest(ctx, &t) {
+			return
+		}
+		if err := item.ValidateTemplate(&t); err != nil {
+			respondValidationError(ctx, "Invalid item template", err)
+			return
+		}
+
+		_, err := ih.templates.GetItemTemplate(userID.(string), t.Name)
+		switch {
+		case err == nil:
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Item template already exists"})
+			return
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			log.Printf("Error getting item template: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		t.UserID = userID.(string)
+		if err := ih.templates.SaveNewItemTemplate(&t); err != nil {
+			log.Printf("Error adding item template: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "Item template has been added",
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+// GetItemTemplateHandler lists the built-in templates followed by the
+// user's own.
+func (ih *ItemHandler) GetItemTemplateHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		own, err := ih.templates.GetItemTemplates(userID.(string))
+		if err != nil {
+			log.Printf("Error getting item templates: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		templates := item.Builtin()
+		for _, t := range own {
+			templates = append(templates, *t)
+		}
+		respondEncrypted(ctx, "Item template list", templates)
+	}
+}
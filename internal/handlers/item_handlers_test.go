@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockItemTemplateRepo struct {
+	templates []*models.ItemTemplate
+}
+
+func (m *MockItemTemplateRepo) GetItemTemplates(userID string) ([]*models.ItemTemplate, error) {
+	var templates []*models.ItemTemplate
+	for _, t := range m.templates {
+		if t.UserID == userID {
+			templates = append(templates, t)
+		}
+	}
+	return templates, nil
+}
+
+func (m *MockItemTemplateRepo) GetItemTemplate(userID, name string) (*models.ItemTemplate, error) {
+	for _, t := range m.templates {
+		if t.UserID == userID && t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockItemTemplateRepo) SaveNewItemTemplate(t *models.ItemTemplate) error {
+	m.templates = append(m.templates, t)
+	return nil
+}
+
+var itemTestKey = []byte("1234567890123456")
+
+func setupItemRouter(ih *ItemHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	token, _ := security.GenerateToken("test_user", 0)
+	router := gin.New()
+	router.Use(
+		func(ctx *gin.Context) {
+			ctx.Set("userID", "test_user")
+			ctx.Set("token", token)
+			ctx.Set("personalKey", itemTestKey)
+			ctx.Next()
+		},
+	)
+	RegisterItemRoutes(router.Group("/api/data"), ih)
+	return router
+}
+
+func encryptedBody(t *testing.T, v interface{}) *bytes.Buffer {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	encrypted, err := security.EncryptData(data, itemTestKey)
+	require.NoError(t, err)
+	body, _ := json.Marshal(map[string]string{"data": base64.StdEncoding.EncodeToString(encrypted)})
+	return bytes.NewBuffer(body)
+}
+
+func decryptedBody(t *testing.T, w *httptest.ResponseRecorder, out interface{}) {
+	var response struct {
+		Body string `json:"body"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	decoded, err := base64.StdEncoding.DecodeString(response.Body)
+	require.NoError(t, err)
+	decrypted, err := security.DecryptData(decoded, itemTestKey)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(decrypted, out))
+}
+
+func serve(router *gin.Engine, method, path string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+	req, _ := http.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestItemHandler_AddItemHandler(t *testing.T) {
+	templates := &MockItemTemplateRepo{
+		templates: []*models.ItemTemplate{
+			{
+				UserID: "test_user",
+				Name:   "wifi",
+				Fields: []models.ItemField{{Name: "ssid", Type: "text", Required: true}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		item               models.Item
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Built-in Template",
+			item: models.Item{
+				Template: "api-key",
+				Name:     "Stripe",
+				Fields:   []models.ItemField{{Name: "key", Value: "sk_live_123"}},
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name: "User Template",
+			item: models.Item{
+				Template: "wifi",
+				Name:     "Home",
+				Fields:   []models.ItemField{{Name: "ssid", Value: "home-5g"}},
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name: "No Template Means Custom",
+			item: models.Item{
+				Name:   "Locker",
+				Fields: []models.ItemField{{Name: "code", Type: "number", Value: "4821"}},
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "Unknown Template",
+			item:               models.Item{Template: "nope", Name: "x"},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"error":"unknown template"}`,
+		},
+		{
+			name: "Invalid Fields",
+			item: models.Item{
+				Template: "api-key",
+				Name:     "Stripe",
+				Fields:   []models.ItemField{{Name: "url", Value: "stripe"}},
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"error":"Invalid item","fields":{"key":"is required","url":"must be an absolute URL"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				items := newMockStore[models.Item]()
+				router := setupItemRouter(NewItemHandler(items, templates))
+
+				w := serve(router, "POST", "/api/data/add-item", encryptedBody(t, tt.item))
+				assert.Equal(t, tt.expectedStatusCode, w.Code)
+				if tt.expectedBody != "" {
+					assert.JSONEq(t, tt.expectedBody, w.Body.String())
+				}
+				if tt.expectedStatusCode == http.StatusCreated {
+					saved, err := items.Get("test_user", 1)
+					require.NoError(t, err)
+					assert.NotEmpty(t, saved.Template)
+					assert.NotEmpty(t, saved.Fields[0].Type)
+				}
+			},
+		)
+	}
+}
+
+func TestItemHandler_GetUpdateDeleteItem(t *testing.T) {
+	items := newMockStore(
+		&models.Item{UserID: "test_user", Template: "custom", Name: "One"},
+		&models.Item{UserID: "test_user", Template: "api-key", Name: "Two"},
+	)
+	router := setupItemRouter(NewItemHandler(items, &MockItemTemplateRepo{}))
+
+	w := serve(router, "GET", "/api/data/get-item?template=custom", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "custom", items.state.filter.Template)
+	var list []*models.Item
+	decryptedBody(t, w, &list)
+	assert.Len(t, list, 2)
+
+	update := models.Item{Name: "Renamed", Fields: []models.ItemField{{Name: "note", Value: "x"}}}
+	w = serve(router, "PUT", "/api/data/update-item/1", encryptedBody(t, update))
+	assert.Equal(t, http.StatusOK, w.Code)
+	got, _ := items.Get("test_user", 1)
+	assert.Equal(t, "Renamed", got.Name)
+	assert.Equal(t, "custom", got.Template)
+
+	// Two keeps the api-key template, whose key field the update lacks.
+	w = serve(router, "PUT", "/api/data/update-item/2", encryptedBody(t, update))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(router, "PUT", "/api/data/update-item/3", encryptedBody(t, update))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, "PUT", "/api/data/update-item/abc", encryptedBody(t, update))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "DELETE", "/api/data/delete-item/3", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, "DELETE", "/api/data/delete-item/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uint{1}, items.state.deleted)
+}
+
+func TestItemHandler_AddItemBatch(t *testing.T) {
+	items := newMockStore[models.Item]()
+	router := setupItemRouter(NewItemHandler(items, &MockItemTemplateRepo{}))
+
+	batch := []models.Item{
+		{Template: "api-key", Name: "Stripe", Fields: []models.ItemField{{Name: "key", Value: "sk_live_123"}}},
+		{Name: "Locker", Fields: []models.ItemField{{Name: "code", Value: "4821"}}},
+	}
+	w := serve(router, "POST", "/api/data/add-item-batch", encryptedBody(t, batch))
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Len(t, items.items, 2)
+	assert.Equal(t, "concealed", items.items[0].Fields[0].Type)
+	assert.Equal(t, "custom", items.items[1].Template)
+
+	batch[1].Template = "nope"
+	w = serve(router, "POST", "/api/data/add-item-batch", encryptedBody(t, batch))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Len(t, items.items, 2)
+}
+
+func TestItemHandler_OrganizeItem(t *testing.T) {
+	items := newMockStore(
+		&models.Item{UserID: "test_user", Template: "custom", Name: "One"},
+		&models.Item{UserID: "test_user", Template: "custom", Name: "Two"},
+	)
+	router := setupItemRouter(NewItemHandler(items, &MockItemTemplateRepo{}))
+
+	favorite := true
+	tags := []string{"work"}
+	change := models.MetaChange{Favorite: &favorite, Tags: &tags}
+	w := serve(router, "PUT", "/api/data/organize-item/2", encryptedBody(t, change))
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, items.state.organized, 1) {
+		assert.Equal(t, []string{"work"}, *items.state.organized[0].Tags)
+	}
+
+	w = serve(router, "GET", "/api/data/get-item?favorite=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	if assert.NotNil(t, items.state.filter.Favorite) {
+		assert.True(t, *items.state.filter.Favorite)
+	}
+
+	w = serve(router, "GET", "/api/data/get-item?favorite=sometimes", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestItemHandler_History(t *testing.T) {
+	items := newMockStore(&models.Item{UserID: "test_user", Template: "custom", Name: "One"})
+	router := setupItemRouter(NewItemHandler(items, &MockItemTemplateRepo{}))
+
+	update := models.Item{Name: "Two"}
+	w := serve(router, "PUT", "/api/data/update-item/1", encryptedBody(t, update))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, "GET", "/api/data/history-item/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []ItemVersion[models.Item]
+	decryptedBody(t, w, &history)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "One", history[0].Item.Name)
+	}
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/data/history-item/9", nil).Code)
+
+	w = serve(router, "POST", "/api/data/restore-item/1/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	got, _ := items.Get("test_user", 1)
+	assert.Equal(t, "One", got.Name)
+	assert.Len(t, items.versions, 2)
+
+	w = serve(router, "POST", "/api/data/restore-item/1/7", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestItemHandler_ItemTemplates(t *testing.T) {
+	templates := &MockItemTemplateRepo{}
+	router := setupItemRouter(NewItemHandler(newMockStore[models.Item](), templates))
+
+	wifi := models.ItemTemplate{
+		Name:   "wifi",
+		Fields: []models.ItemField{{Name: "ssid", Required: true}},
+	}
+	w := serve(router, "POST", "/api/data/add-item-template", encryptedBody(t, wifi))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(router, "POST", "/api/data/add-item-template", encryptedBody(t, wifi))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(
+		router, "POST", "/api/data/add-item-template",
+		encryptedBody(t, models.ItemTemplate{Name: "api-key"}),
+	)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(router, "GET", "/api/data/get-item-template", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []models.ItemTemplate
+	decryptedBody(t, w, &list)
+	var names []string
+	for _, tmpl := range list {
+		names = append(names, tmpl.Name)
+	}
+	assert.Contains(t, names, "api-key")
+	assert.Contains(t, names, "custom")
+	assert.Equal(t, "wifi", names[len(names)-1])
+	assert.Equal(t, "test_user", templates.templates[0].UserID)
+}
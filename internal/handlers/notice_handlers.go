@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// NoticeHandler tells a user what to attend to; clients poll it rather
+// than every request counting it.
+type NoticeHandler struct {
+	grants repository.EmergencyRepo
+	shares repository.ShareRepo
+}
+
+func NewNoticeHandler(grants repository.EmergencyRepo, shares repository.ShareRepo) *NoticeHandler {
+	return &NoticeHandler{grants: grants, shares: shares}
+}
+
+// GetNoticeHandler answers with the models.Notices of the user.
+func (nh *NoticeHandler) GetNoticeHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var notices models.Notices
+		var err error
+		if notices.EmergencyRequests, err = nh.grants.Pending(userID.(string)); err != nil {
+			log.Printf("Error counting emergency access requests: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if notices.SharesEdited, err = nh.shares.Edited(userID.(string)); err != nil {
+			log.Printf("Error counting edited shares: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(
+			http.StatusOK, gin.H{
+				"message": "Notices",
+				"notices": notices,
+			},
+		)
+	}
+}
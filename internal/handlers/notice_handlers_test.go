@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestNoticeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	grants := &MockEmergencyRepo{pending: 2}
+	shares := &MockShareRepo{edited: 1}
+	router := gin.New()
+	router.GET(
+		"/notices", func(ctx *gin.Context) {
+			ctx.Set("userID", "alice")
+		}, NewNoticeHandler(grants, shares).GetNoticeHandler(),
+	)
+
+	w := serve(router, "GET", "/notices", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Notices models.Notices `json:"notices"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.Notices{EmergencyRequests: 2, SharesEdited: 1}, resp.Notices)
+
+	grants.err = errors.New("database is down")
+	assert.Equal(t, http.StatusInternalServerError, serve(router, "GET", "/notices", nil).Code)
+}
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OrgHandler serves organizations and their collections. As with shares,
+// the server only checks roles and key versions: collection keys are
+// sealed by clients to the public keys of the members, and collection
+// items with the collection key.
+type OrgHandler struct {
+	orgs  repository.OrgRepo
+	users repository.UserRepo
+}
+
+func NewOrgHandler(orgs repository.OrgRepo, users repository.UserRepo) *OrgHandler {
+	return &OrgHandler{orgs: orgs, users: users}
+}
+
+// RegisterOrgRoutes adds the organization and collection routes to r. The
+// :id of the organization routes is the organization's, that of the
+// collection routes the collection's.
+func RegisterOrgRoutes(r gin.IRoutes, oh *OrgHandler) {
+	r.POST("/add-org", oh.AddOrgHandler())
+	r.GET("/get-org", oh.GetOrgHandler())
+	r.POST("/invite-org-member/:id", oh.InviteHandler())
+	r.POST("/accept-org/:id", oh.AcceptHandler())
+	r.GET("/get-org-member/:id", oh.MembersHandler())
+	r.DELETE("/remove-org-member/:id/:user", oh.RemoveMemberHandler())
+	r.POST("/add-collection/:id", oh.AddCollectionHandler())
+	r.GET("/get-collection/:id", oh.CollectionsHandler())
+	r.GET("/get-collection-item/:id", oh.ItemsHandler())
+	r.POST("/add-collection-item/:id", oh.AddItemHandler())
+	r.PUT("/update-collection-item/:id/:item", oh.UpdateItemHandler())
+	r.DELETE("/delete-collection-item/:id/:item", oh.DeleteItemHandler())
+}
+
+// respondOrgError maps organization repository errors to responses.
+func respondOrgError(ctx *gin.Context, what string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": what + " not found"})
+	case errors.Is(err, repository.ErrOrgPermission):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": repository.ErrOrgPermission.Error()})
+	case errors.Is(err, repository.ErrMemberExists):
+		ctx.JSON(http.StatusConflict, gin.H{"error": repository.ErrMemberExists.Error()})
+	case errors.Is(err, repository.ErrKeysMismatch):
+		ctx.JSON(http.StatusConflict, gin.H{"error": repository.ErrKeysMismatch.Error()})
+	case errors.Is(err, repository.ErrStaleKey):
+		ctx.JSON(http.StatusConflict, gin.H{"error": repository.ErrStaleKey.Error()})
+	default:
+		log.Printf("Error managing organization: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// itemParam parses the :item route parameter.
+func itemParam(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("item"), 10, 64)
+	if err != nil || id == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// AddOrgHandler creates an organization owned by the user; the body is an
+// encrypted models.Organization.
+func (oh *OrgHandler) AddOrgHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var org models.Organization
+		if !decryptRequest(ctx, &org) {
+			return
+		}
+		org.ID = 0
+		org.Name = strings.TrimSpace(org.Name)
+		if org.Name == "" {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEmptyName.Error()})
+			return
+		}
+
+		if err := oh.orgs.Create(&org, userID.(string)); err != nil {
+			respondOrgError(ctx, "Organization", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "Organization has been created",
+				"id":      org.ID,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+// GetOrgHandler lists the user's memberships and pending invitations.
+func (oh *OrgHandler) GetOrgHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		memberships, err := oh.orgs.Memberships(userID.(string))
+		if err != nil {
+			respondOrgError(ctx, "Organization", err)
+			return
+		}
+		respondEncrypted(ctx, "Organizations", memberships)
+	}
+}
+
+// InviteHandler invites a user with a public key to an organization; the
+// body is an encrypted models.OrgInvite. The role defaults to member.
+func (oh *OrgHandler) InviteHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orgID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		var invite models.OrgInvite
+		if !decryptRequest(ctx, &invite) {
+			return
+		}
+		if invite.Role == "" {
+			invite.Role = models.RoleMember
+		}
+		if err := validateInvite(userID.(string), &invite); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := publicKeyOf(ctx, oh.users, invite.UserID); !ok {
+			return
+		}
+
+		if err := oh.orgs.Invite(userID.(string), orgID, invite); err != nil {
+			respondOrgError(ctx, "Organization", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "User has been invited",
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+func validateInvite(userID string, invite *models.OrgInvite) error {
+	switch {
+	case invite.Role != models.RoleAdmin && invite.Role != models.RoleMember && invite.Role != models.RoleReadOnly:
+		return fmt.Errorf("invalid role %q", invite.Role)
+	case invite.UserID == userID:
+		return errors.New("cannot invite yourself")
+	}
+	return nil
+}
+
+// AcceptHandler accepts the user's invitation to an organization.
+func (oh *OrgHandler) AcceptHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orgID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := oh.orgs.Accept(userID.(string), orgID); err != nil {
+			respondOrgError(ctx, "Invitation", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Invitation has been accepted",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// MembersHandler lists the members of an organization with their public
+// keys, which clients seal collection keys to.
+func (oh *OrgHandler) MembersHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orgID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		members, err := oh.orgs.Members(userID.(string), orgID)
+		if err != nil {
+			respondOrgError(ctx, "Organization", err)
+			return
+		}
+		for _, m := range members {
+			user, err := oh.users.GetUserByUsername(m.UserID)
+			if err != nil {
+				log.Printf("Error getting member: %v", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			m.PublicKey = user.PublicKey
+		}
+		respondEncrypted(ctx, "Organization members", members)
+	}
+}
+
+// RemoveMemberHandler removes a member or invitation and rotates the
+// collection keys; the body is an encrypted list of
+// models.CollectionRotation, one for every collection. A rotation made
+// from stale keys or items gets 409.
+func (oh *OrgHandler) RemoveMemberHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orgID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		var rotations []*models.CollectionRotation
+		if !decryptRequest(ctx, &rotations) {
+			return
+		}
+
+		if err := oh.orgs.Remove(userID.(string), orgID, ctx.Param("user"), rotations); err != nil {
+			respondOrgError(ctx, "Member", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Member has been removed",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// AddCollectionHandler creates a collection in an organization; the body
+// is an encrypted models.NewCollection.
+func (oh *OrgHandler) AddCollectionHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orgID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		var nc models.NewCollection
+		if !decryptRequest(ctx, &nc) {
+			return
+		}
+		nc.Name = strings.TrimSpace(nc.Name)
+		if nc.Name == "" {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEmptyName.Error()})
+			return
+		}
+
+		collection := &models.Collection{OrgID: orgID, Name: nc.Name}
+		if err := oh.orgs.AddCollection(userID.(string), collection, nc.Keys); err != nil {
+			respondOrgError(ctx, "Organization", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "Collection has been added",
+				"id":      collection.ID,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+// CollectionsHandler lists the collections of an organization with the
+// user's sealed collection keys.
+func (oh *OrgHandler) CollectionsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orgID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		collections, err := oh.orgs.Collections(userID.(string), orgID)
+		if err != nil {
+			respondOrgError(ctx, "Organization", err)
+			return
+		}
+		respondEncrypted(ctx, "Collections", collections)
+	}
+}
+
+func (oh *OrgHandler) ItemsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		collectionID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		items, err := oh.orgs.Items(userID.(string), collectionID)
+		if err != nil {
+			respondOrgError(ctx, "Collection", err)
+			return
+		}
+		respondEncrypted(ctx, "Collection items", items)
+	}
+}
+
+// collectionItem decrypts a collection item from the request body.
+func collectionItem(ctx *gin.Context) (*models.CollectionItem, bool) {
+	collectionID, ok := idParam(ctx)
+	if !ok {
+		return nil, false
+	}
+	item := new(models.CollectionItem)
+	if !decryptRequest(ctx, item) {
+		return nil, false
+	}
+	if len(item.Data) == 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "item data is required"})
+		return nil, false
+	}
+	item.ID = 0
+	item.CollectionID = collectionID
+	return item, true
+}
+
+// AddItemHandler adds an item to a collection; the body is an encrypted
+// models.CollectionItem sealed with the current collection key.
+func (oh *OrgHandler) AddItemHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		item, ok := collectionItem(ctx)
+		if !ok {
+			return
+		}
+		if item.ItemType == "" {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "item type is required"})
+			return
+		}
+
+		if err := oh.orgs.SaveItem(userID.(string), item); err != nil {
+			respondOrgError(ctx, "Collection", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "Item has been added to the collection",
+				"id":      item.ID,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+// UpdateItemHandler replaces the data of a collection item; the body is an
+// encrypted models.CollectionItem sealed with the current collection key.
+func (oh *OrgHandler) UpdateItemHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := itemParam(ctx)
+		if !ok {
+			return
+		}
+		item, ok := collectionItem(ctx)
+		if !ok {
+			return
+		}
+		item.ID = id
+
+		if err := oh.orgs.UpdateItem(userID.(string), item); err != nil {
+			respondOrgError(ctx, "Collection item", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Collection item has been updated",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+func (oh *OrgHandler) DeleteItemHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		collectionID, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+		id, ok := itemParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := oh.orgs.DeleteItem(userID.(string), collectionID, id); err != nil {
+			respondOrgError(ctx, "Collection item", err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Collection item has been deleted",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
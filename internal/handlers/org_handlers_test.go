@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+)
+
+// MockOrgRepo records what it is given and fails with err when set.
+type MockOrgRepo struct {
+	err     error
+	invites []models.OrgInvite
+	items   []*models.CollectionItem
+	removed string
+}
+
+func (m *MockOrgRepo) Create(org *models.Organization, ownerID string) error {
+	org.ID = 1
+	return m.err
+}
+
+func (m *MockOrgRepo) Memberships(userID string) ([]*models.OrgMember, error) {
+	return []*models.OrgMember{{OrgID: 1, UserID: userID, Role: models.RoleOwner, OrgName: "acme"}}, m.err
+}
+
+func (m *MockOrgRepo) Invite(userID string, orgID uint, invite models.OrgInvite) error {
+	m.invites = append(m.invites, invite)
+	return m.err
+}
+
+func (m *MockOrgRepo) Accept(userID string, orgID uint) error {
+	return m.err
+}
+
+func (m *MockOrgRepo) Members(userID string, orgID uint) ([]*models.OrgMember, error) {
+	return []*models.OrgMember{{OrgID: orgID, UserID: "bob", Role: models.RoleMember}}, m.err
+}
+
+func (m *MockOrgRepo) Remove(
+	userID string,
+	orgID uint,
+	memberID string,
+	rotations []*models.CollectionRotation,
+) error {
+	m.removed = memberID
+	return m.err
+}
+
+func (m *MockOrgRepo) AddCollection(
+	userID string,
+	collection *models.Collection,
+	keys []*models.CollectionKey,
+) error {
+	collection.ID = 1
+	return m.err
+}
+
+func (m *MockOrgRepo) Collections(userID string, orgID uint) ([]*models.Collection, error) {
+	return nil, m.err
+}
+
+func (m *MockOrgRepo) Items(userID string, collectionID uint) ([]*models.CollectionItem, error) {
+	return m.items, m.err
+}
+
+func (m *MockOrgRepo) SaveItem(userID string, item *models.CollectionItem) error {
+	m.items = append(m.items, item)
+	return m.err
+}
+
+func (m *MockOrgRepo) UpdateItem(userID string, item *models.CollectionItem) error {
+	return m.err
+}
+
+func (m *MockOrgRepo) DeleteItem(userID string, collectionID, id uint) error {
+	return m.err
+}
+
+func TestOrgHandler(t *testing.T) {
+	users := new(MockUserRepo)
+	users.On("GetUserByUsername", "bob").Return(&models.User{Username: "bob", PublicKey: []byte("bob key")}, nil)
+	users.On("GetUserByUsername", "carol").Return(&models.User{Username: "carol"}, nil)
+	orgs := &MockOrgRepo{}
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "alice")
+		},
+	)
+	RegisterOrgRoutes(router, NewOrgHandler(orgs, users))
+	send := func(method, path string, v interface{}) int {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, v)})
+		return serve(router, method, path, bytes.NewBuffer(body)).Code
+	}
+
+	assert.Equal(t, http.StatusCreated, send("POST", "/add-org", models.Organization{Name: "acme"}))
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", "/add-org", models.Organization{Name: " "}))
+
+	invite := models.OrgInvite{UserID: "bob"}
+	assert.Equal(t, http.StatusCreated, send("POST", "/invite-org-member/1", invite))
+	require.Len(t, orgs.invites, 1)
+	assert.Equal(t, models.RoleMember, orgs.invites[0].Role)
+	for name, tc := range map[string]struct {
+		invite models.OrgInvite
+		want   int
+	}{
+		"owner role":    {models.OrgInvite{UserID: "bob", Role: models.RoleOwner}, http.StatusUnprocessableEntity},
+		"self":          {models.OrgInvite{UserID: "alice"}, http.StatusUnprocessableEntity},
+		"no public key": {models.OrgInvite{UserID: "carol"}, http.StatusUnprocessableEntity},
+	} {
+		assert.Equal(t, tc.want, send("POST", "/invite-org-member/1", tc.invite), name)
+	}
+	assert.Len(t, orgs.invites, 1)
+
+	w := serve(router, "GET", "/get-org-member/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var members []*models.OrgMember
+	decryptedBody(t, w, &members)
+	require.Len(t, members, 1)
+	assert.Equal(t, []byte("bob key"), members[0].PublicKey)
+
+	rotations := []*models.CollectionRotation{{CollectionID: 1, KeyVersion: 1}}
+	assert.Equal(t, http.StatusOK, send("DELETE", "/remove-org-member/1/bob", rotations))
+	assert.Equal(t, "bob", orgs.removed)
+
+	assert.Equal(
+		t,
+		http.StatusCreated,
+		send("POST", "/add-collection/1", models.NewCollection{Name: "servers"}),
+	)
+	item := models.CollectionItem{ItemType: "login-password", Data: []byte("sealed"), KeyVersion: 1}
+	assert.Equal(t, http.StatusCreated, send("POST", "/add-collection-item/1", item))
+	require.Len(t, orgs.items, 1)
+	assert.Equal(t, uint(1), orgs.items[0].CollectionID)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", "/add-collection-item/1", models.CollectionItem{}))
+	assert.Equal(t, http.StatusOK, send("PUT", "/update-collection-item/1/1", item))
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/update-collection-item/1/x", item))
+
+	for err, want := range map[error]int{
+		gorm.ErrRecordNotFound:      http.StatusNotFound,
+		repository.ErrOrgPermission: http.StatusForbidden,
+		repository.ErrMemberExists:  http.StatusConflict,
+		repository.ErrKeysMismatch:  http.StatusConflict,
+		repository.ErrStaleKey:      http.StatusConflict,
+	} {
+		orgs.err = err
+		assert.Equal(t, want, send("POST", "/invite-org-member/1", invite), err.Error())
+	}
+	orgs.err = repository.ErrStaleKey
+	assert.Equal(t, http.StatusConflict, send("PUT", "/update-collection-item/1/1", item))
+	orgs.err = gorm.ErrRecordNotFound
+	assert.Equal(t, http.StatusNotFound, serve(router, "POST", "/accept-org/1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/delete-collection-item/1/1", nil).Code)
+	users.AssertExpectations(t)
+}
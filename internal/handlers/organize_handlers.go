@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strings"
+)
+
+var errEmptyName = errors.New("name must not be empty")
+
+// OrganizeHandler serves the folders and tags used to organize vault items.
+type OrganizeHandler struct {
+	folders repository.FolderRepo
+	tags    repository.TagRepo
+}
+
+func NewOrganizeHandler(folders repository.FolderRepo, tags repository.TagRepo) *OrganizeHandler {
+	return &OrganizeHandler{folders: folders, tags: tags}
+}
+
+// respondFolderError maps folder repository errors to responses.
+func respondFolderError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+	case errors.Is(err, repository.ErrFolderNotFound):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Parent folder not found"})
+	case errors.Is(err, repository.ErrFolderCycle):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": repository.ErrFolderCycle.Error()})
+	default:
+		log.Printf("Error managing folder: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (oh *OrganizeHandler) AddFolderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var folder models.Folder
+		if !decryptRequest(ctx, &folder) {
+			return
+		}
+		folder.Name = strings.TrimSpace(folder.Name)
+		if folder.Name == "" {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEmptyName.Error()})
+			return
+		}
+		if folder.ParentID != nil && *folder.ParentID == 0 {
+			folder.ParentID = nil
+		}
+
+		folder.UserID = userID.(string)
+		if err := oh.folders.SaveNewFolder(&folder); err != nil {
+			respondFolderError(ctx, err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "Folder has been added",
+				"id":      folder.ID,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+func (oh *OrganizeHandler) GetFolderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		folders, err := oh.folders.GetFolders(userID.(string))
+		if err != nil {
+			log.Printf("Error getting folders: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		respondEncrypted(ctx, "Folder list", folders)
+	}
+}
+
+// UpdateFolderHandler renames or moves a folder; the body is an encrypted
+// models.FolderChange.
+func (oh *OrganizeHandler) UpdateFolderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		var change models.FolderChange
+		if !decryptRequest(ctx, &change) {
+			return
+		}
+		if change.Name != nil {
+			name := strings.TrimSpace(*change.Name)
+			if name == "" {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEmptyName.Error()})
+				return
+			}
+			change.Name = &name
+		}
+
+		if err := oh.folders.UpdateFolder(userID.(string), id, change); err != nil {
+			respondFolderError(ctx, err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Folder has been updated",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// DeleteFolderHandler deletes a folder; its subfolders and items move to
+// its parent.
+func (oh *OrganizeHandler) DeleteFolderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := oh.folders.DeleteFolder(userID.(string), id); err != nil {
+			respondFolderError(ctx, err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Folder has been deleted",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+func (oh *OrganizeHandler) GetTagHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		tags, err := oh.tags.GetTags(userID.(string))
+		if err != nil {
+			log.Printf("Error getting tags: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		respondEncrypted(ctx, "Tag list", tags)
+	}
+}
+
+// UpdateTagHandler renames a tag on all items; the body is an encrypted
+// {"name": ..., "new_name": ...}.
+func (oh *OrganizeHandler) UpdateTagHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var rename struct {
+			Name    string `json:"name"`
+			NewName string `json:"new_name"`
+		}
+		if !decryptRequest(ctx, &rename) {
+			return
+		}
+		if len(repository.NormalizeTags([]string{rename.NewName})) == 0 {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": errEmptyName.Error()})
+			return
+		}
+
+		if err := oh.tags.RenameTag(userID.(string), rename.Name, rename.NewName); err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			case errors.Is(err, repository.ErrTagExists):
+				ctx.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+			default:
+				log.Printf("Error renaming tag: %v", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Tag has been renamed",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// DeleteTagHandler removes a tag from all items.
+func (oh *OrganizeHandler) DeleteTagHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := oh.tags.DeleteTag(userID.(string), ctx.Param("name")); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+				return
+			}
+			log.Printf("Error deleting tag: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Tag has been deleted",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
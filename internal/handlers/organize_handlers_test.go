@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+)
+
+type MockFolderRepo struct {
+	folders []*models.Folder
+}
+
+func (m *MockFolderRepo) find(userID string, id uint) *models.Folder {
+	for _, f := range m.folders {
+		if f.ID == id && f.UserID == userID {
+			return f
+		}
+	}
+	return nil
+}
+
+func (m *MockFolderRepo) GetFolders(userID string) ([]*models.Folder, error) {
+	var folders []*models.Folder
+	for _, f := range m.folders {
+		if f.UserID == userID {
+			folders = append(folders, f)
+		}
+	}
+	return folders, nil
+}
+
+func (m *MockFolderRepo) SaveNewFolder(folder *models.Folder) error {
+	if folder.ParentID != nil && m.find(folder.UserID, *folder.ParentID) == nil {
+		return repository.ErrFolderNotFound
+	}
+	folder.ID = uint(len(m.folders) + 1)
+	m.folders = append(m.folders, folder)
+	return nil
+}
+
+func (m *MockFolderRepo) UpdateFolder(userID string, id uint, change models.FolderChange) error {
+	folder := m.find(userID, id)
+	if folder == nil {
+		return gorm.ErrRecordNotFound
+	}
+	if change.ParentID != nil && *change.ParentID == id {
+		return repository.ErrFolderCycle
+	}
+	if change.Name != nil {
+		folder.Name = *change.Name
+	}
+	return nil
+}
+
+func (m *MockFolderRepo) DeleteFolder(userID string, id uint) error {
+	for i, f := range m.folders {
+		if f.ID == id && f.UserID == userID {
+			m.folders = append(m.folders[:i], m.folders[i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+type MockTagRepo struct {
+	tags []*models.Tag
+}
+
+func (m *MockTagRepo) GetTags(userID string) ([]*models.Tag, error) {
+	return m.tags, nil
+}
+
+func (m *MockTagRepo) RenameTag(userID, name, newName string) error {
+	var found *models.Tag
+	for _, tag := range m.tags {
+		if tag.Name == newName {
+			return repository.ErrTagExists
+		}
+		if tag.Name == name {
+			found = tag
+		}
+	}
+	if found == nil {
+		return gorm.ErrRecordNotFound
+	}
+	found.Name = newName
+	return nil
+}
+
+func (m *MockTagRepo) DeleteTag(userID, name string) error {
+	for i, tag := range m.tags {
+		if tag.Name == name {
+			m.tags = append(m.tags[:i], m.tags[i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func setupOrganizeRouter(oh *OrganizeHandler) *gin.Engine {
+	router := setupItemRouter(NewItemHandler(newMockStore[models.Item](), &MockItemTemplateRepo{}))
+	router.POST("/api/data/add-folder", oh.AddFolderHandler())
+	router.GET("/api/data/get-folder", oh.GetFolderHandler())
+	router.PUT("/api/data/update-folder/:id", oh.UpdateFolderHandler())
+	router.DELETE("/api/data/delete-folder/:id", oh.DeleteFolderHandler())
+	router.GET("/api/data/get-tag", oh.GetTagHandler())
+	router.PUT("/api/data/update-tag", oh.UpdateTagHandler())
+	router.DELETE("/api/data/delete-tag/:name", oh.DeleteTagHandler())
+	return router
+}
+
+func TestOrganizeHandler_Folders(t *testing.T) {
+	folders := &MockFolderRepo{}
+	router := setupOrganizeRouter(NewOrganizeHandler(folders, &MockTagRepo{}))
+
+	w := serve(router, "POST", "/api/data/add-folder", encryptedBody(t, models.Folder{Name: " Work "}))
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"id": 1`)
+
+	missing := uint(7)
+	w = serve(
+		router, "POST", "/api/data/add-folder",
+		encryptedBody(t, models.Folder{Name: "Sub", ParentID: &missing}),
+	)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(router, "POST", "/api/data/add-folder", encryptedBody(t, models.Folder{Name: "  "}))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(router, "GET", "/api/data/get-folder", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []*models.Folder
+	decryptedBody(t, w, &list)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "Work", list[0].Name)
+	}
+
+	name := "Office"
+	w = serve(
+		router, "PUT", "/api/data/update-folder/1",
+		encryptedBody(t, models.FolderChange{Name: &name}),
+	)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Office", folders.folders[0].Name)
+
+	self := uint(1)
+	w = serve(
+		router, "PUT", "/api/data/update-folder/1",
+		encryptedBody(t, models.FolderChange{ParentID: &self}),
+	)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(
+		router, "PUT", "/api/data/update-folder/5",
+		encryptedBody(t, models.FolderChange{Name: &name}),
+	)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, "DELETE", "/api/data/delete-folder/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(router, "DELETE", "/api/data/delete-folder/1", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOrganizeHandler_Tags(t *testing.T) {
+	tags := &MockTagRepo{tags: []*models.Tag{{Name: "home"}, {Name: "work"}}}
+	router := setupOrganizeRouter(NewOrganizeHandler(&MockFolderRepo{}, tags))
+
+	w := serve(router, "GET", "/api/data/get-tag", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []*models.Tag
+	decryptedBody(t, w, &list)
+	assert.Len(t, list, 2)
+
+	rename := func(name, newName string) int {
+		body := encryptedBody(t, map[string]string{"name": name, "new_name": newName})
+		return serve(router, "PUT", "/api/data/update-tag", body).Code
+	}
+	assert.Equal(t, http.StatusOK, rename("home", "house"))
+	assert.Equal(t, http.StatusConflict, rename("house", "work"))
+	assert.Equal(t, http.StatusNotFound, rename("garden", "yard"))
+	assert.Equal(t, http.StatusUnprocessableEntity, rename("house", " "))
+
+	w = serve(router, "DELETE", "/api/data/delete-tag/work", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(router, "DELETE", "/api/data/delete-tag/work", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// itemBodySlack is the room in an item's body beyond its content.
+const itemBodySlack = 1 << 20
+
+// Quota limits what each user can store; zero fields are unlimited.
+// MaxBytes bounds the binary data contents of a user, MaxItemBytes the
+// content of one binary data item and MaxItems the items of each type.
+type Quota struct {
+	MaxBytes     int64 `json:"max_bytes"`
+	MaxItemBytes int64 `json:"max_item_bytes"`
+	MaxItems     int64 `json:"max_items"`
+}
+
+// Limits enforces a Quota. Items that would exceed it are refused with 413
+// when they are too large on their own and with 507 when the user has run
+// out of room. A nil *Limits allows everything.
+type Limits struct {
+	quota Quota
+	usage repository.UsageRepo
+}
+
+func NewLimits(quota Quota, usage repository.UsageRepo) *Limits {
+	return &Limits{quota: quota, usage: usage}
+}
+
+// allowItem checks that the user may add one more item.
+func (l *Limits) allowItem(ctx *gin.Context, userID string, model interface{}) bool {
+	return l.allowItems(ctx, userID, model, 1)
+}
+
+// allowItems checks that the user may add count more items.
+func (l *Limits) allowItems(ctx *gin.Context, userID string, model interface{}, count int64) bool {
+	if l == nil || l.quota.MaxItems == 0 {
+		return true
+	}
+	n, err := l.usage.Items(userID, model)
+	if err != nil {
+		log.Printf("Error checking quota: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if n+count > l.quota.MaxItems {
+		ctx.JSON(
+			http.StatusInsufficientStorage, gin.H{
+				"error": "Item limit reached",
+				"limit": l.quota.MaxItems,
+			},
+		)
+		return false
+	}
+	return true
+}
+
+// limitBody caps the body of an item at MaxItemBytes plus slack.
+func (l *Limits) limitBody(ctx *gin.Context) {
+	l.limitBatchBody(ctx, 1)
+}
+
+// limitBatchBody is limitBody for a request with up to items items.
+func (l *Limits) limitBatchBody(ctx *gin.Context, items int64) {
+	if l == nil || l.quota.MaxItemBytes == 0 {
+		return
+	}
+	// The content is base64 encoded twice, around the encryption.
+	limit := (l.quota.MaxItemBytes + itemBodySlack) * 16 / 9 * items
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+}
+
+// allowBytes checks size bytes against MaxItemBytes and MaxBytes.
+func (l *Limits) allowBytes(ctx *gin.Context, userID string, size int64, added bool) bool {
+	if l == nil {
+		return true
+	}
+	if l.quota.MaxItemBytes > 0 && size > l.quota.MaxItemBytes {
+		ctx.JSON(
+			http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Item is too large",
+				"limit": l.quota.MaxItemBytes,
+			},
+		)
+		return false
+	}
+	return !added || l.allowAdded(ctx, userID, size)
+}
+
+// allowAdded checks that size more bytes fit in MaxBytes.
+func (l *Limits) allowAdded(ctx *gin.Context, userID string, size int64) bool {
+	if l == nil || l.quota.MaxBytes == 0 {
+		return true
+	}
+	used, err := l.usage.Bytes(userID)
+	if err != nil {
+		log.Printf("Error checking quota: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if used+size > l.quota.MaxBytes {
+		ctx.JSON(
+			http.StatusInsufficientStorage, gin.H{
+				"error": "Storage quota exceeded",
+				"limit": l.quota.MaxBytes,
+				"used":  used,
+			},
+		)
+		return false
+	}
+	return true
+}
+
+// UsageHandler answers with the user's usage and quota.
+func (l *Limits) UsageHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		usage, err := l.usage.Usage(userID.(string))
+		if err != nil {
+			log.Printf("Error getting usage: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(
+			http.StatusOK, gin.H{
+				"message": "Storage usage",
+				"usage":   usage,
+				"quota":   l.quota,
+			},
+		)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockUsageRepo struct {
+	items int64
+	bytes int64
+}
+
+func (m *MockUsageRepo) Usage(userID string) (*repository.Usage, error) {
+	return &repository.Usage{Bytes: m.bytes, Items: map[string]int64{"binary_data": m.items}}, nil
+}
+
+func (m *MockUsageRepo) Items(userID string, model interface{}) (int64, error) {
+	return m.items, nil
+}
+
+func (m *MockUsageRepo) Bytes(userID string) (int64, error) {
+	return m.bytes, nil
+}
+
+func TestLimits(t *testing.T) {
+	usage := &MockUsageRepo{items: 1, bytes: 90}
+	limits := NewLimits(Quota{MaxBytes: 100, MaxItemBytes: 20, MaxItems: 2}, usage)
+	store := newMockStore[models.BinaryData]()
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "test_user")
+		},
+	)
+	RegisterItem(router, limited(BinaryDataSpec(store), limits))
+	router.GET("/usage", limits.UsageHandler())
+	add := func(content []byte) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, models.BinaryData{Content: content})})
+		req, _ := http.NewRequest("POST", "/add-binary-data", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, add(bytes.Repeat([]byte("a"), 10)).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, add(bytes.Repeat([]byte("a"), 21)).Code)
+	assert.Equal(t, http.StatusInsufficientStorage, add(bytes.Repeat([]byte("a"), 11)).Code)
+	w := add(bytes.Repeat([]byte("a"), 2<<20))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "Request body is too large")
+	addBatch := func(contents ...[]byte) int {
+		items := make([]models.BinaryData, len(contents))
+		for i, content := range contents {
+			items[i].Content = content
+		}
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, items)})
+		req, _ := http.NewRequest("POST", "/add-binary-data-batch", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	usage.items = 0
+	assert.Equal(t, http.StatusRequestEntityTooLarge, addBatch([]byte("a"), bytes.Repeat([]byte("a"), 21)))
+	assert.Equal(t, http.StatusInsufficientStorage, addBatch([]byte("a"), []byte("a"), []byte("a")))
+	assert.Equal(t, http.StatusInsufficientStorage, addBatch([]byte("a"), bytes.Repeat([]byte("a"), 10)))
+	assert.Equal(t, http.StatusCreated, addBatch([]byte("a"), bytes.Repeat([]byte("a"), 9)))
+	assert.Len(t, store.state.saved, 3)
+	usage.items = 2
+	assert.Equal(t, http.StatusInsufficientStorage, add([]byte("a")).Code)
+	assert.Len(t, store.state.saved, 3)
+
+	req, _ := http.NewRequest("GET", "/usage", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var got struct {
+		Usage repository.Usage `json:"usage"`
+		Quota Quota            `json:"quota"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, int64(90), got.Usage.Bytes)
+	assert.Equal(t, int64(100), got.Quota.MaxBytes)
+}
+
+func TestLimits_Update(t *testing.T) {
+	usage := &MockUsageRepo{bytes: 95}
+	limits := NewLimits(Quota{MaxBytes: 100, MaxItemBytes: 20}, usage)
+	store := newMockStore[models.BinaryData]()
+	store.items = []*models.BinaryData{{Content: []byte("a")}}
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "test_user")
+		},
+	)
+	RegisterItem(router, limited(BinaryDataSpec(store), limits))
+	update := func(path string, content []byte) int {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, models.BinaryData{Content: content})})
+		req, _ := http.NewRequest("PUT", path, bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Growing an item counts against the quota like adding the difference.
+	assert.Equal(t, http.StatusInsufficientStorage, update("/update-binary-data/1", bytes.Repeat([]byte("a"), 7)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, update("/update-binary-data/1", bytes.Repeat([]byte("a"), 21)))
+	assert.Equal(t, http.StatusOK, update("/update-binary-data/1", bytes.Repeat([]byte("a"), 6)))
+	usage.bytes = 100
+	assert.Equal(t, http.StatusOK, update("/update-binary-data/1", []byte("a")))
+	assert.Equal(t, http.StatusNotFound, update("/update-binary-data/2", []byte("a")))
+}
+
+func TestLimits_Nil(t *testing.T) {
+	var limits *Limits
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	assert.True(t, limits.allowItem(ctx, "test_user", &models.TextData{}))
+	assert.True(t, limits.allowBytes(ctx, "test_user", 1<<40, true))
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+)
+
+// searchType is a secret type covered by the blind index search.
+type searchType struct {
+	route string
+	find  func(userID string, tokens []string) (interface{}, error)
+	index func(userID string, id uint, tokens []string) error
+}
+
+func searchable[T any](spec ItemSpec[T]) searchType {
+	return searchType{
+		route: spec.Route,
+		find: func(userID string, tokens []string) (interface{}, error) {
+			items, err := spec.Store.List(userID, repository.ListFilter{SearchTokens: tokens})
+			if err != nil {
+				return nil, err
+			}
+			if spec.Present != nil {
+				spec.Present(items)
+			}
+			return items, nil
+		},
+		index: spec.Store.Index,
+	}
+}
+
+// SearchIndexEntry is the blind index of one item, keyed by the route of
+// its type, e.g. "login-password".
+type SearchIndexEntry struct {
+	Type   string   `json:"type"`
+	ID     uint     `json:"id"`
+	Tokens []string `json:"tokens"`
+}
+
+// SearchHandler matches blind index tokens computed by the client against
+// the login-password, card, text-data and binary-data items.
+type SearchHandler struct {
+	types []searchType
+}
+
+func NewSearchHandler(s DataStores) *SearchHandler {
+	return &SearchHandler{
+		types: []searchType{
+			searchable(LoginPasswordSpec(s.LoginPasswords)),
+			searchable(CreditCardSpec(s.CreditCards)),
+			searchable(TextDataSpec(s.TextData)),
+			searchable(BinaryDataSpec(s.BinaryData)),
+		},
+	}
+}
+
+// RegisterSearchRoutes adds GET search and PUT search-index to r.
+func RegisterSearchRoutes(r gin.IRoutes, s DataStores) {
+	sh := NewSearchHandler(s)
+	r.GET("/search", sh.SearchHandler())
+	r.PUT("/search-index", sh.IndexHandler())
+}
+
+// SearchHandler lists the items whose index holds every "t" query token,
+// grouped by type.
+func (sh *SearchHandler) SearchHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		tokens := ctx.QueryArray("t")
+		if len(tokens) == 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "No search tokens"})
+			return
+		}
+
+		results := make(map[string]interface{}, len(sh.types))
+		for _, t := range sh.types {
+			items, err := t.find(userID.(string), tokens)
+			if err != nil {
+				log.Printf("Error searching %s: %v", t.route, err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			results[t.route] = items
+		}
+		respondEncrypted(ctx, "Search results", results)
+	}
+}
+
+// IndexHandler replaces the blind index of the items in an encrypted list
+// of SearchIndexEntry, e.g. after tags were renamed or for items stored
+// before they had one.
+func (sh *SearchHandler) IndexHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var entries []SearchIndexEntry
+		if !decryptRequest(ctx, &entries) {
+			return
+		}
+
+		for _, e := range entries {
+			t, ok := sh.lookup(e.Type)
+			if !ok {
+				ctx.JSON(
+					http.StatusUnprocessableEntity,
+					gin.H{"error": fmt.Sprintf("Unsupported item type %q", e.Type)},
+				)
+				return
+			}
+			if err := t.index(userID.(string), e.ID, e.Tokens); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					ctx.JSON(
+						http.StatusNotFound,
+						gin.H{"error": fmt.Sprintf("Item %s %d not found", e.Type, e.ID)},
+					)
+					return
+				}
+				log.Printf("Error indexing %s %d: %v", e.Type, e.ID, err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Search index has been updated",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+func (sh *SearchHandler) lookup(route string) (searchType, bool) {
+	for _, t := range sh.types {
+		if t.route == route {
+			return t, true
+		}
+	}
+	return searchType{}, false
+}
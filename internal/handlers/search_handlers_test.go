@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+)
+
+func searchStores() (DataStores, *MockStore[models.LoginPassword]) {
+	logins := newMockStore(&models.LoginPassword{UserID: "test_user", Login: "octocat"})
+	return DataStores{
+		LoginPasswords: logins,
+		TextData:       newMockStore[models.TextData](),
+		BinaryData:     newMockStore[models.BinaryData](),
+		CreditCards:    newMockStore(&models.CreditCard{UserID: "test_user", CardNumber: "4111111111111111"}),
+		OTPSecrets:     newMockStore[models.OTPSecret](),
+		SSHKeys:        newMockStore[models.SSHKey](),
+	}, logins
+}
+
+func TestSearchHandler_Search(t *testing.T) {
+	stores, logins := searchStores()
+	router := setupItemRouter(NewItemHandler(newMockStore[models.Item](), &MockItemTemplateRepo{}))
+	RegisterSearchRoutes(router.Group("/api/data"), stores)
+
+	w := serve(router, "GET", "/api/data/search?t=aa&t=bb", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"aa", "bb"}, logins.state.filter.SearchTokens)
+
+	var results struct {
+		LoginPasswords []*models.LoginPassword `json:"login-password"`
+		CreditCards    []*models.CreditCard    `json:"card"`
+		TextData       []*models.TextData      `json:"text-data"`
+		BinaryData     []*models.BinaryData    `json:"binary-data"`
+	}
+	decryptedBody(t, w, &results)
+	if assert.Len(t, results.LoginPasswords, 1) {
+		assert.Equal(t, "octocat", results.LoginPasswords[0].Login)
+	}
+	if assert.Len(t, results.CreditCards, 1) {
+		assert.Equal(t, "visa", results.CreditCards[0].Brand)
+	}
+	assert.Empty(t, results.TextData)
+
+	w = serve(router, "GET", "/api/data/search", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSearchHandler_Index(t *testing.T) {
+	stores, logins := searchStores()
+	router := setupItemRouter(NewItemHandler(newMockStore[models.Item](), &MockItemTemplateRepo{}))
+	RegisterSearchRoutes(router.Group("/api/data"), stores)
+
+	entries := []SearchIndexEntry{{Type: "login-password", ID: 4, Tokens: []string{"aa"}}}
+	w := serve(router, "PUT", "/api/data/search-index", encryptedBody(t, entries))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[uint][]string{4: {"aa"}}, logins.state.indexed)
+
+	entries[0].Type = "ssh-key"
+	w = serve(router, "PUT", "/api/data/search-index", encryptedBody(t, entries))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	entries[0].Type = "login-password"
+	logins.state.indexErr = fmt.Errorf("failed: %w", gorm.ErrRecordNotFound)
+	w = serve(router, "PUT", "/api/data/search-index", encryptedBody(t, entries))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
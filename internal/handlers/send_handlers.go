@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultSendViews = 1
+	maxSendViews     = 100
+	defaultSendAge   = 24 * time.Hour
+	maxSendAge       = 30 * 24 * time.Hour
+	// maxSendFailures is the number of wrong passwords that delete a send.
+	maxSendFailures = 5
+)
+
+// SendHandler serves sends, links that hand content to anyone. The content
+// is sealed by the creator's client with a key kept in the fragment of the
+// link, which browsers and clients do not send to the server.
+type SendHandler struct {
+	sends repository.SendRepo
+}
+
+func NewSendHandler(sends repository.SendRepo) *SendHandler {
+	return &SendHandler{sends: sends}
+}
+
+// CreateSendHandler stores a send of the user; the body is a plain JSON
+// models.NewSend, as its data is already sealed. It answers with the
+// access ID of the link.
+func (sh *SendHandler) CreateSendHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req models.NewSend
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		send, err := newSend(userID.(string), &req, time.Now())
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Password != "" {
+			if send.PasswordHash, err = security.HashPassword(req.Password); err != nil {
+				log.Printf("Error hashing send password: %v", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := sh.sends.Create(send); err != nil {
+			log.Printf("Error creating send: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message":    "Send has been created",
+				"id":         send.AccessID,
+				"max_views":  send.MaxViews,
+				"expires_at": send.ExpiresAt,
+				"status":     http.StatusCreated,
+			},
+		)
+	}
+}
+
+// newSend validates req and applies the defaults to it.
+func newSend(userID string, req *models.NewSend, now time.Time) (*models.Send, error) {
+	send := &models.Send{
+		UserID:    userID,
+		Data:      req.Data,
+		MaxViews:  req.MaxViews,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if send.MaxViews == 0 {
+		send.MaxViews = defaultSendViews
+	}
+	if send.ExpiresAt.IsZero() {
+		send.ExpiresAt = now.Add(defaultSendAge)
+	}
+	switch {
+	case len(send.Data) == 0:
+		return nil, errors.New("send data is required")
+	case send.MaxViews < 0 || send.MaxViews > maxSendViews:
+		return nil, fmt.Errorf("max views must be between 1 and %d", maxSendViews)
+	case !send.ExpiresAt.After(now) || send.ExpiresAt.After(now.Add(maxSendAge)):
+		return nil, fmt.Errorf("expiry must be within %s", maxSendAge)
+	}
+	return send, nil
+}
+
+// ReceiveSendHandler answers with the sealed data of a send and counts the
+// read; the last allowed read deletes the send. It needs no account, but
+// the password of a protected send in the models.SendPasswordHeader
+// header. Wrong passwords do not count as reads, but maxSendFailures of
+// them delete the send.
+func (sh *SendHandler) ReceiveSendHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		send, err := sh.sends.Find(ctx.Param("id"), time.Now())
+		if err != nil {
+			respondSendError(ctx, err)
+			return
+		}
+		if send.PasswordHash != "" {
+			password := ctx.GetHeader(models.SendPasswordHeader)
+			if password == "" {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Password required"})
+				return
+			}
+			if !security.CheckPasswordHash(password, send.PasswordHash) {
+				if err := sh.sends.Fail(send, maxSendFailures); err != nil {
+					respondSendError(ctx, err)
+					return
+				}
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+				return
+			}
+		}
+		if err := sh.sends.View(send); err != nil {
+			respondSendError(ctx, err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"data":       send.Data,
+				"views_left": send.MaxViews - send.Views,
+				"expires_at": send.ExpiresAt,
+			},
+		)
+	}
+}
+
+// DeleteSendHandler deletes a send of the user, so that its link no longer
+// opens.
+func (sh *SendHandler) DeleteSendHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := sh.sends.Delete(userID.(string), ctx.Param("id")); err != nil {
+			respondSendError(ctx, err)
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Send has been deleted",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// respondSendError answers 404 for any send that cannot be read.
+func respondSendError(ctx *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Send not found"})
+		return
+	}
+	log.Printf("Error receiving send: %v", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
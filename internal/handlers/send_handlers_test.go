@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// MockSendRepo keeps sends in memory by access ID.
+type MockSendRepo struct {
+	sends map[string]*models.Send
+}
+
+func (m *MockSendRepo) Create(send *models.Send) error {
+	send.AccessID = fmt.Sprintf("send%d", len(m.sends)+1)
+	m.sends[send.AccessID] = send
+	return nil
+}
+
+func (m *MockSendRepo) Find(accessID string, now time.Time) (*models.Send, error) {
+	send, ok := m.sends[accessID]
+	if !ok || !send.ExpiresAt.After(now) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *send
+	return &found, nil
+}
+
+func (m *MockSendRepo) View(send *models.Send) error {
+	stored := m.sends[send.AccessID]
+	stored.Views++
+	if stored.Views >= stored.MaxViews {
+		delete(m.sends, send.AccessID)
+	}
+	send.Views = stored.Views
+	return nil
+}
+
+func (m *MockSendRepo) Fail(send *models.Send, limit int) error {
+	stored := m.sends[send.AccessID]
+	stored.FailedAttempts++
+	if stored.FailedAttempts >= limit {
+		delete(m.sends, send.AccessID)
+	}
+	send.FailedAttempts = stored.FailedAttempts
+	return nil
+}
+
+func (m *MockSendRepo) Delete(userID, accessID string) error {
+	send, ok := m.sends[accessID]
+	if !ok || send.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(m.sends, accessID)
+	return nil
+}
+
+func TestSendHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sends := &MockSendRepo{sends: map[string]*models.Send{}}
+	sh := NewSendHandler(sends)
+	router := gin.New()
+	router.POST(
+		"/send", func(ctx *gin.Context) {
+			ctx.Set("userID", "alice")
+		}, sh.CreateSendHandler(),
+	)
+	router.GET("/send/:id", sh.ReceiveSendHandler())
+	router.DELETE(
+		"/send/:id", func(ctx *gin.Context) {
+			ctx.Set("userID", ctx.GetHeader("X-User"))
+		}, sh.DeleteSendHandler(),
+	)
+	create := func(req models.NewSend) (int, string) {
+		body, _ := json.Marshal(req)
+		w := serve(router, "POST", "/send", bytes.NewBuffer(body))
+		var resp struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.ID
+	}
+	receive := func(id, password string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/send/"+id, nil)
+		if password != "" {
+			req.Header.Set(models.SendPasswordHeader, password)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for name, req := range map[string]models.NewSend{
+		"no data":       {},
+		"too many":      {Data: []byte("x"), MaxViews: maxSendViews + 1},
+		"expired":       {Data: []byte("x"), ExpiresAt: time.Now().Add(-time.Minute)},
+		"too long-term": {Data: []byte("x"), ExpiresAt: time.Now().Add(maxSendAge + time.Hour)},
+	} {
+		code, _ := create(req)
+		assert.Equal(t, http.StatusUnprocessableEntity, code, name)
+	}
+
+	code, id := create(models.NewSend{Data: []byte("sealed")})
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, 1, sends.sends[id].MaxViews)
+	assert.WithinDuration(t, time.Now().Add(defaultSendAge), sends.sends[id].ExpiresAt, time.Minute)
+	w := receive(id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data      []byte `json:"data"`
+		ViewsLeft int    `json:"views_left"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []byte("sealed"), resp.Data)
+	assert.Equal(t, 0, resp.ViewsLeft)
+	assert.Equal(t, http.StatusNotFound, receive(id, "").Code)
+
+	code, id = create(models.NewSend{Data: []byte("sealed"), MaxViews: 2, Password: "hunter2"})
+	require.Equal(t, http.StatusCreated, code)
+	assert.NotEqual(t, "hunter2", sends.sends[id].PasswordHash)
+	assert.Equal(t, http.StatusUnauthorized, receive(id, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, receive(id, "wrong").Code)
+	assert.Equal(t, 0, sends.sends[id].Views)
+	assert.Equal(t, http.StatusOK, receive(id, "hunter2").Code)
+	assert.Equal(t, http.StatusOK, receive(id, "hunter2").Code)
+	assert.Equal(t, http.StatusNotFound, receive(id, "hunter2").Code)
+
+	_, id = create(models.NewSend{Data: []byte("sealed"), Password: "hunter2"})
+	for i := 1; i < maxSendFailures; i++ {
+		assert.Equal(t, http.StatusUnauthorized, receive(id, "wrong").Code)
+	}
+	assert.Equal(t, maxSendFailures-1, sends.sends[id].FailedAttempts)
+	assert.Equal(t, http.StatusUnauthorized, receive(id, "wrong").Code)
+	assert.Equal(t, http.StatusNotFound, receive(id, "hunter2").Code)
+
+	_, id = create(models.NewSend{Data: []byte("sealed")})
+	remove := func(userID string) int {
+		req, _ := http.NewRequest("DELETE", "/send/"+id, nil)
+		req.Header.Set("X-User", userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusNotFound, remove("bob"))
+	assert.Equal(t, http.StatusOK, remove("alice"))
+	assert.Equal(t, http.StatusNotFound, receive(id, "").Code)
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+)
+
+// shareableType is a secret type whose items can be shared.
+type shareableType struct {
+	route  string
+	exists func(userID string, id uint) (bool, error)
+}
+
+func shareable[T any](route string, store repository.Store[T]) shareableType {
+	return shareableType{
+		route: route,
+		exists: func(userID string, id uint) (bool, error) {
+			items, err := store.List(userID, repository.ListFilter{IDs: []uint{id}})
+			return len(items) > 0, err
+		},
+	}
+}
+
+// ShareHandler shares items with other users. The server only checks who
+// may read and replace a share: its data and keys are sealed by the
+// owner's client to the public keys of the owner and the recipient. Binary
+// data cannot be shared, as its contents are not part of the item.
+type ShareHandler struct {
+	shares repository.ShareRepo
+	users  repository.UserRepo
+	types  []shareableType
+}
+
+func NewShareHandler(
+	shares repository.ShareRepo,
+	users repository.UserRepo,
+	s DataStores,
+	items repository.Store[models.Item],
+) *ShareHandler {
+	return &ShareHandler{
+		shares: shares,
+		users:  users,
+		types: []shareableType{
+			shareable("card", s.CreditCards),
+			shareable("text-data", s.TextData),
+			shareable("login-password", s.LoginPasswords),
+			shareable("otp", s.OTPSecrets),
+			shareable("ssh-key", s.SSHKeys),
+			shareable("item", items),
+		},
+	}
+}
+
+// RegisterShareRoutes adds GET get-public-key/:username, POST add-share,
+// GET get-share, GET get-shared-with-me, PUT update-share/:id and DELETE
+// delete-share/:id to r.
+func RegisterShareRoutes(r gin.IRoutes, sh *ShareHandler) {
+	r.GET("/get-public-key/:username", sh.PublicKeyHandler())
+	r.POST("/add-share", sh.AddShareHandler())
+	r.GET("/get-share", sh.ListHandler(sh.shares.SharedBy, "Shared items"))
+	r.GET("/get-shared-with-me", sh.ListHandler(sh.shares.SharedWith, "Items shared with me"))
+	r.PUT("/update-share/:id", sh.UpdateShareHandler())
+	r.DELETE("/delete-share/:id", sh.RevokeHandler())
+}
+
+// PublicKeyHandler answers with the public key of a user, which a client
+// seals the item key of a share to.
+func (sh *ShareHandler) PublicKeyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		publicKey, ok := publicKeyOf(ctx, sh.users, ctx.Param("username"))
+		if !ok {
+			return
+		}
+		ctx.JSON(
+			http.StatusOK, gin.H{
+				"username":   ctx.Param("username"),
+				"public_key": publicKey,
+			},
+		)
+	}
+}
+
+// publicKeyOf looks up the public key of a user.
+func publicKeyOf(ctx *gin.Context, users repository.UserRepo, username string) ([]byte, bool) {
+	user, err := users.GetUserByUsername(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return nil, false
+		}
+		log.Printf("Error getting user: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(user.PublicKey) == 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "User has no public key"})
+		return nil, false
+	}
+	return user.PublicKey, true
+}
+
+// AddShareHandler shares one of the user's items; the body is an encrypted
+// models.Share. Sharing an item again with the same user replaces the
+// share.
+func (sh *ShareHandler) AddShareHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		share := new(models.Share)
+		if !decryptRequest(ctx, share) {
+			return
+		}
+		share.ID = 0
+		share.OwnerID = userID.(string)
+		if share.Permission == "" {
+			share.Permission = models.PermissionRead
+		}
+		if err := validateShare(share); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := publicKeyOf(ctx, sh.users, share.RecipientID); !ok {
+			return
+		}
+		if !sh.checkItem(ctx, share) {
+			return
+		}
+
+		if err := sh.shares.Save(share); err != nil {
+			log.Printf("Error sharing item: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusCreated, gin.H{
+				"message": "Item has been shared",
+				"id":      share.ID,
+				"status":  http.StatusCreated,
+			},
+		)
+	}
+}
+
+func validateShare(share *models.Share) error {
+	switch {
+	case share.Permission != models.PermissionRead && share.Permission != models.PermissionEdit:
+		return fmt.Errorf("invalid permission %q", share.Permission)
+	case share.RecipientID == share.OwnerID:
+		return errors.New("cannot share an item with yourself")
+	case len(share.Data) == 0 || len(share.OwnerKey) == 0 || len(share.RecipientKey) == 0:
+		return errors.New("share data and keys are required")
+	}
+	return nil
+}
+
+// checkItem checks that the shared item is a live item of its owner.
+func (sh *ShareHandler) checkItem(ctx *gin.Context, share *models.Share) bool {
+	for _, t := range sh.types {
+		if t.route != share.ItemType {
+			continue
+		}
+		ok, err := t.exists(share.OwnerID, share.ItemID)
+		if err != nil {
+			log.Printf("Error checking shared %s: %v", t.route, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		}
+		return ok
+	}
+	ctx.JSON(
+		http.StatusUnprocessableEntity,
+		gin.H{"error": fmt.Sprintf("Unsupported item type %q", share.ItemType)},
+	)
+	return false
+}
+
+// ListHandler lists the shares returned by list for the user.
+func (sh *ShareHandler) ListHandler(
+	list func(userID string) ([]*models.Share, error),
+	message string,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		shares, err := list(userID.(string))
+		if err != nil {
+			log.Printf("Error getting shares: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		respondEncrypted(ctx, message, shares)
+	}
+}
+
+// UpdateShareHandler replaces the sealed data of a share; the body is an
+// encrypted models.Share of which only Data is used. Recipients of
+// read-only shares get 403.
+func (sh *ShareHandler) UpdateShareHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		var change models.Share
+		if !decryptRequest(ctx, &change) {
+			return
+		}
+		if len(change.Data) == 0 {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "share data is required"})
+			return
+		}
+
+		if err := sh.shares.UpdateData(userID.(string), id, change.Data); err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+			case errors.Is(err, repository.ErrReadOnlyShare):
+				ctx.JSON(http.StatusForbidden, gin.H{"error": "Share is read-only"})
+			default:
+				log.Printf("Error updating share: %v", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Share has been updated",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+// RevokeHandler deletes one of the user's shares; the recipient can no
+// longer get its data.
+func (sh *ShareHandler) RevokeHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := sh.shares.Revoke(userID.(string), id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+				return
+			}
+			log.Printf("Error revoking share: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Share has been revoked",
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
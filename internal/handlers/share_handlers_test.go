@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"testing"
+)
+
+type MockShareRepo struct {
+	saved     []*models.Share
+	edited    int64
+	updateErr error
+	revokeErr error
+}
+
+func (m *MockShareRepo) Save(share *models.Share) error {
+	share.ID = uint(len(m.saved) + 1)
+	m.saved = append(m.saved, share)
+	return nil
+}
+
+func (m *MockShareRepo) SharedBy(ownerID string) ([]*models.Share, error) {
+	return m.saved, nil
+}
+
+func (m *MockShareRepo) SharedWith(recipientID string) ([]*models.Share, error) {
+	var shares []*models.Share
+	for _, s := range m.saved {
+		if s.RecipientID == recipientID {
+			shares = append(shares, s)
+		}
+	}
+	return shares, nil
+}
+
+func (m *MockShareRepo) UpdateData(userID string, id uint, data []byte) error {
+	return m.updateErr
+}
+
+func (m *MockShareRepo) Edited(ownerID string) (int64, error) {
+	return m.edited, nil
+}
+
+func (m *MockShareRepo) Revoke(ownerID string, id uint) error {
+	return m.revokeErr
+}
+
+func TestShareHandler(t *testing.T) {
+	users := new(MockUserRepo)
+	users.On("GetUserByUsername", "bob").Return(&models.User{Username: "bob", PublicKey: []byte("bob key")}, nil)
+	users.On("GetUserByUsername", "carol").Return(&models.User{Username: "carol"}, nil)
+	users.On("GetUserByUsername", "nobody").
+		Return((*models.User)(nil), fmt.Errorf("failed to get user: %w", gorm.ErrRecordNotFound))
+	shares := &MockShareRepo{}
+	stores := DataStores{
+		LoginPasswords: newMockStore(&models.LoginPassword{Login: "admin", Password: "secret"}),
+		CreditCards:    newMockStore[models.CreditCard](),
+	}
+	router := newDataRouter(
+		func(ctx *gin.Context) {
+			ctx.Set("personalKey", testPersonalKey)
+			ctx.Set("userID", "alice")
+		},
+	)
+	RegisterShareRoutes(router, NewShareHandler(shares, users, stores, newMockStore[models.Item]()))
+	share := func(s models.Share) int {
+		body, _ := json.Marshal(gin.H{"data": encryptForTest(t, s)})
+		return serve(router, "POST", "/add-share", bytes.NewBuffer(body)).Code
+	}
+	valid := models.Share{
+		RecipientID:  "bob",
+		ItemType:     "login-password",
+		ItemID:       1,
+		Data:         []byte("sealed"),
+		OwnerKey:     []byte("owner key"),
+		RecipientKey: []byte("recipient key"),
+	}
+
+	assert.Equal(t, http.StatusCreated, share(valid))
+	require.Len(t, shares.saved, 1)
+	assert.Equal(t, "alice", shares.saved[0].OwnerID)
+	assert.Equal(t, models.PermissionRead, shares.saved[0].Permission)
+
+	for name, tc := range map[string]struct {
+		change func(s *models.Share)
+		want   int
+	}{
+		"invalid permission": {func(s *models.Share) { s.Permission = "admin" }, http.StatusUnprocessableEntity},
+		"self":               {func(s *models.Share) { s.RecipientID = "alice" }, http.StatusUnprocessableEntity},
+		"no keys":            {func(s *models.Share) { s.RecipientKey = nil }, http.StatusUnprocessableEntity},
+		"unknown recipient":  {func(s *models.Share) { s.RecipientID = "nobody" }, http.StatusNotFound},
+		"no public key":      {func(s *models.Share) { s.RecipientID = "carol" }, http.StatusUnprocessableEntity},
+		"unknown type":       {func(s *models.Share) { s.ItemType = "vault" }, http.StatusUnprocessableEntity},
+		"missing item":       {func(s *models.Share) { s.ItemType = "card" }, http.StatusNotFound},
+	} {
+		s := valid
+		tc.change(&s)
+		assert.Equal(t, tc.want, share(s), name)
+	}
+	assert.Len(t, shares.saved, 1)
+
+	w := serve(router, "GET", "/get-public-key/bob", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var key struct {
+		PublicKey []byte `json:"public_key"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &key))
+	assert.Equal(t, []byte("bob key"), key.PublicKey)
+
+	w = serve(router, "GET", "/get-share", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed []*models.Share
+	decryptedBody(t, w, &listed)
+	require.Len(t, listed, 1)
+	assert.Equal(t, []byte("sealed"), listed[0].Data)
+
+	update, _ := json.Marshal(gin.H{"data": encryptForTest(t, models.Share{Data: []byte("edited")})})
+	assert.Equal(t, http.StatusOK, serve(router, "PUT", "/update-share/1", bytes.NewBuffer(update)).Code)
+	shares.updateErr = repository.ErrReadOnlyShare
+	assert.Equal(t, http.StatusForbidden, serve(router, "PUT", "/update-share/1", bytes.NewBuffer(update)).Code)
+
+	assert.Equal(t, http.StatusOK, serve(router, "DELETE", "/delete-share/1", nil).Code)
+	shares.revokeErr = gorm.ErrRecordNotFound
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/delete-share/1", nil).Code)
+	users.AssertExpectations(t)
+}
+
+func TestUserHandler_SetPublicKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := new(MockUserRepo)
+	publicKey := bytes.Repeat([]byte{9}, 32)
+	users.On("SetPublicKey", "alice", publicKey).Return(nil).Once()
+	router := gin.New()
+	router.PUT(
+		"/public-key", func(ctx *gin.Context) {
+			ctx.Set("userID", "alice")
+		}, NewUserHandler(users, nil).SetPublicKey(),
+	)
+
+	body, _ := json.Marshal(gin.H{"public_key": publicKey})
+	assert.Equal(t, http.StatusOK, serve(router, "PUT", "/public-key", bytes.NewBuffer(body)).Code)
+	body, _ = json.Marshal(gin.H{"public_key": []byte("short")})
+	assert.Equal(t, http.StatusBadRequest, serve(router, "PUT", "/public-key", bytes.NewBuffer(body)).Code)
+	users.AssertExpectations(t)
+}
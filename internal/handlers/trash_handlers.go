@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+)
+
+// DeleteItemHandler moves one item to the trash.
+func DeleteItemHandler[T any](spec ItemSpec[T]) gin.HandlerFunc {
+	return trashActionHandler(
+		func(ctx *gin.Context) (func(userID string, id uint) error, bool) {
+			return spec.Store.Delete, true
+		}, "Item has been moved to trash",
+	)
+}
+
+// trashType is a secret type whose deleted items are kept in the trash.
+type trashType struct {
+	route   string
+	trashed func(userID string) (interface{}, error)
+	restore func(userID string, id uint) error
+	purge   func(userID string, id uint) error
+	empty   func(userID string) (int64, error)
+}
+
+func trashable[T any](route string, store repository.Store[T]) trashType {
+	return trashType{
+		route: route,
+		trashed: func(userID string) (interface{}, error) {
+			return store.Trashed(userID)
+		},
+		restore: store.Restore,
+		purge:   store.Purge,
+		empty:   store.EmptyTrash,
+	}
+}
+
+// TrashHandler lists, restores and purges the trashed items of every type.
+// Types are named by their routes, e.g. "login-password" or "item".
+type TrashHandler struct {
+	types []trashType
+}
+
+func NewTrashHandler(s DataStores, items repository.Store[models.Item]) *TrashHandler {
+	return &TrashHandler{
+		types: []trashType{
+			trashable("card", s.CreditCards),
+			trashable("text-data", s.TextData),
+			trashable("binary-data", s.BinaryData),
+			trashable("login-password", s.LoginPasswords),
+			trashable("otp", s.OTPSecrets),
+			trashable("ssh-key", s.SSHKeys),
+			trashable("item", items),
+		},
+	}
+}
+
+// RegisterTrashRoutes adds GET get-trash, PUT restore-trash/:type/:id,
+// DELETE purge-trash/:type/:id and DELETE empty-trash to r.
+func RegisterTrashRoutes(r gin.IRoutes, th *TrashHandler) {
+	r.GET("/get-trash", th.GetTrashHandler())
+	r.PUT("/restore-trash/:type/:id", th.RestoreHandler())
+	r.DELETE("/purge-trash/:type/:id", th.PurgeHandler())
+	r.DELETE("/empty-trash", th.EmptyHandler())
+}
+
+// GetTrashHandler lists the trashed items grouped by type.
+func (th *TrashHandler) GetTrashHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		trash := make(map[string]interface{}, len(th.types))
+		for _, t := range th.types {
+			items, err := t.trashed(userID.(string))
+			if err != nil {
+				log.Printf("Error getting trashed %s: %v", t.route, err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			trash[t.route] = items
+		}
+		respondEncrypted(ctx, "Trash", trash)
+	}
+}
+
+// RestoreHandler moves one item out of the trash.
+func (th *TrashHandler) RestoreHandler() gin.HandlerFunc {
+	return trashActionHandler(
+		func(ctx *gin.Context) (func(userID string, id uint) error, bool) {
+			t, ok := th.typeParam(ctx)
+			return t.restore, ok
+		}, "Item has been restored",
+	)
+}
+
+// PurgeHandler permanently deletes one trashed item.
+func (th *TrashHandler) PurgeHandler() gin.HandlerFunc {
+	return trashActionHandler(
+		func(ctx *gin.Context) (func(userID string, id uint) error, bool) {
+			t, ok := th.typeParam(ctx)
+			return t.purge, ok
+		}, "Item has been permanently deleted",
+	)
+}
+
+// EmptyHandler permanently deletes every trashed item.
+func (th *TrashHandler) EmptyHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var purged int64
+		for _, t := range th.types {
+			n, err := t.empty(userID.(string))
+			if err != nil {
+				log.Printf("Error emptying %s trash: %v", t.route, err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			purged += n
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": "Trash has been emptied",
+				"purged":  purged,
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
+
+func (th *TrashHandler) typeParam(ctx *gin.Context) (trashType, bool) {
+	route := ctx.Param("type")
+	for _, t := range th.types {
+		if t.route == route {
+			return t, true
+		}
+	}
+	ctx.JSON(
+		http.StatusUnprocessableEntity,
+		gin.H{"error": fmt.Sprintf("Unsupported item type %q", route)},
+	)
+	return trashType{}, false
+}
+
+// trashActionHandler applies pick's action to the item in :id.
+func trashActionHandler(
+	pick func(ctx *gin.Context) (func(userID string, id uint) error, bool),
+	message string,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, exists := ctx.Get("userID")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		action, ok := pick(ctx)
+		if !ok {
+			return
+		}
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		if err := action(userID.(string), id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+				return
+			}
+			log.Printf("Error handling trashed item: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.IndentedJSON(
+			http.StatusOK, gin.H{
+				"message": message,
+				"status":  http.StatusOK,
+			},
+		)
+	}
+}
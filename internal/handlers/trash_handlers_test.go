@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestTrashHandler(t *testing.T) {
+	stores, logins := searchStores()
+	items := newMockStore(&models.Item{UserID: "test_user", Name: "Licence"})
+	router := setupItemRouter(NewItemHandler(newMockStore[models.Item](), &MockItemTemplateRepo{}))
+	data := router.Group("/api/data")
+	RegisterDataRoutes(data, stores)
+	RegisterTrashRoutes(data, NewTrashHandler(stores, items))
+
+	w := serve(router, "DELETE", "/api/data/delete-login-password/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uint{1}, logins.state.deleted)
+	w = serve(router, "DELETE", "/api/data/delete-login-password/7", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, "GET", "/api/data/get-trash", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var trash struct {
+		LoginPasswords []*models.LoginPassword `json:"login-password"`
+		Items          []*models.Item          `json:"item"`
+	}
+	decryptedBody(t, w, &trash)
+	if assert.Len(t, trash.LoginPasswords, 1) {
+		assert.Equal(t, "octocat", trash.LoginPasswords[0].Login)
+	}
+	assert.Empty(t, trash.Items)
+
+	w = serve(router, "PUT", "/api/data/restore-trash/login-password/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uint{1}, logins.state.restored)
+	w = serve(router, "PUT", "/api/data/restore-trash/item/1", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, "PUT", "/api/data/restore-trash/folder/1", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = serve(router, "PUT", "/api/data/restore-trash/login-password/x", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "DELETE", "/api/data/purge-trash/login-password/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []uint{1}, logins.state.purged)
+
+	w = serve(router, "DELETE", "/api/data/empty-trash", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"purged": 1`)
+}
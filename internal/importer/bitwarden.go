@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+)
+
+// Bitwarden item types.
+const (
+	bitwardenLogin    = 1
+	bitwardenNote     = 2
+	bitwardenCard     = 3
+	bitwardenIdentity = 4
+)
+
+var errBitwardenEncrypted = errors.New("encrypted Bitwarden exports cannot be read, export as unencrypted JSON")
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	FolderID string `json:"folderId"`
+	Favorite bool   `json:"favorite"`
+	Fields   []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"fields"`
+	Login *struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	} `json:"login"`
+	Card *struct {
+		CardholderName string `json:"cardholderName"`
+		Brand          string `json:"brand"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+	Identity map[string]interface{} `json:"identity"`
+}
+
+// parseBitwarden reads an unencrypted Bitwarden JSON export.
+func parseBitwarden(data []byte) (*Vault, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to read Bitwarden export: %w", err)
+	}
+	if export.Encrypted {
+		return nil, errBitwardenEncrypted
+	}
+	folders := make(map[string]string, len(export.Folders))
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+
+	v := newVault()
+	for _, item := range export.Items {
+		folder := folders[item.FolderID]
+		meta := models.ItemMeta{DisplayName: item.Name, Favorite: item.Favorite}
+		lines := []string{item.Notes}
+		for _, f := range item.Fields {
+			lines = append(lines, field(f.Name, f.Value))
+		}
+
+		switch {
+		case item.Type == bitwardenLogin && item.Login != nil:
+			lp := &models.LoginPassword{
+				ItemMeta: meta,
+				Login:    item.Login.Username,
+				Password: item.Login.Password,
+			}
+			for i, uri := range item.Login.URIs {
+				if i == 0 {
+					lp.URL = uri.URI
+				} else {
+					lines = append(lines, field("URL", uri.URI))
+				}
+			}
+			lp.Metadata = joinLines(append(lines, field("TOTP", item.Login.TOTP))...)
+			v.addLogin(lp, folder)
+		case item.Type == bitwardenCard && item.Card != nil:
+			v.addCard(
+				&models.CreditCard{
+					ItemMeta:   meta,
+					CardNumber: item.Card.Number,
+					ExpiryDate: item.Card.ExpMonth + "/" + item.Card.ExpYear,
+					CVV:        item.Card.Code,
+					CardHolder: item.Card.CardholderName,
+					Brand:      item.Card.Brand,
+					Metadata:   joinLines(lines...),
+				}, folder,
+			)
+		case item.Type == bitwardenIdentity:
+			identity := make([]string, 0, len(item.Identity))
+			for _, key := range sortedKeys(item.Identity) {
+				if value, ok := item.Identity[key].(string); ok {
+					identity = append(identity, field(key, value))
+				}
+			}
+			v.addText(
+				&models.TextData{
+					ItemMeta: meta,
+					Content:  joinLines(append(identity, lines...)...),
+				}, folder,
+			)
+		case item.Type == bitwardenNote:
+			v.addText(&models.TextData{ItemMeta: meta, Content: joinLines(lines...)}, folder)
+		default:
+			v.warn("%s skipped: unknown Bitwarden item type %d", item.Name, item.Type)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,66 @@
+package importer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const bitwardenJSON = `{
+  "encrypted": false,
+  "folders": [{"id": "f1", "name": "Work/Servers"}],
+  "items": [
+    {
+      "type": 1, "name": "GitHub", "notes": "main account", "folderId": "f1", "favorite": true,
+      "fields": [{"name": "Recovery", "value": "abcd-efgh", "type": 1}],
+      "login": {
+        "uris": [{"match": null, "uri": "https://github.com"}, {"uri": "https://gist.github.com"}],
+        "username": "octocat", "password": "p4ss", "totp": "otpauth://totp/GitHub?secret=JBSWY3DP"
+      }
+    },
+    {
+      "type": 3, "name": "Visa", "folderId": null,
+      "card": {"cardholderName": "Jane Doe", "brand": "Visa", "number": "4111111111111111",
+        "expMonth": "5", "expYear": "2099", "code": "123"}
+    },
+    {"type": 2, "name": "Wi-Fi", "notes": "hunter2", "secureNote": {"type": 0}},
+    {"type": 4, "name": "Me", "identity": {"firstName": "Jane", "lastName": "Doe", "ssn": null}}
+  ]
+}`
+
+func TestParseBitwarden(t *testing.T) {
+	v, err := Parse(FormatBitwarden, []byte(bitwardenJSON))
+	require.NoError(t, err)
+
+	require.Len(t, v.LoginPasswords, 1)
+	lp := v.LoginPasswords[0]
+	assert.Equal(t, "GitHub", lp.DisplayName)
+	assert.True(t, lp.Favorite)
+	assert.Equal(t, "octocat", lp.Login)
+	assert.Equal(t, "p4ss", lp.Password)
+	assert.Equal(t, "https://github.com", lp.URL)
+	assert.Equal(
+		t,
+		"main account\nRecovery: abcd-efgh\nURL: https://gist.github.com\nTOTP: otpauth://totp/GitHub?secret=JBSWY3DP",
+		lp.Metadata,
+	)
+	assert.Equal(t, "Work/Servers", v.Folders[&lp.ItemMeta])
+
+	require.Len(t, v.CreditCards, 1)
+	cc := v.CreditCards[0]
+	assert.Equal(t, "05/99", cc.ExpiryDate)
+	assert.Equal(t, "JANE DOE", cc.CardHolder)
+	assert.Empty(t, v.Folders[&cc.ItemMeta])
+
+	require.Len(t, v.TextData, 2)
+	assert.Equal(t, "hunter2", v.TextData[0].Content)
+	assert.Equal(t, "firstName: Jane\nlastName: Doe", v.TextData[1].Content)
+	assert.Empty(t, v.Warnings)
+}
+
+func TestParseBitwarden_Encrypted(t *testing.T) {
+	_, err := Parse(FormatBitwarden, []byte(`{"encrypted": true, "items": []}`))
+	assert.ErrorIs(t, err, errBitwardenEncrypted)
+	_, err = Parse(FormatBitwarden, []byte(`not json`))
+	assert.Error(t, err)
+}
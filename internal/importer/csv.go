@@ -0,0 +1,83 @@
+package importer
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// lastPassNoteURL is the URL of LastPass secure notes.
+const lastPassNoteURL = "http://sn"
+
+// lastPassRow reads a row of a LastPass CSV export.
+func lastPassRow(v *Vault, row csvRow) {
+	folder := strings.ReplaceAll(row.get("grouping"), `\`, "/")
+	meta := models.ItemMeta{DisplayName: row.get("name"), Favorite: row.get("fav") == "1"}
+	extra := row.get("extra")
+	if row.get("url") != lastPassNoteURL {
+		v.addEntry(
+			meta.DisplayName, row.get("username"), row.get("password"), row.get("url"),
+			joinLines(extra, field("TOTP", row.get("totp"))), meta, folder,
+		)
+		return
+	}
+
+	fields := lastPassNoteFields(extra)
+	if fields["NoteType"] != "Credit Card" {
+		v.addText(&models.TextData{ItemMeta: meta, Content: extra}, folder)
+		return
+	}
+	v.addCard(
+		&models.CreditCard{
+			ItemMeta:   meta,
+			CardNumber: fields["Number"],
+			ExpiryDate: lastPassExpiry(fields["Expiration Date"]),
+			CVV:        fields["Security Code"],
+			CardHolder: fields["Name on Card"],
+			Brand:      fields["Type"],
+			Metadata:   fields["Notes"],
+		}, folder,
+	)
+}
+
+// lastPassNoteFields reads the "Name:value" lines of a secure note.
+func lastPassNoteFields(extra string) map[string]string {
+	fields := make(map[string]string)
+	lines := strings.Split(extra, "\n")
+	for i, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if name == "Notes" {
+			fields[name] = strings.TrimSpace(strings.Join(append([]string{value}, lines[i+1:]...), "\n"))
+			break
+		}
+		fields[name] = strings.TrimSpace(value)
+	}
+	return fields
+}
+
+// lastPassExpiry turns "January,2027" into 01/2027.
+func lastPassExpiry(expiry string) string {
+	t, err := time.Parse("January,2006", expiry)
+	if err != nil {
+		return expiry
+	}
+	return t.Format("01/2006")
+}
+
+// browserRow reads a row of a Chrome or Firefox password export.
+func browserRow(v *Vault, row csvRow) {
+	name := row.get("name")
+	if name == "" {
+		if u, err := url.Parse(row.get("url")); err == nil {
+			name = u.Hostname()
+		}
+	}
+	v.addEntry(
+		name, row.get("username"), row.get("password"), row.get("url"), row.get("note"),
+		models.ItemMeta{}, "",
+	)
+}
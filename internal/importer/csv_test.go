@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseLastPass(t *testing.T) {
+	data := "url,username,password,totp,extra,name,grouping,fav\n" +
+		"https://shop.example.com,jane,pw,,likes cats,Shop,Personal\\Shopping,1\n" +
+		"http://sn,,,,\"NoteType:Credit Card\nLanguage:en-US\nName on Card:Jane Doe\nType:Visa\n" +
+		"Number:4111111111111111\nSecurity Code:123\nStart Date:,\nExpiration Date:June,2099\n" +
+		"Notes:first line\nsecond line\",Visa,Cards,0\n" +
+		"http://sn,,,,door code 1234,Door,,0\n"
+	v, err := Parse(FormatLastPass, []byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, v.LoginPasswords, 1)
+	lp := v.LoginPasswords[0]
+	assert.Equal(t, "Shop", lp.DisplayName)
+	assert.Equal(t, "likes cats", lp.Metadata)
+	assert.True(t, lp.Favorite)
+	assert.Equal(t, "Personal/Shopping", v.Folders[&lp.ItemMeta])
+
+	require.Len(t, v.CreditCards, 1)
+	cc := v.CreditCards[0]
+	assert.Equal(t, "4111111111111111", cc.CardNumber)
+	assert.Equal(t, "06/99", cc.ExpiryDate)
+	assert.Equal(t, "123", cc.CVV)
+	assert.Equal(t, "first line\nsecond line", cc.Metadata)
+	assert.Equal(t, "Cards", v.Folders[&cc.ItemMeta])
+
+	require.Len(t, v.TextData, 1)
+	assert.Equal(t, "door code 1234", v.TextData[0].Content)
+}
+
+func TestParseBrowsers(t *testing.T) {
+	chrome := "name,url,username,password,note\n" +
+		"example.com,https://example.com/login,jane,pw,\n"
+	v, err := Parse(FormatChrome, []byte(chrome))
+	require.NoError(t, err)
+	require.Len(t, v.LoginPasswords, 1)
+	assert.Equal(t, "example.com", v.LoginPasswords[0].DisplayName)
+	assert.Equal(t, "https://example.com/login", v.LoginPasswords[0].URL)
+
+	firefox := "\xef\xbb\xbf\"url\",\"username\",\"password\",\"httpRealm\",\"formActionOrigin\",\"guid\"," +
+		"\"timeCreated\",\"timeLastUsed\",\"timePasswordChanged\"\n" +
+		"\"https://accounts.example.org\",\"bob\",\"pw2\",,\"https://accounts.example.org\",\"{1}\",\"1\",\"1\",\"1\"\n"
+	v, err = Parse(FormatFirefox, []byte(firefox))
+	require.NoError(t, err)
+	require.Len(t, v.LoginPasswords, 1)
+	assert.Equal(t, "accounts.example.org", v.LoginPasswords[0].DisplayName)
+	assert.Equal(t, "bob", v.LoginPasswords[0].Login)
+}
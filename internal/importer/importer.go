@@ -0,0 +1,424 @@
+// Package importer reads the exports of other password managers into the
+// vault models, to be uploaded by the client.
+package importer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/elina-chertova/auth-keeper.git/internal/validation"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatBitwarden    = "bitwarden"
+	Format1PUX         = "1pux"
+	Format1PasswordCSV = "1password-csv"
+	FormatKeePassXML   = "keepass-xml"
+	FormatKeePassCSV   = "keepass-csv"
+	FormatKDBX         = "kdbx"
+	FormatLastPass     = "lastpass"
+	FormatChrome       = "chrome"
+	FormatFirefox      = "firefox"
+)
+
+// Formats lists the formats Parse reads.
+var Formats = []string{
+	FormatBitwarden, Format1PUX, Format1PasswordCSV, FormatKeePassXML,
+	FormatKeePassCSV, FormatKDBX, FormatLastPass, FormatChrome, FormatFirefox,
+}
+
+var (
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrNeedsPassword = errors.New("a KeePass database is read with its password by ParseKDBX")
+)
+
+// Vault is what an export holds. Entries that are neither logins nor cards
+// become text data; cards that fail validation too, with a warning. The
+// server only knows folders by ID, so the folder of an item is kept by its
+// ItemMeta in Folders as a path of names separated by "/".
+type Vault struct {
+	LoginPasswords []*models.LoginPassword
+	CreditCards    []*models.CreditCard
+	TextData       []*models.TextData
+	// Attachments hold their file in Content.
+	Attachments []*models.BinaryData
+	Folders     map[*models.ItemMeta]string
+	Warnings    []string
+}
+
+func newVault() *Vault {
+	return &Vault{Folders: make(map[*models.ItemMeta]string)}
+}
+
+// Len is the number of items in v.
+func (v *Vault) Len() int {
+	return len(v.LoginPasswords) + len(v.CreditCards) + len(v.TextData) + len(v.Attachments)
+}
+
+// FolderPaths lists the folder paths used by the items of v.
+func (v *Vault) FolderPaths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, path := range v.Folders {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func (v *Vault) warn(format string, args ...interface{}) {
+	v.Warnings = append(v.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (v *Vault) setFolder(meta *models.ItemMeta, folder string) {
+	if folder = cleanFolder(folder); folder != "" {
+		v.Folders[meta] = folder
+	}
+}
+
+func (v *Vault) addLogin(lp *models.LoginPassword, folder string) {
+	v.LoginPasswords = append(v.LoginPasswords, lp)
+	v.setFolder(&lp.ItemMeta, folder)
+}
+
+func (v *Vault) addText(td *models.TextData, folder string) {
+	v.TextData = append(v.TextData, td)
+	v.setFolder(&td.ItemMeta, folder)
+}
+
+// addCard adds a valid card, or a text note with its fields.
+func (v *Vault) addCard(cc *models.CreditCard, folder string) {
+	exported := *cc
+	validation.NormalizeCreditCard(cc)
+	if err := validation.ValidateCreditCard(cc); err != nil {
+		name := cc.DisplayName
+		if name == "" {
+			name = "card"
+		}
+		v.warn("%s imported as text data: %v", name, err)
+		v.addText(
+			&models.TextData{
+				ItemMeta: cc.ItemMeta,
+				Content: joinLines(
+					field("Card holder", exported.CardHolder),
+					field("Number", exported.CardNumber),
+					field("Expiry date", exported.ExpiryDate),
+					field("CVV", exported.CVV),
+					field("Brand", exported.Brand),
+				),
+				Metadata: cc.Metadata,
+			}, folder,
+		)
+		return
+	}
+	v.CreditCards = append(v.CreditCards, cc)
+	v.setFolder(&cc.ItemMeta, folder)
+}
+
+// addAttachment adds a file of the item named owner.
+func (v *Vault) addAttachment(name string, content []byte, owner string, folder string) {
+	bd := &models.BinaryData{
+		ItemMeta: models.ItemMeta{DisplayName: name},
+		Content:  content,
+		FileName: name,
+		MIMEType: http.DetectContentType(content),
+		Size:     int64(len(content)),
+		SHA256:   sha256Hex(content),
+	}
+	if owner != "" {
+		bd.Metadata = "Attachment of " + owner
+	}
+	v.Attachments = append(v.Attachments, bd)
+	v.setFolder(&bd.ItemMeta, folder)
+}
+
+// Parse reads an export in format. KDBX databases are encrypted and read
+// by ParseKDBX.
+func Parse(format string, data []byte) (*Vault, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	switch format {
+	case FormatBitwarden:
+		return parseBitwarden(data)
+	case Format1PUX:
+		return parse1PUX(data)
+	case Format1PasswordCSV:
+		return parseCSV(data, onePasswordRow)
+	case FormatKeePassXML:
+		return parseKeePassXML(data)
+	case FormatKeePassCSV:
+		return parseCSV(data, keePassRow)
+	case FormatKDBX:
+		return nil, ErrNeedsPassword
+	case FormatLastPass:
+		return parseCSV(data, lastPassRow)
+	case FormatChrome, FormatFirefox:
+		return parseCSV(data, browserRow)
+	}
+	return nil, fmt.Errorf("%w %q, use one of %s", ErrUnknownFormat, format, strings.Join(Formats, ", "))
+}
+
+// Detect guesses the format of an export from its file name and contents.
+func Detect(name string, data []byte) (string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".1pux":
+		return Format1PUX, nil
+	case ".json":
+		return FormatBitwarden, nil
+	case ".xml":
+		return FormatKeePassXML, nil
+	case ".kdbx":
+		return FormatKDBX, nil
+	case ".csv":
+		columns, _, err := readCSV(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+		if err != nil {
+			return "", err
+		}
+		header := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			header[column] = true
+		}
+		switch {
+		case header["httprealm"] || header["formactionorigin"]:
+			return FormatFirefox, nil
+		case header["grouping"] && header["extra"]:
+			return FormatLastPass, nil
+		case header["group"] || header["login name"]:
+			return FormatKeePassCSV, nil
+		case header["title"]:
+			return Format1PasswordCSV, nil
+		case header["name"] && header["url"]:
+			return FormatChrome, nil
+		}
+	}
+	return "", fmt.Errorf("cannot tell the format of %s, pass --format", name)
+}
+
+// Dedupe drops the items of v that are in existing or earlier in v and
+// returns how many it dropped. Logins are equal by URL, username and
+// password, cards by number, text data by content and attachments by
+// SHA-256. Existing passwords may be bcrypt hashes, as the server stores
+// them.
+func (v *Vault) Dedupe(existing *Vault) int {
+	seen := make(map[string]bool)
+	if existing != nil {
+		hashes := make(map[string][]string)
+		for _, lp := range existing.LoginPasswords {
+			if security.IsPasswordHash(lp.Password) {
+				hashes[accountKey(lp)] = append(hashes[accountKey(lp)], lp.Password)
+				continue
+			}
+			seen[loginKey(lp)] = true
+		}
+		for _, lp := range v.LoginPasswords {
+			for _, hash := range hashes[accountKey(lp)] {
+				if security.CheckPasswordHash(lp.Password, hash) {
+					seen[loginKey(lp)] = true
+				}
+			}
+		}
+		for _, cc := range existing.CreditCards {
+			seen[cardKey(cc)] = true
+		}
+		for _, td := range existing.TextData {
+			seen[textKey(td)] = true
+		}
+		for _, bd := range existing.Attachments {
+			seen[binaryKey(bd)] = true
+		}
+	}
+	before := v.Len()
+	v.LoginPasswords = unique(v, seen, v.LoginPasswords, loginKey)
+	v.CreditCards = unique(v, seen, v.CreditCards, cardKey)
+	v.TextData = unique(v, seen, v.TextData, textKey)
+	v.Attachments = unique(v, seen, v.Attachments, binaryKey)
+	return before - v.Len()
+}
+
+func unique[T any, PT interface {
+	*T
+	Meta() *models.ItemMeta
+}](v *Vault, seen map[string]bool, items []*T, key func(*T) string) []*T {
+	kept := items[:0]
+	for _, item := range items {
+		k := key(item)
+		if seen[k] {
+			delete(v.Folders, PT(item).Meta())
+			continue
+		}
+		seen[k] = true
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+func loginKey(lp *models.LoginPassword) string {
+	return accountKey(lp) + "\x00" + lp.Password
+}
+
+func accountKey(lp *models.LoginPassword) string {
+	u := strings.ToLower(strings.TrimSpace(lp.URL))
+	u = strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+	return "login\x00" + strings.TrimSuffix(u, "/") + "\x00" + lp.Login
+}
+
+func cardKey(cc *models.CreditCard) string {
+	return "card\x00" + validation.DigitsOnly(cc.CardNumber)
+}
+
+func textKey(td *models.TextData) string {
+	return "text\x00" + strings.TrimSpace(td.Content)
+}
+
+func binaryKey(bd *models.BinaryData) string {
+	sum := bd.SHA256
+	if sum == "" {
+		sum = sha256Hex(bd.Content)
+	}
+	return "binary\x00" + sum
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cleanFolder joins the non-empty names of a folder path with "/".
+func cleanFolder(path string) string {
+	var names []string
+	for _, name := range strings.Split(path, "/") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+// field is "name: value", or empty without a value.
+func field(name, value string) string {
+	if strings.TrimSpace(value) == "" {
+		return ""
+	}
+	return name + ": " + value
+}
+
+// joinLines joins the non-empty lines.
+func joinLines(lines ...string) string {
+	var kept []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// splitTags splits a list of tags separated by commas or semicolons.
+func splitTags(tags string) []string {
+	var split []string
+	for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			split = append(split, tag)
+		}
+	}
+	return split
+}
+
+// csvRow is a CSV record by lower-case column name.
+type csvRow map[string]string
+
+// get returns the first non-empty of the columns.
+func (r csvRow) get(columns ...string) string {
+	for _, column := range columns {
+		if value := strings.TrimSpace(r[column]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// readCSV returns the lower-case column names of a CSV file and its rows.
+func readCSV(data []byte) ([]string, []csvRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("CSV file is empty")
+	}
+	header := make([]string, len(records[0]))
+	for i, column := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+	}
+	rows := make([]csvRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(csvRow, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+// parseCSV reads a CSV export whose rows are read by add.
+func parseCSV(data []byte, add func(v *Vault, row csvRow)) (*Vault, error) {
+	_, rows, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+	v := newVault()
+	for _, row := range rows {
+		add(v, row)
+	}
+	return v, nil
+}
+
+// addEntry adds a login, or text data if it has no credentials.
+func (v *Vault) addEntry(
+	title, username, password, url, notes string,
+	meta models.ItemMeta,
+	folder string,
+) {
+	meta.DisplayName = title
+	if username == "" && password == "" && url == "" {
+		if notes == "" {
+			return
+		}
+		v.addText(&models.TextData{ItemMeta: meta, Content: notes}, folder)
+		return
+	}
+	v.addLogin(
+		&models.LoginPassword{
+			ItemMeta: meta,
+			Login:    username,
+			Password: password,
+			URL:      url,
+			Metadata: notes,
+		}, folder,
+	)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
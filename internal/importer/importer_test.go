@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse_UnknownFormat(t *testing.T) {
+	_, err := Parse("dashlane", nil)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+	_, err = Parse(FormatKDBX, nil)
+	assert.ErrorIs(t, err, ErrNeedsPassword)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "export.1pux", want: Format1PUX},
+		{name: "bitwarden_export.json", want: FormatBitwarden},
+		{name: "Database.xml", want: FormatKeePassXML},
+		{name: "Passwords.kdbx", want: FormatKDBX},
+		{name: "logins.csv", data: "url,username,password,httpRealm,formActionOrigin\n", want: FormatFirefox},
+		{name: "lastpass.csv", data: "url,username,password,totp,extra,name,grouping,fav\n", want: FormatLastPass},
+		{name: "kp.csv", data: `"Group","Title","Username","Password"` + "\n", want: FormatKeePassCSV},
+		{name: "kp2.csv", data: `"Account","Login Name","Password"` + "\n", want: FormatKeePassCSV},
+		{name: "1p.CSV", data: "\xef\xbb\xbfTitle,Url,Username,Password\n", want: Format1PasswordCSV},
+		{name: "Chrome Passwords.csv", data: "name,url,username,password\n", want: FormatChrome},
+	}
+	for _, tt := range tests {
+		got, err := Detect(tt.name, []byte(tt.data))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+
+	_, err := Detect("vault.csv", []byte("a,b,c\n"))
+	assert.Error(t, err)
+	_, err = Detect("vault.txt", nil)
+	assert.Error(t, err)
+}
+
+func TestVault_AddCard_Invalid(t *testing.T) {
+	v := newVault()
+	v.addCard(
+		&models.CreditCard{
+			ItemMeta:   models.ItemMeta{DisplayName: "Old card"},
+			CardNumber: "1234",
+			CardHolder: "Jane",
+		}, "Cards",
+	)
+	assert.Empty(t, v.CreditCards)
+	require.Len(t, v.TextData, 1)
+	assert.Equal(t, "Card holder: Jane\nNumber: 1234", v.TextData[0].Content)
+	assert.Equal(t, "Cards", v.Folders[&v.TextData[0].ItemMeta])
+	require.Len(t, v.Warnings, 1)
+	assert.Contains(t, v.Warnings[0], "Old card")
+}
+
+func TestVault_Dedupe(t *testing.T) {
+	v := newVault()
+	v.addLogin(&models.LoginPassword{Login: "jane", Password: "pw", URL: "https://Example.com/"}, "Work")
+	v.addLogin(&models.LoginPassword{Login: "jane", Password: "pw", URL: "http://example.com"}, "")
+	v.addLogin(&models.LoginPassword{Login: "jane", Password: "other", URL: "https://example.com"}, "")
+	v.addText(&models.TextData{Content: "note "}, "")
+	v.addText(&models.TextData{Content: "new note"}, "")
+	v.addAttachment("a.txt", []byte("a"), "", "")
+	v.addAttachment("b.txt", []byte("b"), "", "")
+	v.addCard(
+		&models.CreditCard{
+			CardNumber: "4111 1111 1111 1111",
+			ExpiryDate: "01/99",
+			CVV:        "123",
+			CardHolder: "Jane",
+		}, "",
+	)
+
+	hash, err := security.HashPassword("pw")
+	require.NoError(t, err)
+	existing := &Vault{
+		LoginPasswords: []*models.LoginPassword{{Login: "jane", Password: hash, URL: "example.com"}},
+		CreditCards:    []*models.CreditCard{{CardNumber: "4111111111111111"}},
+		TextData:       []*models.TextData{{Content: "note"}},
+		Attachments:    []*models.BinaryData{{SHA256: sha256Hex([]byte("a"))}},
+	}
+	assert.Equal(t, 5, v.Dedupe(existing))
+	require.Len(t, v.LoginPasswords, 1)
+	assert.Equal(t, "other", v.LoginPasswords[0].Password)
+	require.Len(t, v.TextData, 1)
+	assert.Equal(t, "new note", v.TextData[0].Content)
+	require.Len(t, v.Attachments, 1)
+	assert.Equal(t, "b.txt", v.Attachments[0].FileName)
+	assert.Empty(t, v.CreditCards)
+	assert.Empty(t, v.FolderPaths())
+
+	assert.Zero(t, v.Dedupe(nil))
+}
@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/keepass"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// keePassFields are the standard strings of an entry.
+var keePassFields = map[string]bool{
+	keepass.FieldTitle: true, keepass.FieldUserName: true, keepass.FieldPassword: true, keepass.FieldURL: true,
+	keepass.FieldNotes: true,
+}
+
+// keePassCardFields are the strings of a card entry read into its fields.
+var keePassCardFields = map[string]bool{
+	keepass.FieldCardNumber: true, keepass.FieldExpiryDate: true, keepass.FieldCVV: true,
+	keepass.FieldCardHolder: true, keepass.FieldBrand: true,
+}
+
+// parseKeePassXML reads a KeePass 2 XML export.
+func parseKeePassXML(data []byte) (*Vault, error) {
+	var file keepass.File
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to read KeePass XML: %w", err)
+	}
+	pool := make(map[string][]byte)
+	if file.Meta.Binaries != nil {
+		for _, b := range file.Meta.Binaries.Binaries {
+			content, err := keePassContent(b.Value, bool(b.Compressed))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read KeePass binary %s: %w", b.ID, err)
+			}
+			pool[b.ID] = content
+		}
+	}
+	return readKeePass(&file, pool)
+}
+
+// ParseKDBX reads a KeePass KDBX 4 database encrypted with password, whose
+// attachments are in the binary pool of its inner header.
+func ParseKDBX(data []byte, password string) (*Vault, error) {
+	db, err := keepass.Read(bytes.NewReader(data), password)
+	if err != nil {
+		return nil, err
+	}
+	pool := make(map[string][]byte, len(db.Binaries))
+	for i, content := range db.Binaries {
+		pool[strconv.Itoa(i)] = content
+	}
+	return readKeePass(db.File, pool)
+}
+
+// keePassContent decodes base64 content, gzipped if compressed.
+func keePassContent(value string, compressed bool) ([]byte, error) {
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || !compressed {
+		return content, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// readKeePass reads the entries and attachments of a KeePass database.
+func readKeePass(file *keepass.File, pool map[string][]byte) (*Vault, error) {
+	v := newVault()
+	var walk func(group keepass.Group, path string) error
+	walk = func(group keepass.Group, path string) error {
+		if group.UUID != "" && group.UUID == file.Meta.RecycleBinUUID {
+			return nil
+		}
+		for _, entry := range group.Entries {
+			if err := v.addKeePassEntry(entry, path, pool); err != nil {
+				return err
+			}
+		}
+		for _, sub := range group.Groups {
+			if err := walk(sub, path+"/"+sub.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range file.Root.Groups {
+		if err := walk(root, ""); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// addKeePassEntry adds an entry as a card, file, login or text data.
+func (v *Vault) addKeePassEntry(entry keepass.Entry, folder string, pool map[string][]byte) error {
+	values := make(map[string]string, len(entry.Strings))
+	for _, s := range entry.Strings {
+		values[s.Key] = s.Value.Content
+	}
+	card := values[keepass.FieldCardNumber] != ""
+	lines := []string{values[keepass.FieldNotes]}
+	for _, s := range entry.Strings {
+		if !keePassFields[s.Key] && !(card && keePassCardFields[s.Key]) {
+			lines = append(lines, field(s.Key, s.Value.Content))
+		}
+	}
+	title, notes := values[keepass.FieldTitle], joinLines(lines...)
+	username, password, url := values[keepass.FieldUserName], values[keepass.FieldPassword], values[keepass.FieldURL]
+	meta := models.ItemMeta{DisplayName: title, Tags: splitTags(entry.Tags)}
+
+	if card {
+		v.addCard(
+			&models.CreditCard{
+				ItemMeta:   meta,
+				CardNumber: values[keepass.FieldCardNumber],
+				ExpiryDate: values[keepass.FieldExpiryDate],
+				CVV:        values[keepass.FieldCVV],
+				CardHolder: values[keepass.FieldCardHolder],
+				Brand:      values[keepass.FieldBrand],
+				Metadata:   notes,
+			}, folder,
+		)
+	} else if len(entry.Binaries) == 1 && username == "" && password == "" && url == "" {
+		content, ok, err := keePassAttachment(entry.Binaries[0], pool)
+		if err != nil {
+			return fmt.Errorf("failed to read attachment %s of %s: %w", entry.Binaries[0].Key, title, err)
+		}
+		if ok {
+			v.addAttachment(entry.Binaries[0].Key, content, "", folder)
+			bd := v.Attachments[len(v.Attachments)-1]
+			if title != "" {
+				bd.DisplayName = title
+			}
+			bd.Tags, bd.Metadata = meta.Tags, notes
+			return nil
+		}
+	}
+	if !card {
+		v.addEntry(title, username, password, url, notes, meta, folder)
+	}
+
+	for _, b := range entry.Binaries {
+		content, ok, err := keePassAttachment(b, pool)
+		if err != nil {
+			return fmt.Errorf("failed to read attachment %s of %s: %w", b.Key, title, err)
+		}
+		if !ok {
+			v.warn("attachment %s of %s skipped: binary %s not found", b.Key, title, b.Value.Ref)
+			continue
+		}
+		v.addAttachment(b.Key, content, title, folder)
+	}
+	return nil
+}
+
+// keePassAttachment returns the content of an attachment.
+func keePassAttachment(b keepass.BinaryRef, pool map[string][]byte) ([]byte, bool, error) {
+	if b.Value.Ref == "" {
+		content, err := keePassContent(b.Value.Data, false)
+		return content, err == nil, err
+	}
+	content, ok := pool[b.Value.Ref]
+	return content, ok, nil
+}
+
+// keePassRow reads a row of a KeePassXC or KeePass 2 CSV export.
+func keePassRow(v *Vault, row csvRow) {
+	folder := row.get("group")
+	if i := strings.Index(folder, "/"); i >= 0 {
+		folder = folder[i+1:]
+	} else {
+		folder = ""
+	}
+	v.addEntry(
+		row.get("title", "account"),
+		row.get("username", "login name"),
+		row.get("password"),
+		row.get("url", "web site"),
+		joinLines(row.get("notes", "comments"), field("TOTP", row.get("totp"))),
+		models.ItemMeta{},
+		folder,
+	)
+}
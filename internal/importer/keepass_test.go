@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func gzipBase64(t *testing.T, content string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestParseKeePassXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<RecycleBinUUID>YmluYmluYmluYmluYmluYg==</RecycleBinUUID>
+		<Binaries>
+			<Binary ID="0" Compressed="True">` + gzipBase64(t, "ssh-ed25519 AAAA") + `</Binary>
+		</Binaries>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdHJvb3Ryb290cm9vdA==</UUID>
+			<Name>Database</Name>
+			<Entry>
+				<Tags>home;router</Tags>
+				<String><Key>Title</Key><Value>Router</Value></String>
+				<String><Key>UserName</Key><Value>admin</Value></String>
+				<String><Key>Password</Key><Value ProtectInMemory="True">r0uter</Value></String>
+				<String><Key>URL</Key><Value>http://192.168.0.1</Value></String>
+				<String><Key>Notes</Key><Value>in the hall</Value></String>
+				<String><Key>Serial</Key><Value>X-1</Value></String>
+				<History><Entry><String><Key>Password</Key><Value>old</Value></String></Entry></History>
+			</Entry>
+			<Group>
+				<UUID>d29ya3dvcmt3b3Jrd29yaw==</UUID>
+				<Name>Work</Name>
+				<Group>
+					<Name>Servers</Name>
+					<Entry>
+						<String><Key>Title</Key><Value>Build box</Value></String>
+						<String><Key>UserName</Key><Value>ci</Value></String>
+						<Binary><Key>id.pub</Key><Value Ref="0"/></Binary>
+						<Binary><Key>inline.txt</Key><Value>` + base64.StdEncoding.EncodeToString([]byte("inline")) + `</Value></Binary>
+						<Binary><Key>lost.txt</Key><Value Ref="7"/></Binary>
+					</Entry>
+				</Group>
+			</Group>
+			<Group>
+				<UUID>YmluYmluYmluYmluYmluYg==</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry><String><Key>Title</Key><Value>Deleted</Value></String><String><Key>Password</Key><Value>x</Value></String></Entry>
+			</Group>
+			<Entry>
+				<String><Key>Title</Key><Value>Note</Value></String>
+				<String><Key>Notes</Key><Value>only notes</Value></String>
+			</Entry>
+		</Group>
+	</Root>
+</KeePassFile>`
+	v, err := Parse(FormatKeePassXML, []byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, v.LoginPasswords, 2)
+	router := v.LoginPasswords[0]
+	assert.Equal(t, "Router", router.DisplayName)
+	assert.Equal(t, "r0uter", router.Password)
+	assert.Equal(t, "in the hall\nSerial: X-1", router.Metadata)
+	assert.Equal(t, []string{"home", "router"}, router.Tags)
+	assert.Empty(t, v.Folders[&router.ItemMeta])
+	build := v.LoginPasswords[1]
+	assert.Equal(t, "Work/Servers", v.Folders[&build.ItemMeta])
+
+	require.Len(t, v.TextData, 1)
+	assert.Equal(t, "only notes", v.TextData[0].Content)
+
+	require.Len(t, v.Attachments, 2)
+	assert.Equal(t, "id.pub", v.Attachments[0].FileName)
+	assert.Equal(t, []byte("ssh-ed25519 AAAA"), v.Attachments[0].Content)
+	assert.Equal(t, "Attachment of Build box", v.Attachments[0].Metadata)
+	assert.Equal(t, "Work/Servers", v.Folders[&v.Attachments[0].ItemMeta])
+	assert.Equal(t, []byte("inline"), v.Attachments[1].Content)
+	require.Len(t, v.Warnings, 1)
+	assert.Contains(t, v.Warnings[0], "lost.txt")
+}
+
+func TestParseKeePassCSV(t *testing.T) {
+	for name, data := range map[string]string{
+		"KeePassXC": `"Group","Title","Username","Password","URL","Notes","TOTP","Icon","Last Modified","Created"
+"Root/Work","VPN","jane","pw","https://vpn.example.com","note","","0","",""
+"Root","Top","bob","pw2","","","","0","",""
+`,
+		"KeePass 2": `"Account","Login Name","Password","Web Site","Comments"
+"VPN","jane","pw","https://vpn.example.com","note"
+"Top","bob","pw2","",""
+`,
+	} {
+		v, err := Parse(FormatKeePassCSV, []byte(data))
+		require.NoError(t, err, name)
+		require.Len(t, v.LoginPasswords, 2, name)
+		vpn := v.LoginPasswords[0]
+		assert.Equal(t, "VPN", vpn.DisplayName, name)
+		assert.Equal(t, "jane", vpn.Login, name)
+		assert.Equal(t, "https://vpn.example.com", vpn.URL, name)
+		assert.Equal(t, "note", vpn.Metadata, name)
+		if strings.HasPrefix(data, `"Group"`) {
+			assert.Equal(t, "Work", v.Folders[&vpn.ItemMeta], name)
+		}
+		assert.Empty(t, v.Folders[&v.LoginPasswords[1].ItemMeta], name)
+	}
+}
@@ -0,0 +1,232 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 1Password item categories.
+const (
+	onePasswordLogin    = "001"
+	onePasswordCard     = "002"
+	onePasswordNote     = "003"
+	onePasswordPassword = "005"
+	onePasswordDocument = "006"
+)
+
+type onePasswordExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Attrs struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+			Items []onePasswordItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePasswordItem struct {
+	FavIndex     int    `json:"favIndex"`
+	State        string `json:"state"`
+	CategoryUUID string `json:"categoryUuid"`
+	Overview     struct {
+		Title string   `json:"title"`
+		URL   string   `json:"url"`
+		Tags  []string `json:"tags"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Name        string `json:"name"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Title  string `json:"title"`
+			Fields []struct {
+				Title string                 `json:"title"`
+				ID    string                 `json:"id"`
+				Value map[string]interface{} `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+		DocumentAttributes *struct {
+			FileName   string `json:"fileName"`
+			DocumentID string `json:"documentId"`
+		} `json:"documentAttributes"`
+	} `json:"details"`
+}
+
+// parse1PUX reads a 1Password 1PUX export.
+func parse1PUX(data []byte) (*Vault, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read 1PUX archive: %w", err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	exportFile, ok := files["export.data"]
+	if !ok {
+		return nil, fmt.Errorf("failed to read 1PUX archive: export.data not found")
+	}
+	exportData, err := readZipFile(exportFile)
+	if err != nil {
+		return nil, err
+	}
+	var export onePasswordExport
+	if err := json.Unmarshal(exportData, &export); err != nil {
+		return nil, fmt.Errorf("failed to read 1PUX export.data: %w", err)
+	}
+
+	v := newVault()
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				if err := v.add1PUXItem(item, vault.Attrs.Name, files); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+func (v *Vault) add1PUXItem(item onePasswordItem, folder string, files map[string]*zip.File) error {
+	if item.State != "" && item.State != "active" && item.State != "archived" {
+		return nil
+	}
+	meta := models.ItemMeta{
+		DisplayName: item.Overview.Title,
+		Favorite:    item.FavIndex > 0,
+		Tags:        item.Overview.Tags,
+	}
+	if item.State == "archived" {
+		meta.Tags = append(meta.Tags, "archived")
+	}
+	details := item.Details
+	values := make(map[string]string)
+	lines := []string{details.NotesPlain}
+	for _, section := range details.Sections {
+		for _, f := range section.Fields {
+			value := onePasswordValue(f.Value)
+			values[f.ID] = value
+			name := f.Title
+			if name == "" {
+				name = f.ID
+			}
+			lines = append(lines, field(name, value))
+		}
+	}
+
+	switch item.CategoryUUID {
+	case onePasswordLogin, onePasswordPassword:
+		lp := &models.LoginPassword{ItemMeta: meta, URL: item.Overview.URL, Password: details.Password}
+		for _, f := range details.LoginFields {
+			switch f.Designation {
+			case "username":
+				lp.Login = f.Value
+			case "password":
+				lp.Password = f.Value
+			}
+		}
+		lp.Metadata = joinLines(lines...)
+		v.addLogin(lp, folder)
+	case onePasswordCard:
+		v.addCard(
+			&models.CreditCard{
+				ItemMeta:   meta,
+				CardNumber: values["ccnum"],
+				ExpiryDate: onePasswordExpiry(values["expiry"]),
+				CVV:        values["cvv"],
+				CardHolder: values["cardholder"],
+				Brand:      values["type"],
+				Metadata:   joinLines(lines...),
+			}, folder,
+		)
+	case onePasswordDocument:
+		doc := details.DocumentAttributes
+		if doc == nil {
+			return nil
+		}
+		f, ok := files["files/"+doc.DocumentID+"__"+doc.FileName]
+		if !ok {
+			v.warn("%s skipped: file %s not found in the archive", item.Overview.Title, doc.FileName)
+			return nil
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return err
+		}
+		v.addAttachment(doc.FileName, content, "", folder)
+		if notes := joinLines(lines...); notes != "" {
+			v.Attachments[len(v.Attachments)-1].Metadata = notes
+		}
+	default:
+		if content := joinLines(lines...); content != "" {
+			v.addText(&models.TextData{ItemMeta: meta, Content: content}, folder)
+		}
+	}
+	return nil
+}
+
+// onePasswordValue is the text of a section field value.
+func onePasswordValue(value map[string]interface{}) string {
+	for _, kind := range sortedKeys(value) {
+		switch x := value[kind].(type) {
+		case string:
+			return x
+		case float64:
+			return strconv.FormatFloat(x, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// onePasswordExpiry turns a monthYear value, YYYYMM, into MM/YYYY.
+func onePasswordExpiry(monthYear string) string {
+	if len(monthYear) != 6 {
+		return monthYear
+	}
+	return monthYear[4:] + "/" + monthYear[:4]
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return data, nil
+}
+
+// onePasswordRow reads a row of a 1Password CSV export.
+func onePasswordRow(v *Vault, row csvRow) {
+	meta := models.ItemMeta{
+		Favorite: strings.EqualFold(row.get("favorite"), "true"),
+		Tags:     splitTags(row.get("tags")),
+	}
+	if strings.EqualFold(row.get("archived"), "true") {
+		meta.Tags = append(meta.Tags, "archived")
+	}
+	v.addEntry(
+		row.get("title"),
+		row.get("username"),
+		row.get("password"),
+		row.get("url", "website"),
+		joinLines(row.get("notes"), field("TOTP", row.get("otpauth"))),
+		meta,
+		"",
+	)
+}
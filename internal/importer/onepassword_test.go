@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const onePasswordData = `{
+  "accounts": [{
+    "attrs": {"accountName": "Jane"},
+    "vaults": [{
+      "attrs": {"name": "Personal"},
+      "items": [
+        {
+          "favIndex": 1, "state": "active", "categoryUuid": "001",
+          "overview": {"title": "Mail", "url": "https://mail.example.com", "tags": ["email"]},
+          "details": {
+            "loginFields": [
+              {"value": "jane", "name": "username", "designation": "username"},
+              {"value": "s3cret", "name": "password", "designation": "password"}
+            ],
+            "notesPlain": "work mail",
+            "sections": [{"title": "", "fields": [{"title": "PIN", "id": "pin", "value": {"concealed": "4321"}}]}]
+          }
+        },
+        {
+          "state": "archived", "categoryUuid": "002",
+          "overview": {"title": "Visa"},
+          "details": {"sections": [{"title": "", "fields": [
+            {"title": "cardholder name", "id": "cardholder", "value": {"string": "Jane Doe"}},
+            {"title": "number", "id": "ccnum", "value": {"creditCardNumber": "4111111111111111"}},
+            {"title": "verification number", "id": "cvv", "value": {"concealed": "123"}},
+            {"title": "expiry date", "id": "expiry", "value": {"monthYear": 209912}}
+          ]}]}
+        },
+        {"state": "active", "categoryUuid": "003", "overview": {"title": "Note"}, "details": {"notesPlain": "remember"}},
+        {
+          "state": "active", "categoryUuid": "006", "overview": {"title": "Passport"},
+          "details": {"documentAttributes": {"fileName": "passport.pdf", "documentId": "doc1"}}
+        },
+        {"state": "trashed", "categoryUuid": "003", "overview": {"title": "Old"}, "details": {"notesPlain": "gone"}}
+      ]
+    }]
+  }]
+}`
+
+func onePasswordArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"export.data":              onePasswordData,
+		"files/doc1__passport.pdf": "%PDF-1.4 passport",
+	} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParse1PUX(t *testing.T) {
+	v, err := Parse(Format1PUX, onePasswordArchive(t))
+	require.NoError(t, err)
+
+	require.Len(t, v.LoginPasswords, 1)
+	lp := v.LoginPasswords[0]
+	assert.Equal(t, "jane", lp.Login)
+	assert.Equal(t, "s3cret", lp.Password)
+	assert.Equal(t, "https://mail.example.com", lp.URL)
+	assert.Equal(t, "work mail\nPIN: 4321", lp.Metadata)
+	assert.True(t, lp.Favorite)
+	assert.Equal(t, []string{"email"}, lp.Tags)
+	assert.Equal(t, "Personal", v.Folders[&lp.ItemMeta])
+
+	require.Len(t, v.CreditCards, 1)
+	assert.Equal(t, "12/99", v.CreditCards[0].ExpiryDate)
+	assert.Equal(t, []string{"archived"}, v.CreditCards[0].Tags)
+
+	require.Len(t, v.TextData, 1)
+	assert.Equal(t, "remember", v.TextData[0].Content)
+
+	require.Len(t, v.Attachments, 1)
+	assert.Equal(t, "passport.pdf", v.Attachments[0].FileName)
+	assert.Equal(t, []byte("%PDF-1.4 passport"), v.Attachments[0].Content)
+	assert.Equal(t, "application/pdf", v.Attachments[0].MIMEType)
+}
+
+func TestParse1PUX_NotAnArchive(t *testing.T) {
+	_, err := Parse(Format1PUX, []byte(onePasswordData))
+	assert.Error(t, err)
+}
+
+func TestParse1PasswordCSV(t *testing.T) {
+	data := "Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+		"Mail,https://mail.example.com,jane,s3cret,otpauth://totp/x?secret=AB,true,false,email;work,note\n" +
+		"Note,,,,,false,false,,just text\n"
+	v, err := Parse(Format1PasswordCSV, []byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, v.LoginPasswords, 1)
+	lp := v.LoginPasswords[0]
+	assert.Equal(t, "Mail", lp.DisplayName)
+	assert.Equal(t, "note\nTOTP: otpauth://totp/x?secret=AB", lp.Metadata)
+	assert.Equal(t, []string{"email", "work"}, lp.Tags)
+	assert.True(t, lp.Favorite)
+	require.Len(t, v.TextData, 1)
+	assert.Equal(t, "just text", v.TextData[0].Content)
+}
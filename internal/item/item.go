@@ -0,0 +1,236 @@
+package item
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/validation"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FieldText      = "text"
+	FieldConcealed = "concealed"
+	FieldURL       = "url"
+	FieldDate      = "date"
+	FieldNumber    = "number"
+
+	DateLayout = "2006-01-02"
+
+	// TemplateCustom accepts any fields and has no required ones.
+	TemplateCustom = "custom"
+)
+
+var (
+	ErrUnknownTemplate = errors.New("unknown template")
+	ErrBuiltinTemplate = errors.New("template name is reserved by a built-in template")
+)
+
+var templateName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+var builtin = []models.ItemTemplate{
+	{
+		Name: TemplateCustom,
+	},
+	{
+		Name: "api-key",
+		Fields: []models.ItemField{
+			{Name: "key", Type: FieldConcealed, Required: true},
+			{Name: "secret", Type: FieldConcealed},
+			{Name: "url", Type: FieldURL},
+			{Name: "expires", Type: FieldDate},
+		},
+	},
+	{
+		Name: "database",
+		Fields: []models.ItemField{
+			{Name: "host", Type: FieldText, Required: true},
+			{Name: "port", Type: FieldNumber},
+			{Name: "database", Type: FieldText},
+			{Name: "username", Type: FieldText},
+			{Name: "password", Type: FieldConcealed},
+		},
+	},
+	{
+		Name: "software-license",
+		Fields: []models.ItemField{
+			{Name: "product", Type: FieldText, Required: true},
+			{Name: "license_key", Type: FieldConcealed, Required: true},
+			{Name: "licensed_to", Type: FieldText},
+			{Name: "expires", Type: FieldDate},
+		},
+	},
+}
+
+// Builtin returns the templates every user has.
+func Builtin() []models.ItemTemplate {
+	templates := make([]models.ItemTemplate, len(builtin))
+	copy(templates, builtin)
+	return templates
+}
+
+func BuiltinTemplate(name string) (*models.ItemTemplate, bool) {
+	for i := range builtin {
+		if builtin[i].Name == name {
+			t := builtin[i]
+			return &t, true
+		}
+	}
+	return nil, false
+}
+
+func validType(fieldType string) bool {
+	switch fieldType {
+	case FieldText, FieldConcealed, FieldURL, FieldDate, FieldNumber:
+		return true
+	}
+	return false
+}
+
+// ValidateValue checks value against the rules of a field type.
+func ValidateValue(fieldType, value string) error {
+	switch fieldType {
+	case FieldText, FieldConcealed:
+		return nil
+	case FieldURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.New("must be an absolute URL")
+		}
+	case FieldDate:
+		if _, err := time.Parse(DateLayout, value); err != nil {
+			return fmt.Errorf("must be a date in %s format", DateLayout)
+		}
+	case FieldNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return errors.New("must be a number")
+		}
+	default:
+		return fmt.Errorf("unknown field type %q", fieldType)
+	}
+	return nil
+}
+
+// ValidateTemplate checks a user-defined template.
+func ValidateTemplate(t *models.ItemTemplate) error {
+	t.Name = strings.ToLower(strings.TrimSpace(t.Name))
+	if !templateName.MatchString(t.Name) {
+		return validation.FieldErrors{
+			"name": "must contain only lowercase letters, digits and dashes",
+		}
+	}
+	if _, ok := BuiltinTemplate(t.Name); ok {
+		return ErrBuiltinTemplate
+	}
+
+	errs := validation.FieldErrors{}
+	seen := make(map[string]bool)
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		f.Name = strings.TrimSpace(f.Name)
+		f.Value = ""
+		if f.Type == "" {
+			f.Type = FieldText
+		}
+		switch {
+		case f.Name == "":
+			errs[fmt.Sprintf("fields[%d]", i)] = "name is required"
+		case seen[f.Name]:
+			errs[f.Name] = "duplicate field"
+		case !validType(f.Type):
+			errs[f.Name] = fmt.Sprintf("unknown field type %q", f.Type)
+		}
+		seen[f.Name] = true
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Apply validates an item against its template. Fields defined by the
+// template take their type from it, extra fields default to text and are
+// kept after the template ones.
+func Apply(it *models.Item, t *models.ItemTemplate) error {
+	errs := validation.FieldErrors{}
+	it.Name = strings.TrimSpace(it.Name)
+	if it.Name == "" {
+		errs["name"] = "is required"
+	}
+
+	given := make(map[string]models.ItemField)
+	var order []string
+	for _, f := range it.Fields {
+		f.Name = strings.TrimSpace(f.Name)
+		if f.Name == "" {
+			errs["fields"] = "field name is required"
+			continue
+		}
+		if _, dup := given[f.Name]; dup {
+			errs[f.Name] = "duplicate field"
+			continue
+		}
+		given[f.Name] = f
+		order = append(order, f.Name)
+	}
+
+	fields := make([]models.ItemField, 0, len(given))
+	for _, tf := range t.Fields {
+		f, ok := given[tf.Name]
+		delete(given, tf.Name)
+		if !ok || f.Value == "" {
+			if tf.Required {
+				errs[tf.Name] = "is required"
+			}
+			continue
+		}
+		f.Type = tf.Type
+		f.Required = tf.Required
+		fields = append(fields, f)
+	}
+	for _, name := range order {
+		f, ok := given[name]
+		if !ok {
+			continue
+		}
+		if f.Type == "" {
+			f.Type = FieldText
+		}
+		f.Required = false
+		fields = append(fields, f)
+	}
+
+	for _, f := range fields {
+		if _, reported := errs[f.Name]; reported {
+			continue
+		}
+		if !validType(f.Type) {
+			errs[f.Name] = fmt.Sprintf("unknown field type %q", f.Type)
+			continue
+		}
+		if err := ValidateValue(f.Type, f.Value); err != nil {
+			errs[f.Name] = err.Error()
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	it.Template = t.Name
+	it.Fields = fields
+	return nil
+}
+
+// Field returns the value of the named field.
+func Field(it *models.Item, name string) (string, bool) {
+	for _, f := range it.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
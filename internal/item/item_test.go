@@ -0,0 +1,128 @@
+package item
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidateValue(t *testing.T) {
+	tests := []struct {
+		fieldType string
+		value     string
+		wantErr   bool
+	}{
+		{FieldText, "anything", false},
+		{FieldConcealed, "", false},
+		{FieldURL, "https://api.example.com/v1", false},
+		{FieldURL, "example.com", true},
+		{FieldDate, "2027-01-31", false},
+		{FieldDate, "31.01.2027", true},
+		{FieldNumber, "5432", false},
+		{FieldNumber, "1.5", false},
+		{FieldNumber, "five", true},
+		{"color", "red", true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.fieldType+"/"+tt.value, func(t *testing.T) {
+				err := ValidateValue(tt.fieldType, tt.value)
+				assert.Equal(t, tt.wantErr, err != nil, err)
+			},
+		)
+	}
+}
+
+func TestApply(t *testing.T) {
+	apiKey, ok := BuiltinTemplate("api-key")
+	require.True(t, ok)
+
+	t.Run(
+		"types come from template", func(t *testing.T) {
+			it := &models.Item{
+				Name: " Stripe ",
+				Fields: []models.ItemField{
+					{Name: "note", Value: "prod"},
+					{Name: "url", Type: FieldText, Value: "https://api.stripe.com"},
+					{Name: "key", Value: "sk_live_123"},
+				},
+			}
+			require.NoError(t, Apply(it, apiKey))
+			assert.Equal(t, "Stripe", it.Name)
+			assert.Equal(t, "api-key", it.Template)
+			assert.Equal(
+				t, []models.ItemField{
+					{Name: "key", Type: FieldConcealed, Value: "sk_live_123", Required: true},
+					{Name: "url", Type: FieldURL, Value: "https://api.stripe.com"},
+					{Name: "note", Type: FieldText, Value: "prod"},
+				}, it.Fields,
+			)
+		},
+	)
+
+	t.Run(
+		"reports every invalid field", func(t *testing.T) {
+			it := &models.Item{
+				Fields: []models.ItemField{
+					{Name: "url", Value: "not a url"},
+					{Name: "expires", Value: "soon"},
+					{Name: "expires", Value: "2027-01-01"},
+				},
+			}
+			err := Apply(it, apiKey)
+			assert.Equal(
+				t, validation.FieldErrors{
+					"name":    "is required",
+					"key":     "is required",
+					"url":     "must be an absolute URL",
+					"expires": "duplicate field",
+				}, err,
+			)
+		},
+	)
+
+	t.Run(
+		"custom template accepts typed extra fields", func(t *testing.T) {
+			custom, _ := BuiltinTemplate(TemplateCustom)
+			it := &models.Item{
+				Name:   "Locker",
+				Fields: []models.ItemField{{Name: "code", Type: FieldNumber, Value: "4821"}},
+			}
+			require.NoError(t, Apply(it, custom))
+			assert.Equal(t, FieldNumber, it.Fields[0].Type)
+
+			it.Fields[0].Value = "abc"
+			assert.Error(t, Apply(it, custom))
+		},
+	)
+}
+
+func TestValidateTemplate(t *testing.T) {
+	valid := &models.ItemTemplate{
+		Name: " Wifi ",
+		Fields: []models.ItemField{
+			{Name: "ssid", Required: true},
+			{Name: "password", Type: FieldConcealed, Value: "dropped"},
+		},
+	}
+	require.NoError(t, ValidateTemplate(valid))
+	assert.Equal(t, "wifi", valid.Name)
+	assert.Equal(t, FieldText, valid.Fields[0].Type)
+	assert.Empty(t, valid.Fields[1].Value)
+
+	assert.ErrorIs(t, ValidateTemplate(&models.ItemTemplate{Name: "api-key"}), ErrBuiltinTemplate)
+	assert.Error(t, ValidateTemplate(&models.ItemTemplate{Name: "has space"}))
+	assert.Equal(
+		t, validation.FieldErrors{"a": "duplicate field", "b": `unknown field type "color"`},
+		ValidateTemplate(
+			&models.ItemTemplate{
+				Name: "broken",
+				Fields: []models.ItemField{
+					{Name: "a"}, {Name: "a"}, {Name: "b", Type: "color"},
+				},
+			},
+		),
+	)
+}
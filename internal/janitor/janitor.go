@@ -0,0 +1,42 @@
+// Package janitor runs the periodic cleanup jobs of the server.
+package janitor
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Task is one cleanup job. Run gets the time of the tick and returns the
+// number of records it removed.
+type Task struct {
+	Name string
+	Run  func(now time.Time) (int64, error)
+}
+
+// Run runs every task once right away and then on every tick of interval
+// until ctx is done. Failures are logged and retried on the next tick.
+func Run(ctx context.Context, interval time.Duration, tasks ...Task) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	for {
+		for _, task := range tasks {
+			n, err := task.Run(now)
+			if err != nil {
+				log.Printf("Janitor task %s failed: %v", task.Name, err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("Janitor task %s removed %d records", task.Name, n)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var ok, failed atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		Run(
+			ctx, 5*time.Millisecond,
+			Task{
+				Name: "ok",
+				Run: func(time.Time) (int64, error) {
+					if ok.Add(1) == 3 {
+						cancel()
+					}
+					return 1, nil
+				},
+			},
+			Task{
+				Name: "failing",
+				Run: func(time.Time) (int64, error) {
+					failed.Add(1)
+					return 0, errors.New("boom")
+				},
+			},
+		)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop after the context was cancelled")
+	}
+	assert.Equal(t, int32(3), ok.Load())
+	assert.Equal(t, int32(3), failed.Load(), "a failing task must not stop the others")
+}
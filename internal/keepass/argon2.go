@@ -0,0 +1,239 @@
+package keepass
+
+import (
+	"encoding/binary"
+	"golang.org/x/crypto/blake2b"
+	"math/bits"
+	"sync"
+)
+
+// KeePass and KeePassXC derive keys with Argon2d by default, which
+// golang.org/x/crypto/argon2 does not export, and may set a secret key and
+// associated data it does not take either. argon2Key implements Argon2 as
+// specified by RFC 9106, version 0x13, for these databases.
+
+// Argon2 modes.
+const (
+	argon2d  = 0
+	argon2id = 2
+)
+
+const (
+	argon2Words  = 128 // 64-bit words of a 1 KiB block
+	argon2Slices = 4
+)
+
+type argon2Block [argon2Words]uint64
+
+// argon2Params are the Argon2 parameters of a database, memory in KiB.
+type argon2Params struct {
+	mode, version         uint32
+	time, memory, threads uint32
+	salt, secret, data    []byte
+}
+
+// argon2Key derives a keyLen bytes key from password with p.
+func argon2Key(password []byte, p argon2Params, keyLen uint32) []byte {
+	h0 := argon2H0(password, p, keyLen)
+
+	memory := p.memory / (argon2Slices * p.threads) * (argon2Slices * p.threads)
+	memory = max(memory, 2*argon2Slices*p.threads)
+	laneLen := memory / p.threads
+	segLen := laneLen / argon2Slices
+	B := make([]argon2Block, memory)
+
+	var buf [1024]byte
+	for lane := uint32(0); lane < p.threads; lane++ {
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], i)
+			binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+			argon2Hash(buf[:], h0[:])
+			for j := range B[lane*laneLen+i] {
+				B[lane*laneLen+i][j] = binary.LittleEndian.Uint64(buf[j*8:])
+			}
+		}
+	}
+
+	segment := func(pass, slice, lane uint32) {
+		dataIndependent := p.mode == argon2id && pass == 0 && slice < argon2Slices/2
+		var addresses, input, zero argon2Block
+		if dataIndependent {
+			input[0], input[1], input[2] = uint64(pass), uint64(lane), uint64(slice)
+			input[3], input[4], input[5] = uint64(memory), uint64(p.time), uint64(p.mode)
+		}
+		index := uint32(0)
+		if pass == 0 && slice == 0 {
+			// The first two blocks of the lane are already filled.
+			index = 2
+			if dataIndependent {
+				argon2NextAddresses(&addresses, &input, &zero)
+			}
+		}
+		offset := lane*laneLen + slice*segLen + index
+		for ; index < segLen; index, offset = index+1, offset+1 {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				prev += laneLen
+			}
+			var random uint64
+			if dataIndependent {
+				if index%argon2Words == 0 {
+					argon2NextAddresses(&addresses, &input, &zero)
+				}
+				random = addresses[index%argon2Words]
+			} else {
+				random = B[prev][0]
+			}
+			ref := argon2RefIndex(random, laneLen, segLen, p.threads, pass, slice, lane, index)
+			argon2Compress(&B[offset], &B[prev], &B[ref], pass > 0)
+		}
+	}
+	for pass := uint32(0); pass < p.time; pass++ {
+		for slice := uint32(0); slice < argon2Slices; slice++ {
+			var wg sync.WaitGroup
+			for lane := uint32(0); lane < p.threads; lane++ {
+				wg.Add(1)
+				go func(lane uint32) {
+					defer wg.Done()
+					segment(pass, slice, lane)
+				}(lane)
+			}
+			wg.Wait()
+		}
+	}
+
+	final := B[laneLen-1]
+	for lane := uint32(1); lane < p.threads; lane++ {
+		for i, v := range B[lane*laneLen+laneLen-1] {
+			final[i] ^= v
+		}
+	}
+	for i, v := range final {
+		binary.LittleEndian.PutUint64(buf[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	argon2Hash(key, buf[:])
+	return key
+}
+
+// argon2H0 hashes the parameters and inputs.
+func argon2H0(password []byte, p argon2Params, keyLen uint32) []byte {
+	h, _ := blake2b.New512(nil)
+	for _, v := range []uint32{p.threads, keyLen, p.memory, p.time, p.version, p.mode} {
+		h.Write(binary.LittleEndian.AppendUint32(nil, v))
+	}
+	for _, b := range [][]byte{password, p.salt, p.secret, p.data} {
+		h.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(b))))
+		h.Write(b)
+	}
+	return h.Sum(make([]byte, 0, blake2b.Size+8))[:blake2b.Size+8]
+}
+
+// argon2Hash is the variable length hash H' filling out.
+func argon2Hash(out, in []byte) {
+	prefix := binary.LittleEndian.AppendUint32(nil, uint32(len(out)))
+	if len(out) <= blake2b.Size {
+		h, _ := blake2b.New(len(out), nil)
+		h.Write(prefix)
+		h.Write(in)
+		h.Sum(out[:0])
+		return
+	}
+	h, _ := blake2b.New512(nil)
+	h.Write(prefix)
+	h.Write(in)
+	v := h.Sum(nil)
+	for len(out) > blake2b.Size {
+		n := copy(out, v[:blake2b.Size/2])
+		out = out[n:]
+		size := min(len(out), blake2b.Size)
+		h, _ = blake2b.New(size, nil)
+		h.Write(v)
+		v = h.Sum(nil)
+	}
+	copy(out, v)
+}
+
+// argon2NextAddresses computes the next block of reference addresses.
+func argon2NextAddresses(addresses, input, zero *argon2Block) {
+	input[6]++
+	argon2Compress(addresses, zero, input, false)
+	argon2Compress(addresses, zero, addresses, false)
+}
+
+// argon2RefIndex maps a pseudo-random value to a reference block.
+func argon2RefIndex(random uint64, laneLen, segLen, threads, pass, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % threads
+	if pass == 0 && slice == 0 {
+		refLane = lane
+	}
+	// The reference area is made of the blocks already computed: in the
+	// first pass those before the current segment, else the whole lane but
+	// the current segment, and the blocks of the current segment if it is
+	// in the same lane, except the previous block.
+	area, start := 3*segLen, ((slice+1)%argon2Slices)*segLen
+	if pass == 0 {
+		area, start = slice*segLen, 0
+	}
+	if lane == refLane {
+		area += index
+	}
+	if index == 0 || lane == refLane {
+		area--
+	}
+	x := random & 0xFFFFFFFF
+	x = x * x >> 32
+	x = uint64(area) * x >> 32
+	return refLane*laneLen + uint32((uint64(start)+uint64(area)-x-1)%uint64(laneLen))
+}
+
+// argon2Compress sets or XORs out to the compression of x and y.
+func argon2Compress(out, x, y *argon2Block, xor bool) {
+	var r, q argon2Block
+	for i := range r {
+		r[i] = x[i] ^ y[i]
+	}
+	q = r
+	for i := 0; i < argon2Words; i += 16 {
+		argon2Round(
+			&q[i], &q[i+1], &q[i+2], &q[i+3], &q[i+4], &q[i+5], &q[i+6], &q[i+7],
+			&q[i+8], &q[i+9], &q[i+10], &q[i+11], &q[i+12], &q[i+13], &q[i+14], &q[i+15],
+		)
+	}
+	for i := 0; i < 16; i += 2 {
+		argon2Round(
+			&q[i], &q[i+1], &q[i+16], &q[i+17], &q[i+32], &q[i+33], &q[i+48], &q[i+49],
+			&q[i+64], &q[i+65], &q[i+80], &q[i+81], &q[i+96], &q[i+97], &q[i+112], &q[i+113],
+		)
+	}
+	for i := range out {
+		if xor {
+			out[i] ^= q[i] ^ r[i]
+		} else {
+			out[i] = q[i] ^ r[i]
+		}
+	}
+}
+
+// argon2Round is the BLAKE2b round with multiplications.
+func argon2Round(v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, v10, v11, v12, v13, v14, v15 *uint64) {
+	argon2G(v0, v4, v8, v12)
+	argon2G(v1, v5, v9, v13)
+	argon2G(v2, v6, v10, v14)
+	argon2G(v3, v7, v11, v15)
+	argon2G(v0, v5, v10, v15)
+	argon2G(v1, v6, v11, v12)
+	argon2G(v2, v7, v8, v13)
+	argon2G(v3, v4, v9, v14)
+}
+
+func argon2G(a, b, c, d *uint64) {
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -32)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -24)
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -16)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -63)
+}
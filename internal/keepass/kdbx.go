@@ -0,0 +1,600 @@
+// Package keepass writes and reads KeePass databases in the KDBX 4 format,
+// so that a vault can be kept in and brought back from KeePass, KeePassXC
+// and compatible password managers.
+//
+// A KDBX 4 file starts with a header naming the outer cipher, the key
+// derivation and their parameters, followed by its SHA-256 and its
+// HMAC-SHA-256. The payload is a gzipped inner header, holding the key of
+// the inner random stream that protects passwords and the attachments,
+// followed by the XML document. It is encrypted with ChaCha20 or AES-256
+// in CBC mode and split into blocks authenticated with HMAC-SHA-256. The
+// keys are derived from the password with Argon2id when writing, and with
+// Argon2d, Argon2id or AES-KDF when reading.
+package keepass
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"io"
+)
+
+// Outer ciphers.
+const (
+	CipherChaCha20 = "chacha20"
+	CipherAES      = "aes"
+)
+
+const (
+	signature1    = 0x9AA2D903
+	signature2    = 0xB54BFB67
+	versionMajor  = 4
+	versionMinor  = 0
+	blockSize     = 1 << 20
+	seedSize      = 32
+	saltSize      = 32
+	streamKeySize = 64
+
+	// Outer header fields.
+	headerEnd         = 0
+	headerCipherID    = 2
+	headerCompression = 3
+	headerMasterSeed  = 4
+	headerIV          = 7
+	headerKDFParams   = 11
+	compressionGzip   = 1
+
+	// Inner header fields.
+	innerEnd          = 0
+	innerStreamID     = 1
+	innerStreamKey    = 2
+	innerBinary       = 3
+	innerStreamChaCha = 3
+	binaryFlagsPlain  = 0
+
+	// Variant dictionary of the key derivation parameters.
+	variantVersion   = 0x0100
+	variantEnd       = 0x00
+	variantUInt32    = 0x04
+	variantUInt64    = 0x05
+	variantByteArray = 0x42
+	argon2Version    = 0x13
+
+	// maxMemory bounds the Argon2 memory, in KiB, a database may ask for.
+	maxMemory = 4 << 20
+	maxTime   = 256
+	// maxAESRounds bounds the AES-KDF rounds, about a minute of work.
+	maxAESRounds = 1 << 31
+)
+
+var (
+	uuidAES       = []byte{0x31, 0xc1, 0xf2, 0xe6, 0xbf, 0x71, 0x43, 0x50, 0xbe, 0x58, 0x05, 0x21, 0x6a, 0xfc, 0x5a, 0xff}
+	uuidChaCha20  = []byte{0xd6, 0x03, 0x8a, 0x2b, 0x8b, 0x6f, 0x4c, 0xb5, 0xa5, 0x24, 0x33, 0x9a, 0x31, 0xdb, 0xb5, 0x9a}
+	uuidArgon2id  = []byte{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+	uuidArgon2d   = []byte{0xef, 0x63, 0x6d, 0xdf, 0x8c, 0x29, 0x44, 0x4b, 0x91, 0xf7, 0xa9, 0xa4, 0x03, 0xe3, 0x0a, 0x0c}
+	uuidAESKDF    = []byte{0xc9, 0xd9, 0xf3, 0x9a, 0x62, 0x8a, 0x44, 0x60, 0xbf, 0x74, 0x0d, 0x08, 0xc1, 0x8f, 0xd4, 0xea}
+	headerEndData = []byte("\r\n\r\n")
+)
+
+var (
+	ErrNotKeePass    = errors.New("not a KeePass database")
+	ErrVersion       = errors.New("unsupported KeePass database version, only KDBX 4 is read")
+	ErrUnsupported   = errors.New("unsupported KeePass database")
+	ErrPassword      = errors.New("wrong password or damaged KeePass database")
+	ErrDamaged       = errors.New("damaged KeePass database")
+	ErrEmptyPassword = errors.New("password is empty")
+)
+
+// Params are the outer cipher and the Argon2id parameters of a database;
+// Memory is in KiB.
+type Params struct {
+	Cipher  string
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// DefaultParams use ChaCha20 and the Argon2id parameters of backups.
+var DefaultParams = Params{Cipher: CipherChaCha20, Time: 3, Memory: 64 << 10, Threads: 4}
+
+// valid checks the Argon2id parameters of p.
+func (p Params) valid() bool {
+	return p.Time > 0 && p.Time <= maxTime && p.Memory >= 8*uint32(p.Threads) && p.Memory <= maxMemory &&
+		p.Threads > 0
+}
+
+// Database is what a KDBX file holds: the XML document, with protected
+// values in plaintext, and the attachments, which entries refer to by
+// index.
+type Database struct {
+	File     *File
+	Binaries [][]byte
+}
+
+// Write writes db to w as a KDBX 4 file encrypted with password.
+func Write(w io.Writer, password string, db *Database, params Params) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	if !params.valid() || (params.Cipher != CipherChaCha20 && params.Cipher != CipherAES) {
+		return fmt.Errorf("invalid database parameters %+v", params)
+	}
+	cipherID, ivSize := uuidChaCha20, chacha20.NonceSize
+	if params.Cipher == CipherAES {
+		cipherID, ivSize = uuidAES, aes.BlockSize
+	}
+	iv, err := random(ivSize)
+	if err != nil {
+		return err
+	}
+	salt, err := random(saltSize)
+	if err != nil {
+		return err
+	}
+
+	var kdf bytes.Buffer
+	kdf.Write(binary.LittleEndian.AppendUint16(nil, variantVersion))
+	writeVariant(&kdf, variantByteArray, "$UUID", uuidArgon2id)
+	writeVariant(&kdf, variantUInt32, "V", binary.LittleEndian.AppendUint32(nil, argon2Version))
+	writeVariant(&kdf, variantByteArray, "S", salt)
+	writeVariant(&kdf, variantUInt32, "P", binary.LittleEndian.AppendUint32(nil, uint32(params.Threads)))
+	writeVariant(&kdf, variantUInt64, "M", binary.LittleEndian.AppendUint64(nil, uint64(params.Memory)<<10))
+	writeVariant(&kdf, variantUInt64, "I", binary.LittleEndian.AppendUint64(nil, uint64(params.Time)))
+	kdf.WriteByte(variantEnd)
+	return write(w, password, db, cipherID, iv, kdf.Bytes())
+}
+
+// write writes db to w encrypted with password.
+func write(w io.Writer, password string, db *Database, cipherID, iv, kdf []byte) error {
+	cipherName := CipherChaCha20
+	if bytes.Equal(cipherID, uuidAES) {
+		cipherName = CipherAES
+	}
+	seed, err := random(seedSize)
+	if err != nil {
+		return err
+	}
+	streamKey, err := random(streamKeySize)
+	if err != nil {
+		return err
+	}
+
+	var header bytes.Buffer
+	header.Write(binary.LittleEndian.AppendUint32(nil, signature1))
+	header.Write(binary.LittleEndian.AppendUint32(nil, signature2))
+	header.Write(binary.LittleEndian.AppendUint16(nil, versionMinor))
+	header.Write(binary.LittleEndian.AppendUint16(nil, versionMajor))
+	writeField(&header, headerCipherID, cipherID)
+	writeField(&header, headerCompression, binary.LittleEndian.AppendUint32(nil, compressionGzip))
+	writeField(&header, headerMasterSeed, seed)
+	writeField(&header, headerIV, iv)
+	writeField(&header, headerKDFParams, kdf)
+	writeField(&header, headerEnd, headerEndData)
+
+	key, err := deriveKey(password, kdf)
+	if err != nil {
+		return err
+	}
+	encKey, hmacKey := keys(seed, key)
+
+	doc, err := xml.MarshalIndent(db.File, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode database: %w", err)
+	}
+	if doc, err = protect(append([]byte(xml.Header), doc...), innerStream(streamKey), true); err != nil {
+		return err
+	}
+	var payload bytes.Buffer
+	gz := gzip.NewWriter(&payload)
+	var inner bytes.Buffer
+	writeField(&inner, innerStreamID, binary.LittleEndian.AppendUint32(nil, innerStreamChaCha))
+	writeField(&inner, innerStreamKey, streamKey)
+	for _, b := range db.Binaries {
+		writeField(&inner, innerBinary, append([]byte{binaryFlagsPlain}, b...))
+	}
+	writeField(&inner, innerEnd, nil)
+	if _, err := gz.Write(inner.Bytes()); err != nil {
+		return err
+	}
+	if _, err := gz.Write(doc); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	encrypted, err := encrypt(cipherName, encKey, iv, payload.Bytes())
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(header.Bytes())
+	out := append(header.Bytes(), sum[:]...)
+	out = append(out, headerMAC(hmacKey, header.Bytes())...)
+	if _, err := w.Write(out); err != nil {
+		return err
+	}
+	for i := uint64(0); ; i++ {
+		n := min(blockSize, len(encrypted))
+		block := binary.LittleEndian.AppendUint32(nil, uint32(n))
+		block = append(block, encrypted[:n]...)
+		if _, err := w.Write(append(blockMAC(hmacKey, i, block), block...)); err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		encrypted = encrypted[n:]
+	}
+}
+
+// Read reads a KDBX 4 file encrypted with password.
+func Read(r io.Reader, password string) (*Database, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || binary.LittleEndian.Uint32(data) != signature1 ||
+		binary.LittleEndian.Uint32(data[4:]) != signature2 {
+		return nil, ErrNotKeePass
+	}
+	if binary.LittleEndian.Uint16(data[10:]) != versionMajor {
+		return nil, ErrVersion
+	}
+
+	fields, headerSize, err := readFields(data, 12)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < headerSize+64 {
+		return nil, ErrDamaged
+	}
+	header := data[:headerSize]
+	if sum := sha256.Sum256(header); !bytes.Equal(sum[:], data[headerSize:headerSize+32]) {
+		return nil, fmt.Errorf("%w: header checksum mismatch", ErrDamaged)
+	}
+
+	var cipherName string
+	switch cipherID := fields[headerCipherID]; {
+	case bytes.Equal(cipherID, uuidChaCha20):
+		cipherName = CipherChaCha20
+	case bytes.Equal(cipherID, uuidAES):
+		cipherName = CipherAES
+	default:
+		return nil, fmt.Errorf("%w: cipher %x, only ChaCha20 and AES are read", ErrUnsupported, cipherID)
+	}
+	compression := fields[headerCompression]
+	if len(compression) != 4 || binary.LittleEndian.Uint32(compression) > compressionGzip {
+		return nil, fmt.Errorf("%w: compression %x", ErrUnsupported, compression)
+	}
+	seed := fields[headerMasterSeed]
+	if len(seed) != seedSize {
+		return nil, fmt.Errorf("%w: master seed", ErrDamaged)
+	}
+	key, err := deriveKey(password, fields[headerKDFParams])
+	if err != nil {
+		return nil, err
+	}
+	encKey, hmacKey := keys(seed, key)
+	if !hmac.Equal(headerMAC(hmacKey, header), data[headerSize+32:headerSize+64]) {
+		return nil, ErrPassword
+	}
+
+	var encrypted []byte
+	rest := data[headerSize+64:]
+	for i := uint64(0); ; i++ {
+		if len(rest) < 36 {
+			return nil, fmt.Errorf("%w: truncated", ErrDamaged)
+		}
+		n := int(binary.LittleEndian.Uint32(rest[32:]))
+		if n < 0 || n > len(rest)-36 {
+			return nil, fmt.Errorf("%w: truncated", ErrDamaged)
+		}
+		if !hmac.Equal(blockMAC(hmacKey, i, rest[32:36+n]), rest[:32]) {
+			return nil, fmt.Errorf("%w: block %d", ErrDamaged, i)
+		}
+		if n == 0 {
+			break
+		}
+		encrypted = append(encrypted, rest[36:36+n]...)
+		rest = rest[36+n:]
+	}
+	payload, err := decrypt(cipherName, encKey, fields[headerIV], encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(compression) == compressionGzip {
+		gz, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDamaged, err)
+		}
+		if payload, err = io.ReadAll(gz); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDamaged, err)
+		}
+	}
+	return readPayload(payload)
+}
+
+// readPayload reads the inner header and XML of a payload.
+func readPayload(payload []byte) (*Database, error) {
+	db := new(Database)
+	var stream cipher.Stream
+	for pos := 0; ; {
+		if len(payload)-pos < 5 {
+			return nil, fmt.Errorf("%w: inner header", ErrDamaged)
+		}
+		id, n := payload[pos], int(binary.LittleEndian.Uint32(payload[pos+1:]))
+		pos += 5
+		if n < 0 || n > len(payload)-pos {
+			return nil, fmt.Errorf("%w: inner header", ErrDamaged)
+		}
+		value := payload[pos : pos+n]
+		pos += n
+		switch id {
+		case innerStreamID:
+			if len(value) != 4 || binary.LittleEndian.Uint32(value) != innerStreamChaCha {
+				return nil, fmt.Errorf("%w: inner random stream %x", ErrUnsupported, value)
+			}
+		case innerStreamKey:
+			stream = innerStream(value)
+		case innerBinary:
+			if n == 0 {
+				return nil, fmt.Errorf("%w: binary", ErrDamaged)
+			}
+			db.Binaries = append(db.Binaries, value[1:])
+		case innerEnd:
+			if stream == nil {
+				return nil, fmt.Errorf("%w: no inner random stream key", ErrDamaged)
+			}
+			doc, err := protect(payload[pos:], stream, false)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrDamaged, err)
+			}
+			db.File = new(File)
+			if err := xml.Unmarshal(doc, db.File); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrDamaged, err)
+			}
+			return db, nil
+		}
+	}
+}
+
+// readFields reads the outer header fields starting at pos.
+func readFields(data []byte, pos int) (map[byte][]byte, int, error) {
+	fields := make(map[byte][]byte)
+	for {
+		if len(data)-pos < 5 {
+			return nil, 0, fmt.Errorf("%w: truncated header", ErrDamaged)
+		}
+		id, n := data[pos], int(binary.LittleEndian.Uint32(data[pos+1:]))
+		pos += 5
+		if n < 0 || n > len(data)-pos {
+			return nil, 0, fmt.Errorf("%w: truncated header", ErrDamaged)
+		}
+		fields[id] = data[pos : pos+n]
+		pos += n
+		if id == headerEnd {
+			return fields, pos, nil
+		}
+	}
+}
+
+// deriveKey derives the key of a database from password.
+func deriveKey(password string, params []byte) ([]byte, error) {
+	variants, err := readVariants(params)
+	if err != nil {
+		return nil, err
+	}
+	var mode uint32
+	switch uuid := variants["$UUID"]; {
+	case bytes.Equal(uuid, uuidArgon2id):
+		mode = argon2id
+	case bytes.Equal(uuid, uuidArgon2d):
+		mode = argon2d
+	case bytes.Equal(uuid, uuidAESKDF):
+		return aesKDF(compositeKey(password), variants["S"], variants["R"])
+	default:
+		return nil, fmt.Errorf("%w: key derivation %x", ErrUnsupported, uuid)
+	}
+	version, threads := variants["V"], variants["P"]
+	memory, iterations := variants["M"], variants["I"]
+	if len(version) != 4 || binary.LittleEndian.Uint32(version) != argon2Version ||
+		len(threads) != 4 || len(memory) != 8 || len(iterations) != 8 {
+		return nil, fmt.Errorf("%w: Argon2 parameters", ErrUnsupported)
+	}
+	var p Params
+	t, m, th := binary.LittleEndian.Uint64(iterations), binary.LittleEndian.Uint64(memory)>>10,
+		binary.LittleEndian.Uint32(threads)
+	if t > maxTime || m > maxMemory || th > 255 {
+		return nil, fmt.Errorf("%w: Argon2 parameters exceed %d iterations and %d MiB", ErrUnsupported, maxTime, maxMemory>>10)
+	}
+	p.Time, p.Memory, p.Threads = uint32(t), uint32(m), uint8(th)
+	if !p.valid() {
+		return nil, fmt.Errorf("%w: Argon2 parameters", ErrDamaged)
+	}
+	secret, data := variants["K"], variants["A"]
+	if mode == argon2id && len(secret) == 0 && len(data) == 0 {
+		return argon2.IDKey(compositeKey(password), variants["S"], p.Time, p.Memory, p.Threads, 32), nil
+	}
+	return argon2Key(
+		compositeKey(password), argon2Params{
+			mode:    mode,
+			version: argon2Version,
+			time:    p.Time,
+			memory:  p.Memory,
+			threads: uint32(p.Threads),
+			salt:    variants["S"],
+			secret:  secret,
+			data:    data,
+		}, 32,
+	), nil
+}
+
+// aesKDF is the AES-KDF key derivation.
+func aesKDF(key, seed, rounds []byte) ([]byte, error) {
+	if len(seed) != 32 || len(rounds) != 8 {
+		return nil, fmt.Errorf("%w: AES-KDF parameters", ErrDamaged)
+	}
+	n := binary.LittleEndian.Uint64(rounds)
+	if n > maxAESRounds {
+		return nil, fmt.Errorf("%w: AES-KDF parameters exceed %d rounds", ErrUnsupported, uint64(maxAESRounds))
+	}
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, err
+	}
+	key = append([]byte(nil), key...)
+	for i := uint64(0); i < n; i++ {
+		block.Encrypt(key[:aes.BlockSize], key[:aes.BlockSize])
+		block.Encrypt(key[aes.BlockSize:], key[aes.BlockSize:])
+	}
+	sum := sha256.Sum256(key)
+	return sum[:], nil
+}
+
+// readVariants reads a variant dictionary, keeping the raw values by key.
+func readVariants(data []byte) (map[string][]byte, error) {
+	if len(data) < 2 || binary.LittleEndian.Uint16(data)&0xFF00 != variantVersion&0xFF00 {
+		return nil, fmt.Errorf("%w: key derivation parameters", ErrUnsupported)
+	}
+	variants := make(map[string][]byte)
+	for pos := 2; ; {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("%w: key derivation parameters", ErrDamaged)
+		}
+		if data[pos] == variantEnd {
+			return variants, nil
+		}
+		pos++
+		var key, value []byte
+		for _, field := range []*[]byte{&key, &value} {
+			if len(data)-pos < 4 {
+				return nil, fmt.Errorf("%w: key derivation parameters", ErrDamaged)
+			}
+			n := int(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+			if n < 0 || n > len(data)-pos {
+				return nil, fmt.Errorf("%w: key derivation parameters", ErrDamaged)
+			}
+			*field = data[pos : pos+n]
+			pos += n
+		}
+		variants[string(key)] = value
+	}
+}
+
+func writeVariant(buf *bytes.Buffer, kind byte, key string, value []byte) {
+	buf.WriteByte(kind)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(key))))
+	buf.WriteString(key)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value))))
+	buf.Write(value)
+}
+
+func writeField(buf *bytes.Buffer, id byte, value []byte) {
+	buf.WriteByte(id)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value))))
+	buf.Write(value)
+}
+
+// compositeKey is the key of a database protected by a password only.
+func compositeKey(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	sum = sha256.Sum256(sum[:])
+	return sum[:]
+}
+
+// keys returns the outer cipher key and the HMAC base key.
+func keys(seed, key []byte) (encKey, hmacKey []byte) {
+	enc := sha256.Sum256(append(append([]byte(nil), seed...), key...))
+	mac := sha512.Sum512(append(append(append([]byte(nil), seed...), key...), 1))
+	return enc[:], mac[:]
+}
+
+func blockMAC(hmacKey []byte, index uint64, block []byte) []byte {
+	i := binary.LittleEndian.AppendUint64(nil, index)
+	key := sha512.Sum512(append(i, hmacKey...))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(i)
+	mac.Write(block)
+	return mac.Sum(nil)
+}
+
+func headerMAC(hmacKey, header []byte) []byte {
+	i := binary.LittleEndian.AppendUint64(nil, ^uint64(0))
+	key := sha512.Sum512(append(i, hmacKey...))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+// innerStream is the ChaCha20 stream protected values are encrypted with.
+func innerStream(key []byte) cipher.Stream {
+	h := sha512.Sum512(key)
+	stream, err := chacha20.NewUnauthenticatedCipher(h[:32], h[32:32+chacha20.NonceSize])
+	if err != nil {
+		panic(err)
+	}
+	return stream
+}
+
+func encrypt(cipherName string, key, iv, payload []byte) ([]byte, error) {
+	if cipherName == CipherChaCha20 {
+		stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, len(payload))
+		stream.XORKeyStream(out, payload)
+		return out, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(payload)%aes.BlockSize
+	out := append(append([]byte(nil), payload...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return out, nil
+}
+
+func decrypt(cipherName string, key, iv, encrypted []byte) ([]byte, error) {
+	if cipherName == CipherChaCha20 {
+		if len(iv) != chacha20.NonceSize {
+			return nil, fmt.Errorf("%w: IV", ErrDamaged)
+		}
+		return encrypt(cipherName, key, iv, encrypted)
+	}
+	if len(iv) != aes.BlockSize || len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: IV or payload size", ErrDamaged)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, encrypted)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, fmt.Errorf("%w: padding", ErrDamaged)
+	}
+	return out[:len(out)-pad], nil
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,235 @@
+package keepass
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"testing"
+)
+
+// testParams keep key derivation fast in tests.
+var testParams = Params{Cipher: CipherChaCha20, Time: 1, Memory: 64, Threads: 1}
+
+func testDatabase(t *testing.T) *Database {
+	attachment := make([]byte, 3*blockSize/2)
+	_, err := rand.Read(attachment)
+	require.NoError(t, err)
+
+	login := Entry{UUID: NewUUID(), Tags: "mail;work"}
+	login.Set(FieldTitle, "Mail", false)
+	login.Set(FieldUserName, "jane", false)
+	login.Set(FieldPassword, "s3cret & <more>", true)
+	login.Set(FieldURL, "https://mail.example.com", false)
+	login.Set("otp", "otpauth://totp/jane?secret=JBSWY3DPEHPK3PXP", true)
+	file := Entry{UUID: NewUUID()}
+	file.Set(FieldTitle, "Scan", false)
+	file.Set(FieldPassword, "", true)
+	file.Binaries = []BinaryRef{{Key: "scan.bin"}}
+	file.Binaries[0].Value.Ref = "0"
+
+	return &Database{
+		File: &File{
+			Meta: Meta{Generator: "test"},
+			Root: Root{
+				Groups: []Group{
+					{
+						UUID:    NewUUID(),
+						Name:    "Root",
+						Entries: []Entry{login},
+						Groups:  []Group{{UUID: NewUUID(), Name: "Files", Entries: []Entry{file}}},
+					},
+				},
+			},
+		},
+		Binaries: [][]byte{attachment},
+	}
+}
+
+func writeDatabase(t *testing.T, db *Database, params Params) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "correct horse", db, params))
+	return buf.Bytes()
+}
+
+func TestDatabase_RoundTrip(t *testing.T) {
+	for _, c := range []string{CipherChaCha20, CipherAES} {
+		t.Run(
+			c, func(t *testing.T) {
+				db := testDatabase(t)
+				params := testParams
+				params.Cipher = c
+				data := writeDatabase(t, db, params)
+				assert.NotContains(t, string(data), "s3cret")
+
+				read, err := Read(bytes.NewReader(data), "correct horse")
+				require.NoError(t, err)
+				assert.Equal(t, db.Binaries, read.Binaries)
+				assert.Equal(t, "test", read.File.Meta.Generator)
+				root := read.File.Root.Groups[0]
+				assert.Equal(t, db.File.Root.Groups[0].UUID, root.UUID)
+				login := root.Entries[0]
+				assert.Equal(t, "mail;work", login.Tags)
+				assert.Equal(t, "jane", login.Get(FieldUserName))
+				assert.Equal(t, "s3cret & <more>", login.Get(FieldPassword))
+				assert.Equal(t, "otpauth://totp/jane?secret=JBSWY3DPEHPK3PXP", login.Get("otp"))
+				file := root.Groups[0].Entries[0]
+				assert.Equal(t, "Files", root.Groups[0].Name)
+				assert.Equal(t, "Scan", file.Get(FieldTitle))
+				assert.Equal(t, "scan.bin", file.Binaries[0].Key)
+				assert.Equal(t, "0", file.Binaries[0].Value.Ref)
+			},
+		)
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	data := writeDatabase(t, testDatabase(t), testParams)
+
+	_, err := Read(bytes.NewReader(data), "wrong horse")
+	assert.ErrorIs(t, err, ErrPassword)
+
+	_, err = Read(bytes.NewReader([]byte("PK\x03\x04 not a database at all")), "correct horse")
+	assert.ErrorIs(t, err, ErrNotKeePass)
+
+	kdbx3 := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(kdbx3[10:], 3)
+	_, err = Read(bytes.NewReader(kdbx3), "correct horse")
+	assert.ErrorIs(t, err, ErrVersion)
+
+	header := append([]byte(nil), data...)
+	header[20]++
+	_, err = Read(bytes.NewReader(header), "correct horse")
+	assert.ErrorIs(t, err, ErrDamaged)
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-100] ^= 1
+	_, err = Read(bytes.NewReader(tampered), "correct horse")
+	assert.ErrorIs(t, err, ErrDamaged)
+
+	// Cut off after the first block.
+	_, err = Read(bytes.NewReader(data[:len(data)-(3*blockSize/2)]), "correct horse")
+	assert.ErrorIs(t, err, ErrDamaged)
+
+	assert.ErrorIs(t, Write(&bytes.Buffer{}, "", testDatabase(t), testParams), ErrEmptyPassword)
+	assert.Error(t, Write(&bytes.Buffer{}, "pass", testDatabase(t), Params{Cipher: "twofish", Time: 1, Memory: 64, Threads: 1}))
+}
+
+// kdfParams encodes key derivation parameters as a database header does.
+func kdfParams(uuid []byte, variants ...interface{}) []byte {
+	var kdf bytes.Buffer
+	kdf.Write(binary.LittleEndian.AppendUint16(nil, variantVersion))
+	writeVariant(&kdf, variantByteArray, "$UUID", uuid)
+	for i := 0; i < len(variants); i += 2 {
+		switch v := variants[i+1].(type) {
+		case uint32:
+			writeVariant(&kdf, variantUInt32, variants[i].(string), binary.LittleEndian.AppendUint32(nil, v))
+		case uint64:
+			writeVariant(&kdf, variantUInt64, variants[i].(string), binary.LittleEndian.AppendUint64(nil, v))
+		case []byte:
+			writeVariant(&kdf, variantByteArray, variants[i].(string), v)
+		}
+	}
+	kdf.WriteByte(variantEnd)
+	return kdf.Bytes()
+}
+
+func TestRead_KeyDerivations(t *testing.T) {
+	salt := bytes.Repeat([]byte{7}, saltSize)
+	for name, kdf := range map[string][]byte{
+		"Argon2d": kdfParams(
+			uuidArgon2d, "V", uint32(argon2Version), "S", salt, "P", uint32(2),
+			"M", uint64(64<<10), "I", uint64(2),
+		),
+		"Argon2id with secret key": kdfParams(
+			uuidArgon2id, "V", uint32(argon2Version), "S", salt, "P", uint32(1),
+			"M", uint64(64<<10), "I", uint64(1), "K", []byte("secret"), "A", []byte("data"),
+		),
+		"AES-KDF": kdfParams(uuidAESKDF, "S", salt, "R", uint64(1000)),
+	} {
+		t.Run(
+			name, func(t *testing.T) {
+				db := testDatabase(t)
+				var buf bytes.Buffer
+				iv := make([]byte, chacha20.NonceSize)
+				require.NoError(t, write(&buf, "correct horse", db, uuidChaCha20, iv, kdf))
+
+				read, err := Read(bytes.NewReader(buf.Bytes()), "correct horse")
+				require.NoError(t, err)
+				assert.Equal(t, "s3cret & <more>", read.File.Root.Groups[0].Entries[0].Get(FieldPassword))
+				_, err = Read(bytes.NewReader(buf.Bytes()), "wrong horse")
+				assert.ErrorIs(t, err, ErrPassword)
+			},
+		)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	// The Argon2 test vectors of RFC 9106, section 5.
+	rfc := argon2Params{
+		version: argon2Version,
+		time:    3,
+		memory:  32,
+		threads: 4,
+		salt:    bytes.Repeat([]byte{2}, 16),
+		secret:  bytes.Repeat([]byte{3}, 8),
+		data:    bytes.Repeat([]byte{4}, 12),
+	}
+	password := bytes.Repeat([]byte{1}, 32)
+	rfc.mode = argon2d
+	assert.Equal(
+		t, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb",
+		hex.EncodeToString(argon2Key(password, rfc, 32)),
+	)
+	rfc.mode = argon2id
+	assert.Equal(
+		t, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659",
+		hex.EncodeToString(argon2Key(password, rfc, 32)),
+	)
+
+	for _, p := range []Params{{Time: 2, Memory: 64, Threads: 1}, {Time: 1, Memory: 300, Threads: 3}} {
+		want := argon2.IDKey(password, rfc.salt, p.Time, p.Memory, p.Threads, 32)
+		got := argon2Key(
+			password, argon2Params{
+				mode: argon2id, version: argon2Version, time: p.Time, memory: p.Memory,
+				threads: uint32(p.Threads), salt: rfc.salt,
+			}, 32,
+		)
+		assert.Equal(t, want, got, "%+v", p)
+	}
+
+	// AES-256 in ECB mode, three times, by openssl enc -aes-256-ecb -nopad.
+	key, err := deriveKey("pass", kdfParams(uuidAESKDF, "S", seq(32), "R", uint64(3)))
+	require.NoError(t, err)
+	assert.Equal(t, "549b55614ee992adc3a4560a021c4ea7334d8d2d491c7b99071589535fc623f1", hex.EncodeToString(key))
+}
+
+func TestDeriveKey_Unsupported(t *testing.T) {
+	_, err := deriveKey("pass", kdfParams(seq(16)))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = deriveKey(
+		"pass", kdfParams(
+			uuidArgon2id, "V", uint32(argon2Version), "P", uint32(1), "M", uint64(1<<40), "I", uint64(1),
+		),
+	)
+	assert.ErrorIs(t, err, ErrUnsupported, "memory beyond the limit")
+
+	_, err = deriveKey("pass", kdfParams(uuidAESKDF, "S", seq(32), "R", uint64(1<<40)))
+	assert.ErrorIs(t, err, ErrUnsupported, "rounds beyond the limit")
+	_, err = deriveKey("pass", kdfParams(uuidAESKDF, "S", seq(16), "R", uint64(1)))
+	assert.ErrorIs(t, err, ErrDamaged)
+}
+
+// seq returns the bytes 0 to n-1.
+func seq(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
@@ -0,0 +1,223 @@
+package keepass
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The standard strings of an entry.
+const (
+	FieldTitle    = "Title"
+	FieldUserName = "UserName"
+	FieldPassword = "Password"
+	FieldURL      = "URL"
+	FieldNotes    = "Notes"
+)
+
+// The strings of the entries auth-keeper writes for cards, and the string
+// KeePassXC keeps the otpauth URI of a TOTP secret in.
+const (
+	FieldCardNumber = "Card number"
+	FieldExpiryDate = "Expiry date"
+	FieldCVV        = "CVV"
+	FieldCardHolder = "Card holder"
+	FieldBrand      = "Brand"
+	FieldOTP        = "otp"
+)
+
+// File is the XML document of a KeePass database, also exported by KeePass
+// as a KeePass 2 XML file. Only the fields auth-keeper reads and writes are
+// declared; entry history and times are left out.
+type File struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    Meta     `xml:"Meta"`
+	Root    Root     `xml:"Root"`
+}
+
+type Meta struct {
+	Generator      string `xml:"Generator,omitempty"`
+	DatabaseName   string `xml:"DatabaseName,omitempty"`
+	RecycleBinUUID string `xml:"RecycleBinUUID,omitempty"`
+	// Binaries are the attachments of a KeePass 2 XML export; those of a
+	// KDBX 4 database are in Database.Binaries.
+	Binaries *MetaBinaries `xml:"Binaries"`
+}
+
+type MetaBinaries struct {
+	Binaries []MetaBinary `xml:"Binary"`
+}
+
+// MetaBinary is an attachment of a KeePass 2 XML export, base64 encoded
+// and gzipped if Compressed.
+type MetaBinary struct {
+	ID         string `xml:"ID,attr"`
+	Compressed Bool   `xml:"Compressed,attr,omitempty"`
+	Value      string `xml:",chardata"`
+}
+
+type Root struct {
+	Groups []Group `xml:"Group"`
+}
+
+type Group struct {
+	UUID    string  `xml:"UUID"`
+	Name    string  `xml:"Name"`
+	Entries []Entry `xml:"Entry"`
+	Groups  []Group `xml:"Group"`
+}
+
+type Entry struct {
+	UUID     string      `xml:"UUID"`
+	Tags     string      `xml:"Tags,omitempty"`
+	Strings  []String    `xml:"String"`
+	Binaries []BinaryRef `xml:"Binary"`
+}
+
+type String struct {
+	Key   string `xml:"Key"`
+	Value Value  `xml:"Value"`
+}
+
+// Value is the value of a string. Protected values are encrypted by the
+// inner random stream in a KDBX file; File holds them in plaintext.
+type Value struct {
+	Protected Bool   `xml:"Protected,attr,omitempty"`
+	Content   string `xml:",chardata"`
+}
+
+// BinaryRef is an attachment of an entry: a reference to a binary of the
+// database by its index or, in some XML exports, the base64 encoded file.
+type BinaryRef struct {
+	Key   string `xml:"Key"`
+	Value struct {
+		Ref  string `xml:"Ref,attr,omitempty"`
+		Data string `xml:",chardata"`
+	} `xml:"Value"`
+}
+
+// Bool is a boolean attribute, written the way KeePass expects it.
+type Bool bool
+
+func (b Bool) MarshalText() ([]byte, error) {
+	if b {
+		return []byte("True"), nil
+	}
+	return []byte("False"), nil
+}
+
+func (b *Bool) UnmarshalText(text []byte) error {
+	*b = Bool(strings.EqualFold(string(text), "true"))
+	return nil
+}
+
+// Get returns the value of the string key of e.
+func (e *Entry) Get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value.Content
+		}
+	}
+	return ""
+}
+
+// Set adds the string key to e, or replaces its value.
+func (e *Entry) Set(key, value string, protected bool) {
+	for i := range e.Strings {
+		if e.Strings[i].Key == key {
+			e.Strings[i].Value = Value{Protected: Bool(protected), Content: value}
+			return
+		}
+	}
+	e.Strings = append(e.Strings, String{Key: key, Value: Value{Protected: Bool(protected), Content: value}})
+}
+
+// NewUUID returns a random UUID, base64 encoded as in the XML document.
+func NewUUID() string {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(uuid)
+}
+
+// protect seals or opens the protected values of doc in document order.
+func protect(doc []byte, stream cipher.Stream, seal bool) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(doc, []byte("\xef\xbb\xbf"))))
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	var value []byte
+	protected := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "Value" && protectedAttr(&t, !seal) {
+				protected, value = true, value[:0]
+			}
+			tok = t
+		case xml.CharData:
+			if protected {
+				value = append(value, t...)
+				continue
+			}
+		case xml.EndElement:
+			if protected {
+				content, err := xorValue(value, stream, seal)
+				if err != nil {
+					return nil, err
+				}
+				if err := enc.EncodeToken(xml.CharData(content)); err != nil {
+					return nil, err
+				}
+				protected = false
+			}
+		}
+		if err := enc.EncodeToken(tok); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// protectedAttr reports whether an element is protected.
+func protectedAttr(t *xml.StartElement, drop bool) bool {
+	for i, attr := range t.Attr {
+		if attr.Name.Local == "Protected" && strings.EqualFold(attr.Value, "true") {
+			if drop {
+				t.Attr = append(t.Attr[:i:i], t.Attr[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func xorValue(value []byte, stream cipher.Stream, seal bool) ([]byte, error) {
+	if seal {
+		sealed := make([]byte, len(value))
+		stream.XORKeyStream(sealed, value)
+		return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+	}
+	plain, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(value)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: protected value: %v", ErrDamaged, err)
+	}
+	stream.XORKeyStream(plain, plain)
+	return plain, nil
+}
@@ -0,0 +1,65 @@
+package keepass
+
+import (
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	// KeePass reads protected values in document order, history included.
+	doc := `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Root>
+		<Group>
+			<Entry>
+				<String><Key>Password</Key><Value Protected="True">new</Value></String>
+				<String><Key>Empty</Key><Value Protected="True"/></String>
+				<String><Key>Plain</Key><Value>visible</Value></String>
+				<History><Entry><String><Key>Password</Key><Value Protected="True">old</Value></String></Entry></History>
+			</Entry>
+			<Entry>
+				<String><Key>Password</Key><Value Protected="True">other</Value></String>
+			</Entry>
+		</Group>
+	</Root>
+</KeePassFile>`
+	key := []byte(strings.Repeat("k", streamKeySize))
+	sealed, err := protect([]byte(doc), innerStream(key), true)
+	require.NoError(t, err)
+	for _, secret := range []string{">new<", ">old<", ">other<"} {
+		assert.NotContains(t, string(sealed), secret)
+	}
+	assert.Contains(t, string(sealed), ">visible<")
+
+	opened, err := protect(sealed, innerStream(key), false)
+	require.NoError(t, err)
+	assert.NotContains(t, string(opened), "Protected")
+	var file File
+	require.NoError(t, xml.Unmarshal(opened, &file))
+	entries := file.Root.Groups[0].Entries
+	assert.Equal(t, "new", entries[0].Get(FieldPassword))
+	assert.Equal(t, "", entries[0].Get("Empty"))
+	assert.Equal(t, "visible", entries[0].Get("Plain"))
+	assert.Equal(t, "other", entries[1].Get(FieldPassword))
+
+	// The history is read with the stream but not kept.
+	assert.Contains(t, string(opened), ">old<")
+
+	_, err = protect([]byte(`<Value Protected="True">not base64!</Value>`), innerStream(key), false)
+	assert.ErrorIs(t, err, ErrDamaged)
+}
+
+func TestEntry_Set(t *testing.T) {
+	var e Entry
+	e.Set(FieldPassword, "a", true)
+	e.Set(FieldPassword, "b", true)
+	require.Len(t, e.Strings, 1)
+	assert.Equal(t, "b", e.Get(FieldPassword))
+
+	data, err := xml.Marshal(e)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<Value Protected="True">b</Value>`)
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// LimitBody refuses request bodies longer than limit bytes with 413. Bodies
+// of unknown length are cut off at limit, so reading past it fails.
+func LimitBody(limit int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.ContentLength > limit {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(LimitBody(8))
+	router.POST(
+		"/", func(ctx *gin.Context) {
+			if _, err := io.ReadAll(ctx.Request.Body); err != nil {
+				ctx.Status(http.StatusRequestEntityTooLarge)
+				return
+			}
+			ctx.Status(http.StatusOK)
+		},
+	)
+	send := func(body io.Reader) int {
+		req, _ := http.NewRequest("POST", "/", body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(strings.NewReader("12345678")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(strings.NewReader("123456789")))
+	// Without a Content-Length the body is cut off while it is read.
+	assert.Equal(
+		t, http.StatusRequestEntityTooLarge, send(io.MultiReader(strings.NewReader("123456789"))),
+	)
+}
@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/repository"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"log"
 	"net/http"
 	"strings"
@@ -80,7 +83,9 @@ func JWTAuth() gin.HandlerFunc {
 	}
 }
 
-func ExtractUserID() gin.HandlerFunc {
+// ExtractUserID sets the user of the token, rejecting tokens issued before
+// the user's last password change.
+func ExtractUserID(userRepo repository.UserRepo) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		token, exists := ctx.Get("token")
 		if !exists {
@@ -90,7 +95,7 @@ func ExtractUserID() gin.HandlerFunc {
 		}
 
 		tokenStr := fmt.Sprintf("%v", token)
-		userID, err := security.GetUserFromToken(tokenStr)
+		claims, err := security.ParseToken(tokenStr)
 		if err != nil {
 			log.Printf("Error extracting user from token: %v", err)
 			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -98,7 +103,24 @@ func ExtractUserID() gin.HandlerFunc {
 			return
 		}
 
-		ctx.Set("userID", userID)
+		user, err := userRepo.GetUserByUsername(claims.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			} else {
+				log.Printf("Error getting user: %v", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+			}
+			ctx.Abort()
+			return
+		}
+		if user.TokenVersion != claims.TokenVersion {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked, log in again"})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set("userID", claims.UserID)
 		ctx.Next()
 	}
 }
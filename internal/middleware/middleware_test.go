@@ -5,9 +5,11 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
 	"github.com/elina-chertova/auth-keeper.git/internal/security"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestJWTAuth(t *testing.T) {
@@ -41,7 +43,7 @@ func TestJWTAuth(t *testing.T) {
 
 	t.Run(
 		"Valid Authorization Header", func(t *testing.T) {
-			token, _ := security.GenerateToken("test_user")
+			token, _ := security.GenerateToken("test_user", 0)
 			req, _ := http.NewRequest("GET", "/test", nil)
 			req.Header.Set("Authorization", "Bearer "+token)
 			w := httptest.NewRecorder()
@@ -52,9 +54,17 @@ func TestJWTAuth(t *testing.T) {
 }
 
 func TestExtractUserID(t *testing.T) {
+	users := &MockUserRepo{
+		mockGetUserByUsername: func(username string) (*models.User, error) {
+			if username != "test_user" {
+				return nil, gorm.ErrRecordNotFound
+			}
+			return &models.User{Username: username, TokenVersion: 1}, nil
+		},
+	}
 	router := gin.New()
 	router.Use(JWTAuth())
-	router.Use(ExtractUserID())
+	router.Use(ExtractUserID(users))
 
 	router.GET(
 		"/test", func(c *gin.Context) {
@@ -69,7 +79,7 @@ func TestExtractUserID(t *testing.T) {
 
 	t.Run(
 		"Valid Token", func(t *testing.T) {
-			token, _ := security.GenerateToken("test_user")
+			token, _ := security.GenerateToken("test_user", 1)
 			req, _ := http.NewRequest("GET", "/test", nil)
 			req.Header.Set("Authorization", "Bearer "+token)
 			w := httptest.NewRecorder()
@@ -79,6 +89,28 @@ func TestExtractUserID(t *testing.T) {
 		},
 	)
 
+	t.Run(
+		"Revoked Token", func(t *testing.T) {
+			token, _ := security.GenerateToken("test_user", 0)
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		},
+	)
+
+	t.Run(
+		"Unknown User", func(t *testing.T) {
+			token, _ := security.GenerateToken("ghost", 0)
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		},
+	)
+
 	t.Run(
 		"Invalid Token", func(t *testing.T) {
 			req, _ := http.NewRequest("GET", "/test", nil)
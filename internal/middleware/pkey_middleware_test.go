@@ -25,6 +25,14 @@ func (m *MockUserRepo) CreateUser(user *models.User) error {
 	return m.mockCreateUser(user)
 }
 
+func (m *MockUserRepo) UpdatePassword(username, hashedPassword string) error {
+	return nil
+}
+
+func (m *MockUserRepo) SetPublicKey(username string, publicKey []byte) error {
+	return nil
+}
+
 func TestLoadPersonalKey(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -0,0 +1,181 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+var (
+	ErrInvalidSecret    = errors.New("secret must be a valid base32 string")
+	ErrInvalidAlgorithm = errors.New("algorithm must be SHA1, SHA256 or SHA512")
+	ErrInvalidDigits    = errors.New("digits must be between 6 and 8")
+	ErrInvalidPeriod    = errors.New("period must be positive")
+	ErrInvalidURI       = errors.New("invalid otpauth URI")
+)
+
+// Normalize fills in RFC 6238 defaults and canonicalizes the secret.
+func Normalize(s *models.OTPSecret) {
+	s.Secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s.Secret), " ", ""))
+	s.Secret = strings.TrimRight(s.Secret, "=")
+	s.Algorithm = strings.ToUpper(strings.ReplaceAll(s.Algorithm, "-", ""))
+	if s.Algorithm == "" {
+		s.Algorithm = AlgorithmSHA1
+	}
+	if s.Digits == 0 {
+		s.Digits = DefaultDigits
+	}
+	if s.Period == 0 {
+		s.Period = DefaultPeriod
+	}
+}
+
+func Validate(s *models.OTPSecret) error {
+	if _, err := decodeSecret(s.Secret); err != nil {
+		return err
+	}
+	if _, err := hashFunc(s.Algorithm); err != nil {
+		return err
+	}
+	if s.Digits < 6 || s.Digits > 8 {
+		return ErrInvalidDigits
+	}
+	if s.Period <= 0 {
+		return ErrInvalidPeriod
+	}
+	return nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	if secret == "" {
+		return nil, ErrInvalidSecret
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, ErrInvalidAlgorithm
+}
+
+// GenerateCode returns the TOTP code for the moment t.
+func GenerateCode(s *models.OTPSecret, t time.Time) (string, error) {
+	if err := Validate(s); err != nil {
+		return "", err
+	}
+	key, _ := decodeSecret(s.Secret)
+	h, _ := hashFunc(s.Algorithm)
+	return hotp(key, uint64(t.Unix())/uint64(s.Period), s.Digits, h), nil
+}
+
+// Remaining returns how long the code generated at t stays valid.
+func Remaining(s *models.OTPSecret, t time.Time) time.Duration {
+	period := int64(s.Period)
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// hotp implements RFC 4226 with dynamic truncation.
+func hotp(key []byte, counter uint64, digits int, h func() hash.Hash) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// ParseURI parses an otpauth://totp/ URI as produced by QR codes of most
+// services.
+func ParseURI(uri string) (*models.OTPSecret, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+	}
+	if u.Scheme != "otpauth" {
+		return nil, fmt.Errorf("%w: scheme must be otpauth", ErrInvalidURI)
+	}
+	if u.Host != "totp" {
+		return nil, fmt.Errorf("%w: only totp is supported", ErrInvalidURI)
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	s := &models.OTPSecret{Account: label}
+	if i := strings.Index(label, ":"); i >= 0 {
+		s.Issuer = strings.TrimSpace(label[:i])
+		s.Account = strings.TrimSpace(label[i+1:])
+	}
+
+	q := u.Query()
+	s.Secret = q.Get("secret")
+	if issuer := q.Get("issuer"); issuer != "" {
+		s.Issuer = issuer
+	}
+	s.Algorithm = q.Get("algorithm")
+	if d := q.Get("digits"); d != "" {
+		if s.Digits, err = strconv.Atoi(d); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDigits, err)
+		}
+	}
+	if p := q.Get("period"); p != "" {
+		if s.Period, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPeriod, err)
+		}
+	}
+
+	Normalize(s)
+	if err := Validate(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Matches reports whether name refers to the secret by issuer, account or
+// "issuer:account", ignoring case.
+func Matches(s *models.OTPSecret, name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return name == strings.ToLower(s.Issuer) ||
+		name == strings.ToLower(s.Account) ||
+		name == strings.ToLower(s.Issuer+":"+s.Account)
+}
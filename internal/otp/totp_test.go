@@ -0,0 +1,132 @@
+package otp
+
+import (
+	"encoding/base32"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func rfcSecret(seed string) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(seed))
+}
+
+// Test vectors from RFC 6238, Appendix B.
+func TestGenerateCode(t *testing.T) {
+	seeds := map[string]string{
+		AlgorithmSHA1:   "12345678901234567890",
+		AlgorithmSHA256: "12345678901234567890123456789012",
+		AlgorithmSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	tests := []struct {
+		unix int64
+		want map[string]string
+	}{
+		{59, map[string]string{AlgorithmSHA1: "94287082", AlgorithmSHA256: "46119246", AlgorithmSHA512: "90693936"}},
+		{1111111109, map[string]string{AlgorithmSHA1: "07081804", AlgorithmSHA256: "68084774", AlgorithmSHA512: "25091201"}},
+		{1111111111, map[string]string{AlgorithmSHA1: "14050471", AlgorithmSHA256: "67062674", AlgorithmSHA512: "99943326"}},
+		{1234567890, map[string]string{AlgorithmSHA1: "89005924", AlgorithmSHA256: "91819424", AlgorithmSHA512: "93441116"}},
+		{2000000000, map[string]string{AlgorithmSHA1: "69279037", AlgorithmSHA256: "90698825", AlgorithmSHA512: "38618901"}},
+		{20000000000, map[string]string{AlgorithmSHA1: "65353130", AlgorithmSHA256: "77737706", AlgorithmSHA512: "47863826"}},
+	}
+	for _, tt := range tests {
+		for alg, want := range tt.want {
+			t.Run(
+				fmt.Sprintf("%s/%d", alg, tt.unix), func(t *testing.T) {
+					s := &models.OTPSecret{
+						Secret:    rfcSecret(seeds[alg]),
+						Algorithm: alg,
+						Digits:    8,
+						Period:    30,
+					}
+					got, err := GenerateCode(s, time.Unix(tt.unix, 0))
+					assert.NoError(t, err)
+					assert.Equal(t, want, got)
+				},
+			)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	s := &models.OTPSecret{Period: 30}
+	assert.Equal(t, 30*time.Second, Remaining(s, time.Unix(60, 0)))
+	assert.Equal(t, 1*time.Second, Remaining(s, time.Unix(89, 0)))
+}
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    *models.OTPSecret
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Full URI",
+			uri:  "otpauth://totp/ACME%20Co:john@example.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60",
+			want: &models.OTPSecret{
+				Issuer:    "ACME Co",
+				Account:   "john@example.com",
+				Secret:    "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ",
+				Algorithm: AlgorithmSHA256,
+				Digits:    8,
+				Period:    60,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Defaults and lowercase padded secret",
+			uri:  "otpauth://totp/alice?secret=jbswy3dpehpk3pxp%3D%3D%3D",
+			want: &models.OTPSecret{
+				Account:   "alice",
+				Secret:    "JBSWY3DPEHPK3PXP",
+				Algorithm: AlgorithmSHA1,
+				Digits:    DefaultDigits,
+				Period:    DefaultPeriod,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "HOTP is not supported",
+			uri:     "otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=1",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Wrong scheme",
+			uri:     "https://totp/alice?secret=JBSWY3DPEHPK3PXP",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Invalid secret",
+			uri:     "otpauth://totp/alice?secret=not-base32!",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Invalid algorithm",
+			uri:     "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := ParseURI(tt.uri)
+				if !tt.wantErr(t, err, fmt.Sprintf("ParseURI(%v)", tt.uri)) {
+					return
+				}
+				assert.Equal(t, tt.want, got)
+			},
+		)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	s := &models.OTPSecret{Issuer: "GitHub", Account: "octocat"}
+	assert.True(t, Matches(s, "github"))
+	assert.True(t, Matches(s, "OctoCat"))
+	assert.True(t, Matches(s, "github:octocat"))
+	assert.False(t, Matches(s, "gitlab"))
+}
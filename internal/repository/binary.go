@@ -0,0 +1,580 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUploadComplete   = errors.New("upload is already complete")
+	ErrUploadIncomplete = errors.New("upload is not complete")
+	ErrMissingChunks    = errors.New("upload is missing chunks")
+	ErrHashMismatch     = errors.New("content hash does not match the uploaded chunks")
+	ErrChunkedUpdate    = errors.New("chunked binary data cannot be updated")
+	ErrBlobCorrupt      = errors.New("stored content does not match its hash")
+)
+
+// sweepBatch is the number of orphan blobs SweepBlobs deletes per query.
+const sweepBatch = 100
+
+// BinaryRepo serves binary data downloads and stores the chunks of chunked
+// uploads.
+type BinaryRepo interface {
+	Get(userID string, id uint) (*models.BinaryData, error)
+	SaveNewUpload(bd *models.BinaryData) error
+	GetUpload(userID string, id uint) (*models.BinaryData, error)
+	SaveChunk(userID string, id uint, number int, data []byte) error
+	GetChunkNumbers(userID string, id uint) ([]int, error)
+	CompleteUpload(userID string, id uint, hash string) error
+	GetChunk(userID string, id uint, number int) ([]byte, error)
+}
+
+// BinaryDataRepo stores binary data rows in the database and their content
+// and chunks in a blob store. Items of one user with the same content key
+// share their blobs, counted by BlobRef rows. Blobs that are no longer
+// referenced are recorded as orphans and deleted by SweepBlobs.
+type BinaryDataRepo struct {
+	*Repo[models.BinaryData]
+	blobs blobstore.Store
+}
+
+func NewBinaryRepo(db *gorm.DB, blobs blobstore.Store) *BinaryDataRepo {
+	return &BinaryDataRepo{Repo: NewBDRepo(db), blobs: blobs}
+}
+
+// Save stores the content of inline binary data as a blob and creates the
+// row referencing it. Listing binary data returns the rows only; Get loads
+// the content.
+func (br *BinaryDataRepo) Save(bd *models.BinaryData) error {
+	undo := func() {}
+	if !bd.Chunked {
+		var err error
+		if undo, err = br.storeContent(bd.UserID, bd); err != nil {
+			return fmt.Errorf("failed to add new %s: %w", br.name, err)
+		}
+	}
+	if err := br.Repo.Save(bd); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+// SaveAll is Save for several items, whose rows are created in one
+// transaction.
+func (br *BinaryDataRepo) SaveAll(items []*models.BinaryData) error {
+	var undos []func()
+	undo := func() {
+		for _, u := range undos {
+			u()
+		}
+	}
+	for _, bd := range items {
+		if bd.Chunked {
+			continue
+		}
+		u, err := br.storeContent(bd.UserID, bd)
+		if err != nil {
+			undo()
+			return fmt.Errorf("failed to add %d new %s: %w", len(items), br.name, err)
+		}
+		undos = append(undos, u)
+	}
+	if err := br.Repo.SaveAll(items); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+func (br *BinaryDataRepo) Get(userID string, id uint) (*models.BinaryData, error) {
+	bd, err := br.Repo.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := br.loadContent(bd); err != nil {
+		return nil, fmt.Errorf("failed to get %s %d: %w", br.name, id, err)
+	}
+	return bd, nil
+}
+
+// Update stores the new content as a blob and keeps the previous content
+// in the version; the reference to the previous blob is released.
+func (br *BinaryDataRepo) Update(
+	userID string,
+	id uint,
+	item *models.BinaryData,
+	seal Sealer,
+) error {
+	if item.Chunked {
+		return fmt.Errorf("failed to update %s %d: %w", br.name, id, ErrChunkedUpdate)
+	}
+	undo, err := br.storeContent(userID, item)
+	if err != nil {
+		return fmt.Errorf("failed to update %s %d: %w", br.name, id, err)
+	}
+	var prevRef string
+	err = br.Repo.Update(
+		userID, id, item, func(v interface{}) ([]byte, error) {
+			prev := v.(*models.BinaryData)
+			if prev.Chunked {
+				return nil, ErrChunkedUpdate
+			}
+			if err := br.loadContent(prev); err != nil {
+				return nil, err
+			}
+			prevRef = prev.ContentRef
+			return seal(prev)
+		},
+	)
+	if err != nil {
+		undo()
+		return err
+	}
+	if err := br.release(prevRef); err != nil {
+		return fmt.Errorf("failed to update %s %d: %w", br.name, id, err)
+	}
+	return nil
+}
+
+// storeContent stores the inline content of bd and returns its undo.
+func (br *BinaryDataRepo) storeContent(userID string, bd *models.BinaryData) (func(), error) {
+	bd.Size = int64(len(bd.Content))
+	src, refs, err := br.share(userID, bd)
+	if err != nil {
+		return nil, err
+	}
+	if src != nil {
+		bd.ContentRef, bd.ContentHash, bd.Compression = src.ContentRef, src.ContentHash, src.Compression
+		return func() { _ = br.release(refs...) }, nil
+	}
+
+	packed := stream.Pack(bd.Content)
+	ref, err := putNewBlob(br.blobs, packed)
+	if err != nil {
+		return nil, err
+	}
+	bd.ContentRef = ref
+	bd.ContentHash = contentHash(packed)
+	bd.Compression = stream.CompressionZstd
+	return func() { br.dropBlob(ref) }, nil
+}
+
+// loadContent reads and checks the content of inline binary data.
+func (br *BinaryDataRepo) loadContent(bd *models.BinaryData) error {
+	if bd.Chunked || bd.ContentRef == "" {
+		return nil
+	}
+	data, err := br.blobs.Get(bd.ContentRef)
+	if err != nil {
+		return err
+	}
+	if contentHash(data) != bd.ContentHash {
+		return fmt.Errorf("%w: %s %d", ErrBlobCorrupt, br.name, bd.ID)
+	}
+	if bd.Compression != "" {
+		if data, err = stream.Unpack(data, bd.Size); err != nil {
+			return fmt.Errorf("%w: %s %d", ErrBlobCorrupt, br.name, bd.ID)
+		}
+	}
+	bd.Content = data
+	return nil
+}
+
+// share references the blobs of an item of the user with bd's content key.
+func (br *BinaryDataRepo) share(
+	userID string,
+	bd *models.BinaryData,
+) (*models.BinaryData, []string, error) {
+	if bd.ContentKey == "" {
+		return nil, nil, nil
+	}
+	query := br.db.Where(
+		"user_id = ? AND content_key = ? AND sha256 = ? AND size = ? AND chunked = ?",
+		userID, bd.ContentKey, bd.SHA256, bd.Size, bd.Chunked,
+	)
+	if bd.Chunked {
+		query = query.Where("complete = ?", true)
+	} else {
+		query = query.Where("content_ref <> ''")
+	}
+	src := &models.BinaryData{}
+	err := query.Order("id").First(src).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refs := []string{src.ContentRef}
+	if src.Chunked {
+		refs = nil
+		err := br.db.Model(&models.BinaryChunk{}).
+			Where("binary_data_id = ?", src.ID).
+			Order("number").
+			Pluck("ref", &refs).Error
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := br.db.Transaction(func(tx *gorm.DB) error { return retainBlobs(tx, refs...) }); err != nil {
+		return nil, nil, err
+	}
+	// The item may have been purged before the references were taken.
+	var n int64
+	if err := br.db.Model(&models.BinaryData{}).Where("id = ?", src.ID).Count(&n).Error; err != nil || n == 0 {
+		if releaseErr := br.release(refs...); err == nil {
+			err = releaseErr
+		}
+		return nil, nil, err
+	}
+	return src, refs, nil
+}
+
+// release drops one reference to each of refs.
+func (br *BinaryDataRepo) release(refs ...string) error {
+	return br.db.Transaction(func(tx *gorm.DB) error { return releaseBlobs(tx, refs...) })
+}
+
+// dropBlob deletes a blob stored for a row that was never saved.
+func (br *BinaryDataRepo) dropBlob(ref string) {
+	if ref != "" {
+		_ = br.blobs.Delete(ref)
+	}
+}
+
+// SaveNewUpload creates a chunked upload. If the user already has a
+// complete upload with the same content key, the new one shares its chunks
+// and is complete at once.
+func (br *BinaryDataRepo) SaveNewUpload(bd *models.BinaryData) error {
+	src, refs, err := br.share(bd.UserID, bd)
+	if err != nil {
+		return fmt.Errorf("failed to add new %s: %w", br.name, err)
+	}
+	if src == nil {
+		return br.Repo.Save(bd)
+	}
+	bd.ChunkSize, bd.Chunks, bd.Header = src.ChunkSize, src.Chunks, src.Header
+	bd.Compression = src.Compression
+	if err := br.Repo.Save(bd); err != nil {
+		_ = br.release(refs...)
+		return err
+	}
+
+	err = br.db.Transaction(
+		func(tx *gorm.DB) error {
+			var chunks []*models.BinaryChunk
+			if err := tx.Where("binary_data_id = ?", src.ID).Find(&chunks).Error; err != nil {
+				return err
+			}
+			if len(chunks) != len(refs) {
+				return ErrMissingChunks
+			}
+			for _, chunk := range chunks {
+				chunk.BinaryDataID = bd.ID
+			}
+			if err := tx.Create(chunks).Error; err != nil {
+				return err
+			}
+			return tx.Model(bd).UpdateColumns(
+				map[string]interface{}{"complete": true, "cipher_hash": src.CipherHash},
+			).Error
+		},
+	)
+	if err != nil {
+		_ = br.release(refs...)
+		return fmt.Errorf("failed to share the chunks of upload %d: %w", src.ID, err)
+	}
+	bd.Complete, bd.CipherHash = true, src.CipherHash
+	return nil
+}
+
+// GetUpload returns one of the user's chunked binary data items.
+func (br *BinaryDataRepo) GetUpload(userID string, id uint) (*models.BinaryData, error) {
+	bd, err := br.upload(br.db, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload %d: %w", id, err)
+	}
+	return bd, nil
+}
+
+func (br *BinaryDataRepo) upload(tx *gorm.DB, userID string, id uint) (*models.BinaryData, error) {
+	bd := &models.BinaryData{}
+	err := tx.Where(liveItem+" AND chunked = ?", userID, id, true).First(bd).Error
+	if err != nil {
+		return nil, err
+	}
+	return bd, nil
+}
+
+// SaveChunk stores or replaces one chunk of an upload that is not complete.
+func (br *BinaryDataRepo) SaveChunk(userID string, id uint, number int, data []byte) error {
+	ref, err := putNewBlob(br.blobs, data)
+	if err != nil {
+		return fmt.Errorf("failed to save chunk %d of upload %d: %w", number, id, err)
+	}
+	err = br.db.Transaction(
+		func(tx *gorm.DB) error {
+			bd, err := br.upload(tx, userID, id)
+			if err != nil {
+				return err
+			}
+			if bd.Complete {
+				return ErrUploadComplete
+			}
+			var prev []string
+			err = tx.Model(&models.BinaryChunk{}).
+				Where("binary_data_id = ? AND number = ?", id, number).
+				Pluck("ref", &prev).Error
+			if err != nil {
+				return err
+			}
+			chunk := &models.BinaryChunk{
+				BinaryDataID: id,
+				Number:       number,
+				Ref:          ref,
+				Size:         len(data),
+			}
+			err = tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(chunk).Error
+			if err != nil {
+				return err
+			}
+			return orphanBlobs(tx, prev...)
+		},
+	)
+	if err != nil {
+		br.dropBlob(ref)
+		return fmt.Errorf("failed to save chunk %d of upload %d: %w", number, id, err)
+	}
+	return nil
+}
+
+// GetChunkNumbers lists the numbers of the chunks received of an upload.
+func (br *BinaryDataRepo) GetChunkNumbers(userID string, id uint) ([]int, error) {
+	var numbers []int
+	err := func() error {
+		if _, err := br.upload(br.db, userID, id); err != nil {
+			return err
+		}
+		return br.db.Model(&models.BinaryChunk{}).
+			Where("binary_data_id = ?", id).
+			Order("number").
+			Pluck("number", &numbers).Error
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks of upload %d: %w", id, err)
+	}
+	return numbers, nil
+}
+
+// CompleteUpload checks that every chunk has arrived and that their hex
+// SHA-256 in order is hash, and makes the upload downloadable.
+func (br *BinaryDataRepo) CompleteUpload(userID string, id uint, hash string) error {
+	err := br.db.Transaction(
+		func(tx *gorm.DB) error {
+			bd, err := br.upload(tx, userID, id)
+			if err != nil {
+				return err
+			}
+			if bd.Complete {
+				return ErrUploadComplete
+			}
+			var chunks []*models.BinaryChunk
+			err = tx.Where("binary_data_id = ?", id).Order("number").Find(&chunks).Error
+			if err != nil {
+				return err
+			}
+			if len(chunks) != bd.Chunks {
+				return ErrMissingChunks
+			}
+
+			h := sha256.New()
+			for _, chunk := range chunks {
+				data, err := br.blobs.Get(chunk.Ref)
+				if err != nil {
+					return err
+				}
+				h.Write(data)
+			}
+			if hex.EncodeToString(h.Sum(nil)) != hash {
+				return ErrHashMismatch
+			}
+			return tx.Model(bd).UpdateColumns(
+				map[string]interface{}{"complete": true, "cipher_hash": hash},
+			).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete upload %d: %w", id, err)
+	}
+	return nil
+}
+
+// GetChunk returns one chunk of a complete upload.
+func (br *BinaryDataRepo) GetChunk(userID string, id uint, number int) ([]byte, error) {
+	var data []byte
+	err := func() error {
+		bd, err := br.upload(br.db, userID, id)
+		if err != nil {
+			return err
+		}
+		if !bd.Complete {
+			return ErrUploadIncomplete
+		}
+		var chunk models.BinaryChunk
+		err = br.db.Where("binary_data_id = ? AND number = ?", id, number).First(&chunk).Error
+		if err != nil {
+			return err
+		}
+		data, err = br.blobs.Get(chunk.Ref)
+		return err
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk %d of binary data %d: %w", number, id, err)
+	}
+	return data, nil
+}
+
+// releaseBinaryBlobs deletes the chunks and blobs of binary data.
+func releaseBinaryBlobs(tx *gorm.DB, ids []uint) error {
+	var refs, chunkRefs []string
+	err := tx.Model(&models.BinaryData{}).
+		Where("id IN ? AND content_ref <> ''", ids).
+		Pluck("content_ref", &refs).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.BinaryChunk{}).
+		Where("binary_data_id IN ?", ids).
+		Pluck("ref", &chunkRefs).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("binary_data_id IN ?", ids).Delete(&models.BinaryChunk{}).Error; err != nil {
+		return err
+	}
+	return releaseBlobs(tx, append(refs, chunkRefs...)...)
+}
+
+// retainBlobs takes one more reference to each of refs.
+func retainBlobs(tx *gorm.DB, refs ...string) error {
+	for _, ref := range refs {
+		err := tx.Clauses(
+			clause.OnConflict{
+				Columns: []clause.Column{{Name: "ref"}},
+				DoUpdates: clause.Assignments(
+					map[string]interface{}{"refs": gorm.Expr("blob_refs.refs + 1")},
+				),
+			},
+		).Create(&models.BlobRef{Ref: ref, Refs: 2}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseBlobs drops one reference to each of refs.
+func releaseBlobs(tx *gorm.DB, refs ...string) error {
+	var orphans []string
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		res := tx.Model(&models.BlobRef{}).
+			Where("ref = ? AND refs > 2", ref).
+			UpdateColumn("refs", gorm.Expr("refs - 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			continue
+		}
+		// Two references left one: the row goes, as for unshared blobs.
+		res = tx.Where("ref = ?", ref).Delete(&models.BlobRef{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			orphans = append(orphans, ref)
+		}
+	}
+	return orphanBlobs(tx, orphans...)
+}
+
+func orphanBlobs(tx *gorm.DB, refs ...string) error {
+	orphans := make([]*models.OrphanBlob, 0, len(refs))
+	for _, ref := range refs {
+		if ref != "" {
+			orphans = append(orphans, &models.OrphanBlob{Ref: ref})
+		}
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+	return tx.Create(orphans).Error
+}
+
+// SweepBlobs deletes orphan blobs from the blob store and returns their
+// number. Orphans that are referenced again, which can only be the result
+// of a lost reference count, keep their blob.
+func SweepBlobs(db *gorm.DB, blobs blobstore.Store) (int64, error) {
+	var swept int64
+	for {
+		var orphans []*models.OrphanBlob
+		if err := db.Order("id").Limit(sweepBatch).Find(&orphans).Error; err != nil {
+			return swept, fmt.Errorf("failed to sweep blobs: %w", err)
+		}
+		if len(orphans) == 0 {
+			return swept, nil
+		}
+		for _, orphan := range orphans {
+			referenced, err := blobReferenced(db, orphan.Ref)
+			if err != nil {
+				return swept, fmt.Errorf("failed to sweep blobs: %w", err)
+			}
+			if !referenced {
+				if err := blobs.Delete(orphan.Ref); err != nil {
+					return swept, fmt.Errorf("failed to delete blob %s: %w", orphan.Ref, err)
+				}
+			}
+			if err := db.Delete(orphan).Error; err != nil {
+				return swept, fmt.Errorf("failed to sweep blobs: %w", err)
+			}
+			swept++
+		}
+	}
+}
+
+func blobReferenced(db *gorm.DB, ref string) (bool, error) {
+	for _, table := range []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.BinaryData{}, "content_ref"},
+		{&models.BinaryChunk{}, "ref"},
+		{&models.BlobRef{}, "ref"},
+	} {
+		var n int64
+		if err := db.Model(table.model).Where(table.column+" = ?", ref).Count(&n).Error; err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
 	"github.com/stretchr/testify/assert"
 
 	"gorm.io/gorm"
@@ -11,12 +13,16 @@ import (
 
 func setupTestDBWithBinaryData(data []*models.BinaryData) *gorm.DB {
 	db := setupTestDB()
+	repo := NewBinaryRepo(db, testBlobs)
 	for _, d := range data {
-		db.Create(d)
+		repo.Save(d)
 	}
 	return db
 }
 
+// testBlobs keeps the blobs of binary data saved by the tests.
+var testBlobs = blobstore.NewMemory()
+
 func TestNewBDRepo(t *testing.T) {
 	type args struct {
 		db *gorm.DB
@@ -24,14 +30,14 @@ func TestNewBDRepo(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want *BDRepo
+		want *Repo[models.BinaryData]
 	}{
 		{
 			name: "Valid DB instance",
 			args: args{
 				db: &gorm.DB{},
 			},
-			want: &BDRepo{db: &gorm.DB{}},
+			want: &Repo[models.BinaryData]{db: &gorm.DB{}, name: "binary data"},
 		},
 	}
 	for _, tt := range tests {
@@ -43,7 +49,7 @@ func TestNewBDRepo(t *testing.T) {
 	}
 }
 
-func TestBDRepo_GetBinaryData(t *testing.T) {
+func TestBDRepo_List(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -107,25 +113,28 @@ func TestBDRepo_GetBinaryData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				bd := &BDRepo{
-					db: tt.fields.db,
-				}
-				got, err := bd.GetBinaryData(tt.args.userID)
-				if !tt.wantErr(t, err, fmt.Sprintf("GetBinaryData(%v)", tt.args.userID)) {
+				bd := NewBinaryRepo(tt.fields.db, testBlobs)
+				got, err := bd.List(tt.args.userID, ListFilter{})
+				if !tt.wantErr(t, err, fmt.Sprintf("List(%v)", tt.args.userID)) {
 					return
 				}
 				for i := range got {
 					tt.want[i].Model.ID = got[i].Model.ID
 					tt.want[i].CreatedAt = got[i].CreatedAt
 					tt.want[i].UpdatedAt = got[i].UpdatedAt
+					tt.want[i].ContentRef = got[i].ContentRef
+					tt.want[i].ContentHash = contentHash(stream.Pack(tt.want[i].Content))
+					tt.want[i].Compression = stream.CompressionZstd
+					tt.want[i].Size = int64(len(tt.want[i].Content))
+					tt.want[i].Content = nil
 				}
-				assert.Equalf(t, tt.want, got, "GetBinaryData(%v)", tt.args.userID)
+				assert.Equalf(t, tt.want, got, "List(%v)", tt.args.userID)
 			},
 		)
 	}
 }
 
-func TestBDRepo_SaveNewBinaryData(t *testing.T) {
+func TestBDRepo_Save(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -156,13 +165,11 @@ func TestBDRepo_SaveNewBinaryData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				bd := &BDRepo{
-					db: tt.fields.db,
-				}
+				bd := NewBinaryRepo(tt.fields.db, testBlobs)
 				tt.wantErr(
 					t,
-					bd.SaveNewBinaryData(tt.args.binaryData),
-					fmt.Sprintf("SaveNewBinaryData(%v)", tt.args.binaryData),
+					bd.Save(tt.args.binaryData),
+					fmt.Sprintf("Save(%v)", tt.args.binaryData),
 				)
 			},
 		)
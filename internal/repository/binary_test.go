@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/elina-chertova/auth-keeper.git/internal/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestBinaryDataRepo_Upload(t *testing.T) {
+	db := setupTestDB()
+	blobs := blobstore.NewMemory()
+	repo := NewBinaryRepo(db, blobs)
+
+	inline := &models.BinaryData{UserID: "uploaduser", Content: []byte("inline")}
+	require.NoError(t, repo.Save(inline))
+	_, err := repo.GetUpload("uploaduser", inline.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	bd := &models.BinaryData{UserID: "uploaduser", Content: []byte{}, Chunked: true, Chunks: 2}
+	require.NoError(t, repo.SaveNewUpload(bd))
+	_, err = repo.GetUpload("otheruser", bd.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, repo.SaveChunk("uploaduser", bd.ID, 1, []byte("stale")))
+	require.NoError(t, repo.SaveChunk("uploaduser", bd.ID, 1, []byte("second")))
+	assert.ErrorIs(t, repo.SaveChunk("otheruser", bd.ID, 0, []byte("x")), gorm.ErrRecordNotFound)
+	numbers, err := repo.GetChunkNumbers("uploaduser", bd.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, numbers)
+
+	sum := sha256.Sum256([]byte("firstsecond"))
+	hash := hex.EncodeToString(sum[:])
+	assert.ErrorIs(t, repo.CompleteUpload("uploaduser", bd.ID, hash), ErrMissingChunks)
+	_, err = repo.GetChunk("uploaduser", bd.ID, 1)
+	assert.ErrorIs(t, err, ErrUploadIncomplete)
+
+	require.NoError(t, repo.SaveChunk("uploaduser", bd.ID, 0, []byte("first")))
+	assert.ErrorIs(t, repo.CompleteUpload("uploaduser", bd.ID, "00"), ErrHashMismatch)
+	require.NoError(t, repo.CompleteUpload("uploaduser", bd.ID, hash))
+	assert.ErrorIs(t, repo.CompleteUpload("uploaduser", bd.ID, hash), ErrUploadComplete)
+	assert.ErrorIs(t, repo.SaveChunk("uploaduser", bd.ID, 0, []byte("x")), ErrUploadComplete)
+
+	got, err := repo.GetUpload("uploaduser", bd.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Complete)
+	assert.Equal(t, hash, got.CipherHash)
+	chunk, err := repo.GetChunk("uploaduser", bd.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), chunk)
+
+	require.NoError(t, repo.Delete("uploaduser", bd.ID))
+	_, err = repo.GetChunk("uploaduser", bd.ID, 0)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	require.NoError(t, repo.Purge("uploaduser", bd.ID))
+	var left int64
+	db.Model(&models.BinaryChunk{}).Where("binary_data_id = ?", bd.ID).Count(&left)
+	assert.Zero(t, left)
+	swept, err := SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), swept)
+	assert.Equal(t, 1, blobs.Len())
+}
+
+func TestBinaryDataRepo_Blobs(t *testing.T) {
+	db := setupTestDB()
+	blobs := blobstore.NewMemory()
+	repo := NewBinaryRepo(db, blobs)
+
+	bd := &models.BinaryData{UserID: "blobuser", Content: []byte("first")}
+	require.NoError(t, repo.Save(bd))
+	assert.NotEmpty(t, bd.ContentRef)
+	assert.Equal(t, int64(5), bd.Size)
+	assert.False(t, db.Migrator().HasColumn(&models.BinaryData{}, "content"))
+	data, err := blobs.Get(bd.ContentRef)
+	require.NoError(t, err)
+	assert.Equal(t, stream.Pack([]byte("first")), data)
+
+	got, err := repo.Get("blobuser", bd.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), got.Content)
+
+	update := &models.BinaryData{Content: []byte("second")}
+	require.NoError(t, repo.Update("blobuser", bd.ID, update, plainSeal))
+	versions, err := repo.Versions("blobuser", bd.ID)
+	require.NoError(t, err)
+	if assert.Len(t, versions, 1) {
+		assert.Contains(t, string(versions[0].Data), base64.StdEncoding.EncodeToString([]byte("first")))
+	}
+	list, err := repo.List("blobuser", ListFilter{})
+	require.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Nil(t, list[0].Content)
+		assert.Equal(t, int64(6), list[0].Size)
+	}
+	got, err = repo.Get("blobuser", bd.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), got.Content)
+	swept, err := SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), swept)
+	assert.Equal(t, 1, blobs.Len())
+
+	require.NoError(t, blobs.Put(update.ContentRef, []byte("tampered")))
+	_, err = repo.Get("blobuser", bd.ID)
+	assert.ErrorIs(t, err, ErrBlobCorrupt)
+
+	require.NoError(t, repo.Delete("blobuser", bd.ID))
+	require.NoError(t, repo.Purge("blobuser", bd.ID))
+	_, err = SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Zero(t, blobs.Len())
+}
+
+func TestBinaryDataRepo_SaveAll(t *testing.T) {
+	db := setupTestDB()
+	blobs := blobstore.NewMemory()
+	repo := NewBinaryRepo(db, blobs)
+
+	items := []*models.BinaryData{
+		{UserID: "blobbatch", Content: []byte("one")},
+		{UserID: "blobbatch", Content: []byte("two")},
+	}
+	require.NoError(t, repo.SaveAll(items))
+	assert.Equal(t, 2, blobs.Len())
+	for _, bd := range items {
+		got, err := repo.Get("blobbatch", bd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, bd.Content, got.Content)
+	}
+
+	missing := uint(1 << 30)
+	err := repo.SaveAll(
+		[]*models.BinaryData{
+			{UserID: "blobbatch", Content: []byte("three")},
+			{UserID: "blobbatch", Content: []byte("four"), ItemMeta: models.ItemMeta{FolderID: &missing}},
+		},
+	)
+	assert.ErrorIs(t, err, ErrFolderNotFound)
+	_, err = SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Equal(t, 2, blobs.Len())
+	list, err := repo.List("blobbatch", ListFilter{})
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+}
+
+func TestBinaryDataRepo_Dedup(t *testing.T) {
+	db := setupTestDB()
+	blobs := blobstore.NewMemory()
+	repo := NewBinaryRepo(db, blobs)
+	refs := func(ref string) int {
+		var n int64
+		db.Model(&models.BlobRef{}).Where("ref = ?", ref).Select("refs").Scan(&n)
+		return int(n)
+	}
+
+	cert := []byte("-----BEGIN CERTIFICATE-----")
+	sum := sha256.Sum256(cert)
+	newCert := func(user string) *models.BinaryData {
+		return &models.BinaryData{
+			UserID: user, Content: cert, ContentKey: "certkey", SHA256: hex.EncodeToString(sum[:]),
+		}
+	}
+	first, second, other := newCert("dedupuser"), newCert("dedupuser"), newCert("dedupother")
+	require.NoError(t, repo.Save(first))
+	require.NoError(t, repo.Save(second))
+	require.NoError(t, repo.Save(other))
+	assert.Equal(t, first.ContentRef, second.ContentRef)
+	assert.NotEqual(t, first.ContentRef, other.ContentRef)
+	assert.Equal(t, 2, blobs.Len())
+	assert.Equal(t, 2, refs(first.ContentRef))
+
+	got, err := repo.Get("dedupuser", second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, cert, got.Content)
+
+	require.NoError(t, repo.Delete("dedupuser", first.ID))
+	require.NoError(t, repo.Purge("dedupuser", first.ID))
+	assert.Zero(t, refs(first.ContentRef))
+	_, err = SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	got, err = repo.Get("dedupuser", second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, cert, got.Content)
+
+	require.NoError(t, repo.Update("dedupuser", second.ID, &models.BinaryData{Content: []byte("new")}, plainSeal))
+	_, err = SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Equal(t, 2, blobs.Len())
+
+	// A second upload of a complete upload shares its chunks.
+	upload := &models.BinaryData{
+		UserID: "dedupuser", Chunked: true, Chunks: 1, Size: 5, SHA256: "abc", ContentKey: "filekey",
+		Header: []byte("header"),
+	}
+	require.NoError(t, repo.SaveNewUpload(upload))
+	assert.False(t, upload.Complete)
+	require.NoError(t, repo.SaveChunk("dedupuser", upload.ID, 0, []byte("chunk")))
+	chunkSum := sha256.Sum256([]byte("chunk"))
+	require.NoError(t, repo.CompleteUpload("dedupuser", upload.ID, hex.EncodeToString(chunkSum[:])))
+
+	again := &models.BinaryData{
+		UserID: "dedupuser", Chunked: true, Chunks: 1, Size: 5, SHA256: "abc", ContentKey: "filekey",
+		Header: []byte("other"),
+	}
+	require.NoError(t, repo.SaveNewUpload(again))
+	assert.True(t, again.Complete)
+	assert.Equal(t, upload.Header, again.Header)
+	chunk, err := repo.GetChunk("dedupuser", again.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk"), chunk)
+
+	require.NoError(t, repo.Delete("dedupuser", upload.ID))
+	require.NoError(t, repo.Purge("dedupuser", upload.ID))
+	_, err = SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	chunk, err = repo.GetChunk("dedupuser", again.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk"), chunk)
+
+	require.NoError(t, repo.Delete("dedupuser", again.ID))
+	require.NoError(t, repo.Purge("dedupuser", again.ID))
+	_, err = SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Equal(t, 2, blobs.Len())
+}
+
+func TestSweepBlobs_KeepsReferenced(t *testing.T) {
+	db := setupTestDB()
+	blobs := blobstore.NewMemory()
+	repo := NewBinaryRepo(db, blobs)
+
+	bd := &models.BinaryData{UserID: "sweepuser", Content: []byte("kept")}
+	require.NoError(t, repo.Save(bd))
+	require.NoError(t, orphanBlobs(db, bd.ContentRef))
+	swept, err := SweepBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), swept)
+	assert.Equal(t, 1, blobs.Len())
+}
+
+func TestMigrateBlobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:migrateblobs?mode=memory"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(
+		t, db.AutoMigrate(&models.BinaryData{}, &models.BinaryChunk{}, &models.Tag{}, &models.ItemTag{}),
+	)
+	// Columns of the tables before contents moved to the blob store.
+	require.NoError(t, db.Exec("ALTER TABLE binary_data ADD COLUMN `content` blob").Error)
+	require.NoError(t, db.Exec("ALTER TABLE binary_chunks ADD COLUMN `data` blob").Error)
+	require.NoError(
+		t, db.Exec(
+			`INSERT INTO binary_data (id, user_id, content, chunked, complete) VALUES
+			(1, 'u', 'inline', false, false), (2, 'u', '', true, true)`,
+		).Error,
+	)
+	require.NoError(
+		t, db.Exec(
+			`INSERT INTO binary_chunks (binary_data_id, number, data) VALUES
+			(2, 0, 'sealed0'), (2, 1, 'sealed1')`,
+		).Error,
+	)
+	assert.True(t, InlineBlobsLeft(db))
+
+	blobs := blobstore.NewMemory()
+	moved, err := MigrateBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), moved)
+	assert.False(t, InlineBlobsLeft(db))
+	assert.Equal(t, 3, blobs.Len())
+
+	repo := NewBinaryRepo(db, blobs)
+	bd, err := repo.Get("u", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("inline"), bd.Content)
+	var chunk models.BinaryChunk
+	require.NoError(t, db.Where("binary_data_id = 2 AND number = 1").First(&chunk).Error)
+	data, err := blobs.Get(chunk.Ref)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sealed1"), data)
+
+	moved, err = MigrateBlobs(db, blobs)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+)
+
+// migrateBatch is the number of rows MigrateBlobs moves per query.
+const migrateBatch = 100
+
+// Columns that held binary contents before they moved to the blob store.
+const (
+	legacyContentColumn = "content"
+	legacyChunkColumn   = "data"
+)
+
+// InlineBlobsLeft reports whether binary contents are still stored in the
+// database and MigrateBlobs has to be run.
+func InlineBlobsLeft(db *gorm.DB) bool {
+	m := db.Migrator()
+	return m.HasColumn(&models.BinaryData{}, legacyContentColumn) ||
+		m.HasColumn(&models.BinaryChunk{}, legacyChunkColumn)
+}
+
+// MigrateBlobs moves the binary data contents and chunks stored in the
+// database to blobs and drops their columns. It can be run again after a
+// failure and returns the number of blobs written.
+func MigrateBlobs(db *gorm.DB, blobs blobstore.Store) (int64, error) {
+	m := db.Migrator()
+	var moved int64
+	if m.HasColumn(&models.BinaryData{}, legacyContentColumn) {
+		n, err := migrateContents(db, blobs)
+		moved += n
+		if err != nil {
+			return moved, fmt.Errorf("failed to migrate binary data contents: %w", err)
+		}
+		if err := m.DropColumn(&models.BinaryData{}, legacyContentColumn); err != nil {
+			return moved, fmt.Errorf("failed to drop binary data contents: %w", err)
+		}
+	}
+	if m.HasColumn(&models.BinaryChunk{}, legacyChunkColumn) {
+		n, err := migrateChunks(db, blobs)
+		moved += n
+		if err != nil {
+			return moved, fmt.Errorf("failed to migrate binary chunks: %w", err)
+		}
+		if err := m.DropColumn(&models.BinaryChunk{}, legacyChunkColumn); err != nil {
+			return moved, fmt.Errorf("failed to drop binary chunk contents: %w", err)
+		}
+	}
+	return moved, nil
+}
+
+func migrateContents(db *gorm.DB, blobs blobstore.Store) (int64, error) {
+	var moved int64
+	for {
+		var rows []struct {
+			ID      uint
+			Content []byte
+		}
+		err := db.Model(&models.BinaryData{}).
+			Select("id, "+legacyContentColumn).
+			Where("(content_ref IS NULL OR content_ref = '') AND chunked = ?", false).
+			Order("id").
+			Limit(migrateBatch).
+			Scan(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return moved, err
+		}
+		for _, row := range rows {
+			ref, err := putNewBlob(blobs, row.Content)
+			if err != nil {
+				return moved, err
+			}
+			err = db.Model(&models.BinaryData{}).Where("id = ?", row.ID).UpdateColumns(
+				map[string]interface{}{
+					"content_ref":  ref,
+					"size":         len(row.Content),
+					"content_hash": contentHash(row.Content),
+					"sha256":       contentHash(row.Content),
+				},
+			).Error
+			if err != nil {
+				_ = blobs.Delete(ref)
+				return moved, err
+			}
+			moved++
+		}
+	}
+}
+
+func migrateChunks(db *gorm.DB, blobs blobstore.Store) (int64, error) {
+	var moved int64
+	for {
+		var rows []struct {
+			BinaryDataID uint
+			Number       int
+			Data         []byte
+		}
+		err := db.Model(&models.BinaryChunk{}).
+			Select("binary_data_id, number, " + legacyChunkColumn).
+			Where("ref IS NULL OR ref = ''").
+			Order("binary_data_id, number").
+			Limit(migrateBatch).
+			Scan(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return moved, err
+		}
+		for _, row := range rows {
+			ref, err := putNewBlob(blobs, row.Data)
+			if err != nil {
+				return moved, err
+			}
+			err = db.Model(&models.BinaryChunk{}).
+				Where("binary_data_id = ? AND number = ?", row.BinaryDataID, row.Number).
+				UpdateColumns(map[string]interface{}{"ref": ref, "size": len(row.Data)}).Error
+			if err != nil {
+				_ = blobs.Delete(ref)
+				return moved, err
+			}
+			moved++
+		}
+	}
+}
+
+func putNewBlob(blobs blobstore.Store, data []byte) (string, error) {
+	ref, err := blobstore.NewKey()
+	if err != nil {
+		return "", err
+	}
+	return ref, blobs.Put(ref, data)
+}
@@ -16,14 +16,14 @@ func TestNewCCRepo(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want *CCRepo
+		want *Repo[models.CreditCard]
 	}{
 		{
 			name: "Valid DB instance",
 			args: args{
 				db: &gorm.DB{},
 			},
-			want: &CCRepo{db: &gorm.DB{}},
+			want: &Repo[models.CreditCard]{db: &gorm.DB{}, name: "credit card"},
 		},
 	}
 	for _, tt := range tests {
@@ -35,7 +35,7 @@ func TestNewCCRepo(t *testing.T) {
 	}
 }
 
-func TestCCRepo_GetCreditCardList(t *testing.T) {
+func TestCCRepo_List(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -111,11 +111,9 @@ func TestCCRepo_GetCreditCardList(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				cc := &CCRepo{
-					db: tt.fields.db,
-				}
-				got, err := cc.GetCreditCardList(tt.args.userID)
-				if !tt.wantErr(t, err, fmt.Sprintf("GetCreditCardList(%v)", tt.args.userID)) {
+				cc := NewCCRepo(tt.fields.db)
+				got, err := cc.List(tt.args.userID, ListFilter{})
+				if !tt.wantErr(t, err, fmt.Sprintf("List(%v)", tt.args.userID)) {
 					return
 				}
 				for i := range got {
@@ -123,13 +121,13 @@ func TestCCRepo_GetCreditCardList(t *testing.T) {
 					tt.want[i].CreatedAt = got[i].CreatedAt
 					tt.want[i].UpdatedAt = got[i].UpdatedAt
 				}
-				assert.Equalf(t, tt.want, got, "GetCreditCardList(%v)", tt.args.userID)
+				assert.Equalf(t, tt.want, got, "List(%v)", tt.args.userID)
 			},
 		)
 	}
 }
 
-func TestCCRepo_SaveNewCreditCard(t *testing.T) {
+func TestCCRepo_Save(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -163,13 +161,11 @@ func TestCCRepo_SaveNewCreditCard(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				cc := &CCRepo{
-					db: tt.fields.db,
-				}
+				cc := NewCCRepo(tt.fields.db)
 				tt.wantErr(
 					t,
-					cc.SaveNewCreditCard(tt.args.creditCard),
-					fmt.Sprintf("SaveNewCreditCard(%v)", tt.args.creditCard),
+					cc.Save(tt.args.creditCard),
+					fmt.Sprintf("Save(%v)", tt.args.creditCard),
 				)
 			},
 		)
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrEmergencyState       = errors.New("emergency access is not in a state that allows this")
+	ErrEmergencyNotApproved = errors.New("emergency access has not been approved")
+)
+
+type EmergencyRepo interface {
+	Grant(access *models.EmergencyAccess) error
+	GrantedBy(grantorID string) ([]*models.EmergencyAccess, error)
+	GrantedTo(granteeID string) ([]*models.EmergencyAccess, error)
+	Pending(grantorID string) (int64, error)
+	Request(granteeID string, id uint, now time.Time) (*models.EmergencyAccess, error)
+	Reject(grantorID string, id uint) error
+	Approve(grantorID string, id uint) error
+	Revoke(grantorID string, id uint) error
+	Access(granteeID string, id uint, now time.Time) (*models.EmergencyAccess, error)
+}
+
+type emergencyRepo struct {
+	db *gorm.DB
+}
+
+func NewEmergencyRepo(db *gorm.DB) *emergencyRepo {
+	return &emergencyRepo{db: db}
+}
+
+// Grant gives a user emergency access to the grantor's vault. Granting it
+// again to the same user replaces the type, wait period and key and drops
+// a pending request.
+func (er *emergencyRepo) Grant(access *models.EmergencyAccess) error {
+	access.Status = models.EmergencyGranted
+	access.RequestedAt = nil
+	access.RecoveryAt = nil
+	err := er.db.Transaction(
+		func(tx *gorm.DB) error {
+			var existing models.EmergencyAccess
+			err := tx.Where("grantor_id = ? AND grantee_id = ?", access.GrantorID, access.GranteeID).
+				First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return tx.Create(access).Error
+			}
+			if err != nil {
+				return err
+			}
+			access.Model = existing.Model
+			return tx.Save(access).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to grant emergency access to %s: %w", access.GranteeID, err)
+	}
+	return nil
+}
+
+// GrantedBy lists the emergency access the grantor gave, with the pending
+// requests for it.
+func (er *emergencyRepo) GrantedBy(grantorID string) ([]*models.EmergencyAccess, error) {
+	var grants []*models.EmergencyAccess
+	if err := er.db.Where("grantor_id = ?", grantorID).Order("id").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to get emergency access by %s: %w", grantorID, err)
+	}
+	return grants, nil
+}
+
+// GrantedTo lists the emergency access other users gave the grantee,
+// without the sealed keys: those are only served by Access, once approved.
+func (er *emergencyRepo) GrantedTo(granteeID string) ([]*models.EmergencyAccess, error) {
+	var grants []*models.EmergencyAccess
+	if err := er.db.Where("grantee_id = ?", granteeID).Order("id").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to get emergency access to %s: %w", granteeID, err)
+	}
+	for _, access := range grants {
+		access.Key = nil
+	}
+	return grants, nil
+}
+
+// Pending counts the requests for emergency access to the grantor's vault
+// that are waiting for the wait period to pass.
+func (er *emergencyRepo) Pending(grantorID string) (int64, error) {
+	var n int64
+	err := er.db.Model(&models.EmergencyAccess{}).
+		Where("grantor_id = ? AND status = ?", grantorID, models.EmergencyRequested).
+		Count(&n).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count emergency access requests to %s: %w", grantorID, err)
+	}
+	return n, nil
+}
+
+// transition applies change to a grant in status from.
+func (er *emergencyRepo) transition(
+	where string,
+	userID string,
+	id uint,
+	from []string,
+	change func(access *models.EmergencyAccess),
+) (*models.EmergencyAccess, error) {
+	var access models.EmergencyAccess
+	err := er.db.Transaction(
+		func(tx *gorm.DB) error {
+			if err := tx.Where(where+" = ? AND id = ?", userID, id).First(&access).Error; err != nil {
+				return err
+			}
+			allowed := false
+			for _, status := range from {
+				allowed = allowed || access.Status == status
+			}
+			if !allowed {
+				return ErrEmergencyState
+			}
+			change(&access)
+			return tx.Select("status", "requested_at", "recovery_at").Save(&access).Error
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// Request asks for emergency access. It is granted at now plus the wait
+// period unless the grantor rejects it before.
+func (er *emergencyRepo) Request(granteeID string, id uint, now time.Time) (*models.EmergencyAccess, error) {
+	access, err := er.transition(
+		"grantee_id", granteeID, id, []string{models.EmergencyGranted},
+		func(access *models.EmergencyAccess) {
+			recoveryAt := now.Add(time.Duration(access.WaitDays) * 24 * time.Hour)
+			access.Status = models.EmergencyRequested
+			access.RequestedAt = &now
+			access.RecoveryAt = &recoveryAt
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request emergency access %d: %w", id, err)
+	}
+	return access, nil
+}
+
+// Reject turns down a pending request or ends approved access; the grantee
+// may request again.
+func (er *emergencyRepo) Reject(grantorID string, id uint) error {
+	_, err := er.transition(
+		"grantor_id", grantorID, id, []string{models.EmergencyRequested, models.EmergencyApproved},
+		func(access *models.EmergencyAccess) {
+			access.Status = models.EmergencyGranted
+			access.RequestedAt = nil
+			access.RecoveryAt = nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reject emergency access %d: %w", id, err)
+	}
+	return nil
+}
+
+// Approve grants a pending request without waiting.
+func (er *emergencyRepo) Approve(grantorID string, id uint) error {
+	_, err := er.transition(
+		"grantor_id", grantorID, id, []string{models.EmergencyRequested},
+		func(access *models.EmergencyAccess) {
+			access.Status = models.EmergencyApproved
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to approve emergency access %d: %w", id, err)
+	}
+	return nil
+}
+
+func (er *emergencyRepo) Revoke(grantorID string, id uint) error {
+	res := er.db.Where("grantor_id = ? AND id = ?", grantorID, id).Delete(&models.EmergencyAccess{})
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if res.Error != nil {
+		return fmt.Errorf("failed to revoke emergency access %d: %w", id, res.Error)
+	}
+	return nil
+}
+
+// Access returns a grant of the grantee that is approved, or whose request
+// is due by now even if the janitor has yet to approve it.
+func (er *emergencyRepo) Access(granteeID string, id uint, now time.Time) (*models.EmergencyAccess, error) {
+	var access models.EmergencyAccess
+	err := er.db.Where("grantee_id = ? AND id = ?", granteeID, id).First(&access).Error
+	if err == nil && !approved(&access, now) {
+		err = ErrEmergencyNotApproved
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to access emergency access %d: %w", id, err)
+	}
+	return &access, nil
+}
+
+func approved(access *models.EmergencyAccess, now time.Time) bool {
+	switch access.Status {
+	case models.EmergencyApproved:
+		return true
+	case models.EmergencyRequested:
+		return access.RecoveryAt != nil && !access.RecoveryAt.After(now)
+	}
+	return false
+}
+
+// ApproveEmergencyAccess approves the requests for emergency access whose
+// wait period is over by now and returns how many it approved.
+func ApproveEmergencyAccess(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Model(&models.EmergencyAccess{}).
+		Where("status = ? AND recovery_at <= ?", models.EmergencyRequested, now).
+		Update("status", models.EmergencyApproved)
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to approve emergency access: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
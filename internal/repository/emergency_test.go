@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestEmergencyRepo(t *testing.T) {
+	db := setupTestDB()
+	er := NewEmergencyRepo(db)
+	now := time.Now()
+	access := &models.EmergencyAccess{
+		GrantorID: "emgrantor",
+		GranteeID: "emgrantee",
+		Type:      models.EmergencyView,
+		WaitDays:  2,
+		Key:       []byte("sealed key"),
+	}
+	require.NoError(t, er.Grant(access))
+	id := access.ID
+
+	_, err := er.Access("emgrantee", id, now)
+	assert.ErrorIs(t, err, ErrEmergencyNotApproved)
+	assert.ErrorIs(t, er.Reject("emgrantor", id), ErrEmergencyState)
+	_, err = er.Request("emgrantor", id, now)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	requested, err := er.Request("emgrantee", id, now)
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(48*time.Hour), *requested.RecoveryAt, time.Second)
+	_, err = er.Request("emgrantee", id, now)
+	assert.ErrorIs(t, err, ErrEmergencyState)
+	grants, err := er.GrantedBy("emgrantor")
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, models.EmergencyRequested, grants[0].Status)
+	pending, err := er.Pending("emgrantor")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+
+	require.NoError(t, er.Reject("emgrantor", id))
+	grants, err = er.GrantedTo("emgrantee")
+	require.NoError(t, err)
+	assert.Equal(t, models.EmergencyGranted, grants[0].Status)
+	assert.Nil(t, grants[0].RecoveryAt)
+	assert.Nil(t, grants[0].Key, "the key is only served by Access")
+	pending, err = er.Pending("emgrantor")
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+
+	_, err = er.Request("emgrantee", id, now)
+	require.NoError(t, err)
+	_, err = er.Access("emgrantee", id, now.Add(47*time.Hour))
+	assert.ErrorIs(t, err, ErrEmergencyNotApproved)
+	got, err := er.Access("emgrantee", id, now.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sealed key"), got.Key)
+
+	n, err := ApproveEmergencyAccess(db, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = ApproveEmergencyAccess(db, now.Add(49*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = er.Access("emgrantee", id, now)
+	require.NoError(t, err)
+
+	again := &models.EmergencyAccess{
+		GrantorID: "emgrantor",
+		GranteeID: "emgrantee",
+		Type:      models.EmergencyTakeover,
+		WaitDays:  0,
+		Key:       []byte("new key"),
+	}
+	require.NoError(t, er.Grant(again))
+	assert.Equal(t, id, again.ID)
+	_, err = er.Access("emgrantee", id, now)
+	assert.ErrorIs(t, err, ErrEmergencyNotApproved)
+	_, err = er.Request("emgrantee", id, now)
+	require.NoError(t, err)
+	_, err = er.Access("emgrantee", id, now)
+	require.NoError(t, err)
+
+	require.NoError(t, er.Approve("emgrantor", id))
+	assert.ErrorIs(t, er.Approve("emgrantor", id), ErrEmergencyState)
+
+	assert.ErrorIs(t, er.Revoke("emgrantee", id), gorm.ErrRecordNotFound)
+	require.NoError(t, er.Revoke("emgrantor", id))
+	_, err = er.Access("emgrantee", id, now)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+)
+
+type FolderRepo interface {
+	GetFolders(userID string) ([]*models.Folder, error)
+	SaveNewFolder(*models.Folder) error
+	UpdateFolder(userID string, id uint, change models.FolderChange) error
+	DeleteFolder(userID string, id uint) error
+}
+
+type FoldersRepo struct {
+	db *gorm.DB
+}
+
+func NewFolderRepo(db *gorm.DB) *FoldersRepo {
+	return &FoldersRepo{db: db}
+}
+
+func (fr *FoldersRepo) GetFolders(userID string) ([]*models.Folder, error) {
+	var folders []*models.Folder
+	if err := fr.db.Where("user_id = ?", userID).Order("id").Find(&folders).Error; err != nil {
+		return nil, fmt.Errorf("failed to get folders by userID %s: %w", userID, err)
+	}
+	return folders, nil
+}
+
+// SaveNewFolder creates a folder; its parent must belong to the same user.
+func (fr *FoldersRepo) SaveNewFolder(folder *models.Folder) error {
+	err := fr.db.Transaction(
+		func(tx *gorm.DB) error {
+			if folder.ParentID != nil {
+				if err := checkFolder(tx, folder.UserID, *folder.ParentID); err != nil {
+					return err
+				}
+			}
+			return tx.Create(folder).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add new folder: %w", err)
+	}
+	return nil
+}
+
+// UpdateFolder renames or moves a folder. A folder cannot be moved below
+// itself.
+func (fr *FoldersRepo) UpdateFolder(userID string, id uint, change models.FolderChange) error {
+	err := fr.db.Transaction(
+		func(tx *gorm.DB) error {
+			var folder models.Folder
+			if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&folder).Error; err != nil {
+				return err
+			}
+			updates := map[string]interface{}{}
+			if change.Name != nil {
+				updates["name"] = *change.Name
+			}
+			if change.ParentID != nil {
+				if *change.ParentID == 0 {
+					updates["parent_id"] = nil
+				} else {
+					tree, err := folderTree(tx, userID, id)
+					if err != nil {
+						return err
+					}
+					for _, sub := range tree {
+						if sub == *change.ParentID {
+							return ErrFolderCycle
+						}
+					}
+					if err := checkFolder(tx, userID, *change.ParentID); err != nil {
+						return err
+					}
+					updates["parent_id"] = *change.ParentID
+				}
+			}
+			if len(updates) == 0 {
+				return nil
+			}
+			return tx.Model(&folder).Updates(updates).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update folder %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteFolder removes a folder. Its subfolders and items move to its
+// parent.
+func (fr *FoldersRepo) DeleteFolder(userID string, id uint) error {
+	err := fr.db.Transaction(
+		func(tx *gorm.DB) error {
+			var folder models.Folder
+			if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&folder).Error; err != nil {
+				return err
+			}
+			err := tx.Model(&models.Folder{}).
+				Where("user_id = ? AND parent_id = ?", userID, id).
+				Update("parent_id", folder.ParentID).Error
+			if err != nil {
+				return err
+			}
+			for _, model := range VaultModels() {
+				err := tx.Model(model).
+					Where("user_id = ? AND folder_id = ?", userID, id).
+					Update("folder_id", folder.ParentID).Error
+				if err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&folder).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete folder %d: %w", id, err)
+	}
+	return nil
+}
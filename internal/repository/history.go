@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+	"reflect"
+	"time"
+)
+
+// Sealer encrypts an item before it is stored as a version.
+type Sealer func(v interface{}) ([]byte, error)
+
+// Retention limits the versions kept of each item: only the newest
+// MaxVersions and those younger than MaxAge. Zero values keep all.
+type Retention struct {
+	MaxVersions int
+	MaxAge      time.Duration
+}
+
+// SetRetention sets the limits applied to the versions of r's items.
+func (r *Repo[T]) SetRetention(ret Retention) {
+	r.retention = ret
+}
+
+// Update replaces the contents of one of the user's items with those of
+// item and keeps the previous contents, sealed by seal, as a version. The
+// item's metadata is left as it is; search tokens set on item replace its
+// blind index.
+func (r *Repo[T]) Update(userID string, id uint, item *T, seal Sealer) error {
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			prev := new(T)
+			if err := tx.Where(liveItem, userID, id).First(prev).Error; err != nil {
+				return err
+			}
+			table, err := tableOf(tx, prev)
+			if err != nil {
+				return err
+			}
+			if err := saveVersion(tx, userID, table, id, prev, seal, r.retention); err != nil {
+				return err
+			}
+
+			keepIdentity(item, prev)
+			if err := tx.Save(item).Error; err != nil {
+				return err
+			}
+			if meta, ok := any(item).(metaHolder); ok && meta.Meta().SearchTokens != nil {
+				return saveSearchTokens(tx, userID, table, id, meta.Meta().SearchTokens)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update %s %d: %w", r.name, id, err)
+	}
+	return nil
+}
+
+// Versions lists the kept versions of one of the user's items, newest
+// first.
+func (r *Repo[T]) Versions(userID string, id uint) ([]*models.ItemVersion, error) {
+	var versions []*models.ItemVersion
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			item := new(T)
+			if err := tx.Where(liveItem, userID, id).First(item).Error; err != nil {
+				return err
+			}
+			table, err := tableOf(tx, item)
+			if err != nil {
+				return err
+			}
+			if err := pruneVersions(tx, table, id, r.retention); err != nil {
+				return err
+			}
+			return tx.Where("user_id = ? AND item_type = ? AND item_id = ?", userID, table, id).
+				Order("id DESC").
+				Find(&versions).Error
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %d history: %w", r.name, id, err)
+	}
+	return versions, nil
+}
+
+// Version returns one version of one of the user's items.
+func (r *Repo[T]) Version(userID string, id, version uint) (*models.ItemVersion, error) {
+	v := &models.ItemVersion{}
+	err := func() error {
+		table, err := r.table()
+		if err != nil {
+			return err
+		}
+		return r.db.Where(
+			"user_id = ? AND item_type = ? AND item_id = ? AND id = ?",
+			userID, table, id, version,
+		).First(v).Error
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %d version %d: %w", r.name, id, version, err)
+	}
+	return v, nil
+}
+
+// saveVersion stores prev as a version and applies ret.
+func saveVersion(
+	tx *gorm.DB,
+	userID, table string,
+	itemID uint,
+	prev interface{},
+	seal Sealer,
+	ret Retention,
+) error {
+	data, err := seal(prev)
+	if err != nil {
+		return err
+	}
+	v := &models.ItemVersion{UserID: userID, ItemType: table, ItemID: itemID, Data: data}
+	if err := tx.Create(v).Error; err != nil {
+		return err
+	}
+	return pruneVersions(tx, table, itemID, ret)
+}
+
+func pruneVersions(tx *gorm.DB, table string, itemID uint, ret Retention) error {
+	versions := func() *gorm.DB {
+		return tx.Model(&models.ItemVersion{}).Where("item_type = ? AND item_id = ?", table, itemID)
+	}
+	if ret.MaxAge > 0 {
+		err := versions().Where("created_at < ?", time.Now().Add(-ret.MaxAge)).
+			Delete(&models.ItemVersion{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	if ret.MaxVersions > 0 {
+		var ids []uint
+		if err := versions().Order("id DESC").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) > ret.MaxVersions {
+			return tx.Delete(&models.ItemVersion{}, ids[ret.MaxVersions:]).Error
+		}
+	}
+	return nil
+}
+
+// keepIdentity copies the identity of prev to item.
+func keepIdentity(item, prev interface{}) {
+	dst, src := reflect.ValueOf(item).Elem(), reflect.ValueOf(prev).Elem()
+	for _, name := range []string{"Model", "UserID"} {
+		dst.FieldByName(name).Set(src.FieldByName(name))
+	}
+	if meta, ok := item.(metaHolder); ok {
+		m, pm := meta.Meta(), prev.(metaHolder).Meta()
+		m.DisplayName, m.FolderID, m.Favorite = pm.DisplayName, pm.FolderID, pm.Favorite
+	}
+}
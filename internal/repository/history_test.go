@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"encoding/json"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func plainSeal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func TestRepo_History(t *testing.T) {
+	db := setupTestDB()
+	repo := NewLPRepo(db)
+
+	lp := &models.LoginPassword{
+		UserID:   "historyuser",
+		Login:    "alice",
+		Password: "first",
+		ItemMeta: models.ItemMeta{DisplayName: "Mail", Favorite: true, Tags: []string{"work"}},
+	}
+	require.NoError(t, repo.Save(lp))
+
+	update := &models.LoginPassword{Login: "alice", Password: "second"}
+	require.NoError(t, repo.Update("historyuser", lp.ID, update, plainSeal))
+	got, err := repo.Get("historyuser", lp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.Password)
+	assert.Equal(t, "Mail", got.DisplayName)
+	assert.True(t, got.Favorite)
+	assert.Equal(t, []string{"work"}, got.Tags)
+	assert.Equal(t, lp.CreatedAt.Unix(), got.CreatedAt.Unix())
+
+	require.NoError(
+		t, repo.Update("historyuser", lp.ID, &models.LoginPassword{Password: "third"}, plainSeal),
+	)
+	versions, err := repo.Versions("historyuser", lp.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	var newest models.LoginPassword
+	require.NoError(t, json.Unmarshal(versions[0].Data, &newest))
+	assert.Equal(t, "second", newest.Password)
+
+	v, err := repo.Version("historyuser", lp.ID, versions[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "login_passwords", v.ItemType)
+
+	_, err = repo.Version("otheruser", lp.ID, versions[1].ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = repo.Versions("otheruser", lp.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.Update("otheruser", lp.ID, update, plainSeal), gorm.ErrRecordNotFound)
+}
+
+func TestRepo_HistoryRetention(t *testing.T) {
+	db := setupTestDB()
+	repo := NewTDRepo(db)
+	repo.SetRetention(Retention{MaxVersions: 2, MaxAge: 24 * time.Hour})
+
+	td := &models.TextData{UserID: "retentionuser", Content: "v0"}
+	require.NoError(t, repo.Save(td))
+	for _, content := range []string{"v1", "v2", "v3"} {
+		require.NoError(
+			t, repo.Update("retentionuser", td.ID, &models.TextData{Content: content}, plainSeal),
+		)
+	}
+	versions, err := repo.Versions("retentionuser", td.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, db.Model(versions[1]).Update("created_at", old).Error)
+	versions, err = repo.Versions("retentionuser", td.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+func TestItemsRepo_PurgeRemovesHistory(t *testing.T) {
+	db := setupTestDB()
+	ir := NewItemRepo(db)
+
+	it := &models.Item{UserID: "historyitemuser", Template: "custom", Name: "One"}
+	require.NoError(t, ir.Save(it))
+	it.Name = "Two"
+	require.NoError(t, ir.Update("historyitemuser", it.ID, it, plainSeal))
+	require.NoError(t, ir.Delete("historyitemuser", it.ID))
+	require.NoError(t, ir.Purge("historyitemuser", it.ID))
+
+	var count int64
+	db.Model(&models.ItemVersion{}).Where("item_type = ? AND item_id = ?", "items", it.ID).Count(&count)
+	assert.Zero(t, count)
+}
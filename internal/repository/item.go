@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+)
+
+type ItemTemplateRepo interface {
+	GetItemTemplates(userID string) ([]*models.ItemTemplate, error)
+	GetItemTemplate(userID, name string) (*models.ItemTemplate, error)
+	SaveNewItemTemplate(*models.ItemTemplate) error
+}
+
+type TemplateRepo struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepo(db *gorm.DB) *TemplateRepo {
+	return &TemplateRepo{db: db}
+}
+
+func (tr *TemplateRepo) GetItemTemplates(userID string) ([]*models.ItemTemplate, error) {
+	var templates []*models.ItemTemplate
+	if err := tr.db.Where("user_id = ?", userID).Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get item templates by userID %s: %w", userID, err)
+	}
+	return templates, nil
+}
+
+func (tr *TemplateRepo) GetItemTemplate(userID, name string) (*models.ItemTemplate, error) {
+	var template models.ItemTemplate
+	if err := tr.db.Where("user_id = ? AND name = ?", userID, name).First(&template).Error; err != nil {
+		return nil, fmt.Errorf("failed to get item template %s: %w", name, err)
+	}
+	return &template, nil
+}
+
+func (tr *TemplateRepo) SaveNewItemTemplate(template *models.ItemTemplate) error {
+	if err := tr.db.Create(template).Error; err != nil {
+		return fmt.Errorf("failed to add new item template: %w", err)
+	}
+	return nil
+}
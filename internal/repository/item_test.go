@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestItemsRepo_CRUD(t *testing.T) {
+	ir := NewItemRepo(setupTestDB())
+
+	apiKey := &models.Item{
+		UserID:   "itemuser",
+		Template: "api-key",
+		Name:     "Stripe",
+		Fields: []models.ItemField{
+			{Name: "key", Type: "concealed", Value: "sk_live_123", Required: true},
+		},
+	}
+	license := &models.Item{
+		UserID:   "itemuser",
+		Template: "software-license",
+		Name:     "IDE",
+		Fields:   []models.ItemField{{Name: "product", Type: "text", Value: "GoLand"}},
+	}
+	require.NoError(t, ir.Save(apiKey))
+	require.NoError(t, ir.Save(license))
+
+	all, err := ir.Page("itemuser", ListFilter{}, Page{})
+	require.NoError(t, err)
+	assert.Len(t, all.Items, 2)
+	assert.Equal(t, int64(2), all.Total)
+
+	filtered, err := ir.Page("itemuser", ListFilter{Template: "api-key"}, Page{})
+	require.NoError(t, err)
+	if assert.Len(t, filtered.Items, 1) {
+		assert.Equal(t, apiKey.Fields, filtered.Items[0].Fields)
+	}
+
+	_, err = NewLPRepo(ir.db).Page("itemuser", ListFilter{Template: "api-key"}, Page{})
+	assert.ErrorIs(t, err, ErrTemplateFilter)
+
+	byName, err := ir.Page("itemuser", ListFilter{}, Page{Sort: SortName, Limit: 1})
+	require.NoError(t, err)
+	if assert.Len(t, byName.Items, 1) {
+		assert.Equal(t, "IDE", byName.Items[0].Name)
+		assert.NotEmpty(t, byName.NextCursor)
+	}
+
+	got, err := ir.Get("itemuser", apiKey.ID)
+	require.NoError(t, err)
+	got.Name = "Stripe prod"
+	require.NoError(t, ir.Update("itemuser", apiKey.ID, got, plainSeal))
+	got, err = ir.Get("itemuser", apiKey.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Stripe prod", got.Name)
+
+	_, err = ir.Get("otheruser", apiKey.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, ir.Delete("otheruser", apiKey.ID), gorm.ErrRecordNotFound)
+
+	require.NoError(t, ir.Delete("itemuser", apiKey.ID))
+	_, err = ir.Get("itemuser", apiKey.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestTemplateRepo(t *testing.T) {
+	tr := NewTemplateRepo(setupTestDB())
+
+	wifi := &models.ItemTemplate{
+		UserID: "templateuser",
+		Name:   "wifi",
+		Fields: []models.ItemField{{Name: "ssid", Type: "text", Required: true}},
+	}
+	require.NoError(t, tr.SaveNewItemTemplate(wifi))
+	assert.Error(
+		t, tr.SaveNewItemTemplate(&models.ItemTemplate{UserID: "templateuser", Name: "wifi"}),
+	)
+
+	templates, err := tr.GetItemTemplates("templateuser")
+	require.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	got, err := tr.GetItemTemplate("templateuser", "wifi")
+	require.NoError(t, err)
+	assert.Equal(t, wifi.Fields, got.Fields)
+
+	_, err = tr.GetItemTemplate("otheruser", "wifi")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -9,7 +9,7 @@ import (
 	"testing"
 )
 
-func TestLPRepo_GetLoginPasswordData(t *testing.T) {
+func TestLPRepo_List(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -77,11 +77,9 @@ func TestLPRepo_GetLoginPasswordData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				lp := &LPRepo{
-					db: tt.fields.db,
-				}
-				got, err := lp.GetLoginPasswordData(tt.args.userID)
-				if !tt.wantErr(t, err, fmt.Sprintf("GetLoginPasswordData(%v)", tt.args.userID)) {
+				lp := NewLPRepo(tt.fields.db)
+				got, err := lp.List(tt.args.userID, ListFilter{})
+				if !tt.wantErr(t, err, fmt.Sprintf("List(%v)", tt.args.userID)) {
 					return
 				}
 				for i := range got {
@@ -89,13 +87,13 @@ func TestLPRepo_GetLoginPasswordData(t *testing.T) {
 					tt.want[i].CreatedAt = got[i].CreatedAt
 					tt.want[i].UpdatedAt = got[i].UpdatedAt
 				}
-				assert.Equalf(t, tt.want, got, "GetLoginPasswordData(%v)", tt.args.userID)
+				assert.Equalf(t, tt.want, got, "List(%v)", tt.args.userID)
 			},
 		)
 	}
 }
 
-func TestLPRepo_SaveNewLoginPassword(t *testing.T) {
+func TestLPRepo_Save(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -127,13 +125,11 @@ func TestLPRepo_SaveNewLoginPassword(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				lp := &LPRepo{
-					db: tt.fields.db,
-				}
+				lp := NewLPRepo(tt.fields.db)
 				tt.wantErr(
 					t,
-					lp.SaveNewLoginPassword(tt.args.logPass),
-					fmt.Sprintf("SaveNewLoginPassword(%v)", tt.args.logPass),
+					lp.Save(tt.args.logPass),
+					fmt.Sprintf("Save(%v)", tt.args.logPass),
 				)
 			},
 		)
@@ -147,14 +143,14 @@ func TestNewLPRepo(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want *LPRepo
+		want *Repo[models.LoginPassword]
 	}{
 		{
 			name: "Valid DB instance",
 			args: args{
 				db: &gorm.DB{},
 			},
-			want: &LPRepo{db: &gorm.DB{}},
+			want: &Repo[models.LoginPassword]{db: &gorm.DB{}, name: "login-password"},
 		},
 	}
 	for _, tt := range tests {
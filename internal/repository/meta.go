@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFolderNotFound = errors.New("folder not found")
+	ErrFolderCycle    = errors.New("folder cannot be moved into itself")
+	ErrTagExists      = errors.New("tag already exists")
+	ErrTemplateFilter = errors.New("only items can be filtered by template")
+)
+
+// VaultModels lists every model that embeds models.ItemMeta.
+func VaultModels() []interface{} {
+	return []interface{}{
+		&models.LoginPassword{},
+		&models.TextData{},
+		&models.BinaryData{},
+		&models.CreditCard{},
+		&models.OTPSecret{},
+		&models.SSHKey{},
+		&models.Item{},
+	}
+}
+
+// ListFilter narrows list queries; zero values do not filter.
+type ListFilter struct {
+	// Tags must all be present on an item.
+	Tags []string
+	// FolderID matches items in the folder and all of its subfolders.
+	FolderID *uint
+	Favorite *bool
+	// SearchTokens must all be in an item's blind index.
+	SearchTokens []string
+	// IDs limits the result to these items when not nil.
+	IDs []uint
+	// Template matches items of one template; other types have none.
+	Template string
+
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+}
+
+type metaHolder interface {
+	Meta() *models.ItemMeta
+}
+
+func tableOf(db *gorm.DB, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
+
+// IDOf returns the ID of a pointer to a gorm model.
+func IDOf(item interface{}) uint {
+	return uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+}
+
+// NormalizeTags trims, lower-cases, drops empty and duplicate tags and sorts
+// the rest.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// checkFolder verifies that a folder exists and belongs to the user.
+func checkFolder(db *gorm.DB, userID string, folderID uint) error {
+	var count int64
+	err := db.Model(&models.Folder{}).
+		Where("user_id = ? AND id = ?", userID, folderID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrFolderNotFound, folderID)
+	}
+	return nil
+}
+
+// folderTree returns the folder and the IDs of all folders below it.
+func folderTree(db *gorm.DB, userID string, folderID uint) ([]uint, error) {
+	if err := checkFolder(db, userID, folderID); err != nil {
+		return nil, err
+	}
+	ids := []uint{folderID}
+	level := []uint{folderID}
+	for len(level) > 0 {
+		var children []uint
+		err := db.Model(&models.Folder{}).
+			Where("user_id = ? AND parent_id IN ?", userID, level).
+			Pluck("id", &children).Error
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, children...)
+		level = children
+	}
+	return ids, nil
+}
+
+func applyFilter(
+	db *gorm.DB,
+	query *gorm.DB,
+	model interface{},
+	table, userID string,
+	f ListFilter,
+) (*gorm.DB, error) {
+	if f.Favorite != nil {
+		query = query.Where("favorite = ?", *f.Favorite)
+	}
+	if f.FolderID != nil {
+		ids, err := folderTree(db, userID, *f.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("folder_id IN ?", ids)
+	}
+	if f.CreatedAfter != nil {
+		query = query.Where("created_at > ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *f.CreatedBefore)
+	}
+	if f.UpdatedAfter != nil {
+		query = query.Where("updated_at > ?", *f.UpdatedAfter)
+	}
+	if f.UpdatedBefore != nil {
+		query = query.Where("updated_at < ?", *f.UpdatedBefore)
+	}
+	if f.IDs != nil {
+		query = query.Where("id IN ?", f.IDs)
+	}
+	if f.Template != "" {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		if stmt.Schema.LookUpField("template") == nil {
+			return nil, ErrTemplateFilter
+		}
+		query = query.Where("template = ?", f.Template)
+	}
+	if tokens := uniqueTokens(f.SearchTokens); len(tokens) > 0 {
+		matched := db.Model(&models.SearchToken{}).
+			Select("item_id").
+			Where("item_type = ? AND user_id = ? AND token IN ?", table, userID, tokens).
+			Group("item_id").
+			Having("COUNT(DISTINCT token) = ?", len(tokens))
+		query = query.Where("id IN (?)", matched)
+	}
+	if tags := NormalizeTags(f.Tags); len(tags) > 0 {
+		tagged := db.Table("item_tags").
+			Select("item_tags.item_id").
+			Joins("JOIN tags ON tags.id = item_tags.tag_id").
+			Where("item_tags.item_type = ? AND tags.user_id = ? AND tags.name IN ?", table, userID, tags).
+			Group("item_tags.item_id").
+			Having("COUNT(DISTINCT tags.id) = ?", len(tags))
+		query = query.Where("id IN (?)", tagged)
+	}
+	return query, nil
+}
+
+// loadTags fills ItemMeta.Tags of the given items.
+func loadTags[T any](db *gorm.DB, table string, items []*T) error {
+	if len(items) == 0 {
+		return nil
+	}
+	byID := make(map[uint]*models.ItemMeta, len(items))
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		id := IDOf(item)
+		byID[id] = any(item).(metaHolder).Meta()
+		ids = append(ids, id)
+	}
+
+	var rows []struct {
+		ItemID uint
+		Name   string
+	}
+	err := db.Table("item_tags").
+		Select("item_tags.item_id, tags.name").
+		Joins("JOIN tags ON tags.id = item_tags.tag_id").
+		Where("item_tags.item_type = ? AND item_tags.item_id IN ?", table, ids).
+		Order("tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		meta := byID[row.ItemID]
+		meta.Tags = append(meta.Tags, row.Name)
+	}
+	return nil
+}
+
+// saveTags replaces the tags of an item, creating missing tags.
+func saveTags(tx *gorm.DB, userID, table string, itemID uint, tags []string) error {
+	err := tx.Where("item_type = ? AND item_id = ?", table, itemID).
+		Delete(&models.ItemTag{}).Error
+	if err != nil {
+		return err
+	}
+	for _, name := range NormalizeTags(tags) {
+		tag := models.Tag{UserID: userID, Name: name}
+		if err := tx.Where(&tag).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		link := models.ItemTag{ItemType: table, ItemID: itemID, TagID: tag.ID}
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uniqueTokens(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	var out []string
+	for _, t := range tokens {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// saveSearchTokens replaces the blind index of an item.
+func saveSearchTokens(tx *gorm.DB, userID, table string, itemID uint, tokens []string) error {
+	err := tx.Where("item_type = ? AND item_id = ?", table, itemID).
+		Delete(&models.SearchToken{}).Error
+	if err != nil {
+		return err
+	}
+	tokens = uniqueTokens(tokens)
+	if len(tokens) == 0 {
+		return nil
+	}
+	rows := make([]models.SearchToken, 0, len(tokens))
+	for _, t := range tokens {
+		rows = append(rows, models.SearchToken{ItemType: table, ItemID: itemID, Token: t, UserID: userID})
+	}
+	return tx.CreateInBatches(rows, 100).Error
+}
+
+// deleteItemMeta removes the metadata of deleted items.
+func deleteItemMeta(tx *gorm.DB, table string, itemIDs ...uint) error {
+	for _, model := range []interface{}{&models.ItemTag{}, &models.SearchToken{}, &models.ItemVersion{}} {
+		err := tx.Where("item_type = ? AND item_id IN ?", table, itemIDs).Delete(model).Error
+		if err != nil {
+			return err
+		}
+	}
+	if route, ok := shareTypes[table]; ok {
+		return tx.Unscoped().Where("item_type = ? AND item_id IN ?", route, itemIDs).Delete(&models.Share{}).Error
+	}
+	return nil
+}
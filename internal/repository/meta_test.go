@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, []string{"bank", "work"}, NormalizeTags([]string{" Work", "bank", "", "WORK"}))
+	assert.Nil(t, NormalizeTags(nil))
+}
+
+func TestRepo_TagsFoldersAndFilters(t *testing.T) {
+	db := setupTestDB()
+	folders := NewFolderRepo(db)
+	td := NewTDRepo(db)
+
+	root := &models.Folder{UserID: "metauser", Name: "Work"}
+	require.NoError(t, folders.SaveNewFolder(root))
+	sub := &models.Folder{UserID: "metauser", Name: "Servers", ParentID: &root.ID}
+	require.NoError(t, folders.SaveNewFolder(sub))
+
+	note := &models.TextData{UserID: "metauser", Content: "a", ItemMeta: models.ItemMeta{
+		DisplayName: "Deploy notes",
+		FolderID:    &sub.ID,
+		Tags:        []string{"Ops", "prod"},
+	}}
+	require.NoError(t, td.Save(note))
+	assert.Equal(t, []string{"ops", "prod"}, note.Tags)
+	other := &models.TextData{UserID: "metauser", Content: "b", ItemMeta: models.ItemMeta{
+		Favorite: true,
+		Tags:     []string{"ops"},
+	}}
+	require.NoError(t, td.Save(other))
+
+	got, err := td.List("metauser", ListFilter{})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, []string{"ops", "prod"}, got[0].Tags)
+	assert.Equal(t, "Deploy notes", got[0].DisplayName)
+
+	got, err = td.List("metauser", ListFilter{Tags: []string{"OPS", "prod"}})
+	require.NoError(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, note.ID, got[0].ID)
+	}
+
+	got, err = td.List("metauser", ListFilter{FolderID: &root.ID})
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+
+	favorite := true
+	got, err = td.List("metauser", ListFilter{Favorite: &favorite})
+	require.NoError(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, other.ID, got[0].ID)
+	}
+
+	_, err = td.List("otheruser", ListFilter{FolderID: &root.ID})
+	assert.ErrorIs(t, err, ErrFolderNotFound)
+	err = td.Save(&models.TextData{UserID: "otheruser", ItemMeta: models.ItemMeta{FolderID: &root.ID}})
+	assert.ErrorIs(t, err, ErrFolderNotFound)
+
+	name := "Renamed"
+	noFolder := uint(0)
+	tags := []string{"archive"}
+	require.NoError(
+		t, td.Organize(
+			"metauser", note.ID,
+			models.MetaChange{DisplayName: &name, FolderID: &noFolder, Tags: &tags},
+		),
+	)
+	updated, err := td.Get("metauser", note.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", updated.DisplayName)
+	assert.Nil(t, updated.FolderID)
+	assert.Equal(t, []string{"archive"}, updated.Tags)
+	assert.Equal(t, "a", updated.Content)
+
+	assert.ErrorIs(
+		t, td.Organize("otheruser", note.ID, models.MetaChange{DisplayName: &name}),
+		gorm.ErrRecordNotFound,
+	)
+}
+
+func TestFolderRepo(t *testing.T) {
+	db := setupTestDB()
+	folders := NewFolderRepo(db)
+	lp := NewLPRepo(db)
+
+	parent := &models.Folder{UserID: "folderuser", Name: "Personal"}
+	require.NoError(t, folders.SaveNewFolder(parent))
+	child := &models.Folder{UserID: "folderuser", Name: "Banks", ParentID: &parent.ID}
+	require.NoError(t, folders.SaveNewFolder(child))
+	grandchild := &models.Folder{UserID: "folderuser", Name: "Old", ParentID: &child.ID}
+	require.NoError(t, folders.SaveNewFolder(grandchild))
+
+	assert.ErrorIs(
+		t, folders.SaveNewFolder(&models.Folder{UserID: "otheruser", ParentID: &parent.ID}),
+		ErrFolderNotFound,
+	)
+	assert.ErrorIs(
+		t, folders.UpdateFolder(
+			"folderuser", parent.ID, models.FolderChange{ParentID: &grandchild.ID},
+		),
+		ErrFolderCycle,
+	)
+
+	name := "Finance"
+	require.NoError(t, folders.UpdateFolder("folderuser", child.ID, models.FolderChange{Name: &name}))
+
+	login := &models.LoginPassword{
+		UserID: "folderuser", Login: "me", Password: "pw",
+		ItemMeta: models.ItemMeta{FolderID: &child.ID},
+	}
+	require.NoError(t, lp.Save(login))
+
+	require.NoError(t, folders.DeleteFolder("folderuser", child.ID))
+	got, err := folders.GetFolders("folderuser")
+	require.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "Personal", got[0].Name)
+		assert.Equal(t, &parent.ID, got[1].ParentID)
+	}
+	moved, err := lp.Get("folderuser", login.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &parent.ID, moved.FolderID)
+
+	assert.ErrorIs(t, folders.DeleteFolder("folderuser", child.ID), gorm.ErrRecordNotFound)
+}
+
+func TestTagRepo(t *testing.T) {
+	db := setupTestDB()
+	tags := NewTagRepo(db)
+	td := NewTDRepo(db)
+
+	note := &models.TextData{UserID: "taguser", ItemMeta: models.ItemMeta{Tags: []string{"home", "wifi"}}}
+	require.NoError(t, td.Save(note))
+
+	got, err := tags.GetTags("taguser")
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	assert.ErrorIs(t, tags.RenameTag("taguser", "home", "WiFi"), ErrTagExists)
+	require.NoError(t, tags.RenameTag("taguser", "Home", "house"))
+	require.NoError(t, tags.DeleteTag("taguser", "wifi"))
+	assert.ErrorIs(t, tags.DeleteTag("taguser", "wifi"), gorm.ErrRecordNotFound)
+
+	item, err := td.Get("taguser", note.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"house"}, item.Tags)
+}
+
+func TestRepo_SearchTokens(t *testing.T) {
+	lp := NewLPRepo(setupTestDB())
+
+	github := &models.LoginPassword{
+		UserID: "searchuser", Login: "octocat", Password: "pw",
+		ItemMeta: models.ItemMeta{SearchTokens: []string{"t-github", "t-octocat", "t-github"}},
+	}
+	require.NoError(t, lp.Save(github))
+	gitlab := &models.LoginPassword{
+		UserID: "searchuser", Login: "tanuki", Password: "pw",
+		ItemMeta: models.ItemMeta{SearchTokens: []string{"t-gitlab"}},
+	}
+	require.NoError(t, lp.Save(gitlab))
+
+	got, err := lp.List("searchuser", ListFilter{SearchTokens: []string{"t-github", "t-octocat"}})
+	require.NoError(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, github.ID, got[0].ID)
+		assert.Empty(t, got[0].SearchTokens)
+	}
+
+	got, err = lp.List("otheruser", ListFilter{SearchTokens: []string{"t-github"}})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	require.NoError(t, lp.Index("searchuser", gitlab.ID, []string{"t-github"}))
+	got, err = lp.List("searchuser", ListFilter{SearchTokens: []string{"t-github"}})
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+	got, err = lp.List("searchuser", ListFilter{SearchTokens: []string{"t-gitlab"}})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	got, err = lp.List("searchuser", ListFilter{IDs: []uint{gitlab.ID}})
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+
+	assert.ErrorIs(t, lp.Index("otheruser", gitlab.ID, nil), gorm.ErrRecordNotFound)
+}
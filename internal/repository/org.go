@@ -0,0 +1,513 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrgPermission = errors.New("not allowed by the organization role")
+	ErrMemberExists  = errors.New("user is already a member of the organization")
+	ErrKeysMismatch  = errors.New("keys do not match the organization's members and collections")
+	ErrStaleKey      = errors.New("collection key or items have changed")
+)
+
+// roleRanks orders the organization roles.
+var roleRanks = map[string]int{
+	models.RoleReadOnly: 1,
+	models.RoleMember:   2,
+	models.RoleAdmin:    3,
+	models.RoleOwner:    4,
+}
+
+// OrgRepo stores organizations and their collections. Every method is
+// scoped by the requesting user's membership and role, as the item
+// repositories are scoped by user_id; organizations and collections the
+// user is not an accepted member of are not found.
+type OrgRepo interface {
+	Create(org *models.Organization, ownerID string) error
+	Memberships(userID string) ([]*models.OrgMember, error)
+	Invite(userID string, orgID uint, invite models.OrgInvite) error
+	Accept(userID string, orgID uint) error
+	Members(userID string, orgID uint) ([]*models.OrgMember, error)
+	Remove(userID string, orgID uint, memberID string, rotations []*models.CollectionRotation) error
+	AddCollection(userID string, collection *models.Collection, keys []*models.CollectionKey) error
+	Collections(userID string, orgID uint) ([]*models.Collection, error)
+	Items(userID string, collectionID uint) ([]*models.CollectionItem, error)
+	SaveItem(userID string, item *models.CollectionItem) error
+	UpdateItem(userID string, item *models.CollectionItem) error
+	DeleteItem(userID string, collectionID, id uint) error
+}
+
+type orgRepo struct {
+	db *gorm.DB
+}
+
+func NewOrgRepo(db *gorm.DB) *orgRepo {
+	return &orgRepo{db: db}
+}
+
+// member returns the user's membership if its role is at least min.
+func member(tx *gorm.DB, userID string, orgID uint, min string) (*models.OrgMember, error) {
+	var m models.OrgMember
+	err := tx.Where("org_id = ? AND user_id = ? AND accepted = ?", orgID, userID, true).First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	if roleRanks[m.Role] < roleRanks[min] {
+		return nil, ErrOrgPermission
+	}
+	return &m, nil
+}
+
+// collectionOf returns a collection if the user's role is at least min.
+func collectionOf(tx *gorm.DB, userID string, collectionID uint, min string) (*models.Collection, error) {
+	var c models.Collection
+	if err := tx.First(&c, collectionID).Error; err != nil {
+		return nil, err
+	}
+	if _, err := member(tx, userID, c.OrgID, min); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// checkKeys verifies that keys hold exactly one key for each user.
+func checkKeys(keys []*models.CollectionKey, userIDs []string) error {
+	want := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		want[id] = true
+	}
+	if len(keys) != len(want) {
+		return ErrKeysMismatch
+	}
+	for _, k := range keys {
+		if !want[k.UserID] || len(k.Key) == 0 {
+			return ErrKeysMismatch
+		}
+		delete(want, k.UserID)
+	}
+	return nil
+}
+
+// Create stores a new organization with ownerID as its owner.
+func (or *orgRepo) Create(org *models.Organization, ownerID string) error {
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			if err := tx.Create(org).Error; err != nil {
+				return err
+			}
+			return tx.Create(
+				&models.OrgMember{OrgID: org.ID, UserID: ownerID, Role: models.RoleOwner, Accepted: true},
+			).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create organization %s: %w", org.Name, err)
+	}
+	return nil
+}
+
+// Memberships lists the user's memberships and pending invitations.
+func (or *orgRepo) Memberships(userID string) ([]*models.OrgMember, error) {
+	var members []*models.OrgMember
+	err := func() error {
+		if err := or.db.Where("user_id = ?", userID).Order("org_id").Find(&members).Error; err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(members))
+		for i, m := range members {
+			ids[i] = m.OrgID
+		}
+		var orgs []*models.Organization
+		if err := or.db.Where("id IN ?", ids).Find(&orgs).Error; err != nil {
+			return err
+		}
+		names := make(map[uint]string, len(orgs))
+		for _, o := range orgs {
+			names[o.ID] = o.Name
+		}
+		for _, m := range members {
+			m.OrgName = names[m.OrgID]
+		}
+		return nil
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizations of %s: %w", userID, err)
+	}
+	return members, nil
+}
+
+// Invite adds a member that has yet to accept. Admins invite members and
+// read-only members, owners also admins. The invitation carries the key of
+// every collection of the organization.
+func (or *orgRepo) Invite(userID string, orgID uint, invite models.OrgInvite) error {
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			inviter, err := member(tx, userID, orgID, models.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			if roleRanks[invite.Role] >= roleRanks[inviter.Role] && inviter.Role != models.RoleOwner {
+				return ErrOrgPermission
+			}
+			var n int64
+			err = tx.Model(&models.OrgMember{}).
+				Where("org_id = ? AND user_id = ?", orgID, invite.UserID).
+				Count(&n).Error
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return ErrMemberExists
+			}
+
+			var collections []uint
+			if err := tx.Model(&models.Collection{}).Where("org_id = ?", orgID).Pluck("id", &collections).Error; err != nil {
+				return err
+			}
+			want := make(map[uint]bool, len(collections))
+			for _, id := range collections {
+				want[id] = true
+			}
+			if len(invite.Keys) != len(want) {
+				return ErrKeysMismatch
+			}
+			for _, k := range invite.Keys {
+				if !want[k.CollectionID] || len(k.Key) == 0 {
+					return ErrKeysMismatch
+				}
+				delete(want, k.CollectionID)
+				k.UserID = invite.UserID
+			}
+
+			err = tx.Create(
+				&models.OrgMember{OrgID: orgID, UserID: invite.UserID, Role: invite.Role},
+			).Error
+			if err != nil {
+				return err
+			}
+			if len(invite.Keys) == 0 {
+				return nil
+			}
+			return tx.Create(invite.Keys).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to invite %s to organization %d: %w", invite.UserID, orgID, err)
+	}
+	return nil
+}
+
+// Accept makes the user's invitation to an organization a membership.
+func (or *orgRepo) Accept(userID string, orgID uint) error {
+	res := or.db.Model(&models.OrgMember{}).
+		Where("org_id = ? AND user_id = ? AND accepted = ?", orgID, userID, false).
+		Update("accepted", true)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if res.Error != nil {
+		return fmt.Errorf("failed to accept organization %d: %w", orgID, res.Error)
+	}
+	return nil
+}
+
+// Members lists the members and invited users of an organization.
+func (or *orgRepo) Members(userID string, orgID uint) ([]*models.OrgMember, error) {
+	var members []*models.OrgMember
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			if _, err := member(tx, userID, orgID, models.RoleReadOnly); err != nil {
+				return err
+			}
+			return tx.Where("org_id = ?", orgID).Order("id").Find(&members).Error
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members of organization %d: %w", orgID, err)
+	}
+	return members, nil
+}
+
+// Remove deletes a member or invitation and rotates the key of every
+// collection, so that keys the removed user kept cannot open what is
+// stored from now on. rotations must hold, for each collection, the new key
+// sealed to every remaining member and all items sealed with it. Owners
+// cannot be removed and only owners remove admins.
+func (or *orgRepo) Remove(
+	userID string,
+	orgID uint,
+	memberID string,
+	rotations []*models.CollectionRotation,
+) error {
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			remover, err := member(tx, userID, orgID, models.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			var removed models.OrgMember
+			if err := tx.Where("org_id = ? AND user_id = ?", orgID, memberID).First(&removed).Error; err != nil {
+				return err
+			}
+			if removed.Role == models.RoleOwner ||
+				(removed.Role == models.RoleAdmin && remover.Role != models.RoleOwner) {
+				return ErrOrgPermission
+			}
+
+			var remaining []string
+			err = tx.Model(&models.OrgMember{}).
+				Where("org_id = ? AND user_id <> ?", orgID, memberID).
+				Pluck("user_id", &remaining).Error
+			if err != nil {
+				return err
+			}
+			var collections []*models.Collection
+			if err := tx.Where("org_id = ?", orgID).Find(&collections).Error; err != nil {
+				return err
+			}
+			if len(rotations) != len(collections) {
+				return ErrKeysMismatch
+			}
+			byID := make(map[uint]*models.CollectionRotation, len(rotations))
+			for _, r := range rotations {
+				byID[r.CollectionID] = r
+			}
+			for _, c := range collections {
+				r, ok := byID[c.ID]
+				if !ok {
+					return ErrKeysMismatch
+				}
+				if err := rotate(tx, userID, c, r, remaining); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&removed).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from organization %d: %w", memberID, orgID, err)
+	}
+	return nil
+}
+
+// rotate replaces the key of collection c and reseals its items.
+func rotate(
+	tx *gorm.DB,
+	userID string,
+	c *models.Collection,
+	r *models.CollectionRotation,
+	members []string,
+) error {
+	if r.KeyVersion != c.KeyVersion {
+		return ErrStaleKey
+	}
+	if err := checkKeys(r.Keys, members); err != nil {
+		return err
+	}
+	var items []uint
+	if err := tx.Model(&models.CollectionItem{}).Where("collection_id = ?", c.ID).Pluck("id", &items).Error; err != nil {
+		return err
+	}
+	want := make(map[uint]bool, len(items))
+	for _, id := range items {
+		want[id] = true
+	}
+	if len(r.Items) != len(want) {
+		return ErrStaleKey
+	}
+	version := c.KeyVersion + 1
+	for _, item := range r.Items {
+		if !want[item.ID] || len(item.Data) == 0 {
+			return ErrStaleKey
+		}
+		delete(want, item.ID)
+		err := tx.Model(&models.CollectionItem{}).Where("id = ?", item.ID).Updates(
+			map[string]interface{}{"data": item.Data, "key_version": version, "updated_by": userID},
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("collection_id = ?", c.ID).Delete(&models.CollectionKey{}).Error; err != nil {
+		return err
+	}
+	for _, k := range r.Keys {
+		k.CollectionID = c.ID
+	}
+	if len(r.Keys) > 0 {
+		if err := tx.Create(r.Keys).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(c).Update("key_version", version).Error
+}
+
+// AddCollection creates a collection with its key sealed to every member
+// and invited user of the organization.
+func (or *orgRepo) AddCollection(
+	userID string,
+	collection *models.Collection,
+	keys []*models.CollectionKey,
+) error {
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			if _, err := member(tx, userID, collection.OrgID, models.RoleAdmin); err != nil {
+				return err
+			}
+			var members []string
+			err := tx.Model(&models.OrgMember{}).
+				Where("org_id = ?", collection.OrgID).
+				Pluck("user_id", &members).Error
+			if err != nil {
+				return err
+			}
+			if err := checkKeys(keys, members); err != nil {
+				return err
+			}
+			collection.KeyVersion = 1
+			if err := tx.Create(collection).Error; err != nil {
+				return err
+			}
+			for _, k := range keys {
+				k.CollectionID = collection.ID
+			}
+			return tx.Create(keys).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add collection %s: %w", collection.Name, err)
+	}
+	return nil
+}
+
+// Collections lists the collections of an organization with the user's
+// sealed keys.
+func (or *orgRepo) Collections(userID string, orgID uint) ([]*models.Collection, error) {
+	var collections []*models.Collection
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			if _, err := member(tx, userID, orgID, models.RoleReadOnly); err != nil {
+				return err
+			}
+			if err := tx.Where("org_id = ?", orgID).Order("id").Find(&collections).Error; err != nil {
+				return err
+			}
+			if len(collections) == 0 {
+				return nil
+			}
+			ids := make([]uint, len(collections))
+			for i, c := range collections {
+				ids[i] = c.ID
+			}
+			var keys []*models.CollectionKey
+			err := tx.Where("user_id = ? AND collection_id IN ?", userID, ids).Find(&keys).Error
+			if err != nil {
+				return err
+			}
+			byID := make(map[uint][]byte, len(keys))
+			for _, k := range keys {
+				byID[k.CollectionID] = k.Key
+			}
+			for _, c := range collections {
+				c.Key = byID[c.ID]
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collections of organization %d: %w", orgID, err)
+	}
+	return collections, nil
+}
+
+func (or *orgRepo) Items(userID string, collectionID uint) ([]*models.CollectionItem, error) {
+	var items []*models.CollectionItem
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			if _, err := collectionOf(tx, userID, collectionID, models.RoleReadOnly); err != nil {
+				return err
+			}
+			return tx.Where("collection_id = ?", collectionID).Order("id").Find(&items).Error
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items of collection %d: %w", collectionID, err)
+	}
+	return items, nil
+}
+
+// SaveItem adds an item to a collection. It must be sealed with the
+// current collection key.
+func (or *orgRepo) SaveItem(userID string, item *models.CollectionItem) error {
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			c, err := collectionOf(tx, userID, item.CollectionID, models.RoleMember)
+			if err != nil {
+				return err
+			}
+			if item.KeyVersion != c.KeyVersion {
+				return ErrStaleKey
+			}
+			item.UpdatedBy = userID
+			return tx.Create(item).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add item to collection %d: %w", item.CollectionID, err)
+	}
+	return nil
+}
+
+// UpdateItem replaces the data of a collection item. It must be sealed
+// with the current collection key.
+func (or *orgRepo) UpdateItem(userID string, item *models.CollectionItem) error {
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			c, err := collectionOf(tx, userID, item.CollectionID, models.RoleMember)
+			if err != nil {
+				return err
+			}
+			if item.KeyVersion != c.KeyVersion {
+				return ErrStaleKey
+			}
+			res := tx.Model(&models.CollectionItem{}).
+				Where("id = ? AND collection_id = ?", item.ID, item.CollectionID).
+				Updates(map[string]interface{}{"data": item.Data, "key_version": item.KeyVersion, "updated_by": userID})
+			if res.Error == nil && res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return res.Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update collection item %d: %w", item.ID, err)
+	}
+	return nil
+}
+
+func (or *orgRepo) DeleteItem(userID string, collectionID, id uint) error {
+	err := or.db.Transaction(
+		func(tx *gorm.DB) error {
+			if _, err := collectionOf(tx, userID, collectionID, models.RoleMember); err != nil {
+				return err
+			}
+			res := tx.Where("id = ? AND collection_id = ?", id, collectionID).Delete(&models.CollectionItem{})
+			if res.Error == nil && res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return res.Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection item %d: %w", id, err)
+	}
+	return nil
+}
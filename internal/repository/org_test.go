@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestOrgRepo(t *testing.T) {
+	or := NewOrgRepo(setupTestDB())
+	org := &models.Organization{Name: "acme"}
+	require.NoError(t, or.Create(org, "orgowner"))
+
+	collection := &models.Collection{OrgID: org.ID, Name: "servers"}
+	assert.ErrorIs(
+		t,
+		or.AddCollection("orgowner", collection, []*models.CollectionKey{{UserID: "orgother", Key: []byte("k")}}),
+		ErrKeysMismatch,
+	)
+	require.NoError(
+		t,
+		or.AddCollection("orgowner", collection, []*models.CollectionKey{{UserID: "orgowner", Key: []byte("owner key")}}),
+	)
+	assert.Equal(t, 1, collection.KeyVersion)
+
+	assert.ErrorIs(
+		t,
+		or.Invite("orgowner", org.ID, models.OrgInvite{UserID: "orgadmin", Role: models.RoleAdmin}),
+		ErrKeysMismatch,
+	)
+	invite := func(userID, role string) models.OrgInvite {
+		return models.OrgInvite{
+			UserID: userID,
+			Role:   role,
+			Keys:   []*models.CollectionKey{{CollectionID: collection.ID, Key: []byte(userID + " key")}},
+		}
+	}
+	require.NoError(t, or.Invite("orgowner", org.ID, invite("orgadmin", models.RoleAdmin)))
+	assert.ErrorIs(t, or.Invite("orgowner", org.ID, invite("orgadmin", models.RoleMember)), ErrMemberExists)
+	_, err := or.Members("orgadmin", org.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	require.NoError(t, or.Accept("orgadmin", org.ID))
+	assert.ErrorIs(t, or.Accept("orgadmin", org.ID), gorm.ErrRecordNotFound)
+
+	assert.ErrorIs(t, or.Invite("orgadmin", org.ID, invite("orgadmin2", models.RoleAdmin)), ErrOrgPermission)
+	require.NoError(t, or.Invite("orgadmin", org.ID, invite("orgreader", models.RoleReadOnly)))
+	require.NoError(t, or.Accept("orgreader", org.ID))
+	require.NoError(t, or.Invite("orgadmin", org.ID, invite("orgmember", models.RoleMember)))
+	require.NoError(t, or.Accept("orgmember", org.ID))
+
+	memberships, err := or.Memberships("orgmember")
+	require.NoError(t, err)
+	require.Len(t, memberships, 1)
+	assert.Equal(t, "acme", memberships[0].OrgName)
+	members, err := or.Members("orgreader", org.ID)
+	require.NoError(t, err)
+	assert.Len(t, members, 4)
+
+	collections, err := or.Collections("orgmember", org.ID)
+	require.NoError(t, err)
+	require.Len(t, collections, 1)
+	assert.Equal(t, []byte("orgmember key"), collections[0].Key)
+
+	item := &models.CollectionItem{CollectionID: collection.ID, ItemType: "login-password", Data: []byte("v1"), KeyVersion: 1}
+	assert.ErrorIs(t, or.SaveItem("orgreader", item), ErrOrgPermission)
+	require.NoError(t, or.SaveItem("orgmember", item))
+	items, err := or.Items("orgreader", collection.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "orgmember", items[0].UpdatedBy)
+
+	remaining := func(keys ...string) []*models.CollectionKey {
+		var ks []*models.CollectionKey
+		for _, k := range keys {
+			ks = append(ks, &models.CollectionKey{UserID: k, Key: []byte("rotated " + k)})
+		}
+		return ks
+	}
+	rotation := func(version int, keys []*models.CollectionKey, items ...*models.CollectionItem) []*models.CollectionRotation {
+		return []*models.CollectionRotation{{CollectionID: collection.ID, KeyVersion: version, Keys: keys, Items: items}}
+	}
+	resealed := &models.CollectionItem{Data: []byte("v1 rotated")}
+	resealed.ID = item.ID
+
+	assert.ErrorIs(t, or.Remove("orgadmin", org.ID, "orgowner", nil), ErrOrgPermission)
+	assert.ErrorIs(t, or.Remove("orgmember", org.ID, "orgreader", nil), ErrOrgPermission)
+	assert.ErrorIs(t, or.Remove("orgadmin", org.ID, "orgreader", nil), ErrKeysMismatch)
+	assert.ErrorIs(
+		t,
+		or.Remove("orgadmin", org.ID, "orgreader", rotation(1, remaining("orgowner", "orgadmin", "orgmember"))),
+		ErrStaleKey,
+	)
+	assert.ErrorIs(
+		t,
+		or.Remove("orgadmin", org.ID, "orgreader", rotation(1, remaining("orgowner", "orgadmin"), resealed)),
+		ErrKeysMismatch,
+	)
+	require.NoError(
+		t,
+		or.Remove("orgadmin", org.ID, "orgreader", rotation(1, remaining("orgowner", "orgadmin", "orgmember"), resealed)),
+	)
+
+	_, err = or.Items("orgreader", collection.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	collections, err = or.Collections("orgmember", org.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, collections[0].KeyVersion)
+	assert.Equal(t, []byte("rotated orgmember"), collections[0].Key)
+	items, err = or.Items("orgmember", collection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v1 rotated"), items[0].Data)
+	assert.Equal(t, 2, items[0].KeyVersion)
+
+	stale := &models.CollectionItem{CollectionID: collection.ID, Data: []byte("v2"), KeyVersion: 1}
+	stale.ID = item.ID
+	assert.ErrorIs(t, or.UpdateItem("orgmember", stale), ErrStaleKey)
+	stale.KeyVersion = 2
+	require.NoError(t, or.UpdateItem("orgmember", stale))
+	assert.ErrorIs(t, or.DeleteItem("orgmember", collection.ID, item.ID+100), gorm.ErrRecordNotFound)
+	require.NoError(t, or.DeleteItem("orgmember", collection.ID, item.ID))
+	items, err = or.Items("orgowner", collection.ID)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
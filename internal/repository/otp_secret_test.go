@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestNewOTPRepo(t *testing.T) {
+	assert.Equal(
+		t, &Repo[models.OTPSecret]{db: &gorm.DB{}, name: "otp secret"}, NewOTPRepo(&gorm.DB{}),
+	)
+}
+
+func TestOTPRepo_List(t *testing.T) {
+	lpID := uint(7)
+	type fields struct {
+		db *gorm.DB
+	}
+	type args struct {
+		userID string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    []*models.OTPSecret
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Get otp secrets for existing user",
+			fields: fields{
+				db: setupTestDBWithOTPSecrets(
+					[]*models.OTPSecret{
+						{
+							UserID:          "otpuser",
+							Issuer:          "GitHub",
+							Account:         "octocat",
+							Secret:          "JBSWY3DPEHPK3PXP",
+							Algorithm:       "SHA1",
+							Digits:          6,
+							Period:          30,
+							LoginPasswordID: &lpID,
+						},
+					},
+				),
+			},
+			args: args{
+				userID: "otpuser",
+			},
+			want: []*models.OTPSecret{
+				{
+					UserID:          "otpuser",
+					Issuer:          "GitHub",
+					Account:         "octocat",
+					Secret:          "JBSWY3DPEHPK3PXP",
+					Algorithm:       "SHA1",
+					Digits:          6,
+					Period:          30,
+					LoginPasswordID: &lpID,
+				},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Get otp secrets for non-existing user",
+			fields: fields{
+				db: setupTestDB(),
+			},
+			args: args{
+				userID: "nonexistentuser",
+			},
+			want:    []*models.OTPSecret{},
+			wantErr: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				otp := NewOTPRepo(tt.fields.db)
+				got, err := otp.List(tt.args.userID, ListFilter{})
+				if !tt.wantErr(t, err, fmt.Sprintf("List(%v)", tt.args.userID)) {
+					return
+				}
+				for i := range got {
+					tt.want[i].Model.ID = got[i].Model.ID
+					tt.want[i].CreatedAt = got[i].CreatedAt
+					tt.want[i].UpdatedAt = got[i].UpdatedAt
+				}
+				assert.Equalf(t, tt.want, got, "List(%v)", tt.args.userID)
+			},
+		)
+	}
+}
+
+func TestOTPRepo_Save(t *testing.T) {
+	otp := NewOTPRepo(setupTestDB())
+	assert.NoError(
+		t, otp.Save(
+			&models.OTPSecret{
+				UserID:    "user1",
+				Account:   "alice",
+				Secret:    "JBSWY3DPEHPK3PXP",
+				Algorithm: "SHA1",
+				Digits:    6,
+				Period:    30,
+			},
+		),
+	)
+}
+
+func setupTestDBWithOTPSecrets(secrets []*models.OTPSecret) *gorm.DB {
+	db := setupTestDB()
+	for _, s := range secrets {
+		db.Create(s)
+	}
+	return db
+}
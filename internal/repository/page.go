@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"time"
+)
+
+const (
+	SortCreated = "created"
+	SortUpdated = "updated"
+	SortName    = "name"
+
+	// MaxLimit caps the page size a client may ask for.
+	MaxLimit = 1000
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("sort must be created, updated or name")
+	ErrSealedSort    = errors.New("display names are sealed, sort by name on the client")
+)
+
+// Page selects a slice of a sorted list. A zero Limit returns everything
+// after Cursor.
+type Page struct {
+	Limit  int
+	Cursor string
+	// Sort is SortCreated (the default), SortUpdated or SortName.
+	Sort string
+	Desc bool
+}
+
+// PageResult is one page of a list. NextCursor is empty on the last page;
+// Total counts all items matching the filter.
+type PageResult[T any] struct {
+	Items      []*T
+	Total      int64
+	NextCursor string
+}
+
+// cursor is the sort value and ID after the last item of a page.
+type cursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// sortKey is the column a page is ordered by and the model field holding it.
+type sortKey struct {
+	column string
+	field  string
+	isTime bool
+}
+
+// sortKeyOf resolves a sort name for model; sealed names cannot be sorted.
+func sortKeyOf(db *gorm.DB, model interface{}, sort string) (sortKey, error) {
+	switch sort {
+	case "", SortCreated:
+		return sortKey{column: "created_at", field: "CreatedAt", isTime: true}, nil
+	case SortUpdated:
+		return sortKey{column: "updated_at", field: "UpdatedAt", isTime: true}, nil
+	case SortName:
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return sortKey{}, err
+		}
+		if f := stmt.Schema.LookUpField("name"); f != nil {
+			return sortKey{column: f.DBName, field: f.Name}, nil
+		}
+		return sortKey{}, ErrSealedSort
+	}
+	return sortKey{}, ErrInvalidSort
+}
+
+func encodeCursor(sort string, key sortKey, item interface{}) (string, error) {
+	value := reflect.ValueOf(item).Elem().FieldByName(key.field).Interface()
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(cursor{Sort: sort, Value: raw, ID: IDOf(item)})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// applyCursor keeps the items after the cursor position in the page order.
+func applyCursor(query *gorm.DB, page Page, key sortKey) (*gorm.DB, error) {
+	data, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != page.Sort {
+		return nil, ErrInvalidCursor
+	}
+
+	var value interface{}
+	if key.isTime {
+		var t time.Time
+		if err := json.Unmarshal(c.Value, &t); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		value = t
+	} else {
+		var s string
+		if err := json.Unmarshal(c.Value, &s); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		value = s
+	}
+
+	op := ">"
+	if page.Desc {
+		op = "<"
+	}
+	return query.Where(
+		fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", key.column, op),
+		value, value, c.ID,
+	), nil
+}
+
+func orderBy(key sortKey, desc bool) string {
+	if desc {
+		return key.column + " DESC, id DESC"
+	}
+	return key.column + " ASC, id ASC"
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func collectPages(
+	t *testing.T,
+	r *Repo[models.TextData],
+	userID string,
+	filter ListFilter,
+	page Page,
+) []string {
+	var names []string
+	for {
+		res, err := r.Page(userID, filter, page)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(res.Items), page.Limit)
+		for _, it := range res.Items {
+			names = append(names, it.DisplayName)
+		}
+		if res.NextCursor == "" {
+			return names
+		}
+		page.Cursor = res.NextCursor
+	}
+}
+
+func TestRepo_Page(t *testing.T) {
+	db := setupTestDB()
+	td := NewTDRepo(db)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"delta", "alpha", "echo", "charlie", "bravo"} {
+		note := &models.TextData{UserID: "pageuser", Content: name, ItemMeta: models.ItemMeta{DisplayName: name}}
+		note.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		note.UpdatedAt = base.Add(time.Duration(10-i) * time.Hour)
+		if name == "echo" {
+			note.Tags = []string{"keep"}
+		}
+		require.NoError(t, td.Save(note))
+	}
+
+	first, err := td.Page("pageuser", ListFilter{}, Page{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), first.Total)
+	assert.Len(t, first.Items, 2)
+
+	assert.Equal(
+		t, []string{"delta", "alpha", "echo", "charlie", "bravo"},
+		collectPages(t, td, "pageuser", ListFilter{}, Page{Limit: 2}),
+	)
+	assert.Equal(
+		t, []string{"bravo", "charlie", "echo", "alpha", "delta"},
+		collectPages(t, td, "pageuser", ListFilter{}, Page{Limit: 4, Sort: SortUpdated}),
+	)
+
+	after := base.Add(90 * time.Minute)
+	before := base.Add(4 * time.Hour)
+	res, err := td.Page("pageuser", ListFilter{CreatedAfter: &after, CreatedBefore: &before}, Page{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res.Total)
+	assert.Empty(t, res.NextCursor)
+
+	res, err = td.Page("pageuser", ListFilter{Tags: []string{"keep"}}, Page{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.Total)
+	if assert.Len(t, res.Items, 1) {
+		assert.Equal(t, "echo", res.Items[0].DisplayName)
+	}
+
+	_, err = td.Page("pageuser", ListFilter{}, Page{Sort: "size"})
+	assert.ErrorIs(t, err, ErrInvalidSort)
+	_, err = td.Page("pageuser", ListFilter{}, Page{Sort: SortName})
+	assert.ErrorIs(t, err, ErrSealedSort)
+	_, err = td.Page("pageuser", ListFilter{}, Page{Cursor: "!!"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = td.Page("pageuser", ListFilter{}, Page{Cursor: first.NextCursor, Sort: SortUpdated})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
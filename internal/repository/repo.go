@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+	"reflect"
+)
+
+// Store is what the data handlers need from a per-user secret type.
+type Store[T any] interface {
+	List(userID string, filter ListFilter) ([]*T, error)
+	Page(userID string, filter ListFilter, page Page) (*PageResult[T], error)
+	Get(userID string, id uint) (*T, error)
+	Save(item *T) error
+	SaveAll(items []*T) error
+	Organize(userID string, id uint, change models.MetaChange) error
+	Index(userID string, id uint, tokens []string) error
+	Update(userID string, id uint, item *T, seal Sealer) error
+	Versions(userID string, id uint) ([]*models.ItemVersion, error)
+	Version(userID string, id, version uint) (*models.ItemVersion, error)
+	Delete(userID string, id uint) error
+	Trashed(userID string) ([]*T, error)
+	Restore(userID string, id uint) error
+	Purge(userID string, id uint) error
+	EmptyTrash(userID string) (int64, error)
+}
+
+// Repo stores one model type in its own table, scoped by the user_id column.
+// Tags of models embedding models.ItemMeta are kept in the item_tags table,
+// earlier versions in item_versions.
+type Repo[T any] struct {
+	db        *gorm.DB
+	name      string
+	retention Retention
+}
+
+// NewRepo creates a repository for T; name is used in error messages.
+func NewRepo[T any](db *gorm.DB, name string) *Repo[T] {
+	return &Repo[T]{db: db, name: name}
+}
+
+func (r *Repo[T]) table() (string, error) {
+	return tableOf(r.db, new(T))
+}
+
+func (r *Repo[T]) List(userID string, filter ListFilter) ([]*T, error) {
+	res, err := r.page(userID, filter, Page{}, r.db, false)
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+// Page returns one page of the user's items and the total matching filter.
+func (r *Repo[T]) Page(userID string, filter ListFilter, page Page) (*PageResult[T], error) {
+	return r.page(userID, filter, page, r.db, true)
+}
+
+// page runs the filtered query starting from query.
+func (r *Repo[T]) page(
+	userID string,
+	filter ListFilter,
+	page Page,
+	query *gorm.DB,
+	count bool,
+) (*PageResult[T], error) {
+	res := &PageResult[T]{}
+	err := func() error {
+		table, err := r.table()
+		if err != nil {
+			return err
+		}
+		key, err := sortKeyOf(r.db, new(T), page.Sort)
+		if err != nil {
+			return err
+		}
+		query = query.Where("user_id = ? AND deleted_at IS NULL", userID)
+		query, err = applyFilter(r.db, query, new(T), table, userID, filter)
+		if err != nil {
+			return err
+		}
+		query = query.Session(&gorm.Session{})
+		if count {
+			if err := query.Model(new(T)).Count(&res.Total).Error; err != nil {
+				return err
+			}
+		}
+
+		if page.Cursor != "" {
+			if query, err = applyCursor(query, page, key); err != nil {
+				return err
+			}
+		}
+		query = query.Order(orderBy(key, page.Desc))
+		if page.Limit > 0 {
+			query = query.Limit(page.Limit + 1)
+		}
+		if err := query.Find(&res.Items).Error; err != nil {
+			return err
+		}
+		if page.Limit > 0 && len(res.Items) > page.Limit {
+			res.Items = res.Items[:page.Limit]
+			res.NextCursor, err = encodeCursor(page.Sort, key, res.Items[page.Limit-1])
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, ok := any(new(T)).(metaHolder); ok {
+			return loadTags(r.db, table, res.Items)
+		}
+		return nil
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s list by userID %s: %w", r.name, userID, err)
+	}
+	if !count {
+		res.Total = int64(len(res.Items))
+	}
+	return res, nil
+}
+
+// Get returns one of the user's items with its tags.
+func (r *Repo[T]) Get(userID string, id uint) (*T, error) {
+	item := new(T)
+	err := func() error {
+		if err := r.db.Where(liveItem, userID, id).First(item).Error; err != nil {
+			return err
+		}
+		if _, ok := any(item).(metaHolder); !ok {
+			return nil
+		}
+		table, err := r.table()
+		if err != nil {
+			return err
+		}
+		return loadTags(r.db, table, []*T{item})
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %d: %w", r.name, id, err)
+	}
+	return item, nil
+}
+
+// Save creates the item and its tags. A folder set on the item must belong
+// to the item's owner.
+func (r *Repo[T]) Save(item *T) error {
+	if err := r.db.Transaction(func(tx *gorm.DB) error { return create(tx, item) }); err != nil {
+		return fmt.Errorf("failed to add new %s: %w", r.name, err)
+	}
+	return nil
+}
+
+// SaveAll adds items in one transaction: either all of them are added or
+// none is.
+func (r *Repo[T]) SaveAll(items []*T) error {
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			for _, item := range items {
+				if err := create(tx, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add %d new %s: %w", len(items), r.name, err)
+	}
+	return nil
+}
+
+// create adds item with its tags and search tokens in tx.
+func create[T any](tx *gorm.DB, item *T) error {
+	meta, ok := any(item).(metaHolder)
+	if !ok {
+		return tx.Create(item).Error
+	}
+	userID := reflect.ValueOf(item).Elem().FieldByName("UserID").String()
+	m := meta.Meta()
+	if m.FolderID != nil {
+		if err := checkFolder(tx, userID, *m.FolderID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Create(item).Error; err != nil {
+		return err
+	}
+	table, err := tableOf(tx, item)
+	if err != nil {
+		return err
+	}
+	m.Tags = NormalizeTags(m.Tags)
+	if err := saveTags(tx, userID, table, IDOf(item), m.Tags); err != nil {
+		return err
+	}
+	return saveSearchTokens(tx, userID, table, IDOf(item), m.SearchTokens)
+}
+
+// Organize applies change to the display name, folder, favorite flag and
+// tags of one of the user's items.
+func (r *Repo[T]) Organize(userID string, id uint, change models.MetaChange) error {
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			item := new(T)
+			if err := tx.Where(liveItem, userID, id).First(item).Error; err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{}
+			if change.DisplayName != nil {
+				updates["display_name"] = *change.DisplayName
+			}
+			if change.FolderID != nil {
+				if *change.FolderID == 0 {
+					updates["folder_id"] = nil
+				} else {
+					if err := checkFolder(tx, userID, *change.FolderID); err != nil {
+						return err
+					}
+					updates["folder_id"] = *change.FolderID
+				}
+			}
+			if change.Favorite != nil {
+				updates["favorite"] = *change.Favorite
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(item).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+
+			if change.Tags != nil {
+				table, err := tableOf(tx, item)
+				if err != nil {
+					return err
+				}
+				return saveTags(tx, userID, table, id, *change.Tags)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to organize %s %d: %w", r.name, id, err)
+	}
+	return nil
+}
+
+// Index replaces the blind index of one of the user's items.
+func (r *Repo[T]) Index(userID string, id uint, tokens []string) error {
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			item := new(T)
+			if err := tx.Where(liveItem, userID, id).First(item).Error; err != nil {
+				return err
+			}
+			table, err := tableOf(tx, item)
+			if err != nil {
+				return err
+			}
+			return saveSearchTokens(tx, userID, table, id, tokens)
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to index %s %d: %w", r.name, id, err)
+	}
+	return nil
+}
+
+func NewLPRepo(db *gorm.DB) *Repo[models.LoginPassword] {
+	return NewRepo[models.LoginPassword](db, "login-password")
+}
+
+func NewBDRepo(db *gorm.DB) *Repo[models.BinaryData] {
+	return NewRepo[models.BinaryData](db, "binary data")
+}
+
+func NewCCRepo(db *gorm.DB) *Repo[models.CreditCard] {
+	return NewRepo[models.CreditCard](db, "credit card")
+}
+
+func NewTDRepo(db *gorm.DB) *Repo[models.TextData] {
+	return NewRepo[models.TextData](db, "text data")
+}
+
+func NewOTPRepo(db *gorm.DB) *Repo[models.OTPSecret] {
+	return NewRepo[models.OTPSecret](db, "otp secret")
+}
+
+func NewSSHRepo(db *gorm.DB) *Repo[models.SSHKey] {
+	return NewRepo[models.SSHKey](db, "ssh key")
+}
+
+func NewItemRepo(db *gorm.DB) *Repo[models.Item] {
+	return NewRepo[models.Item](db, "item")
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type unmigrated struct {
+	ID     uint
+	UserID string
+}
+
+func TestRepo_ErrorsNameTheType(t *testing.T) {
+	r := NewRepo[unmigrated](setupTestDB(), "widget")
+
+	_, err := r.List("user1", ListFilter{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get widget list by userID user1")
+
+	err = r.Save(&unmigrated{UserID: "user1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to add new widget")
+}
+
+func TestRepo_SaveAll(t *testing.T) {
+	db := setupTestDB()
+	lp := NewLPRepo(db)
+	folder := &models.Folder{UserID: "batchuser", Name: "Imported"}
+	require.NoError(t, NewFolderRepo(db).SaveNewFolder(folder))
+	other := &models.Folder{UserID: "batchother", Name: "Other"}
+	require.NoError(t, NewFolderRepo(db).SaveNewFolder(other))
+
+	require.NoError(
+		t, lp.SaveAll(
+			[]*models.LoginPassword{
+				{UserID: "batchuser", Login: "a", Password: "1", ItemMeta: models.ItemMeta{Tags: []string{"Work"}}},
+				{UserID: "batchuser", Login: "b", Password: "2", ItemMeta: models.ItemMeta{FolderID: &folder.ID}},
+			},
+		),
+	)
+	got, err := lp.List("batchuser", ListFilter{})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	got, err = lp.List("batchuser", ListFilter{Tags: []string{"work"}})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "a", got[0].Login)
+
+	err = lp.SaveAll(
+		[]*models.LoginPassword{
+			{UserID: "batchuser", Login: "c", Password: "3"},
+			{UserID: "batchuser", Login: "d", Password: "4", ItemMeta: models.ItemMeta{FolderID: &other.ID}},
+		},
+	)
+	assert.ErrorIs(t, err, ErrFolderNotFound)
+	assert.Contains(t, err.Error(), "failed to add 2 new")
+	got, err = lp.List("batchuser", ListFilter{})
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+}
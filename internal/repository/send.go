@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// accessIDSize is the number of random bytes in the ID of a send link.
+const accessIDSize = 16
+
+type SendRepo interface {
+	Create(send *models.Send) error
+	Find(accessID string, now time.Time) (*models.Send, error)
+	View(send *models.Send) error
+	Fail(send *models.Send, limit int) error
+	Delete(userID, accessID string) error
+}
+
+type sendRepo struct {
+	db *gorm.DB
+}
+
+func NewSendRepo(db *gorm.DB) *sendRepo {
+	return &sendRepo{db: db}
+}
+
+// Create stores a send under a new random access ID.
+func (sr *sendRepo) Create(send *models.Send) error {
+	id := make([]byte, accessIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate send id: %w", err)
+	}
+	send.AccessID = base64.RawURLEncoding.EncodeToString(id)
+	send.Views = 0
+	send.FailedAttempts = 0
+	if err := sr.db.Create(send).Error; err != nil {
+		return fmt.Errorf("failed to create send: %w", err)
+	}
+	return nil
+}
+
+// Find returns a send that has not expired by now.
+func (sr *sendRepo) Find(accessID string, now time.Time) (*models.Send, error) {
+	var send models.Send
+	err := sr.db.Where("access_id = ? AND expires_at > ?", accessID, now).First(&send).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find send: %w", err)
+	}
+	return &send, nil
+}
+
+// View counts a read of a send found by Find and deletes it with the last
+// allowed read. A send already read up to its limit is not found.
+func (sr *sendRepo) View(send *models.Send) error {
+	err := sr.db.Transaction(
+		func(tx *gorm.DB) error {
+			res := tx.Model(&models.Send{}).
+				Where("id = ? AND views < max_views", send.ID).
+				Update("views", gorm.Expr("views + 1"))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return tx.Where("id = ? AND views >= max_views", send.ID).Delete(&models.Send{}).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to view send: %w", err)
+	}
+	send.Views++
+	return nil
+}
+
+// Fail counts a wrong password given for a send found by Find and deletes
+// the send once limit wrong passwords have been given.
+func (sr *sendRepo) Fail(send *models.Send, limit int) error {
+	err := sr.db.Transaction(
+		func(tx *gorm.DB) error {
+			err := tx.Model(&models.Send{}).
+				Where("id = ?", send.ID).
+				Update("failed_attempts", gorm.Expr("failed_attempts + 1")).Error
+			if err != nil {
+				return err
+			}
+			return tx.Where("id = ? AND failed_attempts >= ?", send.ID, limit).Delete(&models.Send{}).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to count send password failure: %w", err)
+	}
+	send.FailedAttempts++
+	return nil
+}
+
+// Delete removes a send of the user before it is read or expires.
+func (sr *sendRepo) Delete(userID, accessID string) error {
+	res := sr.db.Where("user_id = ? AND access_id = ?", userID, accessID).Delete(&models.Send{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete send: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete send: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// PurgeSends deletes the sends that expired by now and returns how many it
+// removed.
+func PurgeSends(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Where("expires_at <= ?", now).Delete(&models.Send{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to purge sends: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestSendRepo(t *testing.T) {
+	db := setupTestDB()
+	sr := NewSendRepo(db)
+	now := time.Now()
+	newSend := func(maxViews int, expiresAt time.Time) *models.Send {
+		send := &models.Send{UserID: "sender", Data: []byte("sealed"), MaxViews: maxViews, ExpiresAt: expiresAt}
+		require.NoError(t, sr.Create(send))
+		return send
+	}
+
+	twice := newSend(2, now.Add(time.Hour))
+	once := newSend(1, now.Add(time.Hour))
+	assert.Len(t, twice.AccessID, 22)
+	assert.NotEqual(t, twice.AccessID, once.AccessID)
+
+	for i := 0; i < 2; i++ {
+		found, err := sr.Find(twice.AccessID, now)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("sealed"), found.Data)
+		require.NoError(t, sr.View(found))
+	}
+	_, err := sr.Find(twice.AccessID, now)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	found, err := sr.Find(once.AccessID, now)
+	require.NoError(t, err)
+	again := *found
+	require.NoError(t, sr.View(found))
+	assert.ErrorIs(t, sr.View(&again), gorm.ErrRecordNotFound)
+
+	expired := newSend(1, now.Add(-time.Minute))
+	_, err = sr.Find(expired.AccessID, now)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	live := newSend(1, now.Add(time.Hour))
+	n, err := PurgeSends(db, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = sr.Find(live.AccessID, now)
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		found, err = sr.Find(live.AccessID, now)
+		require.NoError(t, err)
+		assert.Equal(t, i, found.FailedAttempts)
+		require.NoError(t, sr.Fail(found, 2))
+	}
+	_, err = sr.Find(live.AccessID, now)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	owned := newSend(1, now.Add(time.Hour))
+	assert.ErrorIs(t, sr.Delete("other", owned.AccessID), gorm.ErrRecordNotFound)
+	require.NoError(t, sr.Delete("sender", owned.AccessID))
+	_, err = sr.Find(owned.AccessID, now)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
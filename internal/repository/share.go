@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+)
+
+var ErrReadOnlyShare = errors.New("share is read-only")
+
+type ShareRepo interface {
+	Save(share *models.Share) error
+	SharedBy(ownerID string) ([]*models.Share, error)
+	SharedWith(recipientID string) ([]*models.Share, error)
+	UpdateData(userID string, id uint, data []byte) error
+	Edited(ownerID string) (int64, error)
+	Revoke(ownerID string, id uint) error
+}
+
+// shareTypes maps item tables to share types.
+var shareTypes = map[string]string{
+	"login_passwords": "login-password",
+	"credit_cards":    "card",
+	"text_data":       "text-data",
+	"otp_secrets":     "otp",
+	"ssh_keys":        "ssh-key",
+	"items":           "item",
+}
+
+type shareRepo struct {
+	db *gorm.DB
+}
+
+func NewShareRepo(db *gorm.DB) *shareRepo {
+	return &shareRepo{db: db}
+}
+
+// Save shares an item with a user. Sharing an item again with the same
+// user replaces the permission, data and keys of the existing share.
+func (sr *shareRepo) Save(share *models.Share) error {
+	share.UpdatedBy = share.OwnerID
+	err := sr.db.Transaction(
+		func(tx *gorm.DB) error {
+			var existing models.Share
+			err := tx.Where(
+				"owner_id = ? AND item_type = ? AND item_id = ? AND recipient_id = ?",
+				share.OwnerID, share.ItemType, share.ItemID, share.RecipientID,
+			).First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return tx.Create(share).Error
+			}
+			if err != nil {
+				return err
+			}
+			share.Model = existing.Model
+			return tx.Save(share).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to share %s %d: %w", share.ItemType, share.ItemID, err)
+	}
+	return nil
+}
+
+// SharedBy lists the shares created by the owner.
+func (sr *shareRepo) SharedBy(ownerID string) ([]*models.Share, error) {
+	var shares []*models.Share
+	if err := sr.db.Where("owner_id = ?", ownerID).Order("id").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("failed to get shares by owner %s: %w", ownerID, err)
+	}
+	return shares, nil
+}
+
+// SharedWith lists the shares of other users' items with the recipient.
+func (sr *shareRepo) SharedWith(recipientID string) ([]*models.Share, error) {
+	var shares []*models.Share
+	if err := sr.db.Where("recipient_id = ?", recipientID).Order("id").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("failed to get shares with %s: %w", recipientID, err)
+	}
+	return shares, nil
+}
+
+// UpdateData replaces the sealed data of a share. The owner may always do
+// so, the recipient only if the share is editable.
+func (sr *shareRepo) UpdateData(userID string, id uint, data []byte) error {
+	err := sr.db.Transaction(
+		func(tx *gorm.DB) error {
+			var share models.Share
+			err := tx.Where("id = ? AND (owner_id = ? OR recipient_id = ?)", id, userID, userID).
+				First(&share).Error
+			if err != nil {
+				return err
+			}
+			if share.OwnerID != userID && share.Permission != models.PermissionEdit {
+				return ErrReadOnlyShare
+			}
+			return tx.Model(&share).Updates(
+				map[string]interface{}{"data": data, "updated_by": userID},
+			).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update share %d: %w", id, err)
+	}
+	return nil
+}
+
+// Edited counts the owner's shares whose data a recipient replaced since
+// the owner last sealed it, which the owner's client has yet to merge into
+// the items.
+func (sr *shareRepo) Edited(ownerID string) (int64, error) {
+	var n int64
+	err := sr.db.Model(&models.Share{}).Where("owner_id = ? AND updated_by <> owner_id", ownerID).Count(&n).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count edited shares of %s: %w", ownerID, err)
+	}
+	return n, nil
+}
+
+// Revoke deletes one of the owner's shares with its sealed data.
+func (sr *shareRepo) Revoke(ownerID string, id uint) error {
+	res := sr.db.Where("owner_id = ? AND id = ?", ownerID, id).Delete(&models.Share{})
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if res.Error != nil {
+		return fmt.Errorf("failed to revoke share %d: %w", id, res.Error)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestShareRepo(t *testing.T) {
+	sr := NewShareRepo(setupTestDB())
+	newShare := func(recipientID, permission, data string) *models.Share {
+		return &models.Share{
+			OwnerID:      "shareowner",
+			RecipientID:  recipientID,
+			ItemType:     "login-password",
+			ItemID:       7,
+			Permission:   permission,
+			Data:         []byte(data),
+			OwnerKey:     []byte("owner key"),
+			RecipientKey: []byte("recipient key"),
+		}
+	}
+	share := newShare("sharereader", models.PermissionRead, "sealed")
+	require.NoError(t, sr.Save(share))
+	editable := newShare("shareeditor", models.PermissionEdit, "sealed")
+	require.NoError(t, sr.Save(editable))
+	again := newShare("sharereader", models.PermissionRead, "sealed again")
+	require.NoError(t, sr.Save(again))
+	assert.Equal(t, share.ID, again.ID)
+
+	byOwner, err := sr.SharedBy("shareowner")
+	require.NoError(t, err)
+	require.Len(t, byOwner, 2)
+	assert.Equal(t, []byte("sealed again"), byOwner[0].Data)
+	withReader, err := sr.SharedWith("sharereader")
+	require.NoError(t, err)
+	require.Len(t, withReader, 1)
+	assert.Equal(t, share.ID, withReader[0].ID)
+
+	assert.ErrorIs(t, sr.UpdateData("sharereader", share.ID, []byte("x")), ErrReadOnlyShare)
+	assert.ErrorIs(t, sr.UpdateData("shareeditor", share.ID, []byte("x")), gorm.ErrRecordNotFound)
+	require.NoError(t, sr.UpdateData("shareeditor", editable.ID, []byte("edited")))
+	withEditor, err := sr.SharedWith("shareeditor")
+	require.NoError(t, err)
+	require.Len(t, withEditor, 1)
+	assert.Equal(t, []byte("edited"), withEditor[0].Data)
+	assert.Equal(t, "shareeditor", withEditor[0].UpdatedBy)
+	edited, err := sr.Edited("shareowner")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), edited)
+	require.NoError(t, sr.UpdateData("shareowner", share.ID, []byte("refreshed")))
+	require.NoError(t, sr.UpdateData("shareowner", editable.ID, []byte("merged")))
+	edited, err = sr.Edited("shareowner")
+	require.NoError(t, err)
+	assert.Zero(t, edited)
+
+	assert.ErrorIs(t, sr.Revoke("sharereader", share.ID), gorm.ErrRecordNotFound)
+	require.NoError(t, sr.Revoke("shareowner", share.ID))
+	withReader, err = sr.SharedWith("sharereader")
+	require.NoError(t, err)
+	assert.Empty(t, withReader)
+	assert.ErrorIs(t, sr.Revoke("shareowner", share.ID), gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+	"strings"
+)
+
+type TagRepo interface {
+	GetTags(userID string) ([]*models.Tag, error)
+	RenameTag(userID, name, newName string) error
+	DeleteTag(userID, name string) error
+}
+
+type TagsRepo struct {
+	db *gorm.DB
+}
+
+func NewTagRepo(db *gorm.DB) *TagsRepo {
+	return &TagsRepo{db: db}
+}
+
+func (tr *TagsRepo) GetTags(userID string) ([]*models.Tag, error) {
+	var tags []*models.Tag
+	if err := tr.db.Where("user_id = ?", userID).Order("name").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tags by userID %s: %w", userID, err)
+	}
+	return tags, nil
+}
+
+// RenameTag renames a tag on every item carrying it.
+func (tr *TagsRepo) RenameTag(userID, name, newName string) error {
+	err := tr.db.Transaction(
+		func(tx *gorm.DB) error {
+			var tag models.Tag
+			err := tx.Where("user_id = ? AND name = ?", userID, strings.ToLower(name)).
+				First(&tag).Error
+			if err != nil {
+				return err
+			}
+			newTags := NormalizeTags([]string{newName})
+			if len(newTags) == 0 {
+				return fmt.Errorf("empty tag name")
+			}
+			var count int64
+			err = tx.Model(&models.Tag{}).
+				Where("user_id = ? AND name = ? AND id <> ?", userID, newTags[0], tag.ID).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrTagExists
+			}
+			return tx.Model(&tag).Update("name", newTags[0]).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rename tag %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteTag removes a tag from every item and deletes it.
+func (tr *TagsRepo) DeleteTag(userID, name string) error {
+	err := tr.db.Transaction(
+		func(tx *gorm.DB) error {
+			var tag models.Tag
+			err := tx.Where("user_id = ? AND name = ?", userID, strings.ToLower(name)).
+				First(&tag).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.ItemTag{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&tag).Error
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", name, err)
+	}
+	return nil
+}
@@ -16,14 +16,14 @@ func TestNewTDRepo(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want *TDRepo
+		want *Repo[models.TextData]
 	}{
 		{
 			name: "Valid DB instance",
 			args: args{
 				db: &gorm.DB{},
 			},
-			want: &TDRepo{db: &gorm.DB{}},
+			want: &Repo[models.TextData]{db: &gorm.DB{}, name: "text data"},
 		},
 	}
 	for _, tt := range tests {
@@ -35,7 +35,7 @@ func TestNewTDRepo(t *testing.T) {
 	}
 }
 
-func TestTDRepo_GetTextData(t *testing.T) {
+func TestTDRepo_List(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -99,11 +99,9 @@ func TestTDRepo_GetTextData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				td := &TDRepo{
-					db: tt.fields.db,
-				}
-				got, err := td.GetTextData(tt.args.userID)
-				if !tt.wantErr(t, err, fmt.Sprintf("GetTextData(%v)", tt.args.userID)) {
+				td := NewTDRepo(tt.fields.db)
+				got, err := td.List(tt.args.userID, ListFilter{})
+				if !tt.wantErr(t, err, fmt.Sprintf("List(%v)", tt.args.userID)) {
 					return
 				}
 				for i := range got {
@@ -111,13 +109,13 @@ func TestTDRepo_GetTextData(t *testing.T) {
 					tt.want[i].CreatedAt = got[i].CreatedAt
 					tt.want[i].UpdatedAt = got[i].UpdatedAt
 				}
-				assert.Equalf(t, tt.want, got, "GetTextData(%v)", tt.args.userID)
+				assert.Equalf(t, tt.want, got, "List(%v)", tt.args.userID)
 			},
 		)
 	}
 }
 
-func TestTDRepo_SaveNewTextData(t *testing.T) {
+func TestTDRepo_Save(t *testing.T) {
 	type fields struct {
 		db *gorm.DB
 	}
@@ -170,13 +168,11 @@ func TestTDRepo_SaveNewTextData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				td := &TDRepo{
-					db: tt.fields.db,
-				}
+				td := NewTDRepo(tt.fields.db)
 				tt.wantErr(
 					t,
-					td.SaveNewTextData(tt.args.textData),
-					fmt.Sprintf("SaveNewTextData(%v)", tt.args.textData),
+					td.Save(tt.args.textData),
+					fmt.Sprintf("Save(%v)", tt.args.textData),
 				)
 			},
 		)
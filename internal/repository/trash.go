@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// liveItem selects one of a user's items that is not in the trash.
+const liveItem = "user_id = ? AND id = ? AND deleted_at IS NULL"
+
+// trashedItem selects one of a user's items in the trash.
+const trashedItem = "user_id = ? AND id = ? AND deleted_at IS NOT NULL"
+
+// Delete moves one of the user's items to the trash by setting its
+// deleted_at. Trashed items are left out of every other query until they
+// are restored.
+func (r *Repo[T]) Delete(userID string, id uint) error {
+	res := r.db.Model(new(T)).Where(liveItem, userID, id).UpdateColumn("deleted_at", time.Now())
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete %s %d: %w", r.name, id, res.Error)
+	}
+	return nil
+}
+
+// Trashed lists the user's items in the trash, most recently deleted first.
+func (r *Repo[T]) Trashed(userID string) ([]*T, error) {
+	var items []*T
+	err := func() error {
+		err := r.db.Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+			Order("deleted_at DESC").
+			Find(&items).Error
+		if err != nil {
+			return err
+		}
+		if _, ok := any(new(T)).(metaHolder); !ok {
+			return nil
+		}
+		table, err := r.table()
+		if err != nil {
+			return err
+		}
+		return loadTags(r.db, table, items)
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed %s list by userID %s: %w", r.name, userID, err)
+	}
+	return items, nil
+}
+
+// Restore moves one of the user's items out of the trash.
+func (r *Repo[T]) Restore(userID string, id uint) error {
+	res := r.db.Model(new(T)).Where(trashedItem, userID, id).UpdateColumn("deleted_at", nil)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = gorm.ErrRecordNotFound
+	}
+	if res.Error != nil {
+		return fmt.Errorf("failed to restore %s %d: %w", r.name, id, res.Error)
+	}
+	return nil
+}
+
+// Purge permanently deletes one of the user's trashed items with its tags,
+// blind index and versions.
+func (r *Repo[T]) Purge(userID string, id uint) error {
+	n, err := r.purge(trashedItem, userID, id)
+	if err == nil && n == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to purge %s %d: %w", r.name, id, err)
+	}
+	return nil
+}
+
+// EmptyTrash permanently deletes all of the user's trashed items and
+// returns their number.
+func (r *Repo[T]) EmptyTrash(userID string) (int64, error) {
+	n, err := r.purge("user_id = ? AND deleted_at IS NOT NULL", userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to empty %s trash of %s: %w", r.name, userID, err)
+	}
+	return n, nil
+}
+
+func (r *Repo[T]) purge(query string, args ...interface{}) (int64, error) {
+	var n int64
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			var err error
+			n, err = purgeWhere(tx, new(T), query, args...)
+			return err
+		},
+	)
+	return n, err
+}
+
+// purgeWhere hard-deletes the rows of model matching query with their dependents.
+func purgeWhere(tx *gorm.DB, model interface{}, query string, args ...interface{}) (int64, error) {
+	var ids []uint
+	if err := tx.Model(model).Where(query, args...).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	table, err := tableOf(tx, model)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := model.(*models.BinaryData); ok {
+		if err := releaseBinaryBlobs(tx, ids); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Delete(model, ids).Error; err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), deleteItemMeta(tx, table, ids...)
+}
+
+// PurgeTrash permanently deletes the items of every user and type that were
+// moved to the trash before the given time. It returns the number of items
+// deleted.
+func PurgeTrash(db *gorm.DB, before time.Time) (int64, error) {
+	var total int64
+	for _, model := range VaultModels() {
+		err := db.Transaction(
+			func(tx *gorm.DB) error {
+				n, err := purgeWhere(tx, model, "deleted_at IS NOT NULL AND deleted_at < ?", before)
+				if err != nil {
+					return err
+				}
+				total += n
+				return nil
+			},
+		)
+		if err != nil {
+			return total, fmt.Errorf("failed to purge trash: %w", err)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestRepo_Trash(t *testing.T) {
+	db := setupTestDB()
+	repo := NewLPRepo(db)
+
+	keep := &models.LoginPassword{UserID: "trashuser", Login: "keep", Password: "p"}
+	drop := &models.LoginPassword{
+		UserID:   "trashuser",
+		Login:    "drop",
+		Password: "p",
+		ItemMeta: models.ItemMeta{Tags: []string{"old"}},
+	}
+	require.NoError(t, repo.Save(keep))
+	require.NoError(t, repo.Save(drop))
+
+	assert.ErrorIs(t, repo.Delete("otheruser", drop.ID), gorm.ErrRecordNotFound)
+	require.NoError(t, repo.Delete("trashuser", drop.ID))
+	assert.ErrorIs(t, repo.Delete("trashuser", drop.ID), gorm.ErrRecordNotFound)
+
+	live, err := repo.List("trashuser", ListFilter{})
+	require.NoError(t, err)
+	if assert.Len(t, live, 1) {
+		assert.Equal(t, "keep", live[0].Login)
+	}
+	_, err = repo.Get("trashuser", drop.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.Organize("trashuser", drop.ID, models.MetaChange{}), gorm.ErrRecordNotFound)
+
+	trashed, err := repo.Trashed("trashuser")
+	require.NoError(t, err)
+	if assert.Len(t, trashed, 1) {
+		assert.Equal(t, "drop", trashed[0].Login)
+		assert.NotNil(t, trashed[0].DeletedAt)
+		assert.Equal(t, []string{"old"}, trashed[0].Tags)
+	}
+
+	assert.ErrorIs(t, repo.Restore("trashuser", keep.ID), gorm.ErrRecordNotFound)
+	require.NoError(t, repo.Restore("trashuser", drop.ID))
+	live, err = repo.List("trashuser", ListFilter{})
+	require.NoError(t, err)
+	assert.Len(t, live, 2)
+
+	assert.ErrorIs(t, repo.Purge("trashuser", drop.ID), gorm.ErrRecordNotFound)
+	require.NoError(t, repo.Delete("trashuser", drop.ID))
+	require.NoError(t, repo.Purge("trashuser", drop.ID))
+	trashed, err = repo.Trashed("trashuser")
+	require.NoError(t, err)
+	assert.Empty(t, trashed)
+	var links int64
+	db.Model(&models.ItemTag{}).Where("item_type = ? AND item_id = ?", "login_passwords", drop.ID).
+		Count(&links)
+	assert.Zero(t, links)
+
+	require.NoError(t, repo.Delete("trashuser", keep.ID))
+	n, err := repo.EmptyTrash("trashuser")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	db := setupTestDB()
+	cards := NewCCRepo(db)
+	texts := NewTDRepo(db)
+
+	oldCard := &models.CreditCard{UserID: "janitoruser", CardNumber: "1", CardHolder: "A"}
+	newText := &models.TextData{UserID: "janitoruser", Content: "fresh"}
+	require.NoError(t, cards.Save(oldCard))
+	require.NoError(t, texts.Save(newText))
+	require.NoError(t, cards.Delete("janitoruser", oldCard.ID))
+	require.NoError(t, texts.Delete("janitoruser", newText.ID))
+	longAgo := time.Now().Add(-60 * 24 * time.Hour)
+	require.NoError(t, db.Model(oldCard).UpdateColumn("deleted_at", longAgo).Error)
+	shares := NewShareRepo(db)
+	for _, s := range []*models.Share{
+		{OwnerID: "janitoruser", RecipientID: "janitorfriend", ItemType: "card", ItemID: oldCard.ID},
+		{OwnerID: "janitoruser", RecipientID: "janitorfriend", ItemType: "text-data", ItemID: newText.ID},
+	} {
+		s.Permission, s.Data, s.OwnerKey, s.RecipientKey = models.PermissionRead, []byte("d"), []byte("k"), []byte("k")
+		require.NoError(t, shares.Save(s))
+	}
+
+	n, err := PurgeTrash(db, time.Now().Add(-30*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	trashedCards, err := cards.Trashed("janitoruser")
+	require.NoError(t, err)
+	assert.Empty(t, trashedCards)
+	trashedTexts, err := texts.Trashed("janitoruser")
+	require.NoError(t, err)
+	assert.Len(t, trashedTexts, 1)
+	shared, err := shares.SharedWith("janitorfriend")
+	require.NoError(t, err)
+	require.Len(t, shared, 1)
+	assert.Equal(t, "text-data", shared[0].ItemType)
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// Usage is what a user stores: the bytes of binary data contents and the
+// number of items of each type by table name. Trashed items count until
+// they are purged.
+type Usage struct {
+	Bytes int64            `json:"bytes"`
+	Items map[string]int64 `json:"items"`
+}
+
+// UsageRepo measures the storage used by a user.
+type UsageRepo interface {
+	Usage(userID string) (*Usage, error)
+	Items(userID string, model interface{}) (int64, error)
+	Bytes(userID string) (int64, error)
+}
+
+type usageRepo struct {
+	db *gorm.DB
+}
+
+func NewUsageRepo(db *gorm.DB) *usageRepo {
+	return &usageRepo{db: db}
+}
+
+func (u *usageRepo) Usage(userID string) (*Usage, error) {
+	usage := &Usage{Items: make(map[string]int64, len(VaultModels()))}
+	for _, model := range VaultModels() {
+		table, err := tableOf(u.db, model)
+		if err != nil {
+			return nil, err
+		}
+		if usage.Items[table], err = u.Items(userID, model); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if usage.Bytes, err = u.Bytes(userID); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// Items counts the user's items of the type of model, trashed ones
+// included.
+func (u *usageRepo) Items(userID string, model interface{}) (int64, error) {
+	var n int64
+	if err := u.db.Model(model).Where("user_id = ?", userID).Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("failed to count items of %s: %w", userID, err)
+	}
+	return n, nil
+}
+
+// Bytes sums the stored chunks of complete uploads and the inline contents,
+// at their original size, of the user's binary data. Blobs shared by
+// several items count once. An upload that is not complete counts its
+// declared size from the start, so that uploads started together cannot
+// together exceed the quota each of them was admitted by.
+func (u *usageRepo) Bytes(userID string) (int64, error) {
+	var n int64
+	err := u.db.Raw(
+		`SELECT COALESCE(SUM(size), 0) FROM (
+			SELECT content_ref AS ref, size FROM binary_data
+			WHERE user_id = ? AND content_ref <> ''
+			UNION
+			SELECT c.ref, c.size FROM binary_chunks c
+			JOIN binary_data b ON b.id = c.binary_data_id
+			WHERE b.user_id = ? AND b.complete
+			UNION
+			SELECT 'upload:' || id AS ref, size FROM binary_data
+			WHERE user_id = ? AND chunked AND NOT complete
+		) AS blobs`,
+		userID, userID, userID,
+	).Scan(&n).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum the storage of %s: %w", userID, err)
+	}
+	return n, nil
+}
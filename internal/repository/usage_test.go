@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/elina-chertova/auth-keeper.git/internal/blobstore"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUsageRepo(t *testing.T) {
+	db := setupTestDB()
+	binaries := NewBinaryRepo(db, blobstore.NewMemory())
+	usage := NewUsageRepo(db)
+
+	for i := 0; i < 2; i++ {
+		bd := &models.BinaryData{
+			UserID: "usageuser", Content: []byte("certificate"), ContentKey: "usagekey", SHA256: "sum",
+		}
+		require.NoError(t, binaries.Save(bd))
+	}
+	upload := &models.BinaryData{UserID: "usageuser", Chunked: true, Chunks: 2, Size: 9}
+	require.NoError(t, binaries.SaveNewUpload(upload))
+	require.NoError(t, binaries.SaveChunk("usageuser", upload.ID, 0, []byte("sealed-0")))
+	require.NoError(t, binaries.SaveChunk("usageuser", upload.ID, 1, []byte("sealed-1!")))
+	sum := sha256.Sum256([]byte("sealed-0sealed-1!"))
+	require.NoError(t, binaries.CompleteUpload("usageuser", upload.ID, hex.EncodeToString(sum[:])))
+	// Chunks of an upload that is not complete count at its declared size.
+	started := &models.BinaryData{UserID: "usageuser", Chunked: true, Chunks: 3, Size: 1000}
+	require.NoError(t, binaries.SaveNewUpload(started))
+	require.NoError(t, NewTDRepo(db).Save(&models.TextData{UserID: "usageuser", Content: "note"}))
+	trashed := &models.TextData{UserID: "usageuser", Content: "old"}
+	require.NoError(t, NewTDRepo(db).Save(trashed))
+	require.NoError(t, NewTDRepo(db).Delete("usageuser", trashed.ID))
+
+	got, err := usage.Usage("usageuser")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("certificate")+len("sealed-0")+len("sealed-1!")+1000), got.Bytes)
+	assert.Equal(t, int64(4), got.Items["binary_data"])
+	assert.Equal(t, int64(2), got.Items["text_data"])
+	assert.Equal(t, int64(0), got.Items["credit_cards"])
+	assert.Len(t, got.Items, len(VaultModels()))
+
+	got, err = usage.Usage("nobody")
+	require.NoError(t, err)
+	assert.Zero(t, got.Bytes)
+}
@@ -9,6 +9,8 @@ import (
 type UserRepo interface {
 	CreateUser(user *models.User) error
 	GetUserByUsername(username string) (*models.User, error)
+	UpdatePassword(username, hashedPassword string) error
+	SetPublicKey(username string, publicKey []byte) error
 }
 
 type userRepo struct {
@@ -33,3 +35,36 @@ func (ur *userRepo) GetUserByUsername(username string) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// UpdatePassword replaces the password hash and raises the token version,
+// so that tokens issued before are rejected.
+func (ur *userRepo) UpdatePassword(username, hashedPassword string) error {
+	res := ur.db.Model(&models.User{}).
+		Where("username = ?", username).
+		Updates(
+			map[string]interface{}{
+				"password":      hashedPassword,
+				"token_version": gorm.Expr("token_version + 1"),
+			},
+		)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update password for %s: %w", username, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("failed to update password for %s: %w", username, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (ur *userRepo) SetPublicKey(username string, publicKey []byte) error {
+	res := ur.db.Model(&models.User{}).
+		Where("username = ?", username).
+		Update("public_key", publicKey)
+	if res.Error != nil {
+		return fmt.Errorf("failed to set public key for %s: %w", username, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("failed to set public key for %s: %w", username, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
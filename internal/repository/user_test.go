@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"testing"
@@ -159,6 +160,26 @@ func setupTestDB() *gorm.DB {
 		&models.TextData{},
 		&models.CreditCard{},
 		&models.BinaryData{},
+		&models.OTPSecret{},
+		&models.SSHKey{},
+		&models.Item{},
+		&models.ItemTemplate{},
+		&models.Folder{},
+		&models.Tag{},
+		&models.ItemTag{},
+		&models.SearchToken{},
+		&models.ItemVersion{},
+		&models.BinaryChunk{},
+		&models.OrphanBlob{},
+		&models.BlobRef{},
+		&models.Share{},
+		&models.Organization{},
+		&models.OrgMember{},
+		&models.Collection{},
+		&models.CollectionKey{},
+		&models.CollectionItem{},
+		&models.Send{},
+		&models.EmergencyAccess{},
 	)
 	return db
 }
@@ -168,3 +189,67 @@ func setupTestDBWithUser(user *models.User) *gorm.DB {
 	db.Create(user)
 	return db
 }
+
+func Test_userRepo_UpdatePassword(t *testing.T) {
+	db := setupTestDBWithUser(
+		&models.User{
+			Username:    "passwordchanger",
+			Password:    "old_hash",
+			Email:       "passwordchanger@example.com",
+			PersonalKey: []byte("personal_key"),
+		},
+	)
+	ur := &userRepo{db: db}
+
+	tests := []struct {
+		name     string
+		username string
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{
+			name:     "Update existing user",
+			username: "passwordchanger",
+			wantErr:  assert.NoError,
+		},
+		{
+			name:     "Update non-existing user",
+			username: "nonexistentuser",
+			wantErr:  assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := ur.UpdatePassword(tt.username, "new_hash")
+				if !tt.wantErr(t, err, fmt.Sprintf("UpdatePassword(%v)", tt.username)) {
+					return
+				}
+				if err != nil {
+					return
+				}
+				got, err := ur.GetUserByUsername(tt.username)
+				assert.NoError(t, err)
+				assert.Equal(t, "new_hash", got.Password)
+				assert.Equal(t, uint(1), got.TokenVersion)
+			},
+		)
+	}
+}
+
+func Test_userRepo_SetPublicKey(t *testing.T) {
+	db := setupTestDBWithUser(
+		&models.User{
+			Username:    "keyholder",
+			Password:    "hash",
+			Email:       "keyholder@example.com",
+			PersonalKey: []byte("personal_key"),
+		},
+	)
+	ur := &userRepo{db: db}
+
+	require.NoError(t, ur.SetPublicKey("keyholder", []byte("public_key")))
+	got, err := ur.GetUserByUsername("keyholder")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("public_key"), got.PublicKey)
+	assert.ErrorIs(t, ur.SetPublicKey("nokeyholder", []byte("public_key")), gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,246 @@
+package search
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/security"
+	"sort"
+	"strings"
+)
+
+// Display names and tags of items are sealed by the client before they
+// reach the server, which keeps them as opaque strings. Tags are sealed
+// deterministically and hex encoded, so the server still filters, renames
+// and deletes them by equality and its lower-casing leaves them intact.
+
+const (
+	metaKeyInfo = "auth-keeper item metadata"
+
+	// SealedNamePrefix and SealedTagPrefix mark sealed display names and
+	// tags. Values without them were stored before sealing and are read as
+	// they are.
+	SealedNamePrefix = "sealed:"
+	SealedTagPrefix  = "tag:"
+
+	// Unreadable stands for a display name or tag OpenMeta cannot open.
+	Unreadable = "[undecryptable]"
+)
+
+var ErrSealedMeta = errors.New("sealed display name or tag cannot be opened")
+
+// DeriveMetaKey derives the key sealing display names and tags from the
+// user's client key.
+func DeriveMetaKey(clientKey []byte) ([]byte, error) {
+	return derive(clientKey, metaKeyInfo)
+}
+
+// SealName seals a display name; the empty name stays empty.
+func SealName(key []byte, name string) (string, error) {
+	if name == "" {
+		return name, nil
+	}
+	sealed, err := security.SealData([]byte(name), key)
+	if err != nil {
+		return "", err
+	}
+	return SealedNamePrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// SealTag trims and lower-cases a tag, like the server does, and seals it
+// so that the same tag always gives the same sealed tag. The empty tag
+// stays empty.
+func SealTag(key []byte, tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return tag, nil
+	}
+	sealed, err := security.SealDeterministic([]byte(tag), key)
+	if err != nil {
+		return "", err
+	}
+	return SealedTagPrefix + hex.EncodeToString(sealed), nil
+}
+
+// IsSealed reports whether value is a sealed display name or tag.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, SealedNamePrefix) || strings.HasPrefix(value, SealedTagPrefix)
+}
+
+// OpenValue opens a display name or tag sealed by SealName or SealTag and
+// returns other values, stored before sealing, as they are.
+func OpenValue(key []byte, value string) (string, error) {
+	var sealed []byte
+	var err error
+	switch {
+	case strings.HasPrefix(value, SealedNamePrefix):
+		sealed, err = base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, SealedNamePrefix))
+	case strings.HasPrefix(value, SealedTagPrefix):
+		sealed, err = hex.DecodeString(strings.TrimPrefix(value, SealedTagPrefix))
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return value, nil
+	}
+	opened, err := security.OpenData(sealed, key)
+	if err != nil {
+		return "", ErrSealedMeta
+	}
+	return string(opened), nil
+}
+
+// SealMeta seals the display names and tags found anywhere in a JSON
+// document, under the "display_name" and "tags" keys. key is only called
+// when there is something to seal.
+func SealMeta(data []byte, key func() ([]byte, error)) ([]byte, error) {
+	return rewriteMeta(
+		data, key, func(k keyFunc, name string) (string, error) {
+			key, err := k()
+			if err != nil {
+				return "", err
+			}
+			return SealName(key, name)
+		}, func(k keyFunc, tags []string) ([]string, error) {
+			key, err := k()
+			if err != nil {
+				return nil, err
+			}
+			sealed := make([]string, 0, len(tags))
+			for _, tag := range tags {
+				s, err := SealTag(key, tag)
+				if err != nil {
+					return nil, err
+				}
+				if s != "" {
+					sealed = append(sealed, s)
+				}
+			}
+			return sealed, nil
+		},
+	)
+}
+
+// OpenMeta opens the display names and tags sealed by SealMeta in a JSON
+// document; the tags of each item are sorted once opened. Values sealed
+// with another key become Unreadable. key is only called when there is
+// something sealed.
+func OpenMeta(data []byte, key func() ([]byte, error)) ([]byte, error) {
+	open := func(k keyFunc, value string) (string, error) {
+		if !IsSealed(value) {
+			return value, nil
+		}
+		key, err := k()
+		if err != nil {
+			return "", err
+		}
+		opened, err := OpenValue(key, value)
+		if errors.Is(err, ErrSealedMeta) {
+			return Unreadable, nil
+		}
+		return opened, err
+	}
+	return rewriteMeta(
+		data, key, open, func(k keyFunc, tags []string) ([]string, error) {
+			opened := make([]string, len(tags))
+			for i, tag := range tags {
+				var err error
+				if opened[i], err = open(k, tag); err != nil {
+					return nil, err
+				}
+			}
+			sort.Strings(opened)
+			return opened, nil
+		},
+	)
+}
+
+type keyFunc func() ([]byte, error)
+
+// rewriteMeta replaces the display names and tags of a JSON document.
+func rewriteMeta(
+	data []byte,
+	key keyFunc,
+	name func(key keyFunc, name string) (string, error),
+	tags func(key keyFunc, tags []string) ([]string, error),
+) ([]byte, error) {
+	// Most documents hold neither key; leave them untouched.
+	if !bytes.Contains(data, []byte(`"display_name"`)) && !bytes.Contains(data, []byte(`"tags"`)) {
+		return data, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var k []byte
+	memoized := func() ([]byte, error) {
+		if k != nil {
+			return k, nil
+		}
+		var err error
+		k, err = key()
+		return k, err
+	}
+	var walk func(v interface{}) error
+	walk = func(v interface{}) error {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, e := range v {
+				if err := walk(e); err != nil {
+					return err
+				}
+			}
+		case map[string]interface{}:
+			for field, e := range v {
+				var err error
+				switch s := e.(type) {
+				case string:
+					if field == "display_name" && s != "" {
+						v[field], err = name(memoized, s)
+					}
+				case []interface{}:
+					if list, ok := stringList(s); field == "tags" && ok && len(list) > 0 {
+						v[field], err = tags(memoized, list)
+					} else {
+						err = walk(e)
+					}
+				default:
+					err = walk(e)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(doc); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// stringList returns the elements of a JSON array if they are all strings.
+func stringList(a []interface{}) ([]string, bool) {
+	list := make([]string, len(a))
+	for i, e := range a {
+		s, ok := e.(string)
+		if !ok {
+			return nil, false
+		}
+		list[i] = s
+	}
+	return list, true
+}
@@ -0,0 +1,156 @@
+// Package search builds blind indexes of vault items: HMAC digests of the
+// words in an item's searchable fields, keyed per user, so the server can
+// match queries without seeing the words themselves. It also seals the
+// display names and tags of items, which the server filters on but must not
+// read.
+package search
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MinPrefix is the shortest indexed prefix of a word.
+	MinPrefix = 2
+	// MaxPrefix is the longest indexed prefix; longer prefix queries are
+	// cut to it and may match more than asked for.
+	MaxPrefix = 12
+
+	tokenBytes = 16
+	keyInfo    = "auth-keeper search index"
+)
+
+var ErrEmptyQuery = errors.New("query has no words to search for")
+
+// DeriveKey derives the index key from the user's client key, which never
+// leaves the client; the server holds the personal key and could otherwise
+// compute the tokens of any word.
+func DeriveKey(clientKey []byte) ([]byte, error) {
+	return derive(clientKey, keyInfo)
+}
+
+func derive(secret []byte, info string) ([]byte, error) {
+	key := make([]byte, sha256.Size)
+	r := hkdf.New(sha256.New, secret, nil, []byte(info))
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Words lower-cases text and splits it on everything but letters and digits.
+func Words(text string) []string {
+	return strings.FieldsFunc(
+		strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		},
+	)
+}
+
+func token(key []byte, kind, word string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(word))
+	return hex.EncodeToString(mac.Sum(nil)[:tokenBytes])
+}
+
+func prefix(word string) string {
+	r := []rune(word)
+	if len(r) > MaxPrefix {
+		r = r[:MaxPrefix]
+	}
+	return string(r)
+}
+
+// IndexTokens returns the blind index of the given fields: one exact token
+// per word and one prefix token per prefix of MinPrefix to MaxPrefix runes.
+func IndexTokens(key []byte, fields ...string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+	for _, field := range fields {
+		for _, word := range Words(field) {
+			add(token(key, "e", word))
+			r := []rune(prefix(word))
+			for n := MinPrefix; n <= len(r); n++ {
+				add(token(key, "p", string(r[:n])))
+			}
+		}
+	}
+	return tokens
+}
+
+// QueryTokens returns one token per query word; an item matches when its
+// index holds all of them. With prefix set words match the beginning of
+// indexed words, otherwise whole words only.
+func QueryTokens(key []byte, query string, prefixMatch bool) ([]string, error) {
+	var tokens []string
+	for _, word := range Words(query) {
+		switch {
+		case !prefixMatch:
+			tokens = append(tokens, token(key, "e", word))
+		case len([]rune(word)) >= MinPrefix:
+			tokens = append(tokens, token(key, "p", prefix(word)))
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	return tokens, nil
+}
+
+// Matches reports whether the plaintext fields match query the way the blind
+// index does, without the MaxPrefix cut.
+func Matches(query string, prefixMatch bool, fields ...string) bool {
+	var words []string
+	for _, field := range fields {
+		words = append(words, Words(field)...)
+	}
+	for _, q := range Words(query) {
+		if prefixMatch && len([]rune(q)) < MinPrefix {
+			continue
+		}
+		found := false
+		for _, w := range words {
+			if w == q || prefixMatch && strings.HasPrefix(w, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Fields returns the searchable fields of a vault item: its name, tags,
+// URL and login where the type has them.
+func Fields(item interface{}) []string {
+	var fields []string
+	if m, ok := item.(interface{ Meta() *models.ItemMeta }); ok {
+		fields = append(fields, m.Meta().DisplayName)
+		fields = append(fields, m.Meta().Tags...)
+	}
+	switch it := item.(type) {
+	case *models.LoginPassword:
+		fields = append(fields, it.Login, it.URL)
+	case *models.CreditCard:
+		fields = append(fields, it.CardHolder, it.Brand)
+	}
+	return fields
+}
@@ -0,0 +1,163 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	key, err := DeriveKey([]byte("1234567890123456"))
+	require.NoError(t, err)
+	return key
+}
+
+func TestDeriveKey(t *testing.T) {
+	a, err := DeriveKey([]byte("1234567890123456"))
+	require.NoError(t, err)
+	b, err := DeriveKey([]byte("6543210987654321"))
+	require.NoError(t, err)
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
+
+func TestWords(t *testing.T) {
+	assert.Equal(
+		t, []string{"https", "github", "com", "john", "doe"},
+		Words("https://GitHub.com john_doe"),
+	)
+}
+
+func contains(index []string, tokens []string) bool {
+	set := make(map[string]bool, len(index))
+	for _, t := range index {
+		set[t] = true
+	}
+	for _, t := range tokens {
+		if !set[t] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndexAndQueryTokens(t *testing.T) {
+	key := testKey(t)
+	index := IndexTokens(key, "GitHub", "octocat@example.com", "https://github.com/authentication")
+
+	tests := []struct {
+		name   string
+		query  string
+		prefix bool
+		match  bool
+	}{
+		{name: "Exact word", query: "github", match: true},
+		{name: "Exact needs whole word", query: "git", match: false},
+		{name: "Prefix", query: "git", prefix: true, match: true},
+		{name: "All words must match", query: "octo example", prefix: true, match: true},
+		{name: "Missing word", query: "octo gitlab", prefix: true, match: false},
+		{name: "Case insensitive", query: "OCTOCAT", match: true},
+		{name: "Long prefix is cut", query: "authenticatixyz", prefix: true, match: true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				tokens, err := QueryTokens(key, tt.query, tt.prefix)
+				require.NoError(t, err)
+				assert.Equal(t, tt.match, contains(index, tokens))
+			},
+		)
+	}
+
+	other, err := DeriveKey([]byte("6543210987654321"))
+	require.NoError(t, err)
+	tokens, err := QueryTokens(other, "github", false)
+	require.NoError(t, err)
+	assert.False(t, contains(index, tokens))
+
+	_, err = QueryTokens(key, "a !", true)
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+	_, err = QueryTokens(key, "", false)
+	assert.ErrorIs(t, err, ErrEmptyQuery)
+}
+
+func TestMatches(t *testing.T) {
+	assert.True(t, Matches("git octo", true, "GitHub", "octocat"))
+	assert.False(t, Matches("git", false, "GitHub"))
+	assert.False(t, Matches("examplexxxxxxxxx", true, "example.com"))
+}
+
+func TestFields(t *testing.T) {
+	lp := &models.LoginPassword{
+		Login: "octocat", URL: "https://github.com", Password: "secret",
+		ItemMeta: models.ItemMeta{DisplayName: "GitHub", Tags: []string{"work"}},
+	}
+	assert.Equal(t, []string{"GitHub", "work", "octocat", "https://github.com"}, Fields(lp))
+
+	card := &models.CreditCard{CardHolder: "John Doe", Brand: "visa", CardNumber: "4111"}
+	assert.Equal(t, []string{"", "John Doe", "visa"}, Fields(card))
+
+	note := &models.TextData{Content: "secret", ItemMeta: models.ItemMeta{DisplayName: "Wifi"}}
+	assert.Equal(t, []string{"Wifi"}, Fields(note))
+}
+
+func TestSealMeta(t *testing.T) {
+	key, err := DeriveMetaKey([]byte("1234567890123456"))
+	require.NoError(t, err)
+	keyOf := func() ([]byte, error) { return key, nil }
+
+	item := []byte(`{"ID":7,"display_name":"Bank <main>","tags":[" Work","home",""],"size":12345678901234}`)
+	sealed, err := SealMeta(item, keyOf)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "Bank")
+	assert.NotContains(t, string(sealed), "work")
+	var fields struct {
+		DisplayName string   `json:"display_name"`
+		Tags        []string `json:"tags"`
+	}
+	require.NoError(t, json.Unmarshal(sealed, &fields))
+	assert.True(t, strings.HasPrefix(fields.DisplayName, SealedNamePrefix))
+	require.Len(t, fields.Tags, 2)
+	work, err := SealTag(key, "WORK ")
+	require.NoError(t, err)
+	assert.Equal(t, work, fields.Tags[0])
+	assert.Equal(t, work, strings.ToLower(work))
+
+	opened, err := OpenMeta([]byte(`[`+string(sealed)+`]`), keyOf)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t, `[{"ID":7,"display_name":"Bank <main>","tags":["home","work"],"size":12345678901234}]`,
+		string(opened),
+	)
+
+	noKey := func() ([]byte, error) { return nil, errors.New("no client key") }
+	legacy := []byte(`{"display_name":"Bank","tags":["work"]}`)
+	opened, err = OpenMeta(legacy, noKey)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(legacy), string(opened))
+	_, err = OpenMeta(sealed, noKey)
+	assert.Error(t, err)
+	untouched := []byte(`{"name":"x"}`)
+	got, err := SealMeta(untouched, noKey)
+	require.NoError(t, err)
+	assert.Equal(t, untouched, got)
+
+	other, err := DeriveMetaKey([]byte("6543210987654321"))
+	require.NoError(t, err)
+	_, err = OpenValue(other, fields.DisplayName)
+	assert.ErrorIs(t, err, ErrSealedMeta)
+	opened, err = OpenMeta(
+		[]byte(`[`+string(sealed)+`,{"display_name":"Bank"}]`),
+		func() ([]byte, error) { return other, nil },
+	)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t, `[{"ID":7,"display_name":"[undecryptable]","tags":["[undecryptable]","[undecryptable]"],`+
+			`"size":12345678901234},{"display_name":"Bank"}]`,
+		string(opened),
+	)
+}
@@ -0,0 +1,303 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// BreachChecker reports whether a password appears in a known breach
+// corpus such as the Have I Been Pwned "ordered by hash" SHA-1 dump.
+type BreachChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+const (
+	sha1HexLen   = 40
+	bloomMagic   = "AKBF"
+	bloomVersion = 1
+)
+
+var (
+	ErrInvalidBloomFilter = errors.New("invalid bloom filter file")
+	ErrInvalidHashLine    = errors.New("invalid line in breach file")
+	ErrInvalidFPRate      = errors.New("false positive rate must be between 0 and 1")
+)
+
+// OpenBreachChecker opens either a prebuilt bloom filter or a sorted HIBP
+// SHA-1 file, detected by the bloom filter magic header.
+func OpenBreachChecker(path string) (BreachChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(bloomMagic))
+	_, err = io.ReadFull(f, magic)
+	f.Close()
+	if err == nil && string(magic) == bloomMagic {
+		return LoadBloomFilter(path)
+	}
+	return OpenHIBPFile(path)
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// HIBPFile looks up hashes in a file of "SHA1:COUNT" lines sorted by hash
+// using binary search over byte offsets, so the file is never loaded into
+// memory.
+type HIBPFile struct {
+	path string
+	size int64
+}
+
+func OpenHIBPFile(path string) (*HIBPFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &HIBPFile{path: path, size: info.Size()}, nil
+}
+
+func (h *HIBPFile) IsBreached(password string) (bool, error) {
+	count, err := h.Count(password)
+	return count > 0, err
+}
+
+// Count returns how many times the password has been seen in breaches.
+func (h *HIBPFile) Count(password string) (int64, error) {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	target := sha1Hex(password)
+	lo, hi := int64(0), h.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := lineStart(f, mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		line, err := readLineAt(f, start)
+		if err != nil {
+			return 0, err
+		}
+		hash, count, err := parseHashLine(line)
+		if err != nil {
+			return 0, err
+		}
+		switch strings.Compare(hash, target) {
+		case 0:
+			return count, nil
+		case -1:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineStart returns the offset of the first line that starts at or after off.
+func lineStart(r io.ReaderAt, off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, 128)
+	pos := off - 1
+	for {
+		n, err := r.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		if err == io.EOF {
+			return pos + int64(n), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		pos += int64(n)
+	}
+}
+
+func readLineAt(r io.ReaderAt, off int64) (string, error) {
+	reader := bufio.NewReader(io.NewSectionReader(r, off, math.MaxInt64-off))
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func parseHashLine(line string) (string, int64, error) {
+	line = strings.TrimSpace(line)
+	if len(line) < sha1HexLen {
+		return "", 0, fmt.Errorf("%w: %q", ErrInvalidHashLine, line)
+	}
+	hash := strings.ToUpper(line[:sha1HexLen])
+	var count int64 = 1
+	if rest := line[sha1HexLen:]; strings.HasPrefix(rest, ":") {
+		if _, err := fmt.Sscanf(rest[1:], "%d", &count); err != nil {
+			return "", 0, fmt.Errorf("%w: %q", ErrInvalidHashLine, line)
+		}
+	}
+	return hash, count, nil
+}
+
+// BloomFilter is a compact probabilistic set of SHA-1 password hashes.
+// False positives are possible at the configured rate, false negatives are
+// not.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+// NewBloomFilter sizes a filter for n hashes with the given false positive
+// rate.
+func NewBloomFilter(n uint64, fpRate float64) (*BloomFilter, error) {
+	if !validFPRate(fpRate) {
+		return nil, ErrInvalidFPRate
+	}
+	if n == 0 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{k: k, m: m, bits: make([]byte, (m+7)/8)}, nil
+}
+
+// validFPRate also rejects NaN.
+func validFPRate(fpRate float64) bool {
+	return fpRate > 0 && fpRate < 1
+}
+
+func (b *BloomFilter) locations(digest []byte) []uint64 {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	locs := make([]uint64, b.k)
+	for i := range locs {
+		locs[i] = (h1 + uint64(i)*h2) % b.m
+	}
+	return locs
+}
+
+// AddHash adds a raw 20-byte SHA-1 digest to the filter.
+func (b *BloomFilter) AddHash(digest []byte) {
+	for _, l := range b.locations(digest) {
+		b.bits[l/8] |= 1 << (l % 8)
+	}
+}
+
+func (b *BloomFilter) containsHash(digest []byte) bool {
+	for _, l := range b.locations(digest) {
+		if b.bits[l/8]&(1<<(l%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomFilter) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	return b.containsHash(sum[:]), nil
+}
+
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomMagic)+1+4+8)
+	copy(header, bloomMagic)
+	header[len(bloomMagic)] = bloomVersion
+	binary.BigEndian.PutUint32(header[len(bloomMagic)+1:], b.k)
+	binary.BigEndian.PutUint64(header[len(bloomMagic)+5:], b.m)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(b.bits)
+	return int64(n + m), err
+}
+
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(bloomMagic) + 1 + 4 + 8
+	if len(data) < headerLen || string(data[:len(bloomMagic)]) != bloomMagic ||
+		data[len(bloomMagic)] != bloomVersion {
+		return nil, ErrInvalidBloomFilter
+	}
+	b := &BloomFilter{
+		k:    binary.BigEndian.Uint32(data[len(bloomMagic)+1:]),
+		m:    binary.BigEndian.Uint64(data[len(bloomMagic)+5:]),
+		bits: data[headerLen:],
+	}
+	if b.k == 0 || b.m == 0 || uint64(len(b.bits)) != (b.m+7)/8 {
+		return nil, ErrInvalidBloomFilter
+	}
+	return b, nil
+}
+
+// BuildBloomFilter reads an HIBP SHA-1 file and builds a bloom filter from
+// every hash in it.
+func BuildBloomFilter(hibpPath string, fpRate float64) (*BloomFilter, error) {
+	if !validFPRate(fpRate) {
+		return nil, ErrInvalidFPRate
+	}
+	f, err := os.Open(hibpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) != "" {
+			lines++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	b, err := NewBloomFilter(lines, fpRate)
+	if err != nil {
+		return nil, err
+	}
+	scanner = bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		hash, _, err := parseHashLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		digest, err := hex.DecodeString(hash)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidHashLine, err)
+		}
+		b.AddHash(digest)
+	}
+	return b, scanner.Err()
+}
@@ -0,0 +1,120 @@
+package security
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeHIBPFile(t *testing.T, passwords []string, lineEnding string) string {
+	t.Helper()
+	lines := make([]string, 0, len(passwords))
+	for i, p := range passwords {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(p), i+1))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	content := strings.Join(lines, lineEnding) + lineEnding
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Error writing breach file: %v", err)
+	}
+	return path
+}
+
+var breachedPasswords = []string{
+	"password", "123456", "qwerty", "letmein", "dragon", "monkey", "P@ssw0rd", "iloveyou",
+	"trustno1", "sunshine", "master", "welcome", "shadow", "ashley", "football", "jesus",
+}
+
+func TestHIBPFile_IsBreached(t *testing.T) {
+	for _, lineEnding := range []string{"\n", "\r\n"} {
+		path := writeHIBPFile(t, breachedPasswords, lineEnding)
+		checker, err := OpenBreachChecker(path)
+		assert.NoError(t, err)
+		assert.IsType(t, &HIBPFile{}, checker)
+
+		tests := []struct {
+			name     string
+			password string
+			want     bool
+		}{
+			{name: "First breached", password: breachedPasswords[0], want: true},
+			{name: "Last breached", password: breachedPasswords[len(breachedPasswords)-1], want: true},
+			{name: "Mixed case breached", password: "P@ssw0rd", want: true},
+			{name: "Not breached", password: "violet-anvil-harbor-quiver", want: false},
+			{name: "Empty password", password: "", want: false},
+		}
+		for _, tt := range tests {
+			t.Run(
+				tt.name, func(t *testing.T) {
+					got, err := checker.IsBreached(tt.password)
+					assert.NoError(t, err)
+					assert.Equalf(t, tt.want, got, "IsBreached(%q)", tt.password)
+				},
+			)
+		}
+	}
+}
+
+func TestHIBPFile_Count(t *testing.T) {
+	path := writeHIBPFile(t, breachedPasswords, "\n")
+	f, err := OpenHIBPFile(path)
+	assert.NoError(t, err)
+
+	for i, p := range breachedPasswords {
+		got, err := f.Count(p)
+		assert.NoError(t, err)
+		assert.Equalf(t, int64(i+1), got, "Count(%q)", p)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	hibpPath := writeHIBPFile(t, breachedPasswords, "\n")
+	bloom, err := BuildBloomFilter(hibpPath, 0.001)
+	assert.NoError(t, err)
+
+	bloomPath := filepath.Join(t.TempDir(), "pwned.bloom")
+	f, err := os.Create(bloomPath)
+	assert.NoError(t, err)
+	_, err = bloom.WriteTo(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	checker, err := OpenBreachChecker(bloomPath)
+	assert.NoError(t, err)
+	assert.IsType(t, &BloomFilter{}, checker)
+
+	for _, p := range breachedPasswords {
+		got, err := checker.IsBreached(p)
+		assert.NoError(t, err)
+		assert.Truef(t, got, "IsBreached(%q)", p)
+	}
+	got, err := checker.IsBreached("violet-anvil-harbor-quiver")
+	assert.NoError(t, err)
+	assert.False(t, got)
+}
+
+func TestBloomFilter_InvalidFPRate(t *testing.T) {
+	hibpPath := writeHIBPFile(t, breachedPasswords, "\n")
+	for _, rate := range []float64{0, -0.1, 1, 2, math.NaN(), math.Inf(1)} {
+		_, err := NewBloomFilter(10, rate)
+		assert.ErrorIsf(t, err, ErrInvalidFPRate, "NewBloomFilter(%v)", rate)
+		_, err = BuildBloomFilter(hibpPath, rate)
+		assert.ErrorIsf(t, err, ErrInvalidFPRate, "BuildBloomFilter(%v)", rate)
+	}
+	_, err := NewBloomFilter(10, 0.5)
+	assert.NoError(t, err)
+}
+
+func TestLoadBloomFilterInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.bloom")
+	assert.NoError(t, os.WriteFile(path, []byte("AKBF\x01short"), 0600))
+	_, err := LoadBloomFilter(path)
+	assert.ErrorIs(t, err, ErrInvalidBloomFilter)
+}
@@ -7,6 +7,13 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
+// IsPasswordHash reports whether s is a bcrypt hash, as the server stores
+// the passwords of login-password entries.
+func IsPasswordHash(s string) bool {
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
+
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
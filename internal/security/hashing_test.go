@@ -27,3 +27,11 @@ func TestCheckPasswordHashWithInvalidHash(t *testing.T) {
 	invalidHash := "invalid_hash"
 	assert.False(t, CheckPasswordHash(password, invalidHash))
 }
+
+func TestIsPasswordHash(t *testing.T) {
+	hash, err := HashPassword("pass123")
+	assert.Nil(t, err)
+	assert.True(t, IsPasswordHash(hash))
+	assert.False(t, IsPasswordHash("pass123"))
+	assert.False(t, IsPasswordHash("$2a$10$short"))
+}
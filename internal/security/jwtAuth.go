@@ -12,6 +12,8 @@ const TokenExp = time.Minute * 10
 type JWTClaims struct {
 	jwt.RegisteredClaims
 	UserID string
+	// TokenVersion is the user's token version when the token was issued.
+	TokenVersion uint `json:",omitempty"`
 }
 
 var (
@@ -19,13 +21,14 @@ var (
 	ErrorTokenExpired = errors.New("token expired")
 )
 
-func GenerateToken(username string) (string, error) {
+func GenerateToken(username string, tokenVersion uint) (string, error) {
 	token := jwt.NewWithClaims(
 		jwt.SigningMethodHS256, JWTClaims{
 			RegisteredClaims: jwt.RegisteredClaims{
 				ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)),
 			},
-			UserID: username,
+			UserID:       username,
+			TokenVersion: tokenVersion,
 		},
 	)
 
@@ -63,6 +66,14 @@ func ValidateToken(signedToken string) error {
 }
 
 func GetUserFromToken(signedToken string) (string, error) {
+	claims, err := ParseToken(signedToken)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+func ParseToken(signedToken string) (*JWTClaims, error) {
 	claims := &JWTClaims{}
 	token, err := jwt.ParseWithClaims(
 		signedToken, claims, func(t *jwt.Token) (interface{}, error) {
@@ -70,11 +81,11 @@ func GetUserFromToken(signedToken string) (string, error) {
 		},
 	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok || !token.Valid {
-		return "", ErrorParseClaims
+		return nil, ErrorParseClaims
 	}
-	return claims.UserID, nil
+	return claims, nil
 }
@@ -17,7 +17,7 @@ func init() {
 func TestGenerateToken(t *testing.T) {
 	username := "test_user"
 
-	tokenString, err := GenerateToken(username)
+	tokenString, err := GenerateToken(username, 0)
 	assert.Nil(t, err)
 	assert.NotEmpty(t, tokenString)
 }
@@ -25,7 +25,7 @@ func TestGenerateToken(t *testing.T) {
 func TestValidateToken(t *testing.T) {
 	username := "test_user"
 
-	tokenString, err := GenerateToken(username)
+	tokenString, err := GenerateToken(username, 0)
 	assert.Nil(t, err)
 	err = ValidateToken(tokenString)
 	assert.Nil(t, err)
@@ -67,7 +67,7 @@ func TestGenerateToken1(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				got, err := GenerateToken(tt.args.username)
+				got, err := GenerateToken(tt.args.username, 0)
 				if !tt.wantErr(t, err, fmt.Sprintf("GenerateToken(%v)", tt.args.username)) {
 					return
 				}
@@ -79,7 +79,7 @@ func TestGenerateToken1(t *testing.T) {
 
 func TestGetUserFromToken(t *testing.T) {
 	username := "test_user"
-	validToken, err := GenerateToken(username)
+	validToken, err := GenerateToken(username, 0)
 	assert.Nil(t, err)
 
 	type args struct {
@@ -121,9 +121,21 @@ func TestGetUserFromToken(t *testing.T) {
 	}
 }
 
+func TestParseToken(t *testing.T) {
+	token, err := GenerateToken("test_user", 3)
+	assert.NoError(t, err)
+	claims, err := ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_user", claims.UserID)
+	assert.Equal(t, uint(3), claims.TokenVersion)
+
+	_, err = ParseToken("invalid_token")
+	assert.Error(t, err)
+}
+
 func TestValidateToken1(t *testing.T) {
 	validUsername := "test_user"
-	validToken, err := GenerateToken(validUsername)
+	validToken, err := GenerateToken(validUsername, 0)
 	assert.Nil(t, err)
 
 	expiredToken := jwt.NewWithClaims(
@@ -0,0 +1,104 @@
+package security
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+const sealKeyInfo = "auth-keeper sealed key"
+
+var ErrInvalidPublicKey = errors.New("invalid public key")
+
+// GenerateKeyPair returns a new X25519 key pair. The public key is given to
+// the server so that other users can seal keys to it; the private key never
+// leaves the client.
+func GenerateKeyPair() (publicKey, privateKey []byte, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key.PublicKey().Bytes(), key.Bytes(), nil
+}
+
+// PublicKey returns the public key of an X25519 private key.
+func PublicKey(privateKey []byte) ([]byte, error) {
+	key, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return key.PublicKey().Bytes(), nil
+}
+
+// ValidatePublicKey checks that publicKey is an X25519 public key.
+func ValidatePublicKey(publicKey []byte) error {
+	if _, err := ecdh.X25519().NewPublicKey(publicKey); err != nil {
+		return ErrInvalidPublicKey
+	}
+	return nil
+}
+
+// SealToPublicKey encrypts data so that only the holder of the private key
+// of publicKey can read it. A fresh ephemeral key pair is agreed with
+// publicKey and its public half is prepended to the result.
+func SealToPublicKey(data, publicKey []byte) ([]byte, error) {
+	recipient, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	key, err := sealKey(secret, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := SealData(data, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(ephemeral.PublicKey().Bytes(), sealed...), nil
+}
+
+// OpenWithPrivateKey decrypts data sealed by SealToPublicKey to the public
+// key of privateKey.
+func OpenWithPrivateKey(sealed, privateKey []byte) ([]byte, error) {
+	own, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < KeySize {
+		return nil, ErrSealedData
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[:KeySize])
+	if err != nil {
+		return nil, ErrSealedData
+	}
+	secret, err := own.ECDH(ephemeral)
+	if err != nil {
+		return nil, ErrSealedData
+	}
+	key, err := sealKey(secret, ephemeral, own.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	return OpenData(sealed[KeySize:], key)
+}
+
+// sealKey derives the key of a sealed message.
+func sealKey(secret []byte, ephemeral, recipient *ecdh.PublicKey) ([]byte, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(sealKeyInfo)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSealToPublicKey(t *testing.T) {
+	alicePub, alicePriv, err := GenerateKeyPair()
+	require.NoError(t, err)
+	_, bobPriv, err := GenerateKeyPair()
+	require.NoError(t, err)
+	require.NoError(t, ValidatePublicKey(alicePub))
+	derived, err := PublicKey(alicePriv)
+	require.NoError(t, err)
+	assert.Equal(t, alicePub, derived)
+
+	sealed, err := SealToPublicKey([]byte("item key"), alicePub)
+	require.NoError(t, err)
+	got, err := OpenWithPrivateKey(sealed, alicePriv)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("item key"), got)
+
+	_, err = OpenWithPrivateKey(sealed, bobPriv)
+	assert.ErrorIs(t, err, ErrSealedData)
+	sealed[len(sealed)-1] ^= 1
+	_, err = OpenWithPrivateKey(sealed, alicePriv)
+	assert.ErrorIs(t, err, ErrSealedData)
+	_, err = OpenWithPrivateKey(sealed[:10], alicePriv)
+	assert.ErrorIs(t, err, ErrSealedData)
+
+	_, err = SealToPublicKey([]byte("item key"), []byte("short"))
+	assert.ErrorIs(t, err, ErrInvalidPublicKey)
+	assert.ErrorIs(t, ValidatePublicKey(make([]byte, 31)), ErrInvalidPublicKey)
+}
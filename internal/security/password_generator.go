@@ -0,0 +1,172 @@
+package security
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!@#$%^&*()-_=+[]{};:,.?/"
+	// ambiguousChars look alike in many fonts and are easy to mistype.
+	ambiguousChars = "Il1O0o|`'\""
+)
+
+const (
+	DefaultPasswordLength   = 20
+	DefaultPassphraseWords  = 8
+	DefaultPassphraseSep    = "-"
+	minGeneratedPasswordLen = 4
+)
+
+var (
+	ErrNoCharacterClasses = errors.New("at least one character class must be enabled")
+	ErrPasswordTooShort   = errors.New("password length is too short for the selected character classes")
+	ErrTooFewWords        = errors.New("passphrase must contain at least one word")
+)
+
+//go:embed wordlist.txt
+var wordlistData string
+
+var wordlist = strings.Fields(wordlistData)
+
+type PasswordOptions struct {
+	Length           int
+	Lower            bool
+	Upper            bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+}
+
+func DefaultPasswordOptions() PasswordOptions {
+	return PasswordOptions{
+		Length:  DefaultPasswordLength,
+		Lower:   true,
+		Upper:   true,
+		Digits:  true,
+		Symbols: true,
+	}
+}
+
+// GeneratePassword returns a random password that contains at least one
+// character of every enabled class.
+func GeneratePassword(opts PasswordOptions) (string, error) {
+	var classes []string
+	for _, class := range []struct {
+		enabled bool
+		chars   string
+	}{
+		{opts.Lower, lowerChars},
+		{opts.Upper, upperChars},
+		{opts.Digits, digitChars},
+		{opts.Symbols, symbolChars},
+	} {
+		if !class.enabled {
+			continue
+		}
+		chars := class.chars
+		if opts.ExcludeAmbiguous {
+			chars = removeChars(chars, ambiguousChars)
+		}
+		classes = append(classes, chars)
+	}
+
+	if len(classes) == 0 {
+		return "", ErrNoCharacterClasses
+	}
+	if opts.Length < minGeneratedPasswordLen || opts.Length < len(classes) {
+		return "", ErrPasswordTooShort
+	}
+
+	password := make([]byte, 0, opts.Length)
+	for _, chars := range classes {
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	all := strings.Join(classes, "")
+	for len(password) < opts.Length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	if err := shuffle(password); err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// GeneratePassphrase returns a passphrase of words picked at random from
+// the embedded wordlist. The list is shorter than a diceware list, so each
+// word adds PassphraseBits(1), about 10.5 bits, rather than 12.9; the
+// default number of words makes up for it.
+func GeneratePassphrase(words int, separator string) (string, error) {
+	if words < 1 {
+		return "", ErrTooFewWords
+	}
+	chosen := make([]string, words)
+	for i := range chosen {
+		n, err := randomInt(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		chosen[i] = wordlist[n]
+	}
+	return strings.Join(chosen, separator), nil
+}
+
+// PassphraseBits is the entropy in bits of a passphrase of the given
+// number of words generated by GeneratePassphrase.
+func PassphraseBits(words int) float64 {
+	return float64(words) * math.Log2(float64(len(wordlist)))
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	n, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n], nil
+}
+
+func shuffle(b []byte) error {
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
+
+func removeChars(s, exclude string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if !strings.ContainsRune(exclude, r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+package security
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PasswordOptions
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Default options",
+			opts:    DefaultPasswordOptions(),
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Digits only",
+			opts: PasswordOptions{
+				Length: 8,
+				Digits: true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Exclude ambiguous",
+			opts: PasswordOptions{
+				Length:           64,
+				Lower:            true,
+				Upper:            true,
+				Digits:           true,
+				ExcludeAmbiguous: true,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "No character classes",
+			opts:    PasswordOptions{Length: 16},
+			wantErr: assert.Error,
+		},
+		{
+			name: "Too short",
+			opts: PasswordOptions{
+				Length: 3,
+				Lower:  true,
+			},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := GeneratePassword(tt.opts)
+				if !tt.wantErr(t, err, fmt.Sprintf("GeneratePassword(%+v)", tt.opts)) {
+					return
+				}
+				if err != nil {
+					return
+				}
+				assert.Len(t, got, tt.opts.Length)
+				if tt.opts.Lower {
+					assert.True(t, strings.ContainsAny(got, lowerChars), "missing lowercase")
+				}
+				if tt.opts.Upper {
+					assert.True(t, strings.ContainsAny(got, upperChars), "missing uppercase")
+				}
+				if tt.opts.Digits {
+					assert.True(t, strings.ContainsAny(got, digitChars), "missing digit")
+				}
+				if tt.opts.Symbols {
+					assert.True(t, strings.ContainsAny(got, symbolChars), "missing symbol")
+				} else {
+					assert.False(t, strings.ContainsAny(got, symbolChars), "unexpected symbol")
+				}
+				if tt.opts.ExcludeAmbiguous {
+					assert.False(t, strings.ContainsAny(got, ambiguousChars), "unexpected ambiguous char")
+				}
+			},
+		)
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	got, err := GeneratePassphrase(DefaultPassphraseWords, DefaultPassphraseSep)
+	assert.NoError(t, err)
+	words := strings.Split(got, DefaultPassphraseSep)
+	assert.Len(t, words, DefaultPassphraseWords)
+	for _, w := range words {
+		assert.Contains(t, wordlist, w)
+	}
+
+	_, err = GeneratePassphrase(0, " ")
+	assert.ErrorIs(t, err, ErrTooFewWords)
+}
+
+func TestPassphraseBits(t *testing.T) {
+	assert.InDelta(t, math.Log2(float64(len(wordlist))), PassphraseBits(1), 1e-9)
+	// The default is at least as strong as six diceware words.
+	assert.GreaterOrEqual(t, PassphraseBits(DefaultPassphraseWords), 6*math.Log2(7776))
+}
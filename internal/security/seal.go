@@ -0,0 +1,76 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+// KeySize is the length of the keys of SealData and of X25519 keys.
+const KeySize = 32
+
+const nonceKeyInfo = "auth-keeper deterministic nonce"
+
+var ErrSealedData = errors.New("sealed data cannot be decrypted")
+
+// NewItemKey returns a random key for SealData.
+func NewItemKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SealData encrypts and authenticates data with ChaCha20-Poly1305 under
+// key. The random nonce is prepended to the result.
+func SealData(data, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// SealDeterministic seals data like SealData but derives the nonce from
+// the data, so that equal data sealed with the same key give equal results.
+// It lets the server compare values it cannot read, and so only suits short
+// values whose equality may be known. OpenData opens the result.
+func SealDeterministic(data, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonceKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(nonceKeyInfo)), nonceKey); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, nonceKey)
+	mac.Write(data)
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// OpenData decrypts data sealed by SealData.
+func OpenData(sealed, key []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrSealedData
+	}
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrSealedData
+	}
+	return data, nil
+}
@@ -0,0 +1,53 @@
+package security
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSealData(t *testing.T) {
+	key, err := NewItemKey()
+	require.NoError(t, err)
+	other, err := NewItemKey()
+	require.NoError(t, err)
+
+	sealed, err := SealData([]byte(`{"password":"secret"}`), key)
+	require.NoError(t, err)
+	again, err := SealData([]byte(`{"password":"secret"}`), key)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again)
+
+	got, err := OpenData(sealed, key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"password":"secret"}`), got)
+	_, err = OpenData(sealed, other)
+	assert.ErrorIs(t, err, ErrSealedData)
+	_, err = OpenData(nil, key)
+	assert.ErrorIs(t, err, ErrSealedData)
+}
+
+func TestSealDeterministic(t *testing.T) {
+	key, err := NewItemKey()
+	require.NoError(t, err)
+	other, err := NewItemKey()
+	require.NoError(t, err)
+
+	sealed, err := SealDeterministic([]byte("work"), key)
+	require.NoError(t, err)
+	again, err := SealDeterministic([]byte("work"), key)
+	require.NoError(t, err)
+	assert.Equal(t, sealed, again)
+	home, err := SealDeterministic([]byte("home"), key)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, home)
+	byOther, err := SealDeterministic([]byte("work"), other)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, byOther)
+
+	got, err := OpenData(sealed, key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("work"), got)
+	_, err = OpenData(sealed, other)
+	assert.ErrorIs(t, err, ErrSealedData)
+}
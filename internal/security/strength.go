@@ -0,0 +1,503 @@
+package security
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The estimator follows the approach of Dropbox's zxcvbn: the password is
+// split into known patterns (dictionary words, sequences, repeats, keyboard
+// rows, years) and the cheapest combination of patterns determines the
+// number of guesses an attacker would need.
+
+const (
+	// maxStrengthInputLen bounds the work of an estimate, which also runs on
+	// registration before the user is known; the runes beyond it only add
+	// strength.
+	maxStrengthInputLen       = 64
+	bruteforceCardinality     = 10
+	minSubmatchGuessesSingle  = 10
+	minSubmatchGuessesMulti   = 50
+	minGuessesBeforeGrowing   = 10000
+	minYearSpace              = 20
+	offlineSlowHashingPerSec  = 1e4
+	MinAccountPasswordScore   = 3
+	passwordStrengthScoreBest = 4
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsData string
+
+var commonPasswords = rankedDictionary(strings.Fields(commonPasswordsData))
+
+var englishWords = uniformDictionary(wordlist)
+
+// currentYear is replaced in tests.
+var currentYear = func() int { return time.Now().Year() }
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"1234567890",
+}
+
+var l33tTable = map[rune]rune{
+	'4': 'a',
+	'@': 'a',
+	'8': 'b',
+	'(': 'c',
+	'3': 'e',
+	'6': 'g',
+	'1': 'i',
+	'!': 'i',
+	'|': 'l',
+	'0': 'o',
+	'$': 's',
+	'5': 's',
+	'7': 't',
+	'+': 't',
+	'2': 'z',
+}
+
+type PasswordStrength struct {
+	Score       int      `json:"score"`
+	Guesses     float64  `json:"guesses"`
+	CrackTime   string   `json:"crack_time"`
+	Warning     string   `json:"warning,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+func (ps PasswordStrength) String() string {
+	s := fmt.Sprintf(
+		"%d/%d (about %.0e guesses, %s to crack)",
+		ps.Score, passwordStrengthScoreBest, ps.Guesses, ps.CrackTime,
+	)
+	if ps.Warning != "" {
+		s += ": " + ps.Warning
+	}
+	return s
+}
+
+type strengthMatch struct {
+	pattern string
+	i, j    int
+	guesses float64
+}
+
+// EstimateStrength returns a zxcvbn-style estimate for the password.
+// userInputs such as the username or email are treated as known words.
+func EstimateStrength(password string, userInputs ...string) PasswordStrength {
+	runes := []rune(password)
+	if len(runes) > maxStrengthInputLen {
+		runes = runes[:maxStrengthInputLen]
+	}
+
+	inputs := make(map[string]int)
+	for _, in := range userInputs {
+		in = strings.ToLower(strings.TrimSpace(in))
+		if len(in) > 0 {
+			inputs[in] = 1
+		}
+	}
+
+	var matches []strengthMatch
+	matches = append(matches, dictionaryMatches(runes, "common", commonPasswords)...)
+	matches = append(matches, dictionaryMatches(runes, "word", englishWords)...)
+	matches = append(matches, dictionaryMatches(runes, "user_input", inputs)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	guesses, seq := mostGuessableSequence(runes, matches)
+	strength := PasswordStrength{
+		Score:     guessesToScore(guesses),
+		Guesses:   guesses,
+		CrackTime: displayCrackTime(guesses / offlineSlowHashingPerSec),
+	}
+	strength.Warning, strength.Suggestions = strengthFeedback(strength.Score, seq)
+	return strength
+}
+
+func rankedDictionary(words []string) map[string]int {
+	d := make(map[string]int, len(words))
+	for i, w := range words {
+		if _, ok := d[w]; !ok {
+			d[w] = i + 1
+		}
+	}
+	return d
+}
+
+func uniformDictionary(words []string) map[string]int {
+	d := make(map[string]int, len(words))
+	for _, w := range words {
+		d[w] = len(words)
+	}
+	return d
+}
+
+func dictionaryMatches(runes []rune, pattern string, dict map[string]int) []strengthMatch {
+	var res []strengthMatch
+	lower := []rune(strings.ToLower(string(runes)))
+	unleeted := make([]rune, len(lower))
+	for i, r := range lower {
+		if sub, ok := l33tTable[r]; ok {
+			unleeted[i] = sub
+		} else {
+			unleeted[i] = r
+		}
+	}
+
+	for i := 0; i < len(lower); i++ {
+		for j := i + 1; j <= len(lower); j++ {
+			token := string(runes[i:j])
+			variations := uppercaseVariations(token)
+
+			if rank, ok := dict[string(lower[i:j])]; ok {
+				res = append(res, strengthMatch{pattern, i, j - 1, float64(rank) * variations})
+			}
+			if rank, ok := dict[reverseString(string(lower[i:j]))]; ok && j-i > 1 {
+				res = append(res, strengthMatch{pattern, i, j - 1, float64(rank) * variations * 2})
+			}
+			subs := 0
+			for k := i; k < j; k++ {
+				if unleeted[k] != lower[k] {
+					subs++
+				}
+			}
+			if subs > 0 && j-i > 1 {
+				if rank, ok := dict[string(unleeted[i:j])]; ok {
+					res = append(
+						res,
+						strengthMatch{pattern, i, j - 1, float64(rank) * variations * math.Pow(2, float64(subs))},
+					)
+				}
+			}
+		}
+	}
+	return res
+}
+
+func uppercaseVariations(token string) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	first := []rune(token)[0]
+	if lower == 0 || unicode.IsUpper(first) && upper == 1 {
+		return 2
+	}
+	var variations float64
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return math.Max(variations, 1)
+}
+
+func sequenceMatches(runes []rune) []strengthMatch {
+	var res []strengthMatch
+	if len(runes) < 3 {
+		return res
+	}
+	start := 0
+	for start < len(runes)-2 {
+		delta := int(runes[start+1]) - int(runes[start])
+		end := start + 1
+		if delta == 1 || delta == -1 {
+			for end+1 < len(runes) && int(runes[end+1])-int(runes[end]) == delta &&
+				sameClass(runes[end], runes[end+1]) {
+				end++
+			}
+		}
+		if end-start >= 2 && sameClass(runes[start], runes[start+1]) {
+			length := end - start + 1
+			first := runes[start]
+			var base float64
+			switch {
+			case strings.ContainsRune("aAzZ019", first):
+				base = 4
+			case unicode.IsDigit(first):
+				base = 10
+			default:
+				base = 26
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			res = append(res, strengthMatch{"sequence", start, end, base * float64(length)})
+			start = end
+			continue
+		}
+		start++
+	}
+	return res
+}
+
+func repeatMatches(runes []rune) []strengthMatch {
+	var res []strengthMatch
+	for i := 0; i < len(runes); i++ {
+		for period := 1; i+2*period <= len(runes); period++ {
+			j := i + period
+			for j < len(runes) && runes[j] == runes[j-period] {
+				j++
+			}
+			repeats := (j - i) / period
+			minRepeats := 2
+			if period == 1 {
+				minRepeats = 3
+			}
+			if repeats < minRepeats {
+				continue
+			}
+			end := i + repeats*period - 1
+			res = append(res, strengthMatch{"repeat", i, end, bruteforceGuesses(period) * float64(repeats)})
+		}
+	}
+	return res
+}
+
+func keyboardMatches(runes []rune) []strengthMatch {
+	var res []strengthMatch
+	lower := []rune(strings.ToLower(string(runes)))
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverseString(row)} {
+			for i := 0; i < len(lower); i++ {
+				pos := strings.IndexRune(r, lower[i])
+				if pos < 0 {
+					continue
+				}
+				j := i
+				for j+1 < len(lower) && pos+(j+1-i) < len(r) && rune(r[pos+(j+1-i)]) == lower[j+1] {
+					j++
+				}
+				if j-i >= 2 {
+					length := float64(j - i + 1)
+					res = append(res, strengthMatch{"keyboard", i, j, 47 * length * 2})
+				}
+			}
+		}
+	}
+	return res
+}
+
+func yearMatches(runes []rune) []strengthMatch {
+	var res []strengthMatch
+	now := currentYear()
+	for i := 0; i+4 <= len(runes); i++ {
+		if strings.Trim(string(runes[i:i+4]), "0123456789") != "" {
+			continue
+		}
+		year, err := strconv.Atoi(string(runes[i : i+4]))
+		if err != nil || year < 1900 || year > 2050 {
+			continue
+		}
+		space := math.Max(math.Abs(float64(year-now)), minYearSpace)
+		res = append(res, strengthMatch{"year", i, i + 3, space})
+	}
+	return res
+}
+
+// mostGuessableSequence finds the cheapest cover of runes by matches.
+func mostGuessableSequence(runes []rune, matches []strengthMatch) (float64, []strengthMatch) {
+	n := len(runes)
+	if n == 0 {
+		return 1, nil
+	}
+
+	byEnd := make([][]strengthMatch, n)
+	for _, m := range matches {
+		m.guesses = math.Max(m.guesses, minMatchGuesses(m))
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			byEnd[j] = append(byEnd[j], strengthMatch{"bruteforce", i, j, bruteforceGuesses(j - i + 1)})
+		}
+	}
+
+	// best[k][l] is the cheapest way to cover the first k runes with l
+	// matches, the last of which is m.
+	type state struct {
+		pi float64
+		m  strengthMatch
+	}
+	best := make([]map[int]state, n+1)
+	best[0] = map[int]state{0: {pi: 1}}
+	for k := 1; k <= n; k++ {
+		best[k] = make(map[int]state)
+		for _, m := range byEnd[k-1] {
+			for l, prev := range best[m.i] {
+				pi := prev.pi * m.guesses
+				if cur, ok := best[k][l+1]; !ok || pi < cur.pi {
+					best[k][l+1] = state{pi: pi, m: m}
+				}
+			}
+		}
+	}
+
+	guesses, length := math.Inf(1), 0
+	for l, s := range best[n] {
+		g := factorial(l) * s.pi
+		if l > 1 {
+			g += math.Pow(minGuessesBeforeGrowing, float64(l-1))
+		}
+		if g < guesses {
+			guesses, length = g, l
+		}
+	}
+	seq := make([]strengthMatch, length)
+	for k, l := n, length; l > 0; l-- {
+		seq[l-1] = best[k][l].m
+		k = seq[l-1].i
+	}
+	return guesses, seq
+}
+
+// bruteforceGuesses is the number of guesses for n runes of no pattern.
+func bruteforceGuesses(n int) float64 {
+	m := strengthMatch{"bruteforce", 0, n - 1, math.Pow(bruteforceCardinality, float64(n))}
+	return math.Max(m.guesses, minMatchGuesses(m))
+}
+
+func minMatchGuesses(m strengthMatch) float64 {
+	if m.j-m.i == 0 {
+		return minSubmatchGuessesSingle
+	}
+	return minSubmatchGuessesMulti
+}
+
+func guessesToScore(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return 0
+	case guesses < 1e6+delta:
+		return 1
+	case guesses < 1e8+delta:
+		return 2
+	case guesses < 1e10+delta:
+		return 3
+	default:
+		return passwordStrengthScoreBest
+	}
+}
+
+func displayCrackTime(seconds float64) string {
+	const (
+		minute = 60
+		hour   = minute * 60
+		day    = hour * 24
+		month  = day * 31
+		year   = month * 12
+	)
+	switch {
+	case seconds < 1:
+		return "less than a second"
+	case seconds < minute:
+		return fmt.Sprintf("%.0f seconds", seconds)
+	case seconds < hour:
+		return fmt.Sprintf("%.0f minutes", seconds/minute)
+	case seconds < day:
+		return fmt.Sprintf("%.0f hours", seconds/hour)
+	case seconds < month:
+		return fmt.Sprintf("%.0f days", seconds/day)
+	case seconds < year:
+		return fmt.Sprintf("%.0f months", seconds/month)
+	case seconds < 100*year:
+		return fmt.Sprintf("%.0f years", seconds/year)
+	default:
+		return "centuries"
+	}
+}
+
+func strengthFeedback(score int, seq []strengthMatch) (string, []string) {
+	if score > 2 {
+		return "", nil
+	}
+	suggestions := []string{"Add another word or two. Uncommon words are better."}
+	if len(seq) == 0 {
+		return "", []string{"Use a few words, avoid common phrases"}
+	}
+
+	longest := seq[0]
+	for _, m := range seq[1:] {
+		if m.j-m.i > longest.j-longest.i {
+			longest = m
+		}
+	}
+	switch longest.pattern {
+	case "common":
+		return "This is a very common password", suggestions
+	case "word":
+		return "A word by itself is easy to guess", suggestions
+	case "user_input":
+		return "Avoid using personal information in passwords", suggestions
+	case "sequence":
+		return "Sequences like abc or 6543 are easy to guess", append(suggestions, "Avoid sequences")
+	case "repeat":
+		return "Repeats like \"aaa\" or \"abcabc\" are easy to guess", append(
+			suggestions,
+			"Avoid repeated words and characters",
+		)
+	case "keyboard":
+		return "Straight rows of keys are easy to guess", append(
+			suggestions,
+			"Use a longer keyboard pattern with more turns",
+		)
+	case "year":
+		return "Recent years are easy to guess", append(
+			suggestions,
+			"Avoid years that are associated with you",
+		)
+	}
+	return "", suggestions
+}
+
+func sameClass(a, b rune) bool {
+	return unicode.IsDigit(a) && unicode.IsDigit(b) ||
+		unicode.IsLower(a) && unicode.IsLower(b) ||
+		unicode.IsUpper(a) && unicode.IsUpper(b)
+}
+
+func reverseString(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r = r * float64(n-k+d) / float64(d)
+	}
+	return r
+}
@@ -0,0 +1,109 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateStrength(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		userInputs  []string
+		maxScore    int
+		minScore    int
+		wantWarning bool
+	}{
+		{
+			name:        "Common password",
+			password:    "password",
+			maxScore:    0,
+			wantWarning: true,
+		},
+		{
+			name:        "L33t common password",
+			password:    "P@ssw0rd",
+			maxScore:    1,
+			wantWarning: true,
+		},
+		{
+			name:        "Sequence",
+			password:    "abcdefgh",
+			maxScore:    1,
+			wantWarning: true,
+		},
+		{
+			name:        "Repeat",
+			password:    "zzzzzzzzzz",
+			maxScore:    1,
+			wantWarning: true,
+		},
+		{
+			name:        "Long repeat",
+			password:    strings.Repeat("a", 1000),
+			maxScore:    0,
+			wantWarning: true,
+		},
+		{
+			name:        "Keyboard row",
+			password:    "asdfghjkl",
+			maxScore:    1,
+			wantWarning: true,
+		},
+		{
+			name:        "User input",
+			password:    "rocketman",
+			userInputs:  []string{"rocketman"},
+			maxScore:    0,
+			wantWarning: true,
+		},
+		{
+			name:     "Random password",
+			password: "xK9#mQ2$vL7!pR4@",
+			minScore: 4,
+			maxScore: 4,
+		},
+		{
+			name:     "Long passphrase",
+			password: "violet-anvil-harbor-quiver-tundra",
+			minScore: 4,
+			maxScore: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := EstimateStrength(tt.password, tt.userInputs...)
+				assert.GreaterOrEqual(t, got.Score, tt.minScore, got.String())
+				assert.LessOrEqual(t, got.Score, tt.maxScore, got.String())
+				assert.Equal(t, tt.wantWarning, got.Warning != "", got.String())
+				assert.NotEmpty(t, got.CrackTime)
+			},
+		)
+	}
+}
+
+func TestEstimateStrengthGeneratedPassword(t *testing.T) {
+	password, err := GeneratePassword(DefaultPasswordOptions())
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, EstimateStrength(password).Score, MinAccountPasswordScore)
+
+	passphrase, err := GeneratePassphrase(DefaultPassphraseWords, DefaultPassphraseSep)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, EstimateStrength(passphrase).Score, MinAccountPasswordScore)
+}
+
+func TestYearMatchesCurrentYear(t *testing.T) {
+	defer func(orig func() int) { currentYear = orig }(currentYear)
+	currentYear = func() int { return 2040 }
+
+	matches := yearMatches([]rune("1990"))
+	assert.Len(t, matches, 1)
+	assert.Equal(t, float64(50), matches[0].guesses)
+
+	matches = yearMatches([]rune("2039"))
+	assert.Len(t, matches, 1)
+	assert.Equal(t, float64(minYearSpace), matches[0].guesses)
+}
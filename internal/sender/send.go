@@ -1,10 +1,12 @@
 package sender
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/levigross/grequests"
 	"net/http"
 	"net/http/cookiejar"
+	"os"
 	"strings"
 	"time"
 )
@@ -28,20 +30,18 @@ func NewClient(baseURL string) *Client {
 func (c *Client) sendRequest(
 	method,
 	endpoint string,
-	data interface{},
+	ro *grequests.RequestOptions,
 	token string,
 ) (*grequests.Response, error) {
 	var err error
 	var resp *grequests.Response
 
-	ro := &grequests.RequestOptions{
-		JSON:       data,
-		HTTPClient: c.HTTPClient,
-	}
+	ro.HTTPClient = c.HTTPClient
 	if token != "" {
-		ro.Headers = map[string]string{
-			"Authorization": "Bearer " + token,
+		if ro.Headers == nil {
+			ro.Headers = map[string]string{}
 		}
+		ro.Headers["Authorization"] = "Bearer " + token
 	}
 
 	urlApp := fmt.Sprintf("%s%s", c.BaseURL, endpoint)
@@ -51,6 +51,10 @@ func (c *Client) sendRequest(
 		resp, err = grequests.Post(urlApp, ro)
 	case "GET":
 		resp, err = grequests.Get(urlApp, ro)
+	case "PUT":
+		resp, err = grequests.Put(urlApp, ro)
+	case "DELETE":
+		resp, err = grequests.Delete(urlApp, ro)
 	default:
 		return nil, fmt.Errorf("unsupported HTTP method: %s", method)
 	}
@@ -68,6 +72,55 @@ func (c *Client) SendRequest(
 	endpoint string,
 	data interface{},
 	token string,
+) (*grequests.Response, error) {
+	return c.withRetries(
+		func() (*grequests.Response, error) {
+			return c.sendRequest(method, endpoint, &grequests.RequestOptions{JSON: data}, token)
+		},
+	)
+}
+
+// SendWithHeaders sends data like SendRequest with the given extra
+// headers.
+func (c *Client) SendWithHeaders(
+	method,
+	endpoint string,
+	data interface{},
+	headers map[string]string,
+	token string,
+) (*grequests.Response, error) {
+	return c.withRetries(
+		func() (*grequests.Response, error) {
+			ro := &grequests.RequestOptions{JSON: data, Headers: map[string]string{}}
+			for k, v := range headers {
+				ro.Headers[k] = v
+			}
+			return c.sendRequest(method, endpoint, ro, token)
+		},
+	)
+}
+
+// SendBytes sends body as is with the application/octet-stream content
+// type, retrying like SendRequest.
+func (c *Client) SendBytes(
+	method,
+	endpoint string,
+	body []byte,
+	token string,
+) (*grequests.Response, error) {
+	return c.withRetries(
+		func() (*grequests.Response, error) {
+			ro := &grequests.RequestOptions{
+				RequestBody: bytes.NewReader(body),
+				Headers:     map[string]string{"Content-Type": "application/octet-stream"},
+			}
+			return c.sendRequest(method, endpoint, ro, token)
+		},
+	)
+}
+
+func (c *Client) withRetries(
+	send func() (*grequests.Response, error),
 ) (*grequests.Response, error) {
 	retryDelays := []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
 	maxRetries := 3
@@ -76,16 +129,16 @@ func (c *Client) SendRequest(
 	var err error
 
 	for retry := 0; retry < maxRetries; retry++ {
-		fmt.Printf("Attempt %d/%d\n", retry+1, maxRetries)
+		fmt.Fprintf(os.Stderr, "Attempt %d/%d\n", retry+1, maxRetries)
 
 		if retry > 0 {
 			time.Sleep(retryDelays[retry-1])
 		}
 
-		resp, err = c.sendRequest(method, endpoint, data, token)
+		resp, err = send()
 
 		if err != nil {
-			fmt.Printf("Error on attempt %d: %v\n", retry+1, err)
+			fmt.Fprintf(os.Stderr, "Error on attempt %d: %v\n", retry+1, err)
 
 			if retry == maxRetries-1 {
 				return nil, fmt.Errorf("error sending request: %v", err)
@@ -93,7 +146,7 @@ func (c *Client) SendRequest(
 			continue
 		}
 
-		fmt.Printf("Success on attempt %d\n", retry+1)
+		fmt.Fprintf(os.Stderr, "Success on attempt %d\n", retry+1)
 		break
 	}
 	return resp, err
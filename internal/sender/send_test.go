@@ -3,6 +3,7 @@ package sender
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -14,9 +15,9 @@ func newTestClient() *Client {
 
 func TestUnsupportedMethod(t *testing.T) {
 	client := newTestClient()
-	_, err := client.SendRequest("PUT", "/test", nil, "")
+	_, err := client.SendRequest("PATCH", "/test", nil, "")
 	assert.NotNil(t, err)
-	assert.Equal(t, "error sending request: unsupported HTTP method: PUT", err.Error())
+	assert.Equal(t, "error sending request: unsupported HTTP method: PATCH", err.Error())
 }
 
 func TestSendGetRequest(t *testing.T) {
@@ -56,3 +57,68 @@ func TestSendPostRequest(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, resp.StatusCode)
 	assert.Equal(t, "test-token", client.AuthToken)
 }
+
+func TestSendPutAndDeleteRequests(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	resp, err := client.SendRequest("PUT", "/", map[string]string{"key": "value"}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = client.SendRequest("DELETE", "/", nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"PUT", "DELETE"}, methods)
+}
+
+func TestSendBytes(t *testing.T) {
+	var body []byte
+	var contentType, auth string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				contentType = r.Header.Get("Content-Type")
+				auth = r.Header.Get("Authorization")
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	resp, err := client.SendBytes("PUT", "/", []byte{0, 1, 2}, "token")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []byte{0, 1, 2}, body)
+	assert.Equal(t, "application/octet-stream", contentType)
+	assert.Equal(t, "Bearer token", auth)
+}
+
+func TestSendWithHeaders(t *testing.T) {
+	var header string
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Get("X-Test")
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	resp, err := client.SendWithHeaders("GET", "/", nil, map[string]string{"X-Test": "value"}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "value", header)
+}
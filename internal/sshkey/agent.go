@@ -0,0 +1,93 @@
+package sshkey
+
+import (
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"io"
+	"log"
+	"net"
+)
+
+var ErrSignDenied = errors.New("signing request denied")
+
+// ConfirmFunc is asked before every signature when per-use confirmation is
+// enabled; returning false refuses the request.
+type ConfirmFunc func(key *agent.Key) bool
+
+// Agent serves vault keys over the OpenSSH agent protocol.
+type Agent struct {
+	agent.ExtendedAgent
+	confirm ConfirmFunc
+}
+
+// NewAgent loads keys into an in-memory keyring. confirm may be nil.
+func NewAgent(keys []*models.SSHKey, confirm ConfirmFunc) (*Agent, error) {
+	keyring := agent.NewKeyring().(agent.ExtendedAgent)
+	for _, k := range keys {
+		raw, err := ssh.ParseRawPrivateKey([]byte(k.PrivateKey))
+		if err != nil {
+			return nil, err
+		}
+		err = keyring.Add(
+			agent.AddedKey{
+				PrivateKey:       raw,
+				Comment:          Label(k),
+				ConfirmBeforeUse: confirm != nil,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Agent{ExtendedAgent: keyring, confirm: confirm}, nil
+}
+
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+func (a *Agent) SignWithFlags(
+	key ssh.PublicKey,
+	data []byte,
+	flags agent.SignatureFlags,
+) (*ssh.Signature, error) {
+	if a.confirm != nil && !a.confirm(a.describe(key)) {
+		return nil, ErrSignDenied
+	}
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
+func (a *Agent) describe(key ssh.PublicKey) *agent.Key {
+	keys, err := a.List()
+	if err == nil {
+		want := string(key.Marshal())
+		for _, k := range keys {
+			if string(k.Marshal()) == want {
+				return k
+			}
+		}
+	}
+	return &agent.Key{Format: key.Type(), Blob: key.Marshal()}
+}
+
+// Serve accepts agent connections on l until it is closed.
+func (a *Agent) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			err := agent.ServeAgent(a, conn)
+			if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("ssh-agent connection closed: %v", err)
+			}
+		}()
+	}
+}
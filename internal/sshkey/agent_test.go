@@ -0,0 +1,71 @@
+package sshkey
+
+import (
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh/agent"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func startAgent(t *testing.T, keys []*models.SSHKey, confirm ConfirmFunc) agent.ExtendedAgent {
+	a, err := NewAgent(keys, confirm)
+	require.NoError(t, err)
+
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "agent.sock"))
+	require.NoError(t, err)
+	go a.Serve(l)
+	t.Cleanup(func() { l.Close() })
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return agent.NewClient(conn)
+}
+
+func TestAgent_ListAndSign(t *testing.T) {
+	key, err := Generate(TypeEd25519, 0, "work")
+	require.NoError(t, err)
+	client := startAgent(t, []*models.SSHKey{key}, nil)
+
+	keys, err := client.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "work", keys[0].Comment)
+
+	sig, err := client.Sign(keys[0], []byte("challenge"))
+	require.NoError(t, err)
+
+	signer, err := Signer(key)
+	require.NoError(t, err)
+	assert.NoError(t, signer.PublicKey().Verify([]byte("challenge"), sig))
+}
+
+func TestAgent_Confirm(t *testing.T) {
+	key, err := Generate(TypeEd25519, 0, "work")
+	require.NoError(t, err)
+
+	var asked []string
+	allow := false
+	client := startAgent(
+		t, []*models.SSHKey{key}, func(k *agent.Key) bool {
+			asked = append(asked, k.Comment)
+			return allow
+		},
+	)
+
+	signer, err := Signer(key)
+	require.NoError(t, err)
+	pub := signer.PublicKey()
+
+	_, err = client.Sign(pub, []byte("challenge"))
+	assert.Error(t, err)
+
+	allow = true
+	sig, err := client.Sign(pub, []byte("challenge"))
+	require.NoError(t, err)
+	assert.NoError(t, pub.Verify([]byte("challenge"), sig))
+	assert.Equal(t, []string{"work", "work"}, asked)
+}
@@ -0,0 +1,181 @@
+package sshkey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"golang.org/x/crypto/ssh"
+	"strings"
+)
+
+const (
+	TypeEd25519 = "ed25519"
+	TypeRSA     = "rsa"
+	TypeECDSA   = "ecdsa"
+
+	DefaultRSABits   = 4096
+	DefaultECDSABits = 256
+)
+
+var (
+	ErrUnsupportedType   = errors.New("key type must be ed25519, rsa or ecdsa")
+	ErrInvalidBits       = errors.New("invalid key size")
+	ErrInvalidKey        = errors.New("invalid private key")
+	ErrPublicKeyMismatch = errors.New("public key does not match private key")
+)
+
+// Generate creates a new key pair in OpenSSH format. bits is ignored for
+// ed25519 and defaults per type when zero.
+func Generate(keyType string, bits int, comment string) (*models.SSHKey, error) {
+	var priv crypto.PrivateKey
+	switch strings.ToLower(keyType) {
+	case TypeEd25519, "":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		priv = key
+	case TypeRSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		if bits < 2048 {
+			return nil, fmt.Errorf("%w: rsa keys must be at least 2048 bits", ErrInvalidBits)
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		priv = key
+	case TypeECDSA:
+		var curve elliptic.Curve
+		switch bits {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: ecdsa keys must be 256, 384 or 521 bits", ErrInvalidBits)
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		priv = key
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return nil, err
+	}
+	return FromPrivateKey(pem.EncodeToMemory(block), nil, comment)
+}
+
+// FromPrivateKey parses a PEM encoded private key, decrypting it with
+// passphrase if needed, and returns it re-encoded as an unencrypted OpenSSH
+// key together with its public key and fingerprint.
+func FromPrivateKey(pemBytes, passphrase []byte, comment string) (*models.SSHKey, error) {
+	var (
+		raw interface{}
+		err error
+	)
+	if len(passphrase) > 0 {
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+	} else {
+		raw, err = ssh.ParseRawPrivateKey(pemBytes)
+	}
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	// ParseRawPrivateKey returns ed25519 keys by pointer.
+	if key, ok := raw.(*ed25519.PrivateKey); ok {
+		raw = *key
+	}
+
+	signer, err := ssh.NewSignerFromKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	block, err := ssh.MarshalPrivateKey(raw, comment)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	pub := signer.PublicKey()
+	return &models.SSHKey{
+		KeyType:     pub.Type(),
+		PrivateKey:  string(pem.EncodeToMemory(block)),
+		PublicKey:   authorizedKey(pub, comment),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		Comment:     comment,
+	}, nil
+}
+
+func authorizedKey(pub ssh.PublicKey, comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// Signer parses the stored private key.
+func Signer(k *models.SSHKey) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(k.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return signer, nil
+}
+
+// Normalize derives the public key, fingerprint and key type from the
+// private key so that clients cannot store mismatching values. A supplied
+// public key must match the private one.
+func Normalize(k *models.SSHKey) error {
+	signer, err := Signer(k)
+	if err != nil {
+		return err
+	}
+	pub := signer.PublicKey()
+
+	if k.PublicKey != "" {
+		given, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrPublicKeyMismatch, err)
+		}
+		if ssh.FingerprintSHA256(given) != ssh.FingerprintSHA256(pub) {
+			return ErrPublicKeyMismatch
+		}
+		if k.Comment == "" {
+			k.Comment = comment
+		}
+	}
+
+	k.KeyType = pub.Type()
+	k.PublicKey = authorizedKey(pub, k.Comment)
+	k.Fingerprint = ssh.FingerprintSHA256(pub)
+	return nil
+}
+
+// Label is how a key is shown to the user: its comment or fingerprint.
+func Label(k *models.SSHKey) string {
+	if k.Comment != "" {
+		return k.Comment
+	}
+	return k.Fingerprint
+}
@@ -0,0 +1,108 @@
+package sshkey
+
+import (
+	"encoding/pem"
+	"errors"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyType  string
+		bits     int
+		wantType string
+		wantErr  error
+	}{
+		{name: "ed25519", keyType: TypeEd25519, wantType: ssh.KeyAlgoED25519},
+		{name: "default type", keyType: "", wantType: ssh.KeyAlgoED25519},
+		{name: "rsa", keyType: TypeRSA, bits: 2048, wantType: ssh.KeyAlgoRSA},
+		{name: "ecdsa 384", keyType: TypeECDSA, bits: 384, wantType: ssh.KeyAlgoECDSA384},
+		{name: "short rsa", keyType: TypeRSA, bits: 1024, wantErr: ErrInvalidBits},
+		{name: "bad ecdsa size", keyType: TypeECDSA, bits: 128, wantErr: ErrInvalidBits},
+		{name: "unsupported", keyType: "dsa", wantErr: ErrUnsupportedType},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				key, err := Generate(tt.keyType, tt.bits, "alice@laptop")
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantType, key.KeyType)
+				assert.True(t, strings.HasPrefix(key.PublicKey, tt.wantType+" "))
+				assert.True(t, strings.HasSuffix(key.PublicKey, " alice@laptop"))
+				assert.True(t, strings.HasPrefix(key.Fingerprint, "SHA256:"))
+				assert.Contains(t, key.PrivateKey, "OPENSSH PRIVATE KEY")
+
+				signer, err := Signer(key)
+				require.NoError(t, err)
+				assert.Equal(t, key.Fingerprint, ssh.FingerprintSHA256(signer.PublicKey()))
+			},
+		)
+	}
+}
+
+func TestFromPrivateKeyWithPassphrase(t *testing.T) {
+	key, err := Generate(TypeEd25519, 0, "")
+	require.NoError(t, err)
+	raw, err := ssh.ParseRawPrivateKey([]byte(key.PrivateKey))
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(raw, "", []byte("hunter2"))
+	require.NoError(t, err)
+	encrypted := pem.EncodeToMemory(block)
+
+	_, err = FromPrivateKey(encrypted, nil, "")
+	var missing *ssh.PassphraseMissingError
+	assert.True(t, errors.As(err, &missing))
+
+	imported, err := FromPrivateKey(encrypted, []byte("hunter2"), "imported")
+	require.NoError(t, err)
+	assert.Equal(t, key.Fingerprint, imported.Fingerprint)
+	assert.Equal(t, "imported", imported.Comment)
+
+	_, err = FromPrivateKey([]byte("not a key"), nil, "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestNormalize(t *testing.T) {
+	key, err := Generate(TypeEd25519, 0, "work")
+	require.NoError(t, err)
+	other, err := Generate(TypeEd25519, 0, "other")
+	require.NoError(t, err)
+
+	t.Run(
+		"derives public fields", func(t *testing.T) {
+			k := &models.SSHKey{PrivateKey: key.PrivateKey, Comment: "work"}
+			require.NoError(t, Normalize(k))
+			assert.Equal(t, key.PublicKey, k.PublicKey)
+			assert.Equal(t, key.Fingerprint, k.Fingerprint)
+			assert.Equal(t, ssh.KeyAlgoED25519, k.KeyType)
+		},
+	)
+	t.Run(
+		"takes comment from public key", func(t *testing.T) {
+			k := &models.SSHKey{PrivateKey: key.PrivateKey, PublicKey: key.PublicKey}
+			require.NoError(t, Normalize(k))
+			assert.Equal(t, "work", k.Comment)
+		},
+	)
+	t.Run(
+		"rejects mismatching public key", func(t *testing.T) {
+			k := &models.SSHKey{PrivateKey: key.PrivateKey, PublicKey: other.PublicKey}
+			assert.ErrorIs(t, Normalize(k), ErrPublicKeyMismatch)
+		},
+	)
+	t.Run(
+		"rejects invalid private key", func(t *testing.T) {
+			assert.ErrorIs(t, Normalize(&models.SSHKey{PrivateKey: "garbage"}), ErrInvalidKey)
+		},
+	)
+}
@@ -0,0 +1,83 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/crypto/hkdf"
+	"hash"
+	"io"
+)
+
+const (
+	// CompressionZstd marks contents whose chunks are packed with Pack
+	// before they are sealed or stored.
+	CompressionZstd = "zstd"
+	// PackOverhead is the most a packed chunk is longer than the chunk.
+	PackOverhead = 1
+
+	packRaw  = 0
+	packZstd = 1
+
+	contentKeyInfo = "auth-keeper binary content key"
+)
+
+var ErrInvalidPacking = errors.New("invalid packed chunk")
+
+// encoder is deterministic, so a resumed upload seals the same chunks.
+var encoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+// Pack compresses chunk with zstd when that makes it shorter. The packed
+// chunk starts with a byte telling Unpack whether it is compressed.
+func Pack(chunk []byte) []byte {
+	packed := encoder.EncodeAll(chunk, []byte{packZstd})
+	if len(packed) < len(chunk)+PackOverhead {
+		return packed
+	}
+	return append([]byte{packRaw}, chunk...)
+}
+
+// Unpack returns the chunk packed by Pack. Chunks longer than limit bytes
+// are rejected without being decompressed in full.
+func Unpack(packed []byte, limit int64) ([]byte, error) {
+	if len(packed) == 0 {
+		return nil, ErrInvalidPacking
+	}
+	switch packed[0] {
+	case packRaw:
+		if int64(len(packed)-PackOverhead) > limit {
+			return nil, ErrInvalidPacking
+		}
+		return packed[PackOverhead:], nil
+	case packZstd:
+		dec, err := zstd.NewReader(
+			bytes.NewReader(packed[PackOverhead:]), zstd.WithDecoderConcurrency(1),
+		)
+		if err != nil {
+			return nil, ErrInvalidPacking
+		}
+		defer dec.Close()
+		chunk, err := io.ReadAll(io.LimitReader(dec, limit+1))
+		if err != nil || int64(len(chunk)) > limit {
+			return nil, ErrInvalidPacking
+		}
+		return chunk, nil
+	default:
+		return nil, ErrInvalidPacking
+	}
+}
+
+// NewContentKey returns the keyed hash identifying contents of the owner of
+// personalKey. Equal contents of one user have equal content keys, so they
+// can share storage, while the keys of different users, or of anyone
+// without the personal key, cannot be compared with them.
+func NewContentKey(personalKey []byte) (hash.Hash, error) {
+	key := make([]byte, sha256.Size)
+	r := hkdf.New(sha256.New, personalKey, nil, []byte(contentKeyInfo))
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, err
+	}
+	return hmac.New(sha256.New, key), nil
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPack(t *testing.T) {
+	text := bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----\n"), 100)
+	packed := Pack(text)
+	assert.Less(t, len(packed), len(text)/10)
+	assert.Equal(t, packed, Pack(text))
+	got, err := Unpack(packed, int64(len(text)))
+	require.NoError(t, err)
+	assert.Equal(t, text, got)
+
+	_, err = Unpack(packed, int64(len(text)-1))
+	assert.ErrorIs(t, err, ErrInvalidPacking)
+
+	random := make([]byte, MinChunkSize)
+	_, err = rand.Read(random)
+	require.NoError(t, err)
+	packed = Pack(random)
+	assert.Len(t, packed, len(random)+PackOverhead)
+	got, err = Unpack(packed, int64(len(random)))
+	require.NoError(t, err)
+	assert.Equal(t, random, got)
+
+	packed = Pack(nil)
+	got, err = Unpack(packed, 0)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	for _, invalid := range [][]byte{nil, {2, 0}, {packZstd, 1, 2, 3}} {
+		_, err = Unpack(invalid, MaxChunkSize)
+		assert.ErrorIs(t, err, ErrInvalidPacking)
+	}
+}
+
+func TestNewContentKey(t *testing.T) {
+	sum := func(personalKey, content []byte) string {
+		h, err := NewContentKey(personalKey)
+		require.NoError(t, err)
+		h.Write(content)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	alice := []byte("0123456789abcdef0123456789abcdef")
+	bob := []byte("fedcba9876543210fedcba9876543210")
+	assert.Equal(t, sum(alice, []byte("cert")), sum(alice, []byte("cert")))
+	assert.NotEqual(t, sum(alice, []byte("cert")), sum(alice, []byte("key")))
+	assert.NotEqual(t, sum(alice, []byte("cert")), sum(bob, []byte("cert")))
+}
@@ -0,0 +1,123 @@
+// Package stream encrypts large contents in independently sealed chunks so
+// they can be uploaded, stored and downloaded piece by piece.
+//
+// Every chunk is sealed with ChaCha20-Poly1305 under a key derived from the
+// owner's personal key and a random per-stream salt. The nonce holds a
+// random per-stream prefix, the chunk index and a flag marking the last
+// chunk, so chunks cannot be reordered, dropped, moved between streams or
+// cut off at the end without Open failing.
+package stream
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+const (
+	// DefaultChunkSize is the plaintext size of every chunk but the last.
+	DefaultChunkSize = 1 << 20
+	// MinChunkSize and MaxChunkSize bound the chunk size of a stream.
+	MinChunkSize = 4 << 10
+	MaxChunkSize = 8 << 20
+	// Overhead is the number of bytes a sealed chunk is longer than its
+	// plaintext.
+	Overhead = chacha20poly1305.Overhead
+	// HeaderSize is the length of a stream header: the key salt followed by
+	// the nonce prefix.
+	HeaderSize = saltSize + prefixSize
+
+	saltSize   = 32
+	prefixSize = chacha20poly1305.NonceSize - 5
+	keyInfo    = "auth-keeper binary stream"
+)
+
+var (
+	ErrInvalidHeader = errors.New("invalid stream header")
+	ErrInvalidChunk  = errors.New("chunk cannot be decrypted")
+)
+
+// NewHeader returns a random header for a new stream.
+func NewHeader() ([]byte, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := rand.Read(header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// Chunks returns the number of chunks of a size bytes long content; empty
+// content still has one, empty, last chunk.
+func Chunks(size int64, chunkSize int) int {
+	if size <= 0 {
+		return 1
+	}
+	return int((size + int64(chunkSize) - 1) / int64(chunkSize))
+}
+
+// SealedSize returns the length of sealed chunk number of a size bytes long
+// content, or -1 if the content has no such chunk.
+func SealedSize(size int64, chunkSize, number int) int {
+	chunks := Chunks(size, chunkSize)
+	switch {
+	case number < 0 || number >= chunks:
+		return -1
+	case number < chunks-1:
+		return chunkSize + Overhead
+	default:
+		return int(size-int64(chunks-1)*int64(chunkSize)) + Overhead
+	}
+}
+
+// Cipher seals and opens the chunks of one stream.
+type Cipher struct {
+	aead   cipher.AEAD
+	prefix []byte
+}
+
+// New returns the cipher of the stream with the given header.
+func New(personalKey, header []byte) (*Cipher, error) {
+	if len(header) != HeaderSize {
+		return nil, ErrInvalidHeader
+	}
+	key := make([]byte, chacha20poly1305.KeySize)
+	r := hkdf.New(sha256.New, personalKey, header[:saltSize], []byte(keyInfo))
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, prefix: header[saltSize:]}, nil
+}
+
+func (c *Cipher) nonce(index int, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	copy(nonce, c.prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], uint32(index))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// Seal encrypts the chunk at index; last must be set for the final chunk.
+func (c *Cipher) Seal(index int, chunk []byte, last bool) []byte {
+	return c.aead.Seal(nil, c.nonce(index, last), chunk, nil)
+}
+
+// Open decrypts the chunk at index sealed by Seal.
+func (c *Cipher) Open(index int, sealed []byte, last bool) ([]byte, error) {
+	chunk, err := c.aead.Open(nil, c.nonce(index, last), sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: chunk %d", ErrInvalidChunk, index)
+	}
+	return chunk, nil
+}
@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestChunks(t *testing.T) {
+	assert.Equal(t, 1, Chunks(0, MinChunkSize))
+	assert.Equal(t, 1, Chunks(MinChunkSize, MinChunkSize))
+	assert.Equal(t, 2, Chunks(MinChunkSize+1, MinChunkSize))
+	assert.Equal(t, 3, Chunks(3*MinChunkSize, MinChunkSize))
+}
+
+func TestSealedSize(t *testing.T) {
+	size := int64(2*MinChunkSize + 10)
+	assert.Equal(t, MinChunkSize+Overhead, SealedSize(size, MinChunkSize, 0))
+	assert.Equal(t, MinChunkSize+Overhead, SealedSize(size, MinChunkSize, 1))
+	assert.Equal(t, 10+Overhead, SealedSize(size, MinChunkSize, 2))
+	assert.Equal(t, -1, SealedSize(size, MinChunkSize, 3))
+	assert.Equal(t, -1, SealedSize(size, MinChunkSize, -1))
+	assert.Equal(t, Overhead, SealedSize(0, MinChunkSize, 0))
+}
+
+func TestCipher(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	header, err := NewHeader()
+	require.NoError(t, err)
+	c, err := New(key, header)
+	require.NoError(t, err)
+
+	first := c.Seal(0, []byte("hello "), false)
+	last := c.Seal(1, []byte("world"), true)
+	assert.Len(t, first, len("hello ")+Overhead)
+
+	got, err := c.Open(0, first, false)
+	require.NoError(t, err)
+	assert.Equal(t, "hello ", string(got))
+	got, err = c.Open(1, last, true)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(got))
+
+	_, err = c.Open(1, first, false)
+	assert.ErrorIs(t, err, ErrInvalidChunk, "reordered chunk")
+	_, err = c.Open(0, first, true)
+	assert.ErrorIs(t, err, ErrInvalidChunk, "truncated stream")
+	tampered := bytes.Clone(last)
+	tampered[0] ^= 1
+	_, err = c.Open(1, tampered, true)
+	assert.ErrorIs(t, err, ErrInvalidChunk, "modified chunk")
+
+	otherHeader, err := NewHeader()
+	require.NoError(t, err)
+	other, err := New(key, otherHeader)
+	require.NoError(t, err)
+	_, err = other.Open(0, first, false)
+	assert.ErrorIs(t, err, ErrInvalidChunk, "chunk of another stream")
+
+	_, err = New(key, header[:10])
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
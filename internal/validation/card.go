@@ -0,0 +1,225 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidExpiry = errors.New("expiry date must be in MM/YY or MM/YYYY format")
+
+// DigitsOnly strips spaces and dashes commonly used to group card numbers.
+func DigitsOnly(number string) string {
+	return strings.Map(
+		func(r rune) rune {
+			if r == ' ' || r == '-' {
+				return -1
+			}
+			return r
+		}, number,
+	)
+}
+
+// Luhn reports whether the card number passes the Luhn checksum.
+func Luhn(number string) bool {
+	digits := DigitsOnly(number)
+	if len(digits) < 2 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if d < '0' || d > '9' {
+			return false
+		}
+		n := int(d - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ParseExpiry parses MM/YY or MM/YYYY and returns the first moment after
+// the card stops being valid, i.e. the start of the following month in UTC.
+func ParseExpiry(expiry string) (time.Time, error) {
+	parts := strings.Split(strings.TrimSpace(expiry), "/")
+	if len(parts) != 2 {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	month, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || month < 1 || month > 12 || len(strings.TrimSpace(parts[0])) > 2 {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	yearStr := strings.TrimSpace(parts[1])
+	year, err := strconv.Atoi(yearStr)
+	if err != nil {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	switch len(yearStr) {
+	case 2:
+		year += 2000
+	case 4:
+	default:
+		return time.Time{}, ErrInvalidExpiry
+	}
+	return time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+const (
+	BrandVisa       = "visa"
+	BrandMastercard = "mastercard"
+	BrandAmex       = "amex"
+	BrandMir        = "mir"
+	BrandDiscover   = "discover"
+	BrandJCB        = "jcb"
+	BrandDiners     = "diners"
+	BrandUnionPay   = "unionpay"
+	BrandMaestro    = "maestro"
+	BrandUnknown    = "unknown"
+)
+
+const maxCardHolderLen = 26
+
+type brandRule struct {
+	brand    string
+	prefixes [][2]int
+	lengths  []int
+	cvvLen   int
+}
+
+// brandRules lists more specific prefixes first.
+var brandRules = []brandRule{
+	{BrandAmex, [][2]int{{34, 34}, {37, 37}}, []int{15}, 4},
+	{BrandMir, [][2]int{{2200, 2204}}, []int{16, 17, 18, 19}, 3},
+	{BrandVisa, [][2]int{{4, 4}}, []int{13, 16, 19}, 3},
+	{BrandMastercard, [][2]int{{51, 55}, {2221, 2720}}, []int{16}, 3},
+	{BrandDiscover, [][2]int{{6011, 6011}, {644, 649}, {65, 65}}, []int{16, 17, 18, 19}, 3},
+	{BrandJCB, [][2]int{{3528, 3589}}, []int{16, 17, 18, 19}, 3},
+	{BrandDiners, [][2]int{{300, 305}, {36, 36}, {38, 39}}, []int{14, 15, 16, 17, 18, 19}, 3},
+	{BrandUnionPay, [][2]int{{62, 62}}, []int{16, 17, 18, 19}, 3},
+	{BrandMaestro, [][2]int{{50, 50}, {56, 58}, {63, 63}, {67, 67}}, []int{12, 13, 14, 15, 16, 17, 18, 19}, 3},
+}
+
+var unknownBrandRule = brandRule{BrandUnknown, nil, []int{12, 13, 14, 15, 16, 17, 18, 19}, 3}
+
+func ruleForNumber(number string) brandRule {
+	digits := DigitsOnly(number)
+	for _, rule := range brandRules {
+		for _, p := range rule.prefixes {
+			width := len(strconv.Itoa(p[0]))
+			if len(digits) < width {
+				continue
+			}
+			prefix, err := strconv.Atoi(digits[:width])
+			if err == nil && prefix >= p[0] && prefix <= p[1] {
+				return rule
+			}
+		}
+	}
+	return unknownBrandRule
+}
+
+// DetectBrand returns the card network for the number based on its IIN
+// prefix, or BrandUnknown.
+func DetectBrand(number string) string {
+	return ruleForNumber(number).brand
+}
+
+// FieldErrors maps a JSON field name to the reason it was rejected.
+type FieldErrors map[string]string
+
+func (fe FieldErrors) Error() string {
+	fields := make([]string, 0, len(fe))
+	for f := range fe {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f + ": " + fe[f]
+	}
+	return "invalid fields: " + strings.Join(parts, "; ")
+}
+
+// NormalizeCreditCard brings user input into canonical form: digits-only
+// card number, MM/YY expiry, upper-case single-spaced holder name, and the
+// detected brand.
+func NormalizeCreditCard(cc *models.CreditCard) {
+	cc.CardNumber = DigitsOnly(strings.TrimSpace(cc.CardNumber))
+	cc.CVV = strings.TrimSpace(cc.CVV)
+	cc.CardHolder = strings.ToUpper(strings.Join(strings.Fields(cc.CardHolder), " "))
+	if expiresAt, err := ParseExpiry(cc.ExpiryDate); err == nil {
+		last := expiresAt.AddDate(0, -1, 0)
+		cc.ExpiryDate = fmt.Sprintf("%02d/%02d", int(last.Month()), last.Year()%100)
+	}
+	cc.Brand = DetectBrand(cc.CardNumber)
+}
+
+// ValidateCreditCard checks a normalized card and returns FieldErrors if
+// any field is invalid.
+func ValidateCreditCard(cc *models.CreditCard) error {
+	errs := FieldErrors{}
+	rule := ruleForNumber(cc.CardNumber)
+
+	switch {
+	case cc.CardNumber == "":
+		errs["card_number"] = "is required"
+	case strings.Trim(cc.CardNumber, "0123456789") != "":
+		errs["card_number"] = "must contain only digits"
+	case !containsInt(rule.lengths, len(cc.CardNumber)):
+		errs["card_number"] = fmt.Sprintf("invalid length %d for %s", len(cc.CardNumber), rule.brand)
+	case !Luhn(cc.CardNumber):
+		errs["card_number"] = "fails the Luhn check"
+	}
+
+	if _, err := ParseExpiry(cc.ExpiryDate); err != nil {
+		errs["expiry_date"] = err.Error()
+	}
+
+	switch {
+	case strings.Trim(cc.CVV, "0123456789") != "" || cc.CVV == "":
+		errs["cvv"] = "must contain only digits"
+	case len(cc.CVV) != rule.cvvLen:
+		errs["cvv"] = fmt.Sprintf("must be %d digits for %s", rule.cvvLen, rule.brand)
+	}
+
+	switch {
+	case cc.CardHolder == "":
+		errs["card_holder"] = "is required"
+	case utf8.RuneCountInString(cc.CardHolder) > maxCardHolderLen:
+		errs["card_holder"] = fmt.Sprintf("must be at most %d characters", maxCardHolderLen)
+	case strings.IndexFunc(cc.CardHolder, invalidHolderRune) >= 0:
+		errs["card_holder"] = "contains invalid characters"
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func invalidHolderRune(r rune) bool {
+	return !unicode.IsLetter(r) && !strings.ContainsRune(" .'-", r)
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
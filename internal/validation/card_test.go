@@ -0,0 +1,221 @@
+package validation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/auth-keeper.git/internal/db/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{number: "4111111111111111", want: true},
+		{number: "4111 1111 1111 1111", want: true},
+		{number: "5555-5555-5555-4444", want: true},
+		{number: "378282246310005", want: true},
+		{number: "4111111111111112", want: false},
+		{number: "1234-5678-9876-5432", want: false},
+		{number: "4111a11111111111", want: false},
+		{number: "0", want: false},
+		{number: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.number, func(t *testing.T) {
+				assert.Equalf(t, tt.want, Luhn(tt.number), "Luhn(%v)", tt.number)
+			},
+		)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		expiry  string
+		want    time.Time
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			expiry:  "12/24",
+			want:    time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: assert.NoError,
+		},
+		{
+			expiry:  "01/2030",
+			want:    time.Date(2030, time.February, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: assert.NoError,
+		},
+		{
+			expiry:  " 6/27 ",
+			want:    time.Date(2027, time.July, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: assert.NoError,
+		},
+		{expiry: "13/25", wantErr: assert.Error},
+		{expiry: "00/25", wantErr: assert.Error},
+		{expiry: "12-25", wantErr: assert.Error},
+		{expiry: "12/025", wantErr: assert.Error},
+		{expiry: "ab/cd", wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.expiry, func(t *testing.T) {
+				got, err := ParseExpiry(tt.expiry)
+				if !tt.wantErr(t, err, fmt.Sprintf("ParseExpiry(%v)", tt.expiry)) {
+					return
+				}
+				assert.Equalf(t, tt.want, got, "ParseExpiry(%v)", tt.expiry)
+			},
+		)
+	}
+}
+
+func TestDetectBrand(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{number: "4111111111111111", want: BrandVisa},
+		{number: "5555 5555 5555 4444", want: BrandMastercard},
+		{number: "2221000000000009", want: BrandMastercard},
+		{number: "378282246310005", want: BrandAmex},
+		{number: "2200123456789010", want: BrandMir},
+		{number: "6011111111111117", want: BrandDiscover},
+		{number: "3530111333300000", want: BrandJCB},
+		{number: "30569309025904", want: BrandDiners},
+		{number: "6200000000000005", want: BrandUnionPay},
+		{number: "6759649826438453", want: BrandMaestro},
+		{number: "9999999999999995", want: BrandUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.number, func(t *testing.T) {
+				assert.Equalf(t, tt.want, DetectBrand(tt.number), "DetectBrand(%v)", tt.number)
+			},
+		)
+	}
+}
+
+func TestNormalizeCreditCard(t *testing.T) {
+	cc := &models.CreditCard{
+		CardNumber: " 4111-1111 1111-1111 ",
+		ExpiryDate: "3/2029",
+		CVV:        " 123 ",
+		CardHolder: "  john   doe ",
+	}
+	NormalizeCreditCard(cc)
+	assert.Equal(t, "4111111111111111", cc.CardNumber)
+	assert.Equal(t, "03/29", cc.ExpiryDate)
+	assert.Equal(t, "123", cc.CVV)
+	assert.Equal(t, "JOHN DOE", cc.CardHolder)
+	assert.Equal(t, BrandVisa, cc.Brand)
+}
+
+func TestValidateCreditCard(t *testing.T) {
+	tests := []struct {
+		name       string
+		card       models.CreditCard
+		wantFields []string
+	}{
+		{
+			name: "Valid Visa",
+			card: models.CreditCard{
+				CardNumber: "4111111111111111",
+				ExpiryDate: "12/29",
+				CVV:        "123",
+				CardHolder: "JOHN DOE",
+			},
+		},
+		{
+			name: "Valid Amex",
+			card: models.CreditCard{
+				CardNumber: "378282246310005",
+				ExpiryDate: "12/2029",
+				CVV:        "1234",
+				CardHolder: "JANE O'NEIL",
+			},
+		},
+		{
+			name: "Amex with three digit CVV",
+			card: models.CreditCard{
+				CardNumber: "378282246310005",
+				ExpiryDate: "12/29",
+				CVV:        "123",
+				CardHolder: "JANE DOE",
+			},
+			wantFields: []string{"cvv"},
+		},
+		{
+			name: "Everything invalid",
+			card: models.CreditCard{
+				CardNumber: "1234567898765432",
+				ExpiryDate: "13/29",
+				CVV:        "12a",
+				CardHolder: "",
+			},
+			wantFields: []string{"card_holder", "card_number", "cvv", "expiry_date"},
+		},
+		{
+			name: "Wrong length",
+			card: models.CreditCard{
+				CardNumber: "41111111111",
+				ExpiryDate: "12/29",
+				CVV:        "123",
+				CardHolder: "JOHN DOE",
+			},
+			wantFields: []string{"card_number"},
+		},
+		{
+			name: "Accented holder at the length limit",
+			card: models.CreditCard{
+				CardNumber: "4111111111111111",
+				ExpiryDate: "12/29",
+				CVV:        "123",
+				CardHolder: "ÉLODIE ÉMILIE CÉLINE DUPRÉ",
+			},
+		},
+		{
+			name: "Holder too long",
+			card: models.CreditCard{
+				CardNumber: "4111111111111111",
+				ExpiryDate: "12/29",
+				CVV:        "123",
+				CardHolder: "ÉLODIE ÉMILIE CÉLINE DUPRÉS",
+			},
+			wantFields: []string{"card_holder"},
+		},
+		{
+			name: "Holder with digits",
+			card: models.CreditCard{
+				CardNumber: "4111111111111111",
+				ExpiryDate: "12/29",
+				CVV:        "123",
+				CardHolder: "JOHN DOE 2",
+			},
+			wantFields: []string{"card_holder"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := ValidateCreditCard(&tt.card)
+				if len(tt.wantFields) == 0 {
+					assert.NoError(t, err)
+					return
+				}
+				var fe FieldErrors
+				if !assert.ErrorAs(t, err, &fe) {
+					return
+				}
+				var got []string
+				for f := range fe {
+					got = append(got, f)
+				}
+				assert.ElementsMatch(t, tt.wantFields, got)
+			},
+		)
+	}
+}